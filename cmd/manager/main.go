@@ -17,18 +17,50 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	goflag "flag"
 	"fmt"
+	"net"
 	"os"
 	"runtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-logr/glogr"
 	flag "github.com/spf13/pflag"
+	"github.com/wave-k8s/wave/pkg/admin"
 	"github.com/wave-k8s/wave/pkg/apis"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/pkg/capabilities"
+	"github.com/wave-k8s/wave/pkg/concurrency"
 	"github.com/wave-k8s/wave/pkg/controller"
+	"github.com/wave-k8s/wave/pkg/controller/rollback"
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/fanout"
+	"github.com/wave-k8s/wave/pkg/faults"
+	"github.com/wave-k8s/wave/pkg/graph"
+	"github.com/wave-k8s/wave/pkg/health"
+	"github.com/wave-k8s/wave/pkg/memory"
+	"github.com/wave-k8s/wave/pkg/metadata"
+	"github.com/wave-k8s/wave/pkg/notify"
+	"github.com/wave-k8s/wave/pkg/plugin"
+	"github.com/wave-k8s/wave/pkg/policy"
+	"github.com/wave-k8s/wave/pkg/profiling"
+	"github.com/wave-k8s/wave/pkg/rpc"
+	"github.com/wave-k8s/wave/pkg/scope"
+	"github.com/wave-k8s/wave/pkg/sigv4"
+	"github.com/wave-k8s/wave/pkg/stream"
+	"github.com/wave-k8s/wave/pkg/trigger"
 	"github.com/wave-k8s/wave/pkg/webhook"
+	"github.com/wave-k8s/wave/pkg/webhook/hashing"
+	"github.com/wave-k8s/wave/pkg/webhook/protection"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
@@ -39,14 +71,130 @@ var (
 	leaderElection          = flag.Bool("leader-election", false, "Should the controller use leader election")
 	leaderElectionID        = flag.String("leader-election-id", "", "Name of the configmap used by the leader election system")
 	leaderElectionNamespace = flag.String("leader-election-namespace", "", "Namespace for the configmap used by the leader election system")
+	leaseDuration           = flag.Duration("leader-election-lease-duration", 15*time.Second, "How long standby instances wait after the last renewal of the leader's lock before taking it over")
+	renewDeadline           = flag.Duration("leader-election-renew-deadline", 10*time.Second, "How long the leader retries renewing its lock before giving up leadership, must be less than the lease duration")
+	retryPeriod             = flag.Duration("leader-election-retry-period", 2*time.Second, "How long instances wait between attempts to acquire or renew the leader's lock")
 	syncPeriod              = flag.Duration("sync-period", 5*time.Minute, "Reconcile sync period")
 	showVersion             = flag.Bool("version", false, "Show version and exit")
+	datadogAPIKey           = flag.String("datadog-api-key", "", "API key used to send rollout events to Datadog (defaults to $DD_API_KEY)")
+	datadogSite             = flag.String("datadog-site", notify.DefaultDatadogSite, "Datadog site to send rollout events to")
+	datadogTags             = flag.StringSlice("datadog-tags", []string{}, "Additional tags to add to Datadog events")
+	notificationConfig      = flag.String("notification-config", "", "Path to a file configuring notification providers and routes")
+	debouncePeriod          = flag.Duration("debounce-period", time.Minute, "How long the configuration of workloads with the debounced trigger policy must remain unchanged before Wave rolls it out")
+	reconcileTimeout        = flag.Duration("reconcile-timeout", time.Minute, "Maximum time a single reconcile, including every API call it makes, may take (0 disables the limit)")
+	antiEntropyInterval     = flag.Duration("anti-entropy-interval", 0, "How often to reconcile every tracked workload regardless of events, repairing hashes which drifted (0 disables the audit)")
+	restartSpreadInterval   = flag.Duration("restart-spread-interval", 0, "Minimum time between two rollouts triggered by Wave (0 disables spreading)")
+	restartSpreadPodStartup = flag.Duration("restart-spread-pod-startup", 0, "Time a Pod is expected to take to become ready; when set, rollouts are spread by the time each is estimated to take from its replicas, surge, maxUnavailable and minReadySeconds, if longer than --restart-spread-interval")
+	capacityMaxPendingPods  = flag.Int("capacity-max-pending-pods", 0, "Defer rollouts while more than this many Pods are Pending (0 disables the check)")
+	capacityMinHeadroom     = flag.Int("capacity-min-headroom-percent", 0, "Defer rollouts while less than this percentage of node CPU or memory is unrequested (0 disables the check)")
+	namespacePriority       = flag.Bool("namespace-priority", false, "Reconcile workloads in Namespaces with a higher wave.pusher.com/priority label first (requires permission to watch Namespaces)")
+	maxReconciles           = flag.Int("max-concurrent-reconciles", 1, "Maximum number of workloads of each kind reconciled at once")
+	minReconciles           = flag.Int("min-concurrent-reconciles", 1, "Minimum number of workloads of each kind reconciled at once when --auto-tune-concurrency is set")
+	fanOutWorkers           = flag.Int("fan-out-workers", 0, "Number of workers reconciling the consumers of a changed ConfigMap or Secret concurrently, shared by every kind of workload (0 reconciles them through each controller's queue)")
+	autoTuneConcurrency     = flag.Bool("auto-tune-concurrency", false, "Tune the number of workloads reconciled at once between --min-concurrent-reconciles and --max-concurrent-reconciles to the depth of the queue and the latency of the API server")
+	latencyTarget           = flag.Duration("concurrency-latency-target", 250*time.Millisecond, "Average latency of the API server above which --auto-tune-concurrency reconciles fewer workloads at once (0 ignores the latency)")
+	maxConcurrentRollouts   = flag.Int("max-concurrent-rollouts", 0, "Maximum number of rollouts triggered by Wave in flight at once across all workloads (0 disables the limit)")
+	capacityRetryInterval   = flag.Duration("capacity-retry-interval", 30*time.Second, "How long to wait before re-checking cluster capacity for a deferred rollout")
+	onDeleteMaxUnavailable  = flag.Int("ondelete-max-unavailable", 0, "Delete outdated Pods of StatefulSets and DaemonSets using the OnDelete update strategy, keeping at most this many Pods of each unavailable (0 disables deleting Pods)")
+	onDeleteRetryInterval   = flag.Duration("ondelete-retry-interval", 10*time.Second, "How often to check the Pods of an OnDelete workload while Wave rolls it out")
+	daemonSetMaxUnavailable = flag.String("daemonset-max-unavailable", "", "Cap the Pods of a DaemonSet the rollouts Wave triggers may take down at once, as a number or a percentage of its desired Pods, e.g. 10% (empty leaves it to the DaemonSet)")
+	daemonSetRetryInterval  = flag.Duration("daemonset-retry-interval", 10*time.Second, "How often to check whether the rollout of a capped DaemonSet is complete, to restore its maxUnavailable")
+	blueGreen               = flag.Bool("blue-green-rollouts", false, "Allow Deployments to opt into blue/green rollouts with the wave.pusher.com/restart-strategy annotation (requires permission to create Deployments and update Services)")
+	blueGreenRetryInterval  = flag.Duration("blue-green-retry-interval", 10*time.Second, "How often to check whether the copy of a Deployment rolled out blue/green is ready")
+	restartHooks            = flag.Bool("restart-hooks", false, "Allow workloads to run Jobs before and after their rollouts with the wave.pusher.com/pre-restart-hook and post-restart-hook annotations (requires permission to create Jobs)")
+	restartHookInterval     = flag.Duration("restart-hook-retry-interval", 10*time.Second, "How often to check on the Job of a restart hook while it runs")
+	rollbacks               = flag.Bool("rollbacks", false, "Restore the snapshotted ConfigMaps and Secrets of workloads named by Rollback resources (requires the Rollback CRD)")
+	awaitSecretData         = flag.Duration("await-secret-data-timeout", 0, "Hold back the first configuration hash of workloads consuming TLS Secrets without a certificate, or Secrets annotated wave.pusher.com/await-data without data, for up to this long after the Secrets are created (0 disables waiting)")
+	initialRolloutWait      = flag.Duration("initial-rollout-wait", 0, "Hold back the first configuration hash of newly created workloads for up to this long while their initial rollout completes (0 disables waiting)")
+	holdRecreate            = flag.Bool("hold-recreate-rollouts", false, "Hold back the rollouts of Deployments with the Recreate strategy unless they allow downtime with the wave.pusher.com/allow-downtime annotation or are in their wave.pusher.com/maintenance-window")
+	holdInFlight            = flag.Bool("hold-in-flight-rollouts", false, "Hold back new configuration hashes of workloads while the rollout of their previous hash is in progress, rolling out the latest hash once it completes or fails")
+	holdFailed              = flag.Bool("hold-failed-rollouts", false, "With --hold-in-flight-rollouts, keep holding back new configuration hashes of Deployments whose rollout exceeded its progress deadline")
+	canaries                = flag.Bool("canaries", false, "Allow workloads to evaluate new configurations in a canary before rolling them out with the wave.pusher.com/canary annotation (requires permission to create Deployments and list Pods)")
+	canaryRetryInterval     = flag.Duration("canary-retry-interval", 10*time.Second, "How often to check whether a canary is Ready")
+	canaryTimeout           = flag.Duration("canary-timeout", 5*time.Minute, "How long a canary may take to become Ready and pass its probe before it fails")
+	canaryProbeTimeout      = flag.Duration("canary-probe-timeout", 5*time.Second, "Timeout of each request of a canary's probe URL")
+	restartExecutor         = flag.String("restart-executor", "patch", "How Wave rolls out new configuration hashes: patch updates the pod template, delete-pods deletes the workload's Pods and webhook posts the rollout to --restart-executor-url")
+	restartExecutorURL      = flag.String("restart-executor-url", "", "URL of an HTTP endpoint, such as an external deployment system, the webhook executor posts each rollout to")
+	restartExecutorAuth     = flag.String("restart-executor-authorization", "", "Authorization header sent with each rollout posted to --restart-executor-url (defaults to $WAVE_RESTART_EXECUTOR_AUTHORIZATION)")
+	restartExecutorTimeout  = flag.Duration("restart-executor-timeout", 10*time.Second, "Maximum time allowed for posting a single rollout to --restart-executor-url")
+	extraURLs               = flag.Bool("extra-urls", false, "Allow workloads to list configuration fetched from outside the cluster with the wave.pusher.com/extra-urls annotation, restarting them when its responses change")
+	extraURLPollInterval    = flag.Duration("extra-url-poll-interval", time.Minute, "How often to fetch the extra URLs of workloads again")
+	extraURLTimeout         = flag.Duration("extra-url-timeout", 10*time.Second, "Maximum time allowed for fetching a single extra URL")
+	extraURLPrefixes        = flag.StringSlice("extra-url-allowed-prefixes", []string{}, "Prefixes of the extra URLs Wave may fetch, e.g. https://config.internal/ (defaults to every http and https URL)")
+	sourcePlugins           = flag.StringArray("source-plugin", []string{}, "Trigger-source plugin workloads can reference with the wave.pusher.com/plugin-sources annotation, as NAME=exec:PATH or NAME=grpc:ADDRESS (repeat the flag for each plugin)")
+	pluginPollInterval      = flag.Duration("plugin-poll-interval", time.Minute, "How often to compute the versions of the plugin sources of workloads again")
+	pluginTimeout           = flag.Duration("plugin-timeout", 10*time.Second, "Maximum time allowed for a single call of a trigger-source plugin")
+	killSwitch              = flag.String("kill-switch", "", "Namespace and name, as namespace/name, of a ConfigMap which stops Wave changing anything in the cluster while its \"disabled\" key is \"true\", for example wave-system/wave-killswitch")
+	snapshotRevisions       = flag.Int("config-snapshot-revisions", 0, "Snapshot the data of the ConfigMaps and Secrets of a workload when Wave triggers its rollout, keeping this many revisions of each (0 disables snapshots; requires permission to create ConfigMaps and Secrets)")
+	policyURL               = flag.String("policy-url", "", "URL of an HTTP endpoint that decides whether each rollout may proceed")
+	policyOPAAddress        = flag.String("policy-opa-address", "", "Address of an Open Policy Agent server that decides whether each rollout may proceed")
+	policyOPAPath           = flag.String("policy-opa-path", "wave/rollout", "Path of the OPA policy document evaluated for each rollout")
+	policyTimeout           = flag.Duration("policy-timeout", 5*time.Second, "Maximum time allowed for a single policy evaluation")
+	policyDeferInterval     = flag.Duration("policy-defer-interval", 5*time.Minute, "How long to wait before re-evaluating a rollout deferred by the policy")
+	policyFailOpen          = flag.Bool("policy-fail-open", false, "Allow rollouts when the policy cannot be evaluated")
+	auditURL                = flag.String("audit-url", "", "URL of an HTTP endpoint to post a JSON record of every rollout decision to")
+	auditAuthorization      = flag.String("audit-authorization", "", "Authorization header sent with each audit record posted to --audit-url (defaults to $WAVE_AUDIT_AUTHORIZATION)")
+	auditS3Bucket           = flag.String("audit-s3-bucket", "", "Amazon S3 bucket to store a JSON record of every rollout decision in")
+	auditS3Region           = flag.String("audit-s3-region", "", "Region of the --audit-s3-bucket (defaults to $AWS_REGION)")
+	auditGCSBucket          = flag.String("audit-gcs-bucket", "", "Google Cloud Storage bucket to store a JSON record of every rollout decision in")
+	auditWrites             = flag.Bool("audit-writes", false, "Also send the audit sink a record of every write Wave makes to the cluster")
+	auditPrefix             = flag.String("audit-prefix", "wave", "Prefix of the objects audit records are stored as")
+	triggerBindAddress      = flag.String("trigger-bind-address", "", "Address to receive trigger requests from external systems on, e.g. :8081 (empty disables the receiver)")
+	triggerToken            = flag.String("trigger-token", "", "Bearer token trigger requests must present (defaults to $WAVE_TRIGGER_TOKEN)")
+	adminBindAddress        = flag.String("admin-bind-address", "", "Address to serve the admin API pausing, resuming and restarting workloads on, e.g. :8084 (empty disables the API)")
+	grpcBindAddress         = flag.String("grpc-bind-address", "", "Address to serve the gRPC integration API on, e.g. :8086 (empty disables the API)")
+	grpcToken               = flag.String("grpc-token", "", "Bearer token allowing every gRPC method (defaults to $WAVE_GRPC_TOKEN)")
+	grpcReadToken           = flag.String("grpc-read-token", "", "Bearer token only allowing gRPC queries (defaults to $WAVE_GRPC_READ_TOKEN)")
+	grpcTLSCert             = flag.String("grpc-tls-cert", "", "TLS certificate to serve the gRPC API with (plaintext if empty)")
+	grpcTLSKey              = flag.String("grpc-tls-key", "", "TLS key to serve the gRPC API with")
+	streamBindAddress       = flag.String("stream-bind-address", "", "Address to stream rollout decisions as Server-Sent Events on, e.g. :8085 (empty disables the stream)")
+	streamToken             = flag.String("stream-token", "", "Bearer token decision stream subscribers must present (defaults to $WAVE_STREAM_TOKEN)")
+	adminToken              = flag.String("admin-token", "", "Bearer token admin requests must present (defaults to $WAVE_ADMIN_TOKEN)")
+	once                    = flag.Bool("once", false, "Reconcile every tracked workload a single time and exit, with a non-zero status if any failed")
+	shadow                  = flag.Bool("shadow", false, "Never write to the cluster and compare the rollouts Wave would trigger against those of the active instance instead")
+	shadowGrace             = flag.Duration("shadow-grace", time.Minute, "How long a shadow instance waits for the active instance to trigger the same rollout before counting a divergence")
+	enableDeployments       = flag.Bool("enable-deployments", true, "Manage Deployments; when false the Deployment controller is not registered and Wave needs no access to Deployments")
+	enableStatefulSets      = flag.Bool("enable-statefulsets", true, "Manage StatefulSets; when false the StatefulSet controller is not registered and Wave needs no access to StatefulSets")
+	enableDaemonSets        = flag.Bool("enable-daemonsets", true, "Manage DaemonSets; when false the DaemonSet controller is not registered and Wave needs no access to DaemonSets")
+	namespaces              = flag.StringSlice("namespaces", []string{}, "Namespaces to manage workloads in, allowing Wave to run with namespaced Roles only (defaults to all namespaces)")
+	scopeWorkloadCache      = flag.Bool("scope-workload-cache", false, "Only cache the Deployments, StatefulSets and DaemonSets with Wave's annotation or finalizer, saving memory on large clusters")
+	memoryLimit             = flag.String("memory-limit", "", "Memory Wave must stay under, e.g. 512Mi; close to it Wave sheds its caches rather than being OOMKilled (empty disables the guard)")
+	memorySoftLimitPercent  = flag.Int("memory-soft-limit-percent", 90, "Percentage of --memory-limit above which Wave sheds its caches")
+	memoryCheckInterval     = flag.Duration("memory-check-interval", 10*time.Second, "How often Wave checks its memory usage against --memory-limit")
+	memoryLargeSecretBytes  = flag.Int("memory-large-secret-bytes", 64*1024, "Size of the data above which cached Secrets are read on demand while Wave is close to --memory-limit")
+	secretMetadataOnly      = flag.Bool("secret-metadata-only", false, "Only list and watch the metadata of Secrets, hashing them by resourceVersion (requires Kubernetes 1.15+)")
+	secretDenyNames         = flag.StringSlice("secret-deny-names", []string{}, "Glob patterns of names of sensitive Secrets Wave must never track or update, e.g. *-root-ca")
+	secretDenySelectors     = flag.StringArray("secret-deny-selectors", []string{}, "Label selectors of sensitive Secrets Wave must never track or update, e.g. security.example.com/critical=true (repeat the flag for each selector)")
+	statusAnnotation        = flag.Bool("status-annotation", false, "Summarize Wave's view of each workload in its wave.pusher.com/status annotation")
+	trackSATokens           = flag.Bool("track-service-account-tokens", false, "Track the legacy token Secrets of ServiceAccounts that workloads mount explicitly, restarting them when the tokens are rotated")
+	sidecarContainers       = flag.StringSlice("sidecar-containers", core.DefaultSidecarContainers, "Glob patterns of names of injected sidecar containers whose ConfigMaps and Secrets Wave doesn't track (empty tracks every container)")
+	sidecarConfigMaps       = flag.StringSlice("sidecar-configmaps", core.DefaultSidecarConfigMaps, "Glob patterns of names of ConfigMaps managed by a service mesh control plane that Wave doesn't track (empty tracks every ConfigMap)")
+	csiSecretsStore         = flag.Bool("csi-secrets-store", false, "Restart workloads when the CSI Secrets Store driver rotates the objects mounted into their Pods (requires the SecretProviderClassPodStatus CRD)")
+	impersonateSA           = flag.String("impersonate-service-account", "", "Name of a service account to impersonate in each namespace when writing to it (empty writes as Wave's own identity)")
+	graphBindAddress        = flag.String("graph-bind-address", "", "Address to serve the workload dependency graph and controller state on, e.g. :8082 (empty disables the endpoints)")
+	protectReferenced       = flag.Bool("protect-referenced-config", false, "Serve a validating webhook which denies deleting ConfigMaps and Secrets referenced by running workloads")
+	hashOnAdmission         = flag.Bool("hash-on-admission", false, "Serve a mutating webhook which places the configuration hash on workloads as they are created, from the controllers' cache")
+	webhookLatencySLO       = flag.Duration("webhook-latency-slo", webhook.DefaultLatencySLO, "Latency an admission call may take before it counts against the webhook latency SLO")
+	metricsBindAddress      = flag.String("metrics-bind-address", ":8080", "Address to serve Prometheus metrics on (0 disables the endpoint)")
+	healthBindAddress       = flag.String("health-bind-address", "", "Address to serve the /healthz and /readyz probes on, e.g. :8083 (empty disables the probes)")
+	webhookBindAddress      = flag.String("webhook-bind-address", ":9876", "Address the webhook server listens on, e.g. [::]:9876")
+	pprofBindAddress        = flag.String("pprof-bind-address", "", "Address to serve runtime profiles under /debug/pprof/ on, e.g. localhost:6060 (empty disables profiling)")
+	injectErrorRate         = flag.Float64("inject-error-rate", 0, "Fraction of API calls which fail with an injected internal error, for resilience testing only (defaults to $WAVE_INJECT_ERROR_RATE)")
+	injectConflictRate      = flag.Float64("inject-conflict-rate", 0, "Fraction of updates and patches which fail with an injected conflict, for resilience testing only (defaults to $WAVE_INJECT_CONFLICT_RATE)")
+	injectLatency           = flag.Duration("inject-latency", 0, "Maximum random latency injected into each API call, for resilience testing only (defaults to $WAVE_INJECT_LATENCY)")
+	webhookCertDir          = flag.String("webhook-cert-dir", "", "Directory containing the webhook server's tls.crt and tls.key (defaults to $TMPDIR/k8s-webhook-server/serving-certs)")
 )
 
 func main() {
 	// Setup flags
 	goflag.Lookup("logtostderr").Value.Set("true")
 	flag.CommandLine.AddGoFlagSet(goflag.CommandLine)
+	for _, name := range []string{"inject-error-rate", "inject-conflict-rate", "inject-latency"} {
+		flag.CommandLine.MarkHidden(name)
+	}
+	if len(os.Args) > 1 && os.Args[1] == "manifests" {
+		os.Exit(printManifests(os.Args[2:]))
+	}
 	flag.Parse()
 
 	if *showVersion {
@@ -64,15 +212,137 @@ func main() {
 		log.Error(err, "unable to set up client config")
 		os.Exit(1)
 	}
+	// Tag Wave's writes so that they can be found in the API server's audit log
+	cfg.Wrap(audit.WrapTransport)
+
+	// Measure the latency of the API server to tune the reconcile workers to
+	reconciles := concurrency.Options{Max: *maxReconciles}
+	if *autoTuneConcurrency {
+		reconciles.Min = *minReconciles
+		reconciles.LatencyTarget = *latencyTarget
+		reconciles.Monitor = concurrency.NewMonitor()
+		cfg.Wrap(reconciles.Monitor.WrapTransport)
+	}
+
+	webhookHost, webhookPort, err := splitHostPort(*webhookBindAddress)
+	if err != nil {
+		log.Error(err, "invalid webhook bind address")
+		os.Exit(1)
+	}
+
+	kinds := enabledKinds()
+	if len(kinds) == 0 {
+		log.Error(fmt.Errorf("at least one of --enable-deployments, --enable-statefulsets and --enable-daemonsets must be true"), "invalid workload kinds")
+		os.Exit(1)
+	}
+	log.Info("managing workloads", "kinds", kinds)
+
+	if *leaderElection && (*renewDeadline >= *leaseDuration || *retryPeriod >= *renewDeadline) {
+		log.Error(fmt.Errorf("--leader-election-retry-period must be less than --leader-election-renew-deadline, which must be less than --leader-election-lease-duration"), "invalid leader election configuration")
+		os.Exit(1)
+	}
 
 	// Create a new Cmd to provide shared dependencies and start components
 	log.Info("setting up manager")
-	mgr, err := manager.New(cfg, manager.Options{
+	mgrOpts := manager.Options{
 		LeaderElection:          *leaderElection,
 		LeaderElectionID:        *leaderElectionID,
 		LeaderElectionNamespace: *leaderElectionNamespace,
+		LeaseDuration:           leaseDuration,
+		RenewDeadline:           renewDeadline,
+		RetryPeriod:             retryPeriod,
 		SyncPeriod:              syncPeriod,
-	})
+		MetricsBindAddress:      *metricsBindAddress,
+		Host:                    webhookHost,
+		Port:                    webhookPort,
+		CertDir:                 *webhookCertDir,
+	}
+	switch {
+	case len(*namespaces) == 1:
+		log.Info("managing workloads in a single namespace", "namespace", (*namespaces)[0])
+		mgrOpts.Namespace = (*namespaces)[0]
+	case len(*namespaces) > 1:
+		log.Info("managing workloads in multiple namespaces", "namespaces", *namespaces)
+		mgrOpts.NewCache = cache.MultiNamespacedCacheBuilder(*namespaces)
+	}
+	var killSwitchKey types.NamespacedName
+	if *killSwitch != "" {
+		parts := strings.SplitN(*killSwitch, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			log.Error(fmt.Errorf("--kill-switch must be of the form namespace/name"), "invalid kill switch configuration")
+			os.Exit(1)
+		}
+		killSwitchKey = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+		if len(*namespaces) > 0 && !isManaged(*namespaces, killSwitchKey.Namespace) {
+			log.Error(fmt.Errorf("the namespace of the kill switch must be one of --namespaces"), "invalid kill switch configuration")
+			os.Exit(1)
+		}
+	}
+	if len(*namespaces) > 0 && *capacityMinHeadroom > 0 {
+		log.Error(fmt.Errorf("--capacity-min-headroom-percent requires listing Nodes, which namespaced Roles cannot grant"), "invalid capacity configuration")
+		os.Exit(1)
+	}
+	// Adapt to the APIs served by the cluster
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		log.Error(err, "unable to set up discovery client")
+		os.Exit(1)
+	}
+	caps, err := capabilities.Discover(discoveryClient)
+	if err != nil {
+		log.Error(err, "unable to discover the APIs served by the cluster")
+		os.Exit(1)
+	}
+	for _, kind := range kinds {
+		version, err := caps.WorkloadVersion(kind)
+		if err != nil {
+			log.Error(err, "unable to manage workloads", "kind", kind)
+			os.Exit(1)
+		}
+		log.Info("discovered workload API", "kind", kind, "version", version.String(), "serverVersion", caps.String())
+	}
+	if *secretMetadataOnly && !caps.AtLeast(1, 15) {
+		log.Error(fmt.Errorf("--secret-metadata-only requires Kubernetes 1.15+, the cluster runs %s", caps), "invalid Secret configuration")
+		os.Exit(1)
+	}
+	if *csiSecretsStore && !caps.Serves(core.SecretProviderClassPodStatusGVK.GroupVersion(), "secretproviderclasspodstatuses") {
+		log.Info("not watching objects mounted by the CSI Secrets Store driver, the cluster doesn't serve SecretProviderClassPodStatuses")
+		*csiSecretsStore = false
+	}
+	mgrOpts.NewClient = caps.NewClient
+
+	if *secretMetadataOnly {
+		log.Info("watching the metadata of Secrets only")
+		mgrOpts.NewCache = metadata.NewSecretCache
+		if len(*namespaces) > 1 {
+			mgrOpts.NewCache = metadata.MultiNamespacedSecretCacheBuilder(*namespaces)
+		}
+	}
+	if *scopeWorkloadCache {
+		log.Info("only caching workloads in Wave's scope")
+		mgrOpts.NewCache = scope.CacheBuilder(mgrOpts.NewCache, *namespaces)
+	}
+	var guard *memory.Guard
+	if *memoryLimit != "" {
+		limit, err := resource.ParseQuantity(*memoryLimit)
+		if err != nil || limit.Sign() <= 0 {
+			log.Error(fmt.Errorf("--memory-limit must be a positive quantity, e.g. 512Mi"), "invalid memory configuration")
+			os.Exit(1)
+		}
+		log.Info("shedding caches close to the memory limit", "limit", limit.String(), "softLimitPercent", *memorySoftLimitPercent)
+		guard = memory.NewGuard(memory.Options{
+			Limit:            uint64(limit.Value()),
+			SoftLimitPercent: *memorySoftLimitPercent,
+			Interval:         *memoryCheckInterval,
+			LargeSecretBytes: *memoryLargeSecretBytes,
+		})
+		// The data of Secrets is only cached when not watching their
+		// metadata, by a single informer when not watching several namespaces
+		if !*secretMetadataOnly && len(*namespaces) <= 1 {
+			mgrOpts.NewCache = memory.CacheBuilder(mgrOpts.NewCache, guard)
+		}
+	}
+	mgr, err := manager.New(cfg, mgrOpts)
 	if err != nil {
 		log.Error(err, "unable to set up overall controller manager")
 		os.Exit(1)
@@ -87,18 +357,503 @@ func main() {
 		os.Exit(1)
 	}
 
+	opts := []core.Option{core.WithCapabilities(caps)}
+	faultOptions, err := faults.OptionsFromEnv(os.Getenv)
+	if err != nil {
+		log.Error(err, "invalid fault injection configuration")
+		os.Exit(1)
+	}
+	if *injectErrorRate > 0 {
+		faultOptions.ErrorRate = *injectErrorRate
+	}
+	if *injectConflictRate > 0 {
+		faultOptions.ConflictRate = *injectConflictRate
+	}
+	if *injectLatency > 0 {
+		faultOptions.Latency = *injectLatency
+	}
+	if err := faultOptions.Validate(); err != nil {
+		log.Error(err, "invalid fault injection configuration")
+		os.Exit(1)
+	}
+	if faultOptions.Enabled() {
+		log.Info("INJECTING FAULTS INTO API CALLS, DO NOT USE IN PRODUCTION", "errorRate", faultOptions.ErrorRate, "conflictRate", faultOptions.ConflictRate, "latency", faultOptions.Latency)
+		opts = append(opts, core.WithFaultInjection(faults.NewInjector(faultOptions, time.Now().UnixNano())))
+	}
+	opts = append(opts, core.WithReconcileTimeout(*reconcileTimeout))
+	opts = append(opts, core.WithDebouncePeriod(*debouncePeriod))
+
+	// Setup notifications
+	if *secretMetadataOnly {
+		opts = append(opts, core.WithSecretMetadataOnly())
+	}
+	if *csiSecretsStore {
+		log.Info("watching objects mounted by the CSI Secrets Store driver")
+		opts = append(opts, core.WithCSISecretsStore())
+	}
+	denyList, err := core.NewSecretDenyList(*secretDenyNames, *secretDenySelectors)
+	if err != nil {
+		log.Error(err, "invalid Secret deny list")
+		os.Exit(1)
+	}
+	opts = append(opts, core.WithSecretDenyList(denyList))
+	if *trackSATokens {
+		log.Info("tracking ServiceAccount token Secrets")
+		opts = append(opts, core.WithServiceAccountTokens())
+	}
+	if *statusAnnotation {
+		log.Info("recording the status of workloads in their annotations")
+		opts = append(opts, core.WithStatusAnnotation())
+	}
+	sidecars, err := core.NewSidecarOptions(*sidecarContainers, *sidecarConfigMaps)
+	if err != nil {
+		log.Error(err, "invalid sidecar name patterns")
+		os.Exit(1)
+	}
+	opts = append(opts, core.WithSidecars(sidecars))
+	if *impersonateSA != "" {
+		log.Info("impersonating a service account in each namespace for writes", "serviceAccount", *impersonateSA)
+	}
+	opts = append(opts, core.WithImpersonation(core.ImpersonationOptions{
+		ServiceAccount: *impersonateSA,
+		Config:         cfg,
+		Scheme:         mgr.GetScheme(),
+		Mapper:         mgr.GetRESTMapper(),
+	}))
+	var notifiers notify.Multi
+	if *datadogAPIKey == "" {
+		*datadogAPIKey = os.Getenv("DD_API_KEY")
+	}
+	if *datadogAPIKey != "" {
+		log.Info("sending rollout events to Datadog", "site", *datadogSite)
+		notifiers = append(notifiers, notify.NewDatadog(*datadogAPIKey, *datadogSite, *datadogTags))
+	}
+	if *notificationConfig != "" {
+		log.Info("loading notification config", "path", *notificationConfig)
+		notificationCfg, err := notify.LoadConfig(*notificationConfig)
+		if err != nil {
+			log.Error(err, "unable to load notification config")
+			os.Exit(1)
+		}
+		router, err := notify.NewRouter(notificationCfg)
+		if err != nil {
+			log.Error(err, "unable to set up notification providers")
+			os.Exit(1)
+		}
+		notifiers = append(notifiers, router)
+	}
+	if len(notifiers) > 0 {
+		opts = append(opts, core.WithNotifier(notifiers))
+	}
+
+	opts = append(opts, core.WithCapacityOptions(core.CapacityOptions{
+		SpreadInterval:     *restartSpreadInterval,
+		PodStartupTime:     *restartSpreadPodStartup,
+		MaxPendingPods:     *capacityMaxPendingPods,
+		MinHeadroomPercent: *capacityMinHeadroom,
+		RetryInterval:      *capacityRetryInterval,
+	}))
+	if *maxConcurrentRollouts > 0 {
+		log.Info("limiting concurrent rollouts", "max", *maxConcurrentRollouts)
+	}
+	opts = append(opts, core.WithRolloutBudget(core.NewRolloutBudget(*maxConcurrentRollouts, *capacityRetryInterval)))
+	if *namespacePriority {
+		log.Info("reconciling workloads by Namespace priority")
+		opts = append(opts, core.WithNamespacePriority())
+	}
+
+	if reconciles.Tuned() {
+		log.Info("tuning concurrent reconciles", "min", reconciles.Min, "max", reconciles.Max, "latencyTarget", reconciles.LatencyTarget.String())
+	} else if reconciles.Max > 1 {
+		log.Info("reconciling workloads concurrently", "max", reconciles.Max)
+	}
+	opts = append(opts, core.WithConcurrency(reconciles))
+	if *fanOutWorkers > 0 {
+		log.Info("reconciling the consumers of changed ConfigMaps and Secrets concurrently", "workers", *fanOutWorkers)
+		pool := fanout.NewPool(*fanOutWorkers)
+		if err := mgr.Add(pool); err != nil {
+			log.Error(err, "unable to register the fan-out pool to the manager")
+			os.Exit(1)
+		}
+		opts = append(opts, core.WithFanOut(pool))
+	}
+
+	if guard != nil {
+		if err := mgr.Add(guard); err != nil {
+			log.Error(err, "unable to register the memory guard to the manager")
+			os.Exit(1)
+		}
+		opts = append(opts, core.WithMemoryGuard(guard))
+	}
+
+	if *initialRolloutWait > 0 {
+		log.Info("waiting for the initial rollouts of new workloads", "timeout", initialRolloutWait.String())
+		opts = append(opts, core.WithInitialRolloutWait(*initialRolloutWait))
+	}
+
+	if *awaitSecretData > 0 {
+		log.Info("waiting for Secrets to be populated before the first hash", "timeout", awaitSecretData.String())
+		opts = append(opts, core.WithAwaitSecretData(*awaitSecretData))
+	}
+
+	if *holdRecreate {
+		log.Info("holding back rollouts causing downtime outside of maintenance windows")
+		opts = append(opts, core.WithRecreateHold())
+	}
+
+	if *holdInFlight {
+		log.Info("holding back new hashes while rollouts are in progress", "holdFailed", *holdFailed)
+		opts = append(opts, core.WithInFlightHold(*holdFailed))
+	}
+
+	if *onDeleteMaxUnavailable > 0 {
+		log.Info("deleting outdated Pods of OnDelete workloads", "maxUnavailable", *onDeleteMaxUnavailable)
+	}
+	opts = append(opts, core.WithOnDeleteRollouts(core.OnDeleteOptions{
+		MaxUnavailable: *onDeleteMaxUnavailable,
+		RetryInterval:  *onDeleteRetryInterval,
+	}))
+	if *daemonSetMaxUnavailable != "" {
+		maxUnavailable, err := core.ParseMaxUnavailable(*daemonSetMaxUnavailable)
+		if err != nil {
+			log.Error(err, "invalid DaemonSet maxUnavailable")
+			os.Exit(1)
+		}
+		log.Info("capping the rollouts of DaemonSets", "maxUnavailable", maxUnavailable.String())
+		opts = append(opts, core.WithDaemonSetMaxUnavailable(core.DaemonSetOptions{
+			MaxUnavailable: maxUnavailable,
+			RetryInterval:  *daemonSetRetryInterval,
+		}))
+	}
+
+	if *blueGreen {
+		log.Info("allowing blue/green rollouts of Deployments")
+		opts = append(opts, core.WithBlueGreenRollouts(core.BlueGreenOptions{
+			RetryInterval: *blueGreenRetryInterval,
+		}))
+	}
+
+	if *restartHooks {
+		log.Info("running restart hooks")
+		opts = append(opts, core.WithRestartHooks(core.RestartHookOptions{
+			RetryInterval: *restartHookInterval,
+		}))
+	}
+
+	if *canaries {
+		log.Info("evaluating new configurations in canaries", "timeout", *canaryTimeout)
+		opts = append(opts, core.WithCanaries(core.CanaryOptions{
+			RetryInterval: *canaryRetryInterval,
+			Timeout:       *canaryTimeout,
+			ProbeTimeout:  *canaryProbeTimeout,
+		}))
+	}
+
+	switch *restartExecutor {
+	case "patch":
+	case "delete-pods":
+		log.Info("rolling out configuration hashes by deleting Pods")
+		opts = append(opts, core.WithPodDeleteExecutor())
+	case "webhook":
+		if *restartExecutorURL == "" {
+			log.Error(fmt.Errorf("--restart-executor=webhook requires --restart-executor-url"), "invalid restart executor configuration")
+			os.Exit(1)
+		}
+		if *restartExecutorAuth == "" {
+			*restartExecutorAuth = os.Getenv("WAVE_RESTART_EXECUTOR_AUTHORIZATION")
+		}
+		log.Info("rolling out configuration hashes with a webhook", "url", *restartExecutorURL)
+		opts = append(opts, core.WithExecutor(core.NewWebhookExecutor(*restartExecutorURL, *restartExecutorAuth, *restartExecutorTimeout)))
+	default:
+		log.Error(fmt.Errorf("unknown restart executor %q, must be patch, delete-pods or webhook", *restartExecutor), "invalid restart executor configuration")
+		os.Exit(1)
+	}
+
+	if len(*sourcePlugins) > 0 {
+		sources := make(map[string]plugin.Source)
+		for _, spec := range *sourcePlugins {
+			name, source, err := plugin.Parse(spec)
+			if err != nil {
+				log.Error(err, "invalid source plugin")
+				os.Exit(1)
+			}
+			sources[name] = source
+		}
+		log.Info("polling trigger-source plugins", "plugins", len(sources), "interval", *pluginPollInterval)
+		opts = append(opts, core.WithSourcePlugins(core.PluginOptions{
+			Sources:      sources,
+			PollInterval: *pluginPollInterval,
+			Timeout:      *pluginTimeout,
+		}))
+	}
+
+	if *extraURLs {
+		log.Info("fetching extra URLs", "interval", *extraURLPollInterval, "allowedPrefixes", *extraURLPrefixes)
+		opts = append(opts, core.WithExtraURLs(core.ExtraURLOptions{
+			PollInterval:    *extraURLPollInterval,
+			Timeout:         *extraURLTimeout,
+			AllowedPrefixes: *extraURLPrefixes,
+		}))
+	}
+
+	if *snapshotRevisions > 0 {
+		log.Info("snapshotting configuration on rollouts", "revisions", *snapshotRevisions)
+		opts = append(opts, core.WithConfigSnapshots(*snapshotRevisions))
+	}
+
+	if *killSwitch != "" {
+		log.Info("watching kill switch", "namespace", killSwitchKey.Namespace, "name", killSwitchKey.Name)
+		opts = append(opts, core.WithKillSwitch(killSwitchKey))
+	}
+
+	// Setup the rollout policy
+	var evaluator policy.Evaluator
+	switch {
+	case *policyURL != "" && *policyOPAAddress != "":
+		log.Error(fmt.Errorf("--policy-url and --policy-opa-address are mutually exclusive"), "invalid policy configuration")
+		os.Exit(1)
+	case *policyURL != "":
+		log.Info("consulting HTTP policy before rollouts", "url", *policyURL)
+		evaluator = policy.NewHTTP(*policyURL, *policyTimeout)
+	case *policyOPAAddress != "":
+		log.Info("consulting OPA policy before rollouts", "address", *policyOPAAddress, "path", *policyOPAPath)
+		evaluator = policy.NewOPA(*policyOPAAddress, *policyOPAPath, *policyTimeout)
+	}
+	opts = append(opts, core.WithPolicy(core.PolicyOptions{
+		Evaluator:     evaluator,
+		Timeout:       *policyTimeout,
+		DeferInterval: *policyDeferInterval,
+		FailOpen:      *policyFailOpen,
+	}))
+
+	// Setup the audit sink
+	var sink audit.Sink
+	switch {
+	case countSet(*auditURL, *auditS3Bucket, *auditGCSBucket) > 1:
+		log.Error(fmt.Errorf("--audit-url, --audit-s3-bucket and --audit-gcs-bucket are mutually exclusive"), "invalid audit configuration")
+		os.Exit(1)
+	case *auditURL != "":
+		log.Info("sending audit records to HTTP endpoint", "url", *auditURL)
+		if *auditAuthorization == "" {
+			*auditAuthorization = os.Getenv("WAVE_AUDIT_AUTHORIZATION")
+		}
+		sink = audit.NewHTTP(*auditURL, *auditAuthorization)
+	case *auditS3Bucket != "":
+		if *auditS3Region == "" {
+			*auditS3Region = os.Getenv("AWS_REGION")
+		}
+		credentials := sigv4.CredentialsFromEnv()
+		if *auditS3Region == "" || !credentials.Valid() {
+			log.Error(fmt.Errorf("an S3 region and AWS credentials are required"), "invalid audit configuration")
+			os.Exit(1)
+		}
+		log.Info("storing audit records in S3", "bucket", *auditS3Bucket, "region", *auditS3Region)
+		sink = audit.NewS3(*auditS3Bucket, *auditPrefix, *auditS3Region, credentials)
+	case *auditGCSBucket != "":
+		log.Info("storing audit records in GCS", "bucket", *auditGCSBucket)
+		sink, err = audit.NewGCS(context.Background(), *auditGCSBucket, *auditPrefix)
+		if err != nil {
+			log.Error(err, "unable to set up GCS audit sink")
+			os.Exit(1)
+		}
+	}
+	// A nil Broadcaster must not become a non-nil audit.Sink
+	var broadcaster *stream.Broadcaster
+	var streamed audit.Sink
+	if *streamBindAddress != "" {
+		broadcaster = stream.NewBroadcaster()
+		streamed = broadcaster
+	}
+	opts = append(opts, core.WithAudit(core.AuditOptions{
+		Sink:   sink,
+		Actor:  actor(),
+		Writes: *auditWrites,
+		Stream: streamed,
+	}))
+
+	if *shadow {
+		if *triggerBindAddress != "" {
+			log.Error(fmt.Errorf("the trigger receiver writes to workloads, which a shadow instance must not"), "invalid shadow configuration")
+			os.Exit(1)
+		}
+		if *adminBindAddress != "" {
+			log.Error(fmt.Errorf("the admin API writes to workloads, which a shadow instance must not"), "invalid shadow configuration")
+			os.Exit(1)
+		}
+		if *grpcBindAddress != "" {
+			log.Error(fmt.Errorf("the gRPC API writes to workloads, which a shadow instance must not"), "invalid shadow configuration")
+			os.Exit(1)
+		}
+		if *rollbacks {
+			log.Error(fmt.Errorf("the rollback controller writes to workloads, which a shadow instance must not"), "invalid shadow configuration")
+			os.Exit(1)
+		}
+		log.Info("running as a shadow of the active instance", "grace", *shadowGrace)
+		opts = append(opts, core.WithShadow(*shadowGrace))
+	}
+
+	// Only list the kinds of workloads Wave manages
+	opts = append(opts, core.WithWorkloadKinds(kinds...))
+
+	// The admin API and the anti-entropy audit act through Handlers of their
+	// own, outside of the registry
+	standaloneOpts := opts
+
+	// Collect the state of each controller for the state endpoint
+	registry := core.NewRegistry()
+	opts = append(opts, core.WithRegistry(registry))
+
+	if *once {
+		os.Exit(reconcileOnce(mgr, caps, opts))
+	}
+
 	// Setup all Controllers
 	log.Info("Setting up controller")
-	if err := controller.AddToManager(mgr); err != nil {
+	if err := controller.AddKindsToManager(mgr, kinds, opts...); err != nil {
 		log.Error(err, "unable to register controllers to the manager")
 		os.Exit(1)
 	}
+	if *rollbacks {
+		log.Info("carrying out Rollbacks")
+		if err := rollback.Add(mgr); err != nil {
+			log.Error(err, "unable to register the rollback controller to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *triggerBindAddress != "" {
+		if *triggerToken == "" {
+			*triggerToken = os.Getenv("WAVE_TRIGGER_TOKEN")
+		}
+		if *triggerToken == "" {
+			log.Error(fmt.Errorf("a trigger token is required"), "invalid trigger configuration")
+			os.Exit(1)
+		}
+		log.Info("setting up trigger receiver", "address", *triggerBindAddress)
+		if err := mgr.Add(trigger.NewServer(mgr.GetClient(), *triggerBindAddress, *triggerToken)); err != nil {
+			log.Error(err, "unable to register trigger receiver to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *adminBindAddress != "" {
+		if *adminToken == "" {
+			*adminToken = os.Getenv("WAVE_ADMIN_TOKEN")
+		}
+		if *adminToken == "" {
+			log.Error(fmt.Errorf("an admin token is required"), "invalid admin configuration")
+			os.Exit(1)
+		}
+		log.Info("setting up admin API", "address", *adminBindAddress)
+		h := core.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("wave"), standaloneOpts...)
+		if err := mgr.Add(admin.NewServer(h, *adminBindAddress, *adminToken)); err != nil {
+			log.Error(err, "unable to register admin API to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *grpcBindAddress != "" {
+		if *grpcToken == "" {
+			*grpcToken = os.Getenv("WAVE_GRPC_TOKEN")
+		}
+		if *grpcReadToken == "" {
+			*grpcReadToken = os.Getenv("WAVE_GRPC_READ_TOKEN")
+		}
+		if *grpcToken == "" {
+			log.Error(fmt.Errorf("a gRPC token is required"), "invalid gRPC configuration")
+			os.Exit(1)
+		}
+		if (*grpcTLSCert == "") != (*grpcTLSKey == "") {
+			log.Error(fmt.Errorf("--grpc-tls-cert and --grpc-tls-key must be given together"), "invalid gRPC configuration")
+			os.Exit(1)
+		}
+		log.Info("setting up gRPC API", "address", *grpcBindAddress, "tls", *grpcTLSCert != "")
+		h := core.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("wave"), standaloneOpts...)
+		if err := mgr.Add(rpc.NewServer(h, rpc.Options{
+			Address:      *grpcBindAddress,
+			ControlToken: *grpcToken,
+			ReadToken:    *grpcReadToken,
+			CertFile:     *grpcTLSCert,
+			KeyFile:      *grpcTLSKey,
+		})); err != nil {
+			log.Error(err, "unable to register gRPC API to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *streamBindAddress != "" {
+		if *streamToken == "" {
+			*streamToken = os.Getenv("WAVE_STREAM_TOKEN")
+		}
+		if *streamToken == "" {
+			log.Error(fmt.Errorf("a stream token is required"), "invalid stream configuration")
+			os.Exit(1)
+		}
+		log.Info("streaming rollout decisions", "address", *streamBindAddress)
+		if err := mgr.Add(stream.NewServer(broadcaster, *streamBindAddress, *streamToken)); err != nil {
+			log.Error(err, "unable to register decision stream to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *antiEntropyInterval > 0 {
+		log.Info("setting up anti-entropy audit", "interval", *antiEntropyInterval)
+		h := core.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("wave"), standaloneOpts...)
+		if err := mgr.Add(core.NewAntiEntropy(h, *antiEntropyInterval, *namespaces)); err != nil {
+			log.Error(err, "unable to register anti-entropy audit to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *graphBindAddress != "" {
+		log.Info("setting up graph endpoint", "address", *graphBindAddress)
+		if err := mgr.Add(graph.NewServer(mgr.GetClient(), *graphBindAddress, registry, core.WorkloadKinds(kinds))); err != nil {
+			log.Error(err, "unable to register graph endpoint to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *healthBindAddress != "" {
+		log.Info("setting up health probes", "address", *healthBindAddress)
+		if err := mgr.Add(health.NewServer(*healthBindAddress, mgr.GetCache())); err != nil {
+			log.Error(err, "unable to register health probes to the manager")
+			os.Exit(1)
+		}
+	}
+
+	if *pprofBindAddress != "" {
+		log.Info("setting up profiling endpoint", "address", *pprofBindAddress)
+		if err := mgr.Add(profiling.NewServer(*pprofBindAddress)); err != nil {
+			log.Error(err, "unable to register profiling endpoint to the manager")
+			os.Exit(1)
+		}
+	}
 
 	log.Info("setting up webhooks")
 	if err := webhook.AddToManager(mgr); err != nil {
 		log.Error(err, "unable to register webhooks to the manager")
 		os.Exit(1)
 	}
+	webhook.SetLatencySLO(*webhookLatencySLO)
+	if *protectReferenced {
+		log.Info("protecting referenced ConfigMaps and Secrets from deletion", "path", protection.Path)
+		if err := protection.AddToManager(mgr, core.WorkloadKinds(kinds)); err != nil {
+			log.Error(err, "unable to register deletion protection webhook to the manager")
+			os.Exit(1)
+		}
+	}
+	if *hashOnAdmission {
+		log.Info("placing configuration hashes on workloads as they are created", "path", hashing.Path)
+		index := core.NewDependencyIndex()
+		if err := index.Register(mgr.GetCache(), kinds...); err != nil {
+			log.Error(err, "unable to index the sources of workloads")
+			os.Exit(1)
+		}
+		if err := hashing.AddToManager(mgr, index, standaloneOpts...); err != nil {
+			log.Error(err, "unable to register configuration hash webhook to the manager")
+			os.Exit(1)
+		}
+	}
 
 	// Start the Cmd
 	log.Info("Starting the Cmd.")
@@ -107,3 +862,86 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+// reconcileOnce reconciles every tracked workload a single time, reading
+// from the API server as the manager's cache is never started, and returns
+// the exit code
+func reconcileOnce(mgr manager.Manager, caps *capabilities.Capabilities, opts []core.Option) int {
+	log := logf.Log.WithName("once")
+	c, err := client.New(mgr.GetConfig(), client.Options{Scheme: mgr.GetScheme(), Mapper: mgr.GetRESTMapper()})
+	if err != nil {
+		log.Error(err, "unable to set up client")
+		return 1
+	}
+
+	h := core.NewHandler(caps.Client(c), mgr.GetEventRecorderFor("wave"), opts...)
+	result, err := h.ReconcileOnce(context.Background(), *namespaces)
+	if err != nil {
+		log.Error(err, "unable to reconcile workloads")
+		return 1
+	}
+	log.Info("reconciled workloads once", "reconciled", result.Reconciled, "requeued", result.Requeued, "failed", result.Failed)
+	if result.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// enabledKinds returns the kinds of workloads whose controllers are enabled
+func enabledKinds() []string {
+	enabled := map[string]bool{"Deployment": *enableDeployments, "StatefulSet": *enableStatefulSets, "DaemonSet": *enableDaemonSets}
+	var kinds []string
+	for _, kind := range controller.Kinds {
+		if enabled[kind] {
+			kinds = append(kinds, kind)
+		}
+	}
+	return kinds
+}
+
+// isManaged returns whether the namespace is one of the namespaces
+func isManaged(namespaces []string, namespace string) bool {
+	for _, n := range namespaces {
+		if n == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// countSet returns the number of non-empty values
+func countSet(values ...string) int {
+	count := 0
+	for _, v := range values {
+		if v != "" {
+			count++
+		}
+	}
+	return count
+}
+
+// splitHostPort splits an address such as :9876 or [::1]:9876 into the host
+// and port the webhook server expects
+func splitHostPort(address string) (string, int, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return "", 0, err
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid port %q: %v", port, err)
+	}
+	return host, p, nil
+}
+
+// actor identifies this Wave instance in audit records
+func actor() string {
+	if name := os.Getenv("POD_NAME"); name != "" {
+		return name
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "wave"
+	}
+	return hostname
+}
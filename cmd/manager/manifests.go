@@ -0,0 +1,120 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/wave-k8s/wave/pkg/manifests"
+)
+
+// manifestFlags are only used to generate manifests and are not passed on
+// to Wave
+var manifestFlags = map[string]bool{
+	"name":       true,
+	"namespace":  true,
+	"image":      true,
+	"replicas":   true,
+	"webhooks":   true,
+	"kubeconfig": true,
+	"master":     true,
+}
+
+// printManifests implements `wave manifests`: it parses Wave's flags from
+// args and prints the manifests deploying Wave with them, returning the exit
+// code
+func printManifests(args []string) int {
+	name := flag.String("name", "wave", "Name of Wave's Deployment, ServiceAccount and RBAC objects")
+	namespace := flag.String("namespace", "wave", "Namespace to deploy Wave in")
+	image := flag.String("image", "quay.io/wave-k8s/wave:"+VERSION, "Container image of Wave")
+	replicas := flag.Int32("replicas", 1, "Number of replicas of Wave (more than one requires --leader-election)")
+	webhooks := flag.Bool("webhooks", false, "Deploy the deletion protection webhook (implies --protect-referenced-config)")
+	if err := flag.CommandLine.Parse(args); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *webhooks {
+		flag.CommandLine.Set("protect-referenced-config", "true")
+	}
+
+	// Only pass on the flags that were set so that Wave's defaults apply
+	waveArgs := []string{}
+	flag.CommandLine.Visit(func(f *flag.Flag) {
+		if manifestFlags[f.Name] {
+			return
+		}
+		if t := f.Value.Type(); t == "stringSlice" || t == "stringArray" {
+			// Repeat the flag for each value as array values may contain commas
+			values, err := csv.NewReader(strings.NewReader(strings.Trim(f.Value.String(), "[]"))).Read()
+			if err != nil {
+				return
+			}
+			for _, v := range values {
+				waveArgs = append(waveArgs, fmt.Sprintf("--%s=%s", f.Name, v))
+			}
+			return
+		}
+		waveArgs = append(waveArgs, fmt.Sprintf("--%s=%s", f.Name, f.Value.String()))
+	})
+
+	_, webhookPort, err := splitHostPort(*webhookBindAddress)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid webhook bind address: %v\n", err)
+		return 1
+	}
+	o := manifests.Options{
+		Name:                      *name,
+		Namespace:                 *namespace,
+		Image:                     *image,
+		Replicas:                  *replicas,
+		Args:                      waveArgs,
+		Namespaces:                *namespaces,
+		Kinds:                     enabledKinds(),
+		LeaderElection:            *leaderElection,
+		LeaderElectionNamespace:   *leaderElectionNamespace,
+		Webhooks:                  *protectReferenced,
+		WebhookPort:               int32(webhookPort),
+		CapacityPods:              *capacityMaxPendingPods > 0,
+		CapacityNodes:             *capacityMinHeadroom > 0,
+		CSISecretsStore:           *csiSecretsStore,
+		OnDelete:                  *onDeleteMaxUnavailable > 0,
+		DeletePods:                *restartExecutor == "delete-pods",
+		BlueGreen:                 *blueGreen,
+		Canaries:                  *canaries,
+		RestartHooks:              *restartHooks,
+		Snapshots:                 *snapshotRevisions > 0,
+		Rollbacks:                 *rollbacks,
+		NamespacePriority:         *namespacePriority,
+		SecretMetadataOnly:        *secretMetadataOnly,
+		Shadow:                    *shadow,
+		ImpersonateServiceAccount: *impersonateSA,
+	}
+	objs, err := o.Objects()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "unable to generate manifests: %v\n", err)
+		return 1
+	}
+	if err := manifests.Write(os.Stdout, objs); err != nil {
+		fmt.Fprintf(os.Stderr, "unable to print manifests: %v\n", err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,156 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package admin contains an authenticated HTTP API through which platform
+tooling and the kubectl plugin can pause, resume and restart workloads, and
+release deferred rollouts, by asking the running Wave controller to make the
+change
+*/
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/trigger"
+	"k8s.io/apimachinery/pkg/api/errors"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// PausePath pauses rollouts of a workload or of every workload in a
+	// namespace
+	PausePath = "/admin/pause"
+
+	// ResumePath resumes rollouts of a workload or of every workload in a
+	// namespace
+	ResumePath = "/admin/resume"
+
+	// FlushPath lets rollouts deferred by restart spreading start immediately
+	FlushPath = "/admin/flush"
+
+	// TriggerPath restarts workloads as described by trigger.Request
+	TriggerPath = "/admin/trigger"
+)
+
+// Response lists the workloads an action changed
+type Response struct {
+	Workloads []string `json:"workloads"`
+}
+
+// Server serves the admin API over HTTP
+type Server struct {
+	handler *core.Handler
+	trigger *trigger.Server
+	address string
+	token   string
+}
+
+// NewServer constructs a Server listening on address which acts through the
+// Handler. Requests must present token as a bearer token.
+func NewServer(h *core.Handler, address, token string) *Server {
+	return &Server{
+		handler: h,
+		trigger: trigger.NewServer(h, "", token),
+		address: address,
+		token:   token,
+	}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv := &http.Server{Addr: s.address, Handler: s.Handler()}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving admin requests: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// Handler returns the http.Handler serving every admin endpoint
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PausePath, s.action("pause", s.handler.Pause))
+	mux.Handle(ResumePath, s.action("resume", s.handler.Resume))
+	mux.Handle(FlushPath, s.action("flush", s.handler.ReleaseRollouts))
+	mux.Handle(TriggerPath, s.trigger)
+	return mux
+}
+
+// action serves an endpoint applying fn to the core.AdminTarget in the body
+func (s *Server) action(name string, fn func(context.Context, core.AdminTarget) ([]string, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if !s.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		target := core.AdminTarget{}
+		if err := json.NewDecoder(r.Body).Decode(&target); err != nil {
+			http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+			return
+		}
+		if (target.Kind == "") != (target.Name == "") {
+			http.Error(w, "kind and name must be given together", http.StatusBadRequest)
+			return
+		}
+
+		workloads, err := fn(r.Context(), target)
+		if err != nil {
+			status := http.StatusInternalServerError
+			switch {
+			case errors.IsNotFound(err):
+				status = http.StatusNotFound
+			case errors.IsBadRequest(err):
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Received admin request", "action", name, "namespace", target.Namespace, "kind", target.Kind, "name", target.Name, "workloads", workloads)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Response{Workloads: workloads})
+	})
+}
+
+// authorized checks the request's bearer token in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + s.token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
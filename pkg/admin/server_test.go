@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave admin Suite", func() {
+	var c client.Client
+	var handler http.Handler
+
+	post := func(path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	annotationsOf := func(name string) map[string]string {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d.GetAnnotations()
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{core.RequiredAnnotation: "true"},
+		}})
+		h := core.NewHandler(c, record.NewFakeRecorder(10))
+		handler = NewServer(h, ":0", "secret-token").Handler()
+	})
+
+	It("pauses workloads", func() {
+		rec := post(PausePath, "secret-token", `{"namespace":"default"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		resp := Response{}
+		Expect(json.NewDecoder(rec.Body).Decode(&resp)).To(Succeed())
+		Expect(resp.Workloads).To(Equal([]string{"Deployment/example"}))
+		Expect(annotationsOf("example")).To(HaveKeyWithValue(core.PausedAnnotation, "true"))
+	})
+
+	It("triggers restarts", func() {
+		rec := post(TriggerPath, "secret-token", `{"namespace":"default","kind":"Deployment","name":"example"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(annotationsOf("example")).To(HaveKey(core.TriggerAnnotation))
+	})
+
+	It("rejects requests without the token", func() {
+		for _, path := range []string{PausePath, ResumePath, FlushPath, TriggerPath} {
+			rec := post(path, "wrong-token", `{"namespace":"default"}`)
+			Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		}
+		Expect(annotationsOf("example")).NotTo(HaveKey(core.PausedAnnotation))
+	})
+
+	It("rejects invalid targets", func() {
+		Expect(post(PausePath, "secret-token", `{"namespace":"default","kind":"Deployment"}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(post(PausePath, "secret-token", `{"kind":"Deployment","name":"example"}`).Code).To(Equal(http.StatusBadRequest))
+		Expect(post(PausePath, "secret-token", `{"namespace":"default","kind":"Deployment","name":"missing"}`).Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("only accepts POST requests", func() {
+		req := httptest.NewRequest(http.MethodGet, FlushPath, nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		Expect(rec.Code).To(Equal(http.StatusMethodNotAllowed))
+	})
+})
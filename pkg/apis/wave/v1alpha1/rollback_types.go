@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// RollbackPhase is the outcome of a Rollback
+type RollbackPhase string
+
+const (
+	// RollbackSucceeded means the ConfigMaps and Secrets of the workload were
+	// restored and its rollout triggered
+	RollbackSucceeded RollbackPhase = "Succeeded"
+
+	// RollbackFailed means the Rollback could not be carried out. It is not
+	// retried.
+	RollbackFailed RollbackPhase = "Failed"
+)
+
+// WorkloadReference names a Deployment, StatefulSet or DaemonSet in the
+// namespace of the Rollback
+type WorkloadReference struct {
+	// +kubebuilder:validation:Enum=Deployment,StatefulSet,DaemonSet
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// RollbackSpec defines the desired state of Rollback
+type RollbackSpec struct {
+	// Workload is the workload whose ConfigMaps and Secrets are restored
+	Workload WorkloadReference `json:"workload"`
+
+	// ToRevision is the revision of each ConfigMap and Secret to restore.
+	// When unset, the revision before the latest of each is restored.
+	// +kubebuilder:validation:Minimum=0
+	ToRevision int `json:"toRevision,omitempty"`
+}
+
+// RestoredSource describes a ConfigMap or Secret restored by a Rollback
+type RestoredSource struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Revision int    `json:"revision"`
+}
+
+// RollbackStatus defines the observed state of Rollback
+type RollbackStatus struct {
+	// Phase is empty until the Rollback has been carried out
+	Phase   RollbackPhase `json:"phase,omitempty"`
+	Message string        `json:"message,omitempty"`
+
+	// Restored lists the ConfigMaps and Secrets restored
+	Restored []RestoredSource `json:"restored,omitempty"`
+
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// Rollback restores the ConfigMaps and Secrets of a workload from the
+// snapshots Wave took of them, and triggers its rollout
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Kind",type="string",JSONPath=".spec.workload.kind"
+// +kubebuilder:printcolumn:name="Workload",type="string",JSONPath=".spec.workload.name"
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+type Rollback struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   RollbackSpec   `json:"spec,omitempty"`
+	Status RollbackStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// RollbackList contains a list of Rollback
+type RollbackList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Rollback `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Rollback{}, &RollbackList{})
+}
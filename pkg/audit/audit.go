@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package audit contains the sinks Wave ships a structured record of every
+rollout decision, and optionally every write, to for retention beyond the
+lifetime of cluster Events
+*/
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"time"
+)
+
+// Decision is the outcome recorded for a rollout
+type Decision string
+
+const (
+	// Triggered records that Wave updated the workload's configuration hash
+	Triggered Decision = "triggered"
+
+	// Skipped records that Wave failed to apply a new configuration hash
+	Skipped Decision = "skipped"
+
+	// Denied records that a policy denied the rollout
+	Denied Decision = "denied"
+
+	// Deferred records that the rollout was postponed
+	Deferred Decision = "deferred"
+)
+
+// Workload identifies the workload the decision was made for
+type Workload struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	UID       string `json:"uid"`
+}
+
+// Change describes a change to a single ConfigMap or Secret
+type Change struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Record is a structured description of a single rollout decision
+type Record struct {
+	// Time is when the decision was made
+	Time time.Time `json:"time"`
+
+	// Actor identifies the Wave instance that made the decision
+	Actor string `json:"actor"`
+
+	Workload Workload `json:"workload"`
+	Decision Decision `json:"decision"`
+	Reason   string   `json:"reason,omitempty"`
+	OldHash  string   `json:"oldHash"`
+	NewHash  string   `json:"newHash"`
+	Changes  []Change `json:"changes"`
+
+	// Write describes the write, for records of the Written decision
+	Write *Write `json:"write,omitempty"`
+}
+
+// Sink stores Records outside of the cluster
+type Sink interface {
+	Write(ctx context.Context, record Record) error
+}
+
+// objectName returns a unique, chronologically sortable name for storing the
+// record as an object in a bucket
+func objectName(prefix string, record Record) string {
+	t := record.Time.UTC()
+	name := fmt.Sprintf("%s-%s-%s-%s-%s.json",
+		t.Format("20060102T150405.000000000Z"),
+		record.Workload.Namespace,
+		record.Workload.Kind,
+		record.Workload.Name,
+		record.Decision,
+	)
+	return path.Join(prefix, t.Format("2006/01/02"), name)
+}
+
+// marshal encodes the record as JSON
+func marshal(record Record) ([]byte, error) {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	return data, nil
+}
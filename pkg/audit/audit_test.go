@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/sigv4"
+)
+
+var _ = Describe("Wave audit Suite", func() {
+	var server *httptest.Server
+	var requests chan *http.Request
+	var bodies chan []byte
+	var status int
+
+	var record = Record{
+		Time:  time.Date(2019, 1, 2, 3, 4, 5, 6, time.UTC),
+		Actor: "wave-0",
+		Workload: Workload{
+			Namespace: "default",
+			Kind:      "Deployment",
+			Name:      "example",
+			UID:       "1234",
+		},
+		Decision: Triggered,
+		OldHash:  "abc",
+		NewHash:  "def",
+		Changes: []Change{
+			{Kind: "ConfigMap", Name: "example1", Type: "modified"},
+		},
+	}
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan []byte, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			requests <- r
+			bodies <- body
+			w.WriteHeader(status)
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("objectName", func() {
+		It("partitions records by date", func() {
+			Expect(objectName("wave", record)).To(Equal("wave/2019/01/02/20190102T030405.000000006Z-default-Deployment-example-triggered.json"))
+		})
+	})
+
+	Context("HTTP", func() {
+		It("posts the record as JSON", func() {
+			Expect(NewHTTP(server.URL, "Bearer token").Write(context.TODO(), record)).To(Succeed())
+			req := <-requests
+			Expect(req.Method).To(Equal(http.MethodPost))
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+
+			received := Record{}
+			Expect(json.Unmarshal(<-bodies, &received)).To(Succeed())
+			Expect(received).To(Equal(record))
+		})
+
+		It("returns an error for unsuccessful responses", func() {
+			status = http.StatusInternalServerError
+			Expect(NewHTTP(server.URL, "").Write(context.TODO(), record)).NotTo(Succeed())
+		})
+	})
+
+	Context("S3", func() {
+		var s3 *S3
+
+		BeforeEach(func() {
+			s3 = NewS3("bucket", "audit", "eu-west-1", sigv4.Credentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "secret",
+			})
+			s3.endpoint = server.URL
+			s3.now = func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) }
+		})
+
+		It("puts the record into the bucket", func() {
+			Expect(s3.Write(context.TODO(), record)).To(Succeed())
+			req := <-requests
+			Expect(req.Method).To(Equal(http.MethodPut))
+			Expect(req.URL.Path).To(Equal("/" + objectName("audit", record)))
+
+			received := Record{}
+			Expect(json.Unmarshal(<-bodies, &received)).To(Succeed())
+			Expect(received).To(Equal(record))
+		})
+
+		It("signs the request", func() {
+			Expect(s3.Write(context.TODO(), record)).To(Succeed())
+			req := <-requests
+			Expect(req.Header.Get("X-Amz-Content-Sha256")).NotTo(BeEmpty())
+			Expect(req.Header.Get("Authorization")).To(HavePrefix("AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20190102/eu-west-1/s3/aws4_request"))
+		})
+	})
+})
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+)
+
+const gcsWriteScope = "https://www.googleapis.com/auth/devstorage.read_write"
+
+// GCS stores each Record as a JSON object in a Google Cloud Storage bucket
+type GCS struct {
+	endpoint string
+	prefix   string
+	tokens   oauth2.TokenSource
+	client   *http.Client
+}
+
+// NewGCS constructs a GCS Sink writing objects under prefix in the bucket,
+// authenticating with the Application Default Credentials
+func NewGCS(ctx context.Context, bucket, prefix string) (*GCS, error) {
+	tokens, err := google.DefaultTokenSource(ctx, gcsWriteScope)
+	if err != nil {
+		return nil, fmt.Errorf("error loading Google credentials: %v", err)
+	}
+	return &GCS{
+		endpoint: fmt.Sprintf("https://storage.googleapis.com/upload/storage/v1/b/%s/o", url.PathEscape(bucket)),
+		prefix:   prefix,
+		tokens:   tokens,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Write uploads the Record to the bucket
+func (g *GCS) Write(ctx context.Context, record Record) error {
+	body, err := marshal(record)
+	if err != nil {
+		return err
+	}
+	token, err := g.tokens.Token()
+	if err != nil {
+		return fmt.Errorf("error fetching Google access token: %v", err)
+	}
+
+	query := url.Values{
+		"uploadType": {"media"},
+		"name":       {objectName(g.prefix, record)},
+	}
+	req, err := http.NewRequest(http.MethodPost, g.endpoint+"?"+query.Encode(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	token.SetAuthHeader(req)
+	return do(g.client, req)
+}
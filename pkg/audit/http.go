@@ -0,0 +1,74 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// HTTP posts each Record as JSON to an HTTP endpoint
+type HTTP struct {
+	url           string
+	authorization string
+	client        *http.Client
+}
+
+// NewHTTP constructs an HTTP Sink. If authorization is not empty it is sent
+// as the Authorization header of each request.
+func NewHTTP(url, authorization string) *HTTP {
+	return &HTTP{
+		url:           url,
+		authorization: authorization,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Write posts the Record to the endpoint
+func (h *HTTP) Write(ctx context.Context, record Record) error {
+	body, err := marshal(record)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if h.authorization != "" {
+		req.Header.Set("Authorization", h.authorization)
+	}
+	return do(h.client, req)
+}
+
+// do sends the request and checks for a successful response
+func do(client *http.Client, req *http.Request) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending audit record: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from audit sink: %s", resp.Status)
+	}
+	return nil
+}
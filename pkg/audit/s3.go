@@ -0,0 +1,68 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/sigv4"
+)
+
+// S3 stores each Record as a JSON object in an Amazon S3 bucket
+type S3 struct {
+	endpoint    string
+	prefix      string
+	region      string
+	credentials sigv4.Credentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewS3 constructs an S3 Sink writing objects under prefix in the bucket
+func NewS3(bucket, prefix, region string, credentials sigv4.Credentials) *S3 {
+	return &S3{
+		endpoint:    fmt.Sprintf("https://%s.s3.%s.amazonaws.com", bucket, region),
+		prefix:      prefix,
+		region:      region,
+		credentials: credentials,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+}
+
+// Write puts the Record into the bucket
+func (s *S3) Write(ctx context.Context, record Record) error {
+	body, err := marshal(record)
+	if err != nil {
+		return err
+	}
+	object := &url.URL{Path: "/" + strings.TrimPrefix(objectName(s.prefix, record), "/")}
+	req, err := http.NewRequest(http.MethodPut, s.endpoint+object.EscapedPath(), bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	sigv4.Sign(req, body, "s3", s.region, s.credentials, s.now())
+	return do(s.client, req)
+}
@@ -0,0 +1,174 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"net/http"
+)
+
+// HeaderAuditID is the request header the Kubernetes API server uses as the
+// ID of a request in its audit log when a client sets it
+const HeaderAuditID = "Audit-ID"
+
+// Written records a write Wave made to the cluster
+const Written Decision = "written"
+
+// Mutation is a kind of change Wave makes to an object. Its value is also the
+// reason of the Event reporting the change.
+type Mutation string
+
+const (
+	// ConfigHashUpdated records an update of a workload's configuration hash
+	ConfigHashUpdated Mutation = "ConfigHashUpdated"
+
+	// OwnerReferenceAdded records that an OwnerReference to a workload was
+	// added to a ConfigMap or Secret
+	OwnerReferenceAdded Mutation = "OwnerReferenceAdded"
+
+	// OwnerReferenceRemoved records that an OwnerReference to a workload was
+	// removed from a ConfigMap or Secret
+	OwnerReferenceRemoved Mutation = "OwnerReferenceRemoved"
+
+	// FinalizerAdded records that Wave's finalizer was added to a workload
+	FinalizerAdded Mutation = "FinalizerAdded"
+
+	// FinalizerRemoved records that Wave's finalizer was removed from a
+	// workload
+	FinalizerRemoved Mutation = "FinalizerRemoved"
+
+	// PodDeleted records that Wave deleted an outdated Pod of a workload
+	// using the OnDelete update strategy
+	PodDeleted Mutation = "PodDeleted"
+
+	// Paused records that rollouts of a workload were paused through the
+	// admin API
+	Paused Mutation = "Paused"
+
+	// Resumed records that rollouts of a workload were resumed through the
+	// admin API
+	Resumed Mutation = "Resumed"
+
+	// RolloutReleased records that the deferred rollout of a workload was
+	// allowed to start immediately through the admin API
+	RolloutReleased Mutation = "RolloutReleased"
+
+	// CloneCreated records that Wave created a copy of a Deployment running
+	// its new configuration for a blue/green rollout
+	CloneCreated Mutation = "CloneCreated"
+
+	// CloneDeleted records that Wave deleted a copy of a Deployment which no
+	// longer serves a blue/green rollout
+	CloneDeleted Mutation = "CloneDeleted"
+
+	// ServiceSwitched records that Wave pointed the selector of a Service at
+	// the Pods of a new configuration during a blue/green rollout
+	ServiceSwitched Mutation = "ServiceSwitched"
+
+	// ScaledDown records that Wave scaled down a Deployment replaced by a
+	// blue/green rollout
+	ScaledDown Mutation = "ScaledDown"
+
+	// PreRestartHookStarted records that Wave created the Job of a
+	// workload's pre-restart hook
+	PreRestartHookStarted Mutation = "PreRestartHookStarted"
+
+	// PostRestartHookStarted records that Wave created the Job of a
+	// workload's post-restart hook
+	PostRestartHookStarted Mutation = "PostRestartHookStarted"
+
+	// SnapshotCreated records that Wave copied the data of a ConfigMap or
+	// Secret of a workload into a new revision
+	SnapshotCreated Mutation = "SnapshotCreated"
+
+	// SnapshotDeleted records that Wave deleted a revision of a ConfigMap or
+	// Secret beyond those it retains
+	SnapshotDeleted Mutation = "SnapshotDeleted"
+
+	// CanaryCreated records that Wave created a canary running a new
+	// configuration of a workload
+	CanaryCreated Mutation = "CanaryCreated"
+
+	// CanaryDeleted records that Wave deleted a canary which passed or no
+	// longer runs the configuration of its workload
+	CanaryDeleted Mutation = "CanaryDeleted"
+
+	// CanaryFailed records that Wave scaled down a failed canary
+	CanaryFailed Mutation = "CanaryFailed"
+)
+
+// Object identifies the object Wave wrote
+type Object struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+// Write describes a single write Wave made to the cluster
+type Write struct {
+	Object    Object     `json:"object"`
+	Mutations []Mutation `json:"mutations"`
+
+	// FieldManager is the field manager the write was attributed to
+	FieldManager string `json:"fieldManager"`
+
+	// RequestUID was sent as the Audit-ID of the request, so the write can
+	// be found in the API server's audit log
+	RequestUID string `json:"requestUID"`
+
+	// User is the user Wave impersonated to make the write, if any
+	User string `json:"user,omitempty"`
+}
+
+type requestUIDKey struct{}
+
+// WithRequestUID returns a copy of the context carrying the request UID
+func WithRequestUID(ctx context.Context, uid string) context.Context {
+	return context.WithValue(ctx, requestUIDKey{}, uid)
+}
+
+// RequestUID returns the request UID carried by the context, if any
+func RequestUID(ctx context.Context) string {
+	uid, _ := ctx.Value(requestUIDKey{}).(string)
+	return uid
+}
+
+// WrapTransport sets the Audit-ID header of every request whose context
+// carries a request UID. It is meant to be used as rest.Config.WrapTransport.
+func WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		uid := RequestUID(req.Context())
+		if uid == "" {
+			return rt.RoundTrip(req)
+		}
+		// RoundTrippers must not modify the request they are given
+		r := req.WithContext(req.Context())
+		r.Header = make(http.Header, len(req.Header)+1)
+		for key, values := range req.Header {
+			r.Header[key] = values
+		}
+		r.Header.Set(HeaderAuditID, uid)
+		return rt.RoundTrip(r)
+	})
+}
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
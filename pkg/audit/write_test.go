@@ -0,0 +1,61 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package audit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wave audit write Suite", func() {
+	var server *httptest.Server
+	var auditIDs chan string
+	var client *http.Client
+
+	BeforeEach(func() {
+		auditIDs = make(chan string, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			auditIDs <- r.Header.Get(HeaderAuditID)
+		}))
+		client = &http.Client{Transport: WrapTransport(http.DefaultTransport)}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("sends the request UID as the Audit-ID", func() {
+		req, err := http.NewRequest(http.MethodPut, server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req = req.WithContext(WithRequestUID(context.Background(), "request-uid"))
+
+		_, err = client.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(auditIDs).To(Receive(Equal("request-uid")))
+		Expect(req.Header.Get(HeaderAuditID)).To(BeEmpty())
+	})
+
+	It("leaves requests without a request UID alone", func() {
+		_, err := client.Get(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(auditIDs).To(Receive(BeEmpty()))
+	})
+})
@@ -0,0 +1,172 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package capabilities discovers the APIs served by the cluster Wave runs
+against and adapts Wave's watches and API calls to them, so that a single
+build of Wave runs across clusters of different Kubernetes versions
+*/
+package capabilities
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+)
+
+// workloadVersions are the versions of the apps API group Wave can handle
+// workloads in, most preferred first
+var workloadVersions = []schema.GroupVersion{appsv1.SchemeGroupVersion, appsv1beta2.SchemeGroupVersion}
+
+// workloadResources maps each kind of workload to its resource
+var workloadResources = map[string]string{
+	"Deployment":  "deployments",
+	"StatefulSet": "statefulsets",
+	"DaemonSet":   "daemonsets",
+}
+
+// legacyTypes constructs the objects of each kind of workload in apps/v1beta2
+var legacyTypes = map[string]func() runtime.Object{
+	"Deployment":  func() runtime.Object { return &appsv1beta2.Deployment{} },
+	"StatefulSet": func() runtime.Object { return &appsv1beta2.StatefulSet{} },
+	"DaemonSet":   func() runtime.Object { return &appsv1beta2.DaemonSet{} },
+}
+
+// currentTypes constructs the objects of each kind of workload in apps/v1
+var currentTypes = map[string]func() runtime.Object{
+	"Deployment":  func() runtime.Object { return &appsv1.Deployment{} },
+	"StatefulSet": func() runtime.Object { return &appsv1.StatefulSet{} },
+	"DaemonSet":   func() runtime.Object { return &appsv1.DaemonSet{} },
+}
+
+// Capabilities are the APIs and the version of Kubernetes served by a
+// cluster. A nil *Capabilities describes a cluster serving every API Wave
+// uses in its current version.
+type Capabilities struct {
+	major, minor int
+	resources    map[schema.GroupVersion]map[string]struct{}
+}
+
+// Discover queries the cluster for the APIs it serves. API groups which fail
+// to be discovered, such as those of unavailable aggregated API servers, are
+// treated as not served.
+func Discover(d discovery.DiscoveryInterface) (*Capabilities, error) {
+	info, err := d.ServerVersion()
+	if err != nil {
+		return nil, fmt.Errorf("error discovering the server version: %v", err)
+	}
+	c := &Capabilities{resources: make(map[schema.GroupVersion]map[string]struct{})}
+	c.major, c.minor = parseVersion(info.Major), parseVersion(info.Minor)
+
+	lists, err := d.ServerResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("error discovering the served APIs: %v", err)
+	}
+	for _, list := range lists {
+		if list == nil {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		resources := make(map[string]struct{})
+		for _, resource := range list.APIResources {
+			resources[resource.Name] = struct{}{}
+		}
+		c.resources[gv] = resources
+	}
+	return c, nil
+}
+
+// parseVersion parses the leading digits of a part of the server version,
+// which some providers suffix, as in "15+"
+func parseVersion(s string) int {
+	end := strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' })
+	if end >= 0 {
+		s = s[:end]
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// String describes the server version
+func (c *Capabilities) String() string {
+	if c == nil {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d", c.major, c.minor)
+}
+
+// AtLeast returns true if the server version is at least major.minor
+func (c *Capabilities) AtLeast(major, minor int) bool {
+	if c == nil {
+		return true
+	}
+	return c.major > major || c.major == major && c.minor >= minor
+}
+
+// Serves returns true if the cluster serves the resource in the group version
+func (c *Capabilities) Serves(gv schema.GroupVersion, resource string) bool {
+	if c == nil {
+		return true
+	}
+	_, ok := c.resources[gv][resource]
+	return ok
+}
+
+// WorkloadVersion returns the version of the apps API group the kind of
+// workload is handled in
+func (c *Capabilities) WorkloadVersion(kind string) (schema.GroupVersion, error) {
+	resource, ok := workloadResources[kind]
+	if !ok {
+		return schema.GroupVersion{}, fmt.Errorf("unknown kind of workload %q", kind)
+	}
+	if c == nil {
+		return appsv1.SchemeGroupVersion, nil
+	}
+	for _, gv := range workloadVersions {
+		if c.Serves(gv, resource) {
+			return gv, nil
+		}
+	}
+	return schema.GroupVersion{}, fmt.Errorf("the cluster serves %s in none of %v", resource, workloadVersions)
+}
+
+// WorkloadType returns an empty object of the kind of workload in the
+// version the cluster serves it in, to be watched by its controller. It falls
+// back to apps/v1.
+func (c *Capabilities) WorkloadType(kind string) runtime.Object {
+	if c.legacy(kind) {
+		return legacyTypes[kind]()
+	}
+	if f, ok := currentTypes[kind]; ok {
+		return f()
+	}
+	return nil
+}
+
+// legacy returns true if the kind of workload is only served in apps/v1beta2
+func (c *Capabilities) legacy(kind string) bool {
+	gv, err := c.WorkloadVersion(kind)
+	return err == nil && gv == appsv1beta2.SchemeGroupVersion
+}
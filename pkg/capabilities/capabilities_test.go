@@ -0,0 +1,99 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capabilities
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/version"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// discover discovers the Capabilities of a fake cluster serving the resource
+// lists at the server version
+func discover(major, minor string, lists ...*metav1.APIResourceList) *Capabilities {
+	d := &fakediscovery.FakeDiscovery{
+		Fake:               &k8stesting.Fake{Resources: lists},
+		FakedServerVersion: &version.Info{Major: major, Minor: minor},
+	}
+	c, err := Discover(d)
+	Expect(err).NotTo(HaveOccurred())
+	return c
+}
+
+// resources lists the resources served in the group version
+func resources(gv schema.GroupVersion, names ...string) *metav1.APIResourceList {
+	list := &metav1.APIResourceList{GroupVersion: gv.String()}
+	for _, name := range names {
+		list.APIResources = append(list.APIResources, metav1.APIResource{Name: name})
+	}
+	return list
+}
+
+var _ = Describe("Capabilities", func() {
+	It("prefers apps/v1", func() {
+		c := discover("1", "14",
+			resources(appsv1.SchemeGroupVersion, "deployments", "statefulsets", "daemonsets"),
+			resources(appsv1beta2.SchemeGroupVersion, "deployments", "statefulsets", "daemonsets"),
+		)
+		Expect(c.WorkloadVersion("Deployment")).To(Equal(appsv1.SchemeGroupVersion))
+		Expect(c.WorkloadType("Deployment")).To(BeAssignableToTypeOf(&appsv1.Deployment{}))
+	})
+
+	It("falls back to apps/v1beta2", func() {
+		c := discover("1", "8", resources(appsv1beta2.SchemeGroupVersion, "deployments", "statefulsets", "daemonsets"))
+		Expect(c.WorkloadVersion("StatefulSet")).To(Equal(appsv1beta2.SchemeGroupVersion))
+		Expect(c.WorkloadType("StatefulSet")).To(BeAssignableToTypeOf(&appsv1beta2.StatefulSet{}))
+		Expect(c.WorkloadType("DaemonSet")).To(BeAssignableToTypeOf(&appsv1beta2.DaemonSet{}))
+	})
+
+	It("fails when no version of a workload is served", func() {
+		c := discover("1", "14", resources(appsv1.SchemeGroupVersion, "deployments"))
+		_, err := c.WorkloadVersion("DaemonSet")
+		Expect(err).To(HaveOccurred())
+		_, err = c.WorkloadVersion("CronJob")
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("compares the server version", func() {
+		c := discover("1", "15+")
+		Expect(c.String()).To(Equal("1.15"))
+		Expect(c.AtLeast(1, 15)).To(BeTrue())
+		Expect(c.AtLeast(1, 16)).To(BeFalse())
+		Expect(c.AtLeast(0, 99)).To(BeTrue())
+	})
+
+	It("reports the served resources", func() {
+		gv := schema.GroupVersion{Group: "secrets-store.csi.x-k8s.io", Version: "v1alpha1"}
+		c := discover("1", "14", resources(gv, "secretproviderclasspodstatuses"))
+		Expect(c.Serves(gv, "secretproviderclasspodstatuses")).To(BeTrue())
+		Expect(c.Serves(gv, "secretproviderclasses")).To(BeFalse())
+	})
+
+	It("assumes every current API is served when nil", func() {
+		var c *Capabilities
+		Expect(c.WorkloadVersion("Deployment")).To(Equal(appsv1.SchemeGroupVersion))
+		Expect(c.WorkloadType("DaemonSet")).To(BeAssignableToTypeOf(&appsv1.DaemonSet{}))
+		Expect(c.AtLeast(1, 99)).To(BeTrue())
+		Expect(c.Serves(appsv1.SchemeGroupVersion, "deployments")).To(BeTrue())
+	})
+})
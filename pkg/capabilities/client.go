@@ -0,0 +1,260 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// legacyLists constructs the lists of each kind of workload in apps/v1beta2
+var legacyLists = map[string]func() runtime.Object{
+	"Deployment":  func() runtime.Object { return &appsv1beta2.DeploymentList{} },
+	"StatefulSet": func() runtime.Object { return &appsv1beta2.StatefulSetList{} },
+	"DaemonSet":   func() runtime.Object { return &appsv1beta2.DaemonSetList{} },
+}
+
+// Client wraps the client so that the apps/v1 workloads Wave handles are read
+// and written in the version of the apps API the cluster serves. Wave's code
+// only ever sees apps/v1 objects, which apps/v1beta2 objects convert to field
+// for field.
+func (c *Capabilities) Client(cl client.Client) client.Client {
+	for kind := range workloadResources {
+		if c.legacy(kind) {
+			return &shimClient{Client: cl, capabilities: c}
+		}
+	}
+	return cl
+}
+
+// NewClient creates the default client of a manager, reading from its cache
+// and writing to the API server, wrapped by Client. It implements
+// manager.NewClientFunc.
+func (c *Capabilities) NewClient(cache cache.Cache, config *rest.Config, options client.Options) (client.Client, error) {
+	cl, err := client.New(config, options)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client(&client.DelegatingClient{
+		Reader: &client.DelegatingReader{
+			CacheReader:  cache,
+			ClientReader: cl,
+		},
+		Writer:       cl,
+		StatusClient: cl,
+	}), nil
+}
+
+// shimClient reads and writes apps/v1 workloads as apps/v1beta2 workloads
+// where the cluster only serves the latter
+type shimClient struct {
+	client.Client
+	capabilities *Capabilities
+}
+
+// legacyOf returns an empty apps/v1beta2 object or list replacing the apps/v1
+// workload or list of workloads, if the cluster doesn't serve it
+func (c *Capabilities) legacyOf(obj runtime.Object) (runtime.Object, bool) {
+	var kind string
+	switch obj.(type) {
+	case *appsv1.Deployment, *appsv1.DeploymentList:
+		kind = "Deployment"
+	case *appsv1.StatefulSet, *appsv1.StatefulSetList:
+		kind = "StatefulSet"
+	case *appsv1.DaemonSet, *appsv1.DaemonSetList:
+		kind = "DaemonSet"
+	default:
+		return nil, false
+	}
+	if !c.legacy(kind) {
+		return nil, false
+	}
+	if meta.IsListType(obj) {
+		return legacyLists[kind](), true
+	}
+	return legacyTypes[kind](), true
+}
+
+// convert replaces the content of to with that of from, of another version
+// of the same kind. The types of both are cleared, so that they are set by
+// the client from their Go types.
+func convert(from, to runtime.Object) error {
+	data, err := json.Marshal(from)
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	v := reflect.ValueOf(to).Elem()
+	v.Set(reflect.Zero(v.Type()))
+	if err := json.Unmarshal(data, to); err != nil {
+		return fmt.Errorf("unable to unmarshal JSON: %v", err)
+	}
+	to.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+	if meta.IsListType(to) {
+		return meta.EachListItem(to, func(item runtime.Object) error {
+			item.GetObjectKind().SetGroupVersionKind(schema.GroupVersionKind{})
+			return nil
+		})
+	}
+	return nil
+}
+
+// read reads the legacy object and converts it to obj
+func read(obj, legacy runtime.Object, f func(runtime.Object) error) error {
+	if err := f(legacy); err != nil {
+		return err
+	}
+	return convert(legacy, obj)
+}
+
+// write converts obj to the legacy object, writes it and converts the object
+// returned by the API server back to obj
+func write(obj, legacy runtime.Object, f func(runtime.Object) error) error {
+	if err := convert(obj, legacy); err != nil {
+		return err
+	}
+	return read(obj, legacy, f)
+}
+
+// Get implements client.Reader
+func (s *shimClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.Get(ctx, key, obj)
+	}
+	return read(obj, legacy, func(legacy runtime.Object) error {
+		return s.Client.Get(ctx, key, legacy)
+	})
+}
+
+// List implements client.Reader
+func (s *shimClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	legacy, ok := s.capabilities.legacyOf(list)
+	if !ok {
+		return s.Client.List(ctx, list, opts...)
+	}
+	return read(list, legacy, func(legacy runtime.Object) error {
+		return s.Client.List(ctx, legacy, opts...)
+	})
+}
+
+// Create implements client.Writer
+func (s *shimClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.Create(ctx, obj, opts...)
+	}
+	return write(obj, legacy, func(legacy runtime.Object) error {
+		return s.Client.Create(ctx, legacy, opts...)
+	})
+}
+
+// Update implements client.Writer
+func (s *shimClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.Update(ctx, obj, opts...)
+	}
+	return write(obj, legacy, func(legacy runtime.Object) error {
+		return s.Client.Update(ctx, legacy, opts...)
+	})
+}
+
+// Patch implements client.Writer. The patch is computed from the apps/v1
+// object, which serializes like its apps/v1beta2 counterpart.
+func (s *shimClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	return write(obj, legacy, func(legacy runtime.Object) error {
+		return s.Client.Patch(ctx, legacy, client.ConstantPatch(patch.Type(), data), opts...)
+	})
+}
+
+// Delete implements client.Writer
+func (s *shimClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.Delete(ctx, obj, opts...)
+	}
+	if err := convert(obj, legacy); err != nil {
+		return err
+	}
+	return s.Client.Delete(ctx, legacy, opts...)
+}
+
+// DeleteAllOf implements client.Writer
+func (s *shimClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.Client.DeleteAllOf(ctx, obj, opts...)
+	}
+	return s.Client.DeleteAllOf(ctx, legacy, opts...)
+}
+
+// Status implements client.StatusClient
+func (s *shimClient) Status() client.StatusWriter {
+	return &shimStatusWriter{StatusWriter: s.Client.Status(), capabilities: s.capabilities}
+}
+
+// shimStatusWriter writes the status of apps/v1 workloads as apps/v1beta2
+// workloads where the cluster only serves the latter
+type shimStatusWriter struct {
+	client.StatusWriter
+	capabilities *Capabilities
+}
+
+// Update implements client.StatusWriter
+func (s *shimStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.StatusWriter.Update(ctx, obj, opts...)
+	}
+	return write(obj, legacy, func(legacy runtime.Object) error {
+		return s.StatusWriter.Update(ctx, legacy, opts...)
+	})
+}
+
+// Patch implements client.StatusWriter
+func (s *shimStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	legacy, ok := s.capabilities.legacyOf(obj)
+	if !ok {
+		return s.StatusWriter.Patch(ctx, obj, patch, opts...)
+	}
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	return write(obj, legacy, func(legacy runtime.Object) error {
+		return s.StatusWriter.Patch(ctx, legacy, client.ConstantPatch(patch.Type(), data), opts...)
+	})
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package capabilities
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	appsv1beta2 "k8s.io/api/apps/v1beta2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Client", func() {
+	var c client.Client
+	var underlying client.Client
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+
+	BeforeEach(func() {
+		replicas := int32(2)
+		d := &appsv1beta2.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", Annotations: map[string]string{"a": "b"}},
+			Spec: appsv1beta2.DeploymentSpec{
+				Replicas: &replicas,
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "app", Image: "app:1"}}}},
+			},
+		}
+		cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"}}
+		underlying = fake.NewFakeClient(d, cm)
+		caps := discover("1", "8", resources(appsv1beta2.SchemeGroupVersion, "deployments", "statefulsets", "daemonsets"))
+		c = caps.Client(underlying)
+	})
+
+	It("isn't wrapped when the cluster serves apps/v1", func() {
+		caps := discover("1", "14", resources(appsv1.SchemeGroupVersion, "deployments", "statefulsets", "daemonsets"))
+		Expect(caps.Client(underlying)).To(BeIdenticalTo(underlying))
+	})
+
+	It("reads apps/v1beta2 workloads as apps/v1 workloads", func() {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		Expect(*d.Spec.Replicas).To(Equal(int32(2)))
+		Expect(d.Spec.Template.Spec.Containers[0].Image).To(Equal("app:1"))
+		Expect(d.APIVersion).To(BeEmpty())
+
+		list := &appsv1.DeploymentList{}
+		Expect(c.List(context.TODO(), list)).To(Succeed())
+		Expect(list.Items).To(HaveLen(1))
+		Expect(list.Items[0].Name).To(Equal("example"))
+	})
+
+	It("writes apps/v1 workloads as apps/v1beta2 workloads", func() {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		d.Spec.Template.Spec.Containers[0].Image = "app:2"
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		original := d.DeepCopy()
+		d.Annotations["c"] = "d"
+		Expect(c.Patch(context.TODO(), d, client.MergeFrom(original))).To(Succeed())
+
+		legacy := &appsv1beta2.Deployment{}
+		Expect(underlying.Get(context.TODO(), key, legacy)).To(Succeed())
+		Expect(legacy.Spec.Template.Spec.Containers[0].Image).To(Equal("app:2"))
+		Expect(legacy.Annotations).To(Equal(map[string]string{"a": "b", "c": "d"}))
+		Expect(d.ResourceVersion).To(Equal(legacy.ResourceVersion))
+	})
+
+	It("clears the fields of the object it reads into", func() {
+		d := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{Paused: true}}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		Expect(d.Spec.Paused).To(BeFalse())
+	})
+
+	It("passes other objects through", func() {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "config"}, cm)).To(Succeed())
+		Expect(c.Delete(context.TODO(), cm)).To(Succeed())
+
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		Expect(c.Delete(context.TODO(), d)).To(Succeed())
+		Expect(underlying.Get(context.TODO(), key, &appsv1beta2.Deployment{})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,109 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// adoptOptions holds the flags of the adopt command
+type adoptOptions struct {
+	selector      string
+	allNamespaces bool
+	dryRun        bool
+}
+
+// newAdoptCommand constructs the adopt command
+func newAdoptCommand(o *Options) *cobra.Command {
+	a := &adoptOptions{}
+	cmd := &cobra.Command{
+		Use:   "adopt --selector key=value",
+		Short: "Enable Wave for every workload matching a label selector",
+		Long: `Enable Wave for every Deployment, StatefulSet and DaemonSet matching a label
+selector by adding the wave.pusher.com/update-on-config-change annotation.
+
+By default the workloads that would be adopted are only printed. Run again with
+--dry-run=false to adopt them.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.adopt(context.Background(), a)
+		},
+	}
+	cmd.Flags().StringVarP(&a.selector, "selector", "l", "", "Label selector matching the workloads to adopt")
+	cmd.Flags().BoolVarP(&a.allNamespaces, "all-namespaces", "A", false, "Adopt workloads in all namespaces")
+	cmd.Flags().BoolVar(&a.dryRun, "dry-run", true, "Only print the workloads that would be adopted")
+	cmd.MarkFlagRequired("selector")
+	return cmd
+}
+
+// adopt annotates the matching workloads for Wave
+func (o *Options) adopt(ctx context.Context, a *adoptOptions) error {
+	selector, err := labels.Parse(a.selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %v", err)
+	}
+	if selector.Empty() {
+		return fmt.Errorf("a non-empty selector is required")
+	}
+	namespace := o.namespace
+	if a.allNamespaces {
+		namespace = ""
+	}
+
+	workloads, err := o.listWorkloads(ctx, namespace, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return err
+	}
+
+	adopted := 0
+	results := []workloadResult{}
+	report := func(w workload, result, message string) {
+		if o.structured() {
+			results = append(results, workloadResult{workloadReference: w.reference(), Result: result})
+			return
+		}
+		fmt.Fprintf(o.out, "%s/%s %s\n", w.GetNamespace(), w, message)
+	}
+	for _, w := range workloads {
+		if w.enabled() {
+			report(w, "already-adopted", "already adopted")
+			continue
+		}
+		adopted++
+		if a.dryRun {
+			report(w, "would-adopt", "would be adopted")
+			continue
+		}
+		if err := o.setAnnotation(ctx, w, core.RequiredAnnotation, "true"); err != nil {
+			return err
+		}
+		report(w, "adopted", "adopted")
+	}
+
+	if o.structured() {
+		return o.printList("WorkloadResultList", results)
+	}
+	if a.dryRun && adopted > 0 {
+		fmt.Fprintf(o.out, "\nDry run: %d workload(s) would be adopted. Run again with --dry-run=false to adopt them.\n", adopted)
+	}
+	return nil
+}
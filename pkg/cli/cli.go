@@ -0,0 +1,118 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package cli implements the kubectl-wave plugin, which lets operators inspect
+and manage how Wave restarts their workloads
+*/
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// offlineAnnotation marks commands that do not connect to a cluster
+const offlineAnnotation = "offline"
+
+// Options holds the state shared by all commands
+type Options struct {
+	kubeconfig  string
+	kubeContext string
+	namespace   string
+	output      string
+
+	client client.Client
+	out    io.Writer
+	now    func() time.Time
+}
+
+// NewCommand constructs the root kubectl-wave command
+func NewCommand() *cobra.Command {
+	o := &Options{out: os.Stdout, now: time.Now}
+	cmd := &cobra.Command{
+		Use:          "kubectl-wave",
+		Short:        "Inspect and manage how Wave restarts workloads",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			formats := defaultOutputFormats
+			if value, ok := cmd.Annotations[outputAnnotation]; ok {
+				formats = strings.Split(value, ",")
+			}
+			if err := o.validateOutput(formats); err != nil {
+				return err
+			}
+			if cmd.Annotations[offlineAnnotation] == "true" {
+				return nil
+			}
+			return o.complete()
+		},
+	}
+	cmd.PersistentFlags().StringVar(&o.kubeconfig, "kubeconfig", "", "Path to the kubeconfig file to use")
+	cmd.PersistentFlags().StringVar(&o.kubeContext, "context", "", "The name of the kubeconfig context to use")
+	cmd.PersistentFlags().StringVarP(&o.namespace, "namespace", "n", "", "The namespace of the workloads")
+	cmd.PersistentFlags().StringVarP(&o.output, "output", "o", "", "Output format: json or yaml. Human-readable output is printed by default")
+
+	cmd.AddCommand(
+		newStatusCommand(o),
+		newPauseCommand(o),
+		newResumeCommand(o),
+		newTriggerCommand(o),
+		newDiffCommand(o),
+		newGraphCommand(o),
+		newHashCommand(o),
+		newAdoptCommand(o),
+		newDoctorCommand(o),
+		newSimulateCommand(o),
+		newRestartConsumersCommand(o),
+		newExportCommand(o),
+		newHistoryCommand(o),
+		newRollbackCommand(o),
+	)
+	return cmd
+}
+
+// complete loads the kubeconfig and constructs the client
+func (o *Options) complete() error {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = o.kubeconfig
+	config := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: o.kubeContext})
+
+	if o.namespace == "" {
+		namespace, _, err := config.Namespace()
+		if err != nil {
+			return fmt.Errorf("error loading namespace: %v", err)
+		}
+		o.namespace = namespace
+	}
+
+	restConfig, err := config.ClientConfig()
+	if err != nil {
+		return fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+	o.client, err = client.New(restConfig, client.Options{})
+	if err != nil {
+		return fmt.Errorf("error creating client: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,217 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/graph"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave CLI Suite", func() {
+	var o *Options
+	var out *bytes.Buffer
+	var ctx = context.TODO()
+
+	annotationsOf := func(name string) map[string]string {
+		d := &appsv1.Deployment{}
+		Expect(o.client.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d.GetAnnotations()
+	}
+
+	BeforeEach(func() {
+		managed := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "managed",
+				Annotations: map[string]string{core.RequiredAnnotation: "true"},
+			},
+		}
+		managed.Spec.Template.Annotations = map[string]string{core.ConfigHashAnnotation: "0123456789abcdef"}
+		unmanaged := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "unmanaged",
+				Labels:    map[string]string{"app.kubernetes.io/part-of": "shop"},
+			},
+		}
+
+		out = &bytes.Buffer{}
+		o = &Options{
+			namespace: "default",
+			client:    fake.NewFakeClient(managed, unmanaged),
+			out:       out,
+			now:       func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) },
+		}
+	})
+
+	Context("parseWorkload", func() {
+		It("accepts kind aliases", func() {
+			kind, name, err := parseWorkload("sts/example")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(kind).To(Equal("StatefulSet"))
+			Expect(name).To(Equal("example"))
+		})
+
+		It("rejects unsupported kinds", func() {
+			_, _, err := parseWorkload("pod/example")
+			Expect(err).To(HaveOccurred())
+		})
+
+		It("rejects arguments without a name", func() {
+			_, _, err := parseWorkload("deployment")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("status", func() {
+		It("lists the workloads Wave manages", func() {
+			Expect(o.status(ctx, nil, false)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("Deployment/managed"))
+			Expect(out.String()).To(ContainSubstring("0123456789ab"))
+			Expect(out.String()).NotTo(ContainSubstring("Deployment/unmanaged"))
+		})
+
+		It("shows named workloads even if Wave does not manage them", func() {
+			Expect(o.status(ctx, []string{"deploy/unmanaged"}, false)).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("Deployment/unmanaged"))
+		})
+	})
+
+	Context("pause and resume", func() {
+		It("sets and removes the paused annotation", func() {
+			Expect(o.annotateAll(ctx, []string{"deployment/managed"}, core.PausedAnnotation, "true", "paused")).To(Succeed())
+			Expect(annotationsOf("managed")).To(HaveKeyWithValue(core.PausedAnnotation, "true"))
+
+			Expect(o.annotateAll(ctx, []string{"deployment/managed"}, core.PausedAnnotation, "", "resumed")).To(Succeed())
+			Expect(annotationsOf("managed")).NotTo(HaveKey(core.PausedAnnotation))
+		})
+
+		It("refuses workloads Wave does not manage", func() {
+			Expect(o.annotateAll(ctx, []string{"deployment/unmanaged"}, core.PausedAnnotation, "true", "paused")).NotTo(Succeed())
+		})
+	})
+
+	Context("trigger", func() {
+		It("sets the trigger annotation to the current time", func() {
+			cmd := newTriggerCommand(o)
+			Expect(cmd.RunE(cmd, []string{"deployment/managed"})).To(Succeed())
+			Expect(annotationsOf("managed")).To(HaveKeyWithValue(core.TriggerAnnotation, "2019-01-02T03:04:05Z"))
+			Expect(out.String()).To(Equal("Deployment/managed triggered\n"))
+		})
+	})
+
+	Context("diff", func() {
+		It("reports a pending restart", func() {
+			Expect(o.diff(ctx, nil)).To(Succeed())
+			Expect(out.String()).To(HavePrefix("Deployment/managed: restart pending\n  applied: 0123456789ab\n"))
+			Expect(out.String()).To(ContainSubstring("sources: unknown"))
+		})
+	})
+
+	Context("graph", func() {
+		It("fetches the graph from the controller", func() {
+			server := httptest.NewServer(graph.NewServer(o.client, "", nil))
+			defer server.Close()
+
+			o.output = "json"
+			Expect(o.graph(ctx, &graphOptions{server: server.URL})).To(Succeed())
+			g := graph.Graph{}
+			Expect(json.Unmarshal(out.Bytes(), &g)).To(Succeed())
+			Expect(g.Workloads).To(HaveLen(2))
+		})
+	})
+
+	Context("output", func() {
+		It("prints the status as JSON", func() {
+			o.output = "json"
+			Expect(o.status(ctx, nil, false)).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`{
+				"kind": "WorkloadStatusList",
+				"items": [{
+					"namespace": "default",
+					"kind": "Deployment",
+					"name": "managed",
+					"enabled": true,
+					"paused": false,
+					"hash": "0123456789abcdef"
+				}]
+			}`))
+		})
+
+		It("prints the diff as YAML", func() {
+			o.output = "yaml"
+			Expect(o.diff(ctx, nil)).To(Succeed())
+			Expect(out.String()).To(HavePrefix("items:\n- appliedHash: 0123456789abcdef\n"))
+			Expect(out.String()).To(ContainSubstring("  pending: true\n"))
+			Expect(out.String()).To(HaveSuffix("kind: WorkloadDiffList\n"))
+		})
+
+		It("prints an empty list rather than null", func() {
+			o.output = "json"
+			Expect(o.adopt(ctx, &adoptOptions{selector: "app=none", dryRun: true})).To(Succeed())
+			Expect(out.String()).To(MatchJSON(`{"kind": "WorkloadResultList", "items": []}`))
+		})
+
+		It("rejects unsupported formats", func() {
+			cmd := NewCommand()
+			cmd.SetArgs([]string{"status", "-o", "dot"})
+			cmd.SetOutput(&bytes.Buffer{})
+			Expect(cmd.Execute()).To(MatchError(ContainSubstring("unsupported output format")))
+		})
+	})
+
+	Context("export", func() {
+		It("prints the controller's state as YAML", func() {
+			server := httptest.NewServer(graph.NewServer(o.client, "", nil))
+			defer server.Close()
+
+			Expect(o.export(ctx, &exportOptions{server: server.URL})).To(Succeed())
+			Expect(out.String()).To(ContainSubstring("  name: managed\n"))
+			Expect(out.String()).To(ContainSubstring("time: "))
+		})
+	})
+
+	Context("adopt", func() {
+		It("only prints the workloads in a dry run", func() {
+			Expect(o.adopt(ctx, &adoptOptions{selector: "app.kubernetes.io/part-of=shop", dryRun: true})).To(Succeed())
+			Expect(out.String()).To(HavePrefix("default/Deployment/unmanaged would be adopted\n"))
+			Expect(annotationsOf("unmanaged")).NotTo(HaveKey(core.RequiredAnnotation))
+		})
+
+		It("annotates the matching workloads", func() {
+			Expect(o.adopt(ctx, &adoptOptions{selector: "app.kubernetes.io/part-of=shop"})).To(Succeed())
+			Expect(out.String()).To(Equal("default/Deployment/unmanaged adopted\n"))
+			Expect(annotationsOf("unmanaged")).To(HaveKeyWithValue(core.RequiredAnnotation, "true"))
+		})
+
+		It("requires a selector", func() {
+			Expect(o.adopt(ctx, &adoptOptions{})).NotTo(Succeed())
+		})
+	})
+})
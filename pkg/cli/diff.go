@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// workloadDiff is the machine-readable configuration diff of a workload
+type workloadDiff struct {
+	workloadReference
+	Pending bool `json:"pending"`
+	core.ConfigDiff
+}
+
+// newDiffCommand constructs the diff command
+func newDiffCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "diff [kind/name...]",
+		Short: "Show whether a restart is pending and which configuration changed",
+		Long: `Compare the configuration hash applied to the named workloads or, if none are
+named, to every workload Wave manages in the namespace, with the hash Wave
+would calculate now, and show the ConfigMaps, Secrets and keys that differ.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.diff(context.Background(), args)
+		},
+	}
+}
+
+// diff prints the configuration diff of each workload
+func (o *Options) diff(ctx context.Context, args []string) error {
+	workloads, err := o.selectWorkloads(ctx, args, o.namespace)
+	if err != nil {
+		return err
+	}
+
+	items := []workloadDiff{}
+	for _, w := range workloads {
+		diff, err := core.DiffConfig(ctx, o.client, w.Object)
+		if err != nil {
+			return fmt.Errorf("error comparing configuration of %s: %v", w, err)
+		}
+		if o.structured() {
+			items = append(items, workloadDiff{workloadReference: w.reference(), Pending: diff.Pending(), ConfigDiff: diff})
+			continue
+		}
+		o.printDiff(w, diff)
+	}
+	if o.structured() {
+		return o.printList("WorkloadDiffList", items)
+	}
+	return nil
+}
+
+// printDiff prints a human readable description of the diff
+func (o *Options) printDiff(w workload, diff core.ConfigDiff) {
+	if !diff.Pending() {
+		fmt.Fprintf(o.out, "%s: up to date (%s)\n", w, shortHash(diff.AppliedHash))
+		return
+	}
+
+	state := "restart pending"
+	if diff.Paused {
+		state = "restart pending, paused"
+	}
+	fmt.Fprintf(o.out, "%s: %s\n", w, state)
+	fmt.Fprintf(o.out, "  applied: %s\n", orNone(shortHash(diff.AppliedHash)))
+	fmt.Fprintf(o.out, "  current: %s\n", shortHash(diff.CurrentHash))
+	if !diff.SourcesKnown {
+		fmt.Fprintf(o.out, "  sources: unknown, no source hashes were recorded with the applied hash\n")
+		return
+	}
+	for _, source := range diff.Sources {
+		fmt.Fprintf(o.out, "  %s %s: %s\n", source.Source, source.Change, strings.Join(source.Keys, ", "))
+	}
+	if len(diff.Sources) == 0 && w.annotation(core.TriggerAnnotation) != "" {
+		fmt.Fprintf(o.out, "  triggered at %s\n", w.annotation(core.TriggerAnnotation))
+	}
+}
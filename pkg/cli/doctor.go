@@ -0,0 +1,438 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	severityError   = "ERROR"
+	severityWarning = "WARNING"
+)
+
+// waveAnnotationPrefix is the prefix of every annotation Wave reads
+const waveAnnotationPrefix = "wave.pusher.com/"
+
+// knownAnnotations are the annotations Wave reads or writes on workloads
+var knownAnnotations = []string{
+	core.RequiredAnnotation,
+	core.PausedAnnotation,
+	core.TriggerAnnotation,
+	core.SourceHashesAnnotation,
+	core.AppliedTriggerAnnotation,
+	core.ApprovalTTLAnnotation,
+	core.ApprovalAnnotation,
+	core.AllowDowntimeAnnotation,
+	core.MaintenanceWindowAnnotation,
+	core.PluginSourcesAnnotation,
+	core.ExecutedHashAnnotation,
+	core.ExtraURLsAnnotation,
+	core.HashPathAnnotation,
+	core.StatusAnnotation,
+	core.OriginalMaxUnavailableAnnotation,
+}
+
+// reloaderAnnotations are annotations used by Stakater Reloader, which also
+// restarts workloads when their configuration changes
+var reloaderAnnotations = []string{
+	"reloader.stakater.com/auto",
+	"reloader.stakater.com/search",
+	"configmap.reloader.stakater.com/reload",
+	"secret.reloader.stakater.com/reload",
+}
+
+// requiredPermissions lists the permissions the Wave controller needs
+var requiredPermissions = []authorizationv1.ResourceAttributes{
+	{Group: "apps", Resource: "deployments", Verb: "update"},
+	{Group: "apps", Resource: "statefulsets", Verb: "update"},
+	{Group: "apps", Resource: "daemonsets", Verb: "update"},
+	{Group: "apps", Resource: "deployments", Verb: "watch"},
+	{Group: "apps", Resource: "statefulsets", Verb: "watch"},
+	{Group: "apps", Resource: "daemonsets", Verb: "watch"},
+	{Resource: "configmaps", Verb: "watch"},
+	{Resource: "configmaps", Verb: "update"},
+	{Resource: "secrets", Verb: "watch"},
+	{Resource: "secrets", Verb: "update"},
+	{Resource: "events", Verb: "create"},
+}
+
+// finding is a problem discovered by the doctor command
+type finding struct {
+	severity string
+	check    string
+	message  string
+	advice   string
+}
+
+// findingOutput is the machine-readable form of a finding
+type findingOutput struct {
+	Severity string `json:"severity"`
+	Check    string `json:"check"`
+	Message  string `json:"message"`
+	Advice   string `json:"advice,omitempty"`
+}
+
+// doctorOptions holds the flags of the doctor command
+type doctorOptions struct {
+	controllerNamespace string
+	serviceAccount      string
+	allNamespaces       bool
+}
+
+// newDoctorCommand constructs the doctor command
+func newDoctorCommand(o *Options) *cobra.Command {
+	d := &doctorOptions{}
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose common problems with Wave",
+		Long: `Check the Wave controller's RBAC permissions and webhook configuration, look
+for orphaned OwnerReferences and finalizers, controllers that conflict with
+Wave and misspelled Wave annotations, and print actionable findings.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			findings := o.doctor(context.Background(), d)
+			if err := o.printFindings(findings); err != nil {
+				return err
+			}
+			for _, f := range findings {
+				if f.severity == severityError {
+					return fmt.Errorf("found %d problem(s)", len(findings))
+				}
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&d.controllerNamespace, "controller-namespace", "wave", "Namespace the Wave controller runs in")
+	cmd.Flags().StringVar(&d.serviceAccount, "service-account", "wave", "ServiceAccount the Wave controller runs as")
+	cmd.Flags().BoolVarP(&d.allNamespaces, "all-namespaces", "A", false, "Check workloads in all namespaces")
+	return cmd
+}
+
+// doctor runs every check and returns the findings
+func (o *Options) doctor(ctx context.Context, d *doctorOptions) []finding {
+	namespace := o.namespace
+	if d.allNamespaces {
+		namespace = ""
+	}
+
+	var findings []finding
+	checks := []struct {
+		name string
+		run  func() ([]finding, error)
+	}{
+		{"rbac", func() ([]finding, error) { return o.checkRBAC(ctx, d) }},
+		{"webhooks", func() ([]finding, error) { return o.checkWebhooks(ctx) }},
+		{"workloads", func() ([]finding, error) { return o.checkWorkloads(ctx, namespace) }},
+		{"owner-references", func() ([]finding, error) { return o.checkOwnerReferences(ctx, namespace) }},
+	}
+	for _, check := range checks {
+		result, err := check.run()
+		if err != nil {
+			findings = append(findings, finding{
+				severity: severityWarning,
+				check:    check.name,
+				message:  fmt.Sprintf("unable to run check: %v", err),
+				advice:   "Run the doctor with a user allowed to read the resources involved",
+			})
+			continue
+		}
+		findings = append(findings, result...)
+	}
+	return findings
+}
+
+// printFindings prints the findings or a reassuring message if there are none
+func (o *Options) printFindings(findings []finding) error {
+	if o.structured() {
+		items := []findingOutput{}
+		for _, f := range findings {
+			items = append(items, findingOutput{Severity: f.severity, Check: f.check, Message: f.message, Advice: f.advice})
+		}
+		return o.printList("FindingList", items)
+	}
+	if len(findings) == 0 {
+		fmt.Fprintln(o.out, "No problems found")
+		return nil
+	}
+	for _, f := range findings {
+		fmt.Fprintf(o.out, "[%s] %s: %s\n", f.severity, f.check, f.message)
+		if f.advice != "" {
+			fmt.Fprintf(o.out, "    %s\n", f.advice)
+		}
+	}
+	return nil
+}
+
+// checkRBAC checks that the controller's ServiceAccount holds every
+// permission Wave needs
+func (o *Options) checkRBAC(ctx context.Context, d *doctorOptions) ([]finding, error) {
+	user := fmt.Sprintf("system:serviceaccount:%s:%s", d.controllerNamespace, d.serviceAccount)
+	var findings []finding
+	for _, permission := range requiredPermissions {
+		attributes := permission
+		review := &authorizationv1.SubjectAccessReview{
+			Spec: authorizationv1.SubjectAccessReviewSpec{
+				User:               user,
+				Groups:             []string{"system:serviceaccounts", "system:serviceaccounts:" + d.controllerNamespace},
+				ResourceAttributes: &attributes,
+			},
+		}
+		if err := o.client.Create(ctx, review); err != nil {
+			return nil, err
+		}
+		if !review.Status.Allowed {
+			resource := permission.Resource
+			if permission.Group != "" {
+				resource = fmt.Sprintf("%s.%s", permission.Resource, permission.Group)
+			}
+			findings = append(findings, finding{
+				severity: severityError,
+				check:    "rbac",
+				message:  fmt.Sprintf("%s cannot %s %s", user, permission.Verb, resource),
+				advice:   "Grant the permission in Wave's ClusterRole (see config/rbac/manager_role.yaml)",
+			})
+		}
+	}
+	return findings, nil
+}
+
+// checkWebhooks checks that every webhook configured for Wave points at a
+// Service that exists
+func (o *Options) checkWebhooks(ctx context.Context) ([]finding, error) {
+	var services []*admissionv1beta1.ServiceReference
+	var names []string
+
+	mutating := &admissionv1beta1.MutatingWebhookConfigurationList{}
+	if err := o.client.List(ctx, mutating); err != nil {
+		return nil, err
+	}
+	for _, config := range mutating.Items {
+		for _, webhook := range config.Webhooks {
+			if isWaveWebhook(config.Name, webhook.Name) && webhook.ClientConfig.Service != nil {
+				services = append(services, webhook.ClientConfig.Service)
+				names = append(names, webhook.Name)
+			}
+		}
+	}
+
+	validating := &admissionv1beta1.ValidatingWebhookConfigurationList{}
+	if err := o.client.List(ctx, validating); err != nil {
+		return nil, err
+	}
+	for _, config := range validating.Items {
+		for _, webhook := range config.Webhooks {
+			if isWaveWebhook(config.Name, webhook.Name) && webhook.ClientConfig.Service != nil {
+				services = append(services, webhook.ClientConfig.Service)
+				names = append(names, webhook.Name)
+			}
+		}
+	}
+
+	var findings []finding
+	for i, ref := range services {
+		service := &corev1.Service{}
+		err := o.client.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, service)
+		if errors.IsNotFound(err) {
+			findings = append(findings, finding{
+				severity: severityError,
+				check:    "webhooks",
+				message:  fmt.Sprintf("webhook %s points at Service %s/%s, which does not exist", names[i], ref.Namespace, ref.Name),
+				advice:   "Remove the stale webhook configuration or redeploy Wave",
+			})
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return findings, nil
+}
+
+// isWaveWebhook returns whether the webhook belongs to Wave
+func isWaveWebhook(configName, webhookName string) bool {
+	return strings.Contains(configName, "wave") || strings.HasSuffix(webhookName, "wave.pusher.com")
+}
+
+// checkWorkloads checks workloads for misspelled annotations, stale
+// finalizers and conflicting controllers
+func (o *Options) checkWorkloads(ctx context.Context, namespace string) ([]finding, error) {
+	workloads, err := o.listWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []finding
+	add := func(severity string, w workload, message, advice string) {
+		findings = append(findings, finding{
+			severity: severity,
+			check:    "workloads",
+			message:  fmt.Sprintf("%s/%s %s", w.GetNamespace(), w, message),
+			advice:   advice,
+		})
+	}
+
+	for _, w := range workloads {
+		annotations := w.GetAnnotations()
+		for key := range annotations {
+			if suggestion := suggestAnnotation(key); suggestion != "" {
+				add(severityError, w, fmt.Sprintf("has unknown annotation %q", key), fmt.Sprintf("Did you mean %q?", suggestion))
+			}
+		}
+		if _, err := core.TriggerPolicyOf(w); err != nil {
+			add(severityError, w, fmt.Sprintf("has annotation %s=%q", core.RequiredAnnotation, annotations[core.RequiredAnnotation]), "Wave leaves the workload as it is until the value is \"true\", \"false\" or a trigger policy")
+		}
+
+		if w.enabled() {
+			for _, key := range reloaderAnnotations {
+				if _, ok := annotations[key]; ok {
+					add(severityWarning, w, fmt.Sprintf("is managed by both Wave and Reloader (%s)", key), "Remove one of the annotations to avoid double restarts")
+				}
+			}
+		}
+
+		if !w.enabled() && w.GetDeletionTimestamp() == nil {
+			for _, finalizer := range w.GetFinalizers() {
+				if finalizer == core.FinalizerString {
+					add(severityWarning, w, "has Wave's finalizer but not the required annotation", "Check that the Wave controller is running so that it can clean up")
+				}
+			}
+		}
+	}
+	return findings, nil
+}
+
+// suggestAnnotation returns the known annotation a Wave annotation key is
+// likely a typo of. It returns an empty string for known keys and keys that
+// do not belong to Wave.
+func suggestAnnotation(key string) string {
+	parts := strings.SplitN(key, "/", 2)
+	if len(parts) != 2 || !strings.Contains(parts[0], "wave") {
+		return ""
+	}
+	for _, known := range knownAnnotations {
+		if key == known {
+			return ""
+		}
+	}
+
+	best, bestDistance := core.RequiredAnnotation, -1
+	for _, known := range knownAnnotations {
+		distance := levenshtein(parts[1], strings.TrimPrefix(known, waveAnnotationPrefix))
+		if bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// minInt returns the smallest of the values
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// checkOwnerReferences checks for ConfigMaps and Secrets with
+// OwnerReferences to workloads that no longer use them
+func (o *Options) checkOwnerReferences(ctx context.Context, namespace string) ([]finding, error) {
+	workloads, err := o.listWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	managed := make(map[types.UID]bool)
+	for _, w := range workloads {
+		managed[w.GetUID()] = w.enabled()
+	}
+
+	var children []core.Object
+	configMaps := &corev1.ConfigMapList{}
+	if err := o.client.List(ctx, configMaps, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range configMaps.Items {
+		children = append(children, &configMaps.Items[i])
+	}
+	secrets := &corev1.SecretList{}
+	if err := o.client.List(ctx, secrets, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	for i := range secrets.Items {
+		children = append(children, &secrets.Items[i])
+	}
+
+	var findings []finding
+	for _, child := range children {
+		kind := "ConfigMap"
+		if _, ok := child.(*corev1.Secret); ok {
+			kind = "Secret"
+		}
+		for _, ref := range child.GetOwnerReferences() {
+			if ref.APIVersion != "apps/v1" || !isWorkloadKind(ref.Kind) {
+				continue
+			}
+			enabled, exists := managed[ref.UID]
+			if exists && enabled {
+				continue
+			}
+			reason := "which no longer exists"
+			if exists {
+				reason = "which is no longer managed by Wave"
+			}
+			findings = append(findings, finding{
+				severity: severityWarning,
+				check:    "owner-references",
+				message:  fmt.Sprintf("%s %s/%s is owned by %s/%s %s", kind, child.GetNamespace(), child.GetName(), ref.Kind, ref.Name, reason),
+				advice:   "Remove the OwnerReference so that the object is not garbage collected unexpectedly",
+			})
+		}
+	}
+	return findings, nil
+}
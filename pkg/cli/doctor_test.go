@@ -0,0 +1,163 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// reviewClient answers SubjectAccessReviews, allowing only the given verbs
+type reviewClient struct {
+	client.Client
+	allowed map[string]bool
+}
+
+func (c *reviewClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if review, ok := obj.(*authorizationv1.SubjectAccessReview); ok {
+		review.Status.Allowed = c.allowed[review.Spec.ResourceAttributes.Verb]
+		return nil
+	}
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+var _ = Describe("Wave CLI doctor Suite", func() {
+	var o *Options
+	var ctx = context.TODO()
+
+	messages := func(findings []finding) []string {
+		result := []string{}
+		for _, f := range findings {
+			result = append(result, f.message)
+		}
+		return result
+	}
+
+	withObjects := func(objs ...runtime.Object) {
+		o = &Options{namespace: "default", client: fake.NewFakeClient(objs...), out: &bytes.Buffer{}}
+	}
+
+	Context("suggestAnnotation", func() {
+		It("ignores known annotations and those of other tools", func() {
+			Expect(suggestAnnotation(core.RequiredAnnotation)).To(BeEmpty())
+			Expect(suggestAnnotation("deployment.kubernetes.io/revision")).To(BeEmpty())
+		})
+
+		It("suggests the closest known annotation", func() {
+			Expect(suggestAnnotation("wave.pusher.com/update-on-config-changes")).To(Equal(core.RequiredAnnotation))
+			Expect(suggestAnnotation("wave.pusher.io/paused")).To(Equal(core.PausedAnnotation))
+		})
+	})
+
+	Context("checkWorkloads", func() {
+		It("reports typos, conflicts and stale finalizers", func() {
+			withObjects(
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "default",
+					Name:        "typo",
+					Annotations: map[string]string{"wave.pusher.com/update-on-config-changes": "true"},
+				}},
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Namespace: "default",
+					Name:      "reloader",
+					Annotations: map[string]string{
+						core.RequiredAnnotation:      "true",
+						"reloader.stakater.com/auto": "true",
+					},
+				}},
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Namespace:   "default",
+					Name:        "policy",
+					Annotations: map[string]string{core.RequiredAnnotation: "debounce"},
+				}},
+				&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+					Namespace:  "default",
+					Name:       "finalizer",
+					Finalizers: []string{core.FinalizerString},
+				}},
+			)
+			findings, err := o.checkWorkloads(ctx, "default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages(findings)).To(ConsistOf(
+				`default/Deployment/typo has unknown annotation "wave.pusher.com/update-on-config-changes"`,
+				"default/Deployment/reloader is managed by both Wave and Reloader (reloader.stakater.com/auto)",
+				`default/Deployment/policy has annotation wave.pusher.com/update-on-config-change="debounce"`,
+				"default/Deployment/finalizer has Wave's finalizer but not the required annotation",
+			))
+		})
+	})
+
+	Context("checkOwnerReferences", func() {
+		It("reports OwnerReferences to workloads that no longer exist", func() {
+			withObjects(&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "orphan",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "gone", UID: "1234"},
+				},
+			}})
+			findings, err := o.checkOwnerReferences(ctx, "default")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages(findings)).To(ConsistOf("ConfigMap default/orphan is owned by Deployment/gone which no longer exists"))
+		})
+	})
+
+	Context("checkWebhooks", func() {
+		It("reports webhooks pointing at missing Services", func() {
+			withObjects(&admissionv1beta1.MutatingWebhookConfiguration{
+				ObjectMeta: metav1.ObjectMeta{Name: "wave-webhook"},
+				Webhooks: []admissionv1beta1.Webhook{{
+					Name: "pods.wave.pusher.com",
+					ClientConfig: admissionv1beta1.WebhookClientConfig{
+						Service: &admissionv1beta1.ServiceReference{Namespace: "wave", Name: "wave-webhook"},
+					},
+				}},
+			})
+			findings, err := o.checkWebhooks(ctx)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages(findings)).To(ConsistOf("webhook pods.wave.pusher.com points at Service wave/wave-webhook, which does not exist"))
+		})
+	})
+
+	Context("checkRBAC", func() {
+		It("reports permissions that are not allowed", func() {
+			withObjects()
+			o.client = &reviewClient{Client: o.client, allowed: map[string]bool{"watch": true, "create": true}}
+			findings, err := o.checkRBAC(ctx, &doctorOptions{controllerNamespace: "wave", serviceAccount: "wave"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(messages(findings)).To(ConsistOf(
+				"system:serviceaccount:wave:wave cannot update deployments.apps",
+				"system:serviceaccount:wave:wave cannot update statefulsets.apps",
+				"system:serviceaccount:wave:wave cannot update daemonsets.apps",
+				"system:serviceaccount:wave:wave cannot update configmaps",
+				"system:serviceaccount:wave:wave cannot update secrets",
+			))
+		})
+	})
+})
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/graph"
+)
+
+// exportOptions holds the flags of the export command
+type exportOptions struct {
+	server        string
+	allNamespaces bool
+}
+
+// newExportCommand constructs the export command
+func newExportCommand(o *Options) *cobra.Command {
+	e := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export --server URL",
+		Short: "Export the state the Wave controller tracks about workloads",
+		Long: `Export the dependency graph and the state the Wave controller holds in memory
+about each workload, read from the state endpoint of the controller, for
+snapshotting during incidents or comparing Wave versions during upgrades.
+
+The export is printed as YAML unless -o json is given.`,
+		Annotations: map[string]string{outputAnnotation: "json,yaml"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.export(context.Background(), e)
+		},
+	}
+	cmd.Flags().StringVar(&e.server, "server", "", "URL of the Wave controller's graph endpoint, e.g. http://localhost:8082")
+	cmd.Flags().BoolVarP(&e.allNamespaces, "all-namespaces", "A", false, "Export workloads in all namespaces")
+	cmd.MarkFlagRequired("server")
+	return cmd
+}
+
+// export fetches the controller's state and prints it
+func (o *Options) export(ctx context.Context, e *exportOptions) error {
+	namespace := o.namespace
+	if e.allNamespaces {
+		namespace = ""
+	}
+
+	query := url.Values{"namespace": {namespace}, "format": {"json"}}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(e.server, "/")+graph.StatePath+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("error fetching state: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response fetching state: %s", resp.Status)
+	}
+	export := graph.Export{}
+	if err := json.NewDecoder(resp.Body).Decode(&export); err != nil {
+		return fmt.Errorf("error decoding state: %v", err)
+	}
+
+	if o.output == "" {
+		o.output = outputYAML
+	}
+	return o.printObject(export)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/graph"
+)
+
+// graphOptions holds the flags of the graph command
+type graphOptions struct {
+	server        string
+	allNamespaces bool
+}
+
+// newGraphCommand constructs the graph command
+func newGraphCommand(o *Options) *cobra.Command {
+	g := &graphOptions{}
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "Show which ConfigMaps and Secrets each workload depends on",
+		Long: `Show the dependency graph between workloads and the ConfigMaps and Secrets
+they use, read from the cluster or, with --server, from the graph endpoint of
+the Wave controller.`,
+		Annotations: map[string]string{outputAnnotation: "table,dot,json,yaml"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.graph(context.Background(), g)
+		},
+	}
+	cmd.Flags().StringVar(&g.server, "server", "", "URL of the Wave controller's graph endpoint, e.g. http://localhost:8082")
+	cmd.Flags().BoolVarP(&g.allNamespaces, "all-namespaces", "A", false, "Show workloads in all namespaces")
+	return cmd
+}
+
+// graph prints the dependency graph
+func (o *Options) graph(ctx context.Context, g *graphOptions) error {
+	namespace := o.namespace
+	if g.allNamespaces {
+		namespace = ""
+	}
+
+	var result graph.Graph
+	var err error
+	if g.server != "" {
+		result, err = fetchGraph(ctx, g.server, namespace)
+	} else {
+		result, err = graph.Build(ctx, o.client, namespace)
+	}
+	if err != nil {
+		return err
+	}
+	if o.structured() {
+		return o.printObject(result)
+	}
+	return result.Write(o.out, o.output)
+}
+
+// fetchGraph fetches the graph from the controller's graph endpoint
+func fetchGraph(ctx context.Context, server, namespace string) (graph.Graph, error) {
+	query := url.Values{"namespace": {namespace}, "format": {"json"}}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(server, "/")+graph.Path+"?"+query.Encode(), nil)
+	if err != nil {
+		return graph.Graph{}, fmt.Errorf("error building request: %v", err)
+	}
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return graph.Graph{}, fmt.Errorf("error fetching graph: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return graph.Graph{}, fmt.Errorf("unexpected response fetching graph: %s", resp.Status)
+	}
+
+	result := graph.Graph{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return graph.Graph{}, fmt.Errorf("error decoding graph: %v", err)
+	}
+	return result, nil
+}
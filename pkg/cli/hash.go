@@ -0,0 +1,135 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/ghodss/yaml"
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// hashOptions holds the flags of the hash command
+type hashOptions struct {
+	files  []string
+	inject bool
+}
+
+// manifestHash is the machine-readable hash of a workload in a manifest
+type manifestHash struct {
+	workloadReference
+	Path string `json:"path"`
+	Hash string `json:"hash"`
+}
+
+// newHashCommand constructs the hash command
+func newHashCommand(o *Options) *cobra.Command {
+	h := &hashOptions{}
+	cmd := &cobra.Command{
+		Use:   "hash -f manifests/",
+		Short: "Calculate configuration hashes from local manifests",
+		Long: `Calculate the configuration hash Wave would apply to each workload it manages
+in local YAML or JSON manifests, resolving references to ConfigMaps and Secrets
+within the same set of files.
+
+With --inject, the manifests are printed with the hash placed where Wave
+would place it and the annotations Wave would set, so that GitOps repositories
+can commit the exact hash Wave computes.`,
+		Annotations: map[string]string{offlineAnnotation: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.hash(context.Background(), h)
+		},
+	}
+	cmd.Flags().StringSliceVarP(&h.files, "filename", "f", nil, "Files or directories containing the manifests")
+	cmd.Flags().BoolVar(&h.inject, "inject", false, "Print the manifests with the annotations injected")
+	cmd.MarkFlagRequired("filename")
+	return cmd
+}
+
+// hash calculates the configuration hash of each managed workload in the
+// manifests
+func (o *Options) hash(ctx context.Context, h *hashOptions) error {
+	namespace := o.namespace
+	if namespace == "" {
+		namespace = "default"
+	}
+	manifests, err := readManifests(h.files, namespace)
+	if err != nil {
+		return err
+	}
+
+	if h.inject && o.output != "" {
+		return fmt.Errorf("--inject prints the manifests and cannot be combined with --output")
+	}
+
+	c := manifestClient(manifests)
+	items := []manifestHash{}
+	for _, m := range manifests {
+		if m.object == nil || core.WorkloadKind(m.object) == "Unknown" || !core.Managed(m.object) {
+			continue
+		}
+		path, err := core.HashPathOf(m.object)
+		if err != nil && h.inject {
+			return fmt.Errorf("error injecting hash of %s in %s: %v", m.describe(), m.path, err)
+		}
+		hash, err := core.SetConfig(ctx, c, m.object)
+		if err != nil {
+			return fmt.Errorf("error calculating hash of %s in %s: %v", m.describe(), m.path, err)
+		}
+		if h.inject {
+			m.setTemplateHash(path, hash)
+			m.setAnnotation(core.SourceHashesAnnotation, m.object.GetAnnotations()[core.SourceHashesAnnotation])
+			continue
+		}
+		items = append(items, manifestHash{
+			workloadReference: workloadReference{Namespace: m.object.GetNamespace(), Kind: core.WorkloadKind(m.object), Name: m.object.GetName()},
+			Path:              m.path,
+			Hash:              hash,
+		})
+	}
+	if o.structured() {
+		return o.printList("ManifestHashList", items)
+	}
+	if !h.inject {
+		tw := tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tHASH")
+		for _, item := range items {
+			fmt.Fprintf(tw, "%s\t%s/%s\t%s\n", item.Namespace, item.Kind, item.Name, item.Hash)
+		}
+		return tw.Flush()
+	}
+
+	for i, m := range manifests {
+		data, err := json.Marshal(m.fields)
+		if err != nil {
+			return fmt.Errorf("unable to marshal JSON: %v", err)
+		}
+		out, err := yaml.JSONToYAML(data)
+		if err != nil {
+			return fmt.Errorf("unable to convert to YAML: %v", err)
+		}
+		if i > 0 {
+			fmt.Fprintln(o.out, "---")
+		}
+		o.out.Write(out)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const hashManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example
+  annotations:
+    wave.pusher.com/update-on-config-change: "true"
+spec:
+  template:
+    spec:
+      containers:
+      - name: example
+        envFrom:
+        - configMapRef:
+            name: example
+        - secretRef:
+            name: example
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: unmanaged
+spec:
+  template:
+    spec:
+      containers:
+      - name: unmanaged
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: example
+data:
+  key: value
+`
+
+var _ = Describe("Wave CLI hash Suite", func() {
+	var dir string
+	var o *Options
+	var out *bytes.Buffer
+
+	writeManifests := func(secret string) {
+		Expect(ioutil.WriteFile(filepath.Join(dir, "workloads.yaml"), []byte(hashManifests), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "secret.yml"), []byte(secret), 0644)).To(Succeed())
+		Expect(ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("not a manifest"), 0644)).To(Succeed())
+	}
+
+	hashOf := func() string {
+		out.Reset()
+		Expect(o.hash(context.TODO(), &hashOptions{files: []string{dir}})).To(Succeed())
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		Expect(lines).To(HaveLen(2))
+		return strings.Fields(lines[1])[2]
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "wave-hash")
+		Expect(err).NotTo(HaveOccurred())
+		out = &bytes.Buffer{}
+		o = &Options{out: out}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("calculates the hash of managed workloads", func() {
+		writeManifests("apiVersion: v1\nkind: Secret\nmetadata:\n  name: example\nstringData:\n  key: value\n")
+		hash := hashOf()
+		Expect(out.String()).To(ContainSubstring("default"))
+		Expect(out.String()).To(ContainSubstring("Deployment/example"))
+		Expect(out.String()).NotTo(ContainSubstring("Deployment/unmanaged"))
+
+		// stringData is hashed as the API server would store it
+		writeManifests("apiVersion: v1\nkind: Secret\nmetadata:\n  name: example\ndata:\n  key: dmFsdWU=\n")
+		Expect(hashOf()).To(Equal(hash))
+	})
+
+	It("fails when a required reference is missing", func() {
+		writeManifests("")
+		Expect(o.hash(context.TODO(), &hashOptions{files: []string{dir}})).NotTo(Succeed())
+	})
+
+	It("injects the annotations into the manifests", func() {
+		writeManifests("apiVersion: v1\nkind: Secret\nmetadata:\n  name: example\nstringData:\n  key: value\n")
+		hash := hashOf()
+
+		out.Reset()
+		Expect(o.hash(context.TODO(), &hashOptions{files: []string{filepath.Join(dir, "secret.yml"), filepath.Join(dir, "workloads.yaml")}, inject: true})).To(Succeed())
+		manifests, err := decodeManifests("output", out.Bytes(), "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(manifests).To(HaveLen(4))
+
+		d, ok := manifests[1].object.(*appsv1.Deployment)
+		Expect(ok).To(BeTrue())
+		Expect(d.Spec.Template.Annotations).To(HaveKeyWithValue(core.ConfigHashAnnotation, hash))
+		Expect(d.Annotations).To(HaveKey(core.SourceHashesAnnotation))
+	})
+
+	Context("when the workload selects a hash path", func() {
+		// writeHashPath writes the manifests with the hash path selected
+		writeHashPath := func(path string) {
+			writeManifests("apiVersion: v1\nkind: Secret\nmetadata:\n  name: example\nstringData:\n  key: value\n")
+			workloads := strings.Replace(hashManifests, "    wave.pusher.com/update-on-config-change: \"true\"\n",
+				"    wave.pusher.com/update-on-config-change: \"true\"\n    wave.pusher.com/hash-path: "+path+"\n", 1)
+			Expect(ioutil.WriteFile(filepath.Join(dir, "workloads.yaml"), []byte(workloads), 0644)).To(Succeed())
+		}
+
+		// inject returns the hash of the workload with the hash path and the
+		// injected Deployment
+		inject := func(path string) (string, *appsv1.Deployment) {
+			writeHashPath(path)
+			hash := hashOf()
+
+			out.Reset()
+			Expect(o.hash(context.TODO(), &hashOptions{files: []string{dir}, inject: true})).To(Succeed())
+			manifests, err := decodeManifests("output", out.Bytes(), "default")
+			Expect(err).NotTo(HaveOccurred())
+			for _, m := range manifests {
+				if d, ok := m.object.(*appsv1.Deployment); ok && d.GetName() == "example" {
+					return hash, d
+				}
+			}
+			Fail("the injected manifests have no Deployment example")
+			return "", nil
+		}
+
+		It("injects the hash into an annotation", func() {
+			hash, d := inject(core.AnnotationsHashPath)
+			Expect(d.Spec.Template.Annotations).To(HaveKeyWithValue(core.ConfigHashAnnotation, hash))
+			Expect(d.Spec.Template.Labels).NotTo(HaveKey(core.ConfigHashAnnotation))
+		})
+
+		It("injects the hash into a label", func() {
+			hash, d := inject(core.LabelsHashPath)
+			Expect(hash).To(HaveLen(63))
+			Expect(d.Spec.Template.Labels).To(HaveKeyWithValue(core.ConfigHashAnnotation, hash))
+			Expect(d.Spec.Template.Annotations).NotTo(HaveKey(core.ConfigHashAnnotation))
+		})
+
+		It("injects the hash into the environment", func() {
+			hash, d := inject(core.EnvHashPath)
+			Expect(d.Spec.Template.Spec.Containers[0].Env).To(ConsistOf(corev1.EnvVar{Name: core.ConfigHashEnvVar, Value: hash}))
+			Expect(d.Spec.Template.Annotations).NotTo(HaveKey(core.ConfigHashAnnotation))
+			Expect(d.Spec.Template.Spec.Containers[0].EnvFrom).To(HaveLen(2))
+		})
+
+		It("refuses to inject the hash at an invalid path", func() {
+			writeHashPath("metadata.labels")
+			err := o.hash(context.TODO(), &hashOptions{files: []string{dir}, inject: true})
+			Expect(err).To(MatchError(ContainSubstring("invalid wave.pusher.com/hash-path")))
+		})
+	})
+})
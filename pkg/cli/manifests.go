@@ -0,0 +1,217 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// manifest is a single document read from a local file
+type manifest struct {
+	path string
+
+	// fields holds the document as decoded from YAML or JSON
+	fields map[string]interface{}
+
+	// object is the typed document, or nil if its kind is not a workload,
+	// ConfigMap or Secret
+	object core.Object
+}
+
+// readManifests reads every YAML or JSON document in the given files and
+// directories. Objects without a namespace are placed in namespace.
+func readManifests(paths []string, namespace string) ([]*manifest, error) {
+	var files []string
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			ext := filepath.Ext(file)
+			if !info.IsDir() && (file == path || ext == ".yaml" || ext == ".yml" || ext == ".json") {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+	}
+
+	manifests := []*manifest{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", file, err)
+		}
+		decoded, err := decodeManifests(file, data, namespace)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, decoded...)
+	}
+	return manifests, nil
+}
+
+// decodeManifests decodes each document in the data
+func decodeManifests(path string, data []byte, namespace string) ([]*manifest, error) {
+	manifests := []*manifest{}
+	decoder := yaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	for {
+		fields := map[string]interface{}{}
+		if err := decoder.Decode(&fields); err != nil {
+			if err == io.EOF {
+				return manifests, nil
+			}
+			return nil, fmt.Errorf("error decoding %s: %v", path, err)
+		}
+		if len(fields) == 0 {
+			continue
+		}
+
+		m := &manifest{path: path, fields: fields}
+		raw, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", path, err)
+		}
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(raw, nil, nil)
+		if err == nil {
+			m.object = asConfigOrWorkload(obj)
+		}
+		if m.object != nil && m.object.GetNamespace() == "" {
+			m.object.SetNamespace(namespace)
+		}
+		manifests = append(manifests, m)
+	}
+}
+
+// asConfigOrWorkload returns the object if it is a ConfigMap, Secret or
+// workload, normalizing Secrets as the API server would
+func asConfigOrWorkload(obj runtime.Object) core.Object {
+	switch o := obj.(type) {
+	case *corev1.ConfigMap:
+		return o
+	case *corev1.Secret:
+		// The API server merges stringData into data
+		if o.Data == nil {
+			o.Data = make(map[string][]byte)
+		}
+		for key, value := range o.StringData {
+			o.Data[key] = []byte(value)
+		}
+		o.StringData = nil
+		return o
+	default:
+		if w, ok := obj.(core.Object); ok && core.WorkloadKind(w) != "Unknown" {
+			return w
+		}
+		return nil
+	}
+}
+
+// manifestClient returns a client serving the ConfigMaps and Secrets in the
+// manifests
+func manifestClient(manifests []*manifest) client.Client {
+	objs := []runtime.Object{}
+	for _, m := range manifests {
+		switch m.object.(type) {
+		case *corev1.ConfigMap, *corev1.Secret:
+			objs = append(objs, m.object)
+		}
+	}
+	return fake.NewFakeClient(objs...)
+}
+
+// setAnnotation sets an annotation in the nested metadata of the document
+func (m *manifest) setAnnotation(key, value string, path ...string) {
+	nestedMap(m.fields, append(path, "metadata", "annotations")...)[key] = value
+}
+
+// setTemplateHash places the configuration hash at the path of the pod
+// template of the document, removing it from the other paths as Wave does
+func (m *manifest) setTemplateHash(path, hash string) {
+	template := nestedMap(m.fields, "spec", "template")
+	if metadata, ok := template["metadata"].(map[string]interface{}); ok {
+		for _, field := range []string{"annotations", "labels"} {
+			if values, ok := metadata[field].(map[string]interface{}); ok {
+				delete(values, core.ConfigHashAnnotation)
+			}
+		}
+	}
+	spec, _ := template["spec"].(map[string]interface{})
+	containers, _ := spec["containers"].([]interface{})
+	for _, c := range containers {
+		container, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		env, _ := container["env"].([]interface{})
+		kept := []interface{}{}
+		for _, e := range env {
+			if v, ok := e.(map[string]interface{}); ok && v["name"] == core.ConfigHashEnvVar {
+				continue
+			}
+			kept = append(kept, e)
+		}
+		if path == core.EnvHashPath {
+			kept = append(kept, map[string]interface{}{"name": core.ConfigHashEnvVar, "value": hash})
+		}
+		if len(kept) != len(env) {
+			container["env"] = kept
+		}
+	}
+
+	switch path {
+	case core.LabelsHashPath:
+		nestedMap(template, "metadata", "labels")[core.ConfigHashAnnotation] = hash
+	case core.AnnotationsHashPath:
+		nestedMap(template, "metadata", "annotations")[core.ConfigHashAnnotation] = hash
+	}
+}
+
+// nestedMap returns the map at the path of the fields, creating any missing
+// maps along the way
+func nestedMap(fields map[string]interface{}, path ...string) map[string]interface{} {
+	for _, field := range path {
+		next, ok := fields[field].(map[string]interface{})
+		if !ok {
+			next = map[string]interface{}{}
+			fields[field] = next
+		}
+		fields = next
+	}
+	return fields
+}
+
+// describe names the manifest's object for output
+func (m *manifest) describe() string {
+	return fmt.Sprintf("%s/%s", core.WorkloadKind(m.object), m.object.GetName())
+}
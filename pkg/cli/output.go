@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+)
+
+const (
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+// outputAnnotation lists the output formats a command supports when they
+// differ from defaultOutputFormats
+const outputAnnotation = "output-formats"
+
+// defaultOutputFormats are the output formats supported by every command
+var defaultOutputFormats = []string{"table", outputJSON, outputYAML}
+
+// list is the envelope of machine-readable output. Fields are only ever
+// added to the items so that scripts can rely on the schema.
+type list struct {
+	Kind  string      `json:"kind"`
+	Items interface{} `json:"items"`
+}
+
+// workloadReference identifies a workload in machine-readable output
+type workloadReference struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+// reference returns the workloadReference of the workload
+func (w workload) reference() workloadReference {
+	return workloadReference{Namespace: w.GetNamespace(), Kind: w.kind, Name: w.GetName()}
+}
+
+// validateOutput checks the output format is one of the given formats
+func (o *Options) validateOutput(formats []string) error {
+	if o.output == "" {
+		return nil
+	}
+	for _, format := range formats {
+		if o.output == format {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported output format %q: must be one of %s", o.output, strings.Join(formats, ", "))
+}
+
+// structured returns whether machine-readable output was requested
+func (o *Options) structured() bool {
+	return o.output == outputJSON || o.output == outputYAML
+}
+
+// printList prints the items as a list of the given kind in the requested
+// machine-readable format
+func (o *Options) printList(kind string, items interface{}) error {
+	return o.printObject(list{Kind: kind, Items: items})
+}
+
+// printObject prints the object in the requested machine-readable format
+func (o *Options) printObject(obj interface{}) error {
+	data, err := json.MarshalIndent(obj, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	if o.output == outputYAML {
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			return fmt.Errorf("unable to convert to YAML: %v", err)
+		}
+		_, err = o.out.Write(data)
+		return err
+	}
+	_, err = fmt.Fprintf(o.out, "%s\n", data)
+	return err
+}
@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// newPauseCommand constructs the pause command
+func newPauseCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "pause kind/name...",
+		Short: "Stop Wave restarting workloads when their configuration changes",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.annotateAll(context.Background(), args, core.PausedAnnotation, "true", "paused")
+		},
+	}
+}
+
+// newResumeCommand constructs the resume command
+func newResumeCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume kind/name...",
+		Short: "Resume restarting paused workloads, applying any pending configuration change",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.annotateAll(context.Background(), args, core.PausedAnnotation, "", "resumed")
+		},
+	}
+}
+
+// newTriggerCommand constructs the trigger command
+func newTriggerCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "trigger kind/name...",
+		Short: "Restart workloads now, even if their configuration is unchanged",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			value := o.now().UTC().Format(time.RFC3339Nano)
+			return o.annotateAll(context.Background(), args, core.TriggerAnnotation, value, "triggered")
+		},
+	}
+}
+
+// workloadResult is the machine-readable result of changing a workload
+type workloadResult struct {
+	workloadReference
+	Result string `json:"result"`
+}
+
+// annotateAll sets an annotation on each of the workloads managed by Wave
+func (o *Options) annotateAll(ctx context.Context, args []string, key, value, verb string) error {
+	results := []workloadResult{}
+	for _, arg := range args {
+		w, err := o.getWorkload(ctx, arg)
+		if err != nil {
+			return err
+		}
+		if !w.enabled() {
+			return fmt.Errorf("%s is not managed by Wave: add the %s annotation to enable it", w, core.RequiredAnnotation)
+		}
+		if err := o.setAnnotation(ctx, w, key, value); err != nil {
+			return err
+		}
+		if o.structured() {
+			results = append(results, workloadResult{workloadReference: w.reference(), Result: verb})
+			continue
+		}
+		fmt.Fprintf(o.out, "%s %s\n", w, verb)
+	}
+	if o.structured() {
+		return o.printList("WorkloadResultList", results)
+	}
+	return nil
+}
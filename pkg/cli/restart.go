@@ -0,0 +1,99 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// newRestartConsumersCommand constructs the restart-consumers command
+func newRestartConsumersCommand(o *Options) *cobra.Command {
+	var force bool
+	cmd := &cobra.Command{
+		Use:   "restart-consumers (configmap|secret)/name",
+		Short: "Restart every workload that uses a ConfigMap or Secret",
+		Long: `Trigger a restart of every workload Wave manages in the namespace that uses the
+named ConfigMap or Secret, for example after rotating credentials.
+
+Paused workloads are skipped. With --force they are resumed and restarted too.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.restartConsumers(context.Background(), args[0], force)
+		},
+	}
+	cmd.Flags().BoolVar(&force, "force", false, "Resume and restart paused workloads")
+	return cmd
+}
+
+// restartConsumers triggers every managed workload consuming the source
+func (o *Options) restartConsumers(ctx context.Context, arg string, force bool) error {
+	source, err := parseSource(arg)
+	if err != nil {
+		return err
+	}
+	source.SetNamespace(o.namespace)
+
+	workloads, err := o.listWorkloads(ctx, o.namespace)
+	if err != nil {
+		return err
+	}
+
+	value := o.now().UTC().Format(time.RFC3339Nano)
+	results := []workloadResult{}
+	for _, w := range workloads {
+		if !w.enabled() || !consumes(w, source) {
+			continue
+		}
+		if w.annotation(core.PausedAnnotation) == "true" {
+			if !force {
+				results = append(results, workloadResult{workloadReference: w.reference(), Result: "skipped-paused"})
+				continue
+			}
+			// Resume in the same update as the trigger so that the workload
+			// only restarts once
+			annotations := w.GetAnnotations()
+			delete(annotations, core.PausedAnnotation)
+			w.SetAnnotations(annotations)
+		}
+		if err := o.setAnnotation(ctx, w, core.TriggerAnnotation, value); err != nil {
+			return err
+		}
+		results = append(results, workloadResult{workloadReference: w.reference(), Result: "triggered"})
+	}
+
+	if o.structured() {
+		return o.printList("WorkloadResultList", results)
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(o.out, "No workloads managed by Wave use %s\n", arg)
+		return nil
+	}
+	for _, r := range results {
+		switch r.Result {
+		case "skipped-paused":
+			fmt.Fprintf(o.out, "%s/%s skipped, paused (use --force to restart it)\n", r.Kind, r.Name)
+		default:
+			fmt.Fprintf(o.out, "%s/%s triggered\n", r.Kind, r.Name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave CLI restart-consumers Suite", func() {
+	var o *Options
+	var out *bytes.Buffer
+	var ctx = context.TODO()
+
+	consumer := func(name string, annotations map[string]string) *appsv1.Deployment {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: annotations},
+		}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "credentials",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{SecretName: "credentials"},
+			},
+		}}
+		return d
+	}
+
+	annotationsOf := func(name string) map[string]string {
+		d := &appsv1.Deployment{}
+		Expect(o.client.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d.GetAnnotations()
+	}
+
+	BeforeEach(func() {
+		out = &bytes.Buffer{}
+		o = &Options{
+			namespace: "default",
+			client: fake.NewFakeClient(
+				consumer("managed", map[string]string{core.RequiredAnnotation: "true"}),
+				consumer("paused", map[string]string{core.RequiredAnnotation: "true", core.PausedAnnotation: "true"}),
+				consumer("unmanaged", nil),
+			),
+			out: out,
+			now: func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) },
+		}
+	})
+
+	It("triggers managed consumers and skips paused ones", func() {
+		Expect(o.restartConsumers(ctx, "secret/credentials", false)).To(Succeed())
+		Expect(annotationsOf("managed")).To(HaveKeyWithValue(core.TriggerAnnotation, "2019-01-02T03:04:05Z"))
+		Expect(annotationsOf("paused")).NotTo(HaveKey(core.TriggerAnnotation))
+		Expect(annotationsOf("unmanaged")).NotTo(HaveKey(core.TriggerAnnotation))
+		Expect(out.String()).To(ContainSubstring("Deployment/paused skipped, paused"))
+	})
+
+	It("resumes and triggers paused consumers with force", func() {
+		Expect(o.restartConsumers(ctx, "secret/credentials", true)).To(Succeed())
+		Expect(annotationsOf("paused")).To(HaveKeyWithValue(core.TriggerAnnotation, "2019-01-02T03:04:05Z"))
+		Expect(annotationsOf("paused")).NotTo(HaveKey(core.PausedAnnotation))
+	})
+
+	It("reports when nothing uses the source", func() {
+		Expect(o.restartConsumers(ctx, "configmap/credentials", false)).To(Succeed())
+		Expect(out.String()).To(Equal("No workloads managed by Wave use configmap/credentials\n"))
+	})
+})
@@ -0,0 +1,135 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// newHistoryCommand constructs the history command
+func newHistoryCommand(o *Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "history kind/name",
+		Short: "List the snapshotted revisions of the ConfigMaps and Secrets of a workload",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.history(context.Background(), args[0])
+		},
+	}
+}
+
+// newRollbackCommand constructs the rollback command
+func newRollbackCommand(o *Options) *cobra.Command {
+	var revision int
+	cmd := &cobra.Command{
+		Use:   "rollback kind/name",
+		Short: "Restore the ConfigMaps and Secrets of a workload from their snapshots",
+		Long: `Restore the data of the ConfigMaps and Secrets of a workload from the snapshots
+Wave took of them when it triggered its rollouts, and trigger its rollout.
+
+Revisions are counted for each ConfigMap and Secret, and are listed by the
+history command. By default each ConfigMap and Secret is restored to the
+revision before its latest. With --to-revision, ConfigMaps and Secrets
+without the revision are left as they are.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.rollback(context.Background(), args[0], revision)
+		},
+	}
+	cmd.Flags().IntVar(&revision, "to-revision", 0, "The revision to restore. Defaults to the revision before the latest")
+	return cmd
+}
+
+// revisionResult is the machine-readable description of a revision
+type revisionResult struct {
+	core.Revision
+	Namespace string `json:"namespace"`
+}
+
+// history prints the revisions of the sources of the workload
+func (o *Options) history(ctx context.Context, arg string) error {
+	w, err := o.getWorkload(ctx, arg)
+	if err != nil {
+		return err
+	}
+	references, err := core.References(w.Object)
+	if err != nil {
+		return err
+	}
+
+	results := []revisionResult{}
+	for _, ref := range references {
+		revisions, err := core.ListRevisions(ctx, o.client, w.GetNamespace(), ref.Kind, ref.Name)
+		if err != nil {
+			return err
+		}
+		for _, revision := range revisions {
+			results = append(results, revisionResult{Revision: revision, Namespace: w.GetNamespace()})
+		}
+	}
+
+	if o.structured() {
+		return o.printList("RevisionList", results)
+	}
+	if len(results) == 0 {
+		fmt.Fprintf(o.out, "No ConfigMap or Secret of %s has been snapshotted\n", w)
+		return nil
+	}
+	tw := tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "SOURCE\tREVISION\tSNAPSHOT\tWORKLOAD\tCREATED")
+	for _, r := range results {
+		created := "-"
+		if !r.Created.IsZero() {
+			created = r.Created.UTC().Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s/%s\t%d\t%s\t%s\t%s\n", r.Kind, r.Name, r.Number, r.Snapshot, r.Workload, created)
+	}
+	return tw.Flush()
+}
+
+// rollback restores the sources of the workload and triggers its rollout
+func (o *Options) rollback(ctx context.Context, arg string, revision int) error {
+	if revision < 0 {
+		return fmt.Errorf("--to-revision must not be negative")
+	}
+	w, err := o.getWorkload(ctx, arg)
+	if err != nil {
+		return err
+	}
+	if !w.enabled() {
+		return fmt.Errorf("%s is not managed by Wave: add the %s annotation to enable it", w, core.RequiredAnnotation)
+	}
+
+	restored, err := core.Rollback(ctx, o.client, w.Object, revision, o.now().UTC().Format(time.RFC3339Nano))
+	if err != nil {
+		return err
+	}
+	if o.structured() {
+		return o.printList("RestoredSourceList", restored)
+	}
+	for _, r := range restored {
+		fmt.Fprintf(o.out, "%s/%s restored to revision %d\n", r.Kind, r.Name, r.Revision)
+	}
+	fmt.Fprintf(o.out, "%s triggered\n", w)
+	return nil
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave CLI rollback Suite", func() {
+	var o *Options
+	var out *bytes.Buffer
+	var ctx = context.TODO()
+
+	// snapshot returns the snapshot of the revision of the ConfigMap
+	snapshot := func(name string, revision int, value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      name + "-rev-" + strconv.Itoa(revision),
+				Labels:    map[string]string{core.SnapshotLabel: "true"},
+				Annotations: map[string]string{
+					core.SnapshotOfAnnotation:       name,
+					core.SnapshotRevisionAnnotation: strconv.Itoa(revision),
+				},
+			},
+			Data: map[string]string{"key": value},
+		}
+	}
+
+	dataOf := func(name string) map[string]string {
+		cm := &corev1.ConfigMap{}
+		Expect(o.client.Get(ctx, types.NamespacedName{Namespace: "default", Name: name}, cm)).To(Succeed())
+		return cm.Data
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{core.RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{
+			{Name: "a", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "a"}}}},
+			{Name: "b", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "b"}}}},
+		}
+		objs := []runtime.Object{
+			d,
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}, Data: map[string]string{"key": "a3"}},
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b"}, Data: map[string]string{"key": "b1"}},
+			snapshot("a", 1, "a1"),
+			snapshot("a", 2, "a2"),
+			snapshot("a", 3, "a3"),
+			snapshot("b", 1, "b1"),
+		}
+		out = &bytes.Buffer{}
+		o = &Options{
+			namespace: "default",
+			client:    fake.NewFakeClient(objs...),
+			out:       out,
+			now:       func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) },
+		}
+	})
+
+	It("lists the revisions of the sources of the workload", func() {
+		Expect(o.history(ctx, "deployment/example")).To(Succeed())
+		Expect(out.String()).To(MatchRegexp(`ConfigMap/a\s+3\s+a-rev-3`))
+		Expect(out.String()).To(MatchRegexp(`ConfigMap/b\s+1\s+b-rev-1`))
+	})
+
+	It("restores the revision before the latest by default", func() {
+		Expect(o.rollback(ctx, "deployment/example", 0)).To(Succeed())
+		Expect(dataOf("a")).To(Equal(map[string]string{"key": "a2"}))
+		Expect(dataOf("b")).To(Equal(map[string]string{"key": "b1"}))
+
+		d := &appsv1.Deployment{}
+		Expect(o.client.Get(ctx, types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		Expect(d.GetAnnotations()).To(HaveKeyWithValue(core.TriggerAnnotation, "2019-01-02T03:04:05Z"))
+		Expect(out.String()).To(Equal("ConfigMap/a restored to revision 2\nDeployment/example triggered\n"))
+	})
+
+	It("restores the given revision of the sources which have it", func() {
+		Expect(o.rollback(ctx, "deployment/example", 1)).To(Succeed())
+		Expect(dataOf("a")).To(Equal(map[string]string{"key": "a1"}))
+		Expect(dataOf("b")).To(Equal(map[string]string{"key": "b1"}))
+		Expect(out.String()).To(ContainSubstring("ConfigMap/b restored to revision 1"))
+	})
+
+	It("fails when no source has the revision", func() {
+		Expect(o.rollback(ctx, "deployment/example", 7)).To(MatchError(ContainSubstring("has revision 7")))
+		Expect(dataOf("a")).To(Equal(map[string]string{"key": "a3"}))
+	})
+})
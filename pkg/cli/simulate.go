@@ -0,0 +1,236 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// simulateOptions holds the flags of the simulate command
+type simulateOptions struct {
+	filename     string
+	fromLiterals []string
+	fromFiles    []string
+}
+
+// simulatedRestart is the machine-readable effect of the proposed change on a
+// workload
+type simulatedRestart struct {
+	workloadReference
+	Restart      bool   `json:"restart"`
+	Deferred     string `json:"deferred,omitempty"`
+	CurrentHash  string `json:"currentHash"`
+	ProposedHash string `json:"proposedHash"`
+}
+
+// newSimulateCommand constructs the simulate command
+func newSimulateCommand(o *Options) *cobra.Command {
+	s := &simulateOptions{}
+	cmd := &cobra.Command{
+		Use:   "simulate (configmap|secret)/name",
+		Short: "Show which workloads a ConfigMap or Secret change would restart",
+		Long: `Show the workloads Wave would restart if a proposed change to a ConfigMap or
+Secret were applied, whether the restart would be deferred, and the resulting
+configuration hashes.
+
+The proposed object is read from a manifest with -f, or built by setting keys
+of the existing object with --from-literal and --from-file.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.simulate(context.Background(), args[0], s)
+		},
+	}
+	cmd.Flags().StringVarP(&s.filename, "filename", "f", "", "Manifest containing the proposed ConfigMap or Secret")
+	cmd.Flags().StringSliceVar(&s.fromLiterals, "from-literal", nil, "Key and value to set, as key=value")
+	cmd.Flags().StringSliceVar(&s.fromFiles, "from-file", nil, "File to set as a key, as [key=]path")
+	return cmd
+}
+
+// simulate prints the effect of the proposed change on each workload
+func (o *Options) simulate(ctx context.Context, arg string, s *simulateOptions) error {
+	proposed, err := o.proposedObject(ctx, arg, s)
+	if err != nil {
+		return err
+	}
+	overlay := &overlayClient{Client: o.client, object: proposed}
+
+	workloads, err := o.listWorkloads(ctx, o.namespace)
+	if err != nil {
+		return err
+	}
+
+	results := []simulatedRestart{}
+	for _, w := range workloads {
+		if !w.enabled() || !consumes(w, proposed) {
+			continue
+		}
+		current, err := core.CalculateConfigHash(ctx, o.client, w.Object)
+		if err != nil {
+			return fmt.Errorf("error calculating current hash of %s: %v", w, err)
+		}
+		next, err := core.CalculateConfigHash(ctx, overlay, w.Object)
+		if err != nil {
+			return fmt.Errorf("error calculating proposed hash of %s: %v", w, err)
+		}
+		result := simulatedRestart{
+			workloadReference: w.reference(),
+			Restart:           current != next,
+			CurrentHash:       current,
+			ProposedHash:      next,
+		}
+		if w.annotation(core.PausedAnnotation) == "true" {
+			result.Deferred = "paused"
+		}
+		results = append(results, result)
+	}
+	if o.structured() {
+		return o.printList("SimulatedRestartList", results)
+	}
+
+	tw := tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKLOAD\tRESTART\tDEFERRED\tCURRENT\tPROPOSED")
+	for _, r := range results {
+		deferred := r.Deferred
+		if deferred == "" {
+			deferred = "no"
+		}
+		fmt.Fprintf(tw, "%s/%s\t%t\t%s\t%s\t%s\n", r.Kind, r.Name, r.Restart, deferred, shortHash(r.CurrentHash), shortHash(r.ProposedHash))
+	}
+	return tw.Flush()
+}
+
+// proposedObject builds the proposed ConfigMap or Secret
+func (o *Options) proposedObject(ctx context.Context, arg string, s *simulateOptions) (core.Object, error) {
+	if s.filename != "" {
+		return o.proposedFromManifest(arg, s.filename)
+	}
+
+	obj, err := parseSource(arg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Start from the existing object, if there is one
+	err = o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: obj.GetName()}, obj)
+	if err != nil && !errors.IsNotFound(err) {
+		return nil, fmt.Errorf("error fetching %s: %v", arg, err)
+	}
+	obj.SetNamespace(o.namespace)
+
+	values, err := proposedValues(s)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range values {
+		switch o := obj.(type) {
+		case *corev1.ConfigMap:
+			if o.Data == nil {
+				o.Data = make(map[string]string)
+			}
+			o.Data[key] = value
+		case *corev1.Secret:
+			if o.Data == nil {
+				o.Data = make(map[string][]byte)
+			}
+			o.Data[key] = []byte(value)
+		}
+	}
+	return obj, nil
+}
+
+// proposedFromManifest reads the proposed object from a manifest
+func (o *Options) proposedFromManifest(arg, filename string) (core.Object, error) {
+	manifests, err := readManifests([]string{filename}, o.namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range manifests {
+		switch m.object.(type) {
+		case *corev1.ConfigMap, *corev1.Secret:
+			return m.object, nil
+		}
+	}
+	return nil, fmt.Errorf("no ConfigMap or Secret found in %s", filename)
+}
+
+// proposedValues parses the --from-literal and --from-file flags
+func proposedValues(s *simulateOptions) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, literal := range s.fromLiterals {
+		// Literals hold configuration values, which may be secret, so they
+		// are identified by position rather than quoted in errors
+		parts := strings.SplitN(literal, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid --from-literal #%d: must be key=value", i+1)
+		}
+		values[parts[0]] = parts[1]
+	}
+	for _, file := range s.fromFiles {
+		key, path := filepath.Base(file), file
+		if parts := strings.SplitN(file, "=", 2); len(parts) == 2 {
+			key, path = parts[0], parts[1]
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %v", path, err)
+		}
+		values[key] = string(data)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("one of --filename, --from-literal or --from-file is required")
+	}
+	return values, nil
+}
+
+// overlayClient reads the proposed object in place of the object of the same
+// kind and name in the cluster
+type overlayClient struct {
+	client.Client
+	object core.Object
+}
+
+// Get returns the proposed object if it matches the key
+func (c *overlayClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if key.Namespace == c.object.GetNamespace() && key.Name == c.object.GetName() {
+		switch proposed := c.object.(type) {
+		case *corev1.ConfigMap:
+			if cm, ok := obj.(*corev1.ConfigMap); ok {
+				proposed.DeepCopyInto(cm)
+				return nil
+			}
+		case *corev1.Secret:
+			if s, ok := obj.(*corev1.Secret); ok {
+				proposed.DeepCopyInto(s)
+				return nil
+			}
+		}
+	}
+	return c.Client.Get(ctx, key, obj)
+}
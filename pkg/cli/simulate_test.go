@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"bytes"
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave CLI simulate Suite", func() {
+	var o *Options
+	var out *bytes.Buffer
+	var ctx = context.TODO()
+
+	consumer := func(name string, annotations map[string]string) *appsv1.Deployment {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: annotations},
+		}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: name,
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "example"},
+				},
+			}},
+		}}
+		return d
+	}
+
+	lineOf := func(workload string) string {
+		for _, line := range strings.Split(out.String(), "\n") {
+			if strings.HasPrefix(line, workload+" ") {
+				return line
+			}
+		}
+		return ""
+	}
+
+	BeforeEach(func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data:       map[string]string{"key": "value"},
+		}
+		out = &bytes.Buffer{}
+		o = &Options{
+			namespace: "default",
+			client: fake.NewFakeClient(cm,
+				consumer("managed", map[string]string{core.RequiredAnnotation: "true"}),
+				consumer("paused", map[string]string{core.RequiredAnnotation: "true", core.PausedAnnotation: "true"}),
+				consumer("unmanaged", nil),
+			),
+			out: out,
+		}
+	})
+
+	It("lists the workloads a changed key would restart", func() {
+		Expect(o.simulate(ctx, "cm/example", &simulateOptions{fromLiterals: []string{"key=changed"}})).To(Succeed())
+		Expect(lineOf("Deployment/managed")).To(MatchRegexp(`true\s+no`))
+		Expect(lineOf("Deployment/paused")).To(MatchRegexp(`true\s+paused`))
+		Expect(out.String()).NotTo(ContainSubstring("Deployment/unmanaged"))
+	})
+
+	It("reports no restart when the data is unchanged", func() {
+		Expect(o.simulate(ctx, "configmap/example", &simulateOptions{fromLiterals: []string{"key=value"}})).To(Succeed())
+		Expect(lineOf("Deployment/managed")).To(MatchRegexp(`false\s+no`))
+	})
+
+	It("requires a proposed change", func() {
+		Expect(o.simulate(ctx, "configmap/example", &simulateOptions{})).NotTo(Succeed())
+	})
+
+	It("rejects kinds other than ConfigMaps and Secrets", func() {
+		Expect(o.simulate(ctx, "deployment/example", &simulateOptions{fromLiterals: []string{"key=value"}})).NotTo(Succeed())
+	})
+})
@@ -0,0 +1,107 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/wave-k8s/wave/pkg/core"
+)
+
+// workloadStatus is the machine-readable status of a workload
+type workloadStatus struct {
+	workloadReference
+	Enabled     bool   `json:"enabled"`
+	Paused      bool   `json:"paused"`
+	LastTrigger string `json:"lastTrigger,omitempty"`
+	Hash        string `json:"hash,omitempty"`
+}
+
+// newStatusCommand constructs the status command
+func newStatusCommand(o *Options) *cobra.Command {
+	var allNamespaces bool
+	cmd := &cobra.Command{
+		Use:   "status [kind/name...]",
+		Short: "Show the Wave status of workloads",
+		Long: `Show the Wave status of the named workloads or, if none are named, of every
+workload Wave manages in the namespace.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.status(context.Background(), args, allNamespaces)
+		},
+	}
+	cmd.Flags().BoolVarP(&allNamespaces, "all-namespaces", "A", false, "List workloads in all namespaces")
+	return cmd
+}
+
+// status prints a table describing the Wave status of workloads
+func (o *Options) status(ctx context.Context, args []string, allNamespaces bool) error {
+	namespace := o.namespace
+	if allNamespaces {
+		namespace = ""
+	}
+	workloads, err := o.selectWorkloads(ctx, args, namespace)
+	if err != nil {
+		return err
+	}
+
+	if o.structured() {
+		items := []workloadStatus{}
+		for _, w := range workloads {
+			items = append(items, workloadStatus{
+				workloadReference: w.reference(),
+				Enabled:           w.enabled(),
+				Paused:            w.annotation(core.PausedAnnotation) == "true",
+				LastTrigger:       w.annotation(core.TriggerAnnotation),
+				Hash:              w.configHash(),
+			})
+		}
+		return o.printList("WorkloadStatusList", items)
+	}
+
+	tw := tabwriter.NewWriter(o.out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tENABLED\tPAUSED\tLAST TRIGGER\tHASH")
+	for _, w := range workloads {
+		fmt.Fprintf(tw, "%s\t%s\t%t\t%t\t%s\t%s\n",
+			w.GetNamespace(),
+			w,
+			w.enabled(),
+			w.annotation(core.PausedAnnotation) == "true",
+			orNone(w.annotation(core.TriggerAnnotation)),
+			orNone(shortHash(w.configHash())),
+		)
+	}
+	return tw.Flush()
+}
+
+// shortHash abbreviates a configuration hash for display
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// orNone substitutes a placeholder for empty values
+func orNone(value string) string {
+	if value == "" {
+		return "<none>"
+	}
+	return value
+}
@@ -0,0 +1,225 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// kindAliases maps the names accepted on the command line to workload kinds
+var kindAliases = map[string]string{
+	"deployment":   "Deployment",
+	"deployments":  "Deployment",
+	"deploy":       "Deployment",
+	"statefulset":  "StatefulSet",
+	"statefulsets": "StatefulSet",
+	"sts":          "StatefulSet",
+	"daemonset":    "DaemonSet",
+	"daemonsets":   "DaemonSet",
+	"ds":           "DaemonSet",
+}
+
+// workload is a Deployment, StatefulSet or DaemonSet
+type workload struct {
+	core.Object
+	kind string
+}
+
+// podTemplate returns the workload's PodTemplate
+func (w workload) podTemplate() *corev1.PodTemplateSpec {
+	switch obj := w.Object.(type) {
+	case *appsv1.Deployment:
+		return &obj.Spec.Template
+	case *appsv1.StatefulSet:
+		return &obj.Spec.Template
+	case *appsv1.DaemonSet:
+		return &obj.Spec.Template
+	default:
+		return &corev1.PodTemplateSpec{}
+	}
+}
+
+// configHash returns the configuration hash Wave last rolled out to the
+// workload
+func (w workload) configHash() string {
+	return core.AppliedHash(w, w.podTemplate())
+}
+
+// annotation returns the value of the given annotation on the workload
+func (w workload) annotation(key string) string {
+	return w.GetAnnotations()[key]
+}
+
+// enabled returns whether Wave manages the workload
+func (w workload) enabled() bool {
+	return core.Managed(w)
+}
+
+// String returns the workload as kind/name
+func (w workload) String() string {
+	return fmt.Sprintf("%s/%s", w.kind, w.GetName())
+}
+
+// consumes returns whether the workload references the ConfigMap or Secret
+func consumes(w workload, obj core.Object) bool {
+	refs, err := core.References(w.Object)
+	if err != nil {
+		return false
+	}
+	kind := "ConfigMap"
+	if _, ok := obj.(*corev1.Secret); ok {
+		kind = "Secret"
+	}
+	for _, ref := range refs {
+		if ref.Kind == kind && ref.Name == obj.GetName() {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSource parses a ConfigMap or Secret argument of the form kind/name and
+// returns an empty object with the name set
+func parseSource(arg string) (core.Object, error) {
+	parts := strings.SplitN(arg, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return nil, fmt.Errorf("%q must be of the form configmap/name or secret/name", arg)
+	}
+	var obj core.Object
+	switch strings.ToLower(parts[0]) {
+	case "configmap", "configmaps", "cm":
+		obj = &corev1.ConfigMap{}
+	case "secret", "secrets":
+		obj = &corev1.Secret{}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q: must be a ConfigMap or Secret", parts[0])
+	}
+	obj.SetName(parts[1])
+	return obj, nil
+}
+
+// parseWorkload parses a workload argument of the form kind/name
+func parseWorkload(arg string) (string, string, error) {
+	parts := strings.SplitN(arg, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return "", "", fmt.Errorf("workload %q must be of the form kind/name", arg)
+	}
+	kind, ok := kindAliases[strings.ToLower(parts[0])]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported kind %q: must be a Deployment, StatefulSet or DaemonSet", parts[0])
+	}
+	return kind, parts[1], nil
+}
+
+// isWorkloadKind returns whether the kind is a workload Wave manages
+func isWorkloadKind(kind string) bool {
+	return kind == "Deployment" || kind == "StatefulSet" || kind == "DaemonSet"
+}
+
+// newWorkloadObject returns an empty object of the given workload kind
+func newWorkloadObject(kind string) core.Object {
+	switch kind {
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	default:
+		return &appsv1.Deployment{}
+	}
+}
+
+// getWorkload fetches the workload named by the argument
+func (o *Options) getWorkload(ctx context.Context, arg string) (workload, error) {
+	kind, name, err := parseWorkload(arg)
+	if err != nil {
+		return workload{}, err
+	}
+	obj := newWorkloadObject(kind)
+	err = o.client.Get(ctx, types.NamespacedName{Namespace: o.namespace, Name: name}, obj)
+	if err != nil {
+		return workload{}, fmt.Errorf("error fetching %s/%s: %v", kind, name, err)
+	}
+	return workload{Object: obj, kind: kind}, nil
+}
+
+// listWorkloads lists the workloads in the namespace, or in all namespaces if
+// namespace is empty, that match the options
+func (o *Options) listWorkloads(ctx context.Context, namespace string, opts ...client.ListOption) ([]workload, error) {
+	objs, err := core.ListWorkloads(ctx, o.client, namespace, opts...)
+	if err != nil {
+		return nil, err
+	}
+	workloads := []workload{}
+	for _, obj := range objs {
+		workloads = append(workloads, workload{Object: obj, kind: core.WorkloadKind(obj)})
+	}
+	return workloads, nil
+}
+
+// selectWorkloads fetches the workloads named by the arguments or, if there
+// are none, lists the workloads Wave manages in the namespace
+func (o *Options) selectWorkloads(ctx context.Context, args []string, namespace string) ([]workload, error) {
+	workloads := []workload{}
+	if len(args) > 0 {
+		for _, arg := range args {
+			w, err := o.getWorkload(ctx, arg)
+			if err != nil {
+				return nil, err
+			}
+			workloads = append(workloads, w)
+		}
+		return workloads, nil
+	}
+
+	all, err := o.listWorkloads(ctx, namespace)
+	if err != nil {
+		return nil, err
+	}
+	for _, w := range all {
+		if w.enabled() {
+			workloads = append(workloads, w)
+		}
+	}
+	return workloads, nil
+}
+
+// setAnnotation sets, or removes if value is empty, an annotation on the
+// workload and updates it
+func (o *Options) setAnnotation(ctx context.Context, w workload, key, value string) error {
+	annotations := w.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if value == "" {
+		delete(annotations, key)
+	} else {
+		annotations[key] = value
+	}
+	w.SetAnnotations(annotations)
+	if err := o.client.Update(ctx, w.Object); err != nil {
+		return fmt.Errorf("error updating %s: %v", w, err)
+	}
+	return nil
+}
@@ -0,0 +1,266 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package concurrency tunes the number of workers of Wave's controllers to the
+depth of their queues and the latency of the API server
+*/
+package concurrency
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// defaultInterval is how often a Limiter adjusts its workers by default
+	defaultInterval = 10 * time.Second
+
+	// sustainedIntervals is for how many intervals in a row the queue must be
+	// backlogged, or empty, before a Limiter adds, or removes, a worker
+	sustainedIntervals = 3
+)
+
+// workers is the number of workers each controller may run at once
+var workers = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "wave_reconcile_workers",
+	Help: "Number of reconciles each controller may run at once",
+}, []string{"controller"})
+
+func init() {
+	metrics.Registry.MustRegister(workers)
+}
+
+// Options configures how many reconciles a controller runs at once
+type Options struct {
+	// Min and Max bound the number of workers. The number is tuned between
+	// them if Min is set and lower than Max, otherwise it is fixed at Max. A
+	// Max below 1 means 1.
+	Min int
+	Max int
+
+	// LatencyTarget is the average API latency above which workers are
+	// removed rather than added. Zero ignores the latency.
+	LatencyTarget time.Duration
+
+	// Interval is how often the number of workers is adjusted, by default
+	// every 10 seconds
+	Interval time.Duration
+
+	// Monitor measures the latency of the API server
+	Monitor *Monitor
+}
+
+// Tuned returns true if the number of workers is tuned
+func (o Options) Tuned() bool {
+	return o.Min > 0 && o.Max > o.Min
+}
+
+// Limiter limits the reconciles a controller runs at once. The controller
+// must run Options.Max workers, of which the Limiter lets a tuned number
+// reconcile: a worker is added once the queue has held more requests than
+// there are workers for a few intervals in a row, and removed once the queue
+// stayed empty as long or whenever the API latency exceeds its target.
+type Limiter struct {
+	name    string
+	options Options
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	limit    int
+	inFlight int
+	queue    workqueue.Interface
+	backlog  int
+	idle     int
+}
+
+// NewLimiter constructs a Limiter for the named controller, starting at the
+// minimum number of workers
+func NewLimiter(name string, o Options) *Limiter {
+	if o.Max < 1 {
+		o.Max = 1
+	}
+	if o.Min > o.Max {
+		o.Min = o.Max
+	}
+	if o.Interval <= 0 {
+		o.Interval = defaultInterval
+	}
+	l := &Limiter{name: name, options: o, limit: o.Max}
+	if o.Tuned() {
+		l.limit = o.Min
+	}
+	l.cond = sync.NewCond(&l.mutex)
+	workers.WithLabelValues(name).Set(float64(l.limit))
+	return l
+}
+
+// MaxConcurrentReconciles returns the number of workers the controller must
+// run
+func (l *Limiter) MaxConcurrentReconciles() int {
+	return l.options.Max
+}
+
+// Limit returns how many reconciles the controller may run at once
+func (l *Limiter) Limit() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.limit
+}
+
+// Reconciler wraps the Reconciler so that it only runs while the Limiter
+// allows it
+func (l *Limiter) Reconciler(r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(request reconcile.Request) (reconcile.Result, error) {
+		l.acquire()
+		defer l.release()
+		return r.Reconcile(request)
+	})
+}
+
+// acquire waits until fewer reconciles than the limit run
+func (l *Limiter) acquire() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for l.inFlight >= l.limit {
+		l.cond.Wait()
+	}
+	l.inFlight++
+}
+
+// release ends a reconcile
+func (l *Limiter) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.inFlight--
+	l.cond.Signal()
+}
+
+// Handler wraps the EventHandler so that the Limiter observes the depth of
+// the controller's workqueue it adds requests to
+func (l *Limiter) Handler(h handler.EventHandler) handler.EventHandler {
+	return &observed{EventHandler: h, limiter: l}
+}
+
+// observed passes the workqueue of the controller to its Limiter
+type observed struct {
+	handler.EventHandler
+	limiter *Limiter
+}
+
+// Create implements handler.EventHandler
+func (o *observed) Create(evt event.CreateEvent, wq workqueue.RateLimitingInterface) {
+	o.limiter.observe(wq)
+	o.EventHandler.Create(evt, wq)
+}
+
+// Update implements handler.EventHandler
+func (o *observed) Update(evt event.UpdateEvent, wq workqueue.RateLimitingInterface) {
+	o.limiter.observe(wq)
+	o.EventHandler.Update(evt, wq)
+}
+
+// Delete implements handler.EventHandler
+func (o *observed) Delete(evt event.DeleteEvent, wq workqueue.RateLimitingInterface) {
+	o.limiter.observe(wq)
+	o.EventHandler.Delete(evt, wq)
+}
+
+// Generic implements handler.EventHandler
+func (o *observed) Generic(evt event.GenericEvent, wq workqueue.RateLimitingInterface) {
+	o.limiter.observe(wq)
+	o.EventHandler.Generic(evt, wq)
+}
+
+// observe binds the Limiter to the controller's workqueue
+func (l *Limiter) observe(wq workqueue.Interface) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.queue == nil {
+		l.queue = wq
+	}
+}
+
+// Start adjusts the number of workers every interval until stop is closed,
+// if it is tuned. It implements manager.Runnable.
+func (l *Limiter) Start(stop <-chan struct{}) error {
+	if !l.options.Tuned() {
+		<-stop
+		return nil
+	}
+	ticker := time.NewTicker(l.options.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+			l.adjust()
+		}
+	}
+}
+
+// adjust adds or removes a worker according to the depth of the queue and
+// the latency of the API server
+func (l *Limiter) adjust() {
+	latency := l.options.Monitor.Latency()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	depth := 0
+	if l.queue != nil {
+		depth = l.queue.Len()
+	}
+
+	previous := l.limit
+	switch {
+	case l.options.LatencyTarget > 0 && latency > l.options.LatencyTarget:
+		l.backlog, l.idle = 0, 0
+		if l.limit > l.options.Min {
+			l.limit--
+		}
+	case depth > l.limit:
+		l.backlog, l.idle = l.backlog+1, 0
+		if l.backlog >= sustainedIntervals && l.limit < l.options.Max {
+			l.backlog = 0
+			l.limit++
+		}
+	case depth == 0:
+		l.backlog, l.idle = 0, l.idle+1
+		if l.idle >= sustainedIntervals && l.limit > l.options.Min {
+			l.idle = 0
+			l.limit--
+		}
+	default:
+		l.backlog, l.idle = 0, 0
+	}
+	if l.limit == previous {
+		return
+	}
+
+	log := logf.Log.WithName("concurrency")
+	log.V(1).Info("Adjusted reconcile workers", "controller", l.name, "workers", l.limit, "queueDepth", depth, "apiLatency", latency.String())
+	workers.WithLabelValues(l.name).Set(float64(l.limit))
+	l.cond.Broadcast()
+}
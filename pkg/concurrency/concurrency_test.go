@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package concurrency
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Limiter", func() {
+	var l *Limiter
+	var wq workqueue.RateLimitingInterface
+
+	enqueue := func(names ...string) {
+		h := l.Handler(&handler.EnqueueRequestForObject{})
+		for _, name := range names {
+			d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+			h.Create(event.CreateEvent{Meta: d, Object: d}, wq)
+		}
+	}
+
+	adjust := func(times int) {
+		for i := 0; i < times; i++ {
+			l.adjust()
+		}
+	}
+
+	BeforeEach(func() {
+		wq = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		l = NewLimiter("test-controller", Options{Min: 1, Max: 3})
+	})
+
+	AfterEach(func() {
+		wq.ShutDown()
+	})
+
+	Context("when not tuned", func() {
+		It("runs a single worker by default", func() {
+			l = NewLimiter("test-controller", Options{})
+			Expect(l.MaxConcurrentReconciles()).To(Equal(1))
+			Expect(l.Limit()).To(Equal(1))
+		})
+
+		It("lets every worker reconcile", func() {
+			l = NewLimiter("test-controller", Options{Max: 4})
+			Expect(l.MaxConcurrentReconciles()).To(Equal(4))
+			Expect(l.Limit()).To(Equal(4))
+		})
+	})
+
+	It("starts at the minimum number of workers", func() {
+		Expect(l.MaxConcurrentReconciles()).To(Equal(3))
+		Expect(l.Limit()).To(Equal(1))
+	})
+
+	It("adds a worker once the queue stays backlogged", func() {
+		enqueue("a", "b", "c")
+		adjust(2)
+		Expect(l.Limit()).To(Equal(1))
+		adjust(1)
+		Expect(l.Limit()).To(Equal(2))
+	})
+
+	It("never exceeds the maximum", func() {
+		enqueue("a", "b", "c", "d", "e")
+		adjust(20)
+		Expect(l.Limit()).To(Equal(3))
+	})
+
+	It("removes a worker once the queue stays empty", func() {
+		enqueue("a", "b", "c", "d")
+		adjust(6)
+		Expect(l.Limit()).To(Equal(3))
+
+		for wq.Len() > 0 {
+			item, _ := wq.Get()
+			wq.Done(item)
+		}
+		adjust(2)
+		Expect(l.Limit()).To(Equal(3))
+		adjust(1)
+		Expect(l.Limit()).To(Equal(2))
+		adjust(10)
+		Expect(l.Limit()).To(Equal(1))
+	})
+
+	It("removes a worker whenever the API latency exceeds the target", func() {
+		m := NewMonitor()
+		l = NewLimiter("test-controller", Options{Min: 1, Max: 3, LatencyTarget: 100 * time.Millisecond, Monitor: m})
+		enqueue("a", "b", "c", "d")
+		adjust(6)
+		Expect(l.Limit()).To(Equal(3))
+
+		m.observe(time.Second)
+		adjust(1)
+		Expect(l.Limit()).To(Equal(2))
+	})
+
+	It("only runs as many reconciles at once as the limit", func() {
+		var running, peak int32
+		release := make(chan struct{})
+		r := l.Reconciler(reconcile.Func(func(reconcile.Request) (reconcile.Result, error) {
+			n := atomic.AddInt32(&running, 1)
+			for {
+				p := atomic.LoadInt32(&peak)
+				if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+					break
+				}
+			}
+			<-release
+			atomic.AddInt32(&running, -1)
+			return reconcile.Result{}, nil
+		}))
+
+		for i := 0; i < 3; i++ {
+			go r.Reconcile(reconcile.Request{NamespacedName: types.NamespacedName{Name: "a"}})
+		}
+		Eventually(func() int32 { return atomic.LoadInt32(&running) }).Should(Equal(int32(1)))
+		Consistently(func() int32 { return atomic.LoadInt32(&running) }, 100*time.Millisecond).Should(Equal(int32(1)))
+		close(release)
+		Eventually(func() int32 { return atomic.LoadInt32(&running) }).Should(Equal(int32(0)))
+		Expect(atomic.LoadInt32(&peak)).To(Equal(int32(1)))
+	})
+})
+
+var _ = Describe("Monitor", func() {
+	It("averages the latency of requests", func() {
+		m := NewMonitor()
+		Expect(m.Latency()).To(BeZero())
+		m.observe(100 * time.Millisecond)
+		Expect(m.Latency()).To(Equal(100 * time.Millisecond))
+		m.observe(200 * time.Millisecond)
+		Expect(m.Latency()).To(Equal(110 * time.Millisecond))
+	})
+
+	It("doesn't measure watches", func() {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(20 * time.Millisecond)
+		}))
+		defer server.Close()
+
+		m := NewMonitor()
+		client := &http.Client{Transport: m.WrapTransport(http.DefaultTransport)}
+		resp, err := client.Get(server.URL + "/api/v1/pods?watch=true")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(m.Latency()).To(BeZero())
+
+		resp, err = client.Get(server.URL + "/api/v1/pods")
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(m.Latency()).To(BeNumerically(">=", 20*time.Millisecond))
+	})
+})
@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package concurrency
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// smoothing is the weight of each new request in the average latency
+const smoothing = 0.1
+
+// Monitor measures the latency of the API requests of the clients whose
+// transports it wraps, as an exponentially weighted moving average. Watches
+// are long-lived and are not measured.
+type Monitor struct {
+	mutex   sync.Mutex
+	latency time.Duration
+}
+
+// NewMonitor constructs a Monitor which hasn't measured any request yet
+func NewMonitor() *Monitor {
+	return &Monitor{}
+}
+
+// WrapTransport measures the latency of every request but watches. It is
+// meant to be used as rest.Config.WrapTransport.
+func (m *Monitor) WrapTransport(rt http.RoundTripper) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Query().Get("watch") == "true" {
+			return rt.RoundTrip(req)
+		}
+		start := time.Now()
+		resp, err := rt.RoundTrip(req)
+		m.observe(time.Since(start))
+		return resp, err
+	})
+}
+
+// observe adds the latency of a request to the average
+func (m *Monitor) observe(latency time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.latency == 0 {
+		m.latency = latency
+		return
+	}
+	m.latency = time.Duration(smoothing*float64(latency) + (1-smoothing)*float64(m.latency))
+}
+
+// Latency returns the average latency of the API requests, or zero if none
+// was measured yet
+func (m *Monitor) Latency() time.Duration {
+	if m == nil {
+		return 0
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.latency
+}
+
+// roundTripperFunc adapts a function to the http.RoundTripper interface
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
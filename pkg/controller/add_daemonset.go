@@ -21,6 +21,6 @@ import (
 )
 
 func init() {
-	// AddToManagerFuncs is a list of functions to create controllers and add them to a manager.
-	AddToManagerFuncs = append(AddToManagerFuncs, daemonset.Add)
+	// AddToManagerFuncs maps each kind of workload to the function creating its controller and adding it to a manager.
+	AddToManagerFuncs["DaemonSet"] = daemonset.Add
 }
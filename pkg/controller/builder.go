@@ -0,0 +1,74 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"github.com/wave-k8s/wave/pkg/core"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Builder adds Wave's controllers to a Manager, for operators embedding Wave
+// which filter the objects it reacts to or watch additional sources
+type Builder struct {
+	mgr   manager.Manager
+	opts  []core.Option
+	kinds []string
+}
+
+// NewBuilder returns a Builder adding Wave's controllers to the Manager
+func NewBuilder(mgr manager.Manager) *Builder {
+	return &Builder{mgr: mgr, kinds: Kinds}
+}
+
+// ForKinds only adds the controllers of the kinds of workloads, among
+// Deployment, StatefulSet and DaemonSet
+func (b *Builder) ForKinds(kinds ...string) *Builder {
+	b.kinds = kinds
+	return b
+}
+
+// WithOptions configures each controller's Handler with the Options
+func (b *Builder) WithOptions(opts ...core.Option) *Builder {
+	b.opts = append(b.opts, opts...)
+	return b
+}
+
+// WithEventFilter filters the events of every watch with the predicates
+func (b *Builder) WithEventFilter(p ...predicate.Predicate) *Builder {
+	return b.WithOptions(core.WithEventFilter(p...))
+}
+
+// WithWorkloadFilter restricts the controllers to the workloads accepted by
+// the predicates
+func (b *Builder) WithWorkloadFilter(p ...predicate.Predicate) *Builder {
+	return b.WithOptions(core.WithWorkloadFilter(p...))
+}
+
+// Watches adds a watch to every controller. handlerFor returns the
+// EventHandler for the controller of the kind, one of Deployment,
+// StatefulSet or DaemonSet.
+func (b *Builder) Watches(src source.Source, handlerFor func(kind string) handler.EventHandler, p ...predicate.Predicate) *Builder {
+	return b.WithOptions(core.WithWatch(core.Watch{Source: src, Handler: handlerFor, Predicates: p}))
+}
+
+// Complete adds the controllers to the Manager
+func (b *Builder) Complete() error {
+	return AddKindsToManager(b.mgr, b.kinds, b.opts...)
+}
@@ -17,16 +17,36 @@ limitations under the License.
 package controller
 
 import (
+	"fmt"
+
+	"github.com/wave-k8s/wave/pkg/core"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
 )
 
-// AddToManagerFuncs is a list of functions to add all Controllers to the Manager
-var AddToManagerFuncs []func(manager.Manager) error
+// Kinds are the kinds of workloads Wave has a Controller for
+var Kinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+
+// AddToManagerFuncs maps the kind of workload of each Controller to the
+// function adding the Controller to the Manager
+var AddToManagerFuncs = map[string]func(manager.Manager, ...core.Option) error{}
 
-// AddToManager adds all Controllers to the Manager
-func AddToManager(m manager.Manager) error {
-	for _, f := range AddToManagerFuncs {
-		if err := f(m); err != nil {
+// AddToManager adds all Controllers to the Manager, configuring each
+// Controller's Handler with the given Options
+func AddToManager(m manager.Manager, opts ...core.Option) error {
+	return AddKindsToManager(m, Kinds, opts...)
+}
+
+// AddKindsToManager adds the Controllers of the kinds of workloads to the
+// Manager, configuring each Controller's Handler with the given Options.
+// The Handlers only list workloads of those kinds.
+func AddKindsToManager(m manager.Manager, kinds []string, opts ...core.Option) error {
+	opts = append(opts, core.WithWorkloadKinds(kinds...))
+	for _, kind := range kinds {
+		f, ok := AddToManagerFuncs[kind]
+		if !ok {
+			return fmt.Errorf("unknown kind of workload %q", kind)
+		}
+		if err := f(m, opts...); err != nil {
 			return err
 		}
 	}
@@ -17,23 +17,15 @@ limitations under the License.
 package daemonset
 
 import (
-	"log"
 	"path/filepath"
-	"sync"
 	"testing"
 
-	"github.com/wave-k8s/wave/test/reporters"
-
-	"github.com/go-logr/glogr"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
-	"github.com/wave-k8s/wave/pkg/apis"
-	"k8s.io/client-go/kubernetes/scheme"
+	"github.com/wave-k8s/wave/test/reporters"
+	"github.com/wave-k8s/wave/test/utils"
 	"k8s.io/client-go/rest"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
-	"sigs.k8s.io/controller-runtime/pkg/manager"
-	"sigs.k8s.io/controller-runtime/pkg/reconcile"
-	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
 
 var cfg *rest.Config
@@ -46,44 +38,9 @@ func TestMain(t *testing.T) {
 var t *envtest.Environment
 
 var _ = BeforeSuite(func() {
-	t = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "..", "..", "config", "crds")},
-	}
-	apis.AddToScheme(scheme.Scheme)
-
-	logf.SetLogger(glogr.New())
-
-	var err error
-	if cfg, err = t.Start(); err != nil {
-		log.Fatal(err)
-	}
+	t, cfg = utils.StartEnvironment(filepath.Join("..", "..", ".."))
 })
 
 var _ = AfterSuite(func() {
-	t.Stop()
+	utils.StopEnvironment(t)
 })
-
-// SetupTestReconcile returns a reconcile.Reconcile implementation that delegates to inner and
-// writes the request to requests after Reconcile is finished.
-func SetupTestReconcile(inner reconcile.Reconciler) (reconcile.Reconciler, chan reconcile.Request) {
-	requests := make(chan reconcile.Request)
-	fn := reconcile.Func(func(req reconcile.Request) (reconcile.Result, error) {
-		result, err := inner.Reconcile(req)
-		requests <- req
-		return result, err
-	})
-	return fn, requests
-}
-
-// StartTestManager adds recFn
-func StartTestManager(mgr manager.Manager) (chan struct{}, *sync.WaitGroup) {
-	stop := make(chan struct{})
-	wg := &sync.WaitGroup{}
-	wg.Add(1)
-	go func() {
-		defer GinkgoRecover()
-		Expect(mgr.Start(stop)).NotTo(HaveOccurred())
-		wg.Done()
-	}()
-	return stop, wg
-}
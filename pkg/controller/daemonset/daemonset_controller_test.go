@@ -86,10 +86,10 @@ var _ = Describe("DaemonSet controller Suite", func() {
 		m = utils.Matcher{Client: c}
 
 		var recFn reconcile.Reconciler
-		recFn, requests = SetupTestReconcile(newReconciler(mgr))
-		Expect(add(mgr, recFn)).NotTo(HaveOccurred())
+		recFn, requests = utils.SetupTestReconcile(newReconciler(mgr))
+		Expect(add(mgr, recFn, core.Watches{})).NotTo(HaveOccurred())
 
-		stopMgr, mgrStopped = StartTestManager(mgr)
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
 
 		// Create some configmaps and secrets
 		cm1 = utils.ExampleConfigMap1.DeepCopy()
@@ -151,8 +151,7 @@ var _ = Describe("DaemonSet controller Suite", func() {
 			return nil
 		}, timeout).Should(Succeed())
 
-		close(stopMgr)
-		mgrStopped.Wait()
+		utils.StopTestManager(stopMgr, mgrStopped)
 
 		utils.DeleteAll(cfg, timeout,
 			&appsv1.DaemonSetList{},
@@ -199,13 +198,11 @@ var _ = Describe("DaemonSet controller Suite", func() {
 			It("Sends an event when updating the hash", func() {
 				m.Eventually(daemonset, timeout).Should(utils.WithPodTemplateAnnotations(HaveKey(core.ConfigHashAnnotation)))
 
-				events := &corev1.EventList{}
-				eventMessage := func(event *corev1.Event) string {
-					return event.Message
-				}
-
 				hashMessage := "Configuration hash updated to ebabf80ef45218b27078a41ca16b35a4f91cb5672f389e520ae9da6ee3df3b1c"
-				m.Eventually(events, timeout).Should(utils.WithItems(ContainElement(WithTransform(eventMessage, Equal(hashMessage)))))
+				m.Eventually(daemonset, timeout).Should(m.WithEvents(ContainElement(And(
+					utils.HaveReason("ConfigChanged"),
+					utils.HaveEventMessage(Equal(hashMessage)),
+				))))
 			})
 
 			Context("And a child is removed", func() {
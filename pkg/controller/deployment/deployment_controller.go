@@ -17,66 +17,164 @@ limitations under the License.
 package deployment
 
 import (
-	"context"
-
+	"github.com/wave-k8s/wave/pkg/concurrency"
 	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/priority"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // Add creates a new Deployment Controller and adds it to the Manager with default RBAC. The Manager will set fields on the Controller
 // and Start it when the Manager is Started.
-func Add(mgr manager.Manager) error {
-	return add(mgr, newReconciler(mgr))
+func Add(mgr manager.Manager, opts ...core.Option) error {
+	r := newReconciler(mgr, opts...)
+	return add(mgr, r, r.handler.Watches())
 }
 
 // newReconciler returns a new reconcile.Reconciler
-func newReconciler(mgr manager.Manager) reconcile.Reconciler {
+func newReconciler(mgr manager.Manager, opts ...core.Option) *ReconcileDeployment {
 	return &ReconcileDeployment{
 		scheme:  mgr.GetScheme(),
-		handler: core.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("wave")),
+		handler: core.NewHandler(mgr.GetClient(), mgr.GetEventRecorderFor("wave"), opts...),
 	}
 }
 
-// add adds a new Controller to mgr with r as the reconcile.Reconciler
-func add(mgr manager.Manager, r reconcile.Reconciler) error {
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+// watches configures the additional watches the Handler's options need.
+func add(mgr manager.Manager, r reconcile.Reconciler, watches core.Watches) error {
+	// Limit the reconciles the controller runs at once
+	limiter := concurrency.NewLimiter("deployment-controller", watches.Concurrency)
+	if err := mgr.Add(limiter); err != nil {
+		return err
+	}
+
+	// Never reconcile a workload while the fan-out pool does
+	reconciler := limiter.Reconciler(r)
+	if watches.FanOut != nil {
+		reconciler = watches.FanOut.Reconciler("deployment-controller", reconciler)
+	}
+
 	// Create a new controller
-	c, err := controller.New("deployment-controller", mgr, controller.Options{Reconciler: r})
+	c, err := controller.New("deployment-controller", mgr, controller.Options{
+		Reconciler:              reconciler,
+		MaxConcurrentReconciles: limiter.MaxConcurrentReconciles(),
+	})
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to Deployment
-	err = c.Watch(&source.Kind{Type: &appsv1.Deployment{}}, &handler.EnqueueRequestForObject{})
+	// Feed requests to the controller by the priority of their Namespace
+	prioritize := limiter.Handler
+	if watches.NamespacePriority {
+		queue := priority.NewQueue(mgr.GetClient(), limiter.MaxConcurrentReconciles())
+		prioritize = func(h handler.EventHandler) handler.EventHandler { return limiter.Handler(queue.Handler(h)) }
+	}
+
+	// Reconcile the consumers of changed ConfigMaps and Secrets on the
+	// fan-out pool, if any, rather than through the workqueue
+	fanOut := prioritize
+	if watches.FanOut != nil {
+		fanOut = func(h handler.EventHandler) handler.EventHandler {
+			return prioritize(watches.FanOut.Handler("deployment-controller", r, h))
+		}
+	}
+
+	// Watch for changes to Deployment, in the version of the apps API the cluster
+	// serves
+	workloadPredicates := append(append([]predicate.Predicate{}, watches.Predicates...), watches.WorkloadPredicates...)
+	err = c.Watch(&source.Kind{Type: watches.Capabilities.WorkloadType("Deployment")}, prioritize(&handler.EnqueueRequestForObject{}), workloadPredicates...)
 	if err != nil {
 		return err
 	}
 
 	// Watch ConfigMaps owned by a Deployment
-	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, &handler.EnqueueRequestForOwner{
+	err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, fanOut(&handler.EnqueueRequestForOwner{
 		IsController: false,
 		OwnerType:    &appsv1.Deployment{},
-	})
+	}), watches.Predicates...)
 	if err != nil {
 		return err
 	}
 
-	// Watch Secrets owned by a Deployment
-	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestForOwner{
+	// Watch Secrets owned by a Deployment, or referenced by one if Wave only
+	// reads the metadata of Secrets
+	var secretHandler handler.EventHandler = &handler.EnqueueRequestForOwner{
 		IsController: false,
 		OwnerType:    &appsv1.Deployment{},
-	})
+	}
+	if watches.SecretMetadataOnly {
+		secretHandler = &handler.EnqueueRequestsFromMapFunc{
+			ToRequests: core.SecretConsumers(mgr.GetClient(), "Deployment", watches.WorkloadKinds),
+		}
+	}
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, fanOut(secretHandler), watches.Predicates...)
 	if err != nil {
 		return err
 	}
 
+	// Watch the objects the CSI Secrets Store driver mounted into the Pods
+	// of a Deployment
+	if watches.SecretProviderClassPodStatuses {
+		status := &unstructured.Unstructured{}
+		status.SetGroupVersionKind(core.SecretProviderClassPodStatusGVK)
+		err = c.Watch(&source.Kind{Type: status}, prioritize(&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: core.SecretProviderClassPodStatusConsumers(mgr.GetClient(), "Deployment"),
+		}), watches.Predicates...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch the copies of Deployments made by blue/green rollouts, so that
+	// their Service is switched as soon as they are ready
+	if watches.BlueGreen {
+		err = c.Watch(&source.Kind{Type: watches.Capabilities.WorkloadType("Deployment")}, prioritize(&handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &appsv1.Deployment{},
+		}), watches.Predicates...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch the Jobs of restart hooks, which the Deployment owns
+	if watches.RestartHooks {
+		err = c.Watch(&source.Kind{Type: &batchv1.Job{}}, prioritize(&handler.EnqueueRequestForOwner{
+			OwnerType: &appsv1.Deployment{},
+		}), watches.Predicates...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Reconcile every Deployment when the kill switch is released
+	if watches.KillSwitch != nil {
+		err = c.Watch(&source.Kind{Type: &corev1.ConfigMap{}}, prioritize(&handler.EnqueueRequestsFromMapFunc{
+			ToRequests: core.KillSwitchReleased(mgr.GetClient(), *watches.KillSwitch, "Deployment"),
+		}), watches.Predicates...)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Add the watches of library consumers embedding Wave
+	for _, w := range watches.Extra {
+		predicates := append(append([]predicate.Predicate{}, watches.Predicates...), w.Predicates...)
+		if err := c.Watch(w.Source, prioritize(w.Handler("Deployment")), predicates...); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -96,8 +194,11 @@ type ReconcileDeployment struct {
 // +kubebuilder:rbac:groups=,resources=events,verbs=create;update;patch
 func (r *ReconcileDeployment) Reconcile(request reconcile.Request) (reconcile.Result, error) {
 	// Fetch the Deployment instance
+	ctx, cancel := r.handler.ReconcileContext()
+	defer cancel()
+
 	instance := &appsv1.Deployment{}
-	err := r.handler.Get(context.TODO(), request.NamespacedName, instance)
+	err := r.handler.Get(ctx, request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Object not found, return.  Created objects are automatically garbage collected.
@@ -107,5 +208,5 @@ func (r *ReconcileDeployment) Reconcile(request reconcile.Request) (reconcile.Re
 		return reconcile.Result{}, err
 	}
 
-	return r.handler.HandleDeployment(instance)
+	return r.handler.HandleDeployment(ctx, instance)
 }
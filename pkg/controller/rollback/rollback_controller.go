@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollback
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	wavev1alpha1 "github.com/wave-k8s/wave/pkg/apis/wave/v1alpha1"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Add creates a new Rollback Controller and adds it to the Manager. The
+// Rollback CRD must be installed.
+func Add(mgr manager.Manager) error {
+	return add(mgr, newReconciler(mgr))
+}
+
+// newReconciler returns a new reconcile.Reconciler
+func newReconciler(mgr manager.Manager) *ReconcileRollback {
+	return &ReconcileRollback{
+		Client:   mgr.GetClient(),
+		recorder: mgr.GetEventRecorderFor("wave"),
+		now:      time.Now,
+	}
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	c, err := controller.New("rollback-controller", mgr, controller.Options{Reconciler: r})
+	if err != nil {
+		return err
+	}
+	return c.Watch(&source.Kind{Type: &wavev1alpha1.Rollback{}}, &handler.EnqueueRequestForObject{})
+}
+
+var _ reconcile.Reconciler = &ReconcileRollback{}
+
+// ReconcileRollback carries out Rollbacks
+type ReconcileRollback struct {
+	client.Client
+	recorder record.EventRecorder
+	now      func() time.Time
+}
+
+// Reconcile restores the ConfigMaps and Secrets of the workload of a
+// Rollback once and records the outcome in its status
+// +kubebuilder:rbac:groups=wave.pusher.com,resources=rollbacks,verbs=get;list;watch
+// +kubebuilder:rbac:groups=wave.pusher.com,resources=rollbacks/status,verbs=update;patch
+func (r *ReconcileRollback) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx := context.Background()
+	instance := &wavev1alpha1.Rollback{}
+	err := r.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{}, err
+	}
+	if instance.Status.Phase != "" {
+		return reconcile.Result{}, nil
+	}
+
+	restored, err := r.rollback(ctx, instance)
+	for _, s := range restored {
+		instance.Status.Restored = append(instance.Status.Restored, wavev1alpha1.RestoredSource{Kind: s.Kind, Name: s.Name, Revision: s.Revision})
+	}
+	if err != nil {
+		instance.Status.Phase = wavev1alpha1.RollbackFailed
+		instance.Status.Message = err.Error()
+		r.recorder.Eventf(instance, corev1.EventTypeWarning, "RollbackFailed", "Unable to roll back %s/%s: %v", instance.Spec.Workload.Kind, instance.Spec.Workload.Name, err)
+	} else {
+		instance.Status.Phase = wavev1alpha1.RollbackSucceeded
+		instance.Status.Message = fmt.Sprintf("Restored %d ConfigMaps and Secrets", len(restored))
+		r.recorder.Eventf(instance, corev1.EventTypeNormal, "RolledBack", "Restored the configuration of %s/%s", instance.Spec.Workload.Kind, instance.Spec.Workload.Name)
+	}
+	now := metav1.NewTime(r.now())
+	instance.Status.CompletionTime = &now
+	if err := r.Status().Update(ctx, instance); err != nil {
+		return reconcile.Result{}, fmt.Errorf("error updating status: %v", err)
+	}
+	return reconcile.Result{}, nil
+}
+
+// rollback restores the ConfigMaps and Secrets of the workload of the
+// Rollback
+func (r *ReconcileRollback) rollback(ctx context.Context, instance *wavev1alpha1.Rollback) ([]core.RestoredSource, error) {
+	var obj core.Object
+	switch instance.Spec.Workload.Kind {
+	case "Deployment":
+		obj = &appsv1.Deployment{}
+	case "StatefulSet":
+		obj = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		obj = &appsv1.DaemonSet{}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", instance.Spec.Workload.Kind)
+	}
+	key := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.Spec.Workload.Name}
+	if err := r.Get(ctx, key, obj); err != nil {
+		return nil, fmt.Errorf("error getting %s %s: %v", instance.Spec.Workload.Kind, key.Name, err)
+	}
+	return core.Rollback(ctx, r.Client, obj, instance.Spec.ToRevision, r.now().UTC().Format(time.RFC3339Nano))
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rollback
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	wavev1alpha1 "github.com/wave-k8s/wave/pkg/apis/wave/v1alpha1"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Rollback controller", func() {
+	var r *ReconcileRollback
+	var ctx = context.TODO()
+	var key = types.NamespacedName{Namespace: "default", Name: "undo"}
+
+	newRollback := func(kind string, revision int) *wavev1alpha1.Rollback {
+		return &wavev1alpha1.Rollback{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "undo"},
+			Spec: wavev1alpha1.RollbackSpec{
+				Workload:   wavev1alpha1.WorkloadReference{Kind: kind, Name: "example"},
+				ToRevision: revision,
+			},
+		}
+	}
+
+	reconcileRollback := func(rollback *wavev1alpha1.Rollback) *wavev1alpha1.Rollback {
+		Expect(r.Create(ctx, rollback)).To(Succeed())
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(r.Get(ctx, key, rollback)).To(Succeed())
+		return rollback
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{core.RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}},
+			}},
+		}}
+		snapshot := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "config-rev-1",
+				Labels:    map[string]string{core.SnapshotLabel: "true"},
+				Annotations: map[string]string{
+					core.SnapshotOfAnnotation:       "config",
+					core.SnapshotRevisionAnnotation: "1",
+				},
+			},
+			Data: map[string]string{"key": "1"},
+		}
+		r = &ReconcileRollback{
+			Client: fake.NewFakeClient(d, snapshot, &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"},
+				Data:       map[string]string{"key": "2"},
+			}),
+			recorder: record.NewFakeRecorder(10),
+			now:      func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) },
+		}
+	})
+
+	It("restores the revision and records the outcome", func() {
+		rollback := reconcileRollback(newRollback("Deployment", 1))
+		Expect(rollback.Status.Phase).To(Equal(wavev1alpha1.RollbackSucceeded))
+		Expect(rollback.Status.Restored).To(Equal([]wavev1alpha1.RestoredSource{{Kind: "ConfigMap", Name: "config", Revision: 1}}))
+		Expect(rollback.Status.CompletionTime).NotTo(BeNil())
+
+		cm := &corev1.ConfigMap{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "config"}, cm)).To(Succeed())
+		Expect(cm.Data).To(Equal(map[string]string{"key": "1"}))
+		d := &appsv1.Deployment{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		Expect(d.GetAnnotations()).To(HaveKeyWithValue(core.TriggerAnnotation, "2019-01-02T03:04:05Z"))
+	})
+
+	It("records a failure without retrying", func() {
+		rollback := reconcileRollback(newRollback("Deployment", 4))
+		Expect(rollback.Status.Phase).To(Equal(wavev1alpha1.RollbackFailed))
+		Expect(rollback.Status.Message).To(ContainSubstring("has revision 4"))
+
+		// A completed Rollback is not carried out again
+		rollback.Spec.ToRevision = 1
+		Expect(r.Update(ctx, rollback)).To(Succeed())
+		_, err := r.Reconcile(reconcile.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+		cm := &corev1.ConfigMap{}
+		Expect(r.Get(ctx, types.NamespacedName{Namespace: "default", Name: "config"}, cm)).To(Succeed())
+		Expect(cm.Data).To(Equal(map[string]string{"key": "2"}))
+	})
+
+	It("fails for a missing workload", func() {
+		rollback := reconcileRollback(newRollback("StatefulSet", 1))
+		Expect(rollback.Status.Phase).To(Equal(wavev1alpha1.RollbackFailed))
+	})
+})
@@ -0,0 +1,164 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+)
+
+// AdminTarget selects the workloads an admin action applies to: the workload
+// of the Kind and Name in the Namespace, or every workload Wave manages in
+// the Namespace if they are empty
+type AdminTarget struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// Pause pauses rollouts of the targeted workloads by setting the
+// PausedAnnotation, and returns the workloads it paused
+func (h *Handler) Pause(ctx context.Context, target AdminTarget) ([]string, error) {
+	return h.administer(ctx, target, audit.Paused, func(desired podController) bool {
+		if isPaused(desired) {
+			return false
+		}
+		annotations := desired.GetAnnotations()
+		annotations[PausedAnnotation] = "true"
+		desired.SetAnnotations(annotations)
+		return true
+	})
+}
+
+// Resume resumes rollouts of the targeted workloads by removing the
+// PausedAnnotation, and returns the workloads it resumed
+func (h *Handler) Resume(ctx context.Context, target AdminTarget) ([]string, error) {
+	return h.administer(ctx, target, audit.Resumed, func(desired podController) bool {
+		annotations := desired.GetAnnotations()
+		if _, ok := annotations[PausedAnnotation]; !ok {
+			return false
+		}
+		delete(annotations, PausedAnnotation)
+		desired.SetAnnotations(annotations)
+		return true
+	})
+}
+
+// ReleaseRollouts lets the rollouts of the targeted workloads which were
+// deferred by restart spreading start immediately, and returns the workloads
+// it released. The reserved start time recorded on each workload is moved to
+// now, which the Handler reconciling the workload restores in place of its
+// own reservation.
+func (h *Handler) ReleaseRollouts(ctx context.Context, target AdminTarget) ([]string, error) {
+	now := h.getClock().Now().UTC()
+	return h.administer(ctx, target, audit.RolloutReleased, func(desired podController) bool {
+		pending, ok := getPendingRollout(desired)
+		if !ok || !pending.NotBefore.After(now) {
+			return false
+		}
+		pending.NotBefore = now
+		return setPendingRollout(desired, &pending) == nil
+	})
+}
+
+// administer applies the mutation to each targeted workload, retrying on
+// conflicts, and reports each write. mutate returns false if the workload
+// needs no change.
+func (h *Handler) administer(ctx context.Context, target AdminTarget, mutation audit.Mutation, mutate func(podController) bool) ([]string, error) {
+	instances, err := h.adminTargets(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	changed := []string{}
+	for _, instance := range instances {
+		key := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}
+		updated := false
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current := instance.DeepCopy()
+			if err := h.Get(ctx, key, current.GetObject()); err != nil {
+				return err
+			}
+			desired := current.DeepCopy()
+			if desired.GetAnnotations() == nil {
+				desired.SetAnnotations(map[string]string{})
+			}
+			if updated = mutate(desired); !updated {
+				return nil
+			}
+			return h.updateWorkload(ctx, current, desired, mutation)
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "error updating %s %s", kindOf(instance), key)
+		}
+		if updated {
+			changed = append(changed, fmt.Sprintf("%s/%s", kindOf(instance), instance.GetName()))
+		}
+	}
+	return changed, nil
+}
+
+// adminTargets returns the workloads Wave manages that the target selects.
+// Naming a workload Wave does not manage is a bad request.
+func (h *Handler) adminTargets(ctx context.Context, target AdminTarget) ([]podController, error) {
+	if target.Namespace == "" {
+		return nil, apierrors.NewBadRequest("a namespace is required")
+	}
+	if target.Kind == "" && target.Name == "" {
+		workloads, err := h.ListWorkloads(ctx, target.Namespace)
+		if err != nil {
+			return nil, err
+		}
+		instances := []podController{}
+		for _, obj := range workloads {
+			if instance, err := asPodController(obj); err == nil && hasRequiredAnnotation(instance) {
+				instances = append(instances, instance)
+			}
+		}
+		return instances, nil
+	}
+
+	var obj Object
+	switch target.Kind {
+	case "Deployment":
+		obj = &appsv1.Deployment{}
+	case "StatefulSet":
+		obj = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		obj = &appsv1.DaemonSet{}
+	default:
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("unsupported kind %q", target.Kind))
+	}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: target.Namespace, Name: target.Name}, obj); err != nil {
+		return nil, err
+	}
+	instance, err := asPodController(obj)
+	if err != nil {
+		return nil, err
+	}
+	if !hasRequiredAnnotation(instance) {
+		return nil, apierrors.NewBadRequest(fmt.Sprintf("%s %s/%s is not managed by Wave", target.Kind, target.Namespace, target.Name))
+	}
+	return []podController{instance}, nil
+}
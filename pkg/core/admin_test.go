@@ -0,0 +1,143 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// patchRecorder records the data of each patch sent, as the fake client
+// cannot remove map keys with merge patches
+type patchRecorder struct {
+	client.Client
+	patches []string
+}
+
+func (r *patchRecorder) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+	r.patches = append(r.patches, string(data))
+	return r.Client.Patch(ctx, obj, patch, opts...)
+}
+
+var _ = Describe("Wave admin Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	newDeployment := func(name string, annotations map[string]string) *appsv1.Deployment {
+		return &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			UID:         types.UID(name),
+			Annotations: annotations,
+		}}
+	}
+
+	annotationsOf := func(name string) map[string]string {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d.GetAnnotations()
+	}
+
+	BeforeEach(func() {
+		pending := `{"hash":"new","notBefore":"2019-01-02T04:00:00Z"}`
+		c = fake.NewFakeClient(
+			newDeployment("first", map[string]string{RequiredAnnotation: "true", PendingRolloutAnnotation: pending}),
+			newDeployment("second", map[string]string{RequiredAnnotation: "true", PausedAnnotation: "true"}),
+			newDeployment("unmanaged", nil),
+		)
+		recorder = record.NewFakeRecorder(10)
+		h = NewHandler(c, recorder, WithClock(clock.NewFakeClock(now)))
+	})
+
+	It("pauses every managed workload in a namespace", func() {
+		paused, err := h.Pause(context.TODO(), AdminTarget{Namespace: "default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(paused).To(Equal([]string{"Deployment/first"}))
+		Expect(annotationsOf("first")).To(HaveKeyWithValue(PausedAnnotation, "true"))
+		Expect(annotationsOf("unmanaged")).NotTo(HaveKey(PausedAnnotation))
+		Expect(recorder.Events).To(Receive(ContainSubstring(string(audit.Paused))))
+	})
+
+	It("resumes a single workload", func() {
+		r := &patchRecorder{Client: c}
+		h.Client = r
+		resumed, err := h.Resume(context.TODO(), AdminTarget{Namespace: "default", Kind: "Deployment", Name: "second"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resumed).To(Equal([]string{"Deployment/second"}))
+		Expect(r.patches).To(Equal([]string{`{"metadata":{"annotations":{"wave.pusher.com/paused":null}}}`}))
+	})
+
+	It("moves the start of deferred rollouts to now", func() {
+		released, err := h.ReleaseRollouts(context.TODO(), AdminTarget{Namespace: "default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(released).To(Equal([]string{"Deployment/first"}))
+
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "first"}, d)).To(Succeed())
+		pending, ok := getPendingRollout(&deployment{d})
+		Expect(ok).To(BeTrue())
+		Expect(pending.Hash).To(Equal("new"))
+		Expect(pending.NotBefore).To(Equal(now))
+	})
+
+	It("lets a released start time replace the reservation", func() {
+		gate := newRolloutGate(nil, CapacityOptions{SpreadInterval: time.Hour})
+		gate.reserve(types.UID("first"), now, time.Hour)
+		gate.reserve(types.UID("first"), now, time.Hour)
+		start, _ := gate.reservation(types.UID("first"))
+		Expect(start).To(Equal(now.Add(time.Hour)))
+
+		gate.restore(types.UID("first"), now.Add(2*time.Hour), time.Hour)
+		start, _ = gate.reservation(types.UID("first"))
+		Expect(start).To(Equal(now.Add(time.Hour)))
+
+		gate.restore(types.UID("first"), now, time.Hour)
+		wait, ok := gate.reserved(types.UID("first"), now)
+		Expect(ok).To(BeTrue())
+		Expect(wait).To(BeZero())
+	})
+
+	It("rejects workloads Wave does not manage", func() {
+		_, err := h.Pause(context.TODO(), AdminTarget{Namespace: "default", Kind: "Deployment", Name: "unmanaged"})
+		Expect(errors.IsBadRequest(err)).To(BeTrue())
+
+		_, err = h.Pause(context.TODO(), AdminTarget{Namespace: "default", Kind: "Deployment", Name: "missing"})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+
+		_, err = h.Pause(context.TODO(), AdminTarget{Namespace: "default", Kind: "Pod", Name: "first"})
+		Expect(errors.IsBadRequest(err)).To(BeTrue())
+	})
+})
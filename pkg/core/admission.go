@@ -0,0 +1,96 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Admit calculates the configuration hash a reconcile would apply to the
+// Deployment, StatefulSet or DaemonSet being created and records it, and the
+// source hashes, on the object, so that its first Pods already run with it.
+// It only reads through the Handler's client, which should be backed by the
+// controller's cache. It returns false, leaving the object as it is for the
+// controller, if Wave doesn't manage it or if its first hash depends on
+// anything else, such as a hold, a Secret awaiting its data or a source
+// outside the cluster.
+func (h *Handler) Admit(ctx context.Context, obj Object) (bool, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return false, err
+	}
+	if !h.admits(instance) {
+		return false, nil
+	}
+	engaged, err := h.killSwitchEngaged(ctx)
+	if err != nil || engaged {
+		return false, err
+	}
+
+	current, err := h.getChildren(ctx, instance, false)
+	if err != nil {
+		return false, errors.Wrap(err, "error fetching current children")
+	}
+	if waiting, _ := h.awaitedSecrets(instance, current); len(waiting) > 0 {
+		return false, nil
+	}
+	hash, err := configHash(current, instance, nil, nil, nil)
+	if err != nil {
+		return false, errors.Wrap(err, "error calculating configuration hash")
+	}
+
+	setConfigHash(instance, hash)
+	setAppliedTrigger(instance, instance)
+	if err := setSourceHashes(instance, calculateSourceHashes(current)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// admits returns true if the first reconcile of the instance would place
+// the hash on its pod template right away, from nothing but the ConfigMaps
+// and Secrets it references
+func (h *Handler) admits(instance podController) bool {
+	if policy, err := TriggerPolicyOf(instance); err != nil || policy != ImmediatePolicy {
+		return false
+	}
+	if _, err := HashPathOf(instance); err != nil {
+		return false
+	}
+	if !listsKind([]client.ListOption{h.kinds}, kindOf(instance)) {
+		return false
+	}
+	if isPaused(instance) || h.shadow != nil || !h.accepts(instance) {
+		return false
+	}
+	if h.usesExecutor(instance) || h.usesBlueGreen(instance) || h.usesCanary(instance) {
+		return false
+	}
+	if h.hooks != nil && instance.GetAnnotations()[PreRestartHookAnnotation] != "" {
+		return false
+	}
+	if h.csiSecretsStore && len(getSecretProviderClasses(instance)) > 0 {
+		return false
+	}
+	if h.plugins != nil && len(getPluginSources(instance)) > 0 {
+		return false
+	}
+	return h.extraURLs == nil || len(getExtraURLs(instance)) == 0
+}
@@ -0,0 +1,160 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var (
+	// antiEntropyRepairs counts the workloads whose configuration hash the
+	// anti-entropy audit found out of date and updated
+	antiEntropyRepairs = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wave_anti_entropy_repairs_total",
+		Help: "Number of workloads whose configuration hash was repaired by the anti-entropy audit, by kind of workload",
+	}, []string{"kind"})
+
+	// antiEntropyFailures counts the workloads the anti-entropy audit could
+	// not reconcile
+	antiEntropyFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wave_anti_entropy_failures_total",
+		Help: "Number of workloads the anti-entropy audit failed to reconcile, by kind of workload",
+	}, []string{"kind"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(antiEntropyRepairs, antiEntropyFailures)
+}
+
+// AuditResult counts the outcomes of an anti-entropy audit
+type AuditResult struct {
+	// Checked is the number of tracked workloads reconciled
+	Checked int
+
+	// Repaired is the number of workloads whose configuration hash was
+	// updated
+	Repaired int
+
+	// Failed is the number of workloads which could not be reconciled
+	Failed int
+}
+
+// AntiEntropy periodically reconciles every tracked workload, independently
+// of watch events, so that hashes which drifted from the workloads'
+// configuration are repaired: after missed events, manual edits of the hash
+// annotation or a restore of etcd from a backup.
+type AntiEntropy struct {
+	h          *Handler
+	interval   time.Duration
+	namespaces []string
+}
+
+// NewAntiEntropy returns an AntiEntropy auditing the workloads in the
+// namespaces, or in all namespaces if none are given, every interval
+func NewAntiEntropy(h *Handler, interval time.Duration, namespaces []string) *AntiEntropy {
+	return &AntiEntropy{h: h, interval: interval, namespaces: namespaces}
+}
+
+// Start implements manager.Runnable. As it writes to workloads, it only runs
+// on the leader.
+func (a *AntiEntropy) Start(stop <-chan struct{}) error {
+	log := logf.Log.WithName("anti-entropy")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	ticker := a.h.getClock().NewTicker(a.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C():
+		}
+		result, err := a.Audit(ctx)
+		if err != nil {
+			log.Error(err, "Unable to audit workloads")
+			continue
+		}
+		log.V(0).Info("Audited workloads", "checked", result.Checked, "repaired", result.Repaired, "failed", result.Failed)
+	}
+}
+
+// Audit reconciles every tracked workload a single time and counts those
+// whose configuration hash it updated. A workload which fails to reconcile
+// is logged and counted rather than stopping the others.
+func (a *AntiEntropy) Audit(ctx context.Context) (AuditResult, error) {
+	log := logf.Log.WithName("anti-entropy")
+	namespaces := a.namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	result := AuditResult{}
+	for _, namespace := range namespaces {
+		workloads, err := a.h.ListWorkloads(ctx, namespace)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range workloads {
+			instance, err := asPodController(obj)
+			if err != nil || !hasRequiredAnnotation(instance) {
+				continue
+			}
+
+			result.Checked++
+			repaired, err := a.repair(ctx, instance)
+			switch {
+			case err != nil:
+				log.Error(err, "Unable to reconcile instance", "kind", kindOf(instance), "namespace", instance.GetNamespace(), "name", instance.GetName())
+				antiEntropyFailures.WithLabelValues(kindOf(instance)).Inc()
+				result.Failed++
+			case repaired:
+				log.V(0).Info("Repaired configuration hash", "kind", kindOf(instance), "namespace", instance.GetNamespace(), "name", instance.GetName())
+				antiEntropyRepairs.WithLabelValues(kindOf(instance)).Inc()
+				result.Repaired++
+			}
+		}
+	}
+	return result, nil
+}
+
+// repair reconciles the instance and returns true if its configuration hash
+// changed as a result
+func (a *AntiEntropy) repair(ctx context.Context, instance podController) (bool, error) {
+	rctx, cancel := a.h.reconcileContext(ctx)
+	defer cancel()
+	if _, err := a.h.handle(rctx, instance); err != nil {
+		return false, err
+	}
+
+	current := instance.DeepCopy()
+	key := types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()}
+	if err := a.h.Client.Get(ctx, key, current.GetObject()); err != nil {
+		return false, err
+	}
+	return getConfigHash(current) != getConfigHash(instance), nil
+}
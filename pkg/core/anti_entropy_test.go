@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave anti-entropy Suite", func() {
+	var c client.Client
+	var a *AntiEntropy
+
+	hashOf := func(name string) string {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return getConfigHash(&deployment{d})
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "tracked",
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			}},
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "untracked"}},
+		)
+		a = NewAntiEntropy(NewHandler(c, record.NewFakeRecorder(10)), time.Hour, nil)
+	})
+
+	It("repairs hashes which drifted from the configuration", func() {
+		result, err := a.Audit(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(AuditResult{Checked: 1, Repaired: 1}))
+		hash := hashOf("tracked")
+		Expect(hash).NotTo(BeEmpty())
+		Expect(hashOf("untracked")).To(BeEmpty())
+
+		// Edit the hash by hand
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "tracked"}, d)).To(Succeed())
+		d.Spec.Template.SetAnnotations(map[string]string{ConfigHashAnnotation: "edited"})
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		result, err = a.Audit(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(AuditResult{Checked: 1, Repaired: 1}))
+		Expect(hashOf("tracked")).To(Equal(hash))
+	})
+
+	It("counts no repairs when the hashes are up to date", func() {
+		_, err := a.Audit(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		result, err := a.Audit(context.TODO())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(AuditResult{Checked: 1}))
+	})
+})
@@ -0,0 +1,129 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ApprovalTTLAnnotation is the key of an optional annotation on workloads
+	// with the ManualPolicy. Its value is a duration, such as "4h", after
+	// which an approval given by changing the TriggerAnnotation expires if it
+	// still wasn't rolled out, for example while waiting for a window, and
+	// must be given again.
+	ApprovalTTLAnnotation = "wave.pusher.com/approval-ttl"
+
+	// ApprovalAnnotation is the key of the annotation on the Deployment
+	// recording the approval waiting to be rolled out and when Wave first saw
+	// it, so that restarting Wave doesn't extend it
+	ApprovalAnnotation = "wave.pusher.com/approval"
+)
+
+// approval is recorded in the ApprovalAnnotation of a workload
+type approval struct {
+	// Trigger is the value of the TriggerAnnotation which gave the approval
+	Trigger string `json:"trigger"`
+
+	// ApprovedAt is when Wave first saw the approval
+	ApprovedAt time.Time `json:"approvedAt"`
+}
+
+// approvalTTL returns the duration of the ApprovalTTLAnnotation of the
+// instance, if it has one
+func approvalTTL(instance podController) (time.Duration, bool, error) {
+	value, ok := instance.GetAnnotations()[ApprovalTTLAnnotation]
+	if !ok {
+		return 0, false, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err == nil && ttl <= 0 {
+		err = fmt.Errorf("must be positive")
+	}
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "invalid %s %q", ApprovalTTLAnnotation, value)
+	}
+	return ttl, true, nil
+}
+
+// getApproval returns the approval recorded on the podController for its
+// current TriggerAnnotation, if any
+func getApproval(obj podController) (approval, bool) {
+	value, ok := obj.GetAnnotations()[ApprovalAnnotation]
+	if !ok {
+		return approval{}, false
+	}
+	recorded := approval{}
+	if err := json.Unmarshal([]byte(value), &recorded); err != nil || recorded.ApprovedAt.IsZero() {
+		return approval{}, false
+	}
+	if recorded.Trigger != obj.GetAnnotations()[TriggerAnnotation] {
+		return approval{}, false
+	}
+	return recorded, true
+}
+
+// approvalExpired returns true if the approval of the instance was given
+// longer than its ApprovalTTLAnnotation ago. An approval Wave sees for the
+// first time was given now. Invalid TTLs are reported and ignored.
+func (h *Handler) approvalExpired(instance podController) (bool, time.Duration) {
+	ttl, ok, err := approvalTTL(instance)
+	if err != nil {
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "InvalidApprovalTTL", "Ignoring approval TTL: %v", err)
+		return false, 0
+	}
+	if !ok {
+		return false, 0
+	}
+	recorded, ok := getApproval(instance)
+	if !ok {
+		return false, ttl
+	}
+	return !h.getClock().Now().Before(recorded.ApprovedAt.Add(ttl)), ttl
+}
+
+// syncApproval records the approval of the instance waiting to be rolled out
+// on the desired state, keeping the time it was first seen, or removes the
+// record once there is none
+func (h *Handler) syncApproval(instance, desired podController) error {
+	annotations := desired.GetAnnotations()
+	policy, _ := TriggerPolicyOf(instance)
+	_, ok, _ := approvalTTL(instance)
+	if policy != ManualPolicy || !ok || !triggered(desired) {
+		if _, ok := annotations[ApprovalAnnotation]; ok {
+			delete(annotations, ApprovalAnnotation)
+			desired.SetAnnotations(annotations)
+		}
+		return nil
+	}
+	if _, ok := getApproval(instance); ok {
+		return nil
+	}
+
+	value, err := json.Marshal(approval{Trigger: annotations[TriggerAnnotation], ApprovedAt: h.getClock().Now().UTC()})
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	annotations[ApprovalAnnotation] = string(value)
+	desired.SetAnnotations(annotations)
+	return nil
+}
@@ -0,0 +1,148 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave approval TTL Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	approve := func(trigger string) {
+		d := getDeployment()
+		d.Annotations[TriggerAnnotation] = trigger
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	setup := func(annotations map[string]string) {
+		annotations[RequiredAnnotation] = string(ManualPolicy)
+		annotations[MaintenanceWindowAnnotation] = "Tue 22:00-23:00"
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: annotations,
+		}}
+		d.Spec.Strategy.Type = appsv1.RecreateDeploymentStrategyType
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		// A Tuesday
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC))
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithRecreateHold())
+	}
+
+	It("expires approvals which weren't rolled out in time", func() {
+		setup(map[string]string{ApprovalTTLAnnotation: "4h"})
+		approve("1")
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		recorded, ok := getApproval(&deployment{getDeployment()})
+		Expect(ok).To(BeTrue())
+		Expect(recorded.ApprovedAt).To(Equal(fakeClock.Now()))
+
+		// Reconciling again doesn't renew the approval
+		fakeClock.Step(time.Hour)
+		handle()
+		recorded, _ = getApproval(&deployment{getDeployment()})
+		Expect(recorded.ApprovedAt).To(Equal(fakeClock.Now().Add(-time.Hour)))
+
+		// Held back in the window once expired
+		fakeClock.SetTime(time.Date(2019, 1, 1, 22, 30, 0, 0, time.UTC))
+		events()
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(events()).To(ContainElement(And(ContainSubstring("RolloutHeld"), ContainSubstring("approval expired after 4h0m0s"))))
+
+		// Approving again rolls it out
+		approve("2")
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("rolls out approvals within their TTL", func() {
+		setup(map[string]string{ApprovalTTLAnnotation: "12h"})
+		approve("1")
+		handle()
+		fakeClock.SetTime(time.Date(2019, 1, 1, 22, 30, 0, 0, time.UTC))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("removes the record of approvals once rolled out", func() {
+		setup(map[string]string{ApprovalTTLAnnotation: "4h"})
+		approve("1")
+		handle()
+		instance := &deployment{getDeployment()}
+		Expect(instance.Annotations).To(HaveKey(ApprovalAnnotation))
+
+		desired := instance.DeepCopy()
+		setAppliedTrigger(instance, desired)
+		Expect(h.syncApproval(instance, desired)).To(Succeed())
+		Expect(desired.GetAnnotations()).NotTo(HaveKey(ApprovalAnnotation))
+	})
+
+	It("doesn't expire approvals without a TTL", func() {
+		setup(map[string]string{})
+		approve("1")
+		handle()
+		Expect(getDeployment().Annotations).NotTo(HaveKey(ApprovalAnnotation))
+		fakeClock.SetTime(time.Date(2019, 1, 1, 22, 30, 0, 0, time.UTC))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("ignores invalid TTLs", func() {
+		setup(map[string]string{ApprovalTTLAnnotation: "soon"})
+		approve("1")
+		handle()
+		Expect(events()).To(ContainElement(ContainSubstring("InvalidApprovalTTL")))
+		Expect(getDeployment().Annotations).NotTo(HaveKey(ApprovalAnnotation))
+	})
+})
@@ -0,0 +1,119 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// auditTimeout is the maximum time allowed for each attempt at writing a
+	// single audit record
+	auditTimeout = 30 * time.Second
+
+	// auditAttempts is the number of times writing an audit record is
+	// attempted before it is dropped
+	auditAttempts = 3
+)
+
+// AuditOptions configures where the Handler ships audit records
+type AuditOptions struct {
+	// Sink receives a Record for each rollout decision
+	Sink audit.Sink
+
+	// Actor identifies this Wave instance in the records
+	Actor string
+
+	// Writes additionally sends the Sink a Record of every write Wave makes
+	// to the cluster
+	Writes bool
+
+	// Stream receives each Record of a rollout decision as soon as it is
+	// made, without retries, for example to stream it to subscribers
+	Stream audit.Sink
+}
+
+// recordDecision writes an audit Record describing the rollout decision to
+// the configured Sink and Stream, if there are any.
+// Records are written to the Sink asynchronously and retried with a backoff so that a
+// slow or briefly unavailable sink cannot block reconciliation.
+func (h *Handler) recordDecision(decision audit.Decision, obj podController, hash string, changes []sourceChange, reason string) {
+	if h.audit == nil {
+		return
+	}
+
+	record := audit.Record{
+		Time:  h.getClock().Now(),
+		Actor: h.audit.Actor,
+		Workload: audit.Workload{
+			Namespace: obj.GetNamespace(),
+			Kind:      kindOf(obj),
+			Name:      obj.GetName(),
+			UID:       string(obj.GetUID()),
+		},
+		Decision: decision,
+		Reason:   reason,
+		OldHash:  getConfigHash(obj),
+		NewHash:  hash,
+		Changes:  []audit.Change{},
+	}
+	for _, change := range changes {
+		record.Changes = append(record.Changes, audit.Change{
+			Kind: change.kind,
+			Name: change.name,
+			Type: change.change,
+		})
+	}
+	if h.audit.Stream != nil {
+		if err := h.audit.Stream.Write(context.Background(), record); err != nil {
+			log := logf.Log.WithName("wave")
+			log.Error(err, "Unable to stream audit record", "namespace", record.Workload.Namespace, "name", record.Workload.Name, "decision", record.Decision)
+		}
+	}
+	h.sendRecord(record)
+}
+
+// sendRecord writes the Record to the configured Sink asynchronously
+func (h *Handler) sendRecord(record audit.Record) {
+	if h.audit.Sink == nil {
+		return
+	}
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		var err error
+		backoff := time.Second
+		for attempt := 0; attempt < auditAttempts; attempt++ {
+			if attempt > 0 {
+				h.getClock().Sleep(backoff)
+				backoff *= 2
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), auditTimeout)
+			err = h.audit.Sink.Write(ctx, record)
+			cancel()
+			if err == nil {
+				return
+			}
+		}
+		log := logf.Log.WithName("wave")
+		log.Error(err, "Unable to write audit record", "namespace", record.Workload.Namespace, "name", record.Workload.Name, "decision", record.Decision)
+	}()
+}
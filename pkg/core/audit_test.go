@@ -0,0 +1,117 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// sinkFunc adapts a function to the audit.Sink interface
+type sinkFunc func(context.Context, audit.Record) error
+
+func (f sinkFunc) Write(ctx context.Context, record audit.Record) error {
+	return f(ctx, record)
+}
+
+var _ = Describe("Wave audit Suite", func() {
+	var h *Handler
+	var records chan audit.Record
+	var instance podController
+
+	BeforeEach(func() {
+		records = make(chan audit.Record, 5)
+		h = &Handler{}
+		instance = &deployment{utils.ExampleDeployment.DeepCopy()}
+		setConfigHash(instance, "old")
+	})
+
+	It("does nothing without a sink", func() {
+		WithAudit(AuditOptions{})(h)
+		Expect(h.audit).To(BeNil())
+		h.recordDecision(audit.Triggered, instance, "new", nil, "")
+	})
+
+	It("records the workload, hashes and changes", func() {
+		WithAudit(AuditOptions{
+			Actor: "wave-0",
+			Sink: sinkFunc(func(ctx context.Context, record audit.Record) error {
+				records <- record
+				return nil
+			}),
+		})(h)
+		changes := []sourceChange{
+			{sourceKey: sourceKey{kind: "ConfigMap", name: "example1"}, change: sourceAdded},
+		}
+		h.recordDecision(audit.Triggered, instance, "new", changes, "")
+
+		var record audit.Record
+		Eventually(records).Should(Receive(&record))
+		Expect(record.Actor).To(Equal("wave-0"))
+		Expect(record.Workload.Kind).To(Equal("Deployment"))
+		Expect(record.Workload.Name).To(Equal(instance.GetName()))
+		Expect(record.Decision).To(Equal(audit.Triggered))
+		Expect(record.OldHash).To(Equal("old"))
+		Expect(record.NewHash).To(Equal("new"))
+		Expect(record.Changes).To(Equal([]audit.Change{{Kind: "ConfigMap", Name: "example1", Type: "added"}}))
+	})
+
+	It("streams decisions synchronously without a sink", func() {
+		WithAudit(AuditOptions{
+			Stream: sinkFunc(func(ctx context.Context, record audit.Record) error {
+				records <- record
+				return nil
+			}),
+		})(h)
+		h.recordDecision(audit.Deferred, instance, "new", nil, "held")
+		Expect(records).To(Receive())
+
+		// Records of writes only go to the sink
+		WithAudit(AuditOptions{Writes: true, Stream: h.audit.Stream})(h)
+		h.recordWrite(audit.Object{Kind: "ConfigMap", Name: "example"}, instance, "", "", []audit.Mutation{audit.OwnerReferenceAdded})
+		Expect(records).NotTo(Receive())
+	})
+
+	It("retries failed writes", func() {
+		now := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+		WithClock(clock.NewFakeClock(now))(h)
+		attempts := 0
+		WithAudit(AuditOptions{
+			Sink: sinkFunc(func(ctx context.Context, record audit.Record) error {
+				attempts++
+				if attempts == 1 {
+					return errors.New("unavailable")
+				}
+				records <- record
+				return nil
+			}),
+		})(h)
+		h.recordDecision(audit.Denied, instance, "new", nil, "frozen")
+
+		var record audit.Record
+		Eventually(records).Should(Receive(&record))
+		Expect(record.Reason).To(Equal("frozen"))
+		Expect(record.Time).To(Equal(now))
+	})
+})
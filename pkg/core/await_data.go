@@ -0,0 +1,104 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// AwaitDataAnnotation is the key of an optional annotation on a Secret. With
+// the value "true", the Secret is populated after its creation, and Wave
+// holds back the first configuration hash of its consumers until the Secret
+// has data.
+const AwaitDataAnnotation = "wave.pusher.com/await-data"
+
+// WithAwaitSecretData holds back the first configuration hash of workloads
+// consuming Secrets which are populated after their creation, such as
+// certificates issued by the certificates.k8s.io CSR approval flow, until the
+// Secrets have data. Otherwise Wave hashes the empty Secrets and restarts the
+// workloads a second time once they are populated. Workloads are no longer
+// held back once the Secrets are older than timeout.
+func WithAwaitSecretData(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.awaitSecretData = timeout
+	}
+}
+
+// checkAwaitingData checks whether the first hash of the instance must wait
+// for Secrets it consumes to be populated and returns the time until it no
+// longer waits. The Secrets' updates requeue the instance before then.
+func (h *Handler) checkAwaitingData(instance podController, current []configObject, hash string, changes []sourceChange) (bool, time.Duration) {
+	waiting, wait := h.awaitedSecrets(instance, current)
+	if len(waiting) == 0 {
+		return false, 0
+	}
+
+	reason := fmt.Sprintf("Secrets %v are awaiting their data; waiting at most %s", waiting, wait)
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Holding back first hash until Secrets are populated", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash, "secrets", waiting, "wait", wait.String())
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutHeld", "Rollout of configuration hash %s held back: %s", shortHash(hash), reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return true, wait
+}
+
+// awaitedSecrets returns the names of the Secrets the first hash of the
+// instance waits for and the time until it no longer waits for them
+func (h *Handler) awaitedSecrets(instance podController, current []configObject) ([]string, time.Duration) {
+	if h.awaitSecretData <= 0 || getConfigHash(instance) != "" {
+		return nil, 0
+	}
+	var waiting []string
+	var wait time.Duration
+	for _, child := range current {
+		secret, ok := child.object.(*corev1.Secret)
+		if !ok || child.metadataOnly || !awaitingData(secret) {
+			continue
+		}
+		age := h.getClock().Since(secret.GetCreationTimestamp().Time)
+		if age >= h.awaitSecretData {
+			continue
+		}
+		waiting = append(waiting, secret.GetName())
+		if remaining := h.awaitSecretData - age; remaining > wait {
+			wait = remaining
+		}
+	}
+	return waiting, wait
+}
+
+// awaitingData returns true if the Secret is yet to be populated: a TLS
+// Secret without a certificate, or a Secret with the AwaitDataAnnotation
+// without any data
+func awaitingData(secret *corev1.Secret) bool {
+	if secret.Type == corev1.SecretTypeTLS && len(secret.Data[corev1.TLSCertKey]) == 0 {
+		return true
+	}
+	if secret.GetAnnotations()[AwaitDataAnnotation] != "true" {
+		return false
+	}
+	for _, value := range secret.Data {
+		if len(value) > 0 {
+			return false
+		}
+	}
+	return true
+}
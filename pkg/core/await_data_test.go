@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave await Secret data Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+	created := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	getSecret := func() *corev1.Secret {
+		s := &corev1.Secret{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "serving-cert"}, s)).To(Succeed())
+		return s
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "serving-cert"}}},
+			},
+		}}
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:         "default",
+				Name:              "serving-cert",
+				CreationTimestamp: metav1.NewTime(created),
+			},
+			Type: corev1.SecretTypeTLS,
+			Data: map[string][]byte{corev1.TLSCertKey: nil, corev1.TLSPrivateKeyKey: []byte("key")},
+		}
+		c = fake.NewFakeClient(d, s)
+		recorder = record.NewFakeRecorder(100)
+		fakeClock = clock.NewFakeClock(created.Add(time.Minute))
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithAwaitSecretData(10*time.Minute))
+	})
+
+	It("holds back the first hash while a TLS Secret has no certificate", func() {
+		Expect(handle()).To(Equal(9 * time.Minute))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		events := []string{}
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		Expect(events).To(ContainElement(And(ContainSubstring("RolloutHeld"), ContainSubstring("serving-cert"))))
+
+		s := getSecret()
+		s.Data[corev1.TLSCertKey] = []byte("cert")
+		Expect(c.Update(context.TODO(), s)).To(Succeed())
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("holds back the first hash while an annotated Secret has no data", func() {
+		s := getSecret()
+		s.Type = corev1.SecretTypeOpaque
+		s.Data = nil
+		s.Annotations = map[string]string{AwaitDataAnnotation: "true"}
+		Expect(c.Update(context.TODO(), s)).To(Succeed())
+
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+		s = getSecret()
+		s.Data = map[string][]byte{"tls.crt": []byte("cert")}
+		Expect(c.Update(context.TODO(), s)).To(Succeed())
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("writes the first hash once the timeout has passed", func() {
+		handle()
+		fakeClock.SetTime(created.Add(10 * time.Minute))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("doesn't hold back later rollouts", func() {
+		d := getDeployment()
+		setConfigHash(&deployment{d}, "previous")
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal("previous"))
+	})
+
+	It("doesn't hold back Secrets whose data Wave cannot read", func() {
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithAwaitSecretData(10*time.Minute), WithSecretMetadataOnly())
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("doesn't wait by default", func() {
+		h = NewHandler(c, recorder, WithClock(fakeClock))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+})
@@ -0,0 +1,284 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// RestartStrategyAnnotation is the key of an optional annotation on the
+	// Deployment choosing how Wave rolls out a new configuration. With the
+	// value "blue-green", Wave runs the new configuration in a copy of the
+	// Deployment and switches the Service named by the
+	// BlueGreenServiceAnnotation to it once it is ready, instead of rolling
+	// the Deployment.
+	RestartStrategyAnnotation = "wave.pusher.com/restart-strategy"
+
+	// BlueGreenServiceAnnotation is the key of the annotation on the
+	// Deployment naming the Service switched by blue/green rollouts
+	BlueGreenServiceAnnotation = "wave.pusher.com/blue-green-service"
+
+	// BlueGreenHashLabel is the key of the label selecting the Pods of a
+	// configuration during blue/green rollouts. Wave adds it to the
+	// selector and Pod template of each copy and to the Service's selector.
+	BlueGreenHashLabel = "wave.pusher.com/blue-green-hash"
+
+	// BlueGreenOfLabel is the key of the label on each copy of a Deployment
+	// naming the Deployment it was copied from
+	BlueGreenOfLabel = "wave.pusher.com/blue-green-of"
+
+	// blueGreenReplicasAnnotation records the replicas of a Deployment Wave
+	// scaled down after its first blue/green rollout
+	blueGreenReplicasAnnotation = "wave.pusher.com/blue-green-replicas"
+
+	// blueGreenStrategy is the value of the RestartStrategyAnnotation
+	// selecting blue/green rollouts
+	blueGreenStrategy = "blue-green"
+
+	// blueGreenHashLength is the length of the prefix of the configuration
+	// hash used in the names and labels of copies
+	blueGreenHashLength = 10
+)
+
+// BlueGreenOptions configures blue/green rollouts of Deployments
+type BlueGreenOptions struct {
+	// RetryInterval is how long to wait before checking whether the copy of
+	// a Deployment running a new configuration is ready
+	RetryInterval time.Duration
+}
+
+// WithBlueGreenRollouts allows Deployments to opt into blue/green rollouts
+// with the RestartStrategyAnnotation
+func WithBlueGreenRollouts(o BlueGreenOptions) Option {
+	return func(h *Handler) {
+		h.blueGreen = &o
+	}
+}
+
+// usesBlueGreen returns true if the instance is a Deployment rolled out by
+// blue/green rollouts
+func (h *Handler) usesBlueGreen(instance podController) bool {
+	_, ok := instance.GetObject().(*appsv1.Deployment)
+	return ok && h.blueGreen != nil && instance.GetAnnotations()[RestartStrategyAnnotation] == blueGreenStrategy
+}
+
+// rollBlueGreen rolls the configuration hash out to a copy of the Deployment
+// and returns true once the copy is ready and the Service was switched to
+// it. The previous copy is then scaled down, and any other copy deleted.
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=create;delete
+// +kubebuilder:rbac:groups=,resources=services,verbs=get;list;watch;update;patch
+func (h *Handler) rollBlueGreen(ctx context.Context, instance podController, hash string) (bool, error) {
+	d := instance.GetObject().(*appsv1.Deployment)
+	serviceName := d.GetAnnotations()[BlueGreenServiceAnnotation]
+	if serviceName == "" {
+		return false, fmt.Errorf("blue/green rollouts require the %s annotation", BlueGreenServiceAnnotation)
+	}
+	service := &corev1.Service{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: d.GetNamespace(), Name: serviceName}, service); err != nil {
+		return false, errors.Wrapf(err, "error getting Service %s", serviceName)
+	}
+
+	clones, err := h.getClones(ctx, d)
+	if err != nil {
+		return false, err
+	}
+	name := cloneName(d, hash)
+	previous := ""
+	if current := getConfigHash(instance); current != "" {
+		previous = cloneName(d, current)
+	}
+
+	// Create the copy running the new configuration and wait for it
+	clone, ok := clones[name]
+	if !ok {
+		clone = newClone(d, hash, blueGreenReplicas(d, clones[previous]))
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Creating copy for blue/green rollout", "namespace", d.GetNamespace(), "name", d.GetName(), "copy", name, "hash", hash)
+		target := audit.Object{Namespace: d.GetNamespace(), Kind: "Deployment", Name: name}
+		desired := withConfigHash(instance, hash)
+		if err := h.createObject(ctx, clone, target, desired, audit.CloneCreated); err != nil && !apierrors.IsAlreadyExists(err) {
+			return false, errors.Wrapf(err, "error creating Deployment %s", name)
+		}
+		return false, nil
+	}
+	if !h.rolloutComplete(&deployment{clone}) {
+		return false, nil
+	}
+
+	// Switch the Service to the copy
+	desired := withConfigHash(instance, hash)
+	if service.Spec.Selector[BlueGreenHashLabel] != shortHash(hash) {
+		original := service.DeepCopy()
+		if service.Spec.Selector == nil {
+			service.Spec.Selector = make(map[string]string)
+		}
+		service.Spec.Selector[BlueGreenHashLabel] = shortHash(hash)
+		target := audit.Object{Namespace: service.GetNamespace(), Kind: "Service", Name: service.GetName()}
+		if err := h.update(ctx, service, original, target, desired, []audit.Mutation{audit.ServiceSwitched}); err != nil {
+			return false, errors.Wrapf(err, "error updating Service %s", serviceName)
+		}
+	}
+
+	// Keep the previous copy scaled down, so it can be switched back to,
+	// and delete the others
+	for cloneName, c := range clones {
+		target := audit.Object{Namespace: c.GetNamespace(), Kind: "Deployment", Name: cloneName}
+		switch {
+		case cloneName == name:
+		case cloneName == previous:
+			if c.Spec.Replicas != nil && *c.Spec.Replicas == 0 {
+				continue
+			}
+			original := c.DeepCopy()
+			zero := int32(0)
+			c.Spec.Replicas = &zero
+			if err := h.update(ctx, c, original, target, desired, []audit.Mutation{audit.ScaledDown}); err != nil {
+				return false, errors.Wrapf(err, "error scaling down Deployment %s", cloneName)
+			}
+		default:
+			if err := h.deleteObject(ctx, c, target, desired, audit.CloneDeleted); err != nil && !apierrors.IsNotFound(err) {
+				return false, errors.Wrapf(err, "error deleting Deployment %s", cloneName)
+			}
+		}
+	}
+	return true, nil
+}
+
+// scaleDownOriginal scales the Deployment down once a copy serves its
+// configuration, recording its replicas for the copies
+func scaleDownOriginal(desired podController) {
+	d := desired.GetObject().(*appsv1.Deployment)
+	if d.Spec.Replicas != nil && *d.Spec.Replicas == 0 {
+		return
+	}
+	annotations := d.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[blueGreenReplicasAnnotation] = strconv.Itoa(int(replicasOf(d)))
+	d.SetAnnotations(annotations)
+	zero := int32(0)
+	d.Spec.Replicas = &zero
+}
+
+// getClones returns the copies of the Deployment, keyed on their name
+func (h *Handler) getClones(ctx context.Context, d *appsv1.Deployment) (map[string]*appsv1.Deployment, error) {
+	list := &appsv1.DeploymentList{}
+	err := h.List(ctx, list, client.InNamespace(d.GetNamespace()), client.MatchingLabels{BlueGreenOfLabel: d.GetName()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing copies of Deployment %s", d.GetName())
+	}
+	clones := make(map[string]*appsv1.Deployment)
+	for i := range list.Items {
+		if owner := metav1.GetControllerOf(&list.Items[i]); owner != nil && owner.UID == d.GetUID() {
+			clones[list.Items[i].GetName()] = &list.Items[i]
+		}
+	}
+	return clones, nil
+}
+
+// newClone returns a copy of the Deployment running the configuration hash,
+// whose Pods are selected by the BlueGreenHashLabel
+func newClone(d *appsv1.Deployment, hash string, replicas int32) *appsv1.Deployment {
+	labels := map[string]string{BlueGreenOfLabel: d.GetName()}
+	for key, value := range d.GetLabels() {
+		labels[key] = value
+	}
+	clone := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       d.GetNamespace(),
+			Name:            cloneName(d, hash),
+			Labels:          labels,
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: *d.Spec.DeepCopy(),
+	}
+	clone.Spec.Replicas = &replicas
+	if clone.Spec.Selector == nil {
+		clone.Spec.Selector = &metav1.LabelSelector{}
+	}
+	if clone.Spec.Selector.MatchLabels == nil {
+		clone.Spec.Selector.MatchLabels = make(map[string]string)
+	}
+	clone.Spec.Selector.MatchLabels[BlueGreenHashLabel] = shortHash(hash)
+	if clone.Spec.Template.Labels == nil {
+		clone.Spec.Template.Labels = make(map[string]string)
+	}
+	clone.Spec.Template.Labels[BlueGreenHashLabel] = shortHash(hash)
+	setConfigHash(&deployment{clone}, hash)
+	return clone
+}
+
+// blueGreenReplicas returns the replicas of a new copy of the Deployment:
+// those of the copy serving its current configuration, if any, or else those
+// of the Deployment before it was scaled down
+func blueGreenReplicas(d *appsv1.Deployment, current *appsv1.Deployment) int32 {
+	if current != nil && current.Spec.Replicas != nil && *current.Spec.Replicas > 0 {
+		return *current.Spec.Replicas
+	}
+	if d.Spec.Replicas == nil || *d.Spec.Replicas > 0 {
+		return replicasOf(d)
+	}
+	if replicas, err := strconv.Atoi(d.GetAnnotations()[blueGreenReplicasAnnotation]); err == nil && replicas > 0 {
+		return int32(replicas)
+	}
+	return 1
+}
+
+// replicasOf returns the desired replicas of the Deployment
+func replicasOf(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
+
+// cloneName returns the name of the copy of the Deployment running the
+// configuration hash
+func cloneName(d *appsv1.Deployment, hash string) string {
+	return fmt.Sprintf("%s-%s", d.GetName(), shortHash(hash))
+}
+
+// shortHash returns the prefix of the configuration hash identifying copies
+func shortHash(hash string) string {
+	if len(hash) > blueGreenHashLength {
+		return hash[:blueGreenHashLength]
+	}
+	return hash
+}
+
+// withConfigHash returns a copy of the instance with the configuration hash,
+// describing the rollout in Events and audit records
+func withConfigHash(instance podController, hash string) podController {
+	desired := instance.DeepCopy()
+	setConfigHash(desired, hash)
+	return desired
+}
@@ -0,0 +1,196 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave blue/green Suite", func() {
+	var c client.Client
+	var h *Handler
+
+	getDeployment := func(name string) *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment("api"))
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	// clones returns the names of the copies of the Deployment
+	clones := func() []string {
+		list := &appsv1.DeploymentList{}
+		Expect(c.List(context.TODO(), list, client.MatchingLabels{BlueGreenOfLabel: "api"})).To(Succeed())
+		names := []string{}
+		for _, d := range list.Items {
+			names = append(names, d.GetName())
+		}
+		return names
+	}
+
+	// makeReady sets the status of the copy as the Deployment controller
+	// would once every Pod is available
+	makeReady := func(name string) {
+		d := getDeployment(name)
+		d.Status.Replicas = *d.Spec.Replicas
+		d.Status.UpdatedReplicas = *d.Spec.Replicas
+		d.Status.AvailableReplicas = *d.Spec.Replicas
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	selector := func() map[string]string {
+		s := &corev1.Service{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "api"}, s)).To(Succeed())
+		return s.Spec.Selector
+	}
+
+	changeConfig := func(value string) {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "api"}, cm)).To(Succeed())
+		cm.Data["key"] = value
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		replicas := int32(3)
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "api",
+				UID:       types.UID("api"),
+				Labels:    map[string]string{"app": "api"},
+				Annotations: map[string]string{
+					RequiredAnnotation:         "true",
+					RestartStrategyAnnotation:  "blue-green",
+					BlueGreenServiceAnnotation: "api",
+				},
+			},
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+				Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "api"}},
+			},
+		}
+		d.Spec.Template.Labels = map[string]string{"app": "api"}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "api",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "api"}},
+			}},
+		}}
+
+		c = fake.NewFakeClient(
+			d,
+			&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"}, Data: map[string]string{"key": "a"}},
+			&corev1.Service{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "api"},
+				Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "api"}},
+			},
+		)
+		h = NewHandler(c, record.NewFakeRecorder(100), WithBlueGreenRollouts(BlueGreenOptions{RetryInterval: 10 * time.Second}))
+	})
+
+	It("switches the Service to a copy once it is ready", func() {
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(clones()).To(HaveLen(1))
+		first := getDeployment(clones()[0])
+		hash := getConfigHash(&deployment{first})
+		Expect(first.GetName()).To(Equal("api-" + hash[:10]))
+		Expect(*first.Spec.Replicas).To(Equal(int32(3)))
+		Expect(first.Spec.Selector.MatchLabels).To(HaveKeyWithValue(BlueGreenHashLabel, hash[:10]))
+		Expect(first.Spec.Template.Labels).To(HaveKeyWithValue(BlueGreenHashLabel, hash[:10]))
+		Expect(metav1.GetControllerOf(first).Name).To(Equal("api"))
+
+		// Nothing changes until the copy is ready
+		Expect(getConfigHash(&deployment{getDeployment("api")})).To(BeEmpty())
+		Expect(selector()).NotTo(HaveKey(BlueGreenHashLabel))
+
+		makeReady(first.GetName())
+		Expect(handle()).To(BeZero())
+		Expect(selector()).To(Equal(map[string]string{"app": "api", BlueGreenHashLabel: hash[:10]}))
+		original := getDeployment("api")
+		Expect(getConfigHash(&deployment{original})).To(Equal(hash))
+		Expect(*original.Spec.Replicas).To(BeZero())
+		Expect(original.GetAnnotations()).To(HaveKeyWithValue(blueGreenReplicasAnnotation, "3"))
+	})
+
+	It("scales the previous copy down and deletes older copies", func() {
+		handle()
+		first := clones()[0]
+		makeReady(first)
+		handle()
+
+		changeConfig("b")
+		handle()
+		Expect(clones()).To(HaveLen(2))
+		second := getDeployment(clonesExcept(clones(), first))
+		Expect(*second.Spec.Replicas).To(Equal(int32(3)))
+		makeReady(second.GetName())
+		handle()
+		Expect(selector()).To(HaveKeyWithValue(BlueGreenHashLabel, second.Spec.Selector.MatchLabels[BlueGreenHashLabel]))
+		Expect(*getDeployment(first).Spec.Replicas).To(BeZero())
+
+		changeConfig("c")
+		handle()
+		third := clonesExcept(clones(), first, second.GetName())
+		makeReady(third)
+		handle()
+		err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: first}, &appsv1.Deployment{})
+		Expect(errors.IsNotFound(err)).To(BeTrue())
+		Expect(*getDeployment(second.GetName()).Spec.Replicas).To(BeZero())
+		Expect(*getDeployment(third).Spec.Replicas).To(Equal(int32(3)))
+	})
+
+	It("rolls out Deployments which did not opt in as usual", func() {
+		d := getDeployment("api")
+		delete(d.Annotations, RestartStrategyAnnotation)
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		Expect(handle()).To(BeZero())
+		Expect(clones()).To(BeEmpty())
+		Expect(getConfigHash(&deployment{getDeployment("api")})).NotTo(BeEmpty())
+	})
+})
+
+// clonesExcept returns the first name which is not excluded
+func clonesExcept(names []string, excluded ...string) string {
+	for _, name := range names {
+		found := false
+		for _, e := range excluded {
+			found = found || e == name
+		}
+		if !found {
+			return name
+		}
+	}
+	return ""
+}
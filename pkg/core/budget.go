@@ -0,0 +1,144 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// RolloutBudget limits how many rollouts triggered by Wave may be in flight
+// at once across every controller. A rollout is in flight from the moment
+// Wave updates the configuration hash until the workload has replaced all of
+// its Pods.
+type RolloutBudget struct {
+	max           int
+	retryInterval time.Duration
+
+	mutex    sync.Mutex
+	inFlight map[types.UID]struct{}
+}
+
+// NewRolloutBudget constructs a RolloutBudget allowing at most max rollouts
+// in flight. Rollouts which must wait are retried after retryInterval.
+func NewRolloutBudget(max int, retryInterval time.Duration) *RolloutBudget {
+	return &RolloutBudget{
+		max:           max,
+		retryInterval: retryInterval,
+		inFlight:      make(map[types.UID]struct{}),
+	}
+}
+
+// WithRolloutBudget configures the Handler to share the RolloutBudget with
+// the Handlers of other controllers. A nil budget or a budget allowing no
+// rollouts disables the limit.
+func WithRolloutBudget(b *RolloutBudget) Option {
+	return func(h *Handler) {
+		if b != nil && b.max > 0 {
+			h.budget = b
+		}
+	}
+}
+
+// acquire takes a slot of the budget for the owner, returning false if every
+// slot is taken by other owners
+func (b *RolloutBudget) acquire(owner types.UID) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.inFlight[owner]; ok {
+		return true
+	}
+	if len(b.inFlight) >= b.max {
+		return false
+	}
+	b.inFlight[owner] = struct{}{}
+	return true
+}
+
+// release returns the slot held by the owner, if any
+func (b *RolloutBudget) release(owner types.UID) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.inFlight, owner)
+}
+
+// size returns the number of rollouts in flight
+func (b *RolloutBudget) size() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return len(b.inFlight)
+}
+
+// checkBudget checks whether the rollout of the instance may start within the
+// rollout budget and returns the time to wait before trying again
+func (h *Handler) checkBudget(instance podController, hash string, changes []sourceChange) time.Duration {
+	if h.budget == nil || h.budget.acquire(instance.GetUID()) {
+		return 0
+	}
+	reason := fmt.Sprintf("%d rollouts in flight", h.budget.size())
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Deferring rollout", "namespace", instance.GetNamespace(), "name", instance.GetName(), "reason", reason, "wait", h.budget.retryInterval.String())
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutDeferred", "Rollout deferred for %s: %s", h.budget.retryInterval, reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return h.budget.retryInterval
+}
+
+// releaseBudget returns the slot held by the instance once its rollout is
+// complete
+func (h *Handler) releaseBudget(instance podController) {
+	if h.budget != nil && h.rolloutComplete(instance) {
+		h.budget.release(instance.GetUID())
+	}
+}
+
+// rolloutComplete returns true if the workload's controller has observed its
+// latest spec and every desired Pod is updated and available. Workloads using
+// the OnDelete update strategy are complete once observed, unless Wave
+// deletes their outdated Pods.
+func (h *Handler) rolloutComplete(instance podController) bool {
+	onDelete := usesOnDelete(instance) && h.onDelete == nil
+	switch o := instance.GetObject().(type) {
+	case *appsv1.Deployment:
+		replicas := int32(1)
+		if o.Spec.Replicas != nil {
+			replicas = *o.Spec.Replicas
+		}
+		return o.Status.ObservedGeneration >= o.Generation &&
+			o.Status.UpdatedReplicas >= replicas &&
+			o.Status.Replicas == o.Status.UpdatedReplicas &&
+			o.Status.AvailableReplicas >= o.Status.UpdatedReplicas
+	case *appsv1.StatefulSet:
+		replicas := int32(1)
+		if o.Spec.Replicas != nil {
+			replicas = *o.Spec.Replicas
+		}
+		return o.Status.ObservedGeneration >= o.Generation &&
+			(onDelete || o.Status.UpdatedReplicas >= replicas && o.Status.ReadyReplicas >= replicas)
+	case *appsv1.DaemonSet:
+		return o.Status.ObservedGeneration >= o.Generation &&
+			(onDelete || o.Status.UpdatedNumberScheduled >= o.Status.DesiredNumberScheduled &&
+				o.Status.NumberAvailable >= o.Status.DesiredNumberScheduled)
+	}
+	return true
+}
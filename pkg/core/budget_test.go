@@ -0,0 +1,138 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave rollout budget Suite", func() {
+	var c client.Client
+	var budget *RolloutBudget
+	var deployments, statefulsets *Handler
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetUID(types.UID("deployment"))
+		s := utils.ExampleStatefulSet.DeepCopy()
+		s.SetUID(types.UID("statefulset"))
+		objs := []podController{&deployment{d}, &statefulset{s}}
+		for _, obj := range objs {
+			obj.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+			podTemplate := obj.GetPodTemplate()
+			podTemplate.Spec.Volumes = nil
+			podTemplate.Spec.Containers = []corev1.Container{{
+				Name: "container",
+				EnvFrom: []corev1.EnvFromSource{
+					{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+				},
+			}}
+			obj.SetPodTemplate(podTemplate)
+		}
+		c = fake.NewFakeClient(d, s, utils.ExampleConfigMap1.DeepCopy())
+
+		budget = NewRolloutBudget(1, time.Minute)
+		deployments = NewHandler(c, record.NewFakeRecorder(100), WithRolloutBudget(budget))
+		statefulsets = NewHandler(c, record.NewFakeRecorder(100), WithRolloutBudget(budget))
+	})
+
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+
+	reconcileDeployment := func() (*appsv1.Deployment, time.Duration) {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		result, err := deployments.HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+		updated := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, updated)).To(Succeed())
+		return updated, result.RequeueAfter
+	}
+
+	reconcileStatefulSet := func() (*appsv1.StatefulSet, time.Duration) {
+		s := &appsv1.StatefulSet{}
+		Expect(c.Get(context.TODO(), key, s)).To(Succeed())
+		result, err := statefulsets.HandleStatefulSet(context.TODO(), s)
+		Expect(err).NotTo(HaveOccurred())
+		updated := &appsv1.StatefulSet{}
+		Expect(c.Get(context.TODO(), key, updated)).To(Succeed())
+		return updated, result.RequeueAfter
+	}
+
+	It("is disabled without slots", func() {
+		h := NewHandler(c, record.NewFakeRecorder(10), WithRolloutBudget(NewRolloutBudget(0, time.Minute)))
+		Expect(h.budget).To(BeNil())
+	})
+
+	It("defers rollouts while the budget is used by other controllers", func() {
+		d, wait := reconcileDeployment()
+		Expect(wait).To(BeZero())
+		Expect(getConfigHash(&deployment{d})).NotTo(BeEmpty())
+
+		s, wait := reconcileStatefulSet()
+		Expect(wait).To(Equal(time.Minute))
+		Expect(getConfigHash(&statefulset{s})).To(BeEmpty())
+
+		// The Deployment has not rolled out yet
+		reconcileDeployment()
+		_, wait = reconcileStatefulSet()
+		Expect(wait).To(Equal(time.Minute))
+	})
+
+	It("admits deferred rollouts once the budget is released", func() {
+		reconcileDeployment()
+		reconcileStatefulSet()
+
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		d.Status.Replicas = 1
+		d.Status.UpdatedReplicas = 1
+		d.Status.AvailableReplicas = 1
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		reconcileDeployment()
+		Expect(budget.size()).To(BeZero())
+
+		s, wait := reconcileStatefulSet()
+		Expect(wait).To(BeZero())
+		Expect(getConfigHash(&statefulset{s})).NotTo(BeEmpty())
+	})
+
+	It("releases the budget when the workload is forgotten", func() {
+		d, _ := reconcileDeployment()
+		deployments.forget(&deployment{d})
+		Expect(budget.size()).To(BeZero())
+	})
+
+	It("considers OnDelete workloads rolled out once observed", func() {
+		s := utils.ExampleStatefulSet.DeepCopy()
+		s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+		Expect(statefulsets.rolloutComplete(&statefulset{s})).To(BeTrue())
+
+		WithOnDeleteRollouts(OnDeleteOptions{MaxUnavailable: 1})(statefulsets)
+		Expect(statefulsets.rolloutComplete(&statefulset{s})).To(BeFalse())
+	})
+})
@@ -0,0 +1,294 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// CanaryAnnotation is the key of an optional annotation on the workload.
+	// With the value "true", Wave runs each new configuration in a single
+	// replica canary Deployment and only rolls it out once the canary is
+	// Ready.
+	CanaryAnnotation = "wave.pusher.com/canary"
+
+	// CanaryProbeURLAnnotation is the key of an optional annotation on the
+	// workload holding a URL the canary must answer with a 2xx status
+	// before the rollout proceeds. A URL without a host, such as
+	// "http://:8080/healthz", is requested from the canary's Pod.
+	CanaryProbeURLAnnotation = "wave.pusher.com/canary-probe-url"
+
+	// CanaryOfLabel is the key of the label on canaries, and their Pods,
+	// naming the workload they were copied from
+	CanaryOfLabel = "wave.pusher.com/canary-of"
+
+	// CanaryHashLabel is the key of the label on canaries, and their Pods,
+	// holding the prefix of the configuration hash they run
+	CanaryHashLabel = "wave.pusher.com/canary-hash"
+
+	// canaryStartedAnnotation records when Wave created a canary
+	canaryStartedAnnotation = "wave.pusher.com/canary-started"
+
+	// canaryFailedAnnotation records why a canary failed
+	canaryFailedAnnotation = "wave.pusher.com/canary-failed"
+)
+
+// CanaryOptions configures the canaries of workloads
+type CanaryOptions struct {
+	// RetryInterval is how long to wait before checking on a canary again
+	RetryInterval time.Duration
+
+	// Timeout is how long a canary may take to become Ready and pass its
+	// probe before it fails
+	Timeout time.Duration
+
+	// ProbeTimeout limits each request of the probe URL
+	ProbeTimeout time.Duration
+}
+
+// WithCanaries allows workloads to opt into evaluating new configurations in
+// a canary with the CanaryAnnotation
+func WithCanaries(o CanaryOptions) Option {
+	return func(h *Handler) {
+		h.canary = &o
+	}
+}
+
+// usesCanary returns true if new configurations of the instance are
+// evaluated in a canary
+func (h *Handler) usesCanary(instance podController) bool {
+	return h.canary != nil && instance.GetAnnotations()[CanaryAnnotation] == "true"
+}
+
+// evaluateCanary runs the configuration hash in a canary of the instance and
+// returns true once it passed, deleting it. Otherwise it returns the time to
+// wait before checking on the canary again. A failed canary is scaled down
+// and defers the rollout until the configuration changes again.
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=create;delete
+// +kubebuilder:rbac:groups=,resources=pods,verbs=get;list;watch
+func (h *Handler) evaluateCanary(ctx context.Context, instance podController, hash string, changes []sourceChange) (bool, time.Duration, error) {
+	canaries, err := h.getCanaries(ctx, instance)
+	if err != nil {
+		return false, 0, err
+	}
+	name := canaryName(instance, hash)
+	desired := withConfigHash(instance, hash)
+
+	// Canaries of other configurations are no longer needed
+	for n, c := range canaries {
+		if n == name {
+			continue
+		}
+		target := audit.Object{Namespace: c.GetNamespace(), Kind: "Deployment", Name: n}
+		if err := h.deleteObject(ctx, c, target, desired, audit.CanaryDeleted); err != nil && !apierrors.IsNotFound(err) {
+			return false, 0, errors.Wrapf(err, "error deleting canary %s", n)
+		}
+	}
+
+	canary, ok := canaries[name]
+	if !ok {
+		canary = newCanary(instance, hash, h.getClock().Now())
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Creating canary", "namespace", instance.GetNamespace(), "name", instance.GetName(), "canary", name, "hash", hash)
+		target := audit.Object{Namespace: canary.GetNamespace(), Kind: "Deployment", Name: name}
+		if err := h.createObject(ctx, canary, target, desired, audit.CanaryCreated); err != nil && !apierrors.IsAlreadyExists(err) {
+			return false, 0, errors.Wrapf(err, "error creating canary %s", name)
+		}
+		return false, h.canary.RetryInterval, nil
+	}
+	if _, failed := canary.GetAnnotations()[canaryFailedAnnotation]; failed {
+		return false, 0, nil
+	}
+
+	pods := &corev1.PodList{}
+	err = h.List(ctx, pods, client.InNamespace(canary.GetNamespace()), client.MatchingLabels(canary.Spec.Selector.MatchLabels))
+	if err != nil {
+		return false, 0, errors.Wrapf(err, "error listing Pods of canary %s", name)
+	}
+	if reason := crashedPod(pods.Items); reason != "" {
+		return false, 0, h.failCanary(ctx, instance, desired, canary, changes, reason)
+	}
+
+	probeErr := fmt.Errorf("canary is not Ready")
+	if h.rolloutComplete(&deployment{canary}) {
+		probeErr = h.probeCanary(ctx, instance, pods.Items)
+	}
+	if probeErr == nil {
+		target := audit.Object{Namespace: canary.GetNamespace(), Kind: "Deployment", Name: name}
+		if err := h.deleteObject(ctx, canary, target, desired, audit.CanaryDeleted); err != nil && !apierrors.IsNotFound(err) {
+			return false, 0, errors.Wrapf(err, "error deleting canary %s", name)
+		}
+		return true, 0, nil
+	}
+
+	started, err := time.Parse(time.RFC3339, canary.GetAnnotations()[canaryStartedAnnotation])
+	if err == nil && h.getClock().Since(started) > h.canary.Timeout {
+		reason := fmt.Sprintf("%v after %s", probeErr, h.canary.Timeout)
+		return false, 0, h.failCanary(ctx, instance, desired, canary, changes, reason)
+	}
+	return false, h.canary.RetryInterval, nil
+}
+
+// failCanary reports the failure of the canary and scales it down, keeping
+// it as a record of the failure which defers the rollout
+func (h *Handler) failCanary(ctx context.Context, instance, desired podController, canary *appsv1.Deployment, changes []sourceChange, reason string) error {
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "CanaryFailed", "Rollout of configuration hash %s held back, canary %s failed: %s", getConfigHash(desired), canary.GetName(), reason)
+	h.recordDecision(audit.Deferred, instance, getConfigHash(desired), changes, fmt.Sprintf("canary failed: %s", reason))
+
+	original := canary.DeepCopy()
+	zero := int32(0)
+	canary.Spec.Replicas = &zero
+	canary.GetAnnotations()[canaryFailedAnnotation] = reason
+	target := audit.Object{Namespace: canary.GetNamespace(), Kind: "Deployment", Name: canary.GetName()}
+	if err := h.update(ctx, canary, original, target, desired, []audit.Mutation{audit.CanaryFailed}); err != nil {
+		return errors.Wrapf(err, "error scaling down canary %s", canary.GetName())
+	}
+	return nil
+}
+
+// probeCanary requests the probe URL of the instance, if any, from the
+// canary's Pod
+func (h *Handler) probeCanary(ctx context.Context, instance podController, pods []corev1.Pod) error {
+	probe := instance.GetAnnotations()[CanaryProbeURLAnnotation]
+	if probe == "" {
+		return nil
+	}
+	u, err := url.Parse(probe)
+	if err != nil {
+		return errors.Wrap(err, "invalid probe URL")
+	}
+	if u.Hostname() == "" {
+		ip := ""
+		for _, pod := range pods {
+			if pod.Status.PodIP != "" && pod.GetDeletionTimestamp() == nil {
+				ip = pod.Status.PodIP
+			}
+		}
+		if ip == "" {
+			return fmt.Errorf("canary Pod has no IP")
+		}
+		u.Host = net.JoinHostPort(ip, u.Port())
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, h.canary.ProbeTimeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "invalid probe URL")
+	}
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "probe failed")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("probe returned %s", resp.Status)
+	}
+	return nil
+}
+
+// crashedPod returns why a container of the Pods failed, or an empty string
+// if none did
+func crashedPod(pods []corev1.Pod) string {
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			switch {
+			case status.RestartCount > 0:
+				return fmt.Sprintf("container %s of Pod %s restarted", status.Name, pod.GetName())
+			case status.State.Waiting != nil && (status.State.Waiting.Reason == "CrashLoopBackOff" || status.State.Waiting.Reason == "CreateContainerConfigError"):
+				return fmt.Sprintf("container %s of Pod %s is in %s: %s", status.Name, pod.GetName(), status.State.Waiting.Reason, status.State.Waiting.Message)
+			case status.State.Terminated != nil && status.State.Terminated.ExitCode != 0:
+				return fmt.Sprintf("container %s of Pod %s exited with code %d", status.Name, pod.GetName(), status.State.Terminated.ExitCode)
+			}
+		}
+	}
+	return ""
+}
+
+// getCanaries returns the canaries of the instance, keyed on their name
+func (h *Handler) getCanaries(ctx context.Context, instance podController) (map[string]*appsv1.Deployment, error) {
+	list := &appsv1.DeploymentList{}
+	err := h.List(ctx, list, client.InNamespace(instance.GetNamespace()), client.MatchingLabels{CanaryOfLabel: instance.GetName()})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing canaries of %s", instance.GetName())
+	}
+	canaries := make(map[string]*appsv1.Deployment)
+	for i := range list.Items {
+		if owner := metav1.GetControllerOf(&list.Items[i]); owner != nil && owner.UID == instance.GetUID() {
+			canaries[list.Items[i].GetName()] = &list.Items[i]
+		}
+	}
+	return canaries, nil
+}
+
+// newCanary returns a single replica Deployment running the Pod template of
+// the instance with the configuration hash. Its Pods are only labelled as
+// canaries so that no Service sends them traffic.
+func newCanary(instance podController, hash string, now time.Time) *appsv1.Deployment {
+	labels := map[string]string{CanaryOfLabel: instance.GetName(), CanaryHashLabel: shortHash(hash)}
+	template := instance.GetPodTemplate().DeepCopy()
+	template.Labels = labels
+	if s, ok := instance.GetObject().(*appsv1.StatefulSet); ok {
+		// The volumes claimed by the StatefulSet are replaced by empty
+		// directories
+		for _, claim := range s.Spec.VolumeClaimTemplates {
+			template.Spec.Volumes = append(template.Spec.Volumes, corev1.Volume{
+				Name:         claim.GetName(),
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			})
+		}
+	}
+
+	one := int32(1)
+	canary := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       instance.GetNamespace(),
+			Name:            canaryName(instance, hash),
+			Labels:          labels,
+			Annotations:     map[string]string{canaryStartedAnnotation: now.UTC().Format(time.RFC3339)},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(instance, appsv1.SchemeGroupVersion.WithKind(kindOf(instance)))},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &one,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: *template,
+		},
+	}
+	setConfigHash(&deployment{canary}, hash)
+	return canary
+}
+
+// canaryName returns the name of the canary of the instance running the
+// configuration hash
+func canaryName(instance podController, hash string) string {
+	return fmt.Sprintf("%s-canary-%s", instance.GetName(), shortHash(hash))
+}
@@ -0,0 +1,212 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave canary Suite", func() {
+	var c client.Client
+	var h *Handler
+	var fakeClock *clock.FakeClock
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	// canaries returns the canaries of the Deployment, keyed on their name
+	canaries := func() map[string]*appsv1.Deployment {
+		list := &appsv1.DeploymentList{}
+		Expect(c.List(context.TODO(), list, client.MatchingLabels{CanaryOfLabel: "example"})).To(Succeed())
+		canaries := make(map[string]*appsv1.Deployment)
+		for i := range list.Items {
+			canaries[list.Items[i].GetName()] = &list.Items[i]
+		}
+		return canaries
+	}
+
+	onlyCanary := func() *appsv1.Deployment {
+		Expect(canaries()).To(HaveLen(1))
+		for _, canary := range canaries() {
+			return canary
+		}
+		return nil
+	}
+
+	// ready sets the status of the canary as the Deployment controller
+	// would once its Pod is available
+	ready := func(canary *appsv1.Deployment) {
+		canary.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+		Expect(c.Update(context.TODO(), canary)).To(Succeed())
+	}
+
+	createPod := func(canary *appsv1.Deployment, status corev1.PodStatus) {
+		Expect(c.Create(context.TODO(), &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: canary.GetName() + "-pod", Labels: canary.Spec.Selector.MatchLabels},
+			Status:     status,
+		})).To(Succeed())
+	}
+
+	setup := func(annotations map[string]string) {
+		annotations[RequiredAnnotation] = "true"
+		annotations[CanaryAnnotation] = "true"
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			UID:         types.UID("example"),
+			Annotations: annotations,
+		}}
+		d.Spec.Template.Labels = map[string]string{"app": "example"}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "example", Image: "example"}}
+		c = fake.NewFakeClient(d)
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+		h = NewHandler(c, record.NewFakeRecorder(100), WithClock(fakeClock), WithCanaries(CanaryOptions{
+			RetryInterval: 10 * time.Second,
+			Timeout:       5 * time.Minute,
+			ProbeTimeout:  time.Second,
+		}))
+	}
+
+	It("rolls out once the canary is Ready", func() {
+		setup(map[string]string{})
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+		canary := onlyCanary()
+		Expect(canary.GetName()).To(MatchRegexp(`^example-canary-[0-9a-f]{10}$`))
+		Expect(*canary.Spec.Replicas).To(Equal(int32(1)))
+		Expect(canary.Spec.Template.Labels).To(Equal(canary.Spec.Selector.MatchLabels))
+		Expect(canary.Spec.Template.Labels).NotTo(HaveKey("app"))
+		Expect(canary.Spec.Template.Spec.Containers[0].Image).To(Equal("example"))
+		Expect(metav1.GetControllerOf(canary).Name).To(Equal("example"))
+
+		// The canary is not Ready yet
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+		ready(canary)
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal(getConfigHash(&deployment{canary})))
+		Expect(canaries()).To(BeEmpty())
+	})
+
+	It("holds back the rollout when the canary crashes", func() {
+		setup(map[string]string{})
+		handle()
+		canary := onlyCanary()
+		createPod(canary, corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+			Name:  "example",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off"}},
+		}}})
+
+		Expect(handle()).To(BeZero())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		canary = onlyCanary()
+		Expect(*canary.Spec.Replicas).To(BeZero())
+		Expect(canary.GetAnnotations()[canaryFailedAnnotation]).To(ContainSubstring("CrashLoopBackOff"))
+
+		// The failed canary keeps holding back the rollout even once Ready
+		ready(canary)
+		Expect(handle()).To(BeZero())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+	})
+
+	It("fails the canary when it is not Ready in time", func() {
+		setup(map[string]string{})
+		handle()
+		fakeClock.Step(6 * time.Minute)
+		Expect(handle()).To(BeZero())
+		Expect(onlyCanary().GetAnnotations()[canaryFailedAnnotation]).To(ContainSubstring("not Ready"))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+	})
+
+	It("replaces the canary when the configuration changes", func() {
+		setup(map[string]string{})
+		handle()
+		old := onlyCanary().GetName()
+
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data:       map[string]string{"key": "value"},
+		}
+		Expect(c.Create(context.TODO(), cm)).To(Succeed())
+		d := getDeployment()
+		d.Spec.Template.Spec.Containers[0].EnvFrom = []corev1.EnvFromSource{{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example"}},
+		}}
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		handle()
+		Expect(onlyCanary().GetName()).NotTo(Equal(old))
+	})
+
+	Context("with a probe URL", func() {
+		var status int
+		var server *httptest.Server
+
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(status)
+			}))
+			port := server.URL[strings.LastIndex(server.URL, ":"):]
+			setup(map[string]string{CanaryProbeURLAnnotation: "http://" + port + "/healthz"})
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("rolls out once the probe of the canary's Pod passes", func() {
+			handle()
+			canary := onlyCanary()
+			createPod(canary, corev1.PodStatus{PodIP: "127.0.0.1"})
+			ready(canary)
+
+			Expect(handle()).To(Equal(10 * time.Second))
+			Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+			status = http.StatusOK
+			handle()
+			Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+			Expect(canaries()).To(BeEmpty())
+		})
+	})
+})
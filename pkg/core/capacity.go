@@ -0,0 +1,293 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// CapacityOptions configures how Wave spreads the rollouts it triggers so
+// that restarting many workloads at once does not cause a large scale-up of
+// the cluster
+type CapacityOptions struct {
+	// SpreadInterval is the minimum time between two rollouts triggered by
+	// Wave. Zero disables spreading.
+	SpreadInterval time.Duration
+
+	// PodStartupTime is the time a Pod is expected to take to start and
+	// become ready. When set, the time between the start of a rollout and the
+	// next one is extended to the time the rollout is estimated to take, from
+	// the replicas of the workload, how many of them its update strategy
+	// replaces at once and its minReadySeconds. Zero spreads every rollout by
+	// SpreadInterval.
+	PodStartupTime time.Duration
+
+	// MaxPendingPods defers rollouts while more than this many Pods in the
+	// cluster are Pending. Zero disables the check.
+	MaxPendingPods int
+
+	// MinHeadroomPercent defers rollouts while the share of allocatable CPU or
+	// memory on schedulable nodes that is not requested by Pods is below this
+	// percentage. Zero disables the check.
+	MinHeadroomPercent int
+
+	// RetryInterval is how long to wait before checking the capacity of the
+	// cluster again after deferring a rollout
+	RetryInterval time.Duration
+}
+
+// enabled returns true if any capacity-aware behaviour is configured
+func (o CapacityOptions) enabled() bool {
+	return o.SpreadInterval > 0 || o.PodStartupTime > 0 || o.MaxPendingPods > 0 || o.MinHeadroomPercent > 0
+}
+
+// rolloutGate decides when a rollout triggered by Wave may proceed
+type rolloutGate struct {
+	client  client.Client
+	options CapacityOptions
+
+	// estimate returns how long the rollout of a workload is expected to
+	// take, if set
+	estimate func(podController) time.Duration
+
+	mutex sync.Mutex
+	// next is the earliest time at which an unreserved rollout may start
+	next time.Time
+	// reservations holds the start time allocated to each deferred rollout
+	reservations map[types.UID]time.Time
+}
+
+// newRolloutGate constructs a rolloutGate using the given options
+func newRolloutGate(c client.Client, options CapacityOptions) *rolloutGate {
+	return &rolloutGate{
+		client:       c,
+		options:      options,
+		reservations: make(map[types.UID]time.Time),
+	}
+}
+
+// admit determines whether the rollout of the instance may start now.
+// If the rollout must be deferred, the time to wait and the reason for
+// deferral are returned.
+func (g *rolloutGate) admit(ctx context.Context, obj podController, now time.Time) (time.Duration, string, error) {
+	// Restore the start time recorded on the instance by a previous run
+	spacing := g.spacing(obj)
+	if pending, ok := getPendingRollout(obj); ok {
+		g.restore(obj.GetUID(), pending.NotBefore, spacing)
+	}
+
+	// A rollout that has already been allocated a start time only needs to
+	// wait until then
+	if wait, ok := g.reserved(obj.GetUID(), now); ok {
+		if wait > 0 {
+			return wait, "waiting for spread interval", nil
+		}
+		return 0, "", nil
+	}
+
+	constrained, reason, err := g.capacityConstrained(ctx)
+	if err != nil {
+		return 0, "", errors.Wrap(err, "error checking cluster capacity")
+	}
+	if constrained {
+		return g.options.RetryInterval, reason, nil
+	}
+
+	if wait := g.reserve(obj.GetUID(), now, spacing); wait > 0 {
+		return wait, "waiting for spread interval", nil
+	}
+	return 0, "", nil
+}
+
+// reserved returns the time until the reservation for the owner starts, if
+// one exists. Reservations are removed once they have started.
+func (g *rolloutGate) reserved(owner types.UID, now time.Time) (time.Duration, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	start, ok := g.reservations[owner]
+	if !ok {
+		return 0, false
+	}
+	if !now.Before(start) {
+		delete(g.reservations, owner)
+		return 0, true
+	}
+	return start.Sub(now), true
+}
+
+// spacing returns the time to leave between the start of the rollout of the
+// workload and the next one: the SpreadInterval, or the time the rollout is
+// estimated to take if that is longer
+func (g *rolloutGate) spacing(obj podController) time.Duration {
+	spacing := g.options.SpreadInterval
+	if g.estimate != nil {
+		if estimate := g.estimate(obj); estimate > spacing {
+			spacing = estimate
+		}
+	}
+	return spacing
+}
+
+// reserve allocates the next free start time to the owner and returns how long
+// the owner must wait for it. The next start time is spacing later.
+func (g *rolloutGate) reserve(owner types.UID, now time.Time, spacing time.Duration) time.Duration {
+	if spacing <= 0 {
+		return 0
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	start := g.next
+	if start.Before(now) {
+		start = now
+	}
+	g.next = start.Add(spacing)
+
+	if start.After(now) {
+		g.reservations[owner] = start
+		return start.Sub(now)
+	}
+	return 0
+}
+
+// restore reserves the start time for the owner unless it already holds an
+// earlier reservation, and makes sure later reservations are spread spacing
+// after it. A recorded start time is only earlier than the reservation when it
+// was released through the admin API.
+func (g *rolloutGate) restore(owner types.UID, start time.Time, spacing time.Duration) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if reserved, ok := g.reservations[owner]; ok && !start.Before(reserved) {
+		return
+	}
+	g.reservations[owner] = start
+	if next := start.Add(spacing); g.next.Before(next) {
+		g.next = next
+	}
+}
+
+// reservation returns the start time reserved for the owner, if any
+func (g *rolloutGate) reservation(owner types.UID) (time.Time, bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	start, ok := g.reservations[owner]
+	return start, ok
+}
+
+// forget removes any reservation held by the owner
+func (g *rolloutGate) forget(owner types.UID) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.reservations, owner)
+}
+
+// capacityConstrained checks the number of Pending Pods and the headroom on
+// the cluster's nodes against the configured limits
+// +kubebuilder:rbac:groups=,resources=pods;nodes,verbs=get;list;watch
+func (g *rolloutGate) capacityConstrained(ctx context.Context) (bool, string, error) {
+	if g.options.MaxPendingPods <= 0 && g.options.MinHeadroomPercent <= 0 {
+		return false, "", nil
+	}
+
+	pods := &corev1.PodList{}
+	if err := g.client.List(ctx, pods); err != nil {
+		return false, "", errors.Wrap(err, "error listing Pods")
+	}
+
+	if g.options.MaxPendingPods > 0 {
+		pending := 0
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodPending {
+				pending++
+			}
+		}
+		if pending > g.options.MaxPendingPods {
+			return true, fmt.Sprintf("%d Pods are pending", pending), nil
+		}
+	}
+
+	if g.options.MinHeadroomPercent > 0 {
+		nodes := &corev1.NodeList{}
+		if err := g.client.List(ctx, nodes); err != nil {
+			return false, "", errors.Wrap(err, "error listing Nodes")
+		}
+		headroom := calculateHeadroomPercent(nodes.Items, pods.Items)
+		if headroom < g.options.MinHeadroomPercent {
+			return true, fmt.Sprintf("cluster headroom is %d%%", headroom), nil
+		}
+	}
+
+	return false, "", nil
+}
+
+// calculateHeadroomPercent returns the smaller of the percentages of
+// allocatable CPU and memory on schedulable, ready nodes that are not
+// requested by Pods running on those nodes
+func calculateHeadroomPercent(nodes []corev1.Node, pods []corev1.Pod) int {
+	var allocatableCPU, allocatableMemory int64
+	schedulable := make(map[string]struct{})
+	for _, node := range nodes {
+		if node.Spec.Unschedulable || !isNodeReady(node) {
+			continue
+		}
+		schedulable[node.GetName()] = struct{}{}
+		allocatableCPU += node.Status.Allocatable.Cpu().MilliValue()
+		allocatableMemory += node.Status.Allocatable.Memory().Value()
+	}
+	if allocatableCPU == 0 || allocatableMemory == 0 {
+		return 0
+	}
+
+	var requestedCPU, requestedMemory int64
+	for _, pod := range pods {
+		if _, ok := schedulable[pod.Spec.NodeName]; !ok {
+			continue
+		}
+		if pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			requestedCPU += container.Resources.Requests.Cpu().MilliValue()
+			requestedMemory += container.Resources.Requests.Memory().Value()
+		}
+	}
+
+	cpu := (allocatableCPU - requestedCPU) * 100 / allocatableCPU
+	memory := (allocatableMemory - requestedMemory) * 100 / allocatableMemory
+	if memory < cpu {
+		return int(memory)
+	}
+	return int(cpu)
+}
+
+// isNodeReady returns true if the node's Ready condition is True
+func isNodeReady(node corev1.Node) bool {
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
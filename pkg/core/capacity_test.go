@@ -0,0 +1,146 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Wave capacity Suite", func() {
+	Context("rolloutGate", func() {
+		var gate *rolloutGate
+		var now time.Time
+		var first, second podController
+
+		BeforeEach(func() {
+			gate = newRolloutGate(nil, CapacityOptions{SpreadInterval: time.Minute})
+			now = time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+			d1 := utils.ExampleDeployment.DeepCopy()
+			d1.SetUID(types.UID("first"))
+			first = &deployment{d1}
+			d2 := utils.ExampleDeployment.DeepCopy()
+			d2.SetUID(types.UID("second"))
+			second = &deployment{d2}
+		})
+
+		It("admits the first rollout immediately", func() {
+			wait, _, err := gate.admit(context.TODO(), first, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeZero())
+		})
+
+		It("spreads subsequent rollouts by the spread interval", func() {
+			wait, _, err := gate.admit(context.TODO(), first, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeZero())
+
+			wait, _, err = gate.admit(context.TODO(), second, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(Equal(time.Minute))
+		})
+
+		It("keeps the reserved start time for a deferred rollout", func() {
+			gate.admit(context.TODO(), first, now)
+			gate.admit(context.TODO(), second, now)
+
+			wait, _, err := gate.admit(context.TODO(), second, now.Add(30*time.Second))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(Equal(30 * time.Second))
+
+			wait, _, err = gate.admit(context.TODO(), second, now.Add(time.Minute))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(wait).To(BeZero())
+		})
+
+		It("releases reservations when the owner is forgotten", func() {
+			gate.admit(context.TODO(), first, now)
+			gate.admit(context.TODO(), second, now)
+			gate.forget(second.GetUID())
+			Expect(gate.reservations).To(BeEmpty())
+		})
+	})
+
+	Context("calculateHeadroomPercent", func() {
+		var readyNode = func(name, cpu, memory string) corev1.Node {
+			return corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name},
+				Status: corev1.NodeStatus{
+					Allocatable: corev1.ResourceList{
+						corev1.ResourceCPU:    resource.MustParse(cpu),
+						corev1.ResourceMemory: resource.MustParse(memory),
+					},
+					Conditions: []corev1.NodeCondition{
+						{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+					},
+				},
+			}
+		}
+		var pod = func(node, cpu, memory string) corev1.Pod {
+			return corev1.Pod{
+				Spec: corev1.PodSpec{
+					NodeName: node,
+					Containers: []corev1.Container{
+						{
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse(cpu),
+									corev1.ResourceMemory: resource.MustParse(memory),
+								},
+							},
+						},
+					},
+				},
+				Status: corev1.PodStatus{Phase: corev1.PodRunning},
+			}
+		}
+
+		It("returns the smaller of the CPU and memory headroom", func() {
+			nodes := []corev1.Node{readyNode("a", "4", "8Gi"), readyNode("b", "4", "8Gi")}
+			pods := []corev1.Pod{pod("a", "2", "12Gi")}
+			Expect(calculateHeadroomPercent(nodes, pods)).To(Equal(25))
+		})
+
+		It("ignores unschedulable nodes and the pods on them", func() {
+			cordoned := readyNode("b", "4", "8Gi")
+			cordoned.Spec.Unschedulable = true
+			nodes := []corev1.Node{readyNode("a", "4", "8Gi"), cordoned}
+			pods := []corev1.Pod{pod("a", "1", "2Gi"), pod("b", "4", "8Gi")}
+			Expect(calculateHeadroomPercent(nodes, pods)).To(Equal(75))
+		})
+
+		It("ignores completed pods", func() {
+			nodes := []corev1.Node{readyNode("a", "4", "8Gi")}
+			completed := pod("a", "4", "8Gi")
+			completed.Status.Phase = corev1.PodSucceeded
+			Expect(calculateHeadroomPercent(nodes, []corev1.Pod{completed})).To(Equal(100))
+		})
+
+		It("returns zero when there are no ready nodes", func() {
+			Expect(calculateHeadroomPercent(nil, nil)).To(Equal(0))
+		})
+	})
+})
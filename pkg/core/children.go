@@ -18,10 +18,12 @@ package core
 
 import (
 	"context"
-	"fmt"
+	"sort"
 	"strings"
 
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
@@ -36,6 +38,7 @@ type configMetadata struct {
 	required bool
 	allKeys  bool
 	keys     map[string]struct{}
+	envFrom  []envFromSource
 }
 
 // getResult is returned from the getObject method as a helper struct to be
@@ -44,89 +47,177 @@ type getResult struct {
 	err      error
 	obj      Object
 	metadata configMetadata
+	denied   bool
+
+	// missing holds the required child which does not exist, if any
+	missing *sourceKey
 }
 
 // getCurrentChildren returns a list of all Secrets and ConfigMaps that are
 // referenced in the Deployment's spec.  Any reference to a whole ConfigMap or Secret
 // (i.e. via an EnvFrom or a Volume) will result in one entry in the list, irrespective of
 // whether individual elements are also references (i.e. via an Env entry).
-func (h *Handler) getCurrentChildren(obj podController) ([]configObject, error) {
-	configMaps, secrets := getChildNamesByType(obj)
+// For a Deployment in a shared hash group, the children of every workload of
+// the group are returned.
+func (h *Handler) getCurrentChildren(ctx context.Context, obj podController) ([]configObject, error) {
+	return h.getChildren(ctx, obj, true)
+}
+
+// getChildren returns the children getCurrentChildren returns. Unless report
+// is false, problems with the references are reported as events on the
+// instance, so callers without an event recorder must pass false.
+func (h *Handler) getChildren(ctx context.Context, obj podController, report bool) ([]configObject, error) {
+	children, err := h.getReferencedChildren(ctx, obj, report)
+	if err != nil {
+		return children, err
+	}
+	return h.addGroupChildren(ctx, obj, children)
+}
+
+// getReferencedChildren returns the Secrets and ConfigMaps referenced in the
+// Deployment's spec. Unless report is false, problems with the references are
+// reported as events on the Deployment.
+func (h *Handler) getReferencedChildren(ctx context.Context, obj podController, report bool) ([]configObject, error) {
+	configMaps, secrets := getChildNamesByType(h.withoutSidecars(obj))
+	h.removeSidecarConfigMaps(obj, configMaps)
+	if _, invalid, ok := onlyTracked(obj); ok && len(invalid) > 0 && report {
+		h.recorder.Eventf(obj.GetObject(), corev1.EventTypeWarning, "InvalidOnlyTrack", "Ignoring invalid entries of %s: %s", OnlyTrackAnnotation, strings.Join(invalid, ", "))
+	}
+	if _, unknown := trackedContainers(obj); len(unknown) > 0 && report {
+		h.recorder.Eventf(obj.GetObject(), corev1.EventTypeWarning, "UnknownTrackedContainer", "Containers %s listed in %s do not exist", strings.Join(unknown, ", "), TrackContainersAnnotation)
+	}
+
+	// Never read the Secrets whose names are denied
+	var denied []string
+	for name := range secrets {
+		if h.secretNameDenied(name) {
+			denied = append(denied, name)
+			delete(secrets, name)
+		}
+	}
 
 	// get all of ConfigMaps and Secrets
 	resultsChan := make(chan getResult)
 	for name, metadata := range configMaps {
 		go func(name string, metadata configMetadata) {
-			resultsChan <- h.getConfigMap(obj.GetNamespace(), name, metadata)
+			resultsChan <- h.getConfigMap(ctx, obj.GetNamespace(), name, metadata)
 		}(name, metadata)
 	}
 	for name, metadata := range secrets {
 		go func(name string, metadata configMetadata) {
-			resultsChan <- h.getSecret(obj.GetNamespace(), name, metadata)
+			resultsChan <- h.getSecret(ctx, obj.GetNamespace(), name, metadata)
 		}(name, metadata)
 	}
 
 	// Range over and collect results from the gets
-	var errs []string
+	var errs []error
+	var missing []sourceKey
 	var children []configObject
 	for i := 0; i < len(configMaps)+len(secrets); i++ {
 		result := <-resultsChan
+		if result.missing != nil {
+			missing = append(missing, *result.missing)
+		}
 		if result.err != nil {
-			errs = append(errs, result.err.Error())
+			errs = append(errs, result.err)
+		}
+		if result.denied {
+			denied = append(denied, result.obj.GetName())
+			continue
 		}
 		if result.obj != nil {
+			_, isSecret := result.obj.(*corev1.Secret)
 			children = append(children, configObject{
-				object:   result.obj,
-				required: result.metadata.required,
-				allKeys:  result.metadata.allKeys,
-				keys:     result.metadata.keys,
+				object:       result.obj,
+				required:     result.metadata.required,
+				allKeys:      result.metadata.allKeys,
+				keys:         result.metadata.keys,
+				envFrom:      result.metadata.envFrom,
+				metadataOnly: isSecret && h.secretMetadataOnly,
 			})
 		}
 	}
 
+	// Report the denied Secrets on the instance
+	sort.Strings(denied)
+	for _, name := range denied {
+		if report {
+			h.recorder.Eventf(obj.GetObject(), corev1.EventTypeWarning, "SecretDenied", "Secret %s matches the sensitive Secret deny list and is not tracked", name)
+		}
+	}
+
+	// Apply the instance's policy for required children which were deleted.
+	// With the restart policy they are treated as optional, so the
+	// instance rolls out without them, unless other children failed too.
+	if policy := sourceDeletedPolicy(obj); policy != "" {
+		if deleted := deletedSources(obj, missing); len(deleted) > 0 {
+			if report {
+				h.reportDeletedSources(obj, policy, deleted)
+			}
+			if policy == sourceDeletedRestart && len(deleted) == len(errs) {
+				errs = nil
+			}
+		}
+	}
+
 	// If there were any errors, don't return any children
 	if len(errs) > 0 {
-		return []configObject{}, fmt.Errorf("error(s) encountered when geting children: %s", strings.Join(errs, ", "))
+		return []configObject{}, childErrors{message: "error(s) encountered when geting children", errs: errs}
 	}
 
 	// No errors, return the list of children
-	return children, nil
+	return canonicalChildren(children), nil
 }
 
 // getChildNamesByType parses the Deployment object and returns two maps,
 // the first containing ConfigMap metadata for all referenced ConfigMaps, keyed on the name of the ConfigMap,
-// the second containing Secret metadata for all referenced Secrets, keyed on the name of the Secrets
+// the second containing Secret metadata for all referenced Secrets, keyed on the name of the Secrets.
+// A child referenced several times is required if any reference requires it, whatever the order of
+// the references.
+// When the Deployment has the OnlyTrackAnnotation, only the children it lists are returned,
+// and when it has the TrackContainersAnnotation, only those referenced by the containers it lists.
 func getChildNamesByType(obj podController) (map[string]configMetadata, map[string]configMetadata) {
 	// Create sets for storing the names fo the ConfigMaps/Secrets
 	configMaps := make(map[string]configMetadata)
 	secrets := make(map[string]configMetadata)
+	containers, _ := trackedContainers(obj)
 
-	// Range through all Volumes and check the VolumeSources for ConfigMaps
-	// and Secrets
-	for _, vol := range obj.GetPodTemplate().Spec.Volumes {
+	// Range through all Volumes and check the VolumeSources, including
+	// projected ones, for ConfigMaps and Secrets
+	for _, vol := range trackedVolumes(obj, containers) {
 		if cm := vol.VolumeSource.ConfigMap; cm != nil {
-			configMaps[cm.Name] = configMetadata{required: isRequired(cm.Optional), allKeys: true}
+			configMaps[cm.Name] = mergeMetadata(configMaps[cm.Name], configMetadata{required: isRequired(cm.Optional), allKeys: true})
 		}
 		if s := vol.VolumeSource.Secret; s != nil {
-			secrets[s.SecretName] = configMetadata{required: isRequired(s.Optional), allKeys: true}
+			secrets[s.SecretName] = mergeMetadata(secrets[s.SecretName], configMetadata{required: isRequired(s.Optional), allKeys: true})
+		}
+		if projected := vol.VolumeSource.Projected; projected != nil {
+			for _, source := range projected.Sources {
+				if cm := source.ConfigMap; cm != nil {
+					configMaps[cm.Name] = mergeMetadata(configMaps[cm.Name], configMetadata{required: isRequired(cm.Optional), allKeys: true})
+				}
+				if s := source.Secret; s != nil {
+					secrets[s.Name] = mergeMetadata(secrets[s.Name], configMetadata{required: isRequired(s.Optional), allKeys: true})
+				}
+			}
 		}
 	}
 
 	// Range through all Containers and their respective EnvFrom,
 	// then check the EnvFromSources for ConfigMaps and Secrets
-	for _, container := range obj.GetPodTemplate().Spec.Containers {
+	for _, container := range containers {
 		for _, env := range container.EnvFrom {
 			if cm := env.ConfigMapRef; cm != nil {
-				configMaps[cm.Name] = configMetadata{required: isRequired(cm.Optional), allKeys: true}
+				configMaps[cm.Name] = parseEnvFrom(configMaps[cm.Name], container, env.Prefix, cm.Optional)
 			}
 			if s := env.SecretRef; s != nil {
-				secrets[s.Name] = configMetadata{required: isRequired(s.Optional), allKeys: true}
+				secrets[s.Name] = parseEnvFrom(secrets[s.Name], container, env.Prefix, s.Optional)
 			}
 		}
 	}
 
 	// Range through all Containers and their respective Env
-	for _, container := range obj.GetPodTemplate().Spec.Containers {
+	for _, container := range containers {
 		for _, env := range container.Env {
 			if valFrom := env.ValueFrom; valFrom != nil {
 				if cm := valFrom.ConfigMapKeyRef; cm != nil {
@@ -139,22 +230,79 @@ func getChildNamesByType(obj podController) (map[string]configMetadata, map[stri
 		}
 	}
 
+	if tracked, _, ok := onlyTracked(obj); ok {
+		filterTracked(tracked, "ConfigMap", configMaps)
+		filterTracked(tracked, "Secret", secrets)
+	}
+
 	return configMaps, secrets
 }
 
+// mergeMetadata merges two references to the same child. The result doesn't
+// depend on the order of the references: the child is required if either
+// requires it, and uses the keys and envFrom sources of both unless either
+// uses all of its keys.
+func mergeMetadata(a, b configMetadata) configMetadata {
+	merged := configMetadata{required: a.required || b.required, allKeys: a.allKeys || b.allKeys}
+	if merged.allKeys {
+		return merged
+	}
+	for _, metadata := range []configMetadata{a, b} {
+		for key := range metadata.keys {
+			if merged.keys == nil {
+				merged.keys = make(map[string]struct{})
+			}
+			merged.keys[key] = struct{}{}
+		}
+		for _, source := range metadata.envFrom {
+			merged.envFrom = appendEnvFrom(merged.envFrom, source)
+		}
+	}
+	return merged
+}
+
+// canonicalChildren merges the children which are the same object, by UID,
+// and sorts them by kind, ConfigMaps first, and name, so that the children
+// of a workload are the same however they were collected
+func canonicalChildren(children []configObject) []configObject {
+	merged := make(map[types.UID]int)
+	var canonical []configObject
+	for _, child := range children {
+		uid := child.object.GetUID()
+		if uid == "" {
+			// Objects read from fake clients have no UID
+			uid = types.UID(sourceKeyOf(child.object).String())
+		}
+		if i, ok := merged[uid]; ok {
+			canonical[i] = mergeChildren(canonical[i], child)
+			continue
+		}
+		merged[uid] = len(canonical)
+		canonical = append(canonical, child)
+	}
+	sort.SliceStable(canonical, func(i, j int) bool {
+		a, b := sourceKeyOf(canonical[i].object), sourceKeyOf(canonical[j].object)
+		if a.kind != b.kind {
+			return a.kind < b.kind
+		}
+		return a.name < b.name
+	})
+	return canonical
+}
+
 func isRequired(b *bool) bool {
 	return b == nil || !*b
 }
 
 // parseConfigMapKeyRef updates the metadata for a ConfigMap to include the keys specified in this ConfigMapKeySelector
 func parseConfigMapKeyRef(metadata configMetadata, cm *corev1.ConfigMapKeySelector) configMetadata {
+	if cm.Optional == nil || !*cm.Optional {
+		metadata.required = true
+	}
 	if !metadata.allKeys {
 		if metadata.keys == nil {
 			metadata.keys = make(map[string]struct{})
 		}
-		if cm.Optional == nil || !*cm.Optional {
-			metadata.required = true
-		}
 		metadata.keys[cm.Key] = struct{}{}
 	}
 	return metadata
@@ -162,13 +310,13 @@ func parseConfigMapKeyRef(metadata configMetadata, cm *corev1.ConfigMapKeySelect
 
 // parseSecretKeyRef updates the metadata for a Secret to include the keys specified in this SecretKeySelector
 func parseSecretKeyRef(metadata configMetadata, s *corev1.SecretKeySelector) configMetadata {
+	if s.Optional == nil || !*s.Optional {
+		metadata.required = true
+	}
 	if !metadata.allKeys {
 		if metadata.keys == nil {
 			metadata.keys = make(map[string]struct{})
 		}
-		if s.Optional == nil || !*s.Optional {
-			metadata.required = true
-		}
 		metadata.keys[s.Key] = struct{}{}
 	}
 	return metadata
@@ -176,24 +324,35 @@ func parseSecretKeyRef(metadata configMetadata, s *corev1.SecretKeySelector) con
 
 // getConfigMap gets a ConfigMap with the given name and namespace from the
 // API server.
-func (h *Handler) getConfigMap(namespace, name string, metadata configMetadata) getResult {
-	return h.getObject(namespace, name, metadata, &corev1.ConfigMap{})
+func (h *Handler) getConfigMap(ctx context.Context, namespace, name string, metadata configMetadata) getResult {
+	return h.getObject(ctx, namespace, name, metadata, &corev1.ConfigMap{})
 }
 
 // getSecret gets a Secret with the given name and namespace from the
-// API server.
-func (h *Handler) getSecret(namespace, name string, metadata configMetadata) getResult {
-	return h.getObject(namespace, name, metadata, &corev1.Secret{})
+// API server. Untracked ServiceAccount tokens are left out of the result.
+func (h *Handler) getSecret(ctx context.Context, namespace, name string, metadata configMetadata) getResult {
+	result := h.getObject(ctx, namespace, name, metadata, &corev1.Secret{})
+	if result.obj != nil && h.secretDenied(result.obj) {
+		result.denied = true
+	}
+	if result.obj != nil && !result.denied && h.secretIgnored(result.obj) {
+		return getResult{metadata: metadata}
+	}
+	return result
 }
 
 // getObject gets the Object with the given name and namespace from the API
 // server
-func (h *Handler) getObject(namespace, name string, metadata configMetadata, obj Object) getResult {
+func (h *Handler) getObject(ctx context.Context, namespace, name string, metadata configMetadata, obj Object) getResult {
 	objectName := types.NamespacedName{Namespace: namespace, Name: name}
-	err := h.Get(context.TODO(), objectName, obj)
+	err := h.Get(ctx, objectName, obj)
 	if err != nil {
 		if metadata.required {
-			return getResult{err: err}
+			result := getResult{err: err}
+			if apierrors.IsNotFound(err) {
+				result.missing = &sourceKey{kind: kindOf(obj), name: name}
+			}
+			return result
 		}
 		return getResult{metadata: metadata}
 	}
@@ -202,21 +361,24 @@ func (h *Handler) getObject(namespace, name string, metadata configMetadata, obj
 
 // getExistingChildren returns a list of all Secrets and ConfigMaps that are
 // owned by the Deployment instance
-func (h *Handler) getExistingChildren(obj podController) ([]Object, error) {
+func (h *Handler) getExistingChildren(ctx context.Context, obj podController) ([]Object, error) {
 	inNamespace := client.InNamespace(obj.GetNamespace())
 
 	// List all ConfigMaps in the Deployment's namespace
 	configMaps := &corev1.ConfigMapList{}
-	err := h.List(context.TODO(), configMaps, inNamespace)
+	err := h.List(ctx, configMaps, inNamespace)
 	if err != nil {
-		return []Object{}, fmt.Errorf("error listing ConfigMaps: %v", err)
+		return []Object{}, errors.Wrap(err, "error listing ConfigMaps")
 	}
 
-	// List all Secrets in the Deployment's namespcae
+	// List all Secrets in the Deployment's namespcae, unless Wave only reads
+	// their metadata and so never owns them
 	secrets := &corev1.SecretList{}
-	err = h.List(context.TODO(), secrets, inNamespace)
-	if err != nil {
-		return []Object{}, fmt.Errorf("error listing Secrets: %v", err)
+	if !h.secretMetadataOnly {
+		err = h.List(ctx, secrets, inNamespace)
+		if err != nil {
+			return []Object{}, errors.Wrap(err, "error listing Secrets")
+		}
 	}
 
 	// Iterate over the ConfigMaps/Secrets and add the ones owned by the
@@ -228,7 +390,7 @@ func (h *Handler) getExistingChildren(obj podController) ([]Object, error) {
 		}
 	}
 	for _, s := range secrets.Items {
-		if isOwnedBy(&s, obj) {
+		if isOwnedBy(&s, obj) && !h.secretDenied(&s) {
 			children = append(children, s.DeepCopy())
 		}
 	}
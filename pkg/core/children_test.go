@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -91,7 +92,7 @@ var _ = Describe("Wave children Suite", func() {
 
 		m.Create(deploymentObject).Should(Succeed())
 
-		stopMgr, mgrStopped = StartTestManager(mgr)
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
 
 		// Ensure the caches have synced
 		m.Get(cm1, timeout).Should(Succeed())
@@ -106,8 +107,7 @@ var _ = Describe("Wave children Suite", func() {
 	})
 
 	AfterEach(func() {
-		close(stopMgr)
-		mgrStopped.Wait()
+		utils.StopTestManager(stopMgr, mgrStopped)
 
 		utils.DeleteAll(cfg, timeout,
 			&appsv1.DeploymentList{},
@@ -119,7 +119,7 @@ var _ = Describe("Wave children Suite", func() {
 	Context("getCurrentChildren", func() {
 		BeforeEach(func() {
 			var err error
-			currentChildren, err = h.getCurrentChildren(podControllerDeployment)
+			currentChildren, err = h.getCurrentChildren(context.TODO(), podControllerDeployment)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -132,10 +132,11 @@ var _ = Describe("Wave children Suite", func() {
 		})
 
 		It("returns ConfigMaps referenced in EnvFrom", func() {
+			container := deploymentObject.Spec.Template.Spec.Containers[1]
 			Expect(currentChildren).To(ContainElement(configObject{
 				object:   cm2,
 				required: true,
-				allKeys:  true,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
 			}))
 		})
 
@@ -169,10 +170,11 @@ var _ = Describe("Wave children Suite", func() {
 		})
 
 		It("returns Secrets referenced in EnvFrom", func() {
+			container := deploymentObject.Spec.Template.Spec.Containers[1]
 			Expect(currentChildren).To(ContainElement(configObject{
 				object:   s2,
 				required: true,
-				allKeys:  true,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
 			}))
 		})
 
@@ -206,7 +208,7 @@ var _ = Describe("Wave children Suite", func() {
 			m.Delete(s2).Should(Succeed())
 			m.Get(s2, timeout).ShouldNot(Succeed())
 
-			current, err := h.getCurrentChildren(podControllerDeployment)
+			current, err := h.getCurrentChildren(context.TODO(), podControllerDeployment)
 			Expect(err).To(HaveOccurred())
 			Expect(current).To(BeEmpty())
 		})
@@ -233,11 +235,19 @@ var _ = Describe("Wave children Suite", func() {
 		})
 
 		It("returns ConfigMaps referenced in EnvFrom", func() {
-			Expect(configMaps).To(HaveKeyWithValue(cm2.GetName(), configMetadata{required: true, allKeys: true}))
+			container := deploymentObject.Spec.Template.Spec.Containers[1]
+			Expect(configMaps).To(HaveKeyWithValue(cm2.GetName(), configMetadata{
+				required: true,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
+			}))
 		})
 
 		It("optional ConfigMaps referenced in EnvFrom are returned as optional", func() {
-			Expect(configMaps).To(HaveKeyWithValue("envfrom-optional", configMetadata{required: false, allKeys: true}))
+			container := deploymentObject.Spec.Template.Spec.Containers[0]
+			Expect(configMaps).To(HaveKeyWithValue("envfrom-optional", configMetadata{
+				required: false,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
+			}))
 		})
 
 		It("returns ConfigMaps referenced in Env", func() {
@@ -267,11 +277,19 @@ var _ = Describe("Wave children Suite", func() {
 		})
 
 		It("returns Secrets referenced in EnvFrom", func() {
-			Expect(secrets).To(HaveKeyWithValue(s2.GetName(), configMetadata{required: true, allKeys: true}))
+			container := deploymentObject.Spec.Template.Spec.Containers[1]
+			Expect(secrets).To(HaveKeyWithValue(s2.GetName(), configMetadata{
+				required: true,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
+			}))
 		})
 
 		It("optional Secrets referenced in EnvFrom are returned as optional", func() {
-			Expect(secrets).To(HaveKeyWithValue("envfrom-optional", configMetadata{required: false, allKeys: true}))
+			container := deploymentObject.Spec.Template.Spec.Containers[0]
+			Expect(secrets).To(HaveKeyWithValue("envfrom-optional", configMetadata{
+				required: false,
+				envFrom:  []envFromSource{newEnvFromSource(container, "")},
+			}))
 		})
 
 		It("returns Secrets referenced in Env", func() {
@@ -300,6 +318,23 @@ var _ = Describe("Wave children Suite", func() {
 			Expect(configMaps).To(HaveLen(7))
 			Expect(secrets).To(HaveLen(7))
 		})
+
+		It("returns ConfigMaps and Secrets referenced in projected Volumes", func() {
+			optional := true
+			d := &deployment{utils.ExampleDeployment.DeepCopy()}
+			d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name: "projected",
+				VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{
+						{ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected-cm"}}},
+						{Secret: &corev1.SecretProjection{LocalObjectReference: corev1.LocalObjectReference{Name: "projected-secret"}, Optional: &optional}},
+					},
+				}},
+			}}
+			configMaps, secrets = getChildNamesByType(d)
+			Expect(configMaps).To(HaveKeyWithValue("projected-cm", configMetadata{required: true, allKeys: true}))
+			Expect(secrets).To(HaveKeyWithValue("projected-secret", configMetadata{required: false, allKeys: true}))
+		})
 	})
 
 	Context("getExistingChildren", func() {
@@ -316,7 +351,7 @@ var _ = Describe("Wave children Suite", func() {
 			}
 
 			var err error
-			existingChildren, err = h.getExistingChildren(podControllerDeployment)
+			existingChildren, err = h.getExistingChildren(context.TODO(), podControllerDeployment)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
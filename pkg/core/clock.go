@@ -0,0 +1,41 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"k8s.io/apimachinery/pkg/util/clock"
+)
+
+// Clock tells the time for all time-based behaviour of the Handler.
+// Tests use clock.FakeClock to make that behaviour deterministic.
+type Clock = clock.Clock
+
+// WithClock configures the Handler to use the given Clock instead of the
+// system clock
+func WithClock(c Clock) Option {
+	return func(h *Handler) {
+		h.clock = c
+	}
+}
+
+// getClock returns the Handler's Clock, defaulting to the system clock
+func (h *Handler) getClock() Clock {
+	if h.clock == nil {
+		return clock.RealClock{}
+	}
+	return h.clock
+}
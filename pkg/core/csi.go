@@ -0,0 +1,263 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// CSIVersionsAnnotation is the key of the annotation on the workload that
+	// records the versions of the objects mounted by the CSI Secrets Store
+	// driver which Wave has seen, most recent last
+	CSIVersionsAnnotation = "wave.pusher.com/csi-versions"
+
+	// csiSecretsStoreDriver is the name of the CSI Secrets Store driver
+	csiSecretsStoreDriver = "secrets-store.csi.k8s.io"
+
+	// csiVersionHistory is the number of versions of each object recorded
+	csiVersionHistory = 5
+)
+
+// SecretProviderClassPodStatusGVK is the kind of the objects the CSI Secrets
+// Store driver reports the versions of the objects it mounted into a Pod in
+var SecretProviderClassPodStatusGVK = schema.GroupVersionKind{
+	Group:   "secrets-store.csi.x-k8s.io",
+	Version: "v1alpha1",
+	Kind:    "SecretProviderClassPodStatus",
+}
+
+// WithCSISecretsStore configures the Handler to include the versions of the
+// objects the CSI Secrets Store driver mounts into a workload's Pods in its
+// configuration hash, so that rotating them restarts the workload even when
+// they are not synced to a Secret
+func WithCSISecretsStore() Option {
+	return func(h *Handler) {
+		h.csiSecretsStore = true
+	}
+}
+
+// csiVersions maps "<SecretProviderClass>/<object ID>" to versions
+type csiVersions map[string][]string
+
+// getSecretProviderClasses returns the names of the SecretProviderClasses
+// mounted by the workload's pod template
+func getSecretProviderClasses(obj podController) map[string]struct{} {
+	classes := make(map[string]struct{})
+	for _, vol := range obj.GetPodTemplate().Spec.Volumes {
+		if vol.CSI != nil && vol.CSI.Driver == csiSecretsStoreDriver && vol.CSI.VolumeAttributes["secretProviderClass"] != "" {
+			classes[vol.CSI.VolumeAttributes["secretProviderClass"]] = struct{}{}
+		}
+	}
+	return classes
+}
+
+// getCSIVersions returns the versions of the objects the CSI Secrets Store
+// driver reports having mounted into the Pods of the workload
+// +kubebuilder:rbac:groups=secrets-store.csi.x-k8s.io,resources=secretproviderclasspodstatuses,verbs=get;list;watch
+// +kubebuilder:rbac:groups=,resources=pods,verbs=get;list;watch
+func (h *Handler) getCSIVersions(ctx context.Context, obj podController) (csiVersions, error) {
+	reported := csiVersions{}
+	classes := getSecretProviderClasses(obj)
+	if !h.csiSecretsStore || len(classes) == 0 {
+		return reported, nil
+	}
+
+	selector, err := metav1.LabelSelectorAsSelector(getSelector(obj))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid selector")
+	}
+	pods := &corev1.PodList{}
+	if err := h.List(ctx, pods, client.InNamespace(obj.GetNamespace()), client.MatchingLabelsSelector{Selector: selector}); err != nil {
+		return nil, errors.Wrap(err, "error listing Pods")
+	}
+	podNames := make(map[string]struct{})
+	for _, pod := range pods.Items {
+		podNames[pod.GetName()] = struct{}{}
+	}
+
+	statuses := &unstructured.UnstructuredList{}
+	statuses.SetGroupVersionKind(SecretProviderClassPodStatusGVK.GroupVersion().WithKind(SecretProviderClassPodStatusGVK.Kind + "List"))
+	if err := h.List(ctx, statuses, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil, errors.Wrap(err, "error listing SecretProviderClassPodStatuses")
+	}
+	for _, status := range statuses.Items {
+		podName, _, _ := unstructured.NestedString(status.Object, "status", "podName")
+		class, _, _ := unstructured.NestedString(status.Object, "status", "secretProviderClassName")
+		if _, ok := podNames[podName]; !ok {
+			continue
+		}
+		if _, ok := classes[class]; !ok {
+			continue
+		}
+		objects, _, _ := unstructured.NestedSlice(status.Object, "status", "objects")
+		for _, o := range objects {
+			object, ok := o.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			id, _, _ := unstructured.NestedString(object, "id")
+			version, _, _ := unstructured.NestedString(object, "version")
+			key := class + "/" + id
+			reported[key] = append(reported[key], version)
+		}
+	}
+	return reported, nil
+}
+
+// updateCSIVersions returns the versions recorded on the workload, updated
+// with the reported versions, and the most recent version of each object.
+// Versions are only ever appended, so that Pods which have not picked up a
+// rotation yet never roll the hash back to an older version.
+func updateCSIVersions(obj podController, reported csiVersions) (csiVersions, map[string]string) {
+	recorded := csiVersions{}
+	if value, ok := obj.GetAnnotations()[CSIVersionsAnnotation]; ok {
+		if err := json.Unmarshal([]byte(value), &recorded); err != nil {
+			recorded = csiVersions{}
+		}
+	}
+
+	classes := getSecretProviderClasses(obj)
+	history := csiVersions{}
+	latest := make(map[string]string)
+	for key, versions := range recorded {
+		if _, ok := classes[strings.SplitN(key, "/", 2)[0]]; ok {
+			history[key] = versions
+		}
+	}
+	for key, versions := range reported {
+		seen := make(map[string]struct{})
+		for _, version := range history[key] {
+			seen[version] = struct{}{}
+		}
+		var unseen []string
+		for _, version := range versions {
+			if _, ok := seen[version]; !ok {
+				seen[version] = struct{}{}
+				unseen = append(unseen, version)
+			}
+		}
+		sort.Strings(unseen)
+		history[key] = append(history[key], unseen...)
+		if len(history[key]) > csiVersionHistory {
+			history[key] = history[key][len(history[key])-csiVersionHistory:]
+		}
+	}
+	for key, versions := range history {
+		if len(versions) > 0 {
+			latest[key] = versions[len(versions)-1]
+		}
+	}
+	return history, latest
+}
+
+// setCSIVersions records the versions seen on the workload
+func setCSIVersions(obj podController, history csiVersions) error {
+	annotations := obj.GetAnnotations()
+	if len(history) == 0 {
+		if _, ok := annotations[CSIVersionsAnnotation]; ok {
+			delete(annotations, CSIVersionsAnnotation)
+			obj.SetAnnotations(annotations)
+		}
+		return nil
+	}
+	value, err := json.Marshal(history)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[CSIVersionsAnnotation] = string(value)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// applyVersions mixes versions, such as the most recent version of each
+// object mounted by the CSI Secrets Store driver, into the hash
+func applyVersions(hash string, latest map[string]string) string {
+	if len(latest) == 0 {
+		return hash
+	}
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	data := hash
+	for _, key := range keys {
+		data += fmt.Sprintf(";%s=%s", key, latest[key])
+	}
+	hashBytes := sha256.Sum256([]byte(data))
+	return fmt.Sprintf("%x", hashBytes)
+}
+
+// SecretProviderClassPodStatusConsumers returns a ToRequestsFunc which maps a
+// SecretProviderClassPodStatus to the workloads of the given kind that have
+// the required annotation and whose selector matches its Pod
+func SecretProviderClassPodStatusConsumers(c client.Client, kind string) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		log := logf.Log.WithName("wave")
+		status, ok := o.Object.(*unstructured.Unstructured)
+		if !ok {
+			return nil
+		}
+		podName, _, _ := unstructured.NestedString(status.Object, "status", "podName")
+		pod := &corev1.Pod{}
+		if err := c.Get(context.TODO(), types.NamespacedName{Namespace: o.Meta.GetNamespace(), Name: podName}, pod); err != nil {
+			return nil
+		}
+
+		workloads, err := ListWorkloads(context.TODO(), c, o.Meta.GetNamespace(), WorkloadKinds{kind})
+		if err != nil {
+			log.Error(err, "Unable to list workloads of Pod", "namespace", o.Meta.GetNamespace(), "name", podName)
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, obj := range workloads {
+			instance, err := asPodController(obj)
+			if err != nil || kindOf(instance) != kind || !hasRequiredAnnotation(instance) {
+				continue
+			}
+			selector, err := metav1.LabelSelectorAsSelector(getSelector(instance))
+			if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.GetLabels())) {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: instance.GetNamespace(), Name: instance.GetName()},
+			})
+		}
+		return requests
+	}
+}
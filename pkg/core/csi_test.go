@@ -0,0 +1,176 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// statusClient serves lists of SecretProviderClassPodStatuses
+type statusClient struct {
+	client.Client
+	statuses []unstructured.Unstructured
+}
+
+func (c *statusClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if u, ok := list.(*unstructured.UnstructuredList); ok {
+		u.Items = c.statuses
+		return nil
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+var _ = Describe("Wave CSI Secrets Store Suite", func() {
+	var instance podController
+	labels := map[string]string{"app": "example"}
+
+	// podStatus returns the SecretProviderClassPodStatus of a Pod reporting
+	// the version of a single object
+	podStatus := func(pod, class, id, version string) *unstructured.Unstructured {
+		u := &unstructured.Unstructured{Object: map[string]interface{}{
+			"metadata": map[string]interface{}{"namespace": "default", "name": pod + "-default-" + class},
+			"status": map[string]interface{}{
+				"podName":                 pod,
+				"secretProviderClassName": class,
+				"objects":                 []interface{}{map[string]interface{}{"id": id, "version": version}},
+			},
+		}}
+		u.SetGroupVersionKind(SecretProviderClassPodStatusGVK)
+		return u
+	}
+
+	// newClient returns a fake client which also lists the given
+	// SecretProviderClassPodStatuses, which the fake client can't list
+	newClient := func(objs ...runtime.Object) client.Client {
+		c := &statusClient{}
+		var typed []runtime.Object
+		for _, obj := range objs {
+			if u, ok := obj.(*unstructured.Unstructured); ok {
+				c.statuses = append(c.statuses, *u)
+			} else {
+				typed = append(typed, obj)
+			}
+		}
+		c.Client = fake.NewFakeClient(typed...)
+		return c
+	}
+
+	pod := func(name string) *corev1.Pod {
+		return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Labels: labels}}
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "example",
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			},
+			Spec: appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "secrets",
+			VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+				Driver:           csiSecretsStoreDriver,
+				VolumeAttributes: map[string]string{"secretProviderClass": "vault"},
+			}},
+		}}
+		instance = &deployment{d}
+	})
+
+	It("reports the versions mounted into the workload's Pods", func() {
+		c := newClient(
+			pod("example-a"),
+			podStatus("example-a", "vault", "db-password", "2"),
+			podStatus("example-a", "other", "db-password", "9"),
+			podStatus("unrelated", "vault", "db-password", "9"),
+		)
+		h := NewHandler(c, record.NewFakeRecorder(10), WithCSISecretsStore())
+		reported, err := h.getCSIVersions(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reported).To(Equal(csiVersions{"vault/db-password": {"2"}}))
+	})
+
+	It("reports nothing unless enabled", func() {
+		h := NewHandler(newClient(pod("example-a"), podStatus("example-a", "vault", "db-password", "2")), record.NewFakeRecorder(10))
+		reported, err := h.getCSIVersions(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(reported).To(BeEmpty())
+	})
+
+	It("only changes the latest version when a new version is reported", func() {
+		history, latest := updateCSIVersions(instance, csiVersions{"vault/db-password": {"1"}})
+		Expect(latest).To(Equal(map[string]string{"vault/db-password": "1"}))
+		Expect(setCSIVersions(instance, history)).To(Succeed())
+
+		// A Pod picked up a rotation before the others
+		history, latest = updateCSIVersions(instance, csiVersions{"vault/db-password": {"1", "2"}})
+		Expect(latest).To(Equal(map[string]string{"vault/db-password": "2"}))
+		Expect(setCSIVersions(instance, history)).To(Succeed())
+
+		// Old Pods still reporting the previous version don't roll it back
+		_, latest = updateCSIVersions(instance, csiVersions{"vault/db-password": {"1"}})
+		Expect(latest).To(Equal(map[string]string{"vault/db-password": "2"}))
+
+		recorded := csiVersions{}
+		Expect(json.Unmarshal([]byte(instance.GetAnnotations()[CSIVersionsAnnotation]), &recorded)).To(Succeed())
+		Expect(recorded).To(Equal(csiVersions{"vault/db-password": {"1", "2"}}))
+	})
+
+	It("forgets the versions of classes no longer mounted", func() {
+		history, _ := updateCSIVersions(instance, csiVersions{"vault/db-password": {"1"}})
+		Expect(setCSIVersions(instance, history)).To(Succeed())
+		instance.GetPodTemplate().Spec.Volumes = nil
+		history, latest := updateCSIVersions(instance, csiVersions{})
+		Expect(history).To(BeEmpty())
+		Expect(latest).To(BeEmpty())
+	})
+
+	It("mixes the latest versions into the hash", func() {
+		Expect(applyVersions("hash", nil)).To(Equal("hash"))
+		a := applyVersions("hash", map[string]string{"vault/db-password": "1"})
+		b := applyVersions("hash", map[string]string{"vault/db-password": "2"})
+		Expect(a).NotTo(Equal("hash"))
+		Expect(a).NotTo(Equal(b))
+	})
+
+	It("maps a SecretProviderClassPodStatus to the workloads selecting its Pod", func() {
+		status := podStatus("example-a", "vault", "db-password", "2")
+		objs := []runtime.Object{pod("example-a"), instance.GetObject()}
+		toRequests := SecretProviderClassPodStatusConsumers(fake.NewFakeClient(objs...), "Deployment")
+		requests := toRequests(handler.MapObject{Meta: status, Object: status})
+		Expect(requests).To(ConsistOf(reconcile.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "example"}}))
+
+		toRequests = SecretProviderClassPodStatusConsumers(fake.NewFakeClient(objs...), "StatefulSet")
+		Expect(toRequests(handler.MapObject{Meta: status, Object: status})).To(BeEmpty())
+	})
+})
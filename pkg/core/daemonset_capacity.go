@@ -0,0 +1,182 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// OriginalMaxUnavailableAnnotation is the key of the annotation recording the
+// maxUnavailable of a DaemonSet's RollingUpdate strategy while Wave lowers it
+// for a rollout it triggered. An empty value records that it was unset.
+const OriginalMaxUnavailableAnnotation = "wave.pusher.com/original-max-unavailable"
+
+// DaemonSetOptions configures how many Pods of a DaemonSet the rollouts Wave
+// triggers may take down at once. DaemonSets run on every node, so rolling
+// out a node agent with a generous maxUnavailable degrades the whole cluster.
+type DaemonSetOptions struct {
+	// MaxUnavailable caps the Pods of a DaemonSet that may be unavailable
+	// during the rollouts Wave triggers, as a number or a percentage of its
+	// desired Pods rounded up. The cap is never lower than one Pod.
+	MaxUnavailable intstr.IntOrString
+
+	// RetryInterval is how long to wait before checking whether the rollout
+	// of a capped DaemonSet is complete again
+	RetryInterval time.Duration
+}
+
+// ParseMaxUnavailable parses a number of Pods, such as "2", or a percentage
+// of the desired Pods, such as "10%"
+func ParseMaxUnavailable(value string) (intstr.IntOrString, error) {
+	parsed := intstr.Parse(value)
+	if parsed.Type == intstr.String && !strings.HasSuffix(parsed.StrVal, "%") {
+		return intstr.IntOrString{}, fmt.Errorf("invalid maxUnavailable %q: must be a number or a percentage", value)
+	}
+	n, err := intstr.GetValueFromIntOrPercent(&parsed, 100, true)
+	if err != nil {
+		return intstr.IntOrString{}, errors.Wrapf(err, "invalid maxUnavailable %q", value)
+	}
+	if n <= 0 {
+		return intstr.IntOrString{}, fmt.Errorf("invalid maxUnavailable %q: must be positive", value)
+	}
+	return parsed, nil
+}
+
+// WithDaemonSetMaxUnavailable caps the maxUnavailable of the RollingUpdate
+// strategy of DaemonSets while the rollouts Wave triggers run, restoring it
+// once each rollout is complete. The Pods Wave deletes to roll out DaemonSets
+// using the OnDelete strategy are capped too.
+func WithDaemonSetMaxUnavailable(o DaemonSetOptions) Option {
+	return func(h *Handler) {
+		h.daemonSets = &o
+	}
+}
+
+// daemonSetCap returns the number of Pods of the DaemonSet that may be
+// unavailable during the rollouts Wave triggers, if it is capped
+func (h *Handler) daemonSetCap(ds *appsv1.DaemonSet) (int, bool) {
+	if h.daemonSets == nil {
+		return 0, false
+	}
+	n, err := intstr.GetValueFromIntOrPercent(&h.daemonSets.MaxUnavailable, int(ds.Status.DesiredNumberScheduled), true)
+	if err != nil || n < 1 {
+		return 1, true
+	}
+	return n, true
+}
+
+// maxUnavailableOf returns the maxUnavailable of the DaemonSet's RollingUpdate
+// strategy, or nil if it is unset
+func maxUnavailableOf(ds *appsv1.DaemonSet) *intstr.IntOrString {
+	if ds.Spec.UpdateStrategy.RollingUpdate == nil {
+		return nil
+	}
+	return ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+}
+
+// capDaemonSet lowers the maxUnavailable of the desired DaemonSet, which rolls
+// out a new configuration hash, to the cap, recording the value it replaces
+// unless an earlier rollout did already
+func (h *Handler) capDaemonSet(desired podController) {
+	ds, ok := desired.GetObject().(*appsv1.DaemonSet)
+	if !ok || ds.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+		return
+	}
+	limit, ok := h.daemonSetCap(ds)
+	if !ok {
+		return
+	}
+	current := maxUnavailableOf(ds)
+	// The DaemonSet controller defaults maxUnavailable to one Pod
+	n := 1
+	if current != nil {
+		n, _ = intstr.GetValueFromIntOrPercent(current, int(ds.Status.DesiredNumberScheduled), true)
+	}
+	if n <= limit {
+		return
+	}
+
+	annotations := ds.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	if _, ok := annotations[OriginalMaxUnavailableAnnotation]; !ok {
+		original := ""
+		if current != nil {
+			original = current.String()
+		}
+		annotations[OriginalMaxUnavailableAnnotation] = original
+		ds.SetAnnotations(annotations)
+	}
+	if ds.Spec.UpdateStrategy.RollingUpdate == nil {
+		ds.Spec.UpdateStrategy.RollingUpdate = &appsv1.RollingUpdateDaemonSet{}
+	}
+	capped := intstr.FromInt(limit)
+	ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = &capped
+
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Capping maxUnavailable for rollout", "namespace", ds.GetNamespace(), "name", ds.GetName(), "maxUnavailable", limit)
+}
+
+// restoreDaemonSet restores the maxUnavailable Wave lowered on the desired
+// DaemonSet once its rollout is complete, and returns the time to wait before
+// checking on the rollout again while it isn't. If the cap is no longer
+// configured, the value is restored straight away.
+func (h *Handler) restoreDaemonSet(instance, desired podController) time.Duration {
+	if _, ok := desired.GetAnnotations()[OriginalMaxUnavailableAnnotation]; !ok {
+		return 0
+	}
+	if h.daemonSets != nil && !h.rolloutComplete(instance) {
+		return h.daemonSets.RetryInterval
+	}
+	restoreMaxUnavailable(desired)
+	return 0
+}
+
+// restoreMaxUnavailable restores the maxUnavailable recorded in the
+// OriginalMaxUnavailableAnnotation of the DaemonSet, if any
+func restoreMaxUnavailable(obj podController) {
+	ds, ok := obj.GetObject().(*appsv1.DaemonSet)
+	if !ok {
+		return
+	}
+	annotations := ds.GetAnnotations()
+	original, ok := annotations[OriginalMaxUnavailableAnnotation]
+	if !ok {
+		return
+	}
+	if ds.Spec.UpdateStrategy.RollingUpdate != nil {
+		if original == "" {
+			ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = nil
+		} else {
+			restored := intstr.Parse(original)
+			ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = &restored
+		}
+	}
+	delete(annotations, OriginalMaxUnavailableAnnotation)
+	ds.SetAnnotations(annotations)
+
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Restoring maxUnavailable after rollout", "namespace", ds.GetNamespace(), "name", ds.GetName(), "maxUnavailable", original)
+}
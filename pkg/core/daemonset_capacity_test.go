@@ -0,0 +1,185 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave DaemonSet capacity Suite", func() {
+	var c client.Client
+	var h *Handler
+
+	getDaemonSet := func() *appsv1.DaemonSet {
+		ds := &appsv1.DaemonSet{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "agent"}, ds)).To(Succeed())
+		return ds
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDaemonSet(context.TODO(), getDaemonSet())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	newDaemonSet := func(maxUnavailable *intstr.IntOrString) *appsv1.DaemonSet {
+		return &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "agent",
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			},
+			Spec: appsv1.DaemonSetSpec{
+				UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+					Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+					RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: maxUnavailable},
+				},
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    "agent",
+						EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+					}},
+				}},
+			},
+			Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 20},
+		}
+	}
+
+	setup := func(ds *appsv1.DaemonSet) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"},
+			Data:       map[string]string{"key": "value"},
+		}
+		c = fake.NewFakeClient(cm, ds)
+		h = NewHandler(c, record.NewFakeRecorder(100), WithDaemonSetMaxUnavailable(DaemonSetOptions{
+			MaxUnavailable: intstr.FromString("10%"),
+			RetryInterval:  10 * time.Second,
+		}))
+	}
+
+	completeRollout := func() {
+		ds := getDaemonSet()
+		ds.Status.UpdatedNumberScheduled = 20
+		ds.Status.NumberAvailable = 20
+		Expect(c.Update(context.TODO(), ds)).To(Succeed())
+	}
+
+	// restored returns the desired state of the DaemonSet once Wave restores
+	// its maxUnavailable. The fake client doesn't remove the fields a merge
+	// patch deletes, so they are checked on the desired state.
+	restored := func() *appsv1.DaemonSet {
+		instance := &daemonset{getDaemonSet()}
+		desired := instance.DeepCopy()
+		Expect(h.restoreDaemonSet(instance, desired)).To(BeZero())
+		return desired.GetObject().(*appsv1.DaemonSet)
+	}
+
+	maxUnavailable := func() *intstr.IntOrString {
+		return getDaemonSet().Spec.UpdateStrategy.RollingUpdate.MaxUnavailable
+	}
+
+	It("caps maxUnavailable until the rollout is complete", func() {
+		fifty := intstr.FromString("50%")
+		setup(newDaemonSet(&fifty))
+
+		handle()
+		Expect(getConfigHash(&daemonset{getDaemonSet()})).NotTo(BeEmpty())
+		Expect(*maxUnavailable()).To(Equal(intstr.FromInt(2)))
+		Expect(getDaemonSet().Annotations).To(HaveKeyWithValue(OriginalMaxUnavailableAnnotation, "50%"))
+
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(*maxUnavailable()).To(Equal(intstr.FromInt(2)))
+
+		completeRollout()
+		Expect(restored().Annotations).NotTo(HaveKey(OriginalMaxUnavailableAnnotation))
+		Expect(handle()).To(BeZero())
+		Expect(*maxUnavailable()).To(Equal(fifty))
+	})
+
+	It("restores an unset maxUnavailable", func() {
+		setup(newDaemonSet(nil))
+
+		// The DaemonSet controller defaults maxUnavailable to one Pod, which
+		// is within the cap
+		handle()
+		Expect(maxUnavailable()).To(BeNil())
+		Expect(getDaemonSet().Annotations).NotTo(HaveKey(OriginalMaxUnavailableAnnotation))
+
+		two := intstr.FromInt(2)
+		ds := getDaemonSet()
+		ds.Annotations[OriginalMaxUnavailableAnnotation] = ""
+		ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable = &two
+		Expect(c.Update(context.TODO(), ds)).To(Succeed())
+		completeRollout()
+		ds = restored()
+		Expect(ds.Annotations).NotTo(HaveKey(OriginalMaxUnavailableAnnotation))
+		Expect(ds.Spec.UpdateStrategy.RollingUpdate.MaxUnavailable).To(BeNil())
+	})
+
+	It("leaves DaemonSets within the cap alone", func() {
+		one := intstr.FromInt(1)
+		setup(newDaemonSet(&one))
+
+		handle()
+		Expect(*maxUnavailable()).To(Equal(one))
+		Expect(getDaemonSet().Annotations).NotTo(HaveKey(OriginalMaxUnavailableAnnotation))
+	})
+
+	It("restores maxUnavailable when the DaemonSet is opted out", func() {
+		five := intstr.FromInt(5)
+		setup(newDaemonSet(&five))
+		handle()
+		Expect(*maxUnavailable()).To(Equal(intstr.FromInt(2)))
+
+		ds := getDaemonSet()
+		delete(ds.Annotations, RequiredAnnotation)
+		Expect(c.Update(context.TODO(), ds)).To(Succeed())
+		handle()
+		Expect(*maxUnavailable()).To(Equal(five))
+	})
+
+	It("rounds percentages up and never goes below one Pod", func() {
+		setup(newDaemonSet(nil))
+		limit, ok := h.daemonSetCap(&appsv1.DaemonSet{Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 15}})
+		Expect(ok).To(BeTrue())
+		Expect(limit).To(Equal(2))
+
+		limit, _ = h.daemonSetCap(&appsv1.DaemonSet{})
+		Expect(limit).To(Equal(1))
+	})
+
+	It("parses numbers and percentages of Pods", func() {
+		Expect(ParseMaxUnavailable("3")).To(Equal(intstr.FromInt(3)))
+		Expect(ParseMaxUnavailable("10%")).To(Equal(intstr.FromString("10%")))
+		for _, invalid := range []string{"0", "0%", "-1", "ten", "10.5%"} {
+			_, err := ParseMaxUnavailable(invalid)
+			Expect(err).To(HaveOccurred(), invalid)
+		}
+	})
+})
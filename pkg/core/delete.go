@@ -18,35 +18,38 @@ package core
 
 import (
 	"context"
-	"fmt"
 	"reflect"
 
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // handleDelete removes all existing Owner References pointing to the object
 // before removing the object's Finalizer
-func (h *Handler) handleDelete(obj podController) (reconcile.Result, error) {
+func (h *Handler) handleDelete(ctx context.Context, obj podController) (reconcile.Result, error) {
 	// Fetch all children with an OwnerReference pointing to the object
-	existing, err := h.getExistingChildren(obj)
+	existing, err := h.getExistingChildren(ctx, obj)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error fetching children: %v", err)
+		return reconcile.Result{}, errors.Wrap(err, "error fetching children")
 	}
 
 	// Remove the OwnerReferences from the children
-	err = h.removeOwnerReferences(obj, existing)
+	err = h.removeOwnerReferences(ctx, obj, existing)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error removing owner references from children: %v", err)
+		return reconcile.Result{}, errors.Wrap(err, "error removing owner references from children")
 	}
 
-	// Remove the object's Finalizer and update if necessary
+	// Remove the object's Finalizer, restore any maxUnavailable Wave lowered
+	// and update if necessary
 	copy := obj.DeepCopy()
 	removeFinalizer(copy)
+	restoreMaxUnavailable(copy)
 	if !reflect.DeepEqual(obj, copy) {
-		err := h.Update(context.TODO(), copy.GetObject())
+		err := h.updateWorkload(ctx, obj, copy, audit.FinalizerRemoved)
 		if err != nil {
-			return reconcile.Result{}, fmt.Errorf("error updating Deployment: %v", err)
+			return reconcile.Result{}, errors.Wrap(err, "error updating Deployment")
 		}
 	}
 	return reconcile.Result{}, nil
@@ -72,13 +72,12 @@ var _ = Describe("Wave owner references Suite", func() {
 
 		ownerRef = utils.GetOwnerRefDeployment(deploymentObject)
 
-		stopMgr, mgrStopped = StartTestManager(mgr)
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
 		m.Get(deploymentObject, timeout).Should(Succeed())
 	})
 
 	AfterEach(func() {
-		close(stopMgr)
-		mgrStopped.Wait()
+		utils.StopTestManager(stopMgr, mgrStopped)
 
 		utils.DeleteAll(cfg, timeout,
 			&appsv1.DeploymentList{},
@@ -114,7 +113,7 @@ var _ = Describe("Wave owner references Suite", func() {
 				return obj
 			}, timeout).Should(Succeed())
 
-			_, err := h.handleDelete(podControllerDeployment)
+			_, err := h.handleDelete(context.TODO(), podControllerDeployment)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
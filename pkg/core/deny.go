@@ -0,0 +1,106 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// SecretDenyList describes sensitive Secrets that Wave must never track or
+// update, whatever the annotations of the workloads using them
+type SecretDenyList struct {
+	// Names are glob patterns matched against the names of Secrets,
+	// e.g. *-root-ca
+	Names []string
+
+	// Selectors match the labels of Secrets
+	Selectors []labels.Selector
+}
+
+// NewSecretDenyList parses glob patterns of Secret names and label selectors
+// into a SecretDenyList
+func NewSecretDenyList(names, selectors []string) (SecretDenyList, error) {
+	d := SecretDenyList{}
+	for _, pattern := range names {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return SecretDenyList{}, errors.Wrapf(err, "invalid Secret name pattern %q", pattern)
+		}
+		d.Names = append(d.Names, pattern)
+	}
+	for _, selector := range selectors {
+		s, err := labels.Parse(selector)
+		if err != nil {
+			return SecretDenyList{}, errors.Wrapf(err, "invalid Secret label selector %q", selector)
+		}
+		d.Selectors = append(d.Selectors, s)
+	}
+	return d, nil
+}
+
+// empty returns true if the SecretDenyList denies nothing
+func (d SecretDenyList) empty() bool {
+	return len(d.Names) == 0 && len(d.Selectors) == 0
+}
+
+// deniesName returns true if the name matches one of the patterns
+func (d SecretDenyList) deniesName(name string) bool {
+	for _, pattern := range d.Names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// denies returns true if the Secret's name or labels match the deny list
+func (d SecretDenyList) denies(obj metav1.Object) bool {
+	if d.deniesName(obj.GetName()) {
+		return true
+	}
+	for _, selector := range d.Selectors {
+		if selector.Matches(labels.Set(obj.GetLabels())) {
+			return true
+		}
+	}
+	return false
+}
+
+// WithSecretDenyList configures the Handler to never track or update the
+// Secrets matching the deny list. Workloads referencing them are reported
+// with a SecretDenied Event.
+func WithSecretDenyList(d SecretDenyList) Option {
+	return func(h *Handler) {
+		if !d.empty() {
+			h.denyList = &d
+		}
+	}
+}
+
+// secretDenied returns true if the Handler must not track the Secret
+func (h *Handler) secretDenied(obj metav1.Object) bool {
+	return h.denyList != nil && h.denyList.denies(obj)
+}
+
+// secretNameDenied returns true if the Handler must not track the Secret with
+// the given name, whatever its labels
+func (h *Handler) secretNameDenied(name string) bool {
+	return h.denyList != nil && h.denyList.deniesName(name)
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave deny list Suite", func() {
+	var c client.Client
+	var recorder *record.FakeRecorder
+	var instance podController
+	var critical *corev1.Secret
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetUID(types.UID("example-uid"))
+		d.Spec.Template.Spec.Volumes = nil
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "cluster-root-ca"}}},
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "critical"}}},
+			},
+		}}
+		instance = &deployment{d}
+
+		critical = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "critical",
+			Labels:    map[string]string{"security.example.com/critical": "true"},
+		}}
+		rootCA := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "cluster-root-ca"}}
+		c = fake.NewFakeClient(d, utils.ExampleSecret1.DeepCopy(), rootCA, critical)
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	newHandler := func() *Handler {
+		denyList, err := NewSecretDenyList([]string{"*-root-ca"}, []string{"security.example.com/critical=true"})
+		Expect(err).NotTo(HaveOccurred())
+		return NewHandler(c, recorder, WithSecretDenyList(denyList))
+	}
+
+	It("rejects invalid patterns and selectors", func() {
+		_, err := NewSecretDenyList([]string{"["}, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = NewSecretDenyList(nil, []string{"a in (b"})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("is disabled when empty", func() {
+		h := NewHandler(c, recorder, WithSecretDenyList(SecretDenyList{}))
+		Expect(h.denyList).To(BeNil())
+		children, err := h.getCurrentChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(children).To(HaveLen(3))
+	})
+
+	It("doesn't track denied Secrets and reports them", func() {
+		children, err := newHandler().getCurrentChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(children).To(HaveLen(1))
+		Expect(children[0].object.GetName()).To(Equal("example1"))
+
+		Expect(recorder.Events).To(Receive(Equal("Warning SecretDenied Secret cluster-root-ca matches the sensitive Secret deny list and is not tracked")))
+		Expect(recorder.Events).To(Receive(Equal("Warning SecretDenied Secret critical matches the sensitive Secret deny list and is not tracked")))
+	})
+
+	It("never updates denied Secrets it owned before", func() {
+		critical.SetOwnerReferences([]metav1.OwnerReference{getOwnerReference(instance)})
+		Expect(c.Update(context.TODO(), critical)).To(Succeed())
+
+		existing, err := newHandler().getExistingChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(existing).To(BeEmpty())
+	})
+})
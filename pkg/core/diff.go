@@ -0,0 +1,354 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/notify"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sourceHashes maps each child, formatted as "<kind>/<name>", to a short hash
+// of each of its keys used to calculate the configuration hash
+type sourceHashes map[string]map[string]string
+
+// SourceDiff describes how a single child differs from when the applied
+// configuration hash was calculated
+type SourceDiff struct {
+	Source string   `json:"source"`
+	Change string   `json:"change"`
+	Keys   []string `json:"keys,omitempty"`
+}
+
+// ConfigDiff compares the configuration hash applied to a workload with the
+// hash Wave would calculate now
+type ConfigDiff struct {
+	AppliedHash string `json:"appliedHash"`
+	CurrentHash string `json:"currentHash"`
+	Paused      bool   `json:"paused"`
+
+	// SourcesKnown is false if the workload has no record of the children
+	// used to calculate the applied hash, in which case Sources is empty
+	SourcesKnown bool         `json:"sourcesKnown"`
+	Sources      []SourceDiff `json:"sources"`
+}
+
+// Pending returns whether the workload will be restarted once the current
+// hash is applied
+func (d ConfigDiff) Pending() bool {
+	return d.AppliedHash != d.CurrentHash
+}
+
+// DiffConfig compares the configuration hash applied to the Deployment,
+// StatefulSet or DaemonSet with the hash Wave would calculate now from its
+// children. It refuses workloads whose hash depends on the versions of CSI
+// objects, plugin sources or extra URLs, which only the controller fetches.
+func DiffConfig(ctx context.Context, c client.Client, obj Object) (ConfigDiff, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+	h, err := offlineHandler(c, instance)
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+	return h.diffConfig(ctx, instance)
+}
+
+// DiffConfig compares the configuration hash applied to the Deployment,
+// StatefulSet or DaemonSet with the hash the Handler would calculate now
+func (h *Handler) DiffConfig(ctx context.Context, obj Object) (ConfigDiff, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+	return h.diffConfig(ctx, instance)
+}
+
+// diffConfig compares the configuration hash applied to the instance with
+// its current configuration hash
+func (h *Handler) diffConfig(ctx context.Context, instance podController) (ConfigDiff, error) {
+	current, hash, err := h.currentConfig(ctx, instance)
+	if err != nil {
+		return ConfigDiff{}, err
+	}
+
+	diff := ConfigDiff{
+		AppliedHash: getConfigHash(instance),
+		CurrentHash: hash,
+		Paused:      isPaused(instance),
+		Sources:     []SourceDiff{},
+	}
+	applied, ok := getSourceHashes(instance)
+	if ok {
+		diff.SourcesKnown = true
+		diff.Sources = diffSourceHashes(applied, calculateSourceHashes(current))
+	}
+	return diff, nil
+}
+
+// CalculateConfigHash returns the configuration hash Wave would apply to the
+// Deployment, StatefulSet or DaemonSet given the ConfigMaps and Secrets
+// readable through the client. Like DiffConfig, it refuses workloads whose
+// hash depends on versions only the controller fetches.
+func CalculateConfigHash(ctx context.Context, c client.Client, obj Object) (string, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return "", err
+	}
+	h, err := offlineHandler(c, instance)
+	if err != nil {
+		return "", err
+	}
+	_, hash, err := h.currentConfig(ctx, instance)
+	return hash, err
+}
+
+// SetConfig calculates the configuration hash of the Deployment, StatefulSet
+// or DaemonSet from the ConfigMaps and Secrets readable through the client
+// and records it, and the source hashes, on the object exactly as Wave would.
+// Like DiffConfig, it refuses workloads whose hash depends on versions only
+// the controller fetches.
+func SetConfig(ctx context.Context, c client.Client, obj Object) (string, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return "", err
+	}
+	h, err := offlineHandler(c, instance)
+	if err != nil {
+		return "", err
+	}
+	current, hash, err := h.currentConfig(ctx, instance)
+	if err != nil {
+		return "", err
+	}
+	setConfigHash(instance, hash)
+	if err := setSourceHashes(instance, calculateSourceHashes(current)); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// offlineHandler returns a Handler reading the children of the instance
+// through the client. It has no event recorder and none of the controller's
+// CSI, plugin or extra URL configuration, so it refuses instances whose hash
+// may depend on them rather than calculate a hash the controller wouldn't.
+func offlineHandler(c client.Client, instance podController) (*Handler, error) {
+	var sources []string
+	if len(getSecretProviderClasses(instance)) > 0 {
+		sources = append(sources, "SecretProviderClasses")
+	}
+	if len(getPluginSources(instance)) > 0 {
+		sources = append(sources, "plugin sources")
+	}
+	if len(getExtraURLs(instance)) > 0 {
+		sources = append(sources, "extra URLs")
+	}
+	if len(sources) > 0 {
+		return nil, fmt.Errorf("unable to calculate the configuration hash outside the controller: it may depend on the versions of %s", strings.Join(sources, ", "))
+	}
+	return &Handler{Client: c}, nil
+}
+
+// currentConfig fetches the children of the instance and calculates the
+// configuration hash Wave would apply to it
+func (h *Handler) currentConfig(ctx context.Context, instance podController) ([]configObject, string, error) {
+	current, err := h.getChildren(ctx, instance, false)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "error fetching current children")
+	}
+	hash, _, err := h.currentHash(ctx, instance, current)
+	if err != nil {
+		return nil, "", err
+	}
+	return current, hash, nil
+}
+
+// asPodController wraps a Deployment, StatefulSet or DaemonSet
+func asPodController(obj Object) (podController, error) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &deployment{o}, nil
+	case *appsv1.StatefulSet:
+		return &statefulset{o}, nil
+	case *appsv1.DaemonSet:
+		return &daemonset{o}, nil
+	default:
+		return nil, fmt.Errorf("unsupported object type %T", obj)
+	}
+}
+
+// calculateSourceHashes hashes each key of each child used to calculate the
+// configuration hash. Keys which are hashed but which the workload doesn't
+// see, such as keys envFrom skips, are left out so that they are never
+// reported as changed.
+func calculateSourceHashes(children []configObject) sourceHashes {
+	hashes := make(sourceHashes)
+	for _, child := range children {
+		keys := make(map[string]string)
+		switch o := child.object.(type) {
+		case *corev1.ConfigMap:
+			for key, value := range getConfigMapData(child) {
+				if _, ok := o.Data[key]; ok && !child.usesKey(key) {
+					continue
+				}
+				keys[key] = shortValueHash([]byte(value))
+			}
+		case *corev1.Secret:
+			for key, value := range getSecretData(child) {
+				if _, ok := o.Data[key]; ok && !child.metadataOnly && !child.usesKey(key) {
+					continue
+				}
+				keys[key] = secretValueHash(child.object, value)
+			}
+		}
+		hashes[sourceKeyOf(child.object).String()] = keys
+	}
+	return hashes
+}
+
+// shortValueHash returns an abbreviated sha256 hash of the value
+func shortValueHash(value []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(value))[:12]
+}
+
+// secretValueHash returns an abbreviated hash of the Secret value, salted with
+// the Secret's UID so that it cannot be matched against precomputed hashes
+// of common values
+func secretValueHash(secret Object, value []byte) string {
+	salted := append([]byte(secret.GetUID()), value...)
+	return shortValueHash(salted)
+}
+
+// diffSourceHashes returns the children and keys that differ between the
+// applied and current source hashes
+func diffSourceHashes(applied, current sourceHashes) []SourceDiff {
+	diffs := []SourceDiff{}
+	for source, keys := range current {
+		appliedKeys, ok := applied[source]
+		if !ok {
+			diffs = append(diffs, SourceDiff{Source: source, Change: sourceAdded, Keys: sortedKeys(keys)})
+			continue
+		}
+		changed := []string{}
+		for key, hash := range keys {
+			if appliedKeys[key] != hash {
+				changed = append(changed, key)
+			}
+		}
+		for key := range appliedKeys {
+			if _, ok := keys[key]; !ok {
+				changed = append(changed, key)
+			}
+		}
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			diffs = append(diffs, SourceDiff{Source: source, Change: sourceModified, Keys: changed})
+		}
+	}
+	for source, keys := range applied {
+		if _, ok := current[source]; !ok {
+			diffs = append(diffs, SourceDiff{Source: source, Change: sourceRemoved, Keys: sortedKeys(keys)})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool {
+		return diffs[i].Source < diffs[j].Source
+	})
+	return diffs
+}
+
+// changedKeys returns the keys that differ between the source hashes
+// recorded on the instance and the current source hashes, or nil if none are
+// recorded
+func changedKeys(instance podController, current sourceHashes) []notify.KeyChange {
+	applied, ok := getSourceHashes(instance)
+	if !ok {
+		return nil
+	}
+	keys := []notify.KeyChange{}
+	for source, hashes := range current {
+		for _, key := range sortedKeys(hashes) {
+			if hash, ok := applied[source][key]; !ok {
+				keys = append(keys, notify.KeyChange{Source: source, Key: key, Change: sourceAdded})
+			} else if hash != hashes[key] {
+				keys = append(keys, notify.KeyChange{Source: source, Key: key, Change: sourceModified})
+			}
+		}
+	}
+	for source, hashes := range applied {
+		for _, key := range sortedKeys(hashes) {
+			if _, ok := current[source][key]; !ok {
+				keys = append(keys, notify.KeyChange{Source: source, Key: key, Change: sourceRemoved})
+			}
+		}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		if keys[i].Source != keys[j].Source {
+			return keys[i].Source < keys[j].Source
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	return keys
+}
+
+// sortedKeys returns the keys of the map in order
+func sortedKeys(m map[string]string) []string {
+	keys := []string{}
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// getSourceHashes returns the source hashes recorded on the podController,
+// if any
+func getSourceHashes(obj podController) (sourceHashes, bool) {
+	value, ok := obj.GetAnnotations()[SourceHashesAnnotation]
+	if !ok {
+		return nil, false
+	}
+	hashes := make(sourceHashes)
+	if err := json.Unmarshal([]byte(value), &hashes); err != nil {
+		return nil, false
+	}
+	return hashes, true
+}
+
+// setSourceHashes records the source hashes on the podController
+func setSourceHashes(obj podController, hashes sourceHashes) error {
+	value, err := json.Marshal(hashes)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[SourceHashesAnnotation] = string(value)
+	obj.SetAnnotations(annotations)
+	return nil
+}
@@ -0,0 +1,182 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/notify"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave diff Suite", func() {
+	Context("diffSourceHashes", func() {
+		applied := sourceHashes{
+			"ConfigMap/example1": {"key1": "a", "key2": "b"},
+			"Secret/example1":    {"key1": "c"},
+		}
+
+		It("reports nothing when the hashes match", func() {
+			Expect(diffSourceHashes(applied, applied)).To(BeEmpty())
+		})
+
+		It("reports added, removed and modified children and keys", func() {
+			current := sourceHashes{
+				"ConfigMap/example1": {"key1": "a", "key2": "changed", "key3": "d"},
+				"ConfigMap/example2": {"key1": "e"},
+			}
+			Expect(diffSourceHashes(applied, current)).To(Equal([]SourceDiff{
+				{Source: "ConfigMap/example1", Change: sourceModified, Keys: []string{"key2", "key3"}},
+				{Source: "ConfigMap/example2", Change: sourceAdded, Keys: []string{"key1"}},
+				{Source: "Secret/example1", Change: sourceRemoved, Keys: []string{"key1"}},
+			}))
+		})
+	})
+
+	Context("changedKeys", func() {
+		It("reports the keys added, removed and modified since the hash was applied", func() {
+			instance := &deployment{&appsv1.Deployment{}}
+			Expect(setSourceHashes(instance, sourceHashes{
+				"ConfigMap/example1": {"key1": "a", "key2": "b"},
+				"Secret/example1":    {"key1": "c"},
+			})).To(Succeed())
+			current := sourceHashes{
+				"ConfigMap/example1": {"key1": "a", "key2": "changed", "key3": "d"},
+			}
+			Expect(changedKeys(instance, current)).To(Equal([]notify.KeyChange{
+				{Source: "ConfigMap/example1", Key: "key2", Change: sourceModified},
+				{Source: "ConfigMap/example1", Key: "key3", Change: sourceAdded},
+				{Source: "Secret/example1", Key: "key1", Change: sourceRemoved},
+			}))
+		})
+
+		It("reports nothing without source hashes", func() {
+			Expect(changedKeys(&deployment{&appsv1.Deployment{}}, sourceHashes{})).To(BeNil())
+		})
+	})
+
+	Context("calculateSourceHashes", func() {
+		It("only hashes the keys in use", func() {
+			cm := utils.ExampleConfigMap1.DeepCopy()
+			hashes := calculateSourceHashes([]configObject{
+				{object: cm, keys: map[string]struct{}{"key1": {}}},
+			})
+			Expect(hashes).To(HaveKey("ConfigMap/example1"))
+			Expect(hashes["ConfigMap/example1"]).To(ConsistOf(shortValueHash([]byte("example1:key1"))))
+		})
+	})
+
+	Context("DiffConfig", func() {
+		var cm *corev1.ConfigMap
+		var instance *appsv1.Deployment
+
+		BeforeEach(func() {
+			cm = utils.ExampleConfigMap1.DeepCopy()
+			instance = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			}
+			instance.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: "container",
+				EnvFrom: []corev1.EnvFromSource{{
+					ConfigMapRef: &corev1.ConfigMapEnvSource{
+						LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()},
+					},
+				}},
+			}}
+		})
+
+		It("reports a pending change without source hashes", func() {
+			diff, err := DiffConfig(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.Pending()).To(BeTrue())
+			Expect(diff.SourcesKnown).To(BeFalse())
+		})
+
+		It("reports the keys changed since the hash was applied", func() {
+			pc := &deployment{instance}
+			children := []configObject{{object: cm, allKeys: true}}
+			hash, err := calculateConfigHash(children)
+			Expect(err).NotTo(HaveOccurred())
+			setConfigHash(pc, hash)
+			Expect(setSourceHashes(pc, calculateSourceHashes(children))).To(Succeed())
+
+			diff, err := DiffConfig(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.Pending()).To(BeFalse())
+
+			cm.Data["key2"] = "modified"
+			diff, err = DiffConfig(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(diff.Pending()).To(BeTrue())
+			Expect(diff.SourcesKnown).To(BeTrue())
+			Expect(diff.Sources).To(Equal([]SourceDiff{
+				{Source: "ConfigMap/example1", Change: sourceModified, Keys: []string{"key2"}},
+			}))
+		})
+
+		// Without an event recorder, problems with the references must not
+		// be reported as events
+		It("ignores unknown containers listed in the track-containers annotation", func() {
+			instance.SetAnnotations(map[string]string{TrackContainersAnnotation: "container,unknown"})
+			_, err := CalculateConfigHash(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("ignores invalid entries of the only-track annotation", func() {
+			instance.SetAnnotations(map[string]string{OnlyTrackAnnotation: "configmap/example1,invalid"})
+			_, err := CalculateConfigHash(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("applies the policy for deleted sources", func() {
+			instance.SetAnnotations(map[string]string{SourceDeletedAnnotation: sourceDeletedRestart})
+			instance.Spec.Template.Spec.Containers[0].EnvFrom = append(instance.Spec.Template.Spec.Containers[0].EnvFrom, corev1.EnvFromSource{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "deleted"}},
+			})
+			Expect(setSourceHashes(&deployment{instance}, sourceHashes{"Secret/deleted": {"key": "a"}})).To(Succeed())
+			_, err := CalculateConfigHash(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("refuses workloads whose hash depends on versions only the controller fetches", func() {
+			instance.SetAnnotations(map[string]string{
+				PluginSourcesAnnotation: "flags=checkout",
+				ExtraURLsAnnotation:     "https://example.com/config",
+			})
+			instance.Spec.Template.Spec.Volumes = []corev1.Volume{{
+				Name: "secrets",
+				VolumeSource: corev1.VolumeSource{CSI: &corev1.CSIVolumeSource{
+					Driver:           csiSecretsStoreDriver,
+					VolumeAttributes: map[string]string{"secretProviderClass": "example"},
+				}},
+			}}
+			_, err := CalculateConfigHash(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).To(MatchError(ContainSubstring("the versions of SecretProviderClasses, plugin sources, extra URLs")))
+			_, err = DiffConfig(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).To(HaveOccurred())
+			_, err = SetConfig(context.TODO(), fake.NewFakeClient(cm), instance)
+			Expect(err).To(HaveOccurred())
+			Expect(getConfigHash(&deployment{instance})).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,245 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// AllowDowntimeAnnotation is the key of an optional annotation on the
+	// workload. With the value "true", Wave restarts workloads whose
+	// rollouts cause downtime whenever their configuration changes.
+	AllowDowntimeAnnotation = "wave.pusher.com/allow-downtime"
+
+	// MaintenanceWindowAnnotation is the key of an optional annotation on
+	// the workload listing, separated by commas, the windows in which Wave
+	// may restart it when its rollouts cause downtime. Each window is a
+	// range of UTC times optionally preceded by a day or range of days, for
+	// example "Mon-Fri 22:00-02:00, Sat 04:00-06:00". Windows may end on the
+	// following day.
+	MaintenanceWindowAnnotation = "wave.pusher.com/maintenance-window"
+)
+
+// WithRecreateHold holds back the rollouts of workloads which cause downtime
+// until they allow it with the AllowDowntimeAnnotation or are in one of the
+// windows of their MaintenanceWindowAnnotation
+func WithRecreateHold() Option {
+	return func(h *Handler) {
+		h.holdRecreate = true
+	}
+}
+
+// causesDowntime returns true if rolling out the instance stops all of its
+// Pods before starting new ones
+func causesDowntime(instance podController) bool {
+	d, ok := instance.GetObject().(*appsv1.Deployment)
+	return ok && d.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType
+}
+
+// checkDowntime checks whether the rollout of the instance must be held back
+// because it causes downtime which isn't allowed now, and returns the time
+// until its next maintenance window opens, if any
+func (h *Handler) checkDowntime(instance podController, hash string, changes []sourceChange) (bool, time.Duration) {
+	if !h.holdRecreate || !causesDowntime(instance) || instance.GetAnnotations()[AllowDowntimeAnnotation] == "true" {
+		return false, 0
+	}
+
+	var reason string
+	wait, err := h.untilMaintenanceWindow(instance)
+	_, hasWindow := instance.GetAnnotations()[MaintenanceWindowAnnotation]
+	switch {
+	case !hasWindow:
+		reason = fmt.Sprintf("the Recreate strategy stops every Pod before starting new ones; set %s: \"true\" or a %s to allow it", AllowDowntimeAnnotation, MaintenanceWindowAnnotation)
+	case err != nil:
+		reason = fmt.Sprintf("the Recreate strategy stops every Pod before starting new ones and %v", err)
+	case wait == 0:
+		return false, 0
+	default:
+		reason = fmt.Sprintf("the Recreate strategy stops every Pod before starting new ones; waiting %s for the maintenance window", wait)
+	}
+
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Holding back rollout causing downtime", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash, "wait", wait.String())
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "RestartHeld", "Restart for configuration hash %s held back: %s", shortHash(hash), reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return true, wait
+}
+
+// untilMaintenanceWindow returns how long until one of the maintenance windows
+// of the instance opens, or zero if one is open now. An error is returned if
+// the instance has no valid MaintenanceWindowAnnotation.
+func (h *Handler) untilMaintenanceWindow(instance podController) (time.Duration, error) {
+	spec, ok := instance.GetAnnotations()[MaintenanceWindowAnnotation]
+	if !ok {
+		return 0, fmt.Errorf("no %s annotation", MaintenanceWindowAnnotation)
+	}
+	windows, err := parseMaintenanceWindows(spec)
+	if err != nil {
+		return 0, errors.Wrapf(err, "its %s is invalid", MaintenanceWindowAnnotation)
+	}
+	now := h.getClock().Now()
+	if inMaintenanceWindow(windows, now) {
+		return 0, nil
+	}
+	return nextMaintenanceWindow(windows, now).Sub(now), nil
+}
+
+// maintenanceWindow is a daily range of UTC times on some days of the week
+type maintenanceWindow struct {
+	days  [7]bool
+	start time.Duration
+	end   time.Duration
+}
+
+// opensAt returns when the window opening on the day of t opens, and whether
+// it opens on that day
+func (w maintenanceWindow) opensAt(t time.Time) (time.Time, bool) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	return day.Add(w.start), w.days[day.Weekday()]
+}
+
+// length returns how long the window is open
+func (w maintenanceWindow) length() time.Duration {
+	if w.end > w.start {
+		return w.end - w.start
+	}
+	return w.end + 24*time.Hour - w.start
+}
+
+// inMaintenanceWindow returns true if one of the windows is open at now
+func inMaintenanceWindow(windows []maintenanceWindow, now time.Time) bool {
+	for _, w := range windows {
+		// A window open now opened today or, ending after midnight,
+		// yesterday
+		for _, day := range []time.Time{now, now.AddDate(0, 0, -1)} {
+			opens, ok := w.opensAt(day)
+			if ok && !now.Before(opens) && now.Before(opens.Add(w.length())) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nextMaintenanceWindow returns when the next of the windows opens after now
+func nextMaintenanceWindow(windows []maintenanceWindow, now time.Time) time.Time {
+	var next time.Time
+	for _, w := range windows {
+		for i := 0; i <= 7; i++ {
+			opens, ok := w.opensAt(now.AddDate(0, 0, i))
+			if ok && opens.After(now) {
+				if next.IsZero() || opens.Before(next) {
+					next = opens
+				}
+				break
+			}
+		}
+	}
+	return next
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseMaintenanceWindows parses the value of a MaintenanceWindowAnnotation
+func parseMaintenanceWindows(spec string) ([]maintenanceWindow, error) {
+	var windows []maintenanceWindow
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		w := maintenanceWindow{}
+		var times string
+		switch len(fields) {
+		case 1:
+			times = fields[0]
+			for i := range w.days {
+				w.days[i] = true
+			}
+		case 2:
+			if err := parseDays(fields[0], &w.days); err != nil {
+				return nil, err
+			}
+			times = fields[1]
+		default:
+			return nil, fmt.Errorf("window %q is not of the form \"[days] HH:MM-HH:MM\"", strings.TrimSpace(part))
+		}
+
+		bounds := strings.Split(times, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("times %q are not of the form HH:MM-HH:MM", times)
+		}
+		var err error
+		if w.start, err = parseTimeOfDay(bounds[0]); err != nil {
+			return nil, err
+		}
+		if w.end, err = parseTimeOfDay(bounds[1]); err != nil {
+			return nil, err
+		}
+		windows = append(windows, w)
+	}
+	return windows, nil
+}
+
+// parseDays marks the days of a day, such as "Sat", or range of days, such as
+// "Mon-Fri"
+func parseDays(spec string, days *[7]bool) error {
+	bounds := strings.Split(spec, "-")
+	if len(bounds) > 2 {
+		return fmt.Errorf("days %q are not a day or range of days", spec)
+	}
+	first, ok := weekdays[strings.ToLower(bounds[0])]
+	if !ok {
+		return fmt.Errorf("unknown day %q", bounds[0])
+	}
+	last := first
+	if len(bounds) == 2 {
+		if last, ok = weekdays[strings.ToLower(bounds[1])]; !ok {
+			return fmt.Errorf("unknown day %q", bounds[1])
+		}
+	}
+	for d := first; ; d = (d + 1) % 7 {
+		days[d] = true
+		if d == last {
+			return nil
+		}
+	}
+}
+
+// parseTimeOfDay parses a time of day of the form HH:MM
+func parseTimeOfDay(spec string) (time.Duration, error) {
+	t, err := time.Parse("15:04", spec)
+	if err != nil {
+		return 0, fmt.Errorf("time %q is not of the form HH:MM", spec)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
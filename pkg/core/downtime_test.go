@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave downtime windows Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	setup := func(strategy appsv1.DeploymentStrategyType, annotations map[string]string) {
+		annotations[RequiredAnnotation] = "true"
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: annotations,
+		}}
+		d.Spec.Strategy.Type = strategy
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		// A Tuesday
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC))
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithRecreateHold())
+	}
+
+	It("rolls out Deployments using the RollingUpdate strategy", func() {
+		setup(appsv1.RollingUpdateDeploymentStrategyType, map[string]string{})
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("holds back Deployments using the Recreate strategy", func() {
+		setup(appsv1.RecreateDeploymentStrategyType, map[string]string{})
+		Expect(handle()).To(BeZero())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(And(ContainSubstring("RestartHeld"), ContainSubstring(AllowDowntimeAnnotation))))
+
+		d := getDeployment()
+		d.Annotations[AllowDowntimeAnnotation] = "true"
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("waits for the maintenance window", func() {
+		setup(appsv1.RecreateDeploymentStrategyType, map[string]string{MaintenanceWindowAnnotation: "Tue 22:00-02:00"})
+		Expect(handle()).To(Equal(10 * time.Hour))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+		// Still open after midnight
+		fakeClock.SetTime(time.Date(2019, 1, 2, 1, 0, 0, 0, time.UTC))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("holds back Deployments with an invalid maintenance window", func() {
+		setup(appsv1.RecreateDeploymentStrategyType, map[string]string{MaintenanceWindowAnnotation: "Someday 22:00-02:00"})
+		Expect(handle()).To(BeZero())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(ContainSubstring("unknown day")))
+	})
+
+	Context("parseMaintenanceWindows", func() {
+		// A Tuesday
+		now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+		It("opens windows without days every day", func() {
+			windows, err := parseMaintenanceWindows("11:00-13:00")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inMaintenanceWindow(windows, now)).To(BeTrue())
+			Expect(inMaintenanceWindow(windows, now.Add(2*time.Hour))).To(BeFalse())
+			Expect(nextMaintenanceWindow(windows, now)).To(Equal(time.Date(2019, 1, 2, 11, 0, 0, 0, time.UTC)))
+		})
+
+		It("opens windows on their days", func() {
+			windows, err := parseMaintenanceWindows("Mon-Fri 22:00-23:00, Sat 04:00-06:00")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inMaintenanceWindow(windows, now)).To(BeFalse())
+			Expect(nextMaintenanceWindow(windows, now)).To(Equal(time.Date(2019, 1, 1, 22, 0, 0, 0, time.UTC)))
+
+			friday := time.Date(2019, 1, 4, 23, 30, 0, 0, time.UTC)
+			Expect(nextMaintenanceWindow(windows, friday)).To(Equal(time.Date(2019, 1, 5, 4, 0, 0, 0, time.UTC)))
+			Expect(inMaintenanceWindow(windows, time.Date(2019, 1, 5, 5, 0, 0, 0, time.UTC))).To(BeTrue())
+			Expect(inMaintenanceWindow(windows, time.Date(2019, 1, 6, 5, 0, 0, 0, time.UTC))).To(BeFalse())
+		})
+
+		It("wraps ranges of days around the week", func() {
+			windows, err := parseMaintenanceWindows("Sat-Mon 00:00-23:59")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inMaintenanceWindow(windows, time.Date(2019, 1, 6, 12, 0, 0, 0, time.UTC))).To(BeTrue())
+			Expect(inMaintenanceWindow(windows, now)).To(BeFalse())
+		})
+
+		It("rejects invalid windows", func() {
+			for _, spec := range []string{"", "Mon", "Mon 22:00", "Mon 22:00-25:00", "Mon-Tue-Wed 01:00-02:00", "Mon 01:00-02:00 UTC"} {
+				_, err := parseMaintenanceWindows(spec)
+				Expect(err).To(HaveOccurred(), spec)
+			}
+		})
+	})
+})
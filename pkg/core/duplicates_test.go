@@ -0,0 +1,165 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Wave duplicate references Suite", func() {
+	// reference adds a reference to the ConfigMap "example" to the spec
+	type reference func(spec *corev1.PodSpec, optional bool)
+
+	references := map[string]reference{
+		"volume": func(spec *corev1.PodSpec, optional bool) {
+			spec.Volumes = append(spec.Volumes, corev1.Volume{
+				Name: fmt.Sprintf("volume-%d", len(spec.Volumes)),
+				VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "example"},
+					Optional:             &optional,
+				}},
+			})
+		},
+		"projected volume": func(spec *corev1.PodSpec, optional bool) {
+			spec.Volumes = append(spec.Volumes, corev1.Volume{
+				Name: fmt.Sprintf("volume-%d", len(spec.Volumes)),
+				VolumeSource: corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{
+					Sources: []corev1.VolumeProjection{{ConfigMap: &corev1.ConfigMapProjection{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "example"},
+						Optional:             &optional,
+					}}},
+				}},
+			})
+		},
+		"envFrom": func(spec *corev1.PodSpec, optional bool) {
+			spec.Containers = append(spec.Containers, corev1.Container{
+				Name: fmt.Sprintf("container-%d", len(spec.Containers)),
+				EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "example"},
+					Optional:             &optional,
+				}}},
+			})
+		},
+		"env": func(spec *corev1.PodSpec, optional bool) {
+			spec.Containers = append(spec.Containers, corev1.Container{
+				Name: fmt.Sprintf("container-%d", len(spec.Containers)),
+				Env: []corev1.EnvVar{{Name: "KEY", ValueFrom: &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "example"},
+					Key:                  "key",
+					Optional:             &optional,
+				}}}},
+			})
+		},
+	}
+
+	// metadataOf returns the metadata of the ConfigMap referenced by a
+	// Deployment making the references in order
+	metadataOf := func(refs []reference, optional []bool) configMetadata {
+		d := &deployment{&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"}}}
+		spec := &d.Spec.Template.Spec
+		for i, ref := range refs {
+			ref(spec, optional[i])
+		}
+		configMaps, _ := getChildNamesByType(d)
+		Expect(configMaps).To(HaveLen(1))
+		return configMaps["example"]
+	}
+
+	It("merges every combination of references in either order", func() {
+		for nameA, a := range references {
+			for nameB, b := range references {
+				for _, optional := range [][]bool{{false, false}, {false, true}, {true, false}, {true, true}} {
+					description := fmt.Sprintf("%s (optional %t) and %s (optional %t)", nameA, optional[0], nameB, optional[1])
+					forward := metadataOf([]reference{a, b}, optional)
+					backward := metadataOf([]reference{b, a}, []bool{optional[1], optional[0]})
+					Expect(forward).To(Equal(backward), description)
+
+					Expect(forward.required).To(Equal(!optional[0] || !optional[1]), description)
+					volume := func(name string) bool { return name == "volume" || name == "projected volume" }
+					Expect(forward.allKeys).To(Equal(volume(nameA) || volume(nameB)), description)
+					if forward.allKeys {
+						Expect(forward.keys).To(BeNil(), description)
+						Expect(forward.envFrom).To(BeNil(), description)
+					}
+					if !forward.allKeys && (nameA == "env" || nameB == "env") {
+						Expect(forward.keys).To(Equal(map[string]struct{}{"key": {}}), description)
+					}
+					if !forward.allKeys && (nameA == "envFrom" || nameB == "envFrom") {
+						Expect(forward.envFrom).To(HaveLen(1), description)
+					}
+				}
+			}
+		}
+	})
+
+	Context("canonicalChildren", func() {
+		var cm1, cm2 *corev1.ConfigMap
+		var s1 *corev1.Secret
+
+		BeforeEach(func() {
+			cm1 = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "b", UID: "cm1"}, Data: map[string]string{"key1": "value1", "key2": "value2"}}
+			cm2 = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", UID: "cm2"}, Data: map[string]string{"key1": "value1"}}
+			s1 = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a", UID: "s1"}, Data: map[string][]byte{"key1": []byte("value1")}}
+		})
+
+		It("orders the children by kind and name", func() {
+			children := canonicalChildren([]configObject{{object: s1}, {object: cm1}, {object: cm2}})
+			Expect(children).To(HaveLen(3))
+			Expect(children[0].object).To(Equal(cm2))
+			Expect(children[1].object).To(Equal(cm1))
+			Expect(children[2].object).To(Equal(s1))
+		})
+
+		It("merges the children with the same UID", func() {
+			children := canonicalChildren([]configObject{
+				{object: cm1, keys: map[string]struct{}{"key1": {}}},
+				{object: cm2, allKeys: true},
+				{object: cm1.DeepCopy(), required: true, keys: map[string]struct{}{"key2": {}}},
+			})
+			Expect(children).To(HaveLen(2))
+			Expect(children[1].object.GetUID()).To(Equal(cm1.GetUID()))
+			Expect(children[1].required).To(BeTrue())
+			Expect(children[1].keys).To(Equal(map[string]struct{}{"key1": {}, "key2": {}}))
+		})
+
+		It("doesn't depend on the order of the children", func() {
+			children := []configObject{
+				{object: cm1, keys: map[string]struct{}{"key1": {}}},
+				{object: s1, allKeys: true},
+				{object: cm1.DeepCopy(), keys: map[string]struct{}{"key2": {}}},
+				{object: cm2, allKeys: true},
+			}
+			reversed := make([]configObject, len(children))
+			for i := range children {
+				reversed[len(children)-1-i] = children[i]
+			}
+
+			forward, err := calculateConfigHash(canonicalChildren(children))
+			Expect(err).NotTo(HaveOccurred())
+			backward, err := calculateConfigHash(canonicalChildren(reversed))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(forward).To(Equal(backward))
+			Expect(canonicalChildren(children)).To(Equal(canonicalChildren(reversed)))
+		})
+	})
+})
@@ -0,0 +1,106 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// envFromSource describes how a container imports a ConfigMap or Secret
+// through envFrom. The kubelet skips keys which, once prefixed, are not
+// valid environment variable names, and the container's own env entries
+// take precedence over imported variables of the same name.
+type envFromSource struct {
+	prefix   string
+	shadowed map[string]struct{}
+}
+
+// newEnvFromSource returns the envFromSource for an envFrom entry of the
+// container
+func newEnvFromSource(container corev1.Container, prefix string) envFromSource {
+	source := envFromSource{prefix: prefix, shadowed: make(map[string]struct{})}
+	for _, env := range container.Env {
+		source.shadowed[env.Name] = struct{}{}
+	}
+	return source
+}
+
+// imports returns true if the key becomes an environment variable of the
+// container
+func (s envFromSource) imports(key string) bool {
+	name := s.prefix + key
+	if len(validation.IsEnvVarName(name)) > 0 {
+		return false
+	}
+	_, ok := s.shadowed[name]
+	return !ok
+}
+
+// parseEnvFrom updates the metadata for a ConfigMap or Secret to include
+// the envFrom entry of the container
+func parseEnvFrom(metadata configMetadata, container corev1.Container, prefix string, optional *bool) configMetadata {
+	metadata.required = metadata.required || isRequired(optional)
+	if !metadata.allKeys {
+		metadata.envFrom = appendEnvFrom(metadata.envFrom, newEnvFromSource(container, prefix))
+	}
+	return metadata
+}
+
+// appendEnvFrom appends the source to the sources unless an identical one is
+// already among them
+func appendEnvFrom(sources []envFromSource, source envFromSource) []envFromSource {
+	for _, existing := range sources {
+		if reflect.DeepEqual(existing, source) {
+			return sources
+		}
+	}
+	return append(sources, source)
+}
+
+// hashesAllKeys returns true if every key of the child is part of the
+// configuration hash. Children imported through envFrom are hashed whole,
+// including keys the kubelet skips or env entries shadow, as they always
+// have been: hashing only the imported keys would change the hash of
+// existing workloads and restart them on upgrade.
+func (c configObject) hashesAllKeys() bool {
+	return c.allKeys || len(c.envFrom) > 0
+}
+
+// hashesKey returns true if the key of the child is part of the
+// configuration hash
+func (c configObject) hashesKey(key string) bool {
+	_, ok := c.keys[key]
+	return ok || c.hashesAllKeys()
+}
+
+// usesKey returns true if the key of the child is used by the workload,
+// either directly or through one of its envFrom sources. Only these keys are
+// reported as changed.
+func (c configObject) usesKey(key string) bool {
+	if _, ok := c.keys[key]; ok || c.allKeys {
+		return true
+	}
+	for _, source := range c.envFrom {
+		if source.imports(key) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,211 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Wave envFrom Suite", func() {
+	var cm *corev1.ConfigMap
+	var s *corev1.Secret
+
+	// children returns the children of a Deployment with the containers
+	children := func(containers ...corev1.Container) []configObject {
+		d := &deployment{&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: containers,
+			}}},
+		}}
+		configMaps, secrets := getChildNamesByType(d)
+		var children []configObject
+		if metadata, ok := configMaps[cm.GetName()]; ok {
+			children = append(children, configObject{object: cm, required: metadata.required, allKeys: metadata.allKeys, keys: metadata.keys, envFrom: metadata.envFrom})
+		}
+		if metadata, ok := secrets[s.GetName()]; ok {
+			children = append(children, configObject{object: s, required: metadata.required, allKeys: metadata.allKeys, keys: metadata.keys, envFrom: metadata.envFrom})
+		}
+		return children
+	}
+
+	// reported returns the keys of the child which are reported when changed
+	reported := func(child configObject) []string {
+		var keys []string
+		for key := range calculateSourceHashes([]configObject{child})[sourceKeyOf(child.object).String()] {
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	envFrom := func(prefix string) corev1.EnvFromSource {
+		return corev1.EnvFromSource{
+			Prefix:       prefix,
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()}},
+		}
+	}
+
+	BeforeEach(func() {
+		cm = &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data: map[string]string{
+				"key":      "value",
+				"1key":     "value",
+				"shadowed": "value",
+			},
+		}
+		s = &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data: map[string][]byte{
+				"key":  []byte("value"),
+				"1key": []byte("value"),
+			},
+		}
+	})
+
+	It("hashes every key of children imported through envFrom", func() {
+		children := children(corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("")},
+			Env:     []corev1.EnvVar{{Name: "shadowed", Value: "value"}},
+		})
+		Expect(children).To(HaveLen(1))
+		Expect(getConfigMapData(children[0])).To(Equal(cm.Data))
+
+		hash, err := calculateConfigHash(children)
+		Expect(err).NotTo(HaveOccurred())
+		whole, err := calculateConfigHash([]configObject{{object: cm, required: true, allKeys: true}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(hash).To(Equal(whole))
+	})
+
+	It("reports the keys which become environment variables", func() {
+		children := children(corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("")},
+			Env:     []corev1.EnvVar{{Name: "shadowed", Value: "value"}},
+		})
+		Expect(reported(children[0])).To(ConsistOf("key"))
+	})
+
+	It("applies the prefix before checking the variable names", func() {
+		children := children(corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("APP_")},
+			Env:     []corev1.EnvVar{{Name: "APP_shadowed", Value: "value"}},
+		})
+		Expect(reported(children[0])).To(ConsistOf("key", "1key"))
+	})
+
+	It("does not treat unprefixed env entries as shadowing prefixed keys", func() {
+		children := children(corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("APP_")},
+			Env:     []corev1.EnvVar{{Name: "shadowed", Value: "value"}},
+		})
+		Expect(reported(children[0])).To(ContainElement("shadowed"))
+	})
+
+	It("reports shadowed keys which are referenced individually", func() {
+		children := children(corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("")},
+			Env: []corev1.EnvVar{{Name: "shadowed", ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()}, Key: "shadowed"},
+			}}},
+		})
+		Expect(reported(children[0])).To(ConsistOf("key", "shadowed"))
+	})
+
+	It("reports the keys imported by any of the containers", func() {
+		children := children(
+			corev1.Container{
+				Name:    "first",
+				EnvFrom: []corev1.EnvFromSource{envFrom("")},
+				Env:     []corev1.EnvVar{{Name: "shadowed", Value: "value"}},
+			},
+			corev1.Container{
+				Name:    "second",
+				EnvFrom: []corev1.EnvFromSource{envFrom("APP_")},
+			},
+		)
+		Expect(reported(children[0])).To(ConsistOf("key", "1key", "shadowed"))
+	})
+
+	It("hashes every key of children also mounted as volumes", func() {
+		d := &deployment{&appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{Name: "config", VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()}},
+			}}},
+			Containers: []corev1.Container{{Name: "container", EnvFrom: []corev1.EnvFromSource{envFrom("")}}},
+		}}}}}
+		configMaps, _ := getChildNamesByType(d)
+		Expect(configMaps).To(HaveKeyWithValue(cm.GetName(), configMetadata{required: true, allKeys: true}))
+	})
+
+	It("models the prefixes of Secrets too", func() {
+		children := children(corev1.Container{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: s.GetName()}},
+			}},
+		})
+		Expect(getSecretData(children[0])).To(Equal(s.Data))
+		Expect(reported(children[0])).To(ConsistOf("key"))
+	})
+
+	It("restarts without reporting changes to keys which are not imported", func() {
+		container := corev1.Container{
+			Name:    "container",
+			EnvFrom: []corev1.EnvFromSource{envFrom("")},
+			Env:     []corev1.EnvVar{{Name: "shadowed", Value: "value"}},
+		}
+		hash := func() string {
+			hash, err := calculateConfigHash(children(container))
+			Expect(err).NotTo(HaveOccurred())
+			return hash
+		}
+		before := hash()
+		applied := calculateSourceHashes(children(container))
+
+		cm.Data["1key"] = "changed"
+		cm.Data["shadowed"] = "changed"
+		Expect(hash()).NotTo(Equal(before))
+		Expect(diffSourceHashes(applied, calculateSourceHashes(children(container)))).To(BeEmpty())
+
+		cm.Data["key"] = "changed"
+		Expect(diffSourceHashes(applied, calculateSourceHashes(children(container)))).To(HaveLen(1))
+	})
+
+	It("lists children imported through envFrom as referenced as a whole", func() {
+		references, err := References(&appsv1.Deployment{Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    "container",
+				EnvFrom: []corev1.EnvFromSource{envFrom("APP_")},
+				Env: []corev1.EnvVar{{Name: "other", ValueFrom: &corev1.EnvVarSource{
+					ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: cm.GetName()}, Key: "key"},
+				}}},
+			}},
+		}}}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(references).To(ConsistOf(Reference{Kind: "ConfigMap", Name: cm.GetName(), Required: true}))
+	})
+})
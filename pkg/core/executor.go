@@ -0,0 +1,195 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// ExecutedHashAnnotation is the key of the annotation on workloads rolled out
+// by an Executor which records the configuration hash it last rolled out, as
+// their pod templates are left as they are
+const ExecutedHashAnnotation = "wave.pusher.com/executed-hash"
+
+// Executor rolls out a new configuration hash of a workload in place of Wave
+// updating the hash on its pod template, for example by deleting its Pods or
+// asking an external deployment system to deploy it. Once Execute succeeds,
+// Wave records the hash in the ExecutedHashAnnotation of the workload.
+type Executor interface {
+	Execute(ctx context.Context, rollout Rollout) error
+}
+
+// Rollout describes a configuration hash an Executor rolls out
+type Rollout struct {
+	Namespace    string   `json:"namespace"`
+	Kind         string   `json:"kind"`
+	Name         string   `json:"name"`
+	Hash         string   `json:"hash"`
+	PreviousHash string   `json:"previousHash,omitempty"`
+	Sources      []string `json:"sources,omitempty"`
+
+	// Object is the workload as Wave last read it. It must not be modified.
+	Object runtime.Object `json:"-"`
+}
+
+// WithExecutor configures the Handler to roll out new configuration hashes
+// with the Executor. Blue/green rollouts are still rolled out by Wave.
+func WithExecutor(e Executor) Option {
+	return func(h *Handler) {
+		h.executor = e
+	}
+}
+
+// WithPodDeleteExecutor configures the Handler to roll out new configuration
+// hashes by deleting the Pods of workloads, leaving their pod templates as
+// they are for tools which own them, such as GitOps controllers
+func WithPodDeleteExecutor() Option {
+	return func(h *Handler) {
+		h.executor = &podDeleteExecutor{handler: h}
+	}
+}
+
+// usesExecutor returns true if the new configuration hashes of the instance
+// are rolled out by an Executor
+func (h *Handler) usesExecutor(instance podController) bool {
+	return h.executor != nil && !h.usesBlueGreen(instance)
+}
+
+// execute rolls out the hash with the Executor and records it on desired
+func (h *Handler) execute(ctx context.Context, instance, desired podController, hash string, changes []sourceChange) error {
+	err := h.executor.Execute(ctx, Rollout{
+		Namespace:    instance.GetNamespace(),
+		Kind:         kindOf(instance),
+		Name:         instance.GetName(),
+		Hash:         hash,
+		PreviousHash: getConfigHash(instance),
+		Sources:      sourceNames(changes),
+		Object:       instance.GetObject(),
+	})
+	if err != nil {
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "ExecutorFailed", "Unable to roll out configuration hash %s: %v", hash, err)
+		return err
+	}
+	setExecutedHash(desired, hash)
+	return nil
+}
+
+// setExecutedHash records the hash an Executor rolled out on the workload
+func setExecutedHash(obj podController, hash string) {
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[ExecutedHashAnnotation] = hash
+	obj.SetAnnotations(annotations)
+}
+
+// podDeleteExecutor rolls out configuration hashes by deleting every Pod of
+// the workload, which its controller replaces with Pods reading the new
+// configuration
+type podDeleteExecutor struct {
+	handler *Handler
+}
+
+// Execute deletes the Pods of the workload
+// +kubebuilder:rbac:groups=,resources=pods,verbs=delete
+func (e *podDeleteExecutor) Execute(ctx context.Context, rollout Rollout) error {
+	obj, ok := rollout.Object.(Object)
+	if !ok {
+		return fmt.Errorf("unsupported workload %T", rollout.Object)
+	}
+	instance, err := asPodController(obj)
+	if err != nil {
+		return err
+	}
+	pods, err := e.handler.getOwnedPods(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	// Report the deletions as rolling out the new hash
+	desired := instance.DeepCopy()
+	setExecutedHash(desired, rollout.Hash)
+	log := logf.Log.WithName("wave")
+	for i := range pods {
+		if !pods[i].GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		log.V(0).Info("Deleting Pod", "namespace", rollout.Namespace, "name", rollout.Name, "pod", pods[i].GetName(), "hash", rollout.Hash)
+		if err := e.handler.deletePod(ctx, &pods[i], desired); err != nil {
+			return errors.Wrapf(err, "error deleting Pod %s", pods[i].GetName())
+		}
+	}
+	return nil
+}
+
+// WebhookExecutor rolls out configuration hashes by posting the Rollout as
+// JSON to an endpoint, such as a webhook triggering a Spinnaker or Harness
+// pipeline which deploys the workload
+type WebhookExecutor struct {
+	url           string
+	authorization string
+	client        *http.Client
+}
+
+// NewWebhookExecutor constructs a WebhookExecutor posting to the URL. If
+// authorization is not empty it is sent as the Authorization header of each
+// request.
+func NewWebhookExecutor(url, authorization string, timeout time.Duration) *WebhookExecutor {
+	return &WebhookExecutor{
+		url:           url,
+		authorization: authorization,
+		client:        &http.Client{Timeout: timeout},
+	}
+}
+
+// Execute posts the Rollout to the endpoint, which must respond with a 2xx
+// status once it accepted the rollout
+func (w *WebhookExecutor) Execute(ctx context.Context, rollout Rollout) error {
+	data, err := json.Marshal(rollout)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "error building request")
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if w.authorization != "" {
+		req.Header.Set("Authorization", w.authorization)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "error calling executor endpoint")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from executor endpoint: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave restart executor Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var d *appsv1.Deployment
+
+	labels := map[string]string{"app": "example"}
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() error {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		return err
+	}
+
+	setup := func(opt Option, objs ...runtime.Object) {
+		c = fake.NewFakeClient(append(objs, d)...)
+		recorder = record.NewFakeRecorder(100)
+		h = NewHandler(c, recorder, opt)
+	}
+
+	BeforeEach(func() {
+		d = &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "example",
+				UID:         types.UID("example-uid"),
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			},
+			Spec: appsv1.DeploymentSpec{Selector: &metav1.LabelSelector{MatchLabels: labels}},
+		}
+		d.Spec.Template.SetAnnotations(map[string]string{ConfigHashAnnotation: "old"})
+	})
+
+	Context("with the webhook executor", func() {
+		var server *httptest.Server
+		var rollouts chan Rollout
+		var status int
+
+		BeforeEach(func() {
+			rollouts = make(chan Rollout, 1)
+			status = http.StatusAccepted
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				Expect(r.Header.Get("Authorization")).To(Equal("Bearer secret"))
+				rollout := Rollout{}
+				Expect(json.NewDecoder(r.Body).Decode(&rollout)).To(Succeed())
+				rollouts <- rollout
+				w.WriteHeader(status)
+			}))
+			setup(WithExecutor(NewWebhookExecutor(server.URL, "Bearer secret", time.Second)))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("posts the rollout and leaves the pod template as it is", func() {
+			Expect(handle()).To(Succeed())
+			var rollout Rollout
+			Eventually(rollouts).Should(Receive(&rollout))
+			Expect(rollout.Namespace).To(Equal("default"))
+			Expect(rollout.Kind).To(Equal("Deployment"))
+			Expect(rollout.Name).To(Equal("example"))
+			Expect(rollout.PreviousHash).To(Equal("old"))
+			Expect(rollout.Hash).NotTo(BeEmpty())
+
+			updated := getDeployment()
+			Expect(updated.Spec.Template.GetAnnotations()[ConfigHashAnnotation]).To(Equal("old"))
+			Expect(updated.GetAnnotations()[ExecutedHashAnnotation]).To(Equal(rollout.Hash))
+			Expect(getConfigHash(&deployment{updated})).To(Equal(rollout.Hash))
+
+			// The executed hash is not rolled out again
+			Expect(handle()).To(Succeed())
+			Consistently(rollouts).ShouldNot(Receive())
+		})
+
+		It("leaves the hash as it is when the endpoint fails", func() {
+			status = http.StatusInternalServerError
+			Expect(handle()).To(MatchError(ContainSubstring("500 Internal Server Error")))
+			Expect(getDeployment().GetAnnotations()).NotTo(HaveKey(ExecutedHashAnnotation))
+			Expect(recorder.Events).To(Receive(ContainSubstring("ExecutorFailed")))
+		})
+	})
+
+	Context("with the pod delete executor", func() {
+		It("deletes the Pods of the workload", func() {
+			controller := true
+			pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            "example-abc",
+				Labels:          labels,
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "Deployment", Name: "example", UID: d.GetUID(), Controller: &controller}},
+			}}
+			other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other", Labels: labels}}
+			setup(WithPodDeleteExecutor(), pod, other)
+
+			Expect(handle()).To(Succeed())
+			pods := &corev1.PodList{}
+			Expect(c.List(context.TODO(), pods)).To(Succeed())
+			Expect(pods.Items).To(HaveLen(1))
+			Expect(pods.Items[0].GetName()).To(Equal("other"))
+
+			updated := getDeployment()
+			Expect(updated.Spec.Template.GetAnnotations()[ConfigHashAnnotation]).To(Equal("old"))
+			Expect(updated.GetAnnotations()[ExecutedHashAnnotation]).NotTo(BeEmpty())
+		})
+	})
+
+	It("replaces the executed hash when patching the pod template", func() {
+		d.Annotations[ExecutedHashAnnotation] = "executed"
+		instance := &deployment{d}
+		Expect(getConfigHash(instance)).To(Equal("executed"))
+
+		setConfigHash(instance, "new")
+		Expect(d.GetAnnotations()).NotTo(HaveKey(ExecutedHashAnnotation))
+		Expect(getConfigHash(instance)).To(Equal("new"))
+	})
+})
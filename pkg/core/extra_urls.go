@@ -0,0 +1,180 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ExtraURLsAnnotation is the key of the annotation listing, comma separated,
+// the URLs of configuration a workload fetches from outside the cluster. The
+// hashes of their responses are mixed into the workload's configuration hash.
+const ExtraURLsAnnotation = "wave.pusher.com/extra-urls"
+
+// ExtraURLOptions configures the fetching of the URLs workloads list in the
+// ExtraURLsAnnotation
+type ExtraURLOptions struct {
+	// PollInterval is how often the URLs of a workload are fetched again, as
+	// no event announces their changes
+	PollInterval time.Duration
+
+	// Timeout limits each request of a URL
+	Timeout time.Duration
+
+	// AllowedPrefixes are the prefixes of the URLs Wave may fetch. If empty,
+	// every http and https URL may be fetched.
+	AllowedPrefixes []string
+}
+
+// WithExtraURLs allows workloads to list configuration fetched from outside
+// the cluster with the ExtraURLsAnnotation
+func WithExtraURLs(o ExtraURLOptions) Option {
+	return func(h *Handler) {
+		h.extraURLs = newURLFetcher(o)
+	}
+}
+
+// urlVersion is the hash of the last response of a URL and its ETag
+type urlVersion struct {
+	etag string
+	hash string
+}
+
+// urlFetcher fetches URLs, revalidating the responses of URLs which returned
+// an ETag instead of fetching them again
+type urlFetcher struct {
+	ExtraURLOptions
+	client *http.Client
+
+	mutex    sync.Mutex
+	versions map[string]urlVersion
+}
+
+// newURLFetcher constructs a urlFetcher
+func newURLFetcher(o ExtraURLOptions) *urlFetcher {
+	return &urlFetcher{
+		ExtraURLOptions: o,
+		client:          &http.Client{Timeout: o.Timeout},
+		versions:        make(map[string]urlVersion),
+	}
+}
+
+// allowed returns true if the URL may be fetched
+func (f *urlFetcher) allowed(url string) bool {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return false
+	}
+	if len(f.AllowedPrefixes) == 0 {
+		return true
+	}
+	for _, prefix := range f.AllowedPrefixes {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// fetch returns the hash of the current response of the URL
+func (f *urlFetcher) fetch(ctx context.Context, url string) (string, error) {
+	f.mutex.Lock()
+	cached, ok := f.versions[url]
+	f.mutex.Unlock()
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return "", errors.Wrap(err, "error building request")
+	}
+	req = req.WithContext(ctx)
+	if ok && cached.etag != "" {
+		req.Header.Set("If-None-Match", cached.etag)
+	}
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", errors.Wrapf(err, "error fetching %s", url)
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && ok:
+		return cached.hash, nil
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		return "", fmt.Errorf("unexpected response fetching %s: %s", url, resp.Status)
+	}
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, resp.Body); err != nil {
+		return "", errors.Wrapf(err, "error reading %s", url)
+	}
+	version := urlVersion{etag: resp.Header.Get("ETag"), hash: fmt.Sprintf("%x", hasher.Sum(nil))}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.versions[url] = version
+	return version.hash, nil
+}
+
+// getExtraURLs parses the ExtraURLsAnnotation of the instance
+func getExtraURLs(obj podController) []string {
+	var urls []string
+	for _, url := range strings.Split(obj.GetAnnotations()[ExtraURLsAnnotation], ",") {
+		if url = strings.TrimSpace(url); url != "" {
+			urls = append(urls, url)
+		}
+	}
+	return urls
+}
+
+// getURLHashes fetches the URLs the instance lists and returns the hashes of
+// their responses. URLs which may not be fetched are reported and left out of
+// the hash.
+func (h *Handler) getURLHashes(ctx context.Context, instance podController) (map[string]string, error) {
+	hashes := make(map[string]string)
+	if h.extraURLs == nil {
+		return hashes, nil
+	}
+	for _, url := range getExtraURLs(instance) {
+		if !h.extraURLs.allowed(url) {
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "ExtraURLDenied", "Ignoring URL %s: it is not an allowed http or https URL", url)
+			continue
+		}
+		hash, err := h.extraURLs.fetch(ctx, url)
+		if err != nil {
+			return nil, err
+		}
+		hashes[url] = hash
+	}
+	return hashes, nil
+}
+
+// extraURLPollInterval returns how long to wait before fetching the URLs of
+// the instance again, or zero if it lists none
+func (h *Handler) extraURLPollInterval(instance podController) time.Duration {
+	if h.extraURLs == nil || len(getExtraURLs(instance)) == 0 {
+		return 0
+	}
+	return h.extraURLs.PollInterval
+}
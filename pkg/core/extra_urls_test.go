@@ -0,0 +1,133 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave extra URLs Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var server *httptest.Server
+	var body, etag string
+	var status int
+	var revalidated int
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() (time.Duration, error) {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		return result.RequeueAfter, err
+	}
+
+	setup := func(urls string, o ExtraURLOptions) {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "example",
+			Annotations: map[string]string{
+				RequiredAnnotation:  "true",
+				ExtraURLsAnnotation: urls,
+			},
+		}}
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		h = NewHandler(c, recorder, WithExtraURLs(o))
+	}
+
+	BeforeEach(func() {
+		body, etag, status, revalidated = `{"mode": "production"}`, `"v1"`, http.StatusOK, 0
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if status != http.StatusOK {
+				w.WriteHeader(status)
+				return
+			}
+			if r.Header.Get("If-None-Match") == etag {
+				revalidated++
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", etag)
+			w.Write([]byte(body))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("restarts the workload when the response of a URL changes", func() {
+		setup(server.URL+"/app.json", ExtraURLOptions{PollInterval: time.Minute})
+		wait, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(Equal(time.Minute))
+		hash := getConfigHash(&deployment{getDeployment()})
+		Expect(hash).NotTo(BeEmpty())
+
+		// Unchanged responses are revalidated with their ETag
+		_, err = handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(revalidated).To(Equal(1))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal(hash))
+
+		body, etag = `{"mode": "maintenance"}`, `"v2"`
+		_, err = handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal(hash))
+	})
+
+	It("leaves the hash as it is when a URL cannot be fetched", func() {
+		status = http.StatusServiceUnavailable
+		setup(server.URL+"/app.json", ExtraURLOptions{})
+		_, err := handle()
+		Expect(err).To(MatchError(ContainSubstring("503 Service Unavailable")))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+	})
+
+	It("ignores URLs without an allowed prefix", func() {
+		setup(server.URL+"/app.json, file:///etc/passwd", ExtraURLOptions{AllowedPrefixes: []string{"https://config.internal/"}})
+		_, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		events := func() []string {
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			return events
+		}()
+		Expect(events).To(ContainElement(And(ContainSubstring("ExtraURLDenied"), ContainSubstring(server.URL+"/app.json"))))
+		Expect(events).To(ContainElement(And(ContainSubstring("ExtraURLDenied"), ContainSubstring("file:///etc/passwd"))))
+		Expect(revalidated).To(BeZero())
+	})
+})
@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+var _ = Describe("Wave filter Suite", func() {
+	var c client.Client
+
+	// team only accepts workloads labelled for team a
+	team := predicate.Funcs{
+		GenericFunc: func(e event.GenericEvent) bool {
+			return e.Meta.GetLabels()["team"] == "a"
+		},
+	}
+
+	handle := func(opts ...Option) *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		key := types.NamespacedName{Namespace: "default", Name: "example"}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		_, err := NewHandler(c, record.NewFakeRecorder(10), opts...).HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+
+		d = &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		return d
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Labels:      map[string]string{"team": "b"},
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}})
+	})
+
+	It("leaves workloads rejected by a workload filter untouched", func() {
+		d := handle(WithWorkloadFilter(team))
+		Expect(d.GetFinalizers()).To(BeEmpty())
+		Expect(d.Spec.Template.GetAnnotations()).NotTo(HaveKey(ConfigHashAnnotation))
+	})
+
+	It("handles workloads accepted by every workload filter", func() {
+		d := handle(WithWorkloadFilter(predicate.Funcs{}))
+		Expect(d.Spec.Template.GetAnnotations()).To(HaveKey(ConfigHashAnnotation))
+	})
+
+	It("exposes the filters and watches to controllers", func() {
+		watches := NewHandler(c, nil, WithEventFilter(team), WithWorkloadFilter(team), WithWatch(Watch{})).Watches()
+		Expect(watches.Predicates).To(HaveLen(1))
+		Expect(watches.WorkloadPredicates).To(HaveLen(1))
+		Expect(watches.Extra).To(HaveLen(1))
+	})
+})
@@ -0,0 +1,320 @@
+// +build gofuzz
+
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// The targets in this file are built by go-fuzz, see `make fuzz`, and
+// exercised with random inputs by the tests when built with the gofuzz tag.
+
+// fuzzNames and fuzzKeys are small so that inputs often reference the same
+// ConfigMaps, Secrets and keys in different ways
+var (
+	fuzzNames = []string{"a", "b", "c", "d"}
+	fuzzKeys  = []string{"k1", "k2", "k3", "k4"}
+)
+
+// fuzzInput decodes structure from the bytes of a fuzzing input, returning
+// zeros once they run out
+type fuzzInput struct {
+	data []byte
+}
+
+// byte consumes and returns the next byte
+func (in *fuzzInput) byte() byte {
+	if len(in.data) == 0 {
+		return 0
+	}
+	b := in.data[0]
+	in.data = in.data[1:]
+	return b
+}
+
+// intn returns a number in [0, n)
+func (in *fuzzInput) intn(n int) int {
+	return int(in.byte()) % n
+}
+
+// bool returns a boolean
+func (in *fuzzInput) bool() bool {
+	return in.byte()&1 == 1
+}
+
+// optional returns a nil, false or true optional flag
+func (in *fuzzInput) optional() *bool {
+	switch in.intn(3) {
+	case 0:
+		return nil
+	case 1:
+		b := false
+		return &b
+	default:
+		b := true
+		return &b
+	}
+}
+
+// name returns the name of a ConfigMap or Secret
+func (in *fuzzInput) name() string {
+	return fuzzNames[in.intn(len(fuzzNames))]
+}
+
+// key returns a key of a ConfigMap or Secret
+func (in *fuzzInput) key() string {
+	return fuzzKeys[in.intn(len(fuzzKeys))]
+}
+
+// declaredRef is a reference the generated PodSpec declares. An empty key
+// references the whole ConfigMap or Secret.
+type declaredRef struct {
+	kind string
+	name string
+	key  string
+}
+
+// fuzzPodSpec generates a PodSpec referencing ConfigMaps and Secrets in
+// every way Wave supports, and returns the references it declares
+func fuzzPodSpec(in *fuzzInput) (corev1.PodSpec, []declaredRef) {
+	spec := corev1.PodSpec{}
+	var refs []declaredRef
+
+	for i := in.intn(4); i > 0; i-- {
+		vol := corev1.Volume{Name: fmt.Sprintf("volume-%d", len(spec.Volumes))}
+		switch in.intn(3) {
+		case 0:
+			cm := &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: in.name()}, Optional: in.optional()}
+			cm.Items = fuzzItems(in)
+			vol.ConfigMap = cm
+			refs = append(refs, declaredRef{kind: "ConfigMap", name: cm.Name})
+		case 1:
+			s := &corev1.SecretVolumeSource{SecretName: in.name(), Optional: in.optional()}
+			s.Items = fuzzItems(in)
+			vol.Secret = s
+			refs = append(refs, declaredRef{kind: "Secret", name: s.SecretName})
+		default:
+			projected := &corev1.ProjectedVolumeSource{}
+			for j := in.intn(4); j > 0; j-- {
+				local := corev1.LocalObjectReference{Name: in.name()}
+				if in.bool() {
+					projected.Sources = append(projected.Sources, corev1.VolumeProjection{
+						ConfigMap: &corev1.ConfigMapProjection{LocalObjectReference: local, Items: fuzzItems(in), Optional: in.optional()},
+					})
+					refs = append(refs, declaredRef{kind: "ConfigMap", name: local.Name})
+				} else {
+					projected.Sources = append(projected.Sources, corev1.VolumeProjection{
+						Secret: &corev1.SecretProjection{LocalObjectReference: local, Items: fuzzItems(in), Optional: in.optional()},
+					})
+					refs = append(refs, declaredRef{kind: "Secret", name: local.Name})
+				}
+			}
+			vol.Projected = projected
+		}
+		spec.Volumes = append(spec.Volumes, vol)
+	}
+
+	for i := 1 + in.intn(3); i > 0; i-- {
+		container := corev1.Container{Name: fmt.Sprintf("container-%d", len(spec.Containers))}
+		for j := in.intn(4); j > 0; j-- {
+			local := corev1.LocalObjectReference{Name: in.name()}
+			if in.bool() {
+				container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+					ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: local, Optional: in.optional()},
+				})
+				refs = append(refs, declaredRef{kind: "ConfigMap", name: local.Name})
+			} else {
+				container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+					SecretRef: &corev1.SecretEnvSource{LocalObjectReference: local, Optional: in.optional()},
+				})
+				refs = append(refs, declaredRef{kind: "Secret", name: local.Name})
+			}
+		}
+		for j := in.intn(6); j > 0; j-- {
+			env := corev1.EnvVar{Name: fmt.Sprintf("ENV_%d", len(container.Env))}
+			local := corev1.LocalObjectReference{Name: in.name()}
+			key := in.key()
+			switch in.intn(3) {
+			case 0:
+				env.ValueFrom = &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: local, Key: key, Optional: in.optional()}}
+				refs = append(refs, declaredRef{kind: "ConfigMap", name: local.Name, key: key})
+			case 1:
+				env.ValueFrom = &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: local, Key: key, Optional: in.optional()}}
+				refs = append(refs, declaredRef{kind: "Secret", name: local.Name, key: key})
+			default:
+				env.Value = key
+			}
+			container.Env = append(container.Env, env)
+		}
+		spec.Containers = append(spec.Containers, container)
+	}
+	return spec, refs
+}
+
+// fuzzItems returns the items of a volume, which Wave ignores as it hashes
+// every key of the ConfigMap or Secret
+func fuzzItems(in *fuzzInput) []corev1.KeyToPath {
+	var items []corev1.KeyToPath
+	for i := in.intn(3); i > 0; i-- {
+		items = append(items, corev1.KeyToPath{Key: in.key(), Path: in.key()})
+	}
+	return items
+}
+
+// fuzzDeployment wraps the PodSpec in a Deployment
+func fuzzDeployment(spec corev1.PodSpec) *deployment {
+	return &deployment{&appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "fuzz"},
+		Spec:       appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: spec}},
+	}}
+}
+
+// checkReferences returns an error if the children extracted from the
+// Deployment miss a declared reference, or include one never declared
+func checkReferences(refs []declaredRef, configMaps, secrets map[string]configMetadata) error {
+	declared := map[string]map[string]bool{"ConfigMap": {}, "Secret": {}}
+	for _, ref := range refs {
+		children := configMaps
+		if ref.kind == "Secret" {
+			children = secrets
+		}
+		metadata, ok := children[ref.name]
+		if !ok {
+			return fmt.Errorf("%s %s is referenced but was not extracted", ref.kind, ref.name)
+		}
+		if ref.key == "" && !metadata.allKeys && len(metadata.envFrom) == 0 {
+			return fmt.Errorf("%s %s is referenced as a whole but only keys %v were extracted", ref.kind, ref.name, metadata.keys)
+		}
+		if _, ok := metadata.keys[ref.key]; ref.key != "" && !metadata.allKeys && !ok {
+			return fmt.Errorf("key %s of %s %s is referenced but was not extracted", ref.key, ref.kind, ref.name)
+		}
+		declared[ref.kind][ref.name] = true
+	}
+	for name := range configMaps {
+		if !declared["ConfigMap"][name] {
+			return fmt.Errorf("ConfigMap %s was extracted but never referenced", name)
+		}
+	}
+	for name := range secrets {
+		if !declared["Secret"][name] {
+			return fmt.Errorf("Secret %s was extracted but never referenced", name)
+		}
+	}
+	return nil
+}
+
+// FuzzReferences checks that extracting the children of an arbitrary
+// PodSpec never panics, misses a declared reference or invents one
+func FuzzReferences(data []byte) int {
+	spec, refs := fuzzPodSpec(&fuzzInput{data: data})
+	configMaps, secrets := getChildNamesByType(fuzzDeployment(spec))
+	if err := checkReferences(refs, configMaps, secrets); err != nil {
+		panic(err)
+	}
+	if len(refs) == 0 {
+		return 0
+	}
+	return 1
+}
+
+// fuzzChildren generates the contents of the ConfigMaps and Secrets
+// extracted from a Deployment
+func fuzzChildren(in *fuzzInput, configMaps, secrets map[string]configMetadata) []configObject {
+	var children []configObject
+	for _, name := range fuzzNames {
+		if metadata, ok := configMaps[name]; ok {
+			cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}, Data: map[string]string{}}
+			for i := in.intn(len(fuzzKeys) + 1); i > 0; i-- {
+				cm.Data[in.key()] = string([]byte{in.byte(), in.byte()})
+			}
+			children = append(children, configObject{object: cm, required: metadata.required, allKeys: metadata.allKeys, keys: metadata.keys, envFrom: metadata.envFrom})
+		}
+		if metadata, ok := secrets[name]; ok {
+			s := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}, Data: map[string][]byte{}}
+			for i := in.intn(len(fuzzKeys) + 1); i > 0; i-- {
+				s.Data[in.key()] = []byte{in.byte(), in.byte()}
+			}
+			children = append(children, configObject{object: s, required: metadata.required, allKeys: metadata.allKeys, keys: metadata.keys, envFrom: metadata.envFrom})
+		}
+	}
+	return children
+}
+
+// hashed returns true if the key of the child is part of the hash
+func hashed(child configObject, key string) bool {
+	return child.hashesKey(key)
+}
+
+// FuzzHash checks that the configuration hash of arbitrary children is
+// stable: independent of their order, unaffected by keys which aren't
+// referenced, and changed by any change to a referenced key
+func FuzzHash(data []byte) int {
+	in := &fuzzInput{data: data}
+	spec, _ := fuzzPodSpec(in)
+	configMaps, secrets := getChildNamesByType(fuzzDeployment(spec))
+	children := fuzzChildren(in, configMaps, secrets)
+	if len(children) == 0 {
+		return 0
+	}
+
+	hash, err := calculateConfigHash(children)
+	if err != nil {
+		panic(err)
+	}
+
+	// The order of the children doesn't matter
+	reversed := make([]configObject, 0, len(children))
+	for i := len(children) - 1; i >= 0; i-- {
+		reversed = append(reversed, children[i])
+	}
+	if h, _ := calculateConfigHash(reversed); h != hash {
+		panic(fmt.Sprintf("hash changed from %s to %s when the children were reordered", hash, h))
+	}
+
+	// Change a single key of a single child
+	i := in.intn(len(children))
+	key := in.key()
+	changed := make([]configObject, len(children))
+	copy(changed, children)
+	switch obj := children[i].object.(type) {
+	case *corev1.ConfigMap:
+		cm := obj.DeepCopy()
+		cm.Data[key] = cm.Data[key] + "-changed"
+		changed[i].object = cm
+	case *corev1.Secret:
+		s := obj.DeepCopy()
+		s.Data[key] = append(append([]byte{}, s.Data[key]...), []byte("-changed")...)
+		changed[i].object = s
+	}
+	h, err := calculateConfigHash(changed)
+	if err != nil {
+		panic(err)
+	}
+	if hashed(children[i], key) && h == hash {
+		panic(fmt.Sprintf("hash didn't change when referenced key %s of %s changed", key, children[i].object.GetName()))
+	}
+	if !hashed(children[i], key) && h != hash {
+		panic(fmt.Sprintf("hash changed when unreferenced key %s of %s changed", key, children[i].object.GetName()))
+	}
+	return 1
+}
@@ -0,0 +1,57 @@
+// +build gofuzz
+
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"math/rand"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wave fuzz Suite", func() {
+	// inputs returns random fuzzing inputs
+	inputs := func() [][]byte {
+		r := rand.New(rand.NewSource(GinkgoRandomSeed()))
+		var inputs [][]byte
+		for i := 0; i < 2000; i++ {
+			data := make([]byte, r.Intn(256))
+			r.Read(data)
+			inputs = append(inputs, data)
+		}
+		return inputs
+	}
+
+	It("extracts every declared reference", func() {
+		for _, data := range inputs() {
+			Expect(func() { FuzzReferences(data) }).NotTo(Panic(), "input %v", data)
+		}
+	})
+
+	It("produces stable hashes", func() {
+		for _, data := range inputs() {
+			Expect(func() { FuzzHash(data) }).NotTo(Panic(), "input %v", data)
+		}
+	})
+
+	It("handles empty inputs", func() {
+		Expect(FuzzReferences(nil)).To(Equal(0))
+		Expect(FuzzHash(nil)).To(Equal(0))
+	})
+})
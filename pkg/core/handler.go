@@ -19,12 +19,22 @@ package core
 import (
 	"context"
 	"fmt"
-	"reflect"
+	"sync"
+	"time"
 
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/pkg/capabilities"
+	"github.com/wave-k8s/wave/pkg/concurrency"
+	"github.com/wave-k8s/wave/pkg/fanout"
+	"github.com/wave-k8s/wave/pkg/faults"
+	"github.com/wave-k8s/wave/pkg/notify"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
 )
@@ -32,39 +42,145 @@ import (
 // Handler performs the main business logic of the Wave controller
 type Handler struct {
 	client.Client
-	recorder record.EventRecorder
+	recorder   record.EventRecorder
+	notifier   notify.Notifier
+	sources    *sourceTracker
+	hashes     *hashTracker
+	debounce   *debouncer
+	invalid    *reporter
+	held       *reporter
+	gate       *rolloutGate
+	policy     *policyHook
+	audit      *AuditOptions
+	clock      Clock
+	denyList   *SecretDenyList
+	sidecars   *SidecarOptions
+	onDelete   *OnDeleteOptions
+	daemonSets *DaemonSetOptions
+	blueGreen  *BlueGreenOptions
+	hooks      *RestartHookOptions
+	canary     *CanaryOptions
+	plugins    *PluginOptions
+	executor   Executor
+	extraURLs  *urlFetcher
+	budget     *RolloutBudget
+	shadow     *shadowTracker
+
+	impersonator *impersonator
+	faults       *faults.Injector
+
+	predicates         []predicate.Predicate
+	workloadPredicates []predicate.Predicate
+	extraWatches       []Watch
+
+	reconcileTimeout   time.Duration
+	initialRolloutWait time.Duration
+	awaitSecretData    time.Duration
+	snapshotRevisions  int
+	killSwitch         *types.NamespacedName
+	kinds              WorkloadKinds
+	concurrency        concurrency.Options
+	capabilities       *capabilities.Capabilities
+	fanOut             *fanout.Pool
+
+	// background tracks the notifications and audit records being sent
+	background sync.WaitGroup
+
+	secretMetadataOnly bool
+	csiSecretsStore    bool
+	namespacePriority  bool
+	holdRecreate       bool
+	holdInFlight       bool
+	holdFailed         bool
+
+	serviceAccountTokens bool
+	statusAnnotation     bool
 }
 
 // NewHandler constructs a new instance of Handler
-func NewHandler(c client.Client, r record.EventRecorder) *Handler {
-	return &Handler{Client: c, recorder: r}
+func NewHandler(c client.Client, r record.EventRecorder, opts ...Option) *Handler {
+	h := &Handler{Client: c, recorder: r, sources: newSourceTracker(), hashes: newHashTracker(), debounce: newDebouncer(), invalid: newReporter(), held: newReporter()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
-// HandleDeployment is called by the deployment controller to reconcile deployments
-func (h *Handler) HandleDeployment(instance *appsv1.Deployment) (reconcile.Result, error) {
-	return h.handlePodController(&deployment{Deployment: instance})
+// HandleDeployment is called by the deployment controller to reconcile deployments.
+// Every API call made is bound to ctx.
+func (h *Handler) HandleDeployment(ctx context.Context, instance *appsv1.Deployment) (reconcile.Result, error) {
+	return h.handle(ctx, &deployment{Deployment: instance})
 }
 
 // HandleStatefulSet is called by the StatefulSet controller to reconcile StatefulSets
-func (h *Handler) HandleStatefulSet(instance *appsv1.StatefulSet) (reconcile.Result, error) {
-	return h.handlePodController(&statefulset{StatefulSet: instance})
+func (h *Handler) HandleStatefulSet(ctx context.Context, instance *appsv1.StatefulSet) (reconcile.Result, error) {
+	return h.handle(ctx, &statefulset{StatefulSet: instance})
 }
 
 // HandleDaemonSet is called by the DaemonSet controller to reconcile DaemonSets
-func (h *Handler) HandleDaemonSet(instance *appsv1.DaemonSet) (reconcile.Result, error) {
-	return h.handlePodController(&daemonset{DaemonSet: instance})
+func (h *Handler) HandleDaemonSet(ctx context.Context, instance *appsv1.DaemonSet) (reconcile.Result, error) {
+	return h.handle(ctx, &daemonset{DaemonSet: instance})
+}
+
+// handle reconciles the state of a podController within the deadline of the
+// context, counting reconciles which exceed it and reporting the errors
+// reconciles fail with. Shadow instances only
+// compare their decision against the active instance's, and nothing is
+// reconciled while the kill switch is engaged.
+func (h *Handler) handle(ctx context.Context, instance podController) (reconcile.Result, error) {
+	if !h.accepts(instance) {
+		return reconcile.Result{}, nil
+	}
+	engaged, err := h.killSwitchEngaged(ctx)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	if engaged {
+		log := logf.Log.WithName("wave")
+		log.V(1).Info("Kill switch engaged, not reconciling", "namespace", instance.GetNamespace(), "name", instance.GetName())
+		return reconcile.Result{}, nil
+	}
+	handle := h.handlePodController
+	if h.shadow != nil {
+		handle = h.handleShadow
+	}
+	result, err := handle(ctx, instance)
+	observeDeadline(ctx, instance)
+	if err != nil {
+		h.reportError(instance, err)
+	}
+	return result, err
 }
 
 // handlePodController reconciles the state of a podController
-func (h *Handler) handlePodController(instance podController) (reconcile.Result, error) {
+func (h *Handler) handlePodController(ctx context.Context, instance podController) (reconcile.Result, error) {
 	log := logf.Log.WithName("wave")
 
+	// Clean up instances marked for deletion whatever their trigger policy,
+	// so that an invalid one can't hold up their deletion
+	if toBeDeleted(instance) && hasFinalizer(instance) {
+		log.V(0).Info("Instance marked for deletion, cleaning up orphans", "namespace", instance.GetNamespace(), "name", instance.GetName())
+		h.forget(instance)
+		return h.handleDelete(ctx, instance)
+	}
+
+	// Leave instances with an invalid trigger policy as they are until it
+	// is fixed, warning once about each invalid value
+	if _, err := TriggerPolicyOf(instance); err != nil {
+		if h.invalid.changed(instance.GetUID(), instance.GetAnnotations()[RequiredAnnotation]) {
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "InvalidTriggerPolicy", "Not reconciling: %v", err)
+		}
+		return reconcile.Result{}, nil
+	}
+	h.invalid.forget(instance.GetUID())
+
 	// If the required annotation isn't present, ignore the instance
 	if !hasRequiredAnnotation(instance) {
 		// Perform deletion logic if the finalizer is present on the object
 		if hasFinalizer(instance) {
 			log.V(0).Info("Required annotation removed from instance, cleaning up orphans", "namespace", instance.GetNamespace(), "name", instance.GetName())
-			return h.handleDelete(instance)
+			h.forget(instance)
+			return h.handleDelete(ctx, instance)
 		}
 		return reconcile.Result{}, nil
 	}
@@ -72,46 +188,319 @@ func (h *Handler) handlePodController(instance podController) (reconcile.Result,
 	// If the instance is marked for deletion, run cleanup process
 	if toBeDeleted(instance) {
 		log.V(0).Info("Instance marked for deletion, cleaning up orphans", "namespace", instance.GetNamespace(), "name", instance.GetName())
-		return h.handleDelete(instance)
+		h.forget(instance)
+		return h.handleDelete(ctx, instance)
+	}
+
+	// Leave instances with an invalid hash path as they are until it is
+	// fixed, rather than placing the hash where they don't expect it
+	if _, err := HashPathOf(instance); err != nil {
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "InvalidHashPath", "Not reconciling: %v", err)
+		return reconcile.Result{}, nil
 	}
 
+	// Report changes made to the hash by others before restoring it
+	h.checkTampered(instance)
+
+	// Free the instance's slot of the rollout budget once it has rolled out
+	h.releaseBudget(instance)
+
 	// Get all children that have an OwnerReference pointing to this instance
-	existing, err := h.getExistingChildren(instance)
+	existing, err := h.getExistingChildren(ctx, instance)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error fetching existing children: %v", err)
+		return reconcile.Result{}, errors.Wrap(err, "error fetching existing children")
 	}
 
 	// Get all children that the instance currently references
-	current, err := h.getCurrentChildren(instance)
+	current, err := h.getCurrentChildren(ctx, instance)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error fetching current children: %v", err)
+		return reconcile.Result{}, errors.Wrap(err, "error fetching current children")
 	}
 
 	// Reconcile the OwnerReferences on the existing and current children
-	err = h.updateOwnerReferences(instance, existing, current)
+	err = h.updateOwnerReferences(ctx, instance, existing, current)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error updating OwnerReferences: %v", err)
+		return reconcile.Result{}, errors.Wrap(err, "error updating OwnerReferences")
 	}
 
-	hash, err := calculateConfigHash(current)
+	hash, csiHistory, err := h.currentHash(ctx, instance, current)
 	if err != nil {
-		return reconcile.Result{}, fmt.Errorf("error calculating configuration hash: %v", err)
+		return reconcile.Result{}, err
+	}
+
+	// Check whether the rollout may proceed now
+	hashChanged := getConfigHash(instance) != hash
+	result := reconcile.Result{}
+	var changes []sourceChange
+	if hashChanged {
+		changes = h.sources.diff(instance.GetUID(), current)
+		admitted, wait, err := h.admitRollout(ctx, instance, current, hash, changes)
+		if err != nil {
+			return reconcile.Result{}, err
+		}
+		if !admitted {
+			hashChanged = false
+			result.RequeueAfter = wait
+		}
+	}
+
+	// Evaluate the configuration in a canary before rolling it out
+	if hashChanged && h.usesCanary(instance) {
+		passed, wait, err := h.evaluateCanary(ctx, instance, hash, changes)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "error evaluating canary")
+		}
+		if !passed {
+			hashChanged = false
+			result.RequeueAfter = wait
+		}
+	}
+
+	// Run the pre-restart hook before rolling out
+	if hashChanged {
+		wait, err := h.runPreRestartHook(ctx, instance, hash, changes)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "error running pre-restart hook")
+		}
+		if wait > 0 {
+			hashChanged = false
+			result.RequeueAfter = wait
+		}
+	}
+
+	// Blue/green rollouts only update the hash once a copy of the Deployment
+	// running the new configuration serves it
+	if hashChanged && h.usesBlueGreen(instance) {
+		switched, err := h.rollBlueGreen(ctx, instance, hash)
+		if err != nil {
+			return reconcile.Result{}, errors.Wrap(err, "error rolling out copy")
+		}
+		if !switched {
+			hashChanged = false
+			result.RequeueAfter = h.blueGreen.RetryInterval
+		}
 	}
 
 	// Update the desired state of the Deployment in a DeepCopy
 	copy := instance.DeepCopy()
-	setConfigHash(copy, hash)
+	var keys []notify.KeyChange
+	if hashChanged {
+		hashes := calculateSourceHashes(current)
+		keys = changedKeys(instance, hashes)
+		if h.usesExecutor(instance) {
+			if err := h.execute(ctx, instance, copy, hash, changes); err != nil {
+				if h.budget != nil {
+					h.budget.release(instance.GetUID())
+				}
+				h.sendNotification(notify.EventSkipped, instance, hash, sourceNames(changes), keys, fmt.Sprintf("error executing rollout: %v", err))
+				h.recordDecision(audit.Skipped, instance, hash, changes, fmt.Sprintf("error executing rollout: %v", err))
+				return reconcile.Result{}, errors.Wrap(err, "error executing rollout")
+			}
+		} else {
+			setConfigHash(copy, hash)
+		}
+		setAppliedTrigger(instance, copy)
+		if err := setSourceHashes(copy, hashes); err != nil {
+			return reconcile.Result{}, err
+		}
+		if err := setCSIVersions(copy, csiHistory); err != nil {
+			return reconcile.Result{}, err
+		}
+		if h.usesBlueGreen(instance) {
+			scaleDownOriginal(copy)
+		}
+		h.capDaemonSet(copy)
+	} else if wait := h.restoreDaemonSet(instance, copy); wait > 0 && (result.RequeueAfter == 0 || wait < result.RequeueAfter) {
+		result.RequeueAfter = wait
+	}
+	if err := h.syncPendingRollout(instance, copy, hash); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := h.syncApproval(instance, copy); err != nil {
+		return reconcile.Result{}, err
+	}
+	if err := h.syncStatus(instance, copy, current, hash, hashChanged); err != nil {
+		return reconcile.Result{}, err
+	}
 	addFinalizer(copy)
 
-	// If the desired state doesn't match the existing state, update it
-	if !reflect.DeepEqual(instance, copy) {
-		log.V(0).Info("Updating instance hash", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash)
-		h.recorder.Eventf(copy.GetObject(), corev1.EventTypeNormal, "ConfigChanged", "Configuration hash updated to %s", hash)
-		err := h.Update(context.TODO(), copy.GetObject())
+	// If the fields Wave owns don't match the desired state, update them.
+	// Only these fields are compared so that changes made by others, such as
+	// sidecar injectors mutating the pod template, are never seen as drift.
+	if needsUpdate(instance, copy) {
+		if hashChanged || !hasFinalizer(instance) {
+			log.V(0).Info("Updating instance hash", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash)
+			message := fmt.Sprintf("Configuration hash updated to %s", hash)
+			if len(keys) > 0 {
+				message = fmt.Sprintf("%s, changed keys: %s", message, notify.DescribeKeys(keys))
+			}
+			h.recorder.Event(copy.GetObject(), corev1.EventTypeNormal, "ConfigChanged", message)
+		}
+		var mutations []audit.Mutation
+		if hashChanged {
+			mutations = append(mutations, audit.ConfigHashUpdated)
+		}
+		if !hasFinalizer(instance) {
+			mutations = append(mutations, audit.FinalizerAdded)
+		}
+		err := h.updateWorkload(ctx, instance, copy, mutations...)
+		if err != nil {
+			if hashChanged {
+				if h.budget != nil {
+					h.budget.release(instance.GetUID())
+				}
+				h.sendNotification(notify.EventSkipped, instance, hash, sourceNames(changes), keys, fmt.Sprintf("error updating instance: %v", err))
+				h.recordDecision(audit.Skipped, instance, hash, changes, fmt.Sprintf("error updating instance: %v", err))
+			}
+			return reconcile.Result{}, errors.Wrapf(err, "error updating instance %s/%s", instance.GetNamespace(), instance.GetName())
+		}
+		if hashChanged {
+			h.sources.record(instance.GetUID(), current)
+			h.snapshotSources(ctx, instance, current)
+			h.sendNotification(notify.EventTriggered, instance, hash, sourceNames(changes), keys, "")
+		}
+	} else if getConfigHash(instance) == hash {
+		observeNoop(instance)
+	}
+
+	// Complete the rollout of workloads which don't replace their Pods
+	rollout, err := h.rollOnDelete(ctx, copy)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "error rolling out Pods")
+	}
+	if rollout.RequeueAfter > 0 && (result.RequeueAfter == 0 || rollout.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = rollout.RequeueAfter
+	}
+
+	// Run the post-restart hook once the rollout completes. The status of a
+	// workload whose hash was just updated doesn't describe the rollout yet.
+	if !hashChanged {
+		hook, err := h.runPostRestartHook(ctx, copy)
 		if err != nil {
-			return reconcile.Result{}, fmt.Errorf("error updating instance %s/%s: %v", instance.GetNamespace(), instance.GetName(), err)
+			return reconcile.Result{}, errors.Wrap(err, "error running post-restart hook")
+		}
+		if hook.RequeueAfter > 0 && (result.RequeueAfter == 0 || hook.RequeueAfter < result.RequeueAfter) {
+			result.RequeueAfter = hook.RequeueAfter
 		}
 	}
 
-	return reconcile.Result{}, nil
+	// Poll the plugin sources and extra URLs, whose changes no event announces
+	if poll := h.pollInterval(instance); poll > 0 && (result.RequeueAfter == 0 || poll < result.RequeueAfter) {
+		result.RequeueAfter = poll
+	}
+
+	h.hashes.record(copy)
+	return result, nil
+}
+
+// pollInterval returns how long to wait before polling the plugin sources
+// and extra URLs of the instance again, or zero if it has none
+func (h *Handler) pollInterval(instance podController) time.Duration {
+	poll := h.pluginPollInterval(instance)
+	if urls := h.extraURLPollInterval(instance); urls > 0 && (poll == 0 || urls < poll) {
+		poll = urls
+	}
+	return poll
+}
+
+// needsUpdate returns true if the fields Wave owns differ between the
+// instance and the desired state
+func needsUpdate(instance, desired podController) bool {
+	return getConfigHash(instance) != getConfigHash(desired) ||
+		instance.GetAnnotations()[SourceHashesAnnotation] != desired.GetAnnotations()[SourceHashesAnnotation] ||
+		instance.GetAnnotations()[CSIVersionsAnnotation] != desired.GetAnnotations()[CSIVersionsAnnotation] ||
+		instance.GetAnnotations()[PendingRolloutAnnotation] != desired.GetAnnotations()[PendingRolloutAnnotation] ||
+		instance.GetAnnotations()[AppliedTriggerAnnotation] != desired.GetAnnotations()[AppliedTriggerAnnotation] ||
+		instance.GetAnnotations()[ApprovalAnnotation] != desired.GetAnnotations()[ApprovalAnnotation] ||
+		instance.GetAnnotations()[ExecutedHashAnnotation] != desired.GetAnnotations()[ExecutedHashAnnotation] ||
+		instance.GetAnnotations()[StatusAnnotation] != desired.GetAnnotations()[StatusAnnotation] ||
+		instance.GetAnnotations()[OriginalMaxUnavailableAnnotation] != desired.GetAnnotations()[OriginalMaxUnavailableAnnotation] ||
+		hasFinalizer(instance) != hasFinalizer(desired)
+}
+
+// admitRollout determines whether the rollout of the instance to the new
+// hash may proceed now. If it may not, the time to wait before trying again
+// is returned; a zero wait means the rollout should not be retried until the
+// instance's configuration changes again.
+func (h *Handler) admitRollout(ctx context.Context, instance podController, current []configObject, hash string, changes []sourceChange) (bool, time.Duration, error) {
+	if isPaused(instance) {
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Rollouts paused, not updating hash", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash)
+		return false, 0, nil
+	}
+
+	if held, wait := h.checkInitialRollout(instance, hash, changes); held {
+		return false, wait, nil
+	}
+
+	if held, wait := h.checkAwaitingData(instance, current, hash, changes); held {
+		return false, wait, nil
+	}
+
+	if held, wait := h.checkInFlight(instance, hash, changes); held {
+		return false, wait, nil
+	}
+
+	if held, wait := h.checkSuspended(instance, current, hash, changes); held {
+		return false, wait, nil
+	}
+
+	if held, wait := h.checkTriggerPolicy(instance, hash, changes); held {
+		return false, wait, nil
+	}
+
+	if held, wait := h.checkDowntime(instance, hash, changes); held {
+		return false, wait, nil
+	}
+
+	allowed, wait, err := h.evaluatePolicy(ctx, instance, hash, changes)
+	if err != nil || !allowed {
+		return false, wait, err
+	}
+
+	wait, err = h.checkCapacity(ctx, instance, hash, changes)
+	if err != nil || wait > 0 {
+		return false, wait, err
+	}
+
+	if wait := h.checkBudget(instance, hash, changes); wait > 0 {
+		return false, wait, nil
+	}
+	return true, 0, nil
+}
+
+// checkCapacity checks whether the rollout of the instance must wait for
+// cluster capacity and returns the time to wait before trying again
+func (h *Handler) checkCapacity(ctx context.Context, instance podController, hash string, changes []sourceChange) (time.Duration, error) {
+	if h.gate == nil {
+		return 0, nil
+	}
+	wait, reason, err := h.gate.admit(ctx, instance, h.getClock().Now())
+	if err != nil {
+		return 0, err
+	}
+	if wait > 0 {
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Deferring rollout", "namespace", instance.GetNamespace(), "name", instance.GetName(), "reason", reason, "wait", wait.String())
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutDeferred", "Rollout deferred for %s: %s", wait, reason)
+		h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	}
+	return wait, nil
+}
+
+// forget removes all state held in memory about the instance
+func (h *Handler) forget(instance podController) {
+	h.sources.forget(instance.GetUID())
+	h.hashes.forget(instance.GetUID())
+	h.debounce.forget(instance.GetUID())
+	h.invalid.forget(instance.GetUID())
+	if h.gate != nil {
+		h.gate.forget(instance.GetUID())
+	}
+	if h.policy != nil {
+		h.policy.forget(instance.GetUID())
+	}
+	if h.budget != nil {
+		h.budget.release(instance.GetUID())
+	}
 }
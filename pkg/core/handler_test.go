@@ -69,7 +69,7 @@ var _ = Describe("Wave controller Suite", func() {
 		h = NewHandler(c, mgr.GetEventRecorderFor("wave"))
 		m = utils.Matcher{Client: c}
 
-		stopMgr, mgrStopped = StartTestManager(mgr)
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
 
 		// Create some configmaps and secrets
 		cm1 = utils.ExampleConfigMap1.DeepCopy()
@@ -96,7 +96,7 @@ var _ = Describe("Wave controller Suite", func() {
 
 		// Create a deployment and wait for it to be reconciled
 		m.Create(deployment).Should(Succeed())
-		_, err = h.HandleDeployment(deployment)
+		_, err = h.HandleDeployment(context.TODO(), deployment)
 		Expect(err).NotTo(HaveOccurred())
 
 		m.Get(deployment).Should(Succeed())
@@ -133,8 +133,7 @@ var _ = Describe("Wave controller Suite", func() {
 			return nil
 		}, timeout).Should(Succeed())
 
-		close(stopMgr)
-		mgrStopped.Wait()
+		utils.StopTestManager(stopMgr, mgrStopped)
 
 		utils.DeleteAll(cfg, timeout,
 			&appsv1.DeploymentList{},
@@ -157,7 +156,7 @@ var _ = Describe("Wave controller Suite", func() {
 					obj.SetAnnotations(annotations)
 					return obj
 				}, timeout).Should(Succeed())
-				_, err := h.HandleDeployment(deployment)
+				_, err := h.HandleDeployment(context.TODO(), deployment)
 				Expect(err).NotTo(HaveOccurred())
 
 				// Get the updated Deployment
@@ -178,6 +177,27 @@ var _ = Describe("Wave controller Suite", func() {
 				m.Eventually(deployment, timeout).Should(utils.WithPodTemplateAnnotations(HaveKey(ConfigHashAnnotation)))
 			})
 
+			It("Keeps finalizers added by others since the Deployment was read", func() {
+				m.Eventually(deployment, timeout).Should(utils.WithFinalizers(ContainElement(FinalizerString)))
+				stale := deployment.DeepCopy()
+
+				// Another controller adds its finalizer between Wave's read
+				// and its patch
+				m.Update(deployment, func(obj utils.Object) utils.Object {
+					obj.SetFinalizers(append(obj.GetFinalizers(), "example.com/other"))
+					return obj
+				}, timeout).Should(Succeed())
+
+				// Opting out from the stale copy removes Wave's finalizer
+				delete(stale.Annotations, RequiredAnnotation)
+				_, err := h.HandleDeployment(context.TODO(), stale)
+				Expect(err).To(HaveOccurred())
+				Expect(classifyError(err)).To(Equal(conflict))
+
+				m.Get(deployment, timeout).Should(Succeed())
+				Expect(deployment.GetFinalizers()).To(ContainElement("example.com/other"))
+			})
+
 			It("Sends an event when updating the hash", func() {
 				m.Eventually(deployment, timeout).Should(utils.WithPodTemplateAnnotations(HaveKey(ConfigHashAnnotation)))
 
@@ -205,7 +225,7 @@ var _ = Describe("Wave controller Suite", func() {
 						dpl.Spec.Template.Spec.Containers = []corev1.Container{containers[0]}
 						return dpl
 					}).Should(Succeed())
-					_, err := h.HandleDeployment(deployment)
+					_, err := h.HandleDeployment(context.TODO(), deployment)
 					Expect(err).NotTo(HaveOccurred())
 
 					// Get the updated Deployment
@@ -241,7 +261,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return cm
 						}).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -261,7 +281,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return cm
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -282,7 +302,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return cm
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -303,7 +323,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return cm
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -327,7 +347,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return s
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -351,7 +371,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return s
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -375,7 +395,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return s
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -399,7 +419,7 @@ var _ = Describe("Wave controller Suite", func() {
 							return s
 						}, timeout).Should(Succeed())
 
-						_, err := h.HandleDeployment(deployment)
+						_, err := h.HandleDeployment(context.TODO(), deployment)
 						Expect(err).NotTo(HaveOccurred())
 
 						// Get the updated Deployment
@@ -410,6 +430,58 @@ var _ = Describe("Wave controller Suite", func() {
 						m.Consistently(deployment, consistentlyTimeout).Should(utils.WithPodTemplateAnnotations(HaveKeyWithValue(ConfigHashAnnotation, originalHash)))
 					})
 				})
+
+				Context("A ConfigMap volume is updated while rollouts are paused", func() {
+					BeforeEach(func() {
+						m.Update(deployment, func(obj utils.Object) utils.Object {
+							annotations := obj.GetAnnotations()
+							annotations[PausedAnnotation] = "true"
+							obj.SetAnnotations(annotations)
+							return obj
+						}, timeout).Should(Succeed())
+						m.Update(cm1, func(obj utils.Object) utils.Object {
+							cm := obj.(*corev1.ConfigMap)
+							cm.Data["key1"] = modified
+							return cm
+						}).Should(Succeed())
+
+						_, err := h.HandleDeployment(context.TODO(), deployment)
+						Expect(err).NotTo(HaveOccurred())
+
+						// Get the updated Deployment
+						m.Get(deployment, timeout).Should(Succeed())
+					})
+
+					It("Does not update the config hash in the Pod Template", func() {
+						m.Consistently(deployment, consistentlyTimeout).Should(utils.WithPodTemplateAnnotations(HaveKeyWithValue(ConfigHashAnnotation, originalHash)))
+					})
+				})
+			})
+
+			Context("And the trigger annotation is set", func() {
+				var originalHash string
+
+				BeforeEach(func() {
+					m.Eventually(deployment, timeout).Should(utils.WithPodTemplateAnnotations(HaveKey(ConfigHashAnnotation)))
+					originalHash = deployment.Spec.Template.GetAnnotations()[ConfigHashAnnotation]
+
+					m.Update(deployment, func(obj utils.Object) utils.Object {
+						annotations := obj.GetAnnotations()
+						annotations[TriggerAnnotation] = "2019-01-02T03:04:05Z"
+						obj.SetAnnotations(annotations)
+						return obj
+					}, timeout).Should(Succeed())
+
+					_, err := h.HandleDeployment(context.TODO(), deployment)
+					Expect(err).NotTo(HaveOccurred())
+
+					// Get the updated Deployment
+					m.Get(deployment, timeout).Should(Succeed())
+				})
+
+				It("Updates the config hash in the Pod Template", func() {
+					m.Eventually(deployment, timeout).ShouldNot(utils.WithPodTemplateAnnotations(HaveKeyWithValue(ConfigHashAnnotation, originalHash)))
+				})
 			})
 
 			Context("And the annotation is removed", func() {
@@ -421,7 +493,7 @@ var _ = Describe("Wave controller Suite", func() {
 
 						return obj
 					}, timeout).Should(Succeed())
-					_, err := h.HandleDeployment(deployment)
+					_, err := h.HandleDeployment(context.TODO(), deployment)
 					Expect(err).NotTo(HaveOccurred())
 
 					m.Eventually(deployment, timeout).ShouldNot(utils.WithAnnotations(HaveKey(RequiredAnnotation)))
@@ -447,7 +519,7 @@ var _ = Describe("Wave controller Suite", func() {
 					m.Eventually(deployment, timeout).ShouldNot(utils.WithDeletionTimestamp(BeNil()))
 					m.Get(deployment).Should(Succeed())
 
-					_, err := h.HandleDeployment(deployment)
+					_, err := h.HandleDeployment(context.TODO(), deployment)
 					Expect(err).NotTo(HaveOccurred())
 				})
 				It("Removes the OwnerReference from the all children", func() {
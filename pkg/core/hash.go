@@ -17,14 +17,88 @@ limitations under the License.
 package core
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"reflect"
 
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 )
 
+// The configuration hash is a stable contract: identical inputs produce the
+// identical hash in every version of Wave and on every architecture, as
+// hashes are committed to Git by `kubectl wave hash` and changing them
+// restarts every workload. testdata/hashes.yaml pins the hashes of known
+// inputs; its entries must never change.
+//
+// The hash is computed in the following steps:
+//
+//  1. The data of the children is encoded as the JSON object
+//     {"configMaps":{NAME:{KEY:VALUE}},"secrets":{NAME:{KEY:BASE64}}}, with
+//     ConfigMaps before Secrets and names and keys sorted bytewise, as
+//     encoding/json sorts map keys. All children are in the workload's
+//     namespace, so it is not encoded. Selected keys, included metadata and
+//     Secret metadata are added as keys of their child. The hash is the hex
+//     encoded SHA256 of the encoding.
+//
+//     Nothing Wave writes to a child feeds into its encoding: its
+//     OwnerReferences are not encoded, labels and annotations prefixed with
+//     wave.pusher.com/ are never included (see isWaveKey), and Wave never
+//     updates Secrets hashed by their resourceVersion.
+//  2. If the workload has a non-empty TriggerAnnotation, the hash becomes the
+//     SHA256 of the hash followed by its value.
+//  3. If the CSI Secrets Store reported object versions, the hash becomes the
+//     SHA256 of the hash followed by ";KEY=VERSION" for each object, sorted
+//     by key.
+//  4. If plugins computed versions of plugin sources, the hash becomes the
+//     SHA256 of the hash followed by ";PLUGIN=ARGUMENT=VERSION" for each
+//     source, sorted by PLUGIN=ARGUMENT.
+//  5. If extra URLs were fetched, the hash becomes the SHA256 of the hash
+//     followed by ";URL=SHA256" for each URL and the hex encoded SHA256 of
+//     its response, sorted by URL.
+//  6. If the workload places the hash in a label, it is truncated to 63
+//     characters.
+
+// configHash computes the configuration hash of the instance from its
+// children, the latest versions of its CSI objects, the versions of its
+// plugin sources and the hashes of its extra URLs
+func configHash(children []configObject, instance podController, csiLatest, pluginVersions, urlHashes map[string]string) (string, error) {
+	hash, err := calculateConfigHash(children)
+	if err != nil {
+		return "", err
+	}
+	hash = applyVersions(applyTrigger(hash, instance), csiLatest)
+	return fitHash(instance, applyVersions(applyVersions(hash, pluginVersions), urlHashes)), nil
+}
+
+// currentHash fetches the versions of the CSI objects, plugin sources and
+// extra URLs of the instance and computes its configuration hash from them
+// and its current children. It also returns the history of CSI object
+// versions to record on the instance.
+func (h *Handler) currentHash(ctx context.Context, instance podController, current []configObject) (string, csiVersions, error) {
+	reported, err := h.getCSIVersions(ctx, instance)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error fetching CSI object versions")
+	}
+	csiHistory, csiLatest := updateCSIVersions(instance, reported)
+	pluginVersions, err := h.getPluginVersions(ctx, instance)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error fetching plugin source versions")
+	}
+	urlHashes, err := h.getURLHashes(ctx, instance)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error fetching extra URLs")
+	}
+
+	hash, err := configHash(current, instance, csiLatest, pluginVersions, urlHashes)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "error calculating configuration hash")
+	}
+	return hash, csiHistory, nil
+}
+
 // calculateConfigHash uses sha256 to hash the configuration within the child
 // objects and returns a hash as a string
 func calculateConfigHash(children []configObject) (string, error) {
@@ -56,57 +130,86 @@ func calculateConfigHash(children []configObject) (string, error) {
 	// Convert the hashSource to a byte slice so that it can be hashed
 	hashSourceBytes, err := json.Marshal(hashSource)
 	if err != nil {
-		return "", fmt.Errorf("unable to marshal JSON: %v", err)
+		return "", errors.Wrap(err, "unable to marshal JSON")
 	}
 
 	hashBytes := sha256.Sum256(hashSourceBytes)
 	return fmt.Sprintf("%x", hashBytes), nil
 }
 
+// applyTrigger mixes the value of the TriggerAnnotation on the given
+// podController, if any, into the configuration hash
+func applyTrigger(hash string, obj podController) string {
+	trigger, ok := obj.GetAnnotations()[TriggerAnnotation]
+	if !ok || trigger == "" {
+		return hash
+	}
+	hashBytes := sha256.Sum256([]byte(hash + trigger))
+	return fmt.Sprintf("%x", hashBytes)
+}
+
 // getConfigMapData extracts all the relevant data from the ConfigMap, whether that is
-// the whole ConfigMap or only the specified keys.
+// the whole ConfigMap or only the specified keys, and the metadata it includes.
 func getConfigMapData(child configObject) map[string]string {
 	cm := *child.object.(*corev1.ConfigMap)
-	if child.allKeys {
+	metadata := getIncludedMetadata(&cm)
+	if child.hashesAllKeys() && len(metadata) == 0 {
 		return cm.Data
 	}
 	keyData := make(map[string]string)
-	for key := range child.keys {
-		if value, exists := cm.Data[key]; exists {
+	for key, value := range cm.Data {
+		if child.hashesKey(key) {
 			keyData[key] = value
 		}
 	}
+	for key, value := range metadata {
+		keyData[key] = value
+	}
 	return keyData
 }
 
 // getSecretData extracts all the relevant data from the Secret, whether that is
-// the whole Secret or only the specified keys.
+// the whole Secret or only the specified keys, and the metadata it includes.
 func getSecretData(child configObject) map[string][]byte {
+	if child.metadataOnly {
+		return getSecretMetadata(child)
+	}
 	s := *child.object.(*corev1.Secret)
-	if child.allKeys {
+	metadata := getIncludedMetadata(&s)
+	if child.hashesAllKeys() && len(metadata) == 0 {
 		return s.Data
 	}
 	keyData := make(map[string][]byte)
-	for key := range child.keys {
-		if value, exists := s.Data[key]; exists {
+	for key, value := range s.Data {
+		if child.hashesKey(key) {
 			keyData[key] = value
 		}
 	}
+	for key, value := range metadata {
+		keyData[key] = []byte(value)
+	}
 	return keyData
 }
 
+// getConfigHash returns the configuration hash currently set on the given
+// podController's PodTemplate, or the hash an Executor last rolled out
+func getConfigHash(obj podController) string {
+	return AppliedHash(obj, obj.GetPodTemplate())
+}
+
 // setConfigHash upates the configuration hash of the given Deployment to the
-// given string
+// given string, replacing any hash an Executor rolled out. The hash is placed
+// at the workload's hash path.
 func setConfigHash(obj podController, hash string) {
-	// Get the existing annotations
-	podTemplate := obj.GetPodTemplate()
-	annotations := podTemplate.GetAnnotations()
-	if annotations == nil {
-		annotations = make(map[string]string)
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		if _, ok := annotations[ExecutedHashAnnotation]; ok {
+			delete(annotations, ExecutedHashAnnotation)
+			obj.SetAnnotations(annotations)
+		}
 	}
 
-	// Update the annotations
-	annotations[ConfigHashAnnotation] = hash
-	podTemplate.SetAnnotations(annotations)
+	podTemplate := obj.GetPodTemplate()
+	path, _ := HashPathOf(obj)
+	setTemplateHash(podTemplate, path, fitHash(obj, hash))
 	obj.SetPodTemplate(podTemplate)
 }
@@ -0,0 +1,116 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hashFixture is an entry of testdata/hashes.yaml
+type hashFixture struct {
+	Name        string            `json:"name"`
+	ConfigMaps  []fixtureChild    `json:"configMaps"`
+	Secrets     []fixtureChild    `json:"secrets"`
+	Trigger     string            `json:"trigger"`
+	CSIVersions map[string]string `json:"csiVersions"`
+	Plugins     map[string]string `json:"plugins"`
+	ExtraURLs   map[string]string `json:"extraURLs"`
+	Hash        string            `json:"hash"`
+}
+
+// fixtureChild is a ConfigMap or Secret of a hashFixture
+type fixtureChild struct {
+	Metadata     metav1.ObjectMeta `json:"metadata"`
+	Data         map[string]string `json:"data"`
+	BinaryData   map[string][]byte `json:"binaryData"`
+	Keys         []string          `json:"keys"`
+	MetadataOnly bool              `json:"metadataOnly"`
+}
+
+// configObject converts the fixtureChild to the child of a workload
+func (f fixtureChild) configObject(secret bool) configObject {
+	child := configObject{allKeys: len(f.Keys) == 0, keys: map[string]struct{}{}, metadataOnly: f.MetadataOnly}
+	for _, key := range f.Keys {
+		child.keys[key] = struct{}{}
+	}
+	if !secret {
+		child.object = &corev1.ConfigMap{ObjectMeta: f.Metadata, Data: f.Data}
+		return child
+	}
+	s := &corev1.Secret{ObjectMeta: f.Metadata, Data: map[string][]byte{}}
+	for key, value := range f.Data {
+		s.Data[key] = []byte(value)
+	}
+	for key, value := range f.BinaryData {
+		s.Data[key] = value
+	}
+	child.object = s
+	return child
+}
+
+var _ = Describe("Wave hash stability Suite", func() {
+	var fixtures []hashFixture
+
+	BeforeEach(func() {
+		data, err := ioutil.ReadFile("testdata/hashes.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		fixtures = nil
+		Expect(yaml.Unmarshal(data, &fixtures)).To(Succeed())
+		Expect(fixtures).NotTo(BeEmpty())
+	})
+
+	It("computes the pinned hash of every fixture", func() {
+		for _, f := range fixtures {
+			children := []configObject{}
+			for _, cm := range f.ConfigMaps {
+				children = append(children, cm.configObject(false))
+			}
+			for _, s := range f.Secrets {
+				children = append(children, s.configObject(true))
+			}
+			// Missing optional children never contribute to the hash
+			children = append(children, configObject{required: false})
+
+			d := utils.ExampleDeployment.DeepCopy()
+			d.SetAnnotations(map[string]string{})
+			if f.Trigger != "" {
+				d.Annotations[TriggerAnnotation] = f.Trigger
+			}
+			instance := &deployment{d}
+
+			hash, err := configHash(children, instance, f.CSIVersions, f.Plugins, f.ExtraURLs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal(f.Hash), "fixture %q", f.Name)
+
+			// The order children are found in does not matter
+			reversed := make([]configObject, len(children))
+			for i, child := range children {
+				reversed[len(children)-1-i] = child
+			}
+			hash, err = configHash(reversed, instance, f.CSIVersions, f.Plugins, f.ExtraURLs)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hash).To(Equal(f.Hash), "fixture %q reversed", f.Name)
+		}
+	})
+})
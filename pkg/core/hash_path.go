@@ -0,0 +1,153 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HashPathAnnotation is the key of an optional annotation on the workload
+	// selecting where in its pod template Wave places the configuration
+	// hash: one of AnnotationsHashPath, the default, LabelsHashPath and
+	// EnvHashPath.
+	HashPathAnnotation = "wave.pusher.com/hash-path"
+
+	// AnnotationsHashPath places the hash in the ConfigHashAnnotation of the
+	// pod template
+	AnnotationsHashPath = "spec.template.metadata.annotations"
+
+	// LabelsHashPath places the hash in a label of the pod template keyed
+	// like the ConfigHashAnnotation. As label values are limited to 63
+	// characters, the hash is truncated to 63 characters.
+	LabelsHashPath = "spec.template.metadata.labels"
+
+	// EnvHashPath places the hash in the ConfigHashEnvVar environment
+	// variable of every container of the pod template
+	EnvHashPath = "spec.template.spec.containers[*].env"
+
+	// ConfigHashEnvVar is the environment variable the hash is placed in by
+	// the EnvHashPath
+	ConfigHashEnvVar = "WAVE_CONFIG_HASH"
+
+	// maxLabelValueLength is the maximum length of the value of a label
+	maxLabelValueLength = 63
+)
+
+// HashPathOf returns the path the workload places its configuration hash
+// at. Invalid paths fall back to the AnnotationsHashPath.
+func HashPathOf(obj metav1.Object) (string, error) {
+	path, ok := obj.GetAnnotations()[HashPathAnnotation]
+	if !ok || path == "" {
+		return AnnotationsHashPath, nil
+	}
+	switch path {
+	case AnnotationsHashPath, LabelsHashPath, EnvHashPath:
+		return path, nil
+	}
+	return AnnotationsHashPath, fmt.Errorf("invalid %s %q, must be one of %s, %s or %s", HashPathAnnotation, path, AnnotationsHashPath, LabelsHashPath, EnvHashPath)
+}
+
+// fitHash shortens the configuration hash to fit the path the workload
+// places it at
+func fitHash(obj metav1.Object, hash string) string {
+	if path, _ := HashPathOf(obj); path == LabelsHashPath && len(hash) > maxLabelValueLength {
+		return hash[:maxLabelValueLength]
+	}
+	return hash
+}
+
+// AppliedHash returns the configuration hash placed on the pod template of
+// the workload, or the hash an Executor last rolled out
+func AppliedHash(workload metav1.Object, template *corev1.PodTemplateSpec) string {
+	if hash, ok := workload.GetAnnotations()[ExecutedHashAnnotation]; ok {
+		return hash
+	}
+	path, _ := HashPathOf(workload)
+	return templateHash(template, path)
+}
+
+// templateHash returns the configuration hash placed at the path of the pod
+// template
+func templateHash(template *corev1.PodTemplateSpec, path string) string {
+	switch path {
+	case LabelsHashPath:
+		return template.GetLabels()[ConfigHashAnnotation]
+	case EnvHashPath:
+		for _, container := range template.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == ConfigHashEnvVar {
+					return env.Value
+				}
+			}
+		}
+		return ""
+	default:
+		return template.GetAnnotations()[ConfigHashAnnotation]
+	}
+}
+
+// podHash returns the configuration hash the Pod was created with
+func podHash(pod *corev1.Pod, path string) string {
+	return templateHash(&corev1.PodTemplateSpec{ObjectMeta: pod.ObjectMeta, Spec: pod.Spec}, path)
+}
+
+// setTemplateHash places the configuration hash at the path of the pod
+// template and removes it from every other path, so that a hash left at a
+// previous path doesn't go stale
+func setTemplateHash(template *corev1.PodTemplateSpec, path, hash string) {
+	annotations := template.GetAnnotations()
+	delete(annotations, ConfigHashAnnotation)
+	labels := template.GetLabels()
+	delete(labels, ConfigHashAnnotation)
+	for i := range template.Spec.Containers {
+		template.Spec.Containers[i].Env = withoutEnv(template.Spec.Containers[i].Env, ConfigHashEnvVar)
+	}
+
+	switch path {
+	case LabelsHashPath:
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[ConfigHashAnnotation] = hash
+	case EnvHashPath:
+		for i := range template.Spec.Containers {
+			template.Spec.Containers[i].Env = append(template.Spec.Containers[i].Env, corev1.EnvVar{Name: ConfigHashEnvVar, Value: hash})
+		}
+	default:
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[ConfigHashAnnotation] = hash
+	}
+	template.SetAnnotations(annotations)
+	template.SetLabels(labels)
+}
+
+// withoutEnv returns the environment variables without the named one, or
+// the variables unchanged if none has the name
+func withoutEnv(env []corev1.EnvVar, name string) []corev1.EnvVar {
+	for i, e := range env {
+		if e.Name == name {
+			return append(append([]corev1.EnvVar{}, env[:i]...), env[i+1:]...)
+		}
+	}
+	return env
+}
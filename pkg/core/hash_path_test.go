@@ -0,0 +1,208 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave hash path Suite", func() {
+	var d *appsv1.Deployment
+	var instance podController
+	hash := "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef"
+
+	BeforeEach(func() {
+		d = utils.ExampleDeployment.DeepCopy()
+		d.Spec.Template.Annotations = nil
+		d.Spec.Template.Labels = map[string]string{"app": "example"}
+		instance = &deployment{d}
+	})
+
+	withPath := func(path string) {
+		annotations := d.GetAnnotations()
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+		annotations[HashPathAnnotation] = path
+		d.SetAnnotations(annotations)
+	}
+
+	It("places the hash in an annotation by default", func() {
+		setConfigHash(instance, hash)
+		Expect(d.Spec.Template.Annotations).To(HaveKeyWithValue(ConfigHashAnnotation, hash))
+		Expect(getConfigHash(instance)).To(Equal(hash))
+	})
+
+	It("places the hash truncated in a label", func() {
+		withPath(LabelsHashPath)
+		Expect(fitHash(d, hash)).To(HaveLen(63))
+		setConfigHash(instance, fitHash(d, hash))
+		Expect(d.Spec.Template.Labels).To(HaveKeyWithValue(ConfigHashAnnotation, hash[:63]))
+		Expect(d.Spec.Template.Labels).To(HaveKeyWithValue("app", "example"))
+		Expect(d.Spec.Template.Annotations).NotTo(HaveKey(ConfigHashAnnotation))
+		Expect(getConfigHash(instance)).To(Equal(hash[:63]))
+	})
+
+	It("places the hash in the environment of every container", func() {
+		withPath(EnvHashPath)
+		setConfigHash(instance, hash)
+		setConfigHash(instance, hash)
+		for _, container := range d.Spec.Template.Spec.Containers {
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: ConfigHashEnvVar, Value: hash}))
+			count := 0
+			for _, env := range container.Env {
+				if env.Name == ConfigHashEnvVar {
+					count++
+				}
+			}
+			Expect(count).To(Equal(1))
+		}
+		Expect(getConfigHash(instance)).To(Equal(hash))
+	})
+
+	It("moves the hash when the path changes", func() {
+		setConfigHash(instance, hash)
+		withPath(EnvHashPath)
+		Expect(getConfigHash(instance)).To(BeEmpty())
+		setConfigHash(instance, hash)
+		Expect(d.Spec.Template.Annotations).NotTo(HaveKey(ConfigHashAnnotation))
+		Expect(getConfigHash(instance)).To(Equal(hash))
+
+		withPath(AnnotationsHashPath)
+		setConfigHash(instance, hash)
+		for _, container := range d.Spec.Template.Spec.Containers {
+			Expect(container.Env).NotTo(ContainElement(corev1.EnvVar{Name: ConfigHashEnvVar, Value: hash}))
+		}
+	})
+
+	It("rejects invalid paths", func() {
+		withPath("spec.template.metadata.name")
+		path, err := HashPathOf(d)
+		Expect(err).To(HaveOccurred())
+		Expect(path).To(Equal(AnnotationsHashPath))
+	})
+
+	Context("when reconciling", func() {
+		var c client.Client
+		var recorder *record.FakeRecorder
+
+		BeforeEach(func() {
+			d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+			c = fake.NewFakeClient(d, utils.ExampleConfigMap1.DeepCopy(), utils.ExampleConfigMap2.DeepCopy(), utils.ExampleConfigMap3.DeepCopy(),
+				utils.ExampleSecret1.DeepCopy(), utils.ExampleSecret2.DeepCopy(), utils.ExampleSecret3.DeepCopy())
+			recorder = record.NewFakeRecorder(100)
+		})
+
+		handle := func() *appsv1.Deployment {
+			h := NewHandler(c, recorder)
+			current := &appsv1.Deployment{}
+			key := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+			Expect(c.Get(context.TODO(), key, current)).To(Succeed())
+			_, err := h.HandleDeployment(context.TODO(), current)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(c.Get(context.TODO(), key, current)).To(Succeed())
+			return current
+		}
+
+		It("keeps a truncated hash in a label stable", func() {
+			d.Annotations[HashPathAnnotation] = LabelsHashPath
+			Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+			first := handle()
+			Expect(first.Spec.Template.Labels[ConfigHashAnnotation]).To(HaveLen(63))
+			second := handle()
+			Expect(second.ResourceVersion).To(Equal(first.ResourceVersion))
+		})
+
+		It("keeps images changed since the workload was read when placing the hash in the environment", func() {
+			d.Annotations[HashPathAnnotation] = EnvHashPath
+			Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+			key := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+			stale := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), key, stale)).To(Succeed())
+
+			// Simulate a deploy changing the image after the workload was read
+			deployed := stale.DeepCopy()
+			deployed.Spec.Template.Spec.Containers[0].Image = "example:changed"
+			Expect(c.Update(context.TODO(), deployed)).To(Succeed())
+
+			_, err := NewHandler(c, recorder).HandleDeployment(context.TODO(), stale)
+			Expect(err).NotTo(HaveOccurred())
+
+			current := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), key, current)).To(Succeed())
+			Expect(current.Spec.Template.Spec.Containers[0].Image).To(Equal("example:changed"))
+			Expect(getConfigHash(&deployment{current})).NotTo(BeEmpty())
+		})
+
+		It("doesn't reconcile workloads with an invalid path", func() {
+			d.Annotations[HashPathAnnotation] = "metadata.labels"
+			Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+			current := handle()
+			Expect(current.Spec.Template.Annotations).NotTo(HaveKey(ConfigHashAnnotation))
+			Expect(recorder.Events).To(Receive(ContainSubstring("InvalidHashPath")))
+		})
+
+		It("cleans up deleted workloads with an invalid path", func() {
+			handle()
+			current := &appsv1.Deployment{}
+			key := types.NamespacedName{Namespace: d.Namespace, Name: d.Name}
+			Expect(c.Get(context.TODO(), key, current)).To(Succeed())
+			Expect(current.GetFinalizers()).To(ContainElement(FinalizerString))
+
+			current.Annotations[HashPathAnnotation] = "metadata.labels"
+			now := metav1.Now()
+			current.SetDeletionTimestamp(&now)
+			Expect(c.Update(context.TODO(), current)).To(Succeed())
+			for len(recorder.Events) > 0 {
+				<-recorder.Events
+			}
+			handle()
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			Expect(events).To(ContainElement(ContainSubstring("Removed finalizer")))
+
+			cm := &corev1.ConfigMap{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: d.Namespace, Name: utils.ExampleConfigMap1.GetName()}, cm)).To(Succeed())
+			Expect(cm.GetOwnerReferences()).To(BeEmpty())
+		})
+
+		It("doesn't warn about unmanaged workloads with an invalid path", func() {
+			d.Annotations[RequiredAnnotation] = "false"
+			d.Annotations[HashPathAnnotation] = "metadata.labels"
+			Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+			handle()
+			Expect(recorder.Events).To(BeEmpty())
+		})
+	})
+})
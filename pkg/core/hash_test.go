@@ -59,7 +59,7 @@ var _ = Describe("Wave hash Suite", func() {
 			Expect(cerr).NotTo(HaveOccurred())
 			m = utils.Matcher{Client: c}
 
-			stopMgr, mgrStopped = StartTestManager(mgr)
+			stopMgr, mgrStopped = utils.StartTestManager(mgr)
 
 			cm1 = utils.ExampleConfigMap1.DeepCopy()
 			cm2 = utils.ExampleConfigMap2.DeepCopy()
@@ -84,8 +84,7 @@ var _ = Describe("Wave hash Suite", func() {
 		})
 
 		AfterEach(func() {
-			close(stopMgr)
-			mgrStopped.Wait()
+			utils.StopTestManager(stopMgr, mgrStopped)
 
 			utils.DeleteAll(cfg, timeout,
 				&appsv1.DeploymentList{},
@@ -319,4 +318,28 @@ var _ = Describe("Wave hash Suite", func() {
 			Expect(hash).To(Equal("annotation"))
 		})
 	})
+
+	Context("applyTrigger", func() {
+		var podControllerDeployment podController
+
+		BeforeEach(func() {
+			podControllerDeployment = &deployment{utils.ExampleDeployment.DeepCopy()}
+		})
+
+		It("leaves the hash unchanged without a trigger", func() {
+			Expect(applyTrigger("1234", podControllerDeployment)).To(Equal("1234"))
+		})
+
+		It("changes the hash when the trigger changes", func() {
+			annotations := map[string]string{TriggerAnnotation: "first"}
+			podControllerDeployment.SetAnnotations(annotations)
+			h1 := applyTrigger("1234", podControllerDeployment)
+			Expect(h1).NotTo(Equal("1234"))
+			Expect(applyTrigger("1234", podControllerDeployment)).To(Equal(h1))
+
+			annotations[TriggerAnnotation] = "second"
+			podControllerDeployment.SetAnnotations(annotations)
+			Expect(applyTrigger("1234", podControllerDeployment)).NotTo(Equal(h1))
+		})
+	})
 })
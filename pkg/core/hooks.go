@@ -0,0 +1,237 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// PreRestartHookAnnotation is the key of an optional annotation on the
+	// Deployment naming a CronJob whose Job template Wave runs before
+	// updating the configuration hash. The rollout is deferred until the
+	// Job succeeds.
+	PreRestartHookAnnotation = "wave.pusher.com/pre-restart-hook"
+
+	// PostRestartHookAnnotation is the key of an optional annotation on the
+	// Deployment naming a CronJob whose Job template Wave runs once a
+	// rollout of a new configuration hash completes
+	PostRestartHookAnnotation = "wave.pusher.com/post-restart-hook"
+
+	// postRestartHookHashAnnotation records the configuration hash the
+	// post-restart hook was last run for, so that it runs once per rollout
+	// even if its Jobs are garbage collected
+	postRestartHookHashAnnotation = "wave.pusher.com/post-restart-hook-hash"
+
+	// hookJobNameLength is the maximum length of the name of a hook Job,
+	// which the Job controller copies into a label
+	hookJobNameLength = 63
+)
+
+// hookPhase is when a restart hook runs
+type hookPhase string
+
+const (
+	preRestart  hookPhase = "pre"
+	postRestart hookPhase = "post"
+)
+
+// RestartHookOptions configures the Jobs Wave runs before and after rollouts
+type RestartHookOptions struct {
+	// RetryInterval is how long to wait before checking a hook Job again
+	// while it runs, or a rollout before running its post-restart hook
+	RetryInterval time.Duration
+}
+
+// WithRestartHooks allows workloads to run Jobs before and after their
+// rollouts with the PreRestartHookAnnotation and PostRestartHookAnnotation
+func WithRestartHooks(o RestartHookOptions) Option {
+	return func(h *Handler) {
+		h.hooks = &o
+	}
+}
+
+// runPreRestartHook runs the pre-restart hook of the instance for the
+// configuration hash, if any, and returns the time to wait before the
+// rollout may proceed. A failed hook defers the rollout until its Job is
+// deleted or the configuration changes again.
+// +kubebuilder:rbac:groups=batch,resources=cronjobs,verbs=get;list;watch
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create
+func (h *Handler) runPreRestartHook(ctx context.Context, instance podController, hash string, changes []sourceChange) (time.Duration, error) {
+	if h.hooks == nil || instance.GetAnnotations()[PreRestartHookAnnotation] == "" {
+		return 0, nil
+	}
+	job, err := h.getHookJob(ctx, instance, preRestart, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	log := logf.Log.WithName("wave")
+	switch {
+	case job == nil:
+		log.V(0).Info("Running pre-restart hook", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash)
+		if err := h.createHookJob(ctx, instance, preRestart, hash); err != nil {
+			return 0, err
+		}
+	case jobFailed(job):
+		reason := fmt.Sprintf("pre-restart hook Job %s failed", job.GetName())
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "PreRestartHookFailed", "Rollout deferred: %s", reason)
+		h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	case jobSucceeded(job):
+		return 0, nil
+	}
+	return h.hooks.RetryInterval, nil
+}
+
+// runPostRestartHook runs the post-restart hook of the instance once the
+// rollout of its configuration hash completes, and returns the time to wait
+// before checking on it again. A failed hook is reported on the instance.
+func (h *Handler) runPostRestartHook(ctx context.Context, instance podController) (reconcile.Result, error) {
+	hash := getConfigHash(instance)
+	if h.hooks == nil || hash == "" || instance.GetAnnotations()[PostRestartHookAnnotation] == "" {
+		return reconcile.Result{}, nil
+	}
+	if instance.GetAnnotations()[postRestartHookHashAnnotation] == hash {
+		// The hook already ran for this rollout: report the outcome of
+		// its Job while it exists
+		job, err := h.getHookJob(ctx, instance, postRestart, hash)
+		switch {
+		case err != nil:
+			return reconcile.Result{}, err
+		case job == nil || jobSucceeded(job):
+			return reconcile.Result{}, nil
+		case jobFailed(job):
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "PostRestartHookFailed", "Post-restart hook Job %s failed after rolling out configuration hash %s", job.GetName(), hash)
+			return reconcile.Result{}, nil
+		}
+		return reconcile.Result{RequeueAfter: h.hooks.RetryInterval}, nil
+	}
+	if !h.rolloutComplete(instance) {
+		return reconcile.Result{RequeueAfter: h.hooks.RetryInterval}, nil
+	}
+
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Running post-restart hook", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash)
+	if err := h.createHookJob(ctx, instance, postRestart, hash); err != nil {
+		return reconcile.Result{}, err
+	}
+	desired := instance.DeepCopy()
+	annotations := desired.GetAnnotations()
+	annotations[postRestartHookHashAnnotation] = hash
+	desired.SetAnnotations(annotations)
+	if err := h.updateWorkload(ctx, instance, desired); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "error recording post-restart hook")
+	}
+	return reconcile.Result{RequeueAfter: h.hooks.RetryInterval}, nil
+}
+
+// getHookJob returns the Job of the instance's hook for the configuration
+// hash, or nil if it doesn't exist
+func (h *Handler) getHookJob(ctx context.Context, instance podController, phase hookPhase, hash string) (*batchv1.Job, error) {
+	job := &batchv1.Job{}
+	err := h.Get(ctx, types.NamespacedName{Namespace: instance.GetNamespace(), Name: hookJobName(instance, phase, hash)}, job)
+	if apierrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "error getting %s-restart hook Job", phase)
+	}
+	return job, nil
+}
+
+// createHookJob creates the Job of the instance's hook for the configuration
+// hash from the Job template of the CronJob the hook annotation names
+func (h *Handler) createHookJob(ctx context.Context, instance podController, phase hookPhase, hash string) error {
+	annotation := PreRestartHookAnnotation
+	if phase == postRestart {
+		annotation = PostRestartHookAnnotation
+	}
+	name := strings.TrimPrefix(instance.GetAnnotations()[annotation], "cronjob/")
+	cronJob := &batchv1beta1.CronJob{}
+	if err := h.Get(ctx, types.NamespacedName{Namespace: instance.GetNamespace(), Name: name}, cronJob); err != nil {
+		return errors.Wrapf(err, "error getting CronJob %s of %s-restart hook", name, phase)
+	}
+
+	template := cronJob.Spec.JobTemplate
+	job := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:       instance.GetNamespace(),
+			Name:            hookJobName(instance, phase, hash),
+			Labels:          template.GetLabels(),
+			Annotations:     map[string]string{ConfigHashAnnotation: hash},
+			OwnerReferences: []metav1.OwnerReference{getOwnerReference(instance)},
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	for key, value := range template.GetAnnotations() {
+		job.Annotations[key] = value
+	}
+	target := audit.Object{Namespace: job.GetNamespace(), Kind: "Job", Name: job.GetName()}
+	mutation := audit.PreRestartHookStarted
+	if phase == postRestart {
+		mutation = audit.PostRestartHookStarted
+	}
+	if err := h.createObject(ctx, job, target, withConfigHash(instance, hash), mutation); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrapf(err, "error creating %s-restart hook Job", phase)
+	}
+	return nil
+}
+
+// hookJobName returns the name of the Job of the instance's hook for the
+// configuration hash
+func hookJobName(instance podController, phase hookPhase, hash string) string {
+	suffix := fmt.Sprintf("-%s-%s", phase, shortHash(hash))
+	name := instance.GetName()
+	if len(name)+len(suffix) > hookJobNameLength {
+		name = strings.TrimRight(name[:hookJobNameLength-len(suffix)], "-.")
+	}
+	return name + suffix
+}
+
+// jobSucceeded returns true if the Job completed
+func jobSucceeded(job *batchv1.Job) bool {
+	return jobCondition(job, batchv1.JobComplete)
+}
+
+// jobFailed returns true if the Job failed
+func jobFailed(job *batchv1.Job) bool {
+	return jobCondition(job, batchv1.JobFailed)
+}
+
+// jobCondition returns true if the condition of the Job is True
+func jobCondition(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
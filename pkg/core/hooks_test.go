@@ -0,0 +1,165 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave restart hooks Suite", func() {
+	var c client.Client
+	var h *Handler
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	// jobs returns the hook Jobs, keyed on their name
+	jobs := func() map[string]*batchv1.Job {
+		list := &batchv1.JobList{}
+		Expect(c.List(context.TODO(), list)).To(Succeed())
+		jobs := make(map[string]*batchv1.Job)
+		for i := range list.Items {
+			jobs[list.Items[i].GetName()] = &list.Items[i]
+		}
+		return jobs
+	}
+
+	// finish sets the condition of the Job as the Job controller would
+	finish := func(name string, conditionType batchv1.JobConditionType) {
+		job := jobs()[name]
+		job.Status.Conditions = []batchv1.JobCondition{{Type: conditionType, Status: corev1.ConditionTrue}}
+		Expect(c.Update(context.TODO(), job)).To(Succeed())
+	}
+
+	newCronJob := func(name string) *batchv1beta1.CronJob {
+		cronJob := &batchv1beta1.CronJob{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+		cronJob.Spec.JobTemplate.Labels = map[string]string{"hook": name}
+		cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers = []corev1.Container{{Name: name, Image: name}}
+		return cronJob
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "example",
+				UID:       types.UID("example"),
+				Annotations: map[string]string{
+					RequiredAnnotation:        "true",
+					PreRestartHookAnnotation:  "drain",
+					PostRestartHookAnnotation: "warm",
+				},
+			}},
+			newCronJob("drain"),
+			newCronJob("warm"),
+		)
+		h = NewHandler(c, record.NewFakeRecorder(100), WithRestartHooks(RestartHookOptions{RetryInterval: 10 * time.Second}))
+	})
+
+	It("defers the rollout until the pre-restart hook succeeds", func() {
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(jobs()).To(HaveLen(1))
+		var name string
+		for n := range jobs() {
+			name = n
+		}
+		Expect(name).To(MatchRegexp(`^example-pre-[0-9a-f]{10}$`))
+		job := jobs()[name]
+		Expect(job.Spec.Template.Spec.Containers[0].Image).To(Equal("drain"))
+		Expect(job.GetLabels()).To(HaveKeyWithValue("hook", "drain"))
+		Expect(job.GetOwnerReferences()[0].Name).To(Equal("example"))
+
+		// A failed hook keeps deferring the rollout
+		finish(name, batchv1.JobFailed)
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+
+		// Deleting the failed Job runs the hook again
+		Expect(c.Delete(context.TODO(), job)).To(Succeed())
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(jobs()).To(HaveKey(name))
+		finish(name, batchv1.JobComplete)
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal(job.GetAnnotations()[ConfigHashAnnotation]))
+	})
+
+	It("runs the post-restart hook once the rollout completes", func() {
+		handle()
+		for name := range jobs() {
+			finish(name, batchv1.JobComplete)
+		}
+		handle()
+		hash := getConfigHash(&deployment{getDeployment()})
+		Expect(hash).NotTo(BeEmpty())
+		Expect(jobs()).To(HaveLen(1))
+
+		// The Deployment controller has not observed the new hash yet
+		d := getDeployment()
+		d.Generation = 2
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		Expect(handle()).To(Equal(10 * time.Second))
+		Expect(jobs()).To(HaveLen(1))
+
+		d = getDeployment()
+		d.Status.ObservedGeneration = 2
+		d.Status.Replicas = 1
+		d.Status.UpdatedReplicas = 1
+		d.Status.AvailableReplicas = 1
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		handle()
+		post := "example-post-" + hash[:10]
+		Expect(jobs()).To(HaveKey(post))
+		Expect(jobs()[post].Spec.Template.Spec.Containers[0].Image).To(Equal("warm"))
+		Expect(getDeployment().GetAnnotations()).To(HaveKeyWithValue(postRestartHookHashAnnotation, hash))
+
+		// The hook only runs once per rollout
+		finish(post, batchv1.JobComplete)
+		Expect(c.Delete(context.TODO(), jobs()[post])).To(Succeed())
+		Expect(handle()).To(BeZero())
+		Expect(jobs()).NotTo(HaveKey(post))
+	})
+
+	It("shortens the names of hook Jobs", func() {
+		d := &deployment{&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "a-very-long-deployment-name-which-exceeds-the-limit-of-labels"}}}
+		name := hookJobName(d, preRestart, "0123456789abcdef")
+		Expect(len(name)).To(BeNumerically("<=", 63))
+		Expect(name).To(HaveSuffix("-pre-0123456789"))
+	})
+})
@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ImpersonationOptions configures the Handler to write to each namespace as a
+// service account of that namespace, so that the tenant's RBAC and admission
+// policies apply to Wave's writes
+type ImpersonationOptions struct {
+	// ServiceAccount is the name of the service account impersonated in the
+	// namespace of each write. Wave must be allowed to impersonate it.
+	ServiceAccount string
+
+	// Config, Scheme and Mapper are used to build the impersonating clients
+	Config *rest.Config
+	Scheme *runtime.Scheme
+	Mapper meta.RESTMapper
+}
+
+// impersonator builds and caches one impersonating client per namespace
+type impersonator struct {
+	options ImpersonationOptions
+
+	mutex   sync.Mutex
+	clients map[string]client.Client
+}
+
+// newImpersonator constructs an impersonator using the given options
+func newImpersonator(options ImpersonationOptions) *impersonator {
+	return &impersonator{
+		options: options,
+		clients: make(map[string]client.Client),
+	}
+}
+
+// user returns the name of the user impersonated in the namespace
+func (i *impersonator) user(namespace string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, i.options.ServiceAccount)
+}
+
+// clientFor returns the client impersonating the namespace's service account
+func (i *impersonator) clientFor(namespace string) (client.Client, error) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if c, ok := i.clients[namespace]; ok {
+		return c, nil
+	}
+
+	cfg := rest.CopyConfig(i.options.Config)
+	cfg.Impersonate = rest.ImpersonationConfig{UserName: i.user(namespace)}
+	c, err := client.New(cfg, client.Options{Scheme: i.options.Scheme, Mapper: i.options.Mapper})
+	if err != nil {
+		return nil, errors.Wrapf(err, "error creating client impersonating %s", cfg.Impersonate.UserName)
+	}
+	i.clients[namespace] = c
+	return c, nil
+}
+
+// WithImpersonation configures the Handler to make its writes as a service
+// account of the namespace written to
+func WithImpersonation(o ImpersonationOptions) Option {
+	return func(h *Handler) {
+		if o.ServiceAccount != "" {
+			h.impersonator = newImpersonator(o)
+		}
+	}
+}
+
+// writerFor returns the client the Handler writes to the namespace with, and
+// the user it impersonates, if any
+func (h *Handler) writerFor(namespace string) (client.Writer, string, error) {
+	if h.impersonator == nil {
+		return h.Client, "", nil
+	}
+	c, err := h.impersonator.clientFor(namespace)
+	if err != nil {
+		return nil, "", err
+	}
+	c = h.capabilities.Client(c)
+	if h.faults != nil {
+		return h.faults.Writer(c), h.impersonator.user(namespace), nil
+	}
+	return c, h.impersonator.user(namespace), nil
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave impersonation Suite", func() {
+	var server *httptest.Server
+	var requests chan *http.Request
+	var options ImpersonationOptions
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests <- r
+			body, _ := ioutil.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(body)
+		}))
+
+		mapper := meta.NewDefaultRESTMapper(nil)
+		mapper.Add(appsv1.SchemeGroupVersion.WithKind("Deployment"), meta.RESTScopeNamespace)
+		options = ImpersonationOptions{
+			ServiceAccount: "wave-writer",
+			Config:         &rest.Config{Host: server.URL},
+			Scheme:         scheme.Scheme,
+			Mapper:         mapper,
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("writes with the Handler's client by default", func() {
+		h := NewHandler(fake.NewFakeClient(), record.NewFakeRecorder(10), WithImpersonation(ImpersonationOptions{}))
+		writer, user, err := h.writerFor("default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(writer).To(Equal(h.Client))
+		Expect(user).To(BeEmpty())
+	})
+
+	It("caches one client per namespace", func() {
+		i := newImpersonator(options)
+		a, err := i.clientFor("a")
+		Expect(err).NotTo(HaveOccurred())
+		again, err := i.clientFor("a")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(again).To(BeIdenticalTo(a))
+		Expect(i.clients).To(HaveLen(1))
+		Expect(i.user("a")).To(Equal("system:serviceaccount:a:wave-writer"))
+	})
+
+	It("impersonates the namespace's service account when writing", func() {
+		h := NewHandler(fake.NewFakeClient(), record.NewFakeRecorder(10), WithImpersonation(options))
+		instance := &deployment{utils.ExampleDeployment.DeepCopy()}
+		original := instance.DeepCopy()
+		addFinalizer(instance)
+		Expect(h.updateWorkload(context.TODO(), original, instance, audit.FinalizerAdded)).To(Succeed())
+
+		var r *http.Request
+		Expect(requests).To(Receive(&r))
+		Expect(r.Method).To(Equal(http.MethodPatch))
+		Expect(r.URL.Path).To(Equal("/apis/apps/v1/namespaces/default/deployments/example"))
+		Expect(r.Header.Get("Impersonate-User")).To(Equal("system:serviceaccount:default:wave-writer"))
+		Expect(r.URL.Query().Get("fieldManager")).To(Equal(FieldManager))
+	})
+})
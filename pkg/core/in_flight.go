@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// progressDeadlineExceeded is the reason of the Progressing condition of a
+// Deployment whose rollout failed to make progress within its deadline
+const progressDeadlineExceeded = "ProgressDeadlineExceeded"
+
+// WithInFlightHold holds back new configuration hashes of workloads while the
+// rollout of their previous hash is in progress, so that Wave doesn't
+// interrupt long rollouts and churn through ReplicaSets. A Deployment whose
+// rollout exceeded its progress deadline has failed and no longer holds back
+// new hashes, unless holdFailed is true.
+func WithInFlightHold(holdFailed bool) Option {
+	return func(h *Handler) {
+		h.holdInFlight = true
+		h.holdFailed = holdFailed
+	}
+}
+
+// rolloutFailed returns true if the workload is a Deployment whose rollout
+// exceeded its progress deadline
+func rolloutFailed(instance podController) bool {
+	d, ok := instance.GetObject().(*appsv1.Deployment)
+	if !ok {
+		return false
+	}
+	for _, c := range d.Status.Conditions {
+		if c.Type == appsv1.DeploymentProgressing {
+			return c.Status == corev1.ConditionFalse && c.Reason == progressDeadlineExceeded
+		}
+	}
+	return false
+}
+
+// checkInFlight checks whether the new hash of the instance must wait for the
+// rollout of its previous hash to complete. The instance's status changes
+// requeue it once the rollout completes or fails.
+func (h *Handler) checkInFlight(instance podController, hash string, changes []sourceChange) (bool, time.Duration) {
+	previous := getConfigHash(instance)
+	if !h.holdInFlight || previous == "" || h.rolloutComplete(instance) {
+		return false, 0
+	}
+	if rolloutFailed(instance) && !h.holdFailed {
+		return false, 0
+	}
+
+	reason := fmt.Sprintf("the rollout of configuration hash %s is in progress", shortHash(previous))
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Holding back hash until the rollout in progress completes", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash, "inProgress", previous)
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutHeld", "Rollout of configuration hash %s held back: %s", shortHash(hash), reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return true, 0
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave in-flight rollout Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	completeRollout := func() {
+		d := getDeployment()
+		d.Status.ObservedGeneration = d.Generation
+		d.Status.Replicas = 1
+		d.Status.UpdatedReplicas = 1
+		d.Status.AvailableReplicas = 1
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	failRollout := func() {
+		d := getDeployment()
+		d.Status.Conditions = []appsv1.DeploymentCondition{{
+			Type:   appsv1.DeploymentProgressing,
+			Status: corev1.ConditionFalse,
+			Reason: progressDeadlineExceeded,
+		}}
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		setConfigHash(&deployment{d}, "previous")
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		h = NewHandler(c, recorder, WithInFlightHold(false))
+	})
+
+	It("holds back new hashes while the rollout is in progress", func() {
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal("previous"))
+		Expect(recorder.Events).To(Receive(And(ContainSubstring("RolloutHeld"), ContainSubstring("in progress"))))
+
+		completeRollout()
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal("previous"))
+	})
+
+	It("rolls out new hashes once the rollout failed", func() {
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal("previous"))
+
+		failRollout()
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal("previous"))
+	})
+
+	It("keeps holding back new hashes of failed rollouts if configured", func() {
+		h = NewHandler(c, recorder, WithInFlightHold(true))
+		failRollout()
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal("previous"))
+	})
+
+	It("doesn't hold back the first hash", func() {
+		d := getDeployment()
+		d.Spec.Template.Annotations = nil
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("doesn't hold back hashes by default", func() {
+		h = NewHandler(c, recorder)
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal("previous"))
+	})
+})
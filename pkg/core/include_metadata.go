@@ -0,0 +1,88 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"strings"
+)
+
+const (
+	// labelKeyPrefix prefixes the keys under which the labels of a child are
+	// hashed
+	labelKeyPrefix = "metadata.labels."
+
+	// annotationKeyPrefix prefixes the keys under which the annotations of a
+	// child are hashed
+	annotationKeyPrefix = "metadata.annotations."
+
+	// lastAppliedAnnotation is set by kubectl apply and changes along with
+	// the data of the child, so it is never hashed
+	lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+	// waveKeyPrefix prefixes the keys of Wave's own labels and annotations
+	waveKeyPrefix = "wave.pusher.com/"
+)
+
+// isWaveKey returns true if the label or annotation key belongs to Wave.
+// Wave's labels and annotations on a child, such as its suspend marker,
+// configure or record how Wave handles the child rather than the
+// configuration of the workloads using it. They are never hashed, even when
+// selected explicitly, so that Wave writing them can never trigger a rollout.
+func isWaveKey(key string) bool {
+	return strings.HasPrefix(key, waveKeyPrefix)
+}
+
+// getIncludedMetadata returns the labels and annotations of the child selected
+// by its IncludeMetadataAnnotation, keyed on labelKeyPrefix or
+// annotationKeyPrefix followed by their key. With the value "true", every
+// label and annotation is selected; otherwise the value lists the keys to
+// select, comma separated. Wave's own labels and annotations are never
+// selected.
+func getIncludedMetadata(obj Object) map[string]string {
+	value, ok := obj.GetAnnotations()[IncludeMetadataAnnotation]
+	if !ok || value == "" {
+		return nil
+	}
+
+	selected := func(string) bool { return true }
+	if value != "true" {
+		keys := make(map[string]struct{})
+		for _, key := range strings.Split(value, ",") {
+			keys[strings.TrimSpace(key)] = struct{}{}
+		}
+		selected = func(key string) bool {
+			_, ok := keys[key]
+			return ok
+		}
+	}
+
+	metadata := make(map[string]string)
+	for key, value := range obj.GetLabels() {
+		if selected(key) && !isWaveKey(key) {
+			metadata[labelKeyPrefix+key] = value
+		}
+	}
+	for key, value := range obj.GetAnnotations() {
+		if isWaveKey(key) || key == lastAppliedAnnotation {
+			continue
+		}
+		if selected(key) {
+			metadata[annotationKeyPrefix+key] = value
+		}
+	}
+	return metadata
+}
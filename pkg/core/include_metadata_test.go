@@ -0,0 +1,119 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+var _ = Describe("Wave include-metadata Suite", func() {
+	var cm *corev1.ConfigMap
+	var s *corev1.Secret
+
+	BeforeEach(func() {
+		cm = utils.ExampleConfigMap1.DeepCopy()
+		cm.SetLabels(map[string]string{"config-version": "1", "team": "a"})
+		s = utils.ExampleSecret1.DeepCopy()
+		s.SetLabels(map[string]string{"config-version": "1"})
+		s.Data = map[string][]byte{"key1": []byte("value1"), "key2": []byte("value2")}
+	})
+
+	hash := func() string {
+		h, err := calculateConfigHash([]configObject{
+			{object: cm, allKeys: true},
+			{object: s, keys: map[string]struct{}{"key1": {}}},
+		})
+		Expect(err).NotTo(HaveOccurred())
+		return h
+	}
+
+	It("ignores metadata without the annotation", func() {
+		before := hash()
+		cm.Labels["config-version"] = "2"
+		s.Labels["config-version"] = "2"
+		Expect(hash()).To(Equal(before))
+	})
+
+	It("hashes every label and annotation with the value true", func() {
+		cm.SetAnnotations(map[string]string{
+			IncludeMetadataAnnotation: "true",
+			lastAppliedAnnotation:     "{}",
+			"owner":                   "team-a",
+		})
+		data := getConfigMapData(configObject{object: cm, allKeys: true})
+		Expect(data).To(HaveKeyWithValue("metadata.labels.config-version", "1"))
+		Expect(data).To(HaveKeyWithValue("metadata.labels.team", "a"))
+		Expect(data).To(HaveKeyWithValue("metadata.annotations.owner", "team-a"))
+		Expect(data).NotTo(HaveKey("metadata.annotations." + IncludeMetadataAnnotation))
+		Expect(data).NotTo(HaveKey("metadata.annotations." + lastAppliedAnnotation))
+		Expect(data).To(HaveKeyWithValue("key1", cm.Data["key1"]))
+		Expect(cm.Data).NotTo(HaveKey("metadata.labels.team"))
+
+		before := hash()
+		cm.Labels["config-version"] = "2"
+		Expect(hash()).NotTo(Equal(before))
+	})
+
+	It("only hashes the listed keys", func() {
+		s.SetAnnotations(map[string]string{IncludeMetadataAnnotation: "config-version, missing"})
+		data := getSecretData(configObject{object: s, keys: map[string]struct{}{"key1": {}}})
+		Expect(data).To(HaveLen(2))
+		Expect(data).To(HaveKeyWithValue("metadata.labels.config-version", []byte("1")))
+		Expect(data).To(HaveKey("key1"))
+
+		before := hash()
+		s.Labels["team"] = "b"
+		Expect(hash()).To(Equal(before))
+		s.Labels["config-version"] = "2"
+		Expect(hash()).NotTo(Equal(before))
+	})
+
+	It("never hashes Wave's own labels and annotations", func() {
+		cm.SetAnnotations(map[string]string{
+			IncludeMetadataAnnotation: "true",
+			SuspendUntilAnnotation:    "2019-01-01T00:00:00Z",
+		})
+		s.SetAnnotations(map[string]string{IncludeMetadataAnnotation: SuspendUntilAnnotation + "," + SnapshotLabel})
+		before := hash()
+
+		cm.Annotations[SuspendUntilAnnotation] = "2019-01-02T00:00:00Z"
+		cm.Annotations[AllowDeletionAnnotation] = "true"
+		cm.Labels[SnapshotLabel] = "true"
+		s.SetAnnotations(map[string]string{
+			IncludeMetadataAnnotation: s.Annotations[IncludeMetadataAnnotation],
+			SuspendUntilAnnotation:    "2019-01-02T00:00:00Z",
+		})
+		s.Labels[SnapshotLabel] = "true"
+		Expect(hash()).To(Equal(before))
+
+		data := getConfigMapData(configObject{object: cm, allKeys: true})
+		for key := range data {
+			Expect(key).NotTo(ContainSubstring(waveKeyPrefix))
+		}
+	})
+
+	It("never hashes the OwnerReferences Wave adds", func() {
+		before := hash()
+		cm.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Deployment", Name: "example", UID: "example-uid"}})
+		s.SetOwnerReferences([]metav1.OwnerReference{{Kind: "Deployment", Name: "example", UID: "example-uid"}})
+		Expect(hash()).To(Equal(before))
+	})
+})
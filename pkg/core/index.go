@@ -0,0 +1,213 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// WorkloadRef identifies a Deployment, StatefulSet or DaemonSet
+type WorkloadRef struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+}
+
+// sourceRef identifies a ConfigMap or Secret
+type sourceRef struct {
+	kind      string
+	namespace string
+	name      string
+}
+
+// DependencyIndex indexes the ConfigMaps and Secrets referenced by the
+// workloads with the required annotation, in both directions. It is fed by
+// the informers of the workloads, so that every component of a binary can
+// share one index rather than listing workloads on each lookup. It is safe
+// for concurrent use.
+type DependencyIndex struct {
+	mutex     sync.RWMutex
+	sources   map[WorkloadRef][]Reference
+	consumers map[sourceRef]map[WorkloadRef]struct{}
+	synced    []toolscache.InformerSynced
+}
+
+// NewDependencyIndex constructs an empty DependencyIndex
+func NewDependencyIndex() *DependencyIndex {
+	return &DependencyIndex{
+		sources:   make(map[WorkloadRef][]Reference),
+		consumers: make(map[sourceRef]map[WorkloadRef]struct{}),
+	}
+}
+
+// workloadTypes are empty objects of each kind of workload
+var workloadTypes = map[string]func() Object{
+	"Deployment":  func() Object { return &appsv1.Deployment{} },
+	"StatefulSet": func() Object { return &appsv1.StatefulSet{} },
+	"DaemonSet":   func() Object { return &appsv1.DaemonSet{} },
+}
+
+// Register feeds the index from the informers of the workloads of the kinds,
+// or of every kind if none are given
+func (i *DependencyIndex) Register(informers cache.Informers, kinds ...string) error {
+	if len(kinds) == 0 {
+		kinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+	}
+	for _, kind := range kinds {
+		newObject, ok := workloadTypes[kind]
+		if !ok {
+			return fmt.Errorf("unknown workload kind %q", kind)
+		}
+		informer, err := informers.GetInformer(newObject())
+		if err != nil {
+			return errors.Wrapf(err, "error getting informer for %ss", kind)
+		}
+		informer.AddEventHandler(i)
+		i.synced = append(i.synced, informer.HasSynced)
+	}
+	return nil
+}
+
+// HasSynced returns true once the informers feeding the index have synced,
+// from when on the index holds every workload
+func (i *DependencyIndex) HasSynced() bool {
+	for _, synced := range i.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (i *DependencyIndex) OnAdd(obj interface{}) {
+	if o, ok := obj.(Object); ok {
+		i.Update(o)
+	}
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (i *DependencyIndex) OnUpdate(oldObj, newObj interface{}) {
+	i.OnAdd(newObj)
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (i *DependencyIndex) OnDelete(obj interface{}) {
+	if tombstone, ok := obj.(toolscache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	if o, ok := obj.(Object); ok {
+		i.Delete(o)
+	}
+}
+
+// Update indexes the references of the workload, or removes it from the
+// index if it no longer has the required annotation
+func (i *DependencyIndex) Update(obj Object) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return
+	}
+	if !hasRequiredAnnotation(instance) {
+		i.Delete(obj)
+		return
+	}
+	references, err := References(obj)
+	if err != nil {
+		return
+	}
+	workload := refOf(instance)
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(workload)
+	i.sources[workload] = references
+	for _, reference := range references {
+		source := sourceRef{kind: reference.Kind, namespace: workload.Namespace, name: reference.Name}
+		if i.consumers[source] == nil {
+			i.consumers[source] = make(map[WorkloadRef]struct{})
+		}
+		i.consumers[source][workload] = struct{}{}
+	}
+}
+
+// Delete removes the workload from the index
+func (i *DependencyIndex) Delete(obj Object) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return
+	}
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(refOf(instance))
+}
+
+// remove removes the workload from the index. The caller must hold the
+// write lock.
+func (i *DependencyIndex) remove(workload WorkloadRef) {
+	for _, reference := range i.sources[workload] {
+		source := sourceRef{kind: reference.Kind, namespace: workload.Namespace, name: reference.Name}
+		delete(i.consumers[source], workload)
+		if len(i.consumers[source]) == 0 {
+			delete(i.consumers, source)
+		}
+	}
+	delete(i.sources, workload)
+}
+
+// Consumers returns the workloads with the required annotation which
+// reference the ConfigMap or Secret of the given kind, namespace and name,
+// sorted by kind and name
+func (i *DependencyIndex) Consumers(kind, namespace, name string) []WorkloadRef {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	consumers := []WorkloadRef{}
+	for workload := range i.consumers[sourceRef{kind: kind, namespace: namespace, name: name}] {
+		consumers = append(consumers, workload)
+	}
+	sort.Slice(consumers, func(a, b int) bool {
+		if consumers[a].Kind != consumers[b].Kind {
+			return consumers[a].Kind < consumers[b].Kind
+		}
+		return consumers[a].Name < consumers[b].Name
+	})
+	return consumers
+}
+
+// Sources returns the ConfigMaps and Secrets referenced by the workload, and
+// whether the workload is indexed
+func (i *DependencyIndex) Sources(workload WorkloadRef) ([]Reference, bool) {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	references, ok := i.sources[workload]
+	if !ok {
+		return nil, false
+	}
+	return append([]Reference{}, references...), true
+}
+
+// refOf returns the WorkloadRef of the instance
+func refOf(instance podController) WorkloadRef {
+	return WorkloadRef{Kind: kindOf(instance), Namespace: instance.GetNamespace(), Name: instance.GetName()}
+}
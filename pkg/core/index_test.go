@@ -0,0 +1,108 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache/informertest"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllertest"
+)
+
+var _ = Describe("Wave dependency index Suite", func() {
+	var index *DependencyIndex
+	var informers *informertest.FakeInformers
+	var deployments *controllertest.FakeInformer
+	var tracked *appsv1.Deployment
+	var ref WorkloadRef
+
+	BeforeEach(func() {
+		index = NewDependencyIndex()
+		informers = &informertest.FakeInformers{}
+		Expect(index.Register(informers, "Deployment")).To(Succeed())
+		var err error
+		deployments, err = informers.FakeInformerFor(&appsv1.Deployment{})
+		Expect(err).NotTo(HaveOccurred())
+
+		tracked = utils.ExampleDeployment.DeepCopy()
+		tracked.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		ref = WorkloadRef{Kind: "Deployment", Namespace: tracked.GetNamespace(), Name: tracked.GetName()}
+	})
+
+	It("indexes the sources of tracked workloads in both directions", func() {
+		deployments.Add(tracked)
+
+		Expect(index.Consumers("ConfigMap", tracked.GetNamespace(), "example1")).To(Equal([]WorkloadRef{ref}))
+		Expect(index.Consumers("Secret", tracked.GetNamespace(), "volume-optional")).To(Equal([]WorkloadRef{ref}))
+		Expect(index.Consumers("ConfigMap", "other", "example1")).To(BeEmpty())
+
+		expected, err := References(tracked)
+		Expect(err).NotTo(HaveOccurred())
+		sources, ok := index.Sources(ref)
+		Expect(ok).To(BeTrue())
+		Expect(sources).To(Equal(expected))
+	})
+
+	It("ignores workloads without the required annotation", func() {
+		untracked := utils.ExampleDeployment.DeepCopy()
+		deployments.Add(untracked)
+		Expect(index.Consumers("ConfigMap", untracked.GetNamespace(), "example1")).To(BeEmpty())
+	})
+
+	It("follows updates to the workloads", func() {
+		deployments.Add(tracked)
+
+		updated := tracked.DeepCopy()
+		updated.Spec.Template.Spec.Volumes = nil
+		updated.Spec.Template.Spec.Containers = updated.Spec.Template.Spec.Containers[:0]
+		deployments.Update(tracked, updated)
+		Expect(index.Consumers("ConfigMap", tracked.GetNamespace(), "example1")).To(BeEmpty())
+		sources, ok := index.Sources(ref)
+		Expect(ok).To(BeTrue())
+		Expect(sources).To(BeEmpty())
+
+		untracked := tracked.DeepCopy()
+		untracked.SetAnnotations(nil)
+		deployments.Update(tracked, untracked)
+		_, ok = index.Sources(ref)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("removes deleted workloads", func() {
+		deployments.Add(tracked)
+		deployments.Delete(tracked)
+		Expect(index.Consumers("ConfigMap", tracked.GetNamespace(), "example1")).To(BeEmpty())
+
+		deployments.Add(tracked)
+		index.OnDelete(toolscache.DeletedFinalStateUnknown{Key: "default/example", Obj: tracked})
+		_, ok := index.Sources(ref)
+		Expect(ok).To(BeFalse())
+	})
+
+	It("has synced once its informers have", func() {
+		Expect(index.HasSynced()).To(BeFalse())
+		deployments.Synced = true
+		Expect(index.HasSynced()).To(BeTrue())
+	})
+
+	It("rejects unknown kinds", func() {
+		Expect(index.Register(informers, "CronJob")).NotTo(Succeed())
+	})
+})
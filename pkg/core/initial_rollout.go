@@ -0,0 +1,57 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// WithInitialRolloutWait holds back the first configuration hash of newly
+// created workloads until their initial rollout completes, so that Wave
+// doesn't start a second rollout within seconds of the first. Workloads are
+// no longer held back once they are older than timeout.
+func WithInitialRolloutWait(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.initialRolloutWait = timeout
+	}
+}
+
+// checkInitialRollout checks whether the first hash of the instance must
+// wait for its initial rollout to complete and returns the time until it no
+// longer waits. The instance's status changes requeue it before then.
+func (h *Handler) checkInitialRollout(instance podController, hash string, changes []sourceChange) (bool, time.Duration) {
+	if h.initialRolloutWait <= 0 || getConfigHash(instance) != "" || h.rolloutComplete(instance) {
+		return false, 0
+	}
+	age := h.getClock().Since(instance.GetCreationTimestamp().Time)
+	if age >= h.initialRolloutWait {
+		return false, 0
+	}
+	wait := h.initialRolloutWait - age
+
+	reason := fmt.Sprintf("the initial rollout is in progress; waiting at most %s", wait)
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Holding back first hash until the initial rollout completes", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash, "wait", wait.String())
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutHeld", "Rollout of configuration hash %s held back: %s", shortHash(hash), reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return true, wait
+}
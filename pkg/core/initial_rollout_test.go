@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave initial rollout Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+	created := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	completeRollout := func() {
+		d := getDeployment()
+		d.Status.ObservedGeneration = d.Generation
+		d.Status.Replicas = 1
+		d.Status.UpdatedReplicas = 1
+		d.Status.AvailableReplicas = 1
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:         "default",
+			Name:              "example",
+			Annotations:       map[string]string{RequiredAnnotation: "true"},
+			CreationTimestamp: metav1.NewTime(created),
+		}}
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		fakeClock = clock.NewFakeClock(created.Add(time.Minute))
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithInitialRolloutWait(5*time.Minute))
+	})
+
+	It("holds back the first hash while the initial rollout progresses", func() {
+		Expect(handle()).To(Equal(4 * time.Minute))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(recorder.Events).To(Receive(And(ContainSubstring("RolloutHeld"), ContainSubstring("initial rollout"))))
+		Expect(hasFinalizer(&deployment{getDeployment()})).To(BeTrue())
+
+		completeRollout()
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("writes the first hash once the wait has passed", func() {
+		handle()
+		fakeClock.SetTime(created.Add(5 * time.Minute))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("doesn't hold back workloads created before the wait", func() {
+		fakeClock.SetTime(created.Add(time.Hour))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("doesn't hold back later rollouts", func() {
+		d := getDeployment()
+		setConfigHash(&deployment{d}, "previous")
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal("previous"))
+	})
+
+	It("doesn't wait by default", func() {
+		h = NewHandler(c, recorder, WithClock(fakeClock))
+		handle()
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+})
@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// KillSwitchKey is the key of the kill switch ConfigMap which, when "true",
+// stops Wave writing to the cluster
+const KillSwitchKey = "disabled"
+
+// killSwitchEngaged is 1 while the kill switch stops Wave writing to the
+// cluster
+var killSwitchEngaged = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "wave_kill_switch_engaged",
+	Help: "Whether the kill switch ConfigMap stops Wave writing to the cluster",
+})
+
+func init() {
+	metrics.Registry.MustRegister(killSwitchEngaged)
+}
+
+// WithKillSwitch configures the Handler to make no changes to the cluster
+// while the ConfigMap with the key has "disabled" set to "true". Workloads
+// are still watched and are reconciled once it is released.
+func WithKillSwitch(key types.NamespacedName) Option {
+	return func(h *Handler) {
+		h.killSwitch = &key
+	}
+}
+
+// killSwitchEngaged returns whether the kill switch stops Wave writing to the
+// cluster. It is released while its ConfigMap does not exist.
+func (h *Handler) killSwitchEngaged(ctx context.Context) (bool, error) {
+	if h.killSwitch == nil {
+		return false, nil
+	}
+	cm := &corev1.ConfigMap{}
+	err := h.Get(ctx, *h.killSwitch, cm)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return false, errors.Wrapf(err, "error getting kill switch %s", h.killSwitch)
+	}
+	engaged := err == nil && isKillSwitchEngaged(cm)
+	if engaged {
+		killSwitchEngaged.Set(1)
+	} else {
+		killSwitchEngaged.Set(0)
+	}
+	return engaged, nil
+}
+
+// checkKillSwitch returns an error if the kill switch is engaged, so that no
+// write is made even by callers which didn't check it beforehand
+func (h *Handler) checkKillSwitch(ctx context.Context) error {
+	engaged, err := h.killSwitchEngaged(ctx)
+	if err != nil {
+		return err
+	}
+	if engaged {
+		return fmt.Errorf("kill switch %s is engaged", h.killSwitch)
+	}
+	return nil
+}
+
+// isKillSwitchEngaged returns whether the kill switch ConfigMap is engaged
+func isKillSwitchEngaged(cm *corev1.ConfigMap) bool {
+	return cm.Data[KillSwitchKey] == "true"
+}
+
+// KillSwitchReleased returns a mapping function for watching the kill switch
+// with the key that, when it is released, requests a reconcile of every
+// workload of the kind, applying the changes held back while it was engaged
+func KillSwitchReleased(c client.Client, key types.NamespacedName, kind string) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		if o.Meta.GetNamespace() != key.Namespace || o.Meta.GetName() != key.Name {
+			return nil
+		}
+		if cm, ok := o.Object.(*corev1.ConfigMap); ok && isKillSwitchEngaged(cm) {
+			return nil
+		}
+
+		workloads, err := ListWorkloads(context.TODO(), c, "", WorkloadKinds{kind})
+		if err != nil {
+			log := logf.Log.WithName("wave")
+			log.Error(err, "Unable to list workloads after releasing the kill switch")
+			return nil
+		}
+		var requests []reconcile.Request
+		for _, obj := range workloads {
+			if WorkloadKind(obj) != kind {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			})
+		}
+		return requests
+	}
+}
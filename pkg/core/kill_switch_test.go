@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+)
+
+var _ = Describe("Wave kill switch Suite", func() {
+	var c client.Client
+	var h *Handler
+	var key = types.NamespacedName{Namespace: "wave-system", Name: "wave-killswitch"}
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	setKillSwitch := func(value string) {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name},
+			Data:       map[string]string{KillSwitchKey: value},
+		}
+		Expect(c.Create(context.TODO(), cm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}})
+		h = NewHandler(c, record.NewFakeRecorder(100), WithKillSwitch(key))
+	})
+
+	It("reconciles while the kill switch does not exist", func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("reconciles while the kill switch is released", func() {
+		setKillSwitch("false")
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("makes no changes while the kill switch is engaged", func() {
+		setKillSwitch("true")
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+		Expect(getDeployment().GetFinalizers()).To(BeEmpty())
+
+		// Writes made outside of a reconcile are refused too
+		d := getDeployment()
+		original := &deployment{d.DeepCopy()}
+		setConfigHash(&deployment{d}, "hash")
+		Expect(h.updateWorkload(context.TODO(), original, &deployment{d})).To(MatchError(ContainSubstring("kill switch wave-system/wave-killswitch is engaged")))
+	})
+
+	It("reconciles every workload of the kind when the kill switch is released", func() {
+		toRequests := KillSwitchReleased(c, key, "Deployment")
+		released := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: key.Namespace, Name: key.Name}}
+		Expect(toRequests(handler.MapObject{Meta: released, Object: released})).To(HaveLen(1))
+		Expect(KillSwitchReleased(c, key, "StatefulSet")(handler.MapObject{Meta: released, Object: released})).To(BeEmpty())
+
+		engaged := released.DeepCopy()
+		engaged.Data = map[string]string{KillSwitchKey: "true"}
+		Expect(toRequests(handler.MapObject{Meta: engaged, Object: engaged})).To(BeEmpty())
+
+		other := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}}
+		Expect(toRequests(handler.MapObject{Meta: other, Object: other})).To(BeEmpty())
+	})
+})
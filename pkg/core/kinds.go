@@ -0,0 +1,66 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WorkloadKinds is a ListOption restricting ListWorkloads to the kinds of
+// workloads, Deployment, StatefulSet or DaemonSet, so that Wave never lists
+// the kinds it isn't allowed to. An empty WorkloadKinds restricts nothing.
+type WorkloadKinds []string
+
+// ApplyToList implements client.ListOption. The kinds are applied by
+// ListWorkloads rather than by the API server.
+func (k WorkloadKinds) ApplyToList(*client.ListOptions) {}
+
+// listsKind returns true if none of the options exclude the kind
+func listsKind(opts []client.ListOption, kind string) bool {
+	for _, opt := range opts {
+		kinds, ok := opt.(WorkloadKinds)
+		if !ok || len(kinds) == 0 {
+			continue
+		}
+		listed := false
+		for _, k := range kinds {
+			listed = listed || k == kind
+		}
+		if !listed {
+			return false
+		}
+	}
+	return true
+}
+
+// WithWorkloadKinds restricts the Handler to the kinds of workloads, whose
+// controllers are the only ones registered, so that it never lists the
+// others
+func WithWorkloadKinds(kinds ...string) Option {
+	return func(h *Handler) {
+		h.kinds = kinds
+	}
+}
+
+// ListWorkloads lists the workloads of the kinds the Handler manages in the
+// namespace, or in all namespaces if namespace is empty, that match the
+// options
+func (h *Handler) ListWorkloads(ctx context.Context, namespace string, opts ...client.ListOption) ([]Object, error) {
+	return ListWorkloads(ctx, h.Client, namespace, append(opts, h.kinds)...)
+}
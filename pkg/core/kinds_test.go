@@ -0,0 +1,73 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave workload kinds Suite", func() {
+	var c client.Client
+
+	// kinds returns the kinds of the workloads
+	kinds := func(workloads []Object) []string {
+		var kinds []string
+		for _, obj := range workloads {
+			kinds = append(kinds, WorkloadKind(obj))
+		}
+		return kinds
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(utils.ExampleDeployment.DeepCopy(), utils.ExampleStatefulSet.DeepCopy(), utils.ExampleDaemonSet.DeepCopy())
+	})
+
+	It("lists every kind by default", func() {
+		workloads, err := ListWorkloads(context.TODO(), c, "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(workloads)).To(ConsistOf("Deployment", "StatefulSet", "DaemonSet"))
+
+		workloads, err = ListWorkloads(context.TODO(), c, "", WorkloadKinds{})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workloads).To(HaveLen(3))
+	})
+
+	It("only lists the given kinds", func() {
+		workloads, err := ListWorkloads(context.TODO(), c, "", WorkloadKinds{"StatefulSet", "DaemonSet"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(workloads)).To(ConsistOf("StatefulSet", "DaemonSet"))
+
+		workloads, err = ListWorkloads(context.TODO(), c, "", WorkloadKinds{"StatefulSet", "DaemonSet"}, WorkloadKinds{"DaemonSet"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(workloads)).To(ConsistOf("DaemonSet"))
+	})
+
+	It("only lists the kinds the Handler manages", func() {
+		h := NewHandler(c, record.NewFakeRecorder(10), WithWorkloadKinds("Deployment"))
+		workloads, err := h.ListWorkloads(context.TODO(), "")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(workloads)).To(ConsistOf("Deployment"))
+		Expect(h.Watches().WorkloadKinds).To(Equal(WorkloadKinds{"Deployment"}))
+	})
+})
@@ -0,0 +1,93 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sort"
+
+	"github.com/wave-k8s/wave/pkg/memory"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// WithMemoryGuard lets the Guard shed the hashes and sources the Handler
+// tracks, least recently used first, while Wave is close to its memory limit.
+// Workloads whose state was shed are no longer checked for tampered hashes,
+// and report all of their sources as added, until they are next reconciled.
+func WithMemoryGuard(g *memory.Guard) Option {
+	return func(h *Handler) {
+		g.AddShedder("hashes", h.hashes.shed)
+		g.AddShedder("sources", h.sources.shed)
+	}
+}
+
+// recency tracks when the entries of a cache keyed by UID were last used
+type recency struct {
+	tick uint64
+	used map[types.UID]uint64
+}
+
+// newRecency constructs a recency without any entry
+func newRecency() recency {
+	return recency{used: make(map[types.UID]uint64)}
+}
+
+// touch marks the entry as the most recently used
+func (r *recency) touch(uid types.UID) {
+	r.tick++
+	r.used[uid] = r.tick
+}
+
+// forget removes the entry
+func (r *recency) forget(uid types.UID) {
+	delete(r.used, uid)
+}
+
+// leastUsed returns the least recently used half of the entries, rounded up
+func (r *recency) leastUsed() []types.UID {
+	uids := make([]types.UID, 0, len(r.used))
+	for uid := range r.used {
+		uids = append(uids, uid)
+	}
+	sort.Slice(uids, func(i, j int) bool {
+		return r.used[uids[i]] < r.used[uids[j]]
+	})
+	return uids[:(len(uids)+1)/2]
+}
+
+// shed drops the least recently used half of the recorded hashes
+func (t *hashTracker) shed() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	uids := t.recency.leastUsed()
+	for _, uid := range uids {
+		delete(t.hashes, uid)
+		t.recency.forget(uid)
+	}
+	return len(uids)
+}
+
+// shed drops the least recently used half of the recorded sources
+func (t *sourceTracker) shed() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	uids := t.recency.leastUsed()
+	for _, uid := range uids {
+		delete(t.versions, uid)
+		t.recency.forget(uid)
+	}
+	return len(uids)
+}
@@ -0,0 +1,59 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Wave memory Suite", func() {
+	It("sheds the least recently used sources", func() {
+		t := newSourceTracker()
+		for _, uid := range []types.UID{"a", "b", "c", "d"} {
+			t.record(uid, nil)
+		}
+		t.diff("a", nil)
+		t.diff("b", nil)
+
+		Expect(t.shed()).To(Equal(2))
+		Expect(t.versions).To(HaveKey(types.UID("a")))
+		Expect(t.versions).To(HaveKey(types.UID("b")))
+		Expect(t.versions).NotTo(HaveKey(types.UID("c")))
+
+		Expect(t.shed()).To(Equal(1))
+		Expect(t.shed()).To(Equal(1))
+		Expect(t.shed()).To(Equal(0))
+	})
+
+	It("sheds the least recently used hashes", func() {
+		t := newHashTracker()
+		for _, uid := range []types.UID{"a", "b", "c"} {
+			t.hashes[uid] = "hash"
+			t.recency.touch(uid)
+		}
+		t.get("a")
+		t.forget("c")
+
+		Expect(t.shed()).To(Equal(1))
+		_, ok := t.get("a")
+		Expect(ok).To(BeTrue())
+		_, ok = t.get("b")
+		Expect(ok).To(BeFalse())
+	})
+})
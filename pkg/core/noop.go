@@ -0,0 +1,42 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// noopReconciles counts the reconciles which found every field Wave owns up
+// to date, and so neither updated the workload nor recorded an event
+var noopReconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_noop_reconciles_total",
+	Help: "Number of reconciles which found the configuration hash already applied and skipped the update, by kind of workload",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(noopReconciles)
+}
+
+// observeNoop counts a reconcile of the instance which skipped the update,
+// as the hash it calculated is the one on its pod template already
+func observeNoop(instance podController) {
+	log := logf.Log.WithName("wave")
+	log.V(1).Info("Configuration hash already applied, not updating instance", "namespace", instance.GetNamespace(), "name", instance.GetName())
+	noopReconciles.WithLabelValues(kindOf(instance)).Inc()
+}
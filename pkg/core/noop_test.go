@@ -0,0 +1,82 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave no-op reconcile Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+
+	// noops returns the number of no-op reconciles of Deployments
+	noops := func() float64 {
+		metric := &dto.Metric{}
+		Expect(noopReconciles.WithLabelValues("Deployment").Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		c = fake.NewFakeClient(d, utils.ExampleConfigMap1.DeepCopy(), utils.ExampleConfigMap2.DeepCopy(), utils.ExampleConfigMap3.DeepCopy(),
+			utils.ExampleSecret1.DeepCopy(), utils.ExampleSecret2.DeepCopy(), utils.ExampleSecret3.DeepCopy())
+		recorder = record.NewFakeRecorder(100)
+		h = NewHandler(c, recorder)
+	})
+
+	It("skips the update and the events when the hash is applied already", func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+		applied := getDeployment()
+		before := noops()
+
+		_, err = h.HandleDeployment(context.TODO(), applied)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getDeployment().GetResourceVersion()).To(Equal(applied.GetResourceVersion()))
+		Expect(recorder.Events).NotTo(Receive())
+		Expect(noops()).To(Equal(before + 1))
+	})
+
+	It("doesn't count reconciles which update the hash", func() {
+		before := noops()
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(noops()).To(Equal(before))
+	})
+})
@@ -0,0 +1,62 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/notify"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// notificationTimeout is the maximum time allowed for sending a single
+// notification
+const notificationTimeout = 30 * time.Second
+
+// sendNotification sends an Event describing the rollout decision to the
+// configured Notifier, if there is one.
+// Notifications are sent asynchronously so that a slow notification provider
+// cannot block reconciliation.
+func (h *Handler) sendNotification(eventType notify.EventType, obj podController, hash string, sources []string, keys []notify.KeyChange, reason string) {
+	if h.notifier == nil {
+		return
+	}
+
+	event := notify.Event{
+		Type:      eventType,
+		Time:      h.getClock().Now(),
+		Namespace: obj.GetNamespace(),
+		Kind:      kindOf(obj),
+		Name:      obj.GetName(),
+		Hash:      hash,
+		Reason:    reason,
+		Sources:   sources,
+		Keys:      keys,
+	}
+
+	h.background.Add(1)
+	go func() {
+		defer h.background.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+		defer cancel()
+		if err := h.notifier.Notify(ctx, event); err != nil {
+			log := logf.Log.WithName("wave")
+			log.Error(err, "Unable to send notification", "namespace", event.Namespace, "name", event.Name, "type", event.Type)
+		}
+	}()
+}
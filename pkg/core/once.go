@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// OnceResult counts the outcomes of reconciling every workload once
+type OnceResult struct {
+	// Reconciled is the number of workloads reconciled successfully
+	Reconciled int
+
+	// Requeued is the number of reconciled workloads which must be
+	// reconciled again later, such as rollouts deferred by spreading or the
+	// policy, or OnDelete rollouts in progress
+	Requeued int
+
+	// Failed is the number of workloads which could not be reconciled
+	Failed int
+}
+
+// ReconcileOnce reconciles every Deployment, StatefulSet and DaemonSet that
+// Wave tracks in the namespaces, or in all namespaces if none are given, a
+// single time. A workload which fails to reconcile is logged and counted
+// rather than stopping the others. It waits for the notifications and audit
+// records sent to complete before returning.
+func (h *Handler) ReconcileOnce(ctx context.Context, namespaces []string) (OnceResult, error) {
+	log := logf.Log.WithName("wave")
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+
+	result := OnceResult{}
+	for _, namespace := range namespaces {
+		workloads, err := h.ListWorkloads(ctx, namespace)
+		if err != nil {
+			return result, err
+		}
+		for _, obj := range workloads {
+			instance, err := asPodController(obj)
+			if err != nil || !hasRequiredAnnotation(instance) && !hasFinalizer(instance) {
+				continue
+			}
+
+			rctx, cancel := h.reconcileContext(ctx)
+			res, err := h.handle(rctx, instance)
+			cancel()
+			switch {
+			case err != nil:
+				log.Error(err, "Unable to reconcile instance", "kind", kindOf(instance), "namespace", instance.GetNamespace(), "name", instance.GetName())
+				result.Failed++
+			case res.RequeueAfter > 0:
+				result.Reconciled++
+				result.Requeued++
+			default:
+				result.Reconciled++
+			}
+		}
+	}
+
+	h.waitBackground(ctx)
+	return result, nil
+}
+
+// waitBackground waits for the notifications and audit records being sent to
+// complete, or for the context to be done
+func (h *Handler) waitBackground(ctx context.Context) {
+	done := make(chan struct{})
+	go func() {
+		h.background.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+}
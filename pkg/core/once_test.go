@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/notify"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave once Suite", func() {
+	var c client.Client
+
+	newDeployment := func(namespace, name, configMap string, required bool) *appsv1.Deployment {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetNamespace(namespace)
+		d.SetName(name)
+		d.SetUID(types.UID(namespace + "-" + name))
+		d.SetAnnotations(map[string]string{})
+		if required {
+			d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		}
+		d.Spec.Template.Spec.Volumes = nil
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: configMap}}},
+			},
+		}}
+		return d
+	}
+
+	hashOf := func(namespace, name string) string {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: namespace, Name: name}, d)).To(Succeed())
+		return getConfigHash(&deployment{d})
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(
+			newDeployment("default", "tracked", "example1", true),
+			newDeployment("default", "ignored", "example1", false),
+			newDeployment("other", "tracked", "example1", true),
+			utils.ExampleConfigMap1.DeepCopy(),
+		)
+	})
+
+	It("reconciles every tracked workload", func() {
+		h := NewHandler(c, record.NewFakeRecorder(100))
+		result, err := h.ReconcileOnce(context.TODO(), nil)
+		Expect(err).NotTo(HaveOccurred())
+		// The ConfigMap doesn't exist in the other namespace
+		Expect(result).To(Equal(OnceResult{Reconciled: 1, Failed: 1}))
+		Expect(hashOf("default", "tracked")).NotTo(BeEmpty())
+		Expect(hashOf("default", "ignored")).To(BeEmpty())
+	})
+
+	It("only reconciles the given namespaces", func() {
+		h := NewHandler(c, record.NewFakeRecorder(100))
+		result, err := h.ReconcileOnce(context.TODO(), []string{"default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result).To(Equal(OnceResult{Reconciled: 1}))
+	})
+
+	It("waits for notifications to be sent", func() {
+		var sent int32
+		h := NewHandler(c, record.NewFakeRecorder(100), WithNotifier(notifierFunc(func(ctx context.Context, event notify.Event) error {
+			time.Sleep(50 * time.Millisecond)
+			atomic.AddInt32(&sent, 1)
+			return nil
+		})))
+		_, err := h.ReconcileOnce(context.TODO(), []string{"default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(atomic.LoadInt32(&sent)).To(Equal(int32(1)))
+	})
+})
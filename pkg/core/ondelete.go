@@ -0,0 +1,193 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// OnDeleteOptions configures how Wave completes the rollouts of StatefulSets
+// and DaemonSets using the OnDelete update strategy, which don't replace
+// their Pods when the pod template changes
+type OnDeleteOptions struct {
+	// MaxUnavailable is the maximum number of Pods of a workload that may be
+	// unavailable while Wave deletes outdated Pods. Zero disables deleting
+	// Pods.
+	MaxUnavailable int
+
+	// RetryInterval is how long to wait before checking the Pods of a
+	// workload again while its rollout is in progress
+	RetryInterval time.Duration
+}
+
+// enabled returns true if Wave should delete outdated Pods
+func (o OnDeleteOptions) enabled() bool {
+	return o.MaxUnavailable > 0
+}
+
+// WithOnDeleteRollouts configures the Handler to delete the outdated Pods of
+// StatefulSets and DaemonSets using the OnDelete update strategy after
+// updating their configuration hash
+func WithOnDeleteRollouts(o OnDeleteOptions) Option {
+	return func(h *Handler) {
+		if o.enabled() {
+			h.onDelete = &o
+		}
+	}
+}
+
+// rollOnDelete deletes the Pods of an OnDelete workload which don't run its
+// current configuration hash, without letting more than MaxUnavailable of
+// its Pods be unavailable. While outdated Pods remain, the workload is
+// requeued to check on them again.
+// +kubebuilder:rbac:groups=,resources=pods,verbs=delete
+func (h *Handler) rollOnDelete(ctx context.Context, instance podController) (reconcile.Result, error) {
+	if h.onDelete == nil || !usesOnDelete(instance) {
+		return reconcile.Result{}, nil
+	}
+	path, _ := HashPathOf(instance)
+	hash := templateHash(instance.GetPodTemplate(), path)
+	if hash == "" {
+		return reconcile.Result{}, nil
+	}
+
+	pods, err := h.getOwnedPods(ctx, instance)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+	unavailable := desiredPods(instance) - len(pods)
+	if unavailable < 0 {
+		unavailable = 0
+	}
+	var outdated []corev1.Pod
+	for _, pod := range pods {
+		if !pod.GetDeletionTimestamp().IsZero() || !isPodReady(pod) {
+			unavailable++
+			continue
+		}
+		if podHash(&pod, path) != hash {
+			outdated = append(outdated, pod)
+		}
+	}
+	if len(outdated) == 0 {
+		return reconcile.Result{}, nil
+	}
+
+	// Replace StatefulSet Pods from the highest ordinal down, like the
+	// RollingUpdate strategy does, and DaemonSet Pods by name
+	sort.Slice(outdated, func(i, j int) bool {
+		a, b := podOrdinal(outdated[i]), podOrdinal(outdated[j])
+		if a != b {
+			return a > b
+		}
+		return outdated[i].GetName() < outdated[j].GetName()
+	})
+	maxUnavailable := h.onDelete.MaxUnavailable
+	if ds, ok := instance.GetObject().(*appsv1.DaemonSet); ok {
+		if limit, ok := h.daemonSetCap(ds); ok && limit < maxUnavailable {
+			maxUnavailable = limit
+		}
+	}
+	log := logf.Log.WithName("wave")
+	for i := 0; i < maxUnavailable-unavailable && i < len(outdated); i++ {
+		log.V(0).Info("Deleting outdated Pod", "namespace", instance.GetNamespace(), "name", instance.GetName(), "pod", outdated[i].GetName(), "hash", hash)
+		if err := h.deletePod(ctx, &outdated[i], instance); err != nil {
+			return reconcile.Result{}, errors.Wrapf(err, "error deleting Pod %s", outdated[i].GetName())
+		}
+	}
+	return reconcile.Result{RequeueAfter: h.onDelete.RetryInterval}, nil
+}
+
+// usesOnDelete returns true if the workload is a StatefulSet or DaemonSet
+// using the OnDelete update strategy
+func usesOnDelete(instance podController) bool {
+	switch o := instance.GetObject().(type) {
+	case *appsv1.StatefulSet:
+		return o.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType
+	case *appsv1.DaemonSet:
+		return o.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType
+	}
+	return false
+}
+
+// desiredPods returns the number of Pods the workload should be running
+func desiredPods(instance podController) int {
+	switch o := instance.GetObject().(type) {
+	case *appsv1.StatefulSet:
+		if o.Spec.Replicas == nil {
+			return 1
+		}
+		return int(*o.Spec.Replicas)
+	case *appsv1.DaemonSet:
+		return int(o.Status.DesiredNumberScheduled)
+	}
+	return 0
+}
+
+// getOwnedPods lists the Pods controlled by the workload
+func (h *Handler) getOwnedPods(ctx context.Context, instance podController) ([]corev1.Pod, error) {
+	s, err := metav1.LabelSelectorAsSelector(getSelector(instance))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid selector")
+	}
+
+	list := &corev1.PodList{}
+	err = h.List(ctx, list, client.InNamespace(instance.GetNamespace()), client.MatchingLabelsSelector{Selector: s})
+	if err != nil {
+		return nil, errors.Wrap(err, "error listing Pods")
+	}
+	var pods []corev1.Pod
+	for _, pod := range list.Items {
+		if owner := metav1.GetControllerOf(&pod); owner != nil && owner.UID == instance.GetUID() {
+			pods = append(pods, pod)
+		}
+	}
+	return pods, nil
+}
+
+// isPodReady returns true if the Pod's Ready condition is True
+func isPodReady(pod corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// podOrdinal returns the ordinal of a StatefulSet Pod, or -1 if its name has
+// no ordinal
+func podOrdinal(pod corev1.Pod) int {
+	name := pod.GetName()
+	ordinal, err := strconv.Atoi(name[strings.LastIndex(name, "-")+1:])
+	if err != nil {
+		return -1
+	}
+	return ordinal
+}
@@ -0,0 +1,204 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Wave OnDelete Suite", func() {
+	var ss *appsv1.StatefulSet
+	var recorder *record.FakeRecorder
+	var options OnDeleteOptions
+
+	labels := map[string]string{"app": "example"}
+
+	requeueAfter := func(d time.Duration) reconcile.Result {
+		return reconcile.Result{RequeueAfter: d}
+	}
+
+	// pod returns a Pod of the workload running the given hash
+	pod := func(owner Object, kind, name, hash string, ready bool) *corev1.Pod {
+		status := corev1.ConditionFalse
+		if ready {
+			status = corev1.ConditionTrue
+		}
+		controller := true
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:       "default",
+				Name:            name,
+				Labels:          labels,
+				Annotations:     map[string]string{ConfigHashAnnotation: hash},
+				OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: owner.GetName(), UID: owner.GetUID(), Controller: &controller}},
+			},
+			Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+		}
+	}
+
+	// remaining returns the names of the Pods left in the cluster
+	remaining := func(c client.Client) []string {
+		pods := &corev1.PodList{}
+		Expect(c.List(context.TODO(), pods)).To(Succeed())
+		names := []string{}
+		for _, p := range pods.Items {
+			names = append(names, p.GetName())
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		replicas := int32(3)
+		ss = &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: types.UID("example-uid")},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas:       &replicas,
+				Selector:       &metav1.LabelSelector{MatchLabels: labels},
+				UpdateStrategy: appsv1.StatefulSetUpdateStrategy{Type: appsv1.OnDeleteStatefulSetStrategyType},
+			},
+		}
+		ss.Spec.Template.SetAnnotations(map[string]string{ConfigHashAnnotation: "new"})
+		recorder = record.NewFakeRecorder(10)
+		options = OnDeleteOptions{MaxUnavailable: 1, RetryInterval: 10 * time.Second}
+	})
+
+	newHandler := func(objs ...runtime.Object) (*Handler, client.Client) {
+		c := fake.NewFakeClient(objs...)
+		return NewHandler(c, recorder, WithOnDeleteRollouts(options)), c
+	}
+
+	It("is disabled without MaxUnavailable", func() {
+		options.MaxUnavailable = 0
+		h, c := newHandler(pod(ss, "StatefulSet", "example-0", "old", true))
+		Expect(h.onDelete).To(BeNil())
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(0)))
+		Expect(remaining(c)).To(ConsistOf("example-0"))
+	})
+
+	It("deletes the outdated Pod with the highest ordinal", func() {
+		h, c := newHandler(
+			pod(ss, "StatefulSet", "example-0", "old", true),
+			pod(ss, "StatefulSet", "example-1", "old", true),
+			pod(ss, "StatefulSet", "example-2", "old", true),
+		)
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(10 * time.Second)))
+		Expect(remaining(c)).To(ConsistOf("example-0", "example-1"))
+		Expect(recorder.Events).To(Receive(HavePrefix(fmt.Sprintf("Normal %s Deleted Pod example-2 to roll out configuration hash new", audit.PodDeleted))))
+	})
+
+	It("deletes up to MaxUnavailable Pods at once", func() {
+		options.MaxUnavailable = 2
+		h, c := newHandler(
+			pod(ss, "StatefulSet", "example-0", "old", true),
+			pod(ss, "StatefulSet", "example-1", "new", true),
+			pod(ss, "StatefulSet", "example-2", "old", true),
+		)
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(10 * time.Second)))
+		Expect(remaining(c)).To(ConsistOf("example-1"))
+	})
+
+	It("waits while Pods are unavailable", func() {
+		h, c := newHandler(
+			pod(ss, "StatefulSet", "example-0", "old", true),
+			pod(ss, "StatefulSet", "example-1", "old", false),
+			pod(ss, "StatefulSet", "example-2", "new", true),
+		)
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(10 * time.Second)))
+		Expect(remaining(c)).To(HaveLen(3))
+	})
+
+	It("counts missing Pods as unavailable", func() {
+		ds := &appsv1.DaemonSet{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: types.UID("ds-uid")},
+			Spec: appsv1.DaemonSetSpec{
+				Selector:       &metav1.LabelSelector{MatchLabels: labels},
+				UpdateStrategy: appsv1.DaemonSetUpdateStrategy{Type: appsv1.OnDeleteDaemonSetStrategyType},
+			},
+			Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 2},
+		}
+		ds.Spec.Template.SetAnnotations(map[string]string{ConfigHashAnnotation: "new"})
+		h, c := newHandler(pod(ds, "DaemonSet", "example-abcde", "old", true))
+		Expect(h.rollOnDelete(context.TODO(), &daemonset{ds})).To(Equal(requeueAfter(10 * time.Second)))
+		Expect(remaining(c)).To(ConsistOf("example-abcde"))
+	})
+
+	It("ignores Pods controlled by other workloads", func() {
+		other := ss.DeepCopy()
+		other.SetUID(types.UID("other-uid"))
+		h, c := newHandler(pod(other, "StatefulSet", "other-0", "old", true))
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(0)))
+		Expect(remaining(c)).To(ConsistOf("other-0"))
+	})
+
+	It("rolls every Pod to the new revision", func() {
+		ss.Status = appsv1.StatefulSetStatus{CurrentRevision: "example-old", UpdateRevision: "example-new"}
+		// revisionPod returns a Pod of the StatefulSet's revision
+		revisionPod := func(name, hash, revision string) *corev1.Pod {
+			p := pod(ss, "StatefulSet", name, hash, true)
+			p.Labels = map[string]string{"app": "example", appsv1.ControllerRevisionHashLabelKey: revision}
+			return p
+		}
+		h, c := newHandler(
+			ss,
+			revisionPod("example-0", "old", "example-old"),
+			revisionPod("example-1", "old", "example-old"),
+			revisionPod("example-2", "old", "example-old"),
+		)
+		m := &utils.Matcher{Client: c}
+		m.EventuallyRestarted(ss, "example-old", 100*time.Millisecond).ShouldNot(Succeed())
+
+		// The StatefulSet controller recreates each deleted Pod of the new
+		// revision
+		for i := 0; i < 3; i++ {
+			Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(10 * time.Second)))
+			left := map[string]bool{}
+			for _, name := range remaining(c) {
+				left[name] = true
+			}
+			for _, name := range []string{"example-0", "example-1", "example-2"} {
+				if !left[name] {
+					Expect(c.Create(context.TODO(), revisionPod(name, "new", "example-new"))).To(Succeed())
+				}
+			}
+		}
+		m.EventuallyRestarted(ss, "example-old", 100*time.Millisecond).Should(Succeed())
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(0)))
+	})
+
+	It("ignores workloads using the RollingUpdate strategy", func() {
+		ss.Spec.UpdateStrategy.Type = appsv1.RollingUpdateStatefulSetStrategyType
+		h, c := newHandler(pod(ss, "StatefulSet", "example-0", "old", true))
+		Expect(h.rollOnDelete(context.TODO(), &statefulset{ss})).To(Equal(requeueAfter(0)))
+		Expect(remaining(c)).To(ConsistOf("example-0"))
+	})
+})
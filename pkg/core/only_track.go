@@ -0,0 +1,74 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+)
+
+// onlyTracked parses the OnlyTrackAnnotation of the instance into the set of
+// children Wave tracks. ok is false when the annotation is not set, in which
+// case every referenced child is tracked. Entries which cannot be parsed are
+// returned as invalid and match no child.
+func onlyTracked(obj podController) (tracked map[sourceKey]struct{}, invalid []string, ok bool) {
+	value, ok := obj.GetAnnotations()[OnlyTrackAnnotation]
+	if !ok {
+		return nil, nil, false
+	}
+	tracked = make(map[sourceKey]struct{})
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, err := parseSourceKey(entry)
+		if err != nil {
+			invalid = append(invalid, entry)
+			continue
+		}
+		tracked[key] = struct{}{}
+	}
+	return tracked, invalid, true
+}
+
+// parseSourceKey parses a "<kind>/<name>" entry of the OnlyTrackAnnotation,
+// where kind is configmap or secret in any case
+func parseSourceKey(entry string) (sourceKey, error) {
+	parts := strings.SplitN(entry, "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return sourceKey{}, fmt.Errorf("expected <kind>/<name>, got %q", entry)
+	}
+	switch strings.ToLower(parts[0]) {
+	case "configmap":
+		return sourceKey{kind: "ConfigMap", name: parts[1]}, nil
+	case "secret":
+		return sourceKey{kind: "Secret", name: parts[1]}, nil
+	default:
+		return sourceKey{}, fmt.Errorf("unknown kind %q in %q", parts[0], entry)
+	}
+}
+
+// filterTracked removes the children of the given kind which are not listed
+// in the OnlyTrackAnnotation
+func filterTracked(tracked map[sourceKey]struct{}, kind string, children map[string]configMetadata) {
+	for name := range children {
+		if _, ok := tracked[sourceKey{kind: kind, name: name}]; !ok {
+			delete(children, name)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+)
+
+var _ = Describe("Wave only-track Suite", func() {
+	var instance podController
+
+	BeforeEach(func() {
+		instance = &deployment{utils.ExampleDeployment.DeepCopy()}
+	})
+
+	setOnlyTrack := func(value string) {
+		instance.SetAnnotations(map[string]string{OnlyTrackAnnotation: value})
+	}
+
+	It("tracks every referenced child without the annotation", func() {
+		configMaps, secrets := getChildNamesByType(instance)
+		Expect(configMaps).To(HaveKey("example4"))
+		Expect(secrets).To(HaveKey("example4"))
+	})
+
+	It("only tracks the listed children", func() {
+		setOnlyTrack("configmap/example1, Secret/example2")
+		configMaps, secrets := getChildNamesByType(instance)
+		Expect(configMaps).To(HaveLen(1))
+		Expect(configMaps).To(HaveKey("example1"))
+		Expect(secrets).To(HaveLen(1))
+		Expect(secrets).To(HaveKey("example2"))
+	})
+
+	It("tracks nothing when the annotation is empty", func() {
+		setOnlyTrack("")
+		configMaps, secrets := getChildNamesByType(instance)
+		Expect(configMaps).To(BeEmpty())
+		Expect(secrets).To(BeEmpty())
+	})
+
+	It("reports invalid entries", func() {
+		setOnlyTrack("configmap/example1,example2,volume/example3,secret/")
+		tracked, invalid, ok := onlyTracked(instance)
+		Expect(ok).To(BeTrue())
+		Expect(tracked).To(HaveLen(1))
+		Expect(tracked).To(HaveKey(sourceKey{kind: "ConfigMap", name: "example1"}))
+		Expect(invalid).To(Equal([]string{"example2", "volume/example3", "secret/"}))
+	})
+})
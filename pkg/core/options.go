@@ -0,0 +1,243 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"github.com/wave-k8s/wave/pkg/capabilities"
+	"github.com/wave-k8s/wave/pkg/concurrency"
+	"github.com/wave-k8s/wave/pkg/fanout"
+	"github.com/wave-k8s/wave/pkg/faults"
+	"github.com/wave-k8s/wave/pkg/notify"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// Option configures optional behaviour of the Handler
+type Option func(*Handler)
+
+// WithNotifier configures the Handler to send an Event to the Notifier
+// whenever it triggers or skips a rollout
+func WithNotifier(n notify.Notifier) Option {
+	return func(h *Handler) {
+		h.notifier = n
+	}
+}
+
+// WithFaultInjection subjects every API call of the Handler to the faults
+// of the Injector, for resilience testing. It must be applied before any
+// other Option so that they use the faulty client too.
+func WithFaultInjection(i *faults.Injector) Option {
+	return func(h *Handler) {
+		if i != nil {
+			h.faults = i
+			h.Client = i.Client(h.Client)
+		}
+	}
+}
+
+// WithCapacityOptions configures the Handler to spread the rollouts it
+// triggers according to the capacity of the cluster
+func WithCapacityOptions(o CapacityOptions) Option {
+	return func(h *Handler) {
+		if o.enabled() {
+			h.gate = newRolloutGate(h.Client, o)
+			if o.PodStartupTime > 0 {
+				h.gate.estimate = h.estimateRollout
+			}
+		}
+	}
+}
+
+// WithPolicy configures the Handler to consult an external policy before
+// applying each configuration hash change
+func WithPolicy(o PolicyOptions) Option {
+	return func(h *Handler) {
+		if o.Evaluator != nil {
+			h.policy = newPolicyHook(o)
+		}
+	}
+}
+
+// WithAudit configures the Handler to ship a record of every rollout
+// decision to an external sink
+func WithAudit(o AuditOptions) Option {
+	return func(h *Handler) {
+		if o.Sink != nil || o.Stream != nil {
+			h.audit = &o
+		}
+	}
+}
+
+// Watches describes what a controller must watch, besides its workloads and
+// the ConfigMaps and Secrets they own, for the Handler's options
+type Watches struct {
+	// SecretMetadataOnly maps Secrets to the workloads that reference them,
+	// with SecretConsumers, as Wave doesn't own them
+	SecretMetadataOnly bool
+
+	// SecretProviderClassPodStatuses maps the SecretProviderClassPodStatuses
+	// of the CSI Secrets Store driver to workloads with
+	// SecretProviderClassPodStatusConsumers
+	SecretProviderClassPodStatuses bool
+
+	// NamespacePriority feeds the requests of every watch to the
+	// controller through a priority.Queue
+	NamespacePriority bool
+
+	// BlueGreen watches the copies Deployments are rolled out to
+	BlueGreen bool
+
+	// RestartHooks watches the Jobs of restart hooks
+	RestartHooks bool
+
+	// KillSwitch, if set, is the ConfigMap whose release reconciles every
+	// workload with KillSwitchReleased
+	KillSwitch *types.NamespacedName
+
+	// Capabilities are the APIs served by the cluster, which determine the
+	// version the controller watches its workloads in
+	Capabilities *capabilities.Capabilities
+
+	// Concurrency configures how many reconciles the controller runs at
+	// once
+	Concurrency concurrency.Options
+
+	// FanOut, if set, reconciles the workloads consuming a changed
+	// ConfigMap or Secret instead of the controller's workqueue
+	FanOut *fanout.Pool
+
+	// WorkloadKinds are the kinds of workloads map functions such as
+	// SecretConsumers may list, or nil for every kind
+	WorkloadKinds WorkloadKinds
+
+	// Predicates filter the events of every watch, including those of
+	// ConfigMaps and Secrets
+	Predicates []predicate.Predicate
+
+	// WorkloadPredicates filter the events of the controller's workloads
+	WorkloadPredicates []predicate.Predicate
+
+	// Extra are watched in addition to the watches above
+	Extra []Watch
+}
+
+// Watch is an additional source of requests for the controllers using a
+// Handler. As every controller starts the Source, it should be a
+// source.Kind.
+type Watch struct {
+	Source source.Source
+
+	// Handler returns the EventHandler mapping events to requests for the
+	// workloads of the kind, one of Deployment, StatefulSet or DaemonSet
+	Handler func(kind string) handler.EventHandler
+
+	Predicates []predicate.Predicate
+}
+
+// WithCapabilities adapts the Handler to the APIs served by the cluster: its
+// controllers watch workloads in the version the cluster serves them in. The
+// client the Handler is constructed with must be adapted too, for example by
+// creating the manager's clients with Capabilities.NewClient.
+func WithCapabilities(c *capabilities.Capabilities) Option {
+	return func(h *Handler) {
+		h.capabilities = c
+	}
+}
+
+// WithConcurrency configures how many reconciles each controller using the
+// Handler runs at once
+func WithConcurrency(o concurrency.Options) Option {
+	return func(h *Handler) {
+		h.concurrency = o
+	}
+}
+
+// WithFanOut reconciles the workloads consuming a changed ConfigMap or
+// Secret concurrently on the Pool, which is shared by every controller using
+// the Handler
+func WithFanOut(p *fanout.Pool) Option {
+	return func(h *Handler) {
+		h.fanOut = p
+	}
+}
+
+// WithEventFilter filters the events of every watch of the controllers using
+// the Handler with the predicates
+func WithEventFilter(p ...predicate.Predicate) Option {
+	return func(h *Handler) {
+		h.predicates = append(h.predicates, p...)
+	}
+}
+
+// WithWorkloadFilter restricts the Handler to the workloads accepted by the
+// predicates. Controllers filter the workloads' events with them, and the
+// Handler leaves workloads whose Generic event they reject untouched, even
+// when a ConfigMap or Secret they own changes.
+func WithWorkloadFilter(p ...predicate.Predicate) Option {
+	return func(h *Handler) {
+		h.workloadPredicates = append(h.workloadPredicates, p...)
+	}
+}
+
+// WithWatch adds a watch to the controllers using the Handler
+func WithWatch(w Watch) Option {
+	return func(h *Handler) {
+		h.extraWatches = append(h.extraWatches, w)
+	}
+}
+
+// accepts returns true if every workload predicate accepts the instance
+func (h *Handler) accepts(instance podController) bool {
+	e := event.GenericEvent{Meta: instance, Object: instance.GetObject()}
+	for _, p := range h.workloadPredicates {
+		if !p.Generic(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// WithNamespacePriority configures controllers using the Handler to reconcile
+// the workloads of Namespaces with higher priorities first, see
+// priority.Label
+func WithNamespacePriority() Option {
+	return func(h *Handler) {
+		h.namespacePriority = true
+	}
+}
+
+// Watches returns what controllers using the Handler must watch
+func (h *Handler) Watches() Watches {
+	return Watches{
+		SecretMetadataOnly:             h.secretMetadataOnly,
+		SecretProviderClassPodStatuses: h.csiSecretsStore,
+		NamespacePriority:              h.namespacePriority,
+		BlueGreen:                      h.blueGreen != nil,
+		RestartHooks:                   h.hooks != nil,
+		KillSwitch:                     h.killSwitch,
+		Capabilities:                   h.capabilities,
+		Concurrency:                    h.concurrency,
+		FanOut:                         h.fanOut,
+		WorkloadKinds:                  h.kinds,
+		Predicates:                     h.predicates,
+		WorkloadPredicates:             h.workloadPredicates,
+		Extra:                          h.extraWatches,
+	}
+}
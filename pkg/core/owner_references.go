@@ -18,17 +18,17 @@ package core
 
 import (
 	"context"
-	"fmt"
 	"reflect"
-	"strings"
 
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // removeOwnerReferences iterates over a list of children and removes the owner
 // reference from the child before updating it
-func (h *Handler) removeOwnerReferences(obj podController, children []Object) error {
+func (h *Handler) removeOwnerReferences(ctx context.Context, obj podController, children []Object) error {
 	for _, child := range children {
 		// Filter the existing ownerReferences
 		ownerRefs := []metav1.OwnerReference{}
@@ -42,9 +42,9 @@ func (h *Handler) removeOwnerReferences(obj podController, children []Object) er
 		if !reflect.DeepEqual(ownerRefs, child.GetOwnerReferences()) {
 			h.recorder.Eventf(child, corev1.EventTypeNormal, "RemoveWatch", "Removing watch for %s %s", kindOf(child), child.GetName())
 			child.SetOwnerReferences(ownerRefs)
-			err := h.Update(context.TODO(), child)
+			err := h.updateChild(ctx, child, obj, audit.OwnerReferenceRemoved)
 			if err != nil {
-				return fmt.Errorf("error updating child %s/%s: %v", child.GetNamespace(), child.GetName(), err)
+				return errors.Wrapf(err, "error updating child %s/%s", child.GetNamespace(), child.GetName())
 			}
 		}
 	}
@@ -54,32 +54,38 @@ func (h *Handler) removeOwnerReferences(obj podController, children []Object) er
 // updateOwnerReferences determines which children need to have their
 // OwnerReferences added/updated and which need to have their OwnerReferences
 // removed and then performs all updates
-func (h *Handler) updateOwnerReferences(owner podController, existing []Object, current []configObject) error {
-	// Add an owner reference to each child object
+func (h *Handler) updateOwnerReferences(ctx context.Context, owner podController, existing []Object, current []configObject) error {
+	// Add an owner reference to each child object, except Secrets whose data
+	// Wave cannot read and so must not update
 	errChan := make(chan error)
+	owned := 0
 	for _, obj := range current {
+		if obj.metadataOnly {
+			continue
+		}
+		owned++
 		go func(child Object) {
-			errChan <- h.updateOwnerReference(owner, child)
+			errChan <- h.updateOwnerReference(ctx, owner, child)
 		}(obj.object)
 	}
 
 	// Return any errors encountered updating the child objects
-	errs := []string{}
-	for range current {
+	errs := []error{}
+	for i := 0; i < owned; i++ {
 		err := <-errChan
 		if err != nil {
-			errs = append(errs, err.Error())
+			errs = append(errs, err)
 		}
 	}
 	if len(errs) > 0 {
-		return fmt.Errorf("error(s) encountered updating children: %s", strings.Join(errs, ", "))
+		return childErrors{message: "error(s) encountered updating children", errs: errs}
 	}
 
 	// Get the orphaned children and remove their OwnerReferences
 	orphans := getOrphans(existing, current)
-	err := h.removeOwnerReferences(owner, orphans)
+	err := h.removeOwnerReferences(ctx, owner, orphans)
 	if err != nil {
-		return fmt.Errorf("error removing Owner References: %v", err)
+		return errors.Wrap(err, "error removing Owner References")
 	}
 
 	return nil
@@ -87,7 +93,7 @@ func (h *Handler) updateOwnerReferences(owner podController, existing []Object,
 
 // updateOwnerReference ensures that the child object has an OwnerReference
 // pointing to the owner
-func (h *Handler) updateOwnerReference(owner podController, child Object) error {
+func (h *Handler) updateOwnerReference(ctx context.Context, owner podController, child Object) error {
 	ownerRef := getOwnerReference(owner)
 	for _, ref := range child.GetOwnerReferences() {
 		// Owner Reference already exists, do nothing
@@ -100,9 +106,9 @@ func (h *Handler) updateOwnerReference(owner podController, child Object) error
 	h.recorder.Eventf(child, corev1.EventTypeNormal, "AddWatch", "Adding watch for %s %s", kindOf(child), child.GetName())
 	ownerRefs := append(child.GetOwnerReferences(), ownerRef)
 	child.SetOwnerReferences(ownerRefs)
-	err := h.Update(context.TODO(), child)
+	err := h.updateChild(ctx, child, owner, audit.OwnerReferenceAdded)
 	if err != nil {
-		return fmt.Errorf("error updating child: %v", err)
+		return errors.Wrap(err, "error updating child")
 	}
 	return nil
 }
@@ -17,6 +17,7 @@ limitations under the License.
 package core
 
 import (
+	"context"
 	"sync"
 	"time"
 
@@ -84,15 +85,14 @@ var _ = Describe("Wave owner references Suite", func() {
 
 		ownerRef = utils.GetOwnerRefDeployment(deploymentObject)
 
-		stopMgr, mgrStopped = StartTestManager(mgr)
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
 
 		// Make sure caches have synced
 		m.Get(deploymentObject, timeout).Should(Succeed())
 	})
 
 	AfterEach(func() {
-		close(stopMgr)
-		mgrStopped.Wait()
+		utils.StopTestManager(stopMgr, mgrStopped)
 
 		utils.DeleteAll(cfg, timeout,
 			&appsv1.DeploymentList{},
@@ -116,7 +116,7 @@ var _ = Describe("Wave owner references Suite", func() {
 			}
 
 			children := []Object{cm1, s1}
-			err := h.removeOwnerReferences(podControllerDeployment, children)
+			err := h.removeOwnerReferences(context.TODO(), podControllerDeployment, children)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -170,7 +170,7 @@ var _ = Describe("Wave owner references Suite", func() {
 				},
 				},
 			}
-			err := h.updateOwnerReferences(podControllerDeployment, existing, current)
+			err := h.updateOwnerReferences(context.TODO(), podControllerDeployment, existing, current)
 			Expect(err).NotTo(HaveOccurred())
 		})
 
@@ -215,7 +215,7 @@ var _ = Describe("Wave owner references Suite", func() {
 			m.Eventually(cm1, timeout).Should(utils.WithOwnerReferences(ContainElement(otherRef)))
 
 			m.Get(cm1, timeout).Should(Succeed())
-			Expect(h.updateOwnerReference(podControllerDeployment, cm1)).NotTo(HaveOccurred())
+			Expect(h.updateOwnerReference(context.TODO(), podControllerDeployment, cm1)).NotTo(HaveOccurred())
 			m.Eventually(cm1, timeout).Should(utils.WithOwnerReferences(ContainElement(ownerRef)))
 		})
 
@@ -232,7 +232,7 @@ var _ = Describe("Wave owner references Suite", func() {
 			// Get the original version
 			m.Get(cm2, timeout).Should(Succeed())
 			originalVersion := cm2.GetResourceVersion()
-			Expect(h.updateOwnerReference(podControllerDeployment, cm2)).NotTo(HaveOccurred())
+			Expect(h.updateOwnerReference(context.TODO(), podControllerDeployment, cm2)).NotTo(HaveOccurred())
 
 			// Compare current version
 			m.Get(cm2, timeout).Should(Succeed())
@@ -241,7 +241,7 @@ var _ = Describe("Wave owner references Suite", func() {
 
 		It("sends events for adding each owner reference", func() {
 			m.Get(cm1, timeout).Should(Succeed())
-			Expect(h.updateOwnerReference(podControllerDeployment, cm1)).NotTo(HaveOccurred())
+			Expect(h.updateOwnerReference(context.TODO(), podControllerDeployment, cm1)).NotTo(HaveOccurred())
 			m.Eventually(cm1, timeout).Should(utils.WithOwnerReferences(ContainElement(ownerRef)))
 
 			events := &corev1.EventList{}
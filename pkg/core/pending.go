@@ -0,0 +1,90 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// pendingRollout is recorded in the PendingRolloutAnnotation of a workload
+// whose rollout was deferred to a reserved start time, so that the
+// reservation survives restarts of Wave
+type pendingRollout struct {
+	// Hash is the configuration hash the rollout was deferred for
+	Hash string `json:"hash"`
+
+	// NotBefore is the earliest time at which the rollout may start
+	NotBefore time.Time `json:"notBefore"`
+}
+
+// getPendingRollout returns the pending rollout recorded on the podController,
+// if any
+func getPendingRollout(obj podController) (pendingRollout, bool) {
+	value, ok := obj.GetAnnotations()[PendingRolloutAnnotation]
+	if !ok {
+		return pendingRollout{}, false
+	}
+	pending := pendingRollout{}
+	if err := json.Unmarshal([]byte(value), &pending); err != nil || pending.NotBefore.IsZero() {
+		return pendingRollout{}, false
+	}
+	return pending, true
+}
+
+// setPendingRollout records the pending rollout on the podController, or
+// removes the record if pending is nil
+func setPendingRollout(obj podController, pending *pendingRollout) error {
+	annotations := obj.GetAnnotations()
+	if pending == nil {
+		if _, ok := annotations[PendingRolloutAnnotation]; ok {
+			delete(annotations, PendingRolloutAnnotation)
+			obj.SetAnnotations(annotations)
+		}
+		return nil
+	}
+
+	value, err := json.Marshal(pending)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[PendingRolloutAnnotation] = string(value)
+	obj.SetAnnotations(annotations)
+	return nil
+}
+
+// syncPendingRollout records the start time reserved for the rollout of the
+// instance to the hash on the desired state. A recorded start time which was
+// not restored, for example while the instance is paused, is kept until the
+// hash is applied.
+func (h *Handler) syncPendingRollout(instance, desired podController, hash string) error {
+	if h.gate == nil {
+		return setPendingRollout(desired, nil)
+	}
+	if start, ok := h.gate.reservation(instance.GetUID()); ok {
+		return setPendingRollout(desired, &pendingRollout{Hash: hash, NotBefore: start.UTC()})
+	}
+	if pending, ok := getPendingRollout(instance); ok && getConfigHash(desired) != hash {
+		return setPendingRollout(desired, &pending)
+	}
+	return setPendingRollout(desired, nil)
+}
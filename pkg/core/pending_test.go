@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave pending rollout Suite", func() {
+	var c client.Client
+	var fakeClock *clock.FakeClock
+
+	newDeployment := func(name string) *appsv1.Deployment {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetName(name)
+		d.SetUID(types.UID(name))
+		d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		d.Spec.Template.Spec.Volumes = nil
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+			},
+		}}
+		return d
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(newDeployment("first"), newDeployment("second"), utils.ExampleConfigMap1.DeepCopy())
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+	})
+
+	newHandler := func() *Handler {
+		return NewHandler(c, record.NewFakeRecorder(100), WithClock(fakeClock), WithCapacityOptions(CapacityOptions{SpreadInterval: time.Minute}))
+	}
+
+	reconcile := func(h *Handler, name string) (*appsv1.Deployment, time.Duration) {
+		d := &appsv1.Deployment{}
+		key := types.NamespacedName{Namespace: "default", Name: name}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		result, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+		updated := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, updated)).To(Succeed())
+		return updated, result.RequeueAfter
+	}
+
+	It("records the reserved start time of deferred rollouts", func() {
+		h := newHandler()
+		d, _ := reconcile(h, "first")
+		Expect(getConfigHash(&deployment{d})).NotTo(BeEmpty())
+		Expect(d.GetAnnotations()).NotTo(HaveKey(PendingRolloutAnnotation))
+
+		d, wait := reconcile(h, "second")
+		Expect(wait).To(Equal(time.Minute))
+		Expect(getConfigHash(&deployment{d})).To(BeEmpty())
+		pending, ok := getPendingRollout(&deployment{d})
+		Expect(ok).To(BeTrue())
+		Expect(pending.NotBefore).To(Equal(fakeClock.Now().Add(time.Minute)))
+	})
+
+	It("keeps the reserved start time across restarts", func() {
+		h := newHandler()
+		reconcile(h, "first")
+		reconcile(h, "second")
+
+		// A new Handler has no reservations in memory
+		fakeClock.Step(30 * time.Second)
+		h = newHandler()
+		d, wait := reconcile(h, "second")
+		Expect(wait).To(Equal(30 * time.Second))
+		Expect(getConfigHash(&deployment{d})).To(BeEmpty())
+
+		fakeClock.Step(30 * time.Second)
+		d, wait = reconcile(h, "second")
+		Expect(wait).To(BeZero())
+		Expect(getConfigHash(&deployment{d})).NotTo(BeEmpty())
+	})
+
+	It("removes the record once the hash is applied", func() {
+		h := newHandler()
+		reconcile(h, "first")
+		d, _ := reconcile(h, "second")
+		instance := &deployment{d}
+		pending, _ := getPendingRollout(instance)
+
+		desired := instance.DeepCopy()
+		Expect(h.syncPendingRollout(instance, desired, pending.Hash)).To(Succeed())
+		Expect(desired.GetAnnotations()).To(HaveKey(PendingRolloutAnnotation))
+
+		h.gate.forget(instance.GetUID())
+		setConfigHash(desired, pending.Hash)
+		Expect(h.syncPendingRollout(instance, desired, pending.Hash)).To(Succeed())
+		Expect(desired.GetAnnotations()).NotTo(HaveKey(PendingRolloutAnnotation))
+	})
+
+	It("keeps the recorded start time while paused", func() {
+		h := newHandler()
+		reconcile(h, "first")
+		reconcile(h, "second")
+
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "second"}, d)).To(Succeed())
+		d.Annotations[PausedAnnotation] = "true"
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		d, _ = reconcile(newHandler(), "second")
+		Expect(d.GetAnnotations()).To(HaveKey(PendingRolloutAnnotation))
+	})
+})
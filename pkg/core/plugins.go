@@ -0,0 +1,132 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/plugin"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// PluginSourcesAnnotation is the key of the annotation listing the plugin
+// sources of a workload as comma separated "<plugin>" or
+// "<plugin>=<argument>" entries, e.g. "flags=checkout,configdb=payments".
+// Their versions are mixed into the workload's configuration hash.
+const PluginSourcesAnnotation = "wave.pusher.com/plugin-sources"
+
+// PluginOptions configures the trigger-source plugins workloads can reference
+type PluginOptions struct {
+	// Sources maps the name of each plugin to the Source computing its
+	// versions
+	Sources map[string]plugin.Source
+
+	// PollInterval is how often the versions of a workload's plugin sources
+	// are computed again, as no event announces their changes
+	PollInterval time.Duration
+
+	// Timeout limits each call of a plugin
+	Timeout time.Duration
+}
+
+// WithSourcePlugins allows workloads to reference external sources of
+// configuration computed by plugins with the PluginSourcesAnnotation
+func WithSourcePlugins(o PluginOptions) Option {
+	return func(h *Handler) {
+		h.plugins = &o
+	}
+}
+
+// pluginSource is an entry of the PluginSourcesAnnotation
+type pluginSource struct {
+	plugin   string
+	argument string
+}
+
+// String returns the key the version of the source is mixed into the hash
+// with
+func (s pluginSource) String() string {
+	return s.plugin + "=" + s.argument
+}
+
+// getPluginSources parses the PluginSourcesAnnotation of the instance
+func getPluginSources(obj podController) []pluginSource {
+	var sources []pluginSource
+	for _, entry := range strings.Split(obj.GetAnnotations()[PluginSourcesAnnotation], ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "=", 2)
+		source := pluginSource{plugin: strings.TrimSpace(parts[0])}
+		if len(parts) == 2 {
+			source.argument = strings.TrimSpace(parts[1])
+		}
+		sources = append(sources, source)
+	}
+	return sources
+}
+
+// getPluginVersions calls the plugins of the sources the instance references
+// and returns their versions. Sources of unknown plugins are reported and
+// left out of the hash.
+func (h *Handler) getPluginVersions(ctx context.Context, instance podController) (map[string]string, error) {
+	versions := make(map[string]string)
+	if h.plugins == nil {
+		return versions, nil
+	}
+	for _, source := range getPluginSources(instance) {
+		p, ok := h.plugins.Sources[source.plugin]
+		if !ok {
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "UnknownPluginSource", "Ignoring source %q: no plugin %q is configured", source, source.plugin)
+			continue
+		}
+		version, err := h.callPlugin(ctx, p, instance, source)
+		if err != nil {
+			return nil, errors.Wrapf(err, "plugin %q", source.plugin)
+		}
+		versions[source.String()] = version
+	}
+	return versions, nil
+}
+
+// callPlugin returns the version of the source within the plugin timeout
+func (h *Handler) callPlugin(ctx context.Context, p plugin.Source, instance podController, source pluginSource) (string, error) {
+	if h.plugins.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.plugins.Timeout)
+		defer cancel()
+	}
+	return p.Version(ctx, &plugin.VersionRequest{
+		Namespace: instance.GetNamespace(),
+		Kind:      kindOf(instance),
+		Name:      instance.GetName(),
+		Argument:  source.argument,
+	})
+}
+
+// pluginPollInterval returns how long to wait before computing the versions
+// of the instance's plugin sources again, or zero if it has none
+func (h *Handler) pluginPollInterval(instance podController) time.Duration {
+	if h.plugins == nil || len(getPluginSources(instance)) == 0 {
+		return 0
+	}
+	return h.plugins.PollInterval
+}
@@ -0,0 +1,138 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/plugin"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakePlugin returns the version set for each argument
+type fakePlugin struct {
+	versions map[string]string
+	requests []*plugin.VersionRequest
+}
+
+func (f *fakePlugin) Version(ctx context.Context, req *plugin.VersionRequest) (string, error) {
+	f.requests = append(f.requests, req)
+	version, ok := f.versions[req.Argument]
+	if !ok {
+		return "", fmt.Errorf("unknown flag %q", req.Argument)
+	}
+	return version, nil
+}
+
+var _ = Describe("Wave plugin sources Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var flags *fakePlugin
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() (time.Duration, error) {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		return result.RequeueAfter, err
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace: "default",
+			Name:      "example",
+			Annotations: map[string]string{
+				RequiredAnnotation:      "true",
+				PluginSourcesAnnotation: "flags=checkout, other",
+			},
+		}}
+		c = fake.NewFakeClient(d)
+		recorder = record.NewFakeRecorder(100)
+		flags = &fakePlugin{versions: map[string]string{"checkout": "1"}}
+		h = NewHandler(c, recorder, WithSourcePlugins(PluginOptions{
+			Sources:      map[string]plugin.Source{"flags": flags},
+			PollInterval: time.Minute,
+		}))
+	})
+
+	It("compares the applied hash with one including the versions of plugin sources", func() {
+		_, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		diff, err := h.DiffConfig(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff.Pending()).To(BeFalse())
+
+		flags.versions["checkout"] = "2"
+		diff, err = h.DiffConfig(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(diff.Pending()).To(BeTrue())
+	})
+
+	It("restarts the workload when the version of a plugin source changes", func() {
+		wait, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(Equal(time.Minute))
+		hash := getConfigHash(&deployment{getDeployment()})
+		Expect(hash).NotTo(BeEmpty())
+		Expect(flags.requests).To(ConsistOf(&plugin.VersionRequest{Namespace: "default", Kind: "Deployment", Name: "example", Argument: "checkout"}))
+
+		_, err = handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal(hash))
+
+		flags.versions["checkout"] = "2"
+		_, err = handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(Equal(hash))
+	})
+
+	It("reports sources of unknown plugins", func() {
+		_, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(recorder.Events).To(Receive(And(ContainSubstring("UnknownPluginSource"), ContainSubstring(`no plugin "other"`))))
+	})
+
+	It("leaves the hash as it is when a plugin fails", func() {
+		delete(flags.versions, "checkout")
+		_, err := handle()
+		Expect(err).To(MatchError(ContainSubstring(`unknown flag "checkout"`)))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(BeEmpty())
+	})
+
+	It("doesn't poll workloads without plugin sources", func() {
+		d := getDeployment()
+		delete(d.Annotations, PluginSourcesAnnotation)
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		wait, err := handle()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(BeZero())
+		Expect(flags.requests).To(BeEmpty())
+	})
+})
@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/pkg/notify"
+	"github.com/wave-k8s/wave/pkg/policy"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// PolicyOptions configures the external policy Wave consults before applying
+// a configuration hash change
+type PolicyOptions struct {
+	// Evaluator makes the decision for each rollout
+	Evaluator policy.Evaluator
+
+	// Timeout is the maximum time allowed for a single evaluation
+	Timeout time.Duration
+
+	// DeferInterval is how long to wait before re-evaluating a deferred
+	// rollout when the verdict does not specify a retry time
+	DeferInterval time.Duration
+
+	// FailOpen allows rollouts to proceed when the policy cannot be
+	// evaluated. Otherwise the rollout is retried with backoff.
+	FailOpen bool
+}
+
+// policyHook remembers the hashes denied for each instance so that a denied
+// rollout is not re-evaluated until the instance's configuration changes
+type policyHook struct {
+	options PolicyOptions
+
+	mutex  sync.Mutex
+	denied map[types.UID]string
+}
+
+// newPolicyHook constructs a policyHook using the given options
+func newPolicyHook(options PolicyOptions) *policyHook {
+	return &policyHook{options: options, denied: make(map[types.UID]string)}
+}
+
+// isDenied returns true if the hash was previously denied for the owner
+func (p *policyHook) isDenied(owner types.UID, hash string) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.denied[owner] == hash
+}
+
+// deny records that the hash was denied for the owner
+func (p *policyHook) deny(owner types.UID, hash string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.denied[owner] = hash
+}
+
+// forget removes any record of denials for the owner
+func (p *policyHook) forget(owner types.UID) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.denied, owner)
+}
+
+// evaluatePolicy consults the external policy about the rollout of the
+// instance to the new hash. It returns whether the rollout is allowed and,
+// for deferred rollouts, how long to wait before evaluating it again.
+func (h *Handler) evaluatePolicy(ctx context.Context, instance podController, hash string, changes []sourceChange) (bool, time.Duration, error) {
+	if h.policy == nil {
+		return true, 0, nil
+	}
+	if h.policy.isDenied(instance.GetUID(), hash) {
+		return false, 0, nil
+	}
+
+	log := logf.Log.WithName("wave")
+	ctx, cancel := context.WithTimeout(ctx, h.policy.options.Timeout)
+	defer cancel()
+	verdict, err := h.policy.options.Evaluator.Evaluate(ctx, buildPolicyInput(instance, hash, changes))
+	if err != nil {
+		if h.policy.options.FailOpen {
+			log.Error(err, "Unable to evaluate policy, allowing rollout", "namespace", instance.GetNamespace(), "name", instance.GetName())
+			return true, 0, nil
+		}
+		return false, 0, errors.Wrap(err, "error evaluating policy")
+	}
+
+	switch verdict.Decision {
+	case policy.Deny:
+		log.V(0).Info("Rollout denied by policy", "namespace", instance.GetNamespace(), "name", instance.GetName(), "reason", verdict.Reason)
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "RolloutDenied", "Rollout to hash %s denied by policy: %s", hash, verdict.Reason)
+		h.sendNotification(notify.EventSkipped, instance, hash, sourceNames(changes), nil, fmt.Sprintf("denied by policy: %s", verdict.Reason))
+		h.recordDecision(audit.Denied, instance, hash, changes, verdict.Reason)
+		h.policy.deny(instance.GetUID(), hash)
+		return false, 0, nil
+	case policy.Defer:
+		wait := verdict.RetryAfter()
+		if wait <= 0 {
+			wait = h.policy.options.DeferInterval
+		}
+		log.V(0).Info("Rollout deferred by policy", "namespace", instance.GetNamespace(), "name", instance.GetName(), "reason", verdict.Reason, "wait", wait.String())
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutDeferred", "Rollout deferred for %s by policy: %s", wait, verdict.Reason)
+		h.recordDecision(audit.Deferred, instance, hash, changes, verdict.Reason)
+		return false, wait, nil
+	default:
+		return true, 0, nil
+	}
+}
+
+// buildPolicyInput describes the rollout to the policy
+func buildPolicyInput(instance podController, hash string, changes []sourceChange) policy.Input {
+	policyChanges := []policy.Change{}
+	for _, change := range changes {
+		policyChanges = append(policyChanges, policy.Change{
+			Kind: change.kind,
+			Name: change.name,
+			Type: change.change,
+		})
+	}
+	return policy.Input{
+		Workload: policy.Workload{
+			Namespace:   instance.GetNamespace(),
+			Kind:        kindOf(instance),
+			Name:        instance.GetName(),
+			Labels:      instance.GetLabels(),
+			Annotations: instance.GetAnnotations(),
+		},
+		CurrentHash: getConfigHash(instance),
+		NewHash:     hash,
+		Changes:     policyChanges,
+		Summary:     summarizeChanges(changes),
+	}
+}
@@ -0,0 +1,110 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/policy"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/client-go/tools/record"
+)
+
+// fakeEvaluator returns a fixed verdict and counts its evaluations
+type fakeEvaluator struct {
+	verdict     policy.Verdict
+	err         error
+	evaluations int
+	lastInput   policy.Input
+}
+
+func (f *fakeEvaluator) Evaluate(ctx context.Context, input policy.Input) (policy.Verdict, error) {
+	f.evaluations++
+	f.lastInput = input
+	return f.verdict, f.err
+}
+
+var _ = Describe("Wave policy Suite", func() {
+	var h *Handler
+	var evaluator *fakeEvaluator
+	var instance podController
+	var changes = []sourceChange{
+		{sourceKey: sourceKey{kind: "ConfigMap", name: "example1"}, change: sourceModified},
+	}
+
+	BeforeEach(func() {
+		evaluator = &fakeEvaluator{verdict: policy.Verdict{Decision: policy.Allow}}
+		h = &Handler{recorder: record.NewFakeRecorder(10)}
+		WithPolicy(PolicyOptions{
+			Evaluator:     evaluator,
+			Timeout:       time.Second,
+			DeferInterval: time.Minute,
+		})(h)
+		instance = &deployment{utils.ExampleDeployment.DeepCopy()}
+	})
+
+	It("passes the workload, hashes and changes to the policy", func() {
+		allowed, _, err := h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+		Expect(evaluator.lastInput.Workload.Kind).To(Equal("Deployment"))
+		Expect(evaluator.lastInput.NewHash).To(Equal("new"))
+		Expect(evaluator.lastInput.Changes).To(Equal([]policy.Change{{Kind: "ConfigMap", Name: "example1", Type: "modified"}}))
+		Expect(evaluator.lastInput.Summary).To(Equal("0 added, 0 removed, 1 modified"))
+	})
+
+	It("does not re-evaluate a denied hash", func() {
+		evaluator.verdict = policy.Verdict{Decision: policy.Deny}
+		allowed, wait, err := h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeFalse())
+		Expect(wait).To(BeZero())
+
+		allowed, _, _ = h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(allowed).To(BeFalse())
+		Expect(evaluator.evaluations).To(Equal(1))
+
+		h.evaluatePolicy(context.TODO(), instance, "newer", changes)
+		Expect(evaluator.evaluations).To(Equal(2))
+	})
+
+	It("uses the default defer interval when the verdict has none", func() {
+		evaluator.verdict = policy.Verdict{Decision: policy.Defer}
+		allowed, wait, err := h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeFalse())
+		Expect(wait).To(Equal(time.Minute))
+	})
+
+	It("returns an error when the policy fails", func() {
+		evaluator.err = errors.New("unavailable")
+		_, _, err := h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("allows the rollout when the policy fails open", func() {
+		evaluator.err = errors.New("unavailable")
+		h.policy.options.FailOpen = true
+		allowed, _, err := h.evaluatePolicy(context.TODO(), instance, "new", changes)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(allowed).To(BeTrue())
+	})
+})
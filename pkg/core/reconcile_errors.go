@@ -0,0 +1,124 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// errorCategory classifies the errors reconciles fail with, so that alerts
+// can tell errors needing an operator from transient ones
+type errorCategory string
+
+const (
+	// rbacDenied errors are API calls Wave's RBAC doesn't permit
+	rbacDenied errorCategory = "rbac_denied"
+
+	// conflict errors are writes made against an outdated version of an
+	// object, which succeed when retried
+	conflict errorCategory = "conflict"
+
+	// notFound errors are objects which don't exist
+	notFound errorCategory = "not_found"
+
+	// throttled errors are API calls rejected by rate limiting
+	throttled errorCategory = "throttled"
+
+	// webhookTimeout errors are writes the API server timed out, typically
+	// because an admission webhook didn't answer in time
+	webhookTimeout errorCategory = "webhook_timeout"
+
+	// otherError errors fit none of the other categories
+	otherError errorCategory = "other"
+)
+
+// errorReasons are the reasons of the Events recording reconcile errors of
+// each category
+var errorReasons = map[errorCategory]string{
+	rbacDenied:     "RBACDenied",
+	conflict:       "UpdateConflict",
+	notFound:       "NotFound",
+	throttled:      "Throttled",
+	webhookTimeout: "WebhookTimeout",
+	otherError:     "ReconcileError",
+}
+
+// reconcileErrors counts the reconciles which failed, by category of error
+var reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_reconcile_errors_total",
+	Help: "Number of reconciles which failed, by kind of workload and category of error",
+}, []string{"kind", "category"})
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrors)
+}
+
+// classifyError returns the category of a reconcile error by the type of the
+// API error the Handler wrapped it around
+func classifyError(err error) errorCategory {
+	cause := errors.Cause(err)
+	switch {
+	case apierrors.IsTimeout(cause):
+		return webhookTimeout
+	case apierrors.IsForbidden(cause):
+		return rbacDenied
+	case apierrors.IsConflict(cause):
+		return conflict
+	case apierrors.IsTooManyRequests(cause):
+		return throttled
+	case apierrors.IsNotFound(cause):
+		return notFound
+	}
+	return otherError
+}
+
+// childErrors are the errors encountered for several children of a workload.
+// The first of them is their cause, by which the reconcile error is
+// classified.
+type childErrors struct {
+	message string
+	errs    []error
+}
+
+// Error implements error
+func (e childErrors) Error() string {
+	messages := []string{}
+	for _, err := range e.errs {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf("%s: %s", e.message, strings.Join(messages, ", "))
+}
+
+// Cause returns the first of the errors
+func (e childErrors) Cause() error {
+	return e.errs[0]
+}
+
+// reportError counts the failed reconcile of the instance by the category of
+// its error, and records it in an Event whose reason names the category
+func (h *Handler) reportError(instance podController, err error) {
+	category := classifyError(err)
+	reconcileErrors.WithLabelValues(kindOf(instance), string(category)).Inc()
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, errorReasons[category], "Reconcile failed: %v", err)
+}
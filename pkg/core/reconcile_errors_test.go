@@ -0,0 +1,100 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/wave-k8s/wave/test/utils"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave reconcile errors Suite", func() {
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+
+	// errorsOf returns the number of failed reconciles of Deployments in the
+	// category
+	errorsOf := func(category errorCategory) float64 {
+		metric := &dto.Metric{}
+		Expect(reconcileErrors.WithLabelValues("Deployment", string(category)).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	It("classifies API errors", func() {
+		Expect(classifyError(apierrors.NewForbidden(deployments, "example", errors.New("denied")))).To(Equal(rbacDenied))
+		Expect(classifyError(apierrors.NewConflict(deployments, "example", errors.New("modified")))).To(Equal(conflict))
+		Expect(classifyError(apierrors.NewNotFound(deployments, "example"))).To(Equal(notFound))
+		Expect(classifyError(apierrors.NewTooManyRequests("Too many requests, please try again later.", 1))).To(Equal(throttled))
+		Expect(classifyError(errors.New("something broke"))).To(Equal(otherError))
+	})
+
+	It("classifies API errors wrapped by the Handler", func() {
+		wrap := func(err error) error {
+			return errors.Wrap(errors.Wrapf(err, "error updating instance %s/%s", "default", "example"), "error rolling out Pods")
+		}
+		Expect(classifyError(wrap(apierrors.NewForbidden(deployments, "example", errors.New("denied"))))).To(Equal(rbacDenied))
+		Expect(classifyError(wrap(apierrors.NewConflict(deployments, "example", errors.New("modified"))))).To(Equal(conflict))
+		Expect(classifyError(wrap(apierrors.NewNotFound(deployments, "example")))).To(Equal(notFound))
+		Expect(classifyError(wrap(apierrors.NewTooManyRequests("Too many requests, please try again later.", 1)))).To(Equal(throttled))
+		Expect(classifyError(wrap(apierrors.NewServerTimeout(deployments, "patch", 1)))).To(Equal(otherError))
+	})
+
+	It("classifies errors of several children by the first of them", func() {
+		err := childErrors{message: "error(s) encountered updating children", errs: []error{
+			apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "example1", errors.New("denied")),
+			apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "example2", errors.New("modified")),
+		}}
+		Expect(classifyError(err)).To(Equal(rbacDenied))
+		Expect(err.Error()).To(HavePrefix("error(s) encountered updating children: "))
+	})
+
+	It("doesn't classify API errors by their message", func() {
+		forbidden := apierrors.NewForbidden(deployments, "example", errors.New("denied"))
+		Expect(classifyError(fmt.Errorf("error updating instance default/example: %v", forbidden))).To(Equal(otherError))
+	})
+
+	It("classifies writes the API server timed out", func() {
+		err := apierrors.NewTimeoutError(`request did not complete within requested timeout - context deadline exceeded`, 0)
+		Expect(classifyError(errors.Wrap(err, "error updating instance default/example"))).To(Equal(webhookTimeout))
+
+		err = apierrors.NewInternalError(errors.New(`failed calling webhook "policy.example.com": Post https://policy.example.svc:443/validate: no endpoints available`))
+		Expect(classifyError(err)).To(Equal(otherError))
+	})
+
+	It("counts failed reconciles and records their category in events", func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		c := fake.NewFakeClient(d)
+		recorder := record.NewFakeRecorder(10)
+		h := NewHandler(c, recorder)
+		before := errorsOf(notFound)
+
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).To(HaveOccurred())
+		Expect(errorsOf(notFound)).To(Equal(before + 1))
+		Eventually(recorder.Events, time.Second).Should(Receive(HavePrefix("Warning NotFound Reconcile failed: ")))
+	})
+})
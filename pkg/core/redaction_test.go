@@ -0,0 +1,240 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/pkg/notify"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// notifierFunc adapts a function to the notify.Notifier interface
+type notifierFunc func(context.Context, notify.Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event notify.Event) error {
+	return f(ctx, event)
+}
+
+// outputs collects everything Wave emits that could leak a Secret value
+type outputs struct {
+	mutex   sync.Mutex
+	entries []string
+}
+
+func (o *outputs) add(format string, args ...interface{}) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	o.entries = append(o.entries, fmt.Sprintf(format, args...))
+}
+
+func (o *outputs) addJSON(v interface{}) {
+	data, err := json.Marshal(v)
+	Expect(err).NotTo(HaveOccurred())
+	o.add("%s", data)
+}
+
+func (o *outputs) all() []string {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return append([]string{}, o.entries...)
+}
+
+// secretAlphabet always includes characters that cannot appear in the hex
+// hashes or names Wave emits, so values never match them by chance
+const secretAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789!#%&*+-_"
+
+// randomSecretValue returns a random value that includes an upper case letter
+func randomSecretValue(r *rand.Rand) string {
+	value := make([]byte, 12+r.Intn(36))
+	for i := range value {
+		value[i] = secretAlphabet[r.Intn(len(secretAlphabet))]
+	}
+	value[r.Intn(len(value))] = byte('A' + r.Intn(26))
+	return string(value)
+}
+
+var _ = Describe("Wave redaction Suite", func() {
+	var r *rand.Rand
+	var out *outputs
+	var values []string
+
+	BeforeEach(func() {
+		r = rand.New(rand.NewSource(GinkgoRandomSeed()))
+		out = &outputs{}
+		values = []string{}
+	})
+
+	// newSecret returns a Secret with random values, remembering them
+	newSecret := func(name string) *corev1.Secret {
+		s := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, UID: types.UID(name + "-uid")},
+			Data:       map[string][]byte{},
+		}
+		for i := 0; i < 1+r.Intn(4); i++ {
+			value := randomSecretValue(r)
+			values = append(values, value)
+			s.Data[fmt.Sprintf("key%d", i)] = []byte(value)
+		}
+		return s
+	}
+
+	// newDeployment returns a Deployment consuming the Secrets in every way
+	// Wave supports, including keys and Secrets which may not exist
+	newDeployment := func() *appsv1.Deployment {
+		optional := r.Intn(2) == 0
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "example",
+				UID:         types.UID("example-uid"),
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			},
+		}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name:         "whole",
+			VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "whole"}},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{{
+				SecretRef: &corev1.SecretEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "maybe-missing"},
+					Optional:             &optional,
+				},
+			}},
+			Env: []corev1.EnvVar{
+				{Name: "KEY0", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "keys"}, Key: "key0",
+				}}},
+				{Name: "MISSING", ValueFrom: &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "keys"}, Key: "missing",
+				}}},
+			},
+		}}
+		return d
+	}
+
+	// reconcile runs the Handler and the diff against the cluster, collecting
+	// every output
+	reconcile := func(c client.Client, h *Handler, recorder *record.FakeRecorder) {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		if _, err := h.HandleDeployment(context.TODO(), d); err != nil {
+			out.add("%v", err)
+		}
+		if diff, err := DiffConfig(context.TODO(), c, d); err != nil {
+			out.add("%v", err)
+		} else {
+			out.addJSON(diff)
+		}
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		out.addJSON(d.GetAnnotations())
+		out.addJSON(d.Spec.Template.GetAnnotations())
+		for len(recorder.Events) > 0 {
+			out.add("%s", <-recorder.Events)
+		}
+	}
+
+	It("never emits Secret values", func() {
+		for i := 0; i < 25; i++ {
+			secrets := []*corev1.Secret{newSecret("whole"), newSecret("keys")}
+			if r.Intn(2) == 0 {
+				secrets = append(secrets, newSecret("maybe-missing"))
+			}
+			objs := []runtime.Object{newDeployment()}
+			for _, s := range secrets {
+				objs = append(objs, s)
+			}
+			c := fake.NewFakeClient(objs...)
+
+			recorder := record.NewFakeRecorder(100)
+			denyList, err := NewSecretDenyList([]string{"whole"}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			opts := []Option{
+				WithNotifier(notifierFunc(func(ctx context.Context, event notify.Event) error {
+					out.addJSON(event)
+					return nil
+				})),
+				WithAudit(AuditOptions{
+					Writes: true,
+					Sink: sinkFunc(func(ctx context.Context, record audit.Record) error {
+						out.addJSON(record)
+						return nil
+					}),
+				}),
+			}
+			if r.Intn(2) == 0 {
+				opts = append(opts, WithSecretDenyList(denyList))
+			}
+			h := NewHandler(c, recorder, opts...)
+
+			// Reconcile, change every value and reconcile again
+			reconcile(c, h, recorder)
+			for _, s := range secrets {
+				for key := range s.Data {
+					value := randomSecretValue(r)
+					values = append(values, value)
+					s.Data[key] = []byte(value)
+				}
+				current := &corev1.Secret{}
+				Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: s.GetName()}, current)).To(Succeed())
+				current.Data = s.Data
+				Expect(c.Update(context.TODO(), current)).To(Succeed())
+			}
+			reconcile(c, h, recorder)
+		}
+
+		// Notifications and audit records are sent asynchronously
+		time.Sleep(100 * time.Millisecond)
+		emitted := out.all()
+		Expect(emitted).NotTo(BeEmpty())
+		for _, value := range values {
+			encoded := base64.StdEncoding.EncodeToString([]byte(value))
+			for _, output := range emitted {
+				Expect(output).NotTo(ContainSubstring(value))
+				Expect(output).NotTo(ContainSubstring(encoded))
+			}
+		}
+	})
+
+	It("salts the hashes of Secret values", func() {
+		a := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{UID: types.UID("a")}}
+		b := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{UID: types.UID("b")}}
+		value := []byte("password")
+		Expect(secretValueHash(a, value)).NotTo(Equal(shortValueHash(value)))
+		Expect(secretValueHash(a, value)).NotTo(Equal(secretValueHash(b, value)))
+		Expect(secretValueHash(a, value)).To(Equal(secretValueHash(a.DeepCopy(), value)))
+	})
+})
@@ -0,0 +1,144 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sort"
+
+	"github.com/pkg/errors"
+	appsv1 "k8s.io/api/apps/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Reference describes a ConfigMap or Secret referenced by a workload
+type Reference struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+
+	// Keys lists the keys referenced individually. It is empty when the
+	// whole object is used.
+	Keys []string `json:"keys,omitempty"`
+
+	Required bool `json:"required"`
+}
+
+// References returns the ConfigMaps and Secrets referenced by the
+// Deployment, StatefulSet or DaemonSet, sorted by kind and name
+func References(obj Object) ([]Reference, error) {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	configMaps, secrets := getChildNamesByType(instance)
+	references := []Reference{}
+	for kind, children := range map[string]map[string]configMetadata{"ConfigMap": configMaps, "Secret": secrets} {
+		for name, metadata := range children {
+			reference := Reference{Kind: kind, Name: name, Required: metadata.required}
+			if !metadata.allKeys && len(metadata.envFrom) == 0 {
+				for key := range metadata.keys {
+					reference.Keys = append(reference.Keys, key)
+				}
+				sort.Strings(reference.Keys)
+			}
+			references = append(references, reference)
+		}
+	}
+	sort.Slice(references, func(i, j int) bool {
+		if references[i].Kind != references[j].Kind {
+			return references[i].Kind < references[j].Kind
+		}
+		return references[i].Name < references[j].Name
+	})
+	return references, nil
+}
+
+// ListWorkloads lists the Deployments, StatefulSets and DaemonSets in the
+// namespace, or in all namespaces if namespace is empty, that match the
+// options
+func ListWorkloads(ctx context.Context, c client.Client, namespace string, opts ...client.ListOption) ([]Object, error) {
+	opts = append(opts, client.InNamespace(namespace))
+	workloads := []Object{}
+
+	if listsKind(opts, "Deployment") {
+		deployments := &appsv1.DeploymentList{}
+		if err := c.List(ctx, deployments, opts...); err != nil {
+			return nil, errors.Wrap(err, "error listing Deployments")
+		}
+		for i := range deployments.Items {
+			workloads = append(workloads, &deployments.Items[i])
+		}
+	}
+
+	if listsKind(opts, "StatefulSet") {
+		statefulsets := &appsv1.StatefulSetList{}
+		if err := c.List(ctx, statefulsets, opts...); err != nil {
+			return nil, errors.Wrap(err, "error listing StatefulSets")
+		}
+		for i := range statefulsets.Items {
+			workloads = append(workloads, &statefulsets.Items[i])
+		}
+	}
+
+	if listsKind(opts, "DaemonSet") {
+		daemonsets := &appsv1.DaemonSetList{}
+		if err := c.List(ctx, daemonsets, opts...); err != nil {
+			return nil, errors.Wrap(err, "error listing DaemonSets")
+		}
+		for i := range daemonsets.Items {
+			workloads = append(workloads, &daemonsets.Items[i])
+		}
+	}
+	return workloads, nil
+}
+
+// Consumers returns the Deployments, StatefulSets and DaemonSets in the
+// namespace that have the required annotation and reference the ConfigMap or
+// Secret of the given kind and name, listing the workloads with the options
+func Consumers(ctx context.Context, c client.Client, namespace, kind, name string, opts ...client.ListOption) ([]Object, error) {
+	workloads, err := ListWorkloads(ctx, c, namespace, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	consumers := []Object{}
+	for _, obj := range workloads {
+		instance, err := asPodController(obj)
+		if err != nil || !hasRequiredAnnotation(instance) {
+			continue
+		}
+		configMaps, secrets := getChildNamesByType(instance)
+		children := configMaps
+		if kind == "Secret" {
+			children = secrets
+		}
+		if _, ok := children[name]; ok {
+			consumers = append(consumers, obj)
+		}
+	}
+	return consumers, nil
+}
+
+// WorkloadKind returns the kind of the Deployment, StatefulSet or DaemonSet
+func WorkloadKind(obj Object) string {
+	instance, err := asPodController(obj)
+	if err != nil {
+		return "Unknown"
+	}
+	return kindOf(instance)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave references Suite", func() {
+	It("returns the ConfigMaps and Secrets referenced by the workload", func() {
+		references, err := References(utils.ExampleDeployment.DeepCopy())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(references).To(ContainElement(Reference{Kind: "ConfigMap", Name: "example1", Required: true}))
+		Expect(references).To(ContainElement(Reference{Kind: "ConfigMap", Name: "example4", Keys: []string{"key1"}, Required: false}))
+		Expect(references).To(ContainElement(Reference{Kind: "Secret", Name: "volume-optional", Required: false}))
+	})
+
+	It("rejects objects that are not workloads", func() {
+		_, err := References(&corev1.ConfigMap{})
+		Expect(err).To(HaveOccurred())
+	})
+	It("returns the tracked workloads consuming a ConfigMap or Secret", func() {
+		tracked := utils.ExampleDeployment.DeepCopy()
+		tracked.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		untracked := utils.ExampleDeployment.DeepCopy()
+		untracked.SetName("untracked")
+		c := fake.NewFakeClient(tracked, untracked)
+
+		consumers, err := Consumers(context.TODO(), c, tracked.GetNamespace(), "ConfigMap", "example1")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumers).To(HaveLen(1))
+		Expect(consumers[0].GetName()).To(Equal(tracked.GetName()))
+
+		consumers, err = Consumers(context.TODO(), c, tracked.GetNamespace(), "ConfigMap", "volume-optional")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumers).To(HaveLen(1))
+
+		consumers, err = Consumers(context.TODO(), c, tracked.GetNamespace(), "Secret", "unused")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(consumers).To(BeEmpty())
+	})
+})
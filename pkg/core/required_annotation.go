@@ -16,14 +16,37 @@ limitations under the License.
 
 package core
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
 // hasRequiredAnnotation returns true if the given PodController has the wave
-// annotation present
+// annotation present with a valid trigger policy
 func hasRequiredAnnotation(obj podController) bool {
-	annotations := obj.GetAnnotations()
-	if value, ok := annotations[RequiredAnnotation]; ok {
-		if value == requiredAnnotationValue {
+	return Managed(obj)
+}
+
+// isPaused returns true if rollouts of the given PodController have been
+// paused
+func isPaused(obj podController) bool {
+	return obj.GetAnnotations()[PausedAnnotation] == "true"
+}
+
+// InScope returns true if Wave may ever read the workload: it has the
+// RequiredAnnotation, whatever its value, or Wave's finalizer, or it is a
+// copy of a workload made for a blue/green rollout or a canary. Caches may
+// leave out every other workload.
+func InScope(obj metav1.Object) bool {
+	if _, ok := obj.GetAnnotations()[RequiredAnnotation]; ok {
+		return true
+	}
+	for _, finalizer := range obj.GetFinalizers() {
+		if finalizer == FinalizerString {
 			return true
 		}
 	}
-	return false
+	labels := obj.GetLabels()
+	_, blueGreen := labels[BlueGreenOfLabel]
+	_, canary := labels[CanaryOfLabel]
+	return blueGreen || canary
 }
@@ -60,4 +60,15 @@ var _ = Describe("Wave required annotation Suite", func() {
 		})
 
 	})
+
+	Context("isPaused", func() {
+		It("returns true when the annotation has value true", func() {
+			deploymentObject.SetAnnotations(map[string]string{PausedAnnotation: "true"})
+			Expect(isPaused(podControllerDeployment)).To(BeTrue())
+		})
+
+		It("returns false when the annotation is not set", func() {
+			Expect(isPaused(podControllerDeployment)).To(BeFalse())
+		})
+	})
 })
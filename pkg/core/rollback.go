@@ -0,0 +1,136 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// RestoredSource describes a ConfigMap or Secret restored by a rollback
+type RestoredSource struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name"`
+	Revision int    `json:"revision"`
+}
+
+// Rollback restores the data of the ConfigMaps and Secrets of the workload
+// from their snapshots of the revision, or of the revision before their
+// latest if revision is 0, and triggers a rollout of the workload with the
+// trigger value. Sources without the revision are left as they are.
+func Rollback(ctx context.Context, c client.Client, obj Object, revision int, trigger string) ([]RestoredSource, error) {
+	references, err := References(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := []RestoredSource{}
+	for _, ref := range references {
+		revisions, err := ListRevisions(ctx, c, obj.GetNamespace(), ref.Kind, ref.Name)
+		if err != nil {
+			return restored, err
+		}
+		target, ok := rollbackTarget(revisions, revision)
+		if !ok {
+			continue
+		}
+		if err := restoreRevision(ctx, c, obj.GetNamespace(), target); err != nil {
+			return restored, err
+		}
+		restored = append(restored, RestoredSource{Kind: ref.Kind, Name: ref.Name, Revision: target.Number})
+	}
+	if len(restored) == 0 {
+		if revision == 0 {
+			return restored, fmt.Errorf("no ConfigMap or Secret of %s/%s has a previous revision", WorkloadKind(obj), obj.GetName())
+		}
+		return restored, fmt.Errorf("no ConfigMap or Secret of %s/%s has revision %d", WorkloadKind(obj), obj.GetName(), revision)
+	}
+
+	annotations := obj.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[TriggerAnnotation] = trigger
+	obj.SetAnnotations(annotations)
+	if err := c.Update(ctx, obj); err != nil {
+		return restored, errors.Wrapf(err, "error triggering %s/%s", WorkloadKind(obj), obj.GetName())
+	}
+	return restored, nil
+}
+
+// rollbackTarget returns the revision to restore from the revisions, oldest
+// first
+func rollbackTarget(revisions []Revision, revision int) (Revision, bool) {
+	if revision == 0 {
+		if len(revisions) < 2 {
+			return Revision{}, false
+		}
+		return revisions[len(revisions)-2], true
+	}
+	for _, r := range revisions {
+		if r.Number == revision {
+			return r, true
+		}
+	}
+	return Revision{}, false
+}
+
+// restoreRevision copies the data of the snapshot of the revision back into
+// its ConfigMap or Secret
+func restoreRevision(ctx context.Context, c client.Client, namespace string, revision Revision) error {
+	switch revision.Kind {
+	case "ConfigMap":
+		snapshot, source := &corev1.ConfigMap{}, &corev1.ConfigMap{}
+		if err := getRevisionObjects(ctx, c, namespace, revision, snapshot, source); err != nil {
+			return err
+		}
+		source.Data, source.BinaryData = snapshot.Data, snapshot.BinaryData
+		return updateRestored(ctx, c, revision, source)
+	case "Secret":
+		snapshot, source := &corev1.Secret{}, &corev1.Secret{}
+		if err := getRevisionObjects(ctx, c, namespace, revision, snapshot, source); err != nil {
+			return err
+		}
+		source.Data = snapshot.Data
+		return updateRestored(ctx, c, revision, source)
+	}
+	return fmt.Errorf("unsupported kind %q", revision.Kind)
+}
+
+// getRevisionObjects fetches the snapshot of the revision and its source
+func getRevisionObjects(ctx context.Context, c client.Client, namespace string, revision Revision, snapshot, source Object) error {
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: revision.Snapshot}, snapshot); err != nil {
+		return errors.Wrapf(err, "error getting snapshot %s", revision.Snapshot)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: revision.Name}, source); err != nil {
+		return errors.Wrapf(err, "error getting %s %s", revision.Kind, revision.Name)
+	}
+	return nil
+}
+
+// updateRestored updates the restored ConfigMap or Secret
+func updateRestored(ctx context.Context, c client.Client, revision Revision, source Object) error {
+	if err := c.Update(ctx, source); err != nil {
+		return errors.Wrapf(err, "error restoring %s %s to revision %d", revision.Kind, revision.Name, revision.Number)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// resourceVersionKey is the key under which the resourceVersion of a Secret
+// is hashed when Wave cannot read its data
+const resourceVersionKey = "metadata.resourceVersion"
+
+// WithSecretMetadataOnly configures the Handler for clusters where Wave may
+// only list and watch the metadata of Secrets. Secrets are hashed by their
+// resourceVersion instead of their data, and Wave never adds
+// OwnerReferences to them. The Handler's Client must serve Secrets from a
+// metadata-only cache, see metadata.NewSecretCache, and controllers must
+// watch Secrets as described by Watches.
+func WithSecretMetadataOnly() Option {
+	return func(h *Handler) {
+		h.secretMetadataOnly = true
+	}
+}
+
+// SecretConsumers returns a ToRequestsFunc which maps a Secret to the
+// workloads of the given kind that have the required annotation and
+// reference the Secret, or share a hash group with a workload that does.
+// Only the workloads of the kinds, or of every kind if none are given, are
+// listed.
+func SecretConsumers(c client.Client, kind string, kinds WorkloadKinds) handler.ToRequestsFunc {
+	return func(o handler.MapObject) []reconcile.Request {
+		consumers, err := Consumers(context.TODO(), c, o.Meta.GetNamespace(), "Secret", o.Meta.GetName(), kinds)
+		if err == nil {
+			consumers, err = withGroupMembers(context.TODO(), c, o.Meta.GetNamespace(), consumers, kinds)
+		}
+		if err != nil {
+			log := logf.Log.WithName("wave")
+			log.Error(err, "Unable to list consumers of Secret", "namespace", o.Meta.GetNamespace(), "name", o.Meta.GetName())
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, obj := range consumers {
+			if WorkloadKind(obj) != kind {
+				continue
+			}
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: obj.GetName()},
+			})
+		}
+		return requests
+	}
+}
+
+// getSecretMetadata returns the data hashed for a Secret whose data Wave
+// cannot read
+func getSecretMetadata(child configObject) map[string][]byte {
+	return map[string][]byte{resourceVersionKey: []byte(child.object.GetResourceVersion())}
+}
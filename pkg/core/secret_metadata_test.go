@@ -0,0 +1,115 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Wave secret metadata Suite", func() {
+	var c client.Client
+	var h *Handler
+	var instance podController
+	var secret *corev1.Secret
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetUID(types.UID("example-uid"))
+		d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		d.Spec.Template.Spec.Volumes = nil
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+			},
+		}}
+		instance = &deployment{d}
+
+		// Secrets served by a metadata-only cache have no data
+		secret = &corev1.Secret{ObjectMeta: *utils.ExampleSecret1.ObjectMeta.DeepCopy()}
+		c = fake.NewFakeClient(d, utils.ExampleConfigMap1.DeepCopy(), secret)
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example1"}, secret)).To(Succeed())
+		h = NewHandler(c, record.NewFakeRecorder(10), WithSecretMetadataOnly())
+	})
+
+	It("hashes Secrets by resourceVersion", func() {
+		child := configObject{object: secret, allKeys: true, metadataOnly: true}
+		Expect(getSecretData(child)).To(Equal(map[string][]byte{
+			resourceVersionKey: []byte(secret.GetResourceVersion()),
+		}))
+
+		hash, err := calculateConfigHash([]configObject{child})
+		Expect(err).NotTo(HaveOccurred())
+		updated := secret.DeepCopy()
+		updated.SetResourceVersion(secret.GetResourceVersion() + "0")
+		changed, err := calculateConfigHash([]configObject{{object: updated, allKeys: true, metadataOnly: true}})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(changed).NotTo(Equal(hash))
+	})
+
+	It("marks current Secrets as metadata only", func() {
+		children, err := h.getCurrentChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(children).To(HaveLen(2))
+		for _, child := range children {
+			_, isSecret := child.object.(*corev1.Secret)
+			Expect(child.metadataOnly).To(Equal(isSecret))
+		}
+	})
+
+	It("never adds OwnerReferences to Secrets", func() {
+		current := []configObject{{object: secret, allKeys: true, metadataOnly: true}}
+		Expect(h.updateOwnerReferences(context.TODO(), instance, []Object{}, current)).To(Succeed())
+
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example1"}, secret)).To(Succeed())
+		Expect(secret.GetOwnerReferences()).To(BeEmpty())
+	})
+
+	It("doesn't treat Secrets as existing children", func() {
+		secret.SetOwnerReferences([]metav1.OwnerReference{getOwnerReference(instance)})
+		Expect(c.Update(context.TODO(), secret)).To(Succeed())
+
+		existing, err := h.getExistingChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(existing).To(BeEmpty())
+	})
+
+	It("maps Secrets to the workloads referencing them", func() {
+		mapper := SecretConsumers(c, "Deployment", nil)
+		Expect(mapper(handler.MapObject{Meta: secret, Object: secret})).To(Equal([]reconcile.Request{
+			{NamespacedName: types.NamespacedName{Namespace: "default", Name: "example"}},
+		}))
+
+		Expect(SecretConsumers(c, "StatefulSet", nil)(handler.MapObject{Meta: secret, Object: secret})).To(BeEmpty())
+
+		other := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unused"}}
+		Expect(mapper(handler.MapObject{Meta: other, Object: other})).To(BeEmpty())
+	})
+})
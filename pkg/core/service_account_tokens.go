@@ -0,0 +1,48 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WithServiceAccountTokens tracks the legacy token Secrets of ServiceAccounts
+// which workloads mount explicitly, so that rotating a token restarts the
+// Pods which only read it at startup. They are ignored by default.
+func WithServiceAccountTokens() Option {
+	return func(h *Handler) {
+		h.serviceAccountTokens = true
+	}
+}
+
+// isServiceAccountToken returns true if the Secret holds the token of a
+// ServiceAccount. The annotation naming the ServiceAccount is checked as
+// well as the type so that the metadata of the Secret suffices.
+func isServiceAccountToken(obj metav1.Object) bool {
+	if s, ok := obj.(*corev1.Secret); ok && s.Type == corev1.SecretTypeServiceAccountToken {
+		return true
+	}
+	_, ok := obj.GetAnnotations()[corev1.ServiceAccountNameKey]
+	return ok
+}
+
+// secretIgnored returns true if the Handler doesn't track the Secret because
+// it is the token of a ServiceAccount
+func (h *Handler) secretIgnored(obj metav1.Object) bool {
+	return !h.serviceAccountTokens && isServiceAccountToken(obj)
+}
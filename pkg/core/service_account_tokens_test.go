@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave ServiceAccount token Suite", func() {
+	var c client.Client
+	var recorder *record.FakeRecorder
+	var instance podController
+
+	names := func(children []configObject) []string {
+		var result []string
+		for _, child := range children {
+			result = append(result, child.object.GetName())
+		}
+		return result
+	}
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetUID(types.UID("example-uid"))
+		d.Spec.Template.Spec.Containers = []corev1.Container{{Name: "container"}}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{
+			{Name: "secret", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "example1"}}},
+			{Name: "token", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "builder-token"}}},
+			{Name: "annotated", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "deployer-token"}}},
+		}
+		instance = &deployment{d}
+
+		token := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "builder-token"},
+			Type:       corev1.SecretTypeServiceAccountToken,
+		}
+		annotated := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "deployer-token",
+			Annotations: map[string]string{corev1.ServiceAccountNameKey: "deployer"},
+		}}
+		c = fake.NewFakeClient(d, utils.ExampleSecret1.DeepCopy(), token, annotated)
+		recorder = record.NewFakeRecorder(10)
+	})
+
+	It("doesn't track ServiceAccount tokens by default", func() {
+		children, err := NewHandler(c, recorder).getCurrentChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(children)).To(ConsistOf("example1"))
+	})
+
+	It("tracks ServiceAccount tokens if enabled", func() {
+		children, err := NewHandler(c, recorder, WithServiceAccountTokens()).getCurrentChildren(context.TODO(), instance)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(names(children)).To(ConsistOf("example1", "builder-token", "deployer-token"))
+	})
+
+	It("recognises tokens by their type or annotation", func() {
+		Expect(isServiceAccountToken(&corev1.Secret{Type: corev1.SecretTypeServiceAccountToken})).To(BeTrue())
+		Expect(isServiceAccountToken(&metav1.ObjectMeta{
+			Annotations: map[string]string{corev1.ServiceAccountNameKey: "deployer"},
+		})).To(BeTrue())
+		Expect(isServiceAccountToken(&corev1.Secret{Type: corev1.SecretTypeOpaque})).To(BeFalse())
+	})
+})
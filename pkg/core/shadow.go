@@ -0,0 +1,189 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// shadowMatch is the result of a decision both instances made
+	shadowMatch = "match"
+
+	// shadowActiveOnly is the result of a rollout only the active instance
+	// triggered
+	shadowActiveOnly = "active_only"
+
+	// shadowShadowOnly is the result of a rollout only the shadow instance
+	// would have triggered
+	shadowShadowOnly = "shadow_only"
+)
+
+// shadowDecisions counts the trigger decisions of a shadow instance compared
+// against those of the active instance
+var shadowDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_shadow_decisions_total",
+	Help: "Number of rollouts triggered by the active instance or a shadow instance, by kind of workload and whether both instances triggered them",
+}, []string{"kind", "result"})
+
+func init() {
+	metrics.Registry.MustRegister(shadowDecisions)
+}
+
+// WithShadow runs the Handler as a shadow of the active Wave instance: it
+// never writes to the cluster, and instead compares the rollouts it would
+// trigger against the configuration hashes the active instance applies.
+// A rollout only one instance triggers within grace counts as a divergence.
+func WithShadow(grace time.Duration) Option {
+	return func(h *Handler) {
+		h.shadow = newShadowTracker(grace)
+		h.recorder = discardRecorder{}
+	}
+}
+
+// shadowState is what the shadow instance knows about a single workload
+type shadowState struct {
+	// active and shadow are the last hashes both instances agreed on
+	active string
+	shadow string
+
+	// since is when the instances started to disagree, zero while they agree
+	since time.Time
+}
+
+// shadowTracker pairs the hashes of the active and shadow instances for each
+// workload
+type shadowTracker struct {
+	grace time.Duration
+
+	mutex  sync.Mutex
+	states map[types.UID]*shadowState
+}
+
+// newShadowTracker constructs a shadowTracker with the given grace period
+func newShadowTracker(grace time.Duration) *shadowTracker {
+	return &shadowTracker{
+		grace:  grace,
+		states: make(map[types.UID]*shadowState),
+	}
+}
+
+// compare records the hashes of both instances for the owner. It returns the
+// result of the decision made since the last agreed hashes, if any, or the
+// time to wait before the other instance must have made the same decision.
+func (t *shadowTracker) compare(owner types.UID, active, shadow string, now time.Time) (string, time.Duration) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state, ok := t.states[owner]
+	if !ok {
+		t.states[owner] = &shadowState{active: active, shadow: shadow}
+		return "", 0
+	}
+
+	activeChanged := active != state.active
+	shadowChanged := shadow != state.shadow
+	switch {
+	case activeChanged && shadowChanged:
+		*state = shadowState{active: active, shadow: shadow}
+		return shadowMatch, 0
+	case !activeChanged && !shadowChanged:
+		state.since = time.Time{}
+		return "", 0
+	}
+
+	if state.since.IsZero() {
+		state.since = now
+	}
+	if waited := now.Sub(state.since); waited < t.grace {
+		return "", t.grace - waited
+	}
+	*state = shadowState{active: active, shadow: shadow}
+	if activeChanged {
+		return shadowActiveOnly, 0
+	}
+	return shadowShadowOnly, 0
+}
+
+// forget removes the state held about the owner
+func (t *shadowTracker) forget(owner types.UID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.states, owner)
+}
+
+// handleShadow computes the configuration hash of the instance without
+// writing anything and compares the decision against the active instance's
+func (h *Handler) handleShadow(ctx context.Context, instance podController) (reconcile.Result, error) {
+	if !hasRequiredAnnotation(instance) || toBeDeleted(instance) {
+		h.shadow.forget(instance.GetUID())
+		return reconcile.Result{}, nil
+	}
+
+	current, err := h.getCurrentChildren(ctx, instance)
+	if err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "error fetching current children")
+	}
+	hash, _, err := h.currentHash(ctx, instance, current)
+	if err != nil {
+		return reconcile.Result{}, err
+	}
+
+	active := getConfigHash(instance)
+	result, wait := h.shadow.compare(instance.GetUID(), active, hash, h.getClock().Now())
+	if result != "" {
+		shadowDecisions.WithLabelValues(kindOf(instance), result).Inc()
+	}
+	if result == shadowActiveOnly || result == shadowShadowOnly {
+		log := logf.Log.WithName("wave")
+		log.V(0).Info("Shadow decision diverged", "namespace", instance.GetNamespace(), "name", instance.GetName(), "result", result, "activeHash", active, "shadowHash", hash)
+	}
+	if poll := h.pollInterval(instance); poll > 0 && (wait == 0 || poll < wait) {
+		wait = poll
+	}
+	return reconcile.Result{RequeueAfter: wait}, nil
+}
+
+// discardRecorder drops every Event, so that a shadow instance doesn't write
+// Events to the cluster
+type discardRecorder struct{}
+
+// Event implements record.EventRecorder
+func (discardRecorder) Event(object runtime.Object, eventtype, reason, message string) {}
+
+// Eventf implements record.EventRecorder
+func (discardRecorder) Eventf(object runtime.Object, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+// PastEventf implements record.EventRecorder
+func (discardRecorder) PastEventf(object runtime.Object, timestamp metav1.Time, eventtype, reason, messageFmt string, args ...interface{}) {
+}
+
+// AnnotatedEventf implements record.EventRecorder
+func (discardRecorder) AnnotatedEventf(object runtime.Object, annotations map[string]string, eventtype, reason, messageFmt string, args ...interface{}) {
+}
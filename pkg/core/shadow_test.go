@@ -0,0 +1,169 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave shadow Suite", func() {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// decisions returns the number of decisions about Deployments with the
+	// given result
+	decisions := func(result string) float64 {
+		metric := &dto.Metric{}
+		Expect(shadowDecisions.WithLabelValues("Deployment", result).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	Context("shadowTracker", func() {
+		var t *shadowTracker
+
+		BeforeEach(func() {
+			t = newShadowTracker(time.Minute)
+			result, wait := t.compare("uid", "a1", "s1", now)
+			Expect(result).To(BeEmpty())
+			Expect(wait).To(BeZero())
+		})
+
+		It("matches decisions both instances made", func() {
+			result, _ := t.compare("uid", "a2", "s2", now)
+			Expect(result).To(Equal(shadowMatch))
+			result, _ = t.compare("uid", "a2", "s2", now)
+			Expect(result).To(BeEmpty())
+		})
+
+		It("waits for the other instance to make the same decision", func() {
+			result, wait := t.compare("uid", "a1", "s2", now)
+			Expect(result).To(BeEmpty())
+			Expect(wait).To(Equal(time.Minute))
+
+			result, wait = t.compare("uid", "a1", "s2", now.Add(20*time.Second))
+			Expect(result).To(BeEmpty())
+			Expect(wait).To(Equal(40 * time.Second))
+
+			result, _ = t.compare("uid", "a2", "s2", now.Add(30*time.Second))
+			Expect(result).To(Equal(shadowMatch))
+		})
+
+		It("reports decisions only one instance made after the grace period", func() {
+			t.compare("uid", "a1", "s2", now)
+			result, wait := t.compare("uid", "a1", "s2", now.Add(time.Minute))
+			Expect(result).To(Equal(shadowShadowOnly))
+			Expect(wait).To(BeZero())
+
+			t.compare("uid", "a2", "s2", now)
+			result, _ = t.compare("uid", "a2", "s2", now.Add(time.Minute))
+			Expect(result).To(Equal(shadowActiveOnly))
+		})
+
+		It("forgets disagreements which resolve themselves", func() {
+			t.compare("uid", "a1", "s2", now)
+			result, _ := t.compare("uid", "a1", "s1", now.Add(30*time.Second))
+			Expect(result).To(BeEmpty())
+
+			_, wait := t.compare("uid", "a1", "s2", now.Add(40*time.Second))
+			Expect(wait).To(Equal(time.Minute))
+		})
+	})
+
+	Context("handleShadow", func() {
+		var c client.Client
+		var fakeClock *clock.FakeClock
+		var active, shadow *Handler
+
+		BeforeEach(func() {
+			d := utils.ExampleDeployment.DeepCopy()
+			d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+			d.Spec.Template.Spec.Volumes = nil
+			d.Spec.Template.Spec.Containers = []corev1.Container{{
+				Name: "container",
+				EnvFrom: []corev1.EnvFromSource{
+					{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+				},
+			}}
+			c = fake.NewFakeClient(d, utils.ExampleConfigMap1.DeepCopy())
+			fakeClock = clock.NewFakeClock(now)
+			active = NewHandler(c, record.NewFakeRecorder(100))
+			shadow = NewHandler(c, record.NewFakeRecorder(100), WithClock(fakeClock), WithShadow(time.Minute))
+		})
+
+		reconcile := func(h *Handler) time.Duration {
+			d := &appsv1.Deployment{}
+			key := types.NamespacedName{Namespace: "default", Name: utils.ExampleDeployment.GetName()}
+			Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+			result, err := h.HandleDeployment(context.TODO(), d)
+			Expect(err).NotTo(HaveOccurred())
+
+			if h == shadow {
+				updated := &appsv1.Deployment{}
+				Expect(c.Get(context.TODO(), key, updated)).To(Succeed())
+				Expect(updated.GetResourceVersion()).To(Equal(d.GetResourceVersion()))
+			}
+			return result.RequeueAfter
+		}
+
+		updateConfigMap := func(value string) {
+			cm := &corev1.ConfigMap{}
+			key := types.NamespacedName{Namespace: "default", Name: utils.ExampleConfigMap1.GetName()}
+			Expect(c.Get(context.TODO(), key, cm)).To(Succeed())
+			cm.Data = map[string]string{"key1": value}
+			Expect(c.Update(context.TODO(), cm)).To(Succeed())
+		}
+
+		It("never writes to the cluster", func() {
+			reconcile(shadow)
+			d := &appsv1.Deployment{}
+			key := types.NamespacedName{Namespace: "default", Name: utils.ExampleDeployment.GetName()}
+			Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+			Expect(d.GetFinalizers()).To(BeEmpty())
+			Expect(getConfigHash(&deployment{d})).To(BeEmpty())
+		})
+
+		It("counts the decisions of both instances", func() {
+			matched, shadowOnly := decisions(shadowMatch), decisions(shadowShadowOnly)
+			reconcile(active)
+			reconcile(shadow)
+
+			updateConfigMap("changed")
+			Expect(reconcile(shadow)).To(Equal(time.Minute))
+			reconcile(active)
+			Expect(reconcile(shadow)).To(BeZero())
+			Expect(decisions(shadowMatch)).To(Equal(matched + 1))
+
+			updateConfigMap("changed again")
+			Expect(reconcile(shadow)).To(Equal(time.Minute))
+			fakeClock.Step(time.Minute)
+			Expect(reconcile(shadow)).To(BeZero())
+			Expect(decisions(shadowShadowOnly)).To(Equal(shadowOnly + 1))
+		})
+	})
+})
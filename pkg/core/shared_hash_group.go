@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// sharedHashGroup returns the shared hash group of the instance, if any
+func sharedHashGroup(obj Object) string {
+	return obj.GetAnnotations()[SharedHashGroupAnnotation]
+}
+
+// groupMembers returns the other tracked workloads in the shared hash group
+// of the instance
+func (h *Handler) groupMembers(ctx context.Context, instance podController) ([]podController, error) {
+	group := sharedHashGroup(instance)
+	if group == "" {
+		return nil, nil
+	}
+	workloads, err := h.ListWorkloads(ctx, instance.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	var members []podController
+	for _, obj := range workloads {
+		member, err := asPodController(obj)
+		if err != nil || kindOf(member) == kindOf(instance) && member.GetName() == instance.GetName() {
+			continue
+		}
+		if sharedHashGroup(member) == group && hasRequiredAnnotation(member) && !toBeDeleted(member) {
+			members = append(members, member)
+		}
+	}
+	return members, nil
+}
+
+// withGroupMembers adds the tracked workloads sharing a hash group with any
+// of the consumers to them
+func withGroupMembers(ctx context.Context, c client.Client, namespace string, consumers []Object, opts ...client.ListOption) ([]Object, error) {
+	groups := make(map[string]struct{})
+	for _, obj := range consumers {
+		if group := sharedHashGroup(obj); group != "" {
+			groups[group] = struct{}{}
+		}
+	}
+	if len(groups) == 0 {
+		return consumers, nil
+	}
+
+	workloads, err := ListWorkloads(ctx, c, namespace, opts...)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[sourceKey]struct{})
+	for _, obj := range consumers {
+		seen[sourceKeyOf(obj)] = struct{}{}
+	}
+	for _, obj := range workloads {
+		instance, err := asPodController(obj)
+		if err != nil || !hasRequiredAnnotation(instance) {
+			continue
+		}
+		if _, ok := groups[sharedHashGroup(obj)]; !ok {
+			continue
+		}
+		if _, ok := seen[sourceKeyOf(obj)]; !ok {
+			seen[sourceKeyOf(obj)] = struct{}{}
+			consumers = append(consumers, obj)
+		}
+	}
+	return consumers, nil
+}
+
+// addGroupChildren adds the children of the other workloads in the shared
+// hash group of the instance to its own. Each child appears once, merging
+// the keys referenced by every workload. Owning every child of the group
+// makes a change to any of them reconcile every workload of the group.
+func (h *Handler) addGroupChildren(ctx context.Context, instance podController, children []configObject) ([]configObject, error) {
+	members, err := h.groupMembers(ctx, instance)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing shared hash group %s", sharedHashGroup(instance))
+	}
+	if len(members) == 0 {
+		return children, nil
+	}
+
+	for _, member := range members {
+		memberChildren, err := h.getReferencedChildren(ctx, member, false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "error fetching children of %s %s in shared hash group %s", kindOf(member), member.GetName(), sharedHashGroup(instance))
+		}
+		children = append(children, memberChildren...)
+	}
+	return canonicalChildren(children), nil
+}
+
+// mergeChildren merges the references two workloads make to the same child
+func mergeChildren(a, b configObject) configObject {
+	merged := a
+	metadata := mergeMetadata(
+		configMetadata{required: a.required, allKeys: a.allKeys, keys: a.keys, envFrom: a.envFrom},
+		configMetadata{required: b.required, allKeys: b.allKeys, keys: b.keys, envFrom: b.envFrom},
+	)
+	merged.required = metadata.required
+	merged.allKeys = metadata.allKeys
+	merged.keys = metadata.keys
+	merged.envFrom = metadata.envFrom
+	return merged
+}
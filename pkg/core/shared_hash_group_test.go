@@ -0,0 +1,117 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave shared hash group Suite", func() {
+	var c client.Client
+	var h *Handler
+
+	newConfigMap := func(name, value string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+			Data:       map[string]string{"key": value},
+		}
+	}
+
+	newDeployment := func(name, group, configMap string) *appsv1.Deployment {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			UID:         types.UID(name),
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		if group != "" {
+			d.Annotations[SharedHashGroupAnnotation] = group
+		}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: configMap},
+				},
+			}},
+		}}
+		return d
+	}
+
+	// hashOf reconciles the Deployment and returns its configuration hash
+	hashOf := func(name string) string {
+		key := types.NamespacedName{Namespace: "default", Name: name}
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+
+		d = &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		return getConfigHash(&deployment{d})
+	}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(
+			newConfigMap("cart", "a"),
+			newConfigMap("payment", "b"),
+			newDeployment("cart", "checkout", "cart"),
+			newDeployment("payment", "checkout", "payment"),
+			newDeployment("standalone", "", "cart"),
+		)
+		h = NewHandler(c, record.NewFakeRecorder(100))
+	})
+
+	It("gives every workload of a group the same hash", func() {
+		cart := hashOf("cart")
+		Expect(cart).NotTo(BeEmpty())
+		Expect(hashOf("payment")).To(Equal(cart))
+		Expect(hashOf("standalone")).NotTo(Equal(cart))
+	})
+
+	It("owns the children of every workload of the group", func() {
+		hashOf("cart")
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "payment"}, cm)).To(Succeed())
+		Expect(cm.GetOwnerReferences()).To(ContainElement(WithTransform(func(ref metav1.OwnerReference) string {
+			return ref.Name
+		}, Equal("cart"))))
+	})
+
+	It("changes the hash of every workload of the group when a child changes", func() {
+		cart, payment := hashOf("cart"), hashOf("payment")
+
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "payment"}, cm)).To(Succeed())
+		cm.Data["key"] = "changed"
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+
+		Expect(hashOf("cart")).NotTo(Equal(cart))
+		Expect(hashOf("payment")).NotTo(Equal(payment))
+		Expect(hashOf("cart")).To(Equal(hashOf("payment")))
+	})
+})
@@ -0,0 +1,157 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"path"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var (
+	// DefaultSidecarContainers are the names of the containers injected by
+	// common service meshes and secret agents
+	DefaultSidecarContainers = []string{"istio-proxy", "istio-validation", "envoy", "envoy-sidecar", "vault-agent"}
+
+	// DefaultSidecarConfigMaps are the names of the ConfigMaps that service
+	// mesh control planes manage in every namespace
+	DefaultSidecarConfigMaps = []string{"istio-ca-root-cert", "istio", "istio-sidecar-injector"}
+)
+
+// SidecarOptions describes the injected sidecars whose configuration is
+// managed by a control plane rather than by the workload's owners
+type SidecarOptions struct {
+	// Containers are glob patterns matched against the names of sidecar
+	// containers. ConfigMaps and Secrets referenced only by sidecar
+	// containers are not tracked.
+	Containers []string
+
+	// ConfigMaps are glob patterns matched against the names of ConfigMaps
+	// which are never tracked, whichever container references them
+	ConfigMaps []string
+}
+
+// NewSidecarOptions validates the glob patterns of sidecar containers and
+// ConfigMaps
+func NewSidecarOptions(containers, configMaps []string) (SidecarOptions, error) {
+	for _, pattern := range append(append([]string{}, containers...), configMaps...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return SidecarOptions{}, errors.Wrapf(err, "invalid sidecar name pattern %q", pattern)
+		}
+	}
+	return SidecarOptions{Containers: containers, ConfigMaps: configMaps}, nil
+}
+
+// WithSidecars configures the Handler to ignore the configuration of the
+// sidecars. Workloads setting the OnlyTrackAnnotation or the
+// TrackContainersAnnotation choose their sources explicitly and are not
+// affected.
+func WithSidecars(opts SidecarOptions) Option {
+	return func(h *Handler) {
+		if len(opts.Containers) > 0 || len(opts.ConfigMaps) > 0 {
+			h.sidecars = &opts
+		}
+	}
+}
+
+// matchesAny returns true if the name matches one of the glob patterns
+func matchesAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// appliesSidecars returns true if the sidecar options apply to the instance
+func (h *Handler) appliesSidecars(obj podController) bool {
+	if h.sidecars == nil {
+		return false
+	}
+	annotations := obj.GetAnnotations()
+	if _, ok := annotations[OnlyTrackAnnotation]; ok {
+		return false
+	}
+	_, ok := annotations[TrackContainersAnnotation]
+	return !ok
+}
+
+// withoutSidecars returns a copy of the instance without its sidecar
+// containers and the volumes only they mount, or the instance itself when it
+// has no sidecars
+func (h *Handler) withoutSidecars(obj podController) podController {
+	if !h.appliesSidecars(obj) || len(h.sidecars.Containers) == 0 {
+		return obj
+	}
+	spec := obj.GetPodTemplate().Spec
+	var containers []corev1.Container
+	mounted := make(map[string]struct{})
+	for _, container := range spec.Containers {
+		if matchesAny(h.sidecars.Containers, container.Name) {
+			continue
+		}
+		containers = append(containers, container)
+		for _, mount := range container.VolumeMounts {
+			mounted[mount.Name] = struct{}{}
+		}
+	}
+	if len(containers) == len(spec.Containers) {
+		return obj
+	}
+
+	var volumes []corev1.Volume
+	for _, vol := range spec.Volumes {
+		if _, ok := mounted[vol.Name]; ok || !mountedBySidecar(spec, vol.Name, h.sidecars.Containers) {
+			volumes = append(volumes, vol)
+		}
+	}
+	trimmed := obj.DeepCopy()
+	template := trimmed.GetPodTemplate()
+	template.Spec.Containers = containers
+	template.Spec.Volumes = volumes
+	return trimmed
+}
+
+// mountedBySidecar returns true if a sidecar container mounts the volume
+func mountedBySidecar(spec corev1.PodSpec, volume string, patterns []string) bool {
+	for _, container := range spec.Containers {
+		if !matchesAny(patterns, container.Name) {
+			continue
+		}
+		for _, mount := range container.VolumeMounts {
+			if mount.Name == volume {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// removeSidecarConfigMaps removes the ConfigMaps managed by a control plane
+// from the ConfigMaps referenced by the instance
+func (h *Handler) removeSidecarConfigMaps(obj podController, configMaps map[string]configMetadata) {
+	if !h.appliesSidecars(obj) {
+		return
+	}
+	for name := range configMaps {
+		if matchesAny(h.sidecars.ConfigMaps, name) {
+			delete(configMaps, name)
+		}
+	}
+}
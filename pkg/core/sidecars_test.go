@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave sidecars Suite", func() {
+	var c client.Client
+	var d *appsv1.Deployment
+
+	configMap := func(name string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name}}
+	}
+
+	envFrom := func(name string) []corev1.EnvFromSource {
+		return []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}}}}
+	}
+
+	volume := func(name string) corev1.Volume {
+		return corev1.Volume{Name: name, VolumeSource: corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: name}},
+		}}
+	}
+
+	// children returns the names of the children tracked with the options
+	children := func(opts SidecarOptions) []string {
+		h := NewHandler(c, record.NewFakeRecorder(10), WithSidecars(opts))
+		children, err := h.getCurrentChildren(context.TODO(), &deployment{d})
+		Expect(err).NotTo(HaveOccurred())
+		var names []string
+		for _, child := range children {
+			names = append(names, child.object.GetName())
+		}
+		return names
+	}
+
+	defaults := func() SidecarOptions {
+		opts, err := NewSidecarOptions(DefaultSidecarContainers, DefaultSidecarConfigMaps)
+		Expect(err).NotTo(HaveOccurred())
+		return opts
+	}
+
+	BeforeEach(func() {
+		d = utils.ExampleDeployment.DeepCopy()
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{volume("app-files"), volume("istio-envoy"), volume("shared"), volume("istio-ca-root-cert")}
+		d.Spec.Template.Spec.Containers = []corev1.Container{
+			{
+				Name:         "app",
+				EnvFrom:      envFrom("app"),
+				VolumeMounts: []corev1.VolumeMount{{Name: "app-files"}, {Name: "shared"}, {Name: "istio-ca-root-cert"}},
+			},
+			{
+				Name:         "istio-proxy",
+				EnvFrom:      envFrom("mesh-env"),
+				VolumeMounts: []corev1.VolumeMount{{Name: "istio-envoy"}, {Name: "shared"}},
+			},
+		}
+		c = fake.NewFakeClient(d, configMap("app"), configMap("app-files"), configMap("shared"),
+			configMap("mesh-env"), configMap("istio-envoy"), configMap("istio-ca-root-cert"))
+	})
+
+	It("rejects invalid patterns", func() {
+		_, err := NewSidecarOptions([]string{"["}, nil)
+		Expect(err).To(HaveOccurred())
+		_, err = NewSidecarOptions(nil, []string{"["})
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("is disabled when empty", func() {
+		h := NewHandler(c, record.NewFakeRecorder(10), WithSidecars(SidecarOptions{}))
+		Expect(h.sidecars).To(BeNil())
+		Expect(children(SidecarOptions{})).To(ConsistOf("app", "app-files", "shared", "mesh-env", "istio-envoy", "istio-ca-root-cert"))
+	})
+
+	It("ignores the configuration of sidecar containers and control planes", func() {
+		Expect(children(defaults())).To(ConsistOf("app", "app-files", "shared"))
+	})
+
+	It("keeps volumes mounted by the workload's containers too", func() {
+		Expect(children(SidecarOptions{Containers: []string{"istio-*"}})).To(ContainElement("shared"))
+	})
+
+	It("does not modify the workload", func() {
+		children(defaults())
+		Expect(d.Spec.Template.Spec.Containers).To(HaveLen(2))
+		Expect(d.Spec.Template.Spec.Volumes).To(HaveLen(4))
+	})
+
+	It("does not apply to workloads choosing their sources", func() {
+		d.SetAnnotations(map[string]string{TrackContainersAnnotation: "app,istio-proxy"})
+		Expect(children(defaults())).To(ConsistOf("app", "app-files", "shared", "mesh-env", "istio-envoy", "istio-ca-root-cert"))
+
+		d.SetAnnotations(map[string]string{OnlyTrackAnnotation: "configmap/istio-ca-root-cert"})
+		Expect(children(defaults())).To(ConsistOf("istio-ca-root-cert"))
+	})
+})
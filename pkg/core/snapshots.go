@@ -0,0 +1,272 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// SnapshotLabel is the key of the label on the copies of ConfigMaps and
+	// Secrets Wave snapshots when it triggers a rollout
+	SnapshotLabel = "wave.pusher.com/snapshot"
+
+	// SnapshotOfAnnotation is the key of the annotation on a snapshot naming
+	// the ConfigMap or Secret it is a copy of
+	SnapshotOfAnnotation = "wave.pusher.com/snapshot-of"
+
+	// SnapshotRevisionAnnotation is the key of the annotation on a snapshot
+	// holding its revision, counting from 1 for each ConfigMap or Secret
+	SnapshotRevisionAnnotation = "wave.pusher.com/snapshot-revision"
+
+	// SnapshotWorkloadAnnotation is the key of the annotation on a snapshot
+	// naming, as "<kind>/<name>", the workload whose rollout it was taken for
+	SnapshotWorkloadAnnotation = "wave.pusher.com/snapshot-workload"
+
+	// snapshotDigestAnnotation holds a digest of the data of a snapshot, so
+	// that unchanged data is not snapshotted again
+	snapshotDigestAnnotation = "wave.pusher.com/snapshot-digest"
+
+	// maxNameLength is the maximum length of the name of a ConfigMap or
+	// Secret
+	maxNameLength = 253
+)
+
+// Revision describes a snapshot of a ConfigMap or Secret
+type Revision struct {
+	// Kind and Name identify the ConfigMap or Secret
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+
+	// Number counts the revisions of the ConfigMap or Secret from 1
+	Number int `json:"number"`
+
+	// Snapshot is the name of the copy holding the data of the revision
+	Snapshot string `json:"snapshot"`
+
+	// Workload is the workload, as "<kind>/<name>", whose rollout the
+	// snapshot was taken for
+	Workload string `json:"workload"`
+
+	Created time.Time `json:"created"`
+}
+
+// WithConfigSnapshots configures the Handler to snapshot the data of the
+// ConfigMaps and Secrets of a workload into copies when it triggers a
+// rollout, keeping the latest revisions of each
+func WithConfigSnapshots(revisions int) Option {
+	return func(h *Handler) {
+		h.snapshotRevisions = revisions
+	}
+}
+
+// ListRevisions returns the revisions of the ConfigMap or Secret of the kind
+// and name, oldest first
+func ListRevisions(ctx context.Context, c client.Client, namespace, kind, name string) ([]Revision, error) {
+	snapshots, err := listSnapshots(ctx, c, namespace, kind, name)
+	if err != nil {
+		return nil, err
+	}
+	revisions := []Revision{}
+	for _, snapshot := range snapshots {
+		revisions = append(revisions, revisionOf(kind, snapshot))
+	}
+	return revisions, nil
+}
+
+// snapshotSources snapshots the children of the instance whose data changed
+// since their latest snapshot. Failures are reported on the instance without
+// failing its rollout.
+// +kubebuilder:rbac:groups=,resources=configmaps;secrets,verbs=create;delete
+func (h *Handler) snapshotSources(ctx context.Context, instance podController, children []configObject) {
+	if h.snapshotRevisions <= 0 {
+		return
+	}
+	for _, child := range children {
+		if child.metadataOnly {
+			continue
+		}
+		if err := h.snapshot(ctx, instance, child.object); err != nil {
+			log := logf.Log.WithName("wave")
+			log.Error(err, "Unable to snapshot source", "namespace", instance.GetNamespace(), "name", instance.GetName(), "source", sourceKeyOf(child.object).String())
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "SnapshotFailed", "Unable to snapshot %s: %v", sourceKeyOf(child.object), err)
+		}
+	}
+}
+
+// snapshot copies the data of the source into a new revision, unless it is
+// the data of its latest revision, and deletes the revisions beyond those
+// retained
+func (h *Handler) snapshot(ctx context.Context, instance podController, source Object) error {
+	kind := kindOf(source)
+	snapshots, err := listSnapshots(ctx, h.Client, source.GetNamespace(), kind, source.GetName())
+	if err != nil {
+		return err
+	}
+	digest, err := snapshotDigest(source)
+	if err != nil {
+		return err
+	}
+
+	number := 1
+	if len(snapshots) > 0 {
+		latest := snapshots[len(snapshots)-1]
+		if latest.GetAnnotations()[snapshotDigestAnnotation] == digest {
+			return nil
+		}
+		number = revisionOf(kind, latest).Number + 1
+	}
+
+	snap := newSnapshot(source, number, digest, fmt.Sprintf("%s/%s", kindOf(instance), instance.GetName()))
+	target := audit.Object{Namespace: snap.GetNamespace(), Kind: kind, Name: snap.GetName()}
+	if err := h.createObject(ctx, snap, target, instance, audit.SnapshotCreated); err != nil && !apierrors.IsAlreadyExists(err) {
+		return errors.Wrap(err, "error creating snapshot")
+	}
+	snapshots = append(snapshots, snap)
+
+	for i := 0; i < len(snapshots)-h.snapshotRevisions; i++ {
+		target := audit.Object{Namespace: snapshots[i].GetNamespace(), Kind: kind, Name: snapshots[i].GetName()}
+		if err := h.deleteObject(ctx, snapshots[i], target, instance, audit.SnapshotDeleted); err != nil && !apierrors.IsNotFound(err) {
+			return errors.Wrapf(err, "error deleting snapshot %s", snapshots[i].GetName())
+		}
+	}
+	return nil
+}
+
+// newSnapshot returns a copy of the data of the source as its revision
+func newSnapshot(source Object, number int, digest, workload string) Object {
+	suffix := fmt.Sprintf("-rev-%d", number)
+	name := source.GetName()
+	if len(name)+len(suffix) > maxNameLength {
+		name = name[:maxNameLength-len(suffix)]
+	}
+	meta := metav1.ObjectMeta{
+		Namespace: source.GetNamespace(),
+		Name:      name + suffix,
+		Labels:    map[string]string{SnapshotLabel: "true"},
+		Annotations: map[string]string{
+			SnapshotOfAnnotation:       source.GetName(),
+			SnapshotRevisionAnnotation: strconv.Itoa(number),
+			SnapshotWorkloadAnnotation: workload,
+			snapshotDigestAnnotation:   digest,
+		},
+		// Snapshots are garbage collected with their source
+		OwnerReferences: []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       kindOf(source),
+			Name:       source.GetName(),
+			UID:        source.GetUID(),
+		}},
+	}
+	switch s := source.(type) {
+	case *corev1.ConfigMap:
+		return &corev1.ConfigMap{ObjectMeta: meta, Data: s.DeepCopy().Data, BinaryData: s.DeepCopy().BinaryData}
+	case *corev1.Secret:
+		return &corev1.Secret{ObjectMeta: meta, Type: s.Type, Data: s.DeepCopy().Data}
+	}
+	return nil
+}
+
+// listSnapshots returns the snapshots of the ConfigMap or Secret of the kind
+// and name, oldest first
+func listSnapshots(ctx context.Context, c client.Client, namespace, kind, name string) ([]Object, error) {
+	var list runtime.Object
+	var items func() []Object
+	switch kind {
+	case "ConfigMap":
+		l := &corev1.ConfigMapList{}
+		list, items = l, func() []Object {
+			objs := []Object{}
+			for i := range l.Items {
+				objs = append(objs, &l.Items[i])
+			}
+			return objs
+		}
+	case "Secret":
+		l := &corev1.SecretList{}
+		list, items = l, func() []Object {
+			objs := []Object{}
+			for i := range l.Items {
+				objs = append(objs, &l.Items[i])
+			}
+			return objs
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %q", kind)
+	}
+	if err := c.List(ctx, list, client.InNamespace(namespace), client.MatchingLabels{SnapshotLabel: "true"}); err != nil {
+		return nil, errors.Wrap(err, "error listing snapshots")
+	}
+
+	snapshots := []Object{}
+	for _, obj := range items() {
+		if obj.GetAnnotations()[SnapshotOfAnnotation] == name {
+			snapshots = append(snapshots, obj)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return revisionOf(kind, snapshots[i]).Number < revisionOf(kind, snapshots[j]).Number
+	})
+	return snapshots, nil
+}
+
+// revisionOf describes the snapshot
+func revisionOf(kind string, snapshot Object) Revision {
+	number, _ := strconv.Atoi(snapshot.GetAnnotations()[SnapshotRevisionAnnotation])
+	return Revision{
+		Kind:     kind,
+		Name:     snapshot.GetAnnotations()[SnapshotOfAnnotation],
+		Number:   number,
+		Snapshot: snapshot.GetName(),
+		Workload: snapshot.GetAnnotations()[SnapshotWorkloadAnnotation],
+		Created:  snapshot.GetCreationTimestamp().Time,
+	}
+}
+
+// snapshotDigest returns a digest of the data of the ConfigMap or Secret,
+// salted for Secrets like the hashes of their values
+func snapshotDigest(source Object) (string, error) {
+	switch s := source.(type) {
+	case *corev1.ConfigMap:
+		data, err := json.Marshal([]interface{}{s.Data, s.BinaryData})
+		if err != nil {
+			return "", err
+		}
+		return shortValueHash(data), nil
+	case *corev1.Secret:
+		data, err := json.Marshal([]interface{}{s.Type, s.Data})
+		if err != nil {
+			return "", err
+		}
+		return secretValueHash(s, data), nil
+	}
+	return "", fmt.Errorf("unsupported kind %q", kindOf(source))
+}
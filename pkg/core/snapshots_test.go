@@ -0,0 +1,120 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave config snapshots Suite", func() {
+	var c client.Client
+	var h *Handler
+
+	handle := func() {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	setData := func(value string) {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "config"}, cm)).To(Succeed())
+		cm.Data = map[string]string{"key": value}
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+	}
+
+	snapshot := func(name string) *corev1.ConfigMap {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, cm)).To(Succeed())
+		return cm
+	}
+
+	revisions := func() []int {
+		revisions, err := ListRevisions(context.TODO(), c, "default", "ConfigMap", "config")
+		Expect(err).NotTo(HaveOccurred())
+		numbers := []int{}
+		for _, revision := range revisions {
+			numbers = append(numbers, revision.Number)
+		}
+		return numbers
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			UID:         types.UID("example"),
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}},
+			}},
+		}}
+		c = fake.NewFakeClient(d, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config", UID: types.UID("config")},
+			Data:       map[string]string{"key": "1"},
+		})
+		h = NewHandler(c, record.NewFakeRecorder(100), WithConfigSnapshots(2))
+	})
+
+	It("snapshots the data of sources when it triggers a rollout", func() {
+		handle()
+		Expect(revisions()).To(Equal([]int{1}))
+		rev := snapshot("config-rev-1")
+		Expect(rev.Data).To(Equal(map[string]string{"key": "1"}))
+		Expect(rev.GetLabels()).To(HaveKeyWithValue(SnapshotLabel, "true"))
+		Expect(rev.GetAnnotations()).To(HaveKeyWithValue(SnapshotWorkloadAnnotation, "Deployment/example"))
+		Expect(rev.GetOwnerReferences()).To(HaveLen(1))
+		Expect(rev.GetOwnerReferences()[0].UID).To(Equal(types.UID("config")))
+
+		// Unchanged data is not snapshotted again
+		handle()
+		Expect(revisions()).To(Equal([]int{1}))
+
+		setData("2")
+		handle()
+		Expect(revisions()).To(Equal([]int{1, 2}))
+		Expect(snapshot("config-rev-2").Data).To(Equal(map[string]string{"key": "2"}))
+	})
+
+	It("keeps only the latest revisions", func() {
+		for _, value := range []string{"1", "2", "3"} {
+			setData(value)
+			handle()
+		}
+		Expect(revisions()).To(Equal([]int{2, 3}))
+	})
+
+	It("does not snapshot when disabled", func() {
+		h = NewHandler(c, record.NewFakeRecorder(100))
+		handle()
+		Expect(revisions()).To(BeEmpty())
+	})
+})
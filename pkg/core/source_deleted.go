@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// sourceDeletedRestart rolls the workload out when a tracked child is
+	// deleted, as if it were optional
+	sourceDeletedRestart = "restart"
+
+	// sourceDeletedWarn reports the deletion of a tracked child and blocks
+	// the workload's rollouts until it is recreated
+	sourceDeletedWarn = "warn"
+)
+
+// sourceDeletedPolicy returns the value of the SourceDeletedAnnotation if it
+// is valid, or the empty string
+func sourceDeletedPolicy(obj podController) string {
+	switch policy := obj.GetAnnotations()[SourceDeletedAnnotation]; policy {
+	case sourceDeletedRestart, sourceDeletedWarn:
+		return policy
+	default:
+		return ""
+	}
+}
+
+// deletedSources returns the missing children which contributed to the
+// configuration hash applied to the instance, and have therefore been
+// deleted since, sorted by kind and name
+func deletedSources(obj podController, missing []sourceKey) []sourceKey {
+	applied, ok := getSourceHashes(obj)
+	if !ok {
+		return nil
+	}
+	var deleted []sourceKey
+	for _, key := range missing {
+		if _, ok := applied[key.String()]; ok {
+			deleted = append(deleted, key)
+		}
+	}
+	sort.Slice(deleted, func(i, j int) bool {
+		return deleted[i].String() < deleted[j].String()
+	})
+	return deleted
+}
+
+// reportDeletedSources emits a warning event on the instance naming the
+// deleted children and what Wave does about them
+func (h *Handler) reportDeletedSources(obj podController, policy string, deleted []sourceKey) {
+	names := []string{}
+	for _, key := range deleted {
+		names = append(names, key.String())
+	}
+	action := "rolling out without them"
+	if policy == sourceDeletedWarn {
+		action = "blocking rollouts until they are recreated"
+	}
+	h.recorder.Eventf(obj.GetObject(), corev1.EventTypeWarning, "SourceDeleted", "Tracked sources %s were deleted, %s", strings.Join(names, ", "), action)
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave source deletion Suite", func() {
+	var c client.Client
+	var recorder *record.FakeRecorder
+	var h *Handler
+	var hash string
+
+	key := types.NamespacedName{Namespace: "default", Name: "example"}
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		d.SetUID(types.UID("example-uid"))
+		d.SetAnnotations(map[string]string{RequiredAnnotation: "true"})
+		d.Spec.Template.Spec.Volumes = nil
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			EnvFrom: []corev1.EnvFromSource{
+				{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+				{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example1"}}},
+			},
+		}}
+		c = fake.NewFakeClient(d, utils.ExampleConfigMap1.DeepCopy(), utils.ExampleSecret1.DeepCopy())
+		recorder = record.NewFakeRecorder(10)
+		h = NewHandler(c, recorder)
+
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		hash = getConfigHash(&deployment{d})
+		Expect(hash).NotTo(BeEmpty())
+		for len(recorder.Events) > 0 {
+			<-recorder.Events
+		}
+	})
+
+	// deleteConfigMap deletes the ConfigMap and reconciles the Deployment with
+	// the given policy
+	deleteConfigMap := func(policy string) (*appsv1.Deployment, error) {
+		Expect(c.Delete(context.TODO(), utils.ExampleConfigMap1.DeepCopy())).To(Succeed())
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		if policy != "" {
+			d.Annotations[SourceDeletedAnnotation] = policy
+			Expect(c.Update(context.TODO(), d)).To(Succeed())
+		}
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		return d, err
+	}
+
+	It("retries without a policy", func() {
+		d, err := deleteConfigMap("")
+		Expect(err).To(HaveOccurred())
+		Expect(getConfigHash(&deployment{d})).To(Equal(hash))
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning NotFound ")))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+
+	It("rolls out without the deleted source with the restart policy", func() {
+		d, err := deleteConfigMap("restart")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(getConfigHash(&deployment{d})).NotTo(Equal(hash))
+		Expect(recorder.Events).To(Receive(Equal("Warning SourceDeleted Tracked sources ConfigMap/example1 were deleted, rolling out without them")))
+
+		applied, ok := getSourceHashes(&deployment{d})
+		Expect(ok).To(BeTrue())
+		Expect(applied).NotTo(HaveKey("ConfigMap/example1"))
+	})
+
+	It("blocks rollouts and warns with the warn policy", func() {
+		d, err := deleteConfigMap("warn")
+		Expect(err).To(HaveOccurred())
+		Expect(getConfigHash(&deployment{d})).To(Equal(hash))
+		Expect(recorder.Events).To(Receive(Equal("Warning SourceDeleted Tracked sources ConfigMap/example1 were deleted, blocking rollouts until they are recreated")))
+	})
+
+	It("ignores sources which were never applied", func() {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), key, d)).To(Succeed())
+		d.Annotations[SourceDeletedAnnotation] = "restart"
+		d.Spec.Template.Spec.Containers[0].EnvFrom = append(d.Spec.Template.Spec.Containers[0].EnvFrom, corev1.EnvFromSource{
+			ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "missing"}},
+		})
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		_, err := h.HandleDeployment(context.TODO(), d)
+		Expect(err).To(HaveOccurred())
+		Expect(recorder.Events).To(Receive(HavePrefix("Warning NotFound ")))
+		Expect(recorder.Events).NotTo(Receive())
+	})
+})
@@ -0,0 +1,145 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	sourceAdded    = "added"
+	sourceRemoved  = "removed"
+	sourceModified = "modified"
+)
+
+// sourceKey identifies a child by its kind and name
+type sourceKey struct {
+	kind string
+	name string
+}
+
+// String formats the sourceKey as "<kind>/<name>"
+func (k sourceKey) String() string {
+	return fmt.Sprintf("%s/%s", k.kind, k.name)
+}
+
+// sourceChange describes how a child has changed since it was last recorded
+type sourceChange struct {
+	sourceKey
+	change string
+}
+
+// sourceTracker remembers the ResourceVersions of the children used to
+// calculate each instance's hash so that the children responsible for a
+// change in the hash can be reported
+type sourceTracker struct {
+	mutex    sync.Mutex
+	versions map[types.UID]map[sourceKey]string
+	recency  recency
+}
+
+// newSourceTracker constructs an empty sourceTracker
+func newSourceTracker() *sourceTracker {
+	return &sourceTracker{versions: make(map[types.UID]map[sourceKey]string), recency: newRecency()}
+}
+
+// diff returns the children that were added, removed or modified since the
+// last time the owner's children were recorded
+func (t *sourceTracker) diff(owner types.UID, children []configObject) []sourceChange {
+	current := sourceVersions(children)
+
+	t.mutex.Lock()
+	previous, ok := t.versions[owner]
+	if ok {
+		t.recency.touch(owner)
+	}
+	t.mutex.Unlock()
+
+	changes := []sourceChange{}
+	for key, version := range current {
+		previousVersion, ok := previous[key]
+		if !ok {
+			changes = append(changes, sourceChange{sourceKey: key, change: sourceAdded})
+		} else if previousVersion != version {
+			changes = append(changes, sourceChange{sourceKey: key, change: sourceModified})
+		}
+	}
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			changes = append(changes, sourceChange{sourceKey: key, change: sourceRemoved})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].String() < changes[j].String()
+	})
+	return changes
+}
+
+// record stores the ResourceVersions of the children for the owner
+func (t *sourceTracker) record(owner types.UID, children []configObject) {
+	current := sourceVersions(children)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.versions[owner] = current
+	t.recency.touch(owner)
+}
+
+// forget removes any record of the owner's children
+func (t *sourceTracker) forget(owner types.UID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.versions, owner)
+	t.recency.forget(owner)
+}
+
+// sourceVersions maps each child to its ResourceVersion
+func sourceVersions(children []configObject) map[sourceKey]string {
+	versions := make(map[sourceKey]string)
+	for _, child := range children {
+		versions[sourceKeyOf(child.object)] = child.object.GetResourceVersion()
+	}
+	return versions
+}
+
+// sourceKeyOf returns the sourceKey identifying the object
+func sourceKeyOf(obj Object) sourceKey {
+	return sourceKey{kind: kindOf(obj), name: obj.GetName()}
+}
+
+// sourceNames returns the names of the changed children formatted as
+// "<kind>/<name>"
+func sourceNames(changes []sourceChange) []string {
+	names := []string{}
+	for _, change := range changes {
+		names = append(names, change.String())
+	}
+	return names
+}
+
+// summarizeChanges returns a short description of the number of children
+// added, removed and modified
+func summarizeChanges(changes []sourceChange) string {
+	counts := make(map[string]int)
+	for _, change := range changes {
+		counts[change.change]++
+	}
+	return fmt.Sprintf("%d added, %d removed, %d modified", counts[sourceAdded], counts[sourceRemoved], counts[sourceModified])
+}
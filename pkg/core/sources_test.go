@@ -0,0 +1,83 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Wave sources Suite", func() {
+	var tracker *sourceTracker
+	var cm1 *corev1.ConfigMap
+	var s1 *corev1.Secret
+	var owner = types.UID("owner")
+
+	BeforeEach(func() {
+		tracker = newSourceTracker()
+		cm1 = utils.ExampleConfigMap1.DeepCopy()
+		cm1.SetResourceVersion("1")
+		s1 = utils.ExampleSecret1.DeepCopy()
+		s1.SetResourceVersion("1")
+	})
+
+	Context("diff", func() {
+		It("reports all children as added when the owner has not been recorded", func() {
+			changes := tracker.diff(owner, []configObject{{object: cm1}, {object: s1}})
+			Expect(sourceNames(changes)).To(Equal([]string{"ConfigMap/example1", "Secret/example1"}))
+			Expect(summarizeChanges(changes)).To(Equal("2 added, 0 removed, 0 modified"))
+		})
+
+		It("reports nothing when the children have not changed", func() {
+			tracker.record(owner, []configObject{{object: cm1}, {object: s1}})
+			changes := tracker.diff(owner, []configObject{{object: cm1}, {object: s1}})
+			Expect(changes).To(BeEmpty())
+		})
+
+		It("reports children whose ResourceVersion changed as modified", func() {
+			tracker.record(owner, []configObject{{object: cm1}, {object: s1}})
+			s1.SetResourceVersion("2")
+			changes := tracker.diff(owner, []configObject{{object: cm1}, {object: s1}})
+			Expect(changes).To(Equal([]sourceChange{
+				{sourceKey: sourceKey{kind: "Secret", name: "example1"}, change: sourceModified},
+			}))
+		})
+
+		It("reports children that were removed", func() {
+			tracker.record(owner, []configObject{{object: cm1}, {object: s1}})
+			changes := tracker.diff(owner, []configObject{{object: s1}})
+			Expect(changes).To(Equal([]sourceChange{
+				{sourceKey: sourceKey{kind: "ConfigMap", name: "example1"}, change: sourceRemoved},
+			}))
+		})
+
+		It("does not record the children", func() {
+			tracker.diff(owner, []configObject{{object: cm1}})
+			Expect(tracker.diff(owner, []configObject{{object: cm1}})).To(HaveLen(1))
+		})
+
+		It("reports all children again after the owner is forgotten", func() {
+			tracker.record(owner, []configObject{{object: cm1}})
+			tracker.forget(owner)
+			changes := tracker.diff(owner, []configObject{{object: cm1}})
+			Expect(sourceNames(changes)).To(Equal([]string{"ConfigMap/example1"}))
+		})
+	})
+})
@@ -0,0 +1,103 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// defaultSurge and defaultUnavailable are the maxSurge and maxUnavailable the
+// API server defaults the RollingUpdate strategy of Deployments to
+var (
+	defaultSurge       = intstr.FromString("25%")
+	defaultUnavailable = intstr.FromString("25%")
+)
+
+// estimateRollout estimates how long the rollout of the workload takes, from
+// the number of Pods its update strategy replaces at once and the time each
+// of them takes to become available. DaemonSets replace no more Pods at once
+// than Wave caps them to. Workloads using the OnDelete strategy aren't
+// estimated.
+func (h *Handler) estimateRollout(obj podController) time.Duration {
+	pods, batch, minReady := rolloutShape(obj)
+	if ds, ok := obj.GetObject().(*appsv1.DaemonSet); ok {
+		if limit, ok := h.daemonSetCap(ds); ok && limit < batch {
+			batch = limit
+		}
+	}
+	if pods <= 0 || batch <= 0 || h.gate == nil {
+		return 0
+	}
+	batches := (pods + batch - 1) / batch
+	return time.Duration(batches) * (h.gate.options.PodStartupTime + time.Duration(minReady)*time.Second)
+}
+
+// rolloutShape returns the number of Pods the rollout of the workload
+// replaces, how many of them are replaced at once and the minReadySeconds
+// each of them must be ready for
+func rolloutShape(obj podController) (int, int, int32) {
+	switch o := obj.GetObject().(type) {
+	case *appsv1.Deployment:
+		replicas := 1
+		if o.Spec.Replicas != nil {
+			replicas = int(*o.Spec.Replicas)
+		}
+		if o.Spec.Strategy.Type == appsv1.RecreateDeploymentStrategyType {
+			return replicas, replicas, o.Spec.MinReadySeconds
+		}
+		surge, unavailable := &defaultSurge, &defaultUnavailable
+		if rolling := o.Spec.Strategy.RollingUpdate; rolling != nil {
+			if rolling.MaxSurge != nil {
+				surge = rolling.MaxSurge
+			}
+			if rolling.MaxUnavailable != nil {
+				unavailable = rolling.MaxUnavailable
+			}
+		}
+		// Resolved like the Deployment controller does, which replaces at
+		// least one Pod at a time
+		s, _ := intstr.GetValueFromIntOrPercent(surge, replicas, true)
+		u, _ := intstr.GetValueFromIntOrPercent(unavailable, replicas, false)
+		if s+u < 1 {
+			u = 1
+		}
+		return replicas, s + u, o.Spec.MinReadySeconds
+	case *appsv1.StatefulSet:
+		if o.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+			return 0, 0, 0
+		}
+		// StatefulSets replace their Pods one at a time
+		return desiredPods(obj), 1, 0
+	case *appsv1.DaemonSet:
+		if o.Spec.UpdateStrategy.Type == appsv1.OnDeleteDaemonSetStrategyType {
+			return 0, 0, 0
+		}
+		pods := int(o.Status.DesiredNumberScheduled)
+		u := 1
+		if max := maxUnavailableOf(o); max != nil {
+			u, _ = intstr.GetValueFromIntOrPercent(max, pods, true)
+		}
+		if u < 1 {
+			u = 1
+		}
+		return pods, u, o.Spec.MinReadySeconds
+	}
+	return 0, 0, 0
+}
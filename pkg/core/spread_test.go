@@ -0,0 +1,112 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+var _ = Describe("Wave rollout spread Suite", func() {
+	var h *Handler
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	newDeployment := func(uid string, replicas int32, minReady int32) *deployment {
+		return &deployment{&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{UID: types.UID(uid)},
+			Spec: appsv1.DeploymentSpec{
+				Replicas:        &replicas,
+				MinReadySeconds: minReady,
+			},
+		}}
+	}
+
+	BeforeEach(func() {
+		h = NewHandler(nil, nil, WithCapacityOptions(CapacityOptions{
+			SpreadInterval: 30 * time.Second,
+			PodStartupTime: 20 * time.Second,
+		}))
+	})
+
+	It("estimates Deployments by their surge, unavailability and minReadySeconds", func() {
+		// The default 25% surge and unavailability replace 3 + 2 Pods at once
+		Expect(h.estimateRollout(newDeployment("first", 10, 10))).To(Equal(2 * 30 * time.Second))
+
+		d := newDeployment("first", 10, 0)
+		surge, unavailable := intstr.FromInt(1), intstr.FromInt(0)
+		d.Spec.Strategy.RollingUpdate = &appsv1.RollingUpdateDeployment{MaxSurge: &surge, MaxUnavailable: &unavailable}
+		Expect(h.estimateRollout(d)).To(Equal(10 * 20 * time.Second))
+
+		d.Spec.Strategy = appsv1.DeploymentStrategy{Type: appsv1.RecreateDeploymentStrategyType}
+		Expect(h.estimateRollout(d)).To(Equal(20 * time.Second))
+	})
+
+	It("estimates StatefulSets one Pod at a time", func() {
+		replicas := int32(3)
+		s := &statefulset{&appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: &replicas}}}
+		Expect(h.estimateRollout(s)).To(Equal(3 * 20 * time.Second))
+
+		s.Spec.UpdateStrategy.Type = appsv1.OnDeleteStatefulSetStrategyType
+		Expect(h.estimateRollout(s)).To(BeZero())
+	})
+
+	It("estimates DaemonSets by their capped maxUnavailable", func() {
+		ten := intstr.FromString("10%")
+		ds := &daemonset{&appsv1.DaemonSet{
+			Spec: appsv1.DaemonSetSpec{
+				MinReadySeconds: 10,
+				UpdateStrategy: appsv1.DaemonSetUpdateStrategy{
+					Type:          appsv1.RollingUpdateDaemonSetStrategyType,
+					RollingUpdate: &appsv1.RollingUpdateDaemonSet{MaxUnavailable: &ten},
+				},
+			},
+			Status: appsv1.DaemonSetStatus{DesiredNumberScheduled: 40},
+		}}
+		Expect(h.estimateRollout(ds)).To(Equal(10 * 30 * time.Second))
+
+		WithDaemonSetMaxUnavailable(DaemonSetOptions{MaxUnavailable: intstr.FromInt(2)})(h)
+		Expect(h.estimateRollout(ds)).To(Equal(20 * 30 * time.Second))
+	})
+
+	It("spreads rollouts by their estimate when it is longer than the interval", func() {
+		large, small, other := newDeployment("large", 10, 10), newDeployment("small", 1, 0), newDeployment("other", 1, 0)
+
+		wait, _, err := h.gate.admit(context.TODO(), large, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(BeZero())
+
+		wait, _, err = h.gate.admit(context.TODO(), small, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(Equal(time.Minute))
+
+		wait, _, err = h.gate.admit(context.TODO(), other, now)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(wait).To(Equal(time.Minute + 30*time.Second))
+	})
+
+	It("doesn't estimate rollouts without a Pod startup time", func() {
+		h = NewHandler(nil, nil, WithCapacityOptions(CapacityOptions{SpreadInterval: 30 * time.Second}))
+		Expect(h.gate.spacing(newDeployment("first", 10, 10))).To(Equal(30 * time.Second))
+	})
+})
@@ -0,0 +1,154 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// TrackedSource is a ConfigMap or Secret recorded the last time Wave updated
+// a workload's configuration hash
+type TrackedSource struct {
+	Kind            string `json:"kind"`
+	Name            string `json:"name"`
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+// TrackedState is the state Wave holds in memory about a workload
+type TrackedState struct {
+	UID           types.UID       `json:"uid"`
+	Sources       []TrackedSource `json:"sources,omitempty"`
+	DeniedHash    string          `json:"deniedHash,omitempty"`
+	ReservedUntil *time.Time      `json:"reservedUntil,omitempty"`
+}
+
+// Registry collects the Handlers of each controller so that the state they
+// hold in memory can be exported
+type Registry struct {
+	mutex    sync.Mutex
+	handlers []*Handler
+}
+
+// NewRegistry constructs an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// WithRegistry adds the Handler to the Registry
+func WithRegistry(r *Registry) Option {
+	return func(h *Handler) {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.handlers = append(r.handlers, h)
+	}
+}
+
+// Tracked returns the state held by every registered Handler, sorted by UID
+func (r *Registry) Tracked() []TrackedState {
+	r.mutex.Lock()
+	handlers := append([]*Handler{}, r.handlers...)
+	r.mutex.Unlock()
+
+	states := []TrackedState{}
+	for _, h := range handlers {
+		states = append(states, h.tracked()...)
+	}
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].UID < states[j].UID
+	})
+	return states
+}
+
+// tracked returns the state the Handler holds about each workload
+func (h *Handler) tracked() []TrackedState {
+	states := make(map[types.UID]*TrackedState)
+	stateOf := func(uid types.UID) *TrackedState {
+		if _, ok := states[uid]; !ok {
+			states[uid] = &TrackedState{UID: uid}
+		}
+		return states[uid]
+	}
+
+	for uid, sources := range h.sources.snapshot() {
+		stateOf(uid).Sources = sources
+	}
+	if h.policy != nil {
+		for uid, hash := range h.policy.snapshot() {
+			stateOf(uid).DeniedHash = hash
+		}
+	}
+	if h.gate != nil {
+		for uid, start := range h.gate.snapshot() {
+			start := start
+			stateOf(uid).ReservedUntil = &start
+		}
+	}
+
+	result := []TrackedState{}
+	for _, state := range states {
+		result = append(result, *state)
+	}
+	return result
+}
+
+// snapshot returns the sources recorded for each owner, sorted by kind and
+// name
+func (t *sourceTracker) snapshot() map[types.UID][]TrackedSource {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	result := make(map[types.UID][]TrackedSource)
+	for owner, versions := range t.versions {
+		sources := []TrackedSource{}
+		for key, version := range versions {
+			sources = append(sources, TrackedSource{Kind: key.kind, Name: key.name, ResourceVersion: version})
+		}
+		sort.Slice(sources, func(i, j int) bool {
+			if sources[i].Kind != sources[j].Kind {
+				return sources[i].Kind < sources[j].Kind
+			}
+			return sources[i].Name < sources[j].Name
+		})
+		result[owner] = sources
+	}
+	return result
+}
+
+// snapshot returns the hash denied for each owner
+func (p *policyHook) snapshot() map[types.UID]string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	result := make(map[types.UID]string)
+	for owner, hash := range p.denied {
+		result[owner] = hash
+	}
+	return result
+}
+
+// snapshot returns the start time reserved for each owner
+func (g *rolloutGate) snapshot() map[types.UID]time.Time {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	result := make(map[types.UID]time.Time)
+	for owner, start := range g.reservations {
+		result[owner] = start
+	}
+	return result
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Wave state Suite", func() {
+	var registry *Registry
+	var deployments, statefulsets *Handler
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+		deployments = NewHandler(nil, record.NewFakeRecorder(10), WithRegistry(registry),
+			WithCapacityOptions(CapacityOptions{SpreadInterval: time.Minute}))
+		statefulsets = NewHandler(nil, record.NewFakeRecorder(10), WithRegistry(registry))
+	})
+
+	It("returns no state before anything is tracked", func() {
+		Expect(registry.Tracked()).To(BeEmpty())
+	})
+
+	It("merges the state of every registered Handler", func() {
+		cm := utils.ExampleConfigMap1.DeepCopy()
+		cm.SetResourceVersion("7")
+		s := utils.ExampleSecret1.DeepCopy()
+		s.SetResourceVersion("3")
+		deployments.sources.record(types.UID("b"), []configObject{{object: s}, {object: cm}})
+		statefulsets.sources.record(types.UID("a"), []configObject{{object: cm}})
+
+		start := time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+		deployments.gate.reservations[types.UID("b")] = start
+
+		Expect(registry.Tracked()).To(Equal([]TrackedState{
+			{
+				UID:     types.UID("a"),
+				Sources: []TrackedSource{{Kind: "ConfigMap", Name: "example1", ResourceVersion: "7"}},
+			},
+			{
+				UID: types.UID("b"),
+				Sources: []TrackedSource{
+					{Kind: "ConfigMap", Name: "example1", ResourceVersion: "7"},
+					{Kind: "Secret", Name: "example1", ResourceVersion: "3"},
+				},
+				ReservedUntil: &start,
+			},
+		}))
+	})
+})
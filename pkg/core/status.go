@@ -0,0 +1,95 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StatusAnnotation is the key of the annotation summarizing Wave's view of
+// a workload, written by Handlers constructed with WithStatusAnnotation
+const StatusAnnotation = "wave.pusher.com/status"
+
+// WithStatusAnnotation records Wave's view of each workload in its
+// StatusAnnotation, so that it can be checked with kubectl. The annotation
+// only changes when its contents do, and so doesn't cause extra updates.
+func WithStatusAnnotation() Option {
+	return func(h *Handler) {
+		h.statusAnnotation = true
+	}
+}
+
+// WorkloadStatus is recorded in the StatusAnnotation of a workload
+type WorkloadStatus struct {
+	// Tracked is the number of ConfigMaps and Secrets the workload references
+	Tracked int `json:"tracked"`
+
+	// LastTrigger is the time Wave last rolled out the workload, if it did
+	LastTrigger *metav1.Time `json:"lastTrigger,omitempty"`
+
+	// Pending is true while the workload's configuration changed but the
+	// rollout is held back
+	Pending bool `json:"pending"`
+}
+
+// StatusOf returns the status recorded on the workload, if any
+func StatusOf(obj metav1.Object) (WorkloadStatus, bool) {
+	value, ok := obj.GetAnnotations()[StatusAnnotation]
+	if !ok {
+		return WorkloadStatus{}, false
+	}
+	status := WorkloadStatus{}
+	if err := json.Unmarshal([]byte(value), &status); err != nil {
+		return WorkloadStatus{}, false
+	}
+	return status, true
+}
+
+// syncStatus records the status of the instance on the desired state.
+// triggered is true if the desired state rolls out the hash, which Wave
+// calculated from the current children.
+func (h *Handler) syncStatus(instance, desired podController, current []configObject, hash string, triggered bool) error {
+	if !h.statusAnnotation {
+		return nil
+	}
+
+	previous, _ := StatusOf(instance)
+	status := WorkloadStatus{
+		Tracked:     len(current),
+		LastTrigger: previous.LastTrigger,
+		Pending:     !triggered && getConfigHash(instance) != hash,
+	}
+	if triggered {
+		now := metav1.NewTime(h.getClock().Now().UTC())
+		status.LastTrigger = &now
+	}
+
+	value, err := json.Marshal(status)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal JSON")
+	}
+	annotations := desired.GetAnnotations()
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations[StatusAnnotation] = string(value)
+	desired.SetAnnotations(annotations)
+	return nil
+}
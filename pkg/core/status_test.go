@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave status annotation Suite", func() {
+	var c client.Client
+	var h *Handler
+	var fakeClock *clock.FakeClock
+	now := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	status := func() WorkloadStatus {
+		s, ok := StatusOf(getDeployment())
+		Expect(ok).To(BeTrue())
+		return s
+	}
+
+	updateConfigMap := func() {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "config"}, cm)).To(Succeed())
+		cm.Data = map[string]string{"key": "changed"}
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		cm := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "config"},
+			Data:       map[string]string{"key": "value"},
+		}
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "example",
+				Annotations: map[string]string{RequiredAnnotation: "true"},
+			},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{
+					Name:    "container",
+					EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "config"}}}},
+				}},
+			}}},
+		}
+		c = fake.NewFakeClient(cm, d)
+		fakeClock = clock.NewFakeClock(now)
+		h = NewHandler(c, record.NewFakeRecorder(100), WithClock(fakeClock), WithStatusAnnotation())
+	})
+
+	It("records the tracked children and the time of the rollout", func() {
+		handle()
+		Expect(status().Tracked).To(Equal(1))
+		Expect(status().LastTrigger.Time.Equal(now)).To(BeTrue())
+		Expect(status().Pending).To(BeFalse())
+	})
+
+	It("doesn't update the workload while its status is unchanged", func() {
+		handle()
+		version := getDeployment().ResourceVersion
+
+		fakeClock.Step(time.Hour)
+		handle()
+		Expect(getDeployment().ResourceVersion).To(Equal(version))
+	})
+
+	It("updates the time of the rollout when the configuration changes", func() {
+		handle()
+		fakeClock.Step(time.Hour)
+		updateConfigMap()
+		handle()
+		Expect(status().LastTrigger.Time.Equal(now.Add(time.Hour))).To(BeTrue())
+	})
+
+	It("marks held back rollouts as pending", func() {
+		handle()
+		d := getDeployment()
+		d.Annotations[PausedAnnotation] = "true"
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		updateConfigMap()
+		handle()
+		Expect(status().Pending).To(BeTrue())
+		Expect(status().LastTrigger.Time.Equal(now)).To(BeTrue())
+
+		d = getDeployment()
+		delete(d.Annotations, PausedAnnotation)
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		handle()
+		Expect(status().Pending).To(BeFalse())
+	})
+
+	It("isn't written by default", func() {
+		h = NewHandler(c, record.NewFakeRecorder(100), WithClock(fakeClock))
+		handle()
+		Expect(getDeployment().Annotations).NotTo(HaveKey(StatusAnnotation))
+	})
+})
@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// suspendedUntil returns the time until which the child is suspended, if
+// its SuspendUntilAnnotation is set
+func suspendedUntil(child configObject) (time.Time, bool, error) {
+	if child.object == nil {
+		return time.Time{}, false, nil
+	}
+	value, ok := child.object.GetAnnotations()[SuspendUntilAnnotation]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, errors.Wrapf(err, "invalid %s on %s %s", SuspendUntilAnnotation, kindOf(child.object), child.object.GetName())
+	}
+	return until, true, nil
+}
+
+// checkSuspended checks whether any child of the instance is suspended and
+// holds back its rollout until the last suspension ends. Invalid suspensions
+// are reported and ignored.
+func (h *Handler) checkSuspended(instance podController, children []configObject, hash string, changes []sourceChange) (bool, time.Duration) {
+	now := h.getClock().Now()
+	var suspended []string
+	var wait time.Duration
+	for _, child := range children {
+		until, ok, err := suspendedUntil(child)
+		if err != nil {
+			h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "InvalidSuspension", "Ignoring suspension: %v", err)
+			continue
+		}
+		if !ok || !until.After(now) {
+			continue
+		}
+		suspended = append(suspended, fmt.Sprintf("%s/%s", kindOf(child.object), child.object.GetName()))
+		if until.Sub(now) > wait {
+			wait = until.Sub(now)
+		}
+	}
+	if len(suspended) == 0 {
+		return false, 0
+	}
+
+	reason := fmt.Sprintf("%s suspended for %s", strings.Join(suspended, ", "), wait)
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Holding back rollout of suspended sources", "namespace", instance.GetNamespace(), "name", instance.GetName(), "hash", hash, "wait", wait.String())
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutHeld", "Rollout of configuration hash %s held back: %s", shortHash(hash), reason)
+	h.recordDecision(audit.Deferred, instance, hash, changes, reason)
+	return true, wait
+}
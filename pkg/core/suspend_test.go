@@ -0,0 +1,130 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave source suspension Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	// events returns the events recorded so far
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	hash := func() string {
+		return getConfigHash(&deployment{getDeployment()})
+	}
+
+	// editConfig changes the data and annotations of the ConfigMap the
+	// Deployment uses
+	editConfig := func(value string, annotations map[string]string) {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, cm)).To(Succeed())
+		cm.Data["key"] = value
+		cm.Annotations = annotations
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example"}},
+			}},
+		}}
+		c = fake.NewFakeClient(d, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data:       map[string]string{"key": "value"},
+		})
+		recorder = record.NewFakeRecorder(100)
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC))
+		h = NewHandler(c, recorder, WithClock(fakeClock))
+		handle()
+	})
+
+	It("holds back rollouts until the suspension ends", func() {
+		initial := hash()
+		editConfig("first", map[string]string{SuspendUntilAnnotation: "2019-01-01T12:30:00Z"})
+		Expect(handle()).To(Equal(30 * time.Minute))
+		editConfig("second", map[string]string{SuspendUntilAnnotation: "2019-01-01T12:30:00Z"})
+		Expect(handle()).To(Equal(30 * time.Minute))
+		Expect(hash()).To(Equal(initial))
+		Expect(events()).To(ContainElement(And(ContainSubstring("RolloutHeld"), ContainSubstring("ConfigMap/example suspended for 30m0s"))))
+
+		fakeClock.SetTime(time.Date(2019, 1, 1, 12, 30, 0, 0, time.UTC))
+		handle()
+		Expect(hash()).NotTo(Equal(initial))
+	})
+
+	It("rolls out once the suspension is removed", func() {
+		initial := hash()
+		editConfig("first", map[string]string{SuspendUntilAnnotation: "2019-01-01T12:30:00Z"})
+		handle()
+		Expect(hash()).To(Equal(initial))
+
+		editConfig("first", nil)
+		handle()
+		Expect(hash()).NotTo(Equal(initial))
+	})
+
+	It("ignores invalid suspensions", func() {
+		initial := hash()
+		editConfig("first", map[string]string{SuspendUntilAnnotation: "tomorrow"})
+		handle()
+		Expect(hash()).NotTo(Equal(initial))
+		Expect(events()).To(ContainElement(ContainSubstring("InvalidSuspension")))
+	})
+})
@@ -0,0 +1,156 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	hashModified = "modified"
+	hashRemoved  = "removed"
+)
+
+// hashTampered counts the configuration hash annotations modified or
+// removed by something other than Wave
+var hashTampered = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_config_hash_tampered_total",
+	Help: "Number of times something other than Wave modified or removed the configuration hash annotation of a workload, by kind of workload and change",
+}, []string{"kind", "change"})
+
+func init() {
+	metrics.Registry.MustRegister(hashTampered)
+}
+
+// hashTracker remembers the configuration hash annotation each instance's
+// PodTemplate had after Wave last reconciled or wrote it, so that changes
+// made to it by others can be told apart from Wave's own
+type hashTracker struct {
+	mutex   sync.Mutex
+	hashes  map[types.UID]string
+	recency recency
+}
+
+// newHashTracker constructs an empty hashTracker
+func newHashTracker() *hashTracker {
+	return &hashTracker{hashes: make(map[types.UID]string), recency: newRecency()}
+}
+
+// record remembers the configuration hash annotation of the instance
+func (t *hashTracker) record(instance podController) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	path, _ := HashPathOf(instance)
+	t.hashes[instance.GetUID()] = templateHash(instance.GetPodTemplate(), path)
+	t.recency.touch(instance.GetUID())
+}
+
+// get returns the configuration hash annotation last recorded for the owner
+func (t *hashTracker) get(owner types.UID) (string, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	hash, ok := t.hashes[owner]
+	if ok {
+		t.recency.touch(owner)
+	}
+	return hash, ok
+}
+
+// forget removes the hash recorded for the owner
+func (t *hashTracker) forget(owner types.UID) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delete(t.hashes, owner)
+	t.recency.forget(owner)
+}
+
+// checkTampered reports a configuration hash annotation which was modified
+// or removed since Wave last reconciled the instance, naming the field
+// managers responsible. The annotation is then restored by the reconcile
+// like any other out of date hash.
+func (h *Handler) checkTampered(instance podController) {
+	expected, ok := h.hashes.get(instance.GetUID())
+	if !ok {
+		return
+	}
+	path, _ := HashPathOf(instance)
+	actual := templateHash(instance.GetPodTemplate(), path)
+	if actual == expected {
+		return
+	}
+	change := hashModified
+	if actual == "" {
+		change = hashRemoved
+	}
+	managers := hashManagers(instance, change)
+	hashTampered.WithLabelValues(kindOf(instance), change).Inc()
+	logf.Log.WithName("wave").V(0).Info("Configuration hash changed outside of Wave", "namespace", instance.GetNamespace(), "name", instance.GetName(), "change", change, "managers", managers)
+	h.recorder.Eventf(instance.GetObject(), corev1.EventTypeWarning, "ConfigHashTampered", "The %s annotation was %s by %s, restoring it", ConfigHashAnnotation, change, strings.Join(managers, ", "))
+	h.hashes.forget(instance.GetUID())
+}
+
+// configHashPath is the path of the configuration hash annotation in the
+// managedFields of a workload
+var configHashPath = []string{"f:spec", "f:template", "f:metadata", "f:annotations", "f:" + ConfigHashAnnotation}
+
+// hashManagers returns the field managers, other than Wave, which own the
+// modified configuration hash annotation. When the annotation was removed, or
+// no manager owns it, the manager which last updated the instance is
+// returned instead.
+func hashManagers(instance metav1.Object, change string) []string {
+	var managers []string
+	var latest *metav1.ManagedFieldsEntry
+	entries := instance.GetManagedFields()
+	for i, entry := range entries {
+		if entry.Manager == FieldManager {
+			continue
+		}
+		if change == hashModified && entry.Fields != nil && ownsPath(*entry.Fields, configHashPath) {
+			managers = append(managers, entry.Manager)
+		}
+		if entry.Operation == metav1.ManagedFieldsOperationUpdate && entry.Time != nil && (latest == nil || latest.Time.Before(entry.Time)) {
+			latest = &entries[i]
+		}
+	}
+	if len(managers) == 0 && latest != nil {
+		managers = append(managers, latest.Manager)
+	}
+	if len(managers) == 0 {
+		managers = append(managers, "an unknown manager")
+	}
+	return managers
+}
+
+// ownsPath returns true if the set of fields contains the path
+func ownsPath(fields metav1.Fields, path []string) bool {
+	for _, element := range path {
+		next, ok := fields.Map[element]
+		if !ok {
+			return false
+		}
+		fields = next
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave hash tampering Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() {
+		_, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+	}
+
+	// events returns the events recorded so far
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	tampered := func(change string) float64 {
+		metric := &dto.Metric{}
+		Expect(hashTampered.WithLabelValues("Deployment", change).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	// ownedHash returns the fields of a manager owning the hash annotation
+	ownedHash := func() *metav1.Fields {
+		fields := metav1.Fields{Map: map[string]metav1.Fields{}}
+		for i := len(configHashPath) - 1; i >= 0; i-- {
+			fields = metav1.Fields{Map: map[string]metav1.Fields{configHashPath[i]: fields}}
+		}
+		return &fields
+	}
+
+	// edit sets the hash annotation, removing it if empty, and the managed
+	// fields of the Deployment
+	edit := func(hash string, managedFields ...metav1.ManagedFieldsEntry) {
+		d := getDeployment()
+		if hash == "" {
+			delete(d.Spec.Template.Annotations, ConfigHashAnnotation)
+		} else {
+			d.Spec.Template.Annotations[ConfigHashAnnotation] = hash
+		}
+		d.SetManagedFields(managedFields)
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: map[string]string{RequiredAnnotation: "true"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example"}},
+			}},
+		}}
+		c = fake.NewFakeClient(d, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data:       map[string]string{"key": "value"},
+		})
+		recorder = record.NewFakeRecorder(100)
+		h = NewHandler(c, recorder)
+		handle()
+		events()
+	})
+
+	It("reports and restores a modified hash", func() {
+		hash := getConfigHash(&deployment{getDeployment()})
+		before := tampered(hashModified)
+		edit("modified",
+			metav1.ManagedFieldsEntry{Manager: FieldManager, Operation: metav1.ManagedFieldsOperationUpdate, Fields: ownedHash()},
+			metav1.ManagedFieldsEntry{Manager: "sync-bot", Operation: metav1.ManagedFieldsOperationUpdate, Fields: ownedHash()},
+		)
+
+		handle()
+		Expect(events()).To(ContainElement(And(ContainSubstring("ConfigHashTampered"), ContainSubstring("modified by sync-bot"))))
+		Expect(tampered(hashModified)).To(Equal(before + 1))
+		Expect(getConfigHash(&deployment{getDeployment()})).To(Equal(hash))
+
+		handle()
+		Expect(events()).NotTo(ContainElement(ContainSubstring("ConfigHashTampered")))
+	})
+
+	It("names the manager which last updated a workload whose hash was removed", func() {
+		before := tampered(hashRemoved)
+		earlier := metav1.NewTime(time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC))
+		later := metav1.NewTime(time.Date(2019, 1, 2, 0, 0, 0, 0, time.UTC))
+		edit("",
+			metav1.ManagedFieldsEntry{Manager: "kubectl", Operation: metav1.ManagedFieldsOperationUpdate, Time: &earlier},
+			metav1.ManagedFieldsEntry{Manager: "helm", Operation: metav1.ManagedFieldsOperationUpdate, Time: &later},
+		)
+
+		handle()
+		Expect(events()).To(ContainElement(ContainSubstring("removed by helm")))
+		Expect(tampered(hashRemoved)).To(Equal(before + 1))
+		Expect(getConfigHash(&deployment{getDeployment()})).NotTo(BeEmpty())
+	})
+
+	It("does not report hashes updated by Wave", func() {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, cm)).To(Succeed())
+		cm.Data["key"] = "changed"
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+
+		handle()
+		handle()
+		Expect(events()).NotTo(ContainElement(ContainSubstring("ConfigHashTampered")))
+	})
+
+	It("does not report workloads it has not reconciled since starting", func() {
+		edit("modified")
+		h = NewHandler(c, recorder)
+		handle()
+		Expect(events()).NotTo(ContainElement(ContainSubstring("ConfigHashTampered")))
+	})
+})
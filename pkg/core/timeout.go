@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// reconcileDeadlineExceeded counts the reconciles which ran out of time
+var reconcileDeadlineExceeded = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_reconcile_deadline_exceeded_total",
+	Help: "Number of reconciles which exceeded the reconcile timeout, by kind of workload",
+}, []string{"kind"})
+
+func init() {
+	metrics.Registry.MustRegister(reconcileDeadlineExceeded)
+}
+
+// WithReconcileTimeout limits the time each reconcile may take, including
+// every API call it makes. Zero disables the limit.
+func WithReconcileTimeout(timeout time.Duration) Option {
+	return func(h *Handler) {
+		h.reconcileTimeout = timeout
+	}
+}
+
+// ReconcileContext returns the context a controller should pass to the
+// Handler for a single reconcile. It carries the reconcile timeout, if any.
+func (h *Handler) ReconcileContext() (context.Context, context.CancelFunc) {
+	return h.reconcileContext(context.Background())
+}
+
+// reconcileContext derives the context of a single reconcile from parent
+func (h *Handler) reconcileContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if h.reconcileTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+	return context.WithTimeout(parent, h.reconcileTimeout)
+}
+
+// observeDeadline counts the reconcile of the instance if it ran out of time
+func observeDeadline(ctx context.Context, instance podController) {
+	if ctx.Err() == context.DeadlineExceeded {
+		reconcileDeadlineExceeded.WithLabelValues(kindOf(instance)).Inc()
+	}
+}
@@ -0,0 +1,70 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/client-go/tools/record"
+)
+
+var _ = Describe("Wave reconcile timeout Suite", func() {
+	// deadlineHits returns the number of reconciles of Deployments which
+	// exceeded the timeout
+	deadlineHits := func() float64 {
+		metric := &dto.Metric{}
+		Expect(reconcileDeadlineExceeded.WithLabelValues("Deployment").Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	It("has no deadline without a timeout", func() {
+		ctx, cancel := NewHandler(nil, record.NewFakeRecorder(10)).ReconcileContext()
+		defer cancel()
+		_, ok := ctx.Deadline()
+		Expect(ok).To(BeFalse())
+	})
+
+	It("sets the deadline to the timeout", func() {
+		ctx, cancel := NewHandler(nil, record.NewFakeRecorder(10), WithReconcileTimeout(time.Minute)).ReconcileContext()
+		defer cancel()
+		deadline, ok := ctx.Deadline()
+		Expect(ok).To(BeTrue())
+		Expect(deadline).To(BeTemporally("~", time.Now().Add(time.Minute), time.Second))
+	})
+
+	It("counts reconciles which exceed the deadline", func() {
+		h := NewHandler(nil, record.NewFakeRecorder(10), WithReconcileTimeout(time.Nanosecond))
+		ctx, cancel := h.ReconcileContext()
+		defer cancel()
+		<-ctx.Done()
+
+		before := deadlineHits()
+		// Without the required annotation the Deployment is ignored
+		_, err := h.HandleDeployment(ctx, utils.ExampleDeployment.DeepCopy())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deadlineHits()).To(Equal(before + 1))
+
+		_, err = h.HandleDeployment(context.Background(), utils.ExampleDeployment.DeepCopy())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(deadlineHits()).To(Equal(before + 1))
+	})
+})
@@ -0,0 +1,79 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// trackedContainers returns the containers of the instance whose references
+// Wave tracks: those named in the TrackContainersAnnotation, or every
+// container when it is not set. It also returns the names listed in the
+// annotation that match no container.
+func trackedContainers(obj podController) ([]corev1.Container, []string) {
+	containers := obj.GetPodTemplate().Spec.Containers
+	value, ok := obj.GetAnnotations()[TrackContainersAnnotation]
+	if !ok {
+		return containers, nil
+	}
+
+	names := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names[name] = false
+		}
+	}
+	var tracked []corev1.Container
+	for _, container := range containers {
+		if _, ok := names[container.Name]; ok {
+			names[container.Name] = true
+			tracked = append(tracked, container)
+		}
+	}
+	var unknown []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" && !names[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	return tracked, unknown
+}
+
+// trackedVolumes returns the volumes of the instance mounted by the tracked
+// containers, or every volume when the TrackContainersAnnotation is not set
+func trackedVolumes(obj podController, containers []corev1.Container) []corev1.Volume {
+	volumes := obj.GetPodTemplate().Spec.Volumes
+	if _, ok := obj.GetAnnotations()[TrackContainersAnnotation]; !ok {
+		return volumes
+	}
+
+	mounted := make(map[string]struct{})
+	for _, container := range containers {
+		for _, mount := range container.VolumeMounts {
+			mounted[mount.Name] = struct{}{}
+		}
+	}
+	var tracked []corev1.Volume
+	for _, vol := range volumes {
+		if _, ok := mounted[vol.Name]; ok {
+			tracked = append(tracked, vol)
+		}
+	}
+	return tracked
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("Wave track-containers Suite", func() {
+	var instance podController
+
+	BeforeEach(func() {
+		instance = &deployment{utils.ExampleDeployment.DeepCopy()}
+		instance.SetAnnotations(map[string]string{TrackContainersAnnotation: "container2"})
+	})
+
+	It("only tracks references of the listed containers", func() {
+		configMaps, secrets := getChildNamesByType(instance)
+		Expect(configMaps).To(HaveLen(3))
+		Expect(configMaps).To(HaveKey("example2"))
+		Expect(configMaps).To(HaveKey("example3"))
+		Expect(configMaps).To(HaveKey("env-optional"))
+		Expect(configMaps["example3"].keys).To(Equal(map[string]struct{}{"key2": {}}))
+		Expect(secrets).To(HaveLen(3))
+		Expect(secrets).NotTo(HaveKey("example1"))
+	})
+
+	It("tracks the volumes the listed containers mount", func() {
+		podTemplate := instance.GetPodTemplate()
+		podTemplate.Spec.Containers[1].VolumeMounts = []corev1.VolumeMount{{Name: "secret1", MountPath: "/secret1"}}
+		instance.SetPodTemplate(podTemplate)
+
+		_, secrets := getChildNamesByType(instance)
+		Expect(secrets).To(HaveKey("example1"))
+		Expect(secrets["example1"].allKeys).To(BeTrue())
+	})
+
+	It("reports containers which do not exist", func() {
+		instance.SetAnnotations(map[string]string{TrackContainersAnnotation: "container2, sidecar"})
+		containers, unknown := trackedContainers(instance)
+		Expect(containers).To(HaveLen(1))
+		Expect(unknown).To(Equal([]string{"sidecar"}))
+	})
+
+	It("tracks every container without the annotation", func() {
+		instance.SetAnnotations(nil)
+		containers, unknown := trackedContainers(instance)
+		Expect(containers).To(HaveLen(2))
+		Expect(unknown).To(BeEmpty())
+		Expect(trackedVolumes(instance, containers)).To(HaveLen(len(instance.GetPodTemplate().Spec.Volumes)))
+	})
+})
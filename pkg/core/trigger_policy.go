@@ -0,0 +1,257 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// TriggerPolicy is a value of the RequiredAnnotation choosing when Wave rolls
+// out the configuration changes of a workload
+type TriggerPolicy string
+
+const (
+	// ImmediatePolicy rolls out each configuration change as soon as Wave
+	// sees it. The value "true" is an alias.
+	ImmediatePolicy TriggerPolicy = "immediate"
+
+	// DebouncedPolicy rolls out a configuration change once the
+	// configuration has not changed again for the debounce period
+	DebouncedPolicy TriggerPolicy = "debounced"
+
+	// WindowedPolicy only rolls out configuration changes in one of the
+	// windows of the workload's MaintenanceWindowAnnotation
+	WindowedPolicy TriggerPolicy = "windowed"
+
+	// ManualPolicy only rolls out configuration changes when the
+	// TriggerAnnotation of the workload changes
+	ManualPolicy TriggerPolicy = "manual"
+
+	// DryRunPolicy never rolls out configuration changes, only reporting
+	// the rollouts Wave would have made
+	DryRunPolicy TriggerPolicy = "dry-run"
+
+	// disabledValue is the value of the RequiredAnnotation explicitly
+	// disabling Wave for a workload
+	disabledValue = "false"
+
+	// defaultDebouncePeriod is how long the configuration of workloads with
+	// the DebouncedPolicy must settle by default
+	defaultDebouncePeriod = time.Minute
+)
+
+// TriggerPolicies lists the valid values of the RequiredAnnotation
+var TriggerPolicies = []TriggerPolicy{ImmediatePolicy, DebouncedPolicy, WindowedPolicy, ManualPolicy, DryRunPolicy}
+
+// TriggerPolicyOf returns the trigger policy the RequiredAnnotation of the
+// workload declares, or an empty policy if Wave does not manage it. An error
+// is returned for values Wave does not recognise.
+func TriggerPolicyOf(obj metav1.Object) (TriggerPolicy, error) {
+	value, ok := obj.GetAnnotations()[RequiredAnnotation]
+	if !ok || value == disabledValue {
+		return "", nil
+	}
+	if value == requiredAnnotationValue {
+		return ImmediatePolicy, nil
+	}
+	for _, policy := range TriggerPolicies {
+		if TriggerPolicy(value) == policy {
+			return policy, nil
+		}
+	}
+	valid := []string{requiredAnnotationValue, disabledValue}
+	for _, policy := range TriggerPolicies {
+		valid = append(valid, string(policy))
+	}
+	return "", fmt.Errorf("invalid %s %q, must be one of %s", RequiredAnnotation, value, strings.Join(valid, ", "))
+}
+
+// Managed returns true if the RequiredAnnotation of the workload declares a
+// valid trigger policy
+func Managed(obj metav1.Object) bool {
+	policy, err := TriggerPolicyOf(obj)
+	return err == nil && policy != ""
+}
+
+// WithDebouncePeriod sets how long the configuration of workloads with the
+// DebouncedPolicy must settle before Wave rolls it out
+func WithDebouncePeriod(period time.Duration) Option {
+	return func(h *Handler) {
+		h.debounce.period = period
+	}
+}
+
+// debouncer tracks since when each workload has had its configuration hash
+type debouncer struct {
+	period time.Duration
+
+	mutex sync.Mutex
+	seen  map[types.UID]debounced
+}
+
+// debounced is the configuration hash of a workload and when Wave first saw it
+type debounced struct {
+	hash  string
+	since time.Time
+}
+
+func newDebouncer() *debouncer {
+	return &debouncer{period: defaultDebouncePeriod, seen: make(map[types.UID]debounced)}
+}
+
+// wait returns how much longer the configuration hash of the workload must
+// settle, starting the period if the hash is new
+func (d *debouncer) wait(uid types.UID, hash string, now time.Time) time.Duration {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	seen, ok := d.seen[uid]
+	if !ok || seen.hash != hash {
+		seen = debounced{hash: hash, since: now}
+		d.seen[uid] = seen
+	}
+	if wait := seen.since.Add(d.period).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// forget removes what the debouncer knows about the workload
+func (d *debouncer) forget(uid types.UID) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	delete(d.seen, uid)
+}
+
+// reporter remembers the last value reported about each workload, so that a
+// condition which persists across reconciles is only reported when it first
+// appears
+type reporter struct {
+	mutex sync.Mutex
+	last  map[types.UID]string
+}
+
+func newReporter() *reporter {
+	return &reporter{last: make(map[types.UID]string)}
+}
+
+// changed records the value reported about the workload and returns true if
+// it differs from the last value reported
+func (r *reporter) changed(uid types.UID, value string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if last, ok := r.last[uid]; ok && last == value {
+		return false
+	}
+	r.last[uid] = value
+	return true
+}
+
+// forget removes what the reporter knows about the workload
+func (r *reporter) forget(uid types.UID) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.last, uid)
+}
+
+// triggered returns true if the TriggerAnnotation of the instance changed
+// since its last rollout
+func triggered(instance podController) bool {
+	annotations := instance.GetAnnotations()
+	return annotations[TriggerAnnotation] != "" && annotations[TriggerAnnotation] != annotations[AppliedTriggerAnnotation]
+}
+
+// setAppliedTrigger records the TriggerAnnotation rolled out on workloads
+// with the ManualPolicy
+func setAppliedTrigger(instance, desired podController) {
+	if policy, _ := TriggerPolicyOf(instance); policy != ManualPolicy {
+		return
+	}
+	annotations := desired.GetAnnotations()
+	annotations[AppliedTriggerAnnotation] = instance.GetAnnotations()[TriggerAnnotation]
+	desired.SetAnnotations(annotations)
+}
+
+// checkTriggerPolicy checks whether the trigger policy of the instance holds
+// back its rollout, and returns the time to wait before trying again. Each
+// hold is only reported when its hash or cause changes, as the holds of some
+// policies last until the workload is changed.
+func (h *Handler) checkTriggerPolicy(instance podController, hash string, changes []sourceChange) (bool, time.Duration) {
+	policy, _ := TriggerPolicyOf(instance)
+	release := func() (bool, time.Duration) {
+		h.held.forget(instance.GetUID())
+		return false, 0
+	}
+
+	// cause identifies the hold independently of the time left
+	var cause, reason string
+	var wait time.Duration
+	switch policy {
+	case DebouncedPolicy:
+		if wait = h.debounce.wait(instance.GetUID(), hash, h.getClock().Now()); wait == 0 {
+			return release()
+		}
+		cause = "settling"
+		reason = fmt.Sprintf("waiting %s for the configuration to settle", wait)
+	case WindowedPolicy:
+		until, err := h.untilMaintenanceWindow(instance)
+		switch {
+		case err != nil:
+			reason = err.Error()
+			cause = reason
+		case until == 0:
+			return release()
+		default:
+			wait = until
+			cause = "outside maintenance window"
+			reason = fmt.Sprintf("waiting %s for the maintenance window", wait)
+		}
+	case ManualPolicy:
+		if !triggered(instance) {
+			reason = fmt.Sprintf("rollouts are manual, change the %s annotation to roll it out", TriggerAnnotation)
+			cause = reason
+			break
+		}
+		expired, ttl := h.approvalExpired(instance)
+		if !expired {
+			return release()
+		}
+		reason = fmt.Sprintf("the approval expired after %s, change the %s annotation again to roll it out", ttl, TriggerAnnotation)
+		cause = reason
+	case DryRunPolicy:
+		reason = "dry run, Wave would have rolled it out now"
+		cause = reason
+	default:
+		return release()
+	}
+
+	log := logf.Log.WithName("wave")
+	log.V(1).Info("Holding back rollout", "namespace", instance.GetNamespace(), "name", instance.GetName(), "policy", policy, "hash", hash, "wait", wait.String())
+	if h.held.changed(instance.GetUID(), fmt.Sprintf("%s/%s/%s", hash, policy, cause)) {
+		h.recorder.Eventf(instance.GetObject(), corev1.EventTypeNormal, "RolloutHeld", "Rollout of configuration hash %s held back by the %s trigger policy: %s", shortHash(hash), policy, reason)
+		h.recordDecision(audit.Deferred, instance, hash, changes, fmt.Sprintf("%s trigger policy: %s", policy, reason))
+	}
+	return true, wait
+}
@@ -0,0 +1,254 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/clock"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave trigger policy Suite", func() {
+	var c client.Client
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var fakeClock *clock.FakeClock
+
+	getDeployment := func() *appsv1.Deployment {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		return d
+	}
+
+	handle := func() time.Duration {
+		result, err := h.HandleDeployment(context.TODO(), getDeployment())
+		Expect(err).NotTo(HaveOccurred())
+		return result.RequeueAfter
+	}
+
+	// events returns the events recorded so far
+	events := func() []string {
+		var events []string
+		for len(recorder.Events) > 0 {
+			events = append(events, <-recorder.Events)
+		}
+		return events
+	}
+
+	hash := func() string {
+		return getConfigHash(&deployment{getDeployment()})
+	}
+
+	annotate := func(key, value string) {
+		d := getDeployment()
+		d.Annotations[key] = value
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+	}
+
+	// changeConfig changes the data of the ConfigMap the Deployment uses
+	changeConfig := func(value string) {
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, cm)).To(Succeed())
+		cm.Data["key"] = value
+		Expect(c.Update(context.TODO(), cm)).To(Succeed())
+	}
+
+	setup := func(annotations map[string]string) {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			Annotations: annotations,
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example"}},
+			}},
+		}}
+		c = fake.NewFakeClient(d, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+			Data:       map[string]string{"key": "value"},
+		})
+		recorder = record.NewFakeRecorder(100)
+		// A Tuesday
+		fakeClock = clock.NewFakeClock(time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC))
+		h = NewHandler(c, recorder, WithClock(fakeClock), WithDebouncePeriod(time.Minute))
+	}
+
+	Context("TriggerPolicyOf", func() {
+		policyOf := func(value string) (TriggerPolicy, error) {
+			return TriggerPolicyOf(&metav1.ObjectMeta{Annotations: map[string]string{RequiredAnnotation: value}})
+		}
+
+		It("accepts the trigger policies", func() {
+			for _, policy := range TriggerPolicies {
+				Expect(policyOf(string(policy))).To(Equal(policy))
+			}
+			Expect(policyOf("true")).To(Equal(ImmediatePolicy))
+		})
+
+		It("does not manage disabled workloads", func() {
+			Expect(policyOf("false")).To(BeEmpty())
+			Expect(TriggerPolicyOf(&metav1.ObjectMeta{})).To(BeEmpty())
+		})
+
+		It("rejects other values", func() {
+			_, err := policyOf("True")
+			Expect(err).To(MatchError(ContainSubstring("must be one of true, false, immediate")))
+		})
+	})
+
+	It("leaves workloads with an invalid trigger policy as they are", func() {
+		setup(map[string]string{RequiredAnnotation: "debounce"})
+		handle()
+		Expect(hash()).To(BeEmpty())
+		Expect(getDeployment().GetFinalizers()).To(BeEmpty())
+		Expect(events()).To(ContainElement(ContainSubstring("InvalidTriggerPolicy")))
+	})
+
+	It("warns once about each invalid trigger policy", func() {
+		setup(map[string]string{RequiredAnnotation: "debounce"})
+		handle()
+		handle()
+		Expect(events()).To(ConsistOf(ContainSubstring("InvalidTriggerPolicy")))
+
+		annotate(RequiredAnnotation, "yes")
+		handle()
+		handle()
+		Expect(events()).To(ConsistOf(ContainSubstring(`"yes"`)))
+	})
+
+	It("cleans up deleted workloads with an invalid trigger policy", func() {
+		setup(map[string]string{RequiredAnnotation: string(ImmediatePolicy)})
+		handle()
+		Expect(getDeployment().GetFinalizers()).To(ContainElement(FinalizerString))
+
+		d := getDeployment()
+		d.Annotations[RequiredAnnotation] = "debounce"
+		now := metav1.Now()
+		d.SetDeletionTimestamp(&now)
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+		events()
+		handle()
+		Expect(events()).To(ContainElement(ContainSubstring("Removed finalizer")))
+
+		cm := &corev1.ConfigMap{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, cm)).To(Succeed())
+		Expect(cm.GetOwnerReferences()).To(BeEmpty())
+	})
+
+	It("rolls out debounced workloads once their configuration settles", func() {
+		setup(map[string]string{RequiredAnnotation: string(DebouncedPolicy)})
+		Expect(handle()).To(Equal(time.Minute))
+		Expect(hash()).To(BeEmpty())
+		Expect(getDeployment().GetFinalizers()).NotTo(BeEmpty())
+
+		// Changing the configuration again restarts the period
+		fakeClock.Step(40 * time.Second)
+		changeConfig("other")
+		Expect(handle()).To(Equal(time.Minute))
+		fakeClock.Step(40 * time.Second)
+		Expect(handle()).To(Equal(20 * time.Second))
+		Expect(hash()).To(BeEmpty())
+
+		fakeClock.Step(20 * time.Second)
+		Expect(handle()).To(BeZero())
+		Expect(hash()).NotTo(BeEmpty())
+	})
+
+	It("rolls out windowed workloads in their maintenance window", func() {
+		setup(map[string]string{
+			RequiredAnnotation:          string(WindowedPolicy),
+			MaintenanceWindowAnnotation: "14:00-15:00",
+		})
+		Expect(handle()).To(Equal(2 * time.Hour))
+		Expect(hash()).To(BeEmpty())
+
+		fakeClock.Step(2 * time.Hour)
+		handle()
+		Expect(hash()).NotTo(BeEmpty())
+	})
+
+	It("holds back windowed workloads without a maintenance window", func() {
+		setup(map[string]string{RequiredAnnotation: string(WindowedPolicy)})
+		Expect(handle()).To(BeZero())
+		Expect(hash()).To(BeEmpty())
+		Expect(events()).To(ContainElement(ContainSubstring("no " + MaintenanceWindowAnnotation)))
+	})
+
+	It("rolls out manual workloads when they are triggered", func() {
+		setup(map[string]string{RequiredAnnotation: string(ManualPolicy)})
+		handle()
+		Expect(hash()).To(BeEmpty())
+
+		annotate(TriggerAnnotation, "1")
+		handle()
+		rolledOut := hash()
+		Expect(rolledOut).NotTo(BeEmpty())
+		Expect(getDeployment().GetAnnotations()).To(HaveKeyWithValue(AppliedTriggerAnnotation, "1"))
+
+		changeConfig("other")
+		handle()
+		Expect(hash()).To(Equal(rolledOut))
+
+		annotate(TriggerAnnotation, "2")
+		handle()
+		Expect(hash()).NotTo(Equal(rolledOut))
+	})
+
+	It("never rolls out dry-run workloads", func() {
+		setup(map[string]string{RequiredAnnotation: string(DryRunPolicy)})
+		handle()
+		annotate(TriggerAnnotation, "1")
+		handle()
+		Expect(hash()).To(BeEmpty())
+		Expect(events()).To(ContainElement(ContainSubstring("RolloutHeld")))
+	})
+
+	It("reports each held back rollout once", func() {
+		setup(map[string]string{RequiredAnnotation: string(ManualPolicy)})
+		held := func() int {
+			count := 0
+			for _, event := range events() {
+				if strings.Contains(event, "RolloutHeld") {
+					count++
+				}
+			}
+			return count
+		}
+		handle()
+		handle()
+		handle()
+		Expect(held()).To(Equal(1))
+
+		changeConfig("changed")
+		handle()
+		handle()
+		Expect(held()).To(Equal(1))
+	})
+})
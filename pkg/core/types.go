@@ -33,9 +33,77 @@ const (
 	FinalizerString = "wave.pusher.com/finalizer"
 
 	// RequiredAnnotation is the key of the annotation on the Deployment that Wave
-	// checks for before processing the deployment
+	// checks for before processing the deployment. Its value is the
+	// TriggerPolicy of the Deployment
 	RequiredAnnotation = "wave.pusher.com/update-on-config-change"
 
+	// AppliedTriggerAnnotation is the key of the annotation on the Deployment
+	// recording the value of the TriggerAnnotation last rolled out by
+	// workloads with the ManualPolicy
+	AppliedTriggerAnnotation = "wave.pusher.com/applied-trigger"
+
+	// SourceHashesAnnotation is the key of the annotation on the Deployment
+	// that records a short hash of each key of each child used to calculate
+	// the applied configuration hash
+	SourceHashesAnnotation = "wave.pusher.com/source-hashes"
+
+	// PausedAnnotation is the key of an optional annotation on the Deployment.
+	// While its value is "true", Wave does not update the configuration hash
+	PausedAnnotation = "wave.pusher.com/paused"
+
+	// TriggerAnnotation is the key of an optional annotation on the Deployment
+	// whose value is mixed into the configuration hash. Changing its value
+	// restarts the Deployment even if its configuration is unchanged
+	TriggerAnnotation = "wave.pusher.com/trigger"
+
+	// OnlyTrackAnnotation is the key of an optional annotation on the
+	// Deployment listing the only children Wave tracks, as comma separated
+	// "configmap/<name>" or "secret/<name>" entries. Other referenced
+	// ConfigMaps and Secrets are ignored
+	OnlyTrackAnnotation = "wave.pusher.com/only-track"
+
+	// TrackContainersAnnotation is the key of an optional annotation on the
+	// Deployment listing, comma separated, the containers whose references
+	// Wave tracks. References made only by other containers, and volumes
+	// only they mount, are ignored
+	TrackContainersAnnotation = "wave.pusher.com/track-containers"
+
+	// SourceDeletedAnnotation is the key of an optional annotation on the
+	// Deployment choosing what Wave does when a required ConfigMap or Secret
+	// which contributed to its configuration hash is deleted: "restart"
+	// rolls the Deployment out without it, "warn" emits a warning event and
+	// blocks rollouts until it is recreated. Without it, Wave retries until
+	// the child is recreated
+	SourceDeletedAnnotation = "wave.pusher.com/on-source-deleted"
+
+	// IncludeMetadataAnnotation is the key of an optional annotation on a
+	// ConfigMap or Secret. When its value is "true", the labels and
+	// annotations of the child are hashed along with its data; otherwise it
+	// lists, comma separated, the keys of the labels and annotations to hash
+	IncludeMetadataAnnotation = "wave.pusher.com/include-metadata"
+
+	// PendingRolloutAnnotation is the key of the annotation on the Deployment
+	// recording the start time reserved for a rollout deferred by restart
+	// spreading, so that restarting Wave neither loses nor advances it
+	PendingRolloutAnnotation = "wave.pusher.com/pending-rollout"
+
+	// SuspendUntilAnnotation is the key of an optional annotation on a
+	// ConfigMap or Secret holding an RFC 3339 timestamp. Until then, the
+	// rollouts of workloads referencing it are held back, so that a series of
+	// edits to it triggers a single rollout
+	SuspendUntilAnnotation = "wave.pusher.com/suspend-until"
+
+	// AllowDeletionAnnotation is the key of an optional annotation on a
+	// ConfigMap or Secret. While its value is "true", the deletion protection
+	// webhook allows deleting it even if running workloads still reference it
+	AllowDeletionAnnotation = "wave.pusher.com/allow-deletion"
+
+	// SharedHashGroupAnnotation is the key of an optional annotation on the
+	// Deployment naming a group of tightly-coupled workloads in its
+	// namespace. Every workload of the group hashes the ConfigMaps and
+	// Secrets referenced by any of them, so they all restart together.
+	SharedHashGroupAnnotation = "wave.pusher.com/shared-hash-group"
+
 	// requiredAnnotationValue is the value of the annotation on the Deployment that Wave
 	// checks for before processing the deployment
 	requiredAnnotationValue = "true"
@@ -56,6 +124,10 @@ type configObject struct {
 	required bool
 	allKeys  bool
 	keys     map[string]struct{}
+	envFrom  []envFromSource
+
+	// metadataOnly is true for Secrets whose data Wave cannot read
+	metadataOnly bool
 }
 
 type podController interface {
@@ -126,3 +198,16 @@ func (d *daemonset) SetPodTemplate(template *corev1.PodTemplateSpec) {
 func (d *daemonset) DeepCopy() podController {
 	return &daemonset{d.DaemonSet.DeepCopy()}
 }
+
+// getSelector returns the label selector of the workload's Pods
+func getSelector(obj podController) *metav1.LabelSelector {
+	switch o := obj.GetObject().(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Selector
+	case *appsv1.StatefulSet:
+		return o.Spec.Selector
+	case *appsv1.DaemonSet:
+		return o.Spec.Selector
+	}
+	return nil
+}
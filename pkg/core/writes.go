@@ -0,0 +1,273 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/wave-k8s/wave/pkg/audit"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/apimachinery/pkg/util/uuid"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// FieldManager is the field manager every write Wave makes is attributed to
+const FieldManager = "wave"
+
+// updateWorkload writes the changes made to the original workload as a
+// strategic merge patch, reporting each of the mutations. Patching leaves
+// fields written by others, such as sidecars added by injecting webhooks or
+// images changed by deploys, untouched. The patch fails with a conflict if the
+// workload has changed since the original was read.
+func (h *Handler) updateWorkload(ctx context.Context, original, workload podController, mutations ...audit.Mutation) error {
+	target := audit.Object{Namespace: workload.GetNamespace(), Kind: kindOf(workload), Name: workload.GetName()}
+	if err := h.update(ctx, workload.GetObject(), original.GetObject(), target, workload, mutations); err != nil {
+		return err
+	}
+	h.hashes.record(workload)
+	return nil
+}
+
+// updateChild writes a ConfigMap or Secret of the workload, reporting the
+// mutation
+func (h *Handler) updateChild(ctx context.Context, child Object, workload podController, mutation audit.Mutation) error {
+	target := audit.Object{Namespace: child.GetNamespace(), Kind: kindOf(child), Name: child.GetName()}
+	return h.update(ctx, child, nil, target, workload, []audit.Mutation{mutation})
+}
+
+// update writes the object, or patches it if the original is given,
+// attributing the write to Wave's FieldManager and tagging the request with a
+// new UID. Each mutation is then reported by an Event on the object and, if
+// configured, an audit Record.
+func (h *Handler) update(ctx context.Context, obj, original runtime.Object, target audit.Object, workload podController, mutations []audit.Mutation) error {
+	if err := h.checkKillSwitch(ctx); err != nil {
+		return err
+	}
+	writer, user, err := h.writerFor(target.Namespace)
+	if err != nil {
+		return err
+	}
+	requestUID := string(uuid.NewUUID())
+	ctx = audit.WithRequestUID(ctx, requestUID)
+	if original != nil {
+		err = writer.Patch(ctx, obj, lockedStrategicMergeFrom(original), client.FieldOwner(FieldManager))
+	} else {
+		err = writer.Update(ctx, obj, client.FieldOwner(FieldManager))
+	}
+	if err != nil {
+		return err
+	}
+
+	h.reportWrite(obj, target, workload, requestUID, user, mutations)
+	return nil
+}
+
+// lockedStrategicMergePatch is a strategic merge patch carrying the
+// resourceVersion of the object it was computed from, so that the API server
+// rejects it with a conflict if the object has changed since. Lists such as
+// the containers, their env and the finalizers are merged by key, so the
+// patch only carries the entries Wave changed: setting the hash environment
+// variable doesn't write back the rest of each container.
+type lockedStrategicMergePatch struct {
+	from runtime.Object
+}
+
+// lockedStrategicMergeFrom returns a lockedStrategicMergePatch from the
+// original object, which must be a built-in type
+func lockedStrategicMergeFrom(original runtime.Object) client.Patch {
+	return &lockedStrategicMergePatch{from: original}
+}
+
+// Type implements client.Patch
+func (p *lockedStrategicMergePatch) Type() types.PatchType {
+	return types.StrategicMergePatchType
+}
+
+// Data implements client.Patch
+func (p *lockedStrategicMergePatch) Data(obj runtime.Object) ([]byte, error) {
+	original, err := json.Marshal(p.from)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal JSON")
+	}
+	modified, err := json.Marshal(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal JSON")
+	}
+	data, err := strategicpatch.CreateTwoWayMergePatch(original, modified, p.from)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to create patch")
+	}
+	accessor, err := meta.Accessor(p.from)
+	if err != nil {
+		return nil, err
+	}
+	if accessor.GetResourceVersion() == "" {
+		return data, nil
+	}
+
+	patch := make(map[string]interface{})
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal JSON")
+	}
+	metadata, ok := patch["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = make(map[string]interface{})
+		patch["metadata"] = metadata
+	}
+	metadata["resourceVersion"] = accessor.GetResourceVersion()
+	return json.Marshal(patch)
+}
+
+// deletePod deletes a Pod of the workload, reporting the deletion on the
+// workload
+func (h *Handler) deletePod(ctx context.Context, pod *corev1.Pod, workload podController) error {
+	target := audit.Object{Namespace: pod.GetNamespace(), Kind: "Pod", Name: pod.GetName()}
+	return h.deleteObject(ctx, pod, target, workload, audit.PodDeleted)
+}
+
+// createObject creates an object on behalf of the workload, reporting the
+// creation on the workload
+func (h *Handler) createObject(ctx context.Context, obj Object, target audit.Object, workload podController, mutation audit.Mutation) error {
+	if err := h.checkKillSwitch(ctx); err != nil {
+		return err
+	}
+	writer, user, err := h.writerFor(target.Namespace)
+	if err != nil {
+		return err
+	}
+	requestUID := string(uuid.NewUUID())
+	ctx = audit.WithRequestUID(ctx, requestUID)
+	if err := writer.Create(ctx, obj, client.FieldOwner(FieldManager)); err != nil {
+		return err
+	}
+
+	h.reportWrite(workload.GetObject(), target, workload, requestUID, user, []audit.Mutation{mutation})
+	return nil
+}
+
+// deleteObject deletes an object on behalf of the workload, reporting the
+// deletion on the workload
+func (h *Handler) deleteObject(ctx context.Context, obj Object, target audit.Object, workload podController, mutation audit.Mutation) error {
+	if err := h.checkKillSwitch(ctx); err != nil {
+		return err
+	}
+	writer, user, err := h.writerFor(target.Namespace)
+	if err != nil {
+		return err
+	}
+	requestUID := string(uuid.NewUUID())
+	ctx = audit.WithRequestUID(ctx, requestUID)
+	if err := writer.Delete(ctx, obj); err != nil {
+		return err
+	}
+
+	h.reportWrite(workload.GetObject(), target, workload, requestUID, user, []audit.Mutation{mutation})
+	return nil
+}
+
+// reportWrite reports each mutation of a write by an Event on the object and,
+// if configured, an audit Record
+func (h *Handler) reportWrite(obj runtime.Object, target audit.Object, workload podController, requestUID, user string, mutations []audit.Mutation) {
+	for _, mutation := range mutations {
+		h.recorder.Eventf(obj, corev1.EventTypeNormal, string(mutation), "%s (field manager %s, request %s)", mutationMessage(mutation, target, workload), FieldManager, requestUID)
+	}
+	h.recordWrite(target, workload, requestUID, user, mutations)
+}
+
+// mutationMessage describes the mutation in the Event reporting it
+func mutationMessage(mutation audit.Mutation, target audit.Object, workload podController) string {
+	switch mutation {
+	case audit.ConfigHashUpdated:
+		return fmt.Sprintf("Updated configuration hash to %s", getConfigHash(workload))
+	case audit.OwnerReferenceAdded:
+		return fmt.Sprintf("Added OwnerReference to %s %s", kindOf(workload), workload.GetName())
+	case audit.OwnerReferenceRemoved:
+		return fmt.Sprintf("Removed OwnerReference to %s %s", kindOf(workload), workload.GetName())
+	case audit.FinalizerAdded:
+		return "Added finalizer " + FinalizerString
+	case audit.FinalizerRemoved:
+		return "Removed finalizer " + FinalizerString
+	case audit.PodDeleted:
+		return fmt.Sprintf("Deleted Pod %s to roll out configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.Paused:
+		return "Paused rollouts"
+	case audit.Resumed:
+		return "Resumed rollouts"
+	case audit.RolloutReleased:
+		return "Released deferred rollout"
+	case audit.CloneCreated:
+		return fmt.Sprintf("Created Deployment %s to roll out configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.CloneDeleted:
+		return fmt.Sprintf("Deleted Deployment %s", target.Name)
+	case audit.ServiceSwitched:
+		return fmt.Sprintf("Switched Service %s to configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.ScaledDown:
+		return fmt.Sprintf("Scaled down %s %s", target.Kind, target.Name)
+	case audit.PreRestartHookStarted:
+		return fmt.Sprintf("Created Job %s before rolling out configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.PostRestartHookStarted:
+		return fmt.Sprintf("Created Job %s after rolling out configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.SnapshotCreated:
+		return fmt.Sprintf("Snapshotted %s as %s", target.Kind, target.Name)
+	case audit.SnapshotDeleted:
+		return fmt.Sprintf("Deleted snapshot %s %s", target.Kind, target.Name)
+	case audit.CanaryCreated:
+		return fmt.Sprintf("Created canary %s running configuration hash %s", target.Name, getConfigHash(workload))
+	case audit.CanaryDeleted:
+		return fmt.Sprintf("Deleted canary %s", target.Name)
+	case audit.CanaryFailed:
+		return fmt.Sprintf("Scaled down failed canary %s", target.Name)
+	default:
+		return string(mutation)
+	}
+}
+
+// recordWrite writes an audit Record describing the write to the configured
+// Sink, if writes are audited
+func (h *Handler) recordWrite(target audit.Object, workload podController, requestUID, user string, mutations []audit.Mutation) {
+	if h.audit == nil || !h.audit.Writes || len(mutations) == 0 {
+		return
+	}
+
+	h.sendRecord(audit.Record{
+		Time:  h.getClock().Now(),
+		Actor: h.audit.Actor,
+		Workload: audit.Workload{
+			Namespace: workload.GetNamespace(),
+			Kind:      kindOf(workload),
+			Name:      workload.GetName(),
+			UID:       string(workload.GetUID()),
+		},
+		Decision: audit.Written,
+		NewHash:  getConfigHash(workload),
+		Changes:  []audit.Change{},
+		Write: &audit.Write{
+			Object:       target,
+			Mutations:    mutations,
+			FieldManager: FieldManager,
+			RequestUID:   requestUID,
+			User:         user,
+		},
+	})
+}
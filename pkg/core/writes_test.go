@@ -0,0 +1,123 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package core
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+	"github.com/wave-k8s/wave/test/utils"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave writes Suite", func() {
+	var h *Handler
+	var recorder *record.FakeRecorder
+	var records chan audit.Record
+	var instance podController
+	var cm *corev1.ConfigMap
+
+	BeforeEach(func() {
+		d := utils.ExampleDeployment.DeepCopy()
+		cm = utils.ExampleConfigMap1.DeepCopy()
+		instance = &deployment{d}
+		recorder = record.NewFakeRecorder(10)
+		records = make(chan audit.Record, 5)
+		h = NewHandler(fake.NewFakeClient(d, cm), recorder, WithAudit(AuditOptions{
+			Actor:  "wave-0",
+			Writes: true,
+			Sink: sinkFunc(func(ctx context.Context, record audit.Record) error {
+				records <- record
+				return nil
+			}),
+		}))
+	})
+
+	It("reports each mutation of the workload", func() {
+		original := instance.DeepCopy()
+		setConfigHash(instance, "new")
+		addFinalizer(instance)
+		Expect(h.updateWorkload(context.TODO(), original, instance, audit.ConfigHashUpdated, audit.FinalizerAdded)).To(Succeed())
+
+		Expect(recorder.Events).To(Receive(MatchRegexp(`^Normal ConfigHashUpdated Updated configuration hash to new \(field manager wave, request [-0-9a-f]+\)$`)))
+		Expect(recorder.Events).To(Receive(MatchRegexp(`^Normal FinalizerAdded Added finalizer wave.pusher.com/finalizer \(field manager wave, request [-0-9a-f]+\)$`)))
+
+		var r audit.Record
+		Eventually(records).Should(Receive(&r))
+		Expect(r.Decision).To(Equal(audit.Written))
+		Expect(r.NewHash).To(Equal("new"))
+		Expect(r.Write).NotTo(BeNil())
+		Expect(r.Write.Object).To(Equal(audit.Object{Namespace: "default", Kind: "Deployment", Name: "example"}))
+		Expect(r.Write.Mutations).To(Equal([]audit.Mutation{audit.ConfigHashUpdated, audit.FinalizerAdded}))
+		Expect(r.Write.FieldManager).To(Equal(FieldManager))
+		Expect(r.Write.RequestUID).NotTo(BeEmpty())
+	})
+
+	It("leaves fields of the workload written by others untouched", func() {
+		// Simulate a sidecar injected after the instance was read
+		injected := utils.ExampleDeployment.DeepCopy()
+		injected.Spec.Template.Spec.Containers = append(injected.Spec.Template.Spec.Containers, corev1.Container{Name: "sidecar", Image: "sidecar"})
+		injected.Spec.Template.SetAnnotations(map[string]string{"sidecar.example.com/status": "injected"})
+		h.Client = fake.NewFakeClient(injected)
+
+		original := instance.DeepCopy()
+		setConfigHash(instance, "new")
+		Expect(h.updateWorkload(context.TODO(), original, instance, audit.ConfigHashUpdated)).To(Succeed())
+
+		d := &appsv1.Deployment{}
+		Expect(h.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		Expect(d.Spec.Template.Spec.Containers).To(HaveLen(len(injected.Spec.Template.Spec.Containers)))
+		Expect(d.Spec.Template.GetAnnotations()).To(HaveKeyWithValue("sidecar.example.com/status", "injected"))
+		Expect(d.Spec.Template.GetAnnotations()).To(HaveKeyWithValue(ConfigHashAnnotation, "new"))
+	})
+
+	It("makes patches conditional on the resourceVersion the workload was read at", func() {
+		original := instance.DeepCopy()
+		original.SetResourceVersion("42")
+		updated := original.DeepCopy()
+		removeFinalizer(updated)
+		setConfigHash(updated, "new")
+
+		data, err := lockedStrategicMergeFrom(original.GetObject()).Data(updated.GetObject())
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(data)).To(ContainSubstring(`"resourceVersion":"42"`))
+		Expect(string(data)).To(ContainSubstring(`"new"`))
+	})
+
+	It("reports the mutation of a child on the child", func() {
+		Expect(h.updateChild(context.TODO(), cm, instance, audit.OwnerReferenceAdded)).To(Succeed())
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal OwnerReferenceAdded Added OwnerReference to Deployment example (field manager wave, request ")))
+
+		var r audit.Record
+		Eventually(records).Should(Receive(&r))
+		Expect(r.Workload.Kind).To(Equal("Deployment"))
+		Expect(r.Write.Object).To(Equal(audit.Object{Namespace: "default", Kind: "ConfigMap", Name: "example1"}))
+	})
+
+	It("doesn't record writes unless asked to", func() {
+		h.audit.Writes = false
+		Expect(h.updateChild(context.TODO(), cm, instance, audit.OwnerReferenceRemoved)).To(Succeed())
+		Expect(recorder.Events).To(Receive(HavePrefix("Normal OwnerReferenceRemoved ")))
+		Consistently(records).ShouldNot(Receive())
+	})
+})
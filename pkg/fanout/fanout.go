@@ -0,0 +1,286 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var (
+	// pending is the number of reconciles waiting for a worker of the pool
+	pending = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wave_fan_out_pending",
+		Help: "Number of reconciles of consumers of changed ConfigMaps and Secrets waiting for a fan-out worker",
+	})
+
+	// reconciles counts the reconciles the pool ran, by controller and
+	// whether they succeeded
+	reconciles = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wave_fan_out_reconciles_total",
+		Help: "Number of reconciles run by fan-out workers, by controller and result",
+	}, []string{"controller", "result"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(pending, reconciles)
+}
+
+// Pool reconciles the workloads consuming a changed ConfigMap or Secret
+// concurrently, on a bounded number of workers shared by every controller,
+// rather than one after the other through the workqueue of each controller.
+// Workers take turns between Namespaces, so that a Secret consumed by many
+// workloads of one Namespace doesn't hold back those of the others.
+//
+// A workload is never reconciled by the Pool and by its controller at once.
+// Reconciles which fail or ask to be requeued are handed back to the
+// controller's workqueue.
+type Pool struct {
+	workers int
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queues  map[string][]key
+	ring    []string
+	tasks   map[key]task
+	busy    map[key]struct{}
+	stopped bool
+}
+
+// key identifies a reconcile of a controller
+type key struct {
+	controller string
+	request    reconcile.Request
+}
+
+// task is how a pending reconcile is run, and where it is handed back to
+type task struct {
+	reconciler reconcile.Reconciler
+	queue      workqueue.RateLimitingInterface
+}
+
+// NewPool constructs a Pool running at most workers reconciles at once
+func NewPool(workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	p := &Pool{
+		workers: workers,
+		queues:  make(map[string][]key),
+		tasks:   make(map[key]task),
+		busy:    make(map[key]struct{}),
+	}
+	p.cond = sync.NewCond(&p.mutex)
+	return p
+}
+
+// Start implements manager.Runnable, running the workers until stop is
+// closed
+func (p *Pool) Start(stop <-chan struct{}) error {
+	var wg sync.WaitGroup
+	for i := 0; i < p.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+	<-stop
+	p.mutex.Lock()
+	p.stopped = true
+	p.cond.Broadcast()
+	p.mutex.Unlock()
+	wg.Wait()
+	return nil
+}
+
+// Reconciler wraps the Reconciler of the named controller so that it never
+// reconciles a workload while the Pool does
+func (p *Pool) Reconciler(controller string, r reconcile.Reconciler) reconcile.Reconciler {
+	return reconcile.Func(func(request reconcile.Request) (reconcile.Result, error) {
+		k := key{controller: controller, request: request}
+		p.lock(k)
+		defer p.unlock(k)
+		return r.Reconcile(request)
+	})
+}
+
+// Handler wraps the EventHandler of the named controller so that the
+// requests it enqueues are reconciled by r on the Pool
+func (p *Pool) Handler(controller string, r reconcile.Reconciler, h handler.EventHandler) handler.EventHandler {
+	return &fannedOut{EventHandler: h, pool: p, controller: controller, reconciler: r}
+}
+
+// fannedOut passes the requests enqueued by an EventHandler to the Pool
+type fannedOut struct {
+	handler.EventHandler
+	pool       *Pool
+	controller string
+	reconciler reconcile.Reconciler
+}
+
+// Create implements handler.EventHandler
+func (f *fannedOut) Create(evt event.CreateEvent, wq workqueue.RateLimitingInterface) {
+	f.EventHandler.Create(evt, f.collector(wq))
+}
+
+// Update implements handler.EventHandler
+func (f *fannedOut) Update(evt event.UpdateEvent, wq workqueue.RateLimitingInterface) {
+	f.EventHandler.Update(evt, f.collector(wq))
+}
+
+// Delete implements handler.EventHandler
+func (f *fannedOut) Delete(evt event.DeleteEvent, wq workqueue.RateLimitingInterface) {
+	f.EventHandler.Delete(evt, f.collector(wq))
+}
+
+// Generic implements handler.EventHandler
+func (f *fannedOut) Generic(evt event.GenericEvent, wq workqueue.RateLimitingInterface) {
+	f.EventHandler.Generic(evt, f.collector(wq))
+}
+
+// collector returns a workqueue collecting the requests added to it
+func (f *fannedOut) collector(wq workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	return &collector{RateLimitingInterface: wq, handler: f}
+}
+
+// collector intercepts the requests added to the controller's workqueue
+type collector struct {
+	workqueue.RateLimitingInterface
+	handler *fannedOut
+}
+
+// Add implements workqueue.Interface
+func (c *collector) Add(item interface{}) {
+	request, ok := item.(reconcile.Request)
+	if !ok {
+		c.RateLimitingInterface.Add(item)
+		return
+	}
+	c.handler.pool.push(key{controller: c.handler.controller, request: request}, task{
+		reconciler: c.handler.reconciler,
+		queue:      c.RateLimitingInterface,
+	})
+}
+
+// push holds the reconcile until a worker runs it, unless it is pending
+// already
+func (p *Pool) push(k key, t task) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if _, ok := p.tasks[k]; ok {
+		return
+	}
+	p.tasks[k] = t
+	namespace := k.request.Namespace
+	if len(p.queues[namespace]) == 0 {
+		p.ring = append(p.ring, namespace)
+	}
+	p.queues[namespace] = append(p.queues[namespace], k)
+	pending.Set(float64(len(p.tasks)))
+	p.cond.Broadcast()
+}
+
+// pop waits for a pending reconcile, taking the Namespaces in turns, and
+// returns it unless the Pool stopped
+func (p *Pool) pop() (key, task, bool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for len(p.ring) == 0 && !p.stopped {
+		p.cond.Wait()
+	}
+	if p.stopped {
+		return key{}, task{}, false
+	}
+
+	namespace := p.ring[0]
+	p.ring = p.ring[1:]
+	k := p.queues[namespace][0]
+	p.queues[namespace] = p.queues[namespace][1:]
+	if len(p.queues[namespace]) > 0 {
+		p.ring = append(p.ring, namespace)
+	} else {
+		delete(p.queues, namespace)
+	}
+	t := p.tasks[k]
+	delete(p.tasks, k)
+	pending.Set(float64(len(p.tasks)))
+	return k, t, true
+}
+
+// work runs pending reconciles until the Pool stops
+func (p *Pool) work() {
+	for {
+		k, t, ok := p.pop()
+		if !ok {
+			return
+		}
+		p.run(k, t)
+	}
+}
+
+// run reconciles the request, handing it back to the controller's
+// workqueue if it fails or asks to be requeued
+func (p *Pool) run(k key, t task) {
+	p.lock(k)
+	result, err := t.reconciler.Reconcile(k.request)
+	p.unlock(k)
+
+	switch {
+	case err != nil:
+		reconciles.WithLabelValues(k.controller, "error").Inc()
+		t.queue.AddRateLimited(k.request)
+	case result.RequeueAfter > 0:
+		reconciles.WithLabelValues(k.controller, "requeue").Inc()
+		t.queue.Forget(k.request)
+		t.queue.AddAfter(k.request, result.RequeueAfter)
+	case result.Requeue:
+		reconciles.WithLabelValues(k.controller, "requeue").Inc()
+		t.queue.AddRateLimited(k.request)
+	default:
+		reconciles.WithLabelValues(k.controller, "success").Inc()
+		t.queue.Forget(k.request)
+	}
+}
+
+// lock waits until neither the Pool nor the controller reconciles the
+// request, and marks it as being reconciled
+func (p *Pool) lock(k key) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for {
+		if _, ok := p.busy[k]; !ok {
+			break
+		}
+		p.cond.Wait()
+	}
+	p.busy[k] = struct{}{}
+}
+
+// unlock marks the request as no longer being reconciled
+func (p *Pool) unlock(k key) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	delete(p.busy, k)
+	p.cond.Broadcast()
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package fanout
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// request returns a request for the workload
+func request(namespace, name string) reconcile.Request {
+	return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+}
+
+var _ = Describe("Wave fan-out Suite", func() {
+	var pool *Pool
+	var wq workqueue.RateLimitingInterface
+	var stop chan struct{}
+
+	var mutex sync.Mutex
+	var reconciled []reconcile.Request
+	var result reconcile.Result
+	var err error
+
+	r := reconcile.Func(func(req reconcile.Request) (reconcile.Result, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		reconciled = append(reconciled, req)
+		return result, err
+	})
+
+	// reconciledRequests returns the requests reconciled so far
+	reconciledRequests := func() []reconcile.Request {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]reconcile.Request{}, reconciled...)
+	}
+
+	// enqueue passes the requests to the Pool as an EventHandler would
+	enqueue := func(requests ...reconcile.Request) {
+		h := pool.Handler("test-controller", r, &handler.Funcs{
+			UpdateFunc: func(_ event.UpdateEvent, q workqueue.RateLimitingInterface) {
+				for _, req := range requests {
+					q.Add(req)
+				}
+			},
+		})
+		h.Update(event.UpdateEvent{MetaOld: &metav1.ObjectMeta{}, MetaNew: &metav1.ObjectMeta{}}, wq)
+	}
+
+	BeforeEach(func() {
+		pool = NewPool(1)
+		wq = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		stop = make(chan struct{})
+		reconciled = nil
+		result = reconcile.Result{}
+		err = nil
+	})
+
+	AfterEach(func() {
+		close(stop)
+		wq.ShutDown()
+	})
+
+	start := func() {
+		go pool.Start(stop)
+	}
+
+	It("reconciles the requests rather than enqueuing them", func() {
+		start()
+		enqueue(request("default", "a"), request("default", "b"))
+		Eventually(reconciledRequests).Should(HaveLen(2))
+		Expect(wq.Len()).To(BeZero())
+	})
+
+	It("takes turns between Namespaces", func() {
+		enqueue(request("a", "1"), request("a", "2"), request("a", "3"), request("b", "1"), request("c", "1"))
+		start()
+		Eventually(reconciledRequests).Should(HaveLen(5))
+		Expect(reconciledRequests()).To(Equal([]reconcile.Request{
+			request("a", "1"), request("b", "1"), request("c", "1"), request("a", "2"), request("a", "3"),
+		}))
+	})
+
+	It("reconciles a request pending several times once", func() {
+		enqueue(request("default", "a"), request("default", "a"))
+		start()
+		Eventually(reconciledRequests).Should(HaveLen(1))
+		Consistently(reconciledRequests, 100*time.Millisecond).Should(HaveLen(1))
+	})
+
+	It("hands failed reconciles back to the workqueue", func() {
+		err = errors.New("failed")
+		start()
+		enqueue(request("default", "a"))
+		Eventually(func() int { return wq.NumRequeues(request("default", "a")) }).Should(Equal(1))
+	})
+
+	It("hands reconciles asking to be requeued back to the workqueue", func() {
+		result = reconcile.Result{RequeueAfter: 10 * time.Millisecond}
+		start()
+		enqueue(request("default", "a"))
+		Eventually(wq.Len).Should(Equal(1))
+	})
+
+	It("never reconciles a request while the controller does", func() {
+		reconciling := make(chan struct{})
+		release := make(chan struct{})
+		controller := pool.Reconciler("test-controller", reconcile.Func(func(reconcile.Request) (reconcile.Result, error) {
+			close(reconciling)
+			<-release
+			return reconcile.Result{}, nil
+		}))
+		go controller.Reconcile(request("default", "a"))
+		<-reconciling
+
+		start()
+		enqueue(request("default", "a"))
+		Consistently(reconciledRequests, 100*time.Millisecond).Should(BeEmpty())
+		close(release)
+		Eventually(reconciledRequests).Should(HaveLen(1))
+	})
+})
@@ -0,0 +1,306 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faults
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// ErrorRateEnv is the environment variable setting Options.ErrorRate
+	ErrorRateEnv = "WAVE_INJECT_ERROR_RATE"
+
+	// ConflictRateEnv is the environment variable setting Options.ConflictRate
+	ConflictRateEnv = "WAVE_INJECT_CONFLICT_RATE"
+
+	// LatencyEnv is the environment variable setting Options.Latency
+	LatencyEnv = "WAVE_INJECT_LATENCY"
+)
+
+// injected counts the faults injected, by type of fault
+var injected = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "wave_injected_faults_total",
+	Help: "Number of faults injected into API calls for resilience testing, by type of fault",
+}, []string{"type"})
+
+func init() {
+	metrics.Registry.MustRegister(injected)
+}
+
+// Options configures the faults an Injector injects into API calls
+type Options struct {
+	// ErrorRate is the fraction of API calls which fail with an internal
+	// server error
+	ErrorRate float64
+
+	// ConflictRate is the fraction of updates and patches which fail with a
+	// conflict
+	ConflictRate float64
+
+	// Latency is the maximum latency added to each API call. Each call is
+	// delayed by a random duration up to Latency.
+	Latency time.Duration
+}
+
+// Enabled returns true if the Options inject any fault
+func (o Options) Enabled() bool {
+	return o.ErrorRate > 0 || o.ConflictRate > 0 || o.Latency > 0
+}
+
+// Validate returns an error if a rate is not between 0 and 1 or the latency
+// is negative
+func (o Options) Validate() error {
+	if o.ErrorRate < 0 || o.ErrorRate > 1 {
+		return fmt.Errorf("error rate %v must be between 0 and 1", o.ErrorRate)
+	}
+	if o.ConflictRate < 0 || o.ConflictRate > 1 {
+		return fmt.Errorf("conflict rate %v must be between 0 and 1", o.ConflictRate)
+	}
+	if o.Latency < 0 {
+		return fmt.Errorf("latency %v must not be negative", o.Latency)
+	}
+	return nil
+}
+
+// OptionsFromEnv reads Options from the environment variables looked up by
+// getenv, such as os.Getenv
+func OptionsFromEnv(getenv func(string) string) (Options, error) {
+	o := Options{}
+	var err error
+	if value := getenv(ErrorRateEnv); value != "" {
+		if o.ErrorRate, err = strconv.ParseFloat(value, 64); err != nil {
+			return Options{}, fmt.Errorf("invalid %s: %v", ErrorRateEnv, err)
+		}
+	}
+	if value := getenv(ConflictRateEnv); value != "" {
+		if o.ConflictRate, err = strconv.ParseFloat(value, 64); err != nil {
+			return Options{}, fmt.Errorf("invalid %s: %v", ConflictRateEnv, err)
+		}
+	}
+	if value := getenv(LatencyEnv); value != "" {
+		if o.Latency, err = time.ParseDuration(value); err != nil {
+			return Options{}, fmt.Errorf("invalid %s: %v", LatencyEnv, err)
+		}
+	}
+	return o, nil
+}
+
+// Injector injects artificial errors, conflicts and latency into the API
+// calls of the clients it wraps. It is only meant for resilience testing.
+type Injector struct {
+	options Options
+
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+// NewInjector constructs an Injector injecting the faults of the Options,
+// choosing which calls fail from the given seed
+func NewInjector(o Options, seed int64) *Injector {
+	return &Injector{options: o, rand: rand.New(rand.NewSource(seed))}
+}
+
+// Client wraps the client so that its API calls are subject to faults
+func (i *Injector) Client(c client.Client) client.Client {
+	return &faultyClient{Client: c, injector: i}
+}
+
+// Writer wraps the writer so that its API calls are subject to faults
+func (i *Injector) Writer(w client.Writer) client.Writer {
+	return &faultyWriter{Writer: w, injector: i}
+}
+
+// float64 returns a random number in [0, 1)
+func (i *Injector) float64() float64 {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return i.rand.Float64()
+}
+
+// inject delays the call to the object and returns the fault it must fail
+// with, if any. Conflicts are only injected into updates.
+func (i *Injector) inject(ctx context.Context, obj runtime.Object, update bool) error {
+	if i.options.Latency > 0 {
+		injected.WithLabelValues("latency").Inc()
+		delay := time.Duration(i.float64() * float64(i.options.Latency))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if i.options.ErrorRate > 0 && i.float64() < i.options.ErrorRate {
+		injected.WithLabelValues("error").Inc()
+		return apierrors.NewInternalError(errors.New("injected fault"))
+	}
+	if update && i.options.ConflictRate > 0 && i.float64() < i.options.ConflictRate {
+		injected.WithLabelValues("conflict").Inc()
+		return apierrors.NewConflict(resourceOf(obj), nameOf(obj), errors.New("injected conflict"))
+	}
+	return nil
+}
+
+// resourceOf returns a GroupResource naming the kind of the object, for
+// error messages
+func resourceOf(obj runtime.Object) schema.GroupResource {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	return schema.GroupResource{Group: gvk.Group, Resource: gvk.Kind}
+}
+
+// nameOf returns the name of the object, if it has one
+func nameOf(obj runtime.Object) string {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return ""
+	}
+	return accessor.GetName()
+}
+
+// faultyClient injects faults into the API calls of a client.Client
+type faultyClient struct {
+	client.Client
+	injector *Injector
+}
+
+// Get implements client.Reader
+func (c *faultyClient) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if err := c.injector.inject(ctx, obj, false); err != nil {
+		return err
+	}
+	return c.Client.Get(ctx, key, obj)
+}
+
+// List implements client.Reader
+func (c *faultyClient) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := c.injector.inject(ctx, list, false); err != nil {
+		return err
+	}
+	return c.Client.List(ctx, list, opts...)
+}
+
+// Create implements client.Writer
+func (c *faultyClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	return c.injector.Writer(c.Client).Create(ctx, obj, opts...)
+}
+
+// Delete implements client.Writer
+func (c *faultyClient) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	return c.injector.Writer(c.Client).Delete(ctx, obj, opts...)
+}
+
+// Update implements client.Writer
+func (c *faultyClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	return c.injector.Writer(c.Client).Update(ctx, obj, opts...)
+}
+
+// Patch implements client.Writer
+func (c *faultyClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	return c.injector.Writer(c.Client).Patch(ctx, obj, patch, opts...)
+}
+
+// DeleteAllOf implements client.Writer
+func (c *faultyClient) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	return c.injector.Writer(c.Client).DeleteAllOf(ctx, obj, opts...)
+}
+
+// Status implements client.StatusClient
+func (c *faultyClient) Status() client.StatusWriter {
+	return &faultyStatusWriter{StatusWriter: c.Client.Status(), injector: c.injector}
+}
+
+// faultyWriter injects faults into the API calls of a client.Writer
+type faultyWriter struct {
+	client.Writer
+	injector *Injector
+}
+
+// Create implements client.Writer
+func (w *faultyWriter) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if err := w.injector.inject(ctx, obj, false); err != nil {
+		return err
+	}
+	return w.Writer.Create(ctx, obj, opts...)
+}
+
+// Delete implements client.Writer
+func (w *faultyWriter) Delete(ctx context.Context, obj runtime.Object, opts ...client.DeleteOption) error {
+	if err := w.injector.inject(ctx, obj, false); err != nil {
+		return err
+	}
+	return w.Writer.Delete(ctx, obj, opts...)
+}
+
+// Update implements client.Writer
+func (w *faultyWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := w.injector.inject(ctx, obj, true); err != nil {
+		return err
+	}
+	return w.Writer.Update(ctx, obj, opts...)
+}
+
+// Patch implements client.Writer
+func (w *faultyWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.injector.inject(ctx, obj, true); err != nil {
+		return err
+	}
+	return w.Writer.Patch(ctx, obj, patch, opts...)
+}
+
+// DeleteAllOf implements client.Writer
+func (w *faultyWriter) DeleteAllOf(ctx context.Context, obj runtime.Object, opts ...client.DeleteAllOfOption) error {
+	if err := w.injector.inject(ctx, obj, false); err != nil {
+		return err
+	}
+	return w.Writer.DeleteAllOf(ctx, obj, opts...)
+}
+
+// faultyStatusWriter injects faults into the API calls of a
+// client.StatusWriter
+type faultyStatusWriter struct {
+	client.StatusWriter
+	injector *Injector
+}
+
+// Update implements client.StatusWriter
+func (w *faultyStatusWriter) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	if err := w.injector.inject(ctx, obj, true); err != nil {
+		return err
+	}
+	return w.StatusWriter.Update(ctx, obj, opts...)
+}
+
+// Patch implements client.StatusWriter
+func (w *faultyStatusWriter) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if err := w.injector.inject(ctx, obj, true); err != nil {
+		return err
+	}
+	return w.StatusWriter.Patch(ctx, obj, patch, opts...)
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package faults
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/utils"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave faults Suite", func() {
+	var c client.Client
+	key := types.NamespacedName{Namespace: "default", Name: utils.ExampleConfigMap1.GetName()}
+
+	BeforeEach(func() {
+		c = fake.NewFakeClient(utils.ExampleConfigMap1.DeepCopy())
+	})
+
+	It("passes calls through without faults", func() {
+		faulty := NewInjector(Options{}, 1).Client(c)
+		cm := &corev1.ConfigMap{}
+		Expect(faulty.Get(context.TODO(), key, cm)).To(Succeed())
+		Expect(faulty.Update(context.TODO(), cm)).To(Succeed())
+	})
+
+	It("fails calls with internal errors", func() {
+		faulty := NewInjector(Options{ErrorRate: 1}, 1).Client(c)
+		err := faulty.Get(context.TODO(), key, &corev1.ConfigMap{})
+		Expect(apierrors.IsInternalError(err)).To(BeTrue())
+		err = faulty.List(context.TODO(), &corev1.ConfigMapList{})
+		Expect(apierrors.IsInternalError(err)).To(BeTrue())
+	})
+
+	It("fails updates with conflicts", func() {
+		faulty := NewInjector(Options{ConflictRate: 1}, 1).Client(c)
+		cm := &corev1.ConfigMap{}
+		Expect(faulty.Get(context.TODO(), key, cm)).To(Succeed())
+		Expect(apierrors.IsConflict(faulty.Update(context.TODO(), cm))).To(BeTrue())
+		Expect(apierrors.IsConflict(faulty.Patch(context.TODO(), cm, client.MergeFrom(cm.DeepCopy())))).To(BeTrue())
+		Expect(apierrors.IsConflict(faulty.Status().Update(context.TODO(), cm))).To(BeTrue())
+		Expect(faulty.Delete(context.TODO(), cm)).To(Succeed())
+	})
+
+	It("fails a fraction of calls", func() {
+		faulty := NewInjector(Options{ErrorRate: 0.5}, 1).Client(c)
+		failed := 0
+		for i := 0; i < 200; i++ {
+			if faulty.Get(context.TODO(), key, &corev1.ConfigMap{}) != nil {
+				failed++
+			}
+		}
+		Expect(failed).To(BeNumerically("~", 100, 30))
+	})
+
+	It("delays calls until the context is done", func() {
+		faulty := NewInjector(Options{Latency: time.Hour}, 1).Client(c)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(faulty.Get(ctx, key, &corev1.ConfigMap{})).To(Equal(context.DeadlineExceeded))
+	})
+
+	It("reads options from the environment", func() {
+		env := map[string]string{
+			ErrorRateEnv:    "0.1",
+			ConflictRateEnv: "0.2",
+			LatencyEnv:      "2s",
+		}
+		o, err := OptionsFromEnv(func(name string) string { return env[name] })
+		Expect(err).NotTo(HaveOccurred())
+		Expect(o).To(Equal(Options{ErrorRate: 0.1, ConflictRate: 0.2, Latency: 2 * time.Second}))
+		Expect(o.Enabled()).To(BeTrue())
+		Expect(o.Validate()).To(Succeed())
+
+		env[LatencyEnv] = "soon"
+		_, err = OptionsFromEnv(func(name string) string { return env[name] })
+		Expect(err).To(HaveOccurred())
+	})
+
+	It("rejects invalid options", func() {
+		Expect(Options{ErrorRate: 1.5}.Validate()).To(HaveOccurred())
+		Expect(Options{ConflictRate: -1}.Validate()).To(HaveOccurred())
+		Expect(Options{Latency: -time.Second}.Validate()).To(HaveOccurred())
+		Expect(Options{}.Enabled()).To(BeFalse())
+	})
+})
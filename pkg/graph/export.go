@@ -0,0 +1,85 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"context"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// StatePath is the path the server serves the controller's state on
+const StatePath = "/state"
+
+// Tracker reports the state the controller holds in memory about workloads
+type Tracker interface {
+	Tracked() []core.TrackedState
+}
+
+// Export is a snapshot of the dependency graph and the state the controller
+// tracks about each workload
+type Export struct {
+	Time      time.Time          `json:"time"`
+	Workloads []ExportedWorkload `json:"workloads"`
+
+	// Orphaned holds state tracked about workloads that no longer exist. It
+	// is only reported when exporting all namespaces.
+	Orphaned []core.TrackedState `json:"orphaned,omitempty"`
+}
+
+// ExportedWorkload is a workload in the graph and the state tracked about it
+type ExportedWorkload struct {
+	Workload
+	Tracked *core.TrackedState `json:"tracked,omitempty"`
+}
+
+// BuildExport builds the graph of the namespace, or of all namespaces if
+// namespace is empty, and joins it with the state reported by the tracker.
+// Workloads are listed with the options.
+func BuildExport(ctx context.Context, c client.Client, namespace string, tracker Tracker, now time.Time, opts ...client.ListOption) (Export, error) {
+	g, err := Build(ctx, c, namespace, opts...)
+	if err != nil {
+		return Export{}, err
+	}
+
+	tracked := make(map[string]core.TrackedState)
+	if tracker != nil {
+		for _, state := range tracker.Tracked() {
+			tracked[string(state.UID)] = state
+		}
+	}
+
+	export := Export{Time: now, Workloads: []ExportedWorkload{}}
+	for _, workload := range g.Workloads {
+		exported := ExportedWorkload{Workload: workload}
+		if state, ok := tracked[string(workload.UID)]; ok && workload.UID != "" {
+			exported.Tracked = &state
+			delete(tracked, string(workload.UID))
+		}
+		export.Workloads = append(export.Workloads, exported)
+	}
+	if namespace == "" && tracker != nil {
+		for _, state := range tracker.Tracked() {
+			if _, ok := tracked[string(state.UID)]; ok {
+				export.Orphaned = append(export.Orphaned, state)
+			}
+		}
+	}
+	return export, nil
+}
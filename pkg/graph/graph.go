@@ -0,0 +1,155 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package graph builds the dependency graph between workloads and the
+ConfigMaps and Secrets they use, and renders it as a table, DOT or JSON
+*/
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Graph is the dependency graph between workloads and their sources
+type Graph struct {
+	Workloads []Workload `json:"workloads"`
+}
+
+// Workload is a Deployment, StatefulSet or DaemonSet and the ConfigMaps and
+// Secrets it references
+type Workload struct {
+	Namespace string           `json:"namespace"`
+	Kind      string           `json:"kind"`
+	Name      string           `json:"name"`
+	UID       types.UID        `json:"uid,omitempty"`
+	Enabled   bool             `json:"enabled"`
+	Sources   []core.Reference `json:"sources"`
+}
+
+// Build lists the workloads in the namespace, or in all namespaces if
+// namespace is empty, with the options and builds their dependency graph
+func Build(ctx context.Context, c client.Client, namespace string, opts ...client.ListOption) (Graph, error) {
+	objs, err := core.ListWorkloads(ctx, c, namespace, opts...)
+	if err != nil {
+		return Graph{}, err
+	}
+
+	g := Graph{Workloads: []Workload{}}
+	for _, obj := range objs {
+		references, err := core.References(obj)
+		if err != nil {
+			return Graph{}, err
+		}
+		g.Workloads = append(g.Workloads, Workload{
+			Namespace: obj.GetNamespace(),
+			Kind:      core.WorkloadKind(obj),
+			Name:      obj.GetName(),
+			UID:       obj.GetUID(),
+			Enabled:   core.Managed(obj),
+			Sources:   references,
+		})
+	}
+	return g, nil
+}
+
+// Write renders the graph in the given format: table, dot or json
+func (g Graph) Write(w io.Writer, format string) error {
+	switch format {
+	case "table", "":
+		return g.writeTable(w)
+	case "dot":
+		return g.writeDOT(w)
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	default:
+		return fmt.Errorf("unsupported format %q: must be one of table, dot or json", format)
+	}
+}
+
+// writeTable renders one row per edge of the graph
+func (g Graph) writeTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tWORKLOAD\tENABLED\tSOURCE\tKEYS")
+	for _, workload := range g.Workloads {
+		for _, source := range workload.Sources {
+			fmt.Fprintf(tw, "%s\t%s/%s\t%t\t%s/%s\t%s\n",
+				workload.Namespace,
+				workload.Kind, workload.Name,
+				workload.Enabled,
+				source.Kind, source.Name,
+				keysOf(source),
+			)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeDOT renders the graph in the Graphviz DOT language. Workloads that
+// Wave does not manage and optional sources are drawn dashed.
+func (g Graph) writeDOT(w io.Writer) error {
+	nodes := make(map[string]bool)
+	var b strings.Builder
+	b.WriteString("digraph wave {\n  rankdir=LR;\n")
+	for _, workload := range g.Workloads {
+		from := nodeID(workload.Namespace, workload.Kind, workload.Name)
+		style := "solid"
+		if !workload.Enabled {
+			style = "dashed"
+		}
+		fmt.Fprintf(&b, "  %q [shape=box, style=%s];\n", from, style)
+
+		for _, source := range workload.Sources {
+			to := nodeID(workload.Namespace, source.Kind, source.Name)
+			if !nodes[to] {
+				nodes[to] = true
+				fmt.Fprintf(&b, "  %q [shape=ellipse];\n", to)
+			}
+			style := "solid"
+			if !source.Required {
+				style = "dashed"
+			}
+			fmt.Fprintf(&b, "  %q -> %q [label=%q, style=%s];\n", from, to, keysOf(source), style)
+		}
+	}
+	b.WriteString("}\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// nodeID uniquely identifies an object within the graph
+func nodeID(namespace, kind, name string) string {
+	return fmt.Sprintf("%s/%s/%s", namespace, kind, name)
+}
+
+// keysOf describes the keys of the source that are used
+func keysOf(source core.Reference) string {
+	if len(source.Keys) == 0 {
+		return "*"
+	}
+	return strings.Join(source.Keys, ",")
+}
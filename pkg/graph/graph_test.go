@@ -0,0 +1,166 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// trackerFunc adapts a function to the Tracker interface
+type trackerFunc func() []core.TrackedState
+
+func (f trackerFunc) Tracked() []core.TrackedState {
+	return f()
+}
+
+var _ = Describe("Wave graph Suite", func() {
+	var c client.Client
+	var out *bytes.Buffer
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace:   "default",
+				Name:        "example",
+				UID:         types.UID("example-uid"),
+				Annotations: map[string]string{core.RequiredAnnotation: "true"},
+			},
+		}
+		d.Spec.Template.Spec.Volumes = []corev1.Volume{{
+			Name: "config",
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					LocalObjectReference: corev1.LocalObjectReference{Name: "config"},
+				},
+			},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "container",
+			Env: []corev1.EnvVar{{
+				Name: "PASSWORD",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+						Key:                  "password",
+					},
+				},
+			}},
+		}}
+		other := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "example"},
+		}
+		c = fake.NewFakeClient(d, other)
+		out = &bytes.Buffer{}
+	})
+
+	It("builds the graph of a namespace", func() {
+		g, err := Build(context.TODO(), c, "default")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(g.Workloads).To(Equal([]Workload{{
+			Namespace: "default",
+			Kind:      "Deployment",
+			Name:      "example",
+			UID:       types.UID("example-uid"),
+			Enabled:   true,
+			Sources: []core.Reference{
+				{Kind: "ConfigMap", Name: "config", Required: true},
+				{Kind: "Secret", Name: "credentials", Keys: []string{"password"}, Required: true},
+			},
+		}}))
+	})
+
+	It("renders a table", func() {
+		g, _ := Build(context.TODO(), c, "default")
+		Expect(g.Write(out, "table")).To(Succeed())
+		Expect(out.String()).To(ContainSubstring("Secret/credentials"))
+		Expect(out.String()).To(ContainSubstring("password"))
+	})
+
+	It("renders DOT", func() {
+		g, _ := Build(context.TODO(), c, "default")
+		Expect(g.Write(out, "dot")).To(Succeed())
+		Expect(out.String()).To(HavePrefix("digraph wave {"))
+		Expect(out.String()).To(ContainSubstring(`"default/Deployment/example" -> "default/ConfigMap/config" [label="*", style=solid];`))
+	})
+
+	It("rejects unknown formats", func() {
+		Expect(Graph{}.Write(out, "xml")).NotTo(Succeed())
+	})
+
+	It("serves the graph as JSON", func() {
+		rec := httptest.NewRecorder()
+		NewServer(c, ":0", nil).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		g := Graph{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &g)).To(Succeed())
+		Expect(g.Workloads).To(HaveLen(2))
+	})
+
+	Context("export", func() {
+		var tracker trackerFunc
+		var now = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		BeforeEach(func() {
+			tracker = func() []core.TrackedState {
+				return []core.TrackedState{
+					{UID: types.UID("deleted-uid"), DeniedHash: "abc"},
+					{UID: types.UID("example-uid"), Sources: []core.TrackedSource{{Kind: "ConfigMap", Name: "config", ResourceVersion: "1"}}},
+				}
+			}
+		})
+
+		It("joins the tracked state with the graph", func() {
+			export, err := BuildExport(context.TODO(), c, "default", tracker, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(export.Time).To(Equal(now))
+			Expect(export.Workloads).To(HaveLen(1))
+			Expect(export.Workloads[0].Tracked).NotTo(BeNil())
+			Expect(export.Workloads[0].Tracked.Sources).To(HaveLen(1))
+			Expect(export.Orphaned).To(BeEmpty())
+		})
+
+		It("reports state about deleted workloads across all namespaces", func() {
+			export, err := BuildExport(context.TODO(), c, "", tracker, now)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(export.Workloads).To(HaveLen(2))
+			Expect(export.Orphaned).To(Equal([]core.TrackedState{{UID: types.UID("deleted-uid"), DeniedHash: "abc"}}))
+		})
+
+		It("serves the export as YAML", func() {
+			rec := httptest.NewRecorder()
+			NewServer(c, ":0", tracker).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, StatePath+"?namespace=default&format=yaml", nil))
+			Expect(rec.Code).To(Equal(http.StatusOK))
+			Expect(rec.Body.String()).To(ContainSubstring("  tracked:\n    sources:\n    - kind: ConfigMap\n"))
+		})
+	})
+})
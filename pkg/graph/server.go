@@ -0,0 +1,133 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Path is the path the server serves the graph on
+const Path = "/graph"
+
+// Server serves the dependency graph from the controller's cache and the
+// state the controller tracks about each workload
+type Server struct {
+	client  client.Client
+	address string
+	tracker Tracker
+	opts    []client.ListOption
+}
+
+// NewServer constructs a Server listening on address. The tracker may be nil
+// if the controller's state should not be exported. Workloads are listed
+// with the options.
+func NewServer(c client.Client, address string, tracker Tracker, opts ...client.ListOption) *Server {
+	return &Server{client: c, address: address, tracker: tracker, opts: opts}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	mux.Handle(StatePath, s)
+	srv := &http.Server{Addr: s.address, Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving graph: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// ServeHTTP renders the graph, or on StatePath the export of the controller's
+// state, of the namespace given by the namespace query parameter, or of all
+// namespaces, in the format given by the format query parameter
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == StatePath {
+		s.serveState(w, r)
+		return
+	}
+
+	g, err := Build(r.Context(), s.client, r.URL.Query().Get("namespace"), s.opts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		g.Write(w, "json")
+	case "table", "dot":
+		w.Header().Set("Content-Type", "text/plain")
+		g.Write(w, format)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+	}
+}
+
+// serveState renders the export of the controller's state as JSON or YAML
+func (s *Server) serveState(w http.ResponseWriter, r *http.Request) {
+	export, err := BuildExport(r.Context(), s.client, r.URL.Query().Get("namespace"), s.tracker, time.Now().UTC(), s.opts...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data, err := json.MarshalIndent(export, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+	case "yaml":
+		data, err = yaml.JSONToYAML(data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+	default:
+		http.Error(w, fmt.Sprintf("unsupported format %q", format), http.StatusBadRequest)
+		return
+	}
+	w.Write(data)
+}
@@ -0,0 +1,86 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+const (
+	// LivenessPath is the path the server reports liveness on
+	LivenessPath = "/healthz"
+
+	// ReadinessPath is the path the server reports readiness on
+	ReadinessPath = "/readyz"
+)
+
+// Server serves liveness and readiness probes. Wave is ready once the
+// controller's cache has synced.
+type Server struct {
+	address string
+	cache   cache.Cache
+	ready   int32
+}
+
+// NewServer constructs a Server listening on address
+func NewServer(address string, c cache.Cache) *Server {
+	return &Server{address: address, cache: c}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(LivenessPath, s)
+	mux.Handle(ReadinessPath, s)
+	srv := &http.Server{Addr: s.address, Handler: mux}
+
+	go func() {
+		if s.cache.WaitForCacheSync(stop) {
+			atomic.StoreInt32(&s.ready, 1)
+		}
+	}()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving health probes: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// ServeHTTP reports whether Wave is alive or, on ReadinessPath, ready
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == ReadinessPath && atomic.LoadInt32(&s.ready) == 0 {
+		http.Error(w, "caches not synced", http.StatusServiceUnavailable)
+		return
+	}
+	w.Write([]byte("ok"))
+}
@@ -0,0 +1,75 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+)
+
+// syncCache is a cache.Cache whose WaitForCacheSync blocks until synced is
+// closed
+type syncCache struct {
+	cache.Cache
+	synced chan struct{}
+}
+
+func (c *syncCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	select {
+	case <-c.synced:
+		return true
+	case <-stop:
+		return false
+	}
+}
+
+var _ = Describe("Wave health Suite", func() {
+	var s *Server
+	var c *syncCache
+	var stop chan struct{}
+
+	get := func(path string) int {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec.Code
+	}
+
+	BeforeEach(func() {
+		c = &syncCache{synced: make(chan struct{})}
+		s = NewServer("127.0.0.1:0", c)
+		stop = make(chan struct{})
+		go s.Start(stop)
+	})
+
+	AfterEach(func() {
+		close(stop)
+	})
+
+	It("is alive before the cache has synced", func() {
+		Expect(get(LivenessPath)).To(Equal(http.StatusOK))
+	})
+
+	It("is ready once the cache has synced", func() {
+		Expect(get(ReadinessPath)).To(Equal(http.StatusServiceUnavailable))
+		close(c.synced)
+		Eventually(func() int { return get(ReadinessPath) }).Should(Equal(http.StatusOK))
+	})
+})
@@ -0,0 +1,367 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ProtectionPath is the path the deletion protection webhook is served on.
+// It matches protection.Path, which cannot be imported without registering
+// the webhook.
+const ProtectionPath = "/validate-config-deletion"
+
+// Options describes how Wave is deployed and which of its features are
+// enabled
+type Options struct {
+	// Name is the name of Wave's Deployment, ServiceAccount and RBAC objects
+	Name string
+
+	// Namespace is the namespace Wave runs in
+	Namespace string
+
+	// Image is the container image of Wave
+	Image string
+
+	// Replicas is the number of replicas of Wave. More than one requires
+	// LeaderElection.
+	Replicas int32
+
+	// Args are the arguments passed to Wave
+	Args []string
+
+	// Namespaces restricts Wave to the namespaces, granting it Roles in
+	// each of them instead of a ClusterRole
+	Namespaces []string
+
+	// Kinds restricts Wave to the kinds of workloads, among Deployment,
+	// StatefulSet and DaemonSet, granting it access to those only. Every
+	// kind is managed if empty.
+	Kinds []string
+
+	// LeaderElection grants Wave the right to hold a leader election lock
+	// in LeaderElectionNamespace, or Namespace if empty
+	LeaderElection          bool
+	LeaderElectionNamespace string
+
+	// WebhookPort is the port the webhook server listens on. Webhooks adds
+	// the Service and ValidatingWebhookConfiguration of the deletion
+	// protection webhook.
+	Webhooks    bool
+	WebhookPort int32
+
+	// The remaining options enable features which need extra permissions
+	CapacityPods              bool
+	CapacityNodes             bool
+	CSISecretsStore           bool
+	OnDelete                  bool
+	DeletePods                bool
+	BlueGreen                 bool
+	Canaries                  bool
+	RestartHooks              bool
+	Snapshots                 bool
+	Rollbacks                 bool
+	NamespacePriority         bool
+	SecretMetadataOnly        bool
+	Shadow                    bool
+	ImpersonateServiceAccount string
+}
+
+// Validate returns an error if the Options cannot be deployed
+func (o Options) Validate() error {
+	if o.Name == "" || o.Namespace == "" || o.Image == "" {
+		return fmt.Errorf("a name, namespace and image are required")
+	}
+	if o.Replicas > 1 && !o.LeaderElection {
+		return fmt.Errorf("running more than one replica requires leader election")
+	}
+	if len(o.Namespaces) > 0 && (o.CapacityNodes || o.NamespacePriority) {
+		return fmt.Errorf("listing Nodes or Namespaces requires a ClusterRole, which cannot be used with namespaces")
+	}
+	for _, kind := range o.Kinds {
+		if _, ok := workloadResources[kind]; !ok {
+			return fmt.Errorf("unknown kind of workload %q", kind)
+		}
+	}
+	return nil
+}
+
+// Rules returns the rules Wave needs in every namespace it manages, or in
+// the cluster
+func (o Options) Rules() []rbacv1.PolicyRule {
+	write := []string{"update", "patch"}
+	if o.Shadow {
+		write = nil
+	}
+	read := []string{"get", "list", "watch"}
+
+	rules := []rbacv1.PolicyRule{
+		{APIGroups: []string{"apps"}, Resources: o.workloadResources(), Verbs: append(read, write...)},
+		{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: append(read, write...)},
+	}
+	if o.SecretMetadataOnly {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: read})
+	} else {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"secrets"}, Verbs: append(read, write...)})
+	}
+	if !o.Shadow {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "update", "patch"}})
+	}
+
+	pods := []string{}
+	if o.CapacityPods || o.CapacityNodes || o.CSISecretsStore || o.OnDelete || o.DeletePods || o.Canaries {
+		pods = append(pods, read...)
+	}
+	if (o.OnDelete || o.DeletePods) && !o.Shadow {
+		pods = append(pods, "delete")
+	}
+	if len(pods) > 0 {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"pods"}, Verbs: pods})
+	}
+	if o.CapacityNodes {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"nodes"}, Verbs: read})
+	}
+	if o.RestartHooks && !o.Shadow {
+		rules = append(rules,
+			rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"cronjobs"}, Verbs: read},
+			rbacv1.PolicyRule{APIGroups: []string{"batch"}, Resources: []string{"jobs"}, Verbs: append(read, "create")},
+		)
+	}
+	if o.Snapshots && !o.Shadow {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"configmaps", "secrets"}, Verbs: []string{"create", "delete"}})
+	}
+	if o.Rollbacks && !o.Shadow {
+		rules = append(rules,
+			rbacv1.PolicyRule{APIGroups: []string{"wave.pusher.com"}, Resources: []string{"rollbacks"}, Verbs: read},
+			rbacv1.PolicyRule{APIGroups: []string{"wave.pusher.com"}, Resources: []string{"rollbacks/status"}, Verbs: []string{"update", "patch"}},
+		)
+	}
+	if (o.BlueGreen || o.Canaries) && !o.Shadow {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"apps"}, Resources: []string{"deployments"}, Verbs: []string{"create", "delete"}})
+	}
+	if o.BlueGreen && !o.Shadow {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"services"}, Verbs: append(read, write...)})
+	}
+	if o.CSISecretsStore {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{"secrets-store.csi.x-k8s.io"}, Resources: []string{"secretproviderclasspodstatuses"}, Verbs: read})
+	}
+	if o.NamespacePriority {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"namespaces"}, Verbs: read})
+	}
+	if o.ImpersonateServiceAccount != "" && !o.Shadow {
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{""}, Resources: []string{"serviceaccounts"}, ResourceNames: []string{o.ImpersonateServiceAccount}, Verbs: []string{"impersonate"}})
+	}
+	return rules
+}
+
+// workloadResources maps the kinds of workloads to their resources
+var workloadResources = map[string]string{"Deployment": "deployments", "StatefulSet": "statefulsets", "DaemonSet": "daemonsets"}
+
+// workloadResources returns the sorted resources of the kinds of workloads
+// Wave manages
+func (o Options) workloadResources() []string {
+	kinds := o.Kinds
+	if len(kinds) == 0 {
+		kinds = []string{"Deployment", "StatefulSet", "DaemonSet"}
+	}
+	var resources []string
+	for _, kind := range kinds {
+		resources = append(resources, workloadResources[kind])
+	}
+	sort.Strings(resources)
+	return resources
+}
+
+// Objects returns every object needed to deploy Wave with the Options
+func (o Options) Objects() ([]runtime.Object, error) {
+	if err := o.Validate(); err != nil {
+		return nil, err
+	}
+	labels := map[string]string{"app.kubernetes.io/name": o.Name}
+	meta := func(name, namespace string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: namespace, Labels: labels}
+	}
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: o.Name, Namespace: o.Namespace}}
+
+	objs := []runtime.Object{&corev1.ServiceAccount{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+		ObjectMeta: meta(o.Name, o.Namespace),
+	}}
+
+	if len(o.Namespaces) == 0 {
+		objs = append(objs,
+			&rbacv1.ClusterRole{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+				ObjectMeta: meta(o.Name, ""),
+				Rules:      o.Rules(),
+			},
+			&rbacv1.ClusterRoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+				ObjectMeta: meta(o.Name, ""),
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: o.Name},
+				Subjects:   subjects,
+			},
+		)
+	}
+	for _, namespace := range o.Namespaces {
+		objs = append(objs, role(meta(o.Name, namespace), o.Rules()), roleBinding(meta(o.Name, namespace), o.Name, subjects))
+	}
+
+	if o.LeaderElection {
+		namespace := o.LeaderElectionNamespace
+		if namespace == "" {
+			namespace = o.Namespace
+		}
+		name := o.Name + "-leader-election"
+		objs = append(objs,
+			role(meta(name, namespace), []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"configmaps"}, Verbs: []string{"get", "create", "update"}},
+				{APIGroups: []string{""}, Resources: []string{"events"}, Verbs: []string{"create", "patch"}},
+			}),
+			roleBinding(meta(name, namespace), name, subjects),
+		)
+	}
+
+	objs = append(objs, o.deployment(labels))
+
+	if o.Webhooks {
+		objs = append(objs, o.webhookObjects(labels)...)
+	}
+	return objs, nil
+}
+
+// role returns a Role with the rules
+func role(meta metav1.ObjectMeta, rules []rbacv1.PolicyRule) *rbacv1.Role {
+	return &rbacv1.Role{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+		ObjectMeta: meta,
+		Rules:      rules,
+	}
+}
+
+// roleBinding returns a RoleBinding of the Role to the subjects
+func roleBinding(meta metav1.ObjectMeta, role string, subjects []rbacv1.Subject) *rbacv1.RoleBinding {
+	return &rbacv1.RoleBinding{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+		ObjectMeta: meta,
+		RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: role},
+		Subjects:   subjects,
+	}
+}
+
+// deployment returns the Deployment running Wave
+func (o Options) deployment(labels map[string]string) *appsv1.Deployment {
+	replicas := o.Replicas
+	if replicas == 0 {
+		replicas = 1
+	}
+	container := corev1.Container{
+		Name:  "wave",
+		Image: o.Image,
+		Args:  o.Args,
+	}
+	if o.Webhooks {
+		container.Ports = []corev1.ContainerPort{{Name: "webhook-server", ContainerPort: o.WebhookPort, Protocol: corev1.ProtocolTCP}}
+	}
+	return &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      o.Name,
+			Namespace: o.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: labels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: o.Name,
+					Containers:         []corev1.Container{container},
+				},
+			},
+		},
+	}
+}
+
+// webhookObjects returns the Service and ValidatingWebhookConfiguration of
+// the deletion protection webhook. The caBundle must be set to the CA which
+// signed the webhook server's certificate.
+func (o Options) webhookObjects(labels map[string]string) []runtime.Object {
+	path := ProtectionPath
+	failurePolicy := admissionregistrationv1beta1.Ignore
+	sideEffects := admissionregistrationv1beta1.SideEffectClassNone
+	return []runtime.Object{
+		&corev1.Service{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+			ObjectMeta: metav1.ObjectMeta{Name: o.Name + "-webhook", Namespace: o.Namespace, Labels: labels},
+			Spec: corev1.ServiceSpec{
+				Selector: labels,
+				Ports:    []corev1.ServicePort{{Port: 443, TargetPort: intstr.FromInt(int(o.WebhookPort))}},
+			},
+		},
+		&admissionregistrationv1beta1.ValidatingWebhookConfiguration{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "admissionregistration.k8s.io/v1beta1", Kind: "ValidatingWebhookConfiguration"},
+			ObjectMeta: metav1.ObjectMeta{Name: o.Name + "-protect-referenced-config", Labels: labels},
+			Webhooks: []admissionregistrationv1beta1.Webhook{{
+				Name: "protect-referenced-config.wave.pusher.com",
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+					Service: &admissionregistrationv1beta1.ServiceReference{Name: o.Name + "-webhook", Namespace: o.Namespace, Path: &path},
+				},
+				Rules: []admissionregistrationv1beta1.RuleWithOperations{{
+					Operations: []admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Delete},
+					Rule: admissionregistrationv1beta1.Rule{
+						APIGroups:   []string{""},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"configmaps", "secrets"},
+					},
+				}},
+				FailurePolicy: &failurePolicy,
+				SideEffects:   &sideEffects,
+			}},
+		},
+	}
+}
+
+// Write prints the objects as a stream of YAML documents
+func Write(w io.Writer, objs []runtime.Object) error {
+	buf := &bytes.Buffer{}
+	for i, obj := range objs {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("error marshalling %T: %v", obj, err)
+		}
+		if i > 0 {
+			buf.WriteString("---\n")
+		}
+		buf.Write(data)
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
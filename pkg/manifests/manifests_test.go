@@ -0,0 +1,179 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package manifests
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// permissions flattens rules into "group/resource verb" strings
+func permissions(rules []rbacv1.PolicyRule) map[string]bool {
+	perms := map[string]bool{}
+	for _, rule := range rules {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					perms[group+"/"+resource+" "+verb] = true
+				}
+			}
+		}
+	}
+	return perms
+}
+
+// kinds returns the kinds of the objects
+func kinds(objs []runtime.Object) []string {
+	out := []string{}
+	for _, obj := range objs {
+		out = append(out, obj.GetObjectKind().GroupVersionKind().Kind)
+	}
+	return out
+}
+
+var _ = Describe("Wave manifests Suite", func() {
+	var o Options
+
+	BeforeEach(func() {
+		o = Options{Name: "wave", Namespace: "wave", Image: "wave:test", WebhookPort: 9876}
+	})
+
+	It("grants exactly the permissions of config/rbac with every feature enabled", func() {
+		o.CapacityPods = true
+		o.CapacityNodes = true
+		o.CSISecretsStore = true
+		o.OnDelete = true
+		o.DeletePods = true
+		o.NamespacePriority = true
+		o.BlueGreen = true
+		o.Canaries = true
+		o.RestartHooks = true
+		o.Snapshots = true
+		o.Rollbacks = true
+
+		data, err := ioutil.ReadFile("../../config/rbac/manager_role.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		role := &rbacv1.ClusterRole{}
+		Expect(yaml.Unmarshal(data, role)).To(Succeed())
+
+		// The webhook server's own rules are generated by controller-runtime
+		expected := permissions(role.Rules)
+		for perm := range expected {
+			if strings.HasPrefix(perm, "admissionregistration.k8s.io/") || perm == "/services create" || perm == "/services delete" {
+				delete(expected, perm)
+			}
+		}
+		Expect(permissions(o.Rules())).To(Equal(expected))
+	})
+
+	It("only grants read access in shadow mode", func() {
+		o.OnDelete = true
+		o.ImpersonateServiceAccount = "wave-writer"
+		o.Shadow = true
+		for perm := range permissions(o.Rules()) {
+			Expect(perm).To(Or(HaveSuffix(" get"), HaveSuffix(" list"), HaveSuffix(" watch")))
+		}
+	})
+
+	It("does not grant writes to Secrets with secret metadata only", func() {
+		o.SecretMetadataOnly = true
+		perms := permissions(o.Rules())
+		Expect(perms).To(HaveKey("/secrets watch"))
+		Expect(perms).NotTo(HaveKey("/secrets update"))
+		Expect(perms).To(HaveKey("/configmaps update"))
+	})
+
+	It("only grants access to the kinds of workloads Wave manages", func() {
+		o.Kinds = []string{"StatefulSet", "Deployment"}
+		perms := permissions(o.Rules())
+		Expect(perms).To(HaveKey("apps/deployments patch"))
+		Expect(perms).To(HaveKey("apps/statefulsets watch"))
+		Expect(perms).NotTo(HaveKey("apps/daemonsets list"))
+
+		o.Kinds = []string{"CronJob"}
+		Expect(o.Validate()).NotTo(Succeed())
+	})
+
+	It("limits impersonation to the service account", func() {
+		o.ImpersonateServiceAccount = "wave-writer"
+		Expect(o.Rules()).To(ContainElement(rbacv1.PolicyRule{
+			APIGroups:     []string{""},
+			Resources:     []string{"serviceaccounts"},
+			ResourceNames: []string{"wave-writer"},
+			Verbs:         []string{"impersonate"},
+		}))
+	})
+
+	It("uses a ClusterRole by default", func() {
+		objs, err := o.Objects()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(objs)).To(Equal([]string{"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Deployment"}))
+	})
+
+	It("uses a Role in each namespace with namespaces", func() {
+		o.Namespaces = []string{"team-a", "team-b"}
+		objs, err := o.Objects()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(objs)).To(Equal([]string{"ServiceAccount", "Role", "RoleBinding", "Role", "RoleBinding", "Deployment"}))
+		Expect(objs[3].(*rbacv1.Role).Namespace).To(Equal("team-b"))
+	})
+
+	It("adds leader election and webhook objects", func() {
+		o.LeaderElection = true
+		o.LeaderElectionNamespace = "locks"
+		o.Webhooks = true
+		o.Replicas = 2
+		objs, err := o.Objects()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(kinds(objs)).To(Equal([]string{
+			"ServiceAccount", "ClusterRole", "ClusterRoleBinding", "Role", "RoleBinding",
+			"Deployment", "Service", "ValidatingWebhookConfiguration",
+		}))
+		Expect(objs[3].(*rbacv1.Role).Namespace).To(Equal("locks"))
+		d := objs[5].(*appsv1.Deployment)
+		Expect(*d.Spec.Replicas).To(Equal(int32(2)))
+		Expect(d.Spec.Template.Spec.Containers[0].Ports[0].ContainerPort).To(Equal(int32(9876)))
+	})
+
+	It("rejects options which cannot be deployed", func() {
+		o.Replicas = 2
+		Expect(o.Validate()).NotTo(Succeed())
+
+		o.Replicas = 1
+		o.Namespaces = []string{"team-a"}
+		o.CapacityNodes = true
+		Expect(o.Validate()).NotTo(Succeed())
+	})
+
+	It("writes a stream of YAML documents", func() {
+		objs, err := o.Objects()
+		Expect(err).NotTo(HaveOccurred())
+		buf := &bytes.Buffer{}
+		Expect(Write(buf, objs)).To(Succeed())
+		docs := strings.Split(buf.String(), "---\n")
+		Expect(docs).To(HaveLen(len(objs)))
+		Expect(docs[0]).To(ContainSubstring("kind: ServiceAccount"))
+	})
+})
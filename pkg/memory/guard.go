@@ -0,0 +1,218 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+var (
+	// rssBytes is the resident set size of the process at the last check
+	rssBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wave_memory_rss_bytes",
+		Help: "Resident set size of Wave at the last memory check",
+	})
+
+	// limitBytes is the configured memory limit
+	limitBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wave_memory_limit_bytes",
+		Help: "Memory limit Wave sheds its caches to stay under",
+	})
+
+	// constrained is 1 while the resident set size is over the soft limit
+	constrained = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wave_memory_constrained",
+		Help: "Whether Wave's resident set size is over the soft memory limit (1) or not (0)",
+	})
+
+	// shed counts the entries dropped from each cache to free memory
+	shed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wave_memory_shed_total",
+		Help: "Number of entries dropped from Wave's caches to stay under the memory limit, by cache",
+	}, []string{"cache"})
+
+	// onDemandReads counts the Secrets read from the API server because
+	// their data was dropped from the cache
+	onDemandReads = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wave_memory_on_demand_reads_total",
+		Help: "Number of reads of Secrets whose data was dropped from the cache, served by the API server",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(rssBytes, limitBytes, constrained, shed, onDemandReads)
+}
+
+// Options configures a Guard
+type Options struct {
+	// Limit is the resident set size, in bytes, the process must stay under
+	Limit uint64
+
+	// SoftLimitPercent is the percentage of the Limit above which caches are
+	// shed. It defaults to 90.
+	SoftLimitPercent int
+
+	// Interval is how often the resident set size is checked. It defaults to
+	// 10 seconds.
+	Interval time.Duration
+
+	// LargeSecretBytes is the size of the data above which Secrets are read
+	// on demand while constrained. It defaults to 64KiB.
+	LargeSecretBytes int
+}
+
+// softLimit returns the resident set size above which caches are shed
+func (o Options) softLimit() uint64 {
+	percent := o.SoftLimitPercent
+	if percent <= 0 || percent > 100 {
+		percent = 90
+	}
+	return o.Limit / 100 * uint64(percent)
+}
+
+// Shedder drops entries of a cache to free memory, least recently used first,
+// returning how many it dropped
+type Shedder func() int
+
+// Guard keeps the process under a memory limit: while its resident set size
+// is over the soft limit, the registered caches are shed, least recently used
+// entries first, rather than letting the process be OOMKilled in the middle
+// of a rollout. Shedding only costs Wave information it can rebuild, at the
+// price of more API requests.
+type Guard struct {
+	options Options
+	rss     func() (uint64, error)
+
+	mutex       sync.Mutex
+	shedders    []namedShedder
+	constrained bool
+}
+
+// namedShedder is a Shedder and the cache it sheds, used as metric label
+type namedShedder struct {
+	name string
+	shed Shedder
+}
+
+// NewGuard constructs a Guard enforcing the Options
+func NewGuard(o Options) *Guard {
+	if o.Interval <= 0 {
+		o.Interval = 10 * time.Second
+	}
+	if o.LargeSecretBytes <= 0 {
+		o.LargeSecretBytes = 64 * 1024
+	}
+	limitBytes.Set(float64(o.Limit))
+	return &Guard{options: o, rss: residentSetSize}
+}
+
+// AddShedder registers a Shedder of the named cache
+func (g *Guard) AddShedder(name string, s Shedder) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.shedders = append(g.shedders, namedShedder{name: name, shed: s})
+}
+
+// Constrained returns whether the resident set size was over the soft limit
+// at the last check
+func (g *Guard) Constrained() bool {
+	if g == nil {
+		return false
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	return g.constrained
+}
+
+// Start implements manager.Runnable, checking the resident set size every
+// Interval until stop is closed
+func (g *Guard) Start(stop <-chan struct{}) error {
+	ticker := time.NewTicker(g.options.Interval)
+	defer ticker.Stop()
+	for {
+		g.check()
+		select {
+		case <-stop:
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// check measures the resident set size and sheds every cache while it is
+// over the soft limit
+func (g *Guard) check() {
+	log := logf.Log.WithName("memory")
+	rss, err := g.rss()
+	if err != nil {
+		log.Error(err, "unable to measure the resident set size")
+		return
+	}
+	rssBytes.Set(float64(rss))
+	over := rss >= g.options.softLimit()
+
+	g.mutex.Lock()
+	if over != g.constrained {
+		log.Info("memory constraint changed", "constrained", over, "rss", rss, "limit", g.options.Limit)
+	}
+	g.constrained = over
+	shedders := append([]namedShedder{}, g.shedders...)
+	g.mutex.Unlock()
+
+	if !over {
+		constrained.Set(0)
+		return
+	}
+	constrained.Set(1)
+	for _, s := range shedders {
+		shed.WithLabelValues(s.name).Add(float64(s.shed()))
+	}
+	debug.FreeOSMemory()
+}
+
+// residentSetSize returns the resident set size of the process, falling back
+// to the memory obtained from the OS by the Go runtime where /proc is not
+// available
+func residentSetSize() (uint64, error) {
+	statm, err := ioutil.ReadFile("/proc/self/statm")
+	if err != nil {
+		var stats runtime.MemStats
+		runtime.ReadMemStats(&stats)
+		return stats.Sys, nil
+	}
+	fields := strings.Fields(string(statm))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("unexpected content of /proc/self/statm: %q", statm)
+	}
+	pages, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to parse /proc/self/statm: %v", err)
+	}
+	return pages * uint64(os.Getpagesize()), nil
+}
@@ -0,0 +1,162 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// storeCache serves reads from a store, like the informer cache does
+type storeCache struct {
+	cache.Cache
+	store toolscache.Store
+}
+
+func (c *storeCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	item, _, err := c.store.GetByKey(key.Namespace + "/" + key.Name)
+	if err != nil {
+		return err
+	}
+	item.(*corev1.Secret).DeepCopyInto(obj.(*corev1.Secret))
+	return nil
+}
+
+func (c *storeCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	secrets := list.(*corev1.SecretList)
+	for _, item := range c.store.List() {
+		secrets.Items = append(secrets.Items, *item.(*corev1.Secret).DeepCopy())
+	}
+	return nil
+}
+
+// counterValue returns the value of the counter
+func counterValue(c interface{ Write(*dto.Metric) error }) float64 {
+	m := &dto.Metric{}
+	Expect(c.Write(m)).To(Succeed())
+	return m.GetCounter().GetValue()
+}
+
+var _ = Describe("Wave memory guard Suite", func() {
+	var g *Guard
+	var rss uint64
+
+	BeforeEach(func() {
+		g = NewGuard(Options{Limit: 1000})
+		rss = 500
+		g.rss = func() (uint64, error) { return rss, nil }
+	})
+
+	It("doesn't shed under the soft limit", func() {
+		calls := 0
+		g.AddShedder("test-under", func() int { calls++; return 1 })
+		g.check()
+		Expect(g.Constrained()).To(BeFalse())
+		Expect(calls).To(BeZero())
+	})
+
+	It("sheds every cache over the soft limit", func() {
+		g.AddShedder("test-over", func() int { return 3 })
+		before := counterValue(shed.WithLabelValues("test-over"))
+		rss = 900
+		g.check()
+		Expect(g.Constrained()).To(BeTrue())
+		Expect(counterValue(shed.WithLabelValues("test-over")) - before).To(Equal(3.0))
+
+		rss = 800
+		g.check()
+		Expect(g.Constrained()).To(BeFalse())
+	})
+
+	It("honours the soft limit percentage", func() {
+		g = NewGuard(Options{Limit: 1000, SoftLimitPercent: 50})
+		g.rss = func() (uint64, error) { return 500, nil }
+		g.check()
+		Expect(g.Constrained()).To(BeTrue())
+	})
+
+	It("measures the resident set size", func() {
+		size, err := residentSetSize()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeNumerically(">", 0))
+	})
+
+	Context("with large Secrets", func() {
+		var s *secretCache
+		var store toolscache.Store
+		large := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "large"},
+			Data:       map[string][]byte{"key": []byte(strings.Repeat("x", 100))},
+		}
+		small := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "small"},
+			Data:       map[string][]byte{"key": []byte("x")},
+		}
+
+		BeforeEach(func() {
+			store = toolscache.NewStore(toolscache.MetaNamespaceKeyFunc)
+			Expect(store.Add(large.DeepCopy())).To(Succeed())
+			Expect(store.Add(small.DeepCopy())).To(Succeed())
+			s = &secretCache{
+				Cache:     &storeCache{store: store},
+				reader:    fake.NewFakeClient(large.DeepCopy(), small.DeepCopy()),
+				threshold: 50,
+				store:     func() (toolscache.Store, error) { return store, nil },
+			}
+		})
+
+		It("drops the data of large Secrets only", func() {
+			Expect(s.shed()).To(Equal(1))
+			item, _, _ := store.GetByKey("default/large")
+			Expect(item.(*corev1.Secret).Data).To(BeEmpty())
+			Expect(item.(*corev1.Secret).Annotations).To(HaveKey(OnDemandAnnotation))
+			item, _, _ = store.GetByKey("default/small")
+			Expect(item.(*corev1.Secret).Data).NotTo(BeEmpty())
+
+			Expect(s.shed()).To(BeZero())
+		})
+
+		It("reads the Secrets whose data was dropped on demand", func() {
+			s.shed()
+			before := counterValue(onDemandReads)
+
+			secret := &corev1.Secret{}
+			Expect(s.Get(context.TODO(), client.ObjectKey{Namespace: "default", Name: "large"}, secret)).To(Succeed())
+			Expect(secret.Data).To(Equal(large.Data))
+			Expect(secret.Annotations).NotTo(HaveKey(OnDemandAnnotation))
+
+			secrets := &corev1.SecretList{}
+			Expect(s.List(context.TODO(), secrets)).To(Succeed())
+			Expect(secrets.Items).To(HaveLen(2))
+			for _, item := range secrets.Items {
+				Expect(item.Data).NotTo(BeEmpty())
+			}
+			Expect(counterValue(onDemandReads) - before).To(Equal(2.0))
+		})
+	})
+})
@@ -0,0 +1,170 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package memory
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// OnDemandAnnotation marks the Secrets of the cache whose data was dropped
+// while constrained. It is never written to the API server.
+const OnDemandAnnotation = "wave.pusher.com/on-demand"
+
+// CacheBuilder returns a NewCacheFunc wrapping the Cache built by newCache
+// (cache.New if nil): while the Guard is constrained, the data of large
+// Secrets is dropped from the cache, and reads of these Secrets are served by
+// the API server instead. It is meant to be used as manager.Options.NewCache,
+// and not with a cache watching the metadata of Secrets only.
+func CacheBuilder(newCache cache.NewCacheFunc, g *Guard) cache.NewCacheFunc {
+	if newCache == nil {
+		newCache = cache.New
+	}
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		c, err := newCache(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		reader, err := client.New(config, client.Options{Scheme: opts.Scheme, Mapper: opts.Mapper})
+		if err != nil {
+			return nil, err
+		}
+		s := &secretCache{Cache: c, reader: reader, threshold: g.options.LargeSecretBytes}
+		s.store = s.informerStore
+		g.AddShedder("secrets", s.shed)
+		return s, nil
+	}
+}
+
+// secretCache reads the Secrets whose data was dropped from the cache from
+// the API server
+type secretCache struct {
+	cache.Cache
+	reader    client.Reader
+	threshold int
+	store     func() (toolscache.Store, error)
+}
+
+// Get implements client.Reader
+func (s *secretCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	if err := s.Cache.Get(ctx, key, obj); err != nil {
+		return err
+	}
+	secret, ok := obj.(*corev1.Secret)
+	if !ok || !onDemand(secret) {
+		return nil
+	}
+	// Decoding merges into maps, reset the Secret to drop the marker
+	*secret = corev1.Secret{}
+	onDemandReads.Inc()
+	return s.reader.Get(ctx, key, obj)
+}
+
+// List implements client.Reader
+func (s *secretCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	if err := s.Cache.List(ctx, list, opts...); err != nil {
+		return err
+	}
+	secrets, ok := list.(*corev1.SecretList)
+	if !ok {
+		return nil
+	}
+	for i := range secrets.Items {
+		secret := &secrets.Items[i]
+		if !onDemand(secret) {
+			continue
+		}
+		onDemandReads.Inc()
+		key := client.ObjectKey{Namespace: secret.Namespace, Name: secret.Name}
+		*secret = corev1.Secret{}
+		if err := s.reader.Get(ctx, key, secret); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shed drops the data of the large Secrets of the cache, marking them to be
+// read on demand until the watch next updates them
+func (s *secretCache) shed() int {
+	store, err := s.store()
+	if err != nil {
+		logf.Log.WithName("memory").Error(err, "unable to shed the data of cached Secrets")
+		return 0
+	}
+	shed := 0
+	for _, item := range store.List() {
+		secret, ok := item.(*corev1.Secret)
+		if !ok || onDemand(secret) || dataSize(secret) <= s.threshold {
+			continue
+		}
+		// Objects of the store are shared, replace rather than modify them
+		stripped := secret.DeepCopy()
+		stripped.Data = nil
+		stripped.StringData = nil
+		annotations := make(map[string]string, len(secret.Annotations)+1)
+		for k, v := range secret.Annotations {
+			annotations[k] = v
+		}
+		annotations[OnDemandAnnotation] = "true"
+		stripped.Annotations = annotations
+		if err := store.Update(stripped); err != nil {
+			continue
+		}
+		shed++
+	}
+	return shed
+}
+
+// informerStore returns the store of the Secret informer of the cache
+func (s *secretCache) informerStore() (toolscache.Store, error) {
+	informer, err := s.Cache.GetInformer(&corev1.Secret{})
+	if err != nil {
+		return nil, err
+	}
+	indexer, ok := informer.(interface{ GetStore() toolscache.Store })
+	if !ok {
+		return nil, fmt.Errorf("the Secret informer of the cache doesn't expose its store")
+	}
+	return indexer.GetStore(), nil
+}
+
+// onDemand returns whether the data of the cached Secret was dropped
+func onDemand(secret *corev1.Secret) bool {
+	_, ok := secret.Annotations[OnDemandAnnotation]
+	return ok
+}
+
+// dataSize returns the size of the data of the Secret
+func dataSize(secret *corev1.Secret) int {
+	size := 0
+	for k, v := range secret.Data {
+		size += len(k) + len(v)
+	}
+	for k, v := range secret.StringData {
+		size += len(k) + len(v)
+	}
+	return size
+}
@@ -0,0 +1,323 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1beta1 "k8s.io/apimachinery/pkg/apis/meta/v1beta1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// listContentType asks the API server to return lists of Secrets as
+	// lists of their metadata
+	listContentType = "application/json;as=PartialObjectMetadataList;g=meta.k8s.io;v=v1beta1"
+
+	// watchContentType asks the API server to return watch events for
+	// Secrets containing only their metadata
+	watchContentType = "application/json;as=PartialObjectMetadata;g=meta.k8s.io;v=v1beta1"
+)
+
+var secretGVK = corev1.SchemeGroupVersion.WithKind("Secret")
+
+// NewSecretCache builds the default informer cache of controller-runtime,
+// except that Secrets are watched through a metadata-only informer: Secrets
+// read or listed from the cache, and Secrets passed to event handlers, only
+// have their ObjectMeta set.
+//
+// It is meant to be used as manager.Options.NewCache.
+func NewSecretCache(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+	return newSecretCache(config, opts, cache.New, []string{opts.Namespace})
+}
+
+// MultiNamespacedSecretCacheBuilder returns a NewCacheFunc which behaves like
+// NewSecretCache, restricted to the given namespaces
+func MultiNamespacedSecretCacheBuilder(namespaces []string) cache.NewCacheFunc {
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		return newSecretCache(config, opts, cache.MultiNamespacedCacheBuilder(namespaces), namespaces)
+	}
+}
+
+// newSecretCache wraps the Cache built by newCache with one metadata-only
+// Secret informer for each namespace
+func newSecretCache(config *rest.Config, opts cache.Options, newCache cache.NewCacheFunc, namespaces []string) (cache.Cache, error) {
+	c, err := newCache(config, opts)
+	if err != nil {
+		return nil, err
+	}
+	resync := 10 * time.Hour
+	if opts.Resync != nil {
+		resync = *opts.Resync
+	}
+	secrets := make(informers)
+	for _, namespace := range namespaces {
+		lw, err := newListWatch(config, namespace)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Secret metadata client: %v", err)
+		}
+		secrets[namespace] = toolscache.NewSharedIndexInformer(lw, &corev1.Secret{}, resync, toolscache.Indexers{
+			toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+		})
+	}
+	return &secretCache{Cache: c, secrets: secrets}, nil
+}
+
+// secretCache serves Secrets from metadata-only informers and everything
+// else from the wrapped Cache
+type secretCache struct {
+	cache.Cache
+	secrets informers
+}
+
+// informers holds the metadata-only Secret informers of a secretCache, keyed
+// on the namespace they watch, or the empty string for all namespaces.
+// It fans event handlers out to every informer.
+type informers map[string]toolscache.SharedIndexInformer
+
+// forNamespace returns the informer watching the namespace, if any
+func (i informers) forNamespace(namespace string) (toolscache.SharedIndexInformer, bool) {
+	if informer, ok := i[""]; ok {
+		return informer, true
+	}
+	informer, ok := i[namespace]
+	return informer, ok
+}
+
+// AddEventHandler implements cache.Informer
+func (i informers) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	for _, informer := range i {
+		informer.AddEventHandler(handler)
+	}
+}
+
+// AddEventHandlerWithResyncPeriod implements cache.Informer
+func (i informers) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range i {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+// AddIndexers implements cache.Informer
+func (i informers) AddIndexers(indexers toolscache.Indexers) error {
+	for _, informer := range i {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasSynced implements cache.Informer
+func (i informers) HasSynced() bool {
+	for _, informer := range i {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// Get implements client.Reader
+func (c *secretCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	secret, ok := obj.(*corev1.Secret)
+	if !ok {
+		return c.Cache.Get(ctx, key, obj)
+	}
+	informer, ok := c.secrets.forNamespace(key.Namespace)
+	if !ok {
+		return errors.NewNotFound(corev1.Resource("secrets"), key.Name)
+	}
+	item, exists, err := informer.GetIndexer().GetByKey(key.String())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.NewNotFound(corev1.Resource("secrets"), key.Name)
+	}
+	item.(*corev1.Secret).DeepCopyInto(secret)
+	return nil
+}
+
+// List implements client.Reader
+func (c *secretCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	secrets, ok := list.(*corev1.SecretList)
+	if !ok {
+		return c.Cache.List(ctx, list, opts...)
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	var items []interface{}
+	if listOpts.Namespace != "" {
+		if informer, ok := c.secrets.forNamespace(listOpts.Namespace); ok {
+			var err error
+			items, err = informer.GetIndexer().ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, informer := range c.secrets {
+			items = append(items, informer.GetIndexer().List()...)
+		}
+	}
+
+	secrets.Items = []corev1.Secret{}
+	for _, item := range items {
+		secret := item.(*corev1.Secret)
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(secret.GetLabels())) {
+			continue
+		}
+		secrets.Items = append(secrets.Items, *secret.DeepCopy())
+	}
+	return nil
+}
+
+// GetInformer implements cache.Informers
+func (c *secretCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return c.secrets, nil
+	}
+	return c.Cache.GetInformer(obj)
+}
+
+// GetInformerForKind implements cache.Informers
+func (c *secretCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	if gvk == secretGVK {
+		return c.secrets, nil
+	}
+	return c.Cache.GetInformerForKind(gvk)
+}
+
+// Start implements cache.Informers
+func (c *secretCache) Start(stop <-chan struct{}) error {
+	for _, informer := range c.secrets {
+		go informer.Run(stop)
+	}
+	return c.Cache.Start(stop)
+}
+
+// WaitForCacheSync implements cache.Informers
+func (c *secretCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	if !toolscache.WaitForCacheSync(stop, c.secrets.HasSynced) {
+		return false
+	}
+	return c.Cache.WaitForCacheSync(stop)
+}
+
+// IndexField implements client.FieldIndexer
+func (c *secretCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	if _, ok := obj.(*corev1.Secret); ok {
+		return fmt.Errorf("field indexes are not supported for Secret metadata")
+	}
+	return c.Cache.IndexField(obj, field, extractValue)
+}
+
+// newListWatch creates a ListWatch which lists and watches the metadata of
+// Secrets in the given namespace, or all namespaces if it is empty
+func newListWatch(config *rest.Config, namespace string) (*toolscache.ListWatch, error) {
+	scheme := runtime.NewScheme()
+	metav1.AddToGroupVersion(scheme, schema.GroupVersion{Version: "v1"})
+	scheme.AddKnownTypes(metav1beta1.SchemeGroupVersion, &metav1beta1.PartialObjectMetadata{})
+
+	cfg := rest.CopyConfig(config)
+	cfg.APIPath = "/api"
+	cfg.GroupVersion = &corev1.SchemeGroupVersion
+	cfg.NegotiatedSerializer = serializer.DirectCodecFactory{CodecFactory: serializer.NewCodecFactory(scheme)}
+	restClient, err := rest.RESTClientFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &toolscache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			body, err := restClient.Get().
+				SetHeader("Accept", listContentType).
+				NamespaceIfScoped(namespace, namespace != "").
+				Resource("secrets").
+				VersionedParams(&opts, metav1.ParameterCodec).
+				Do().
+				Raw()
+			if err != nil {
+				return nil, err
+			}
+			list := &partialObjectMetadataList{}
+			if err := json.Unmarshal(body, list); err != nil {
+				return nil, fmt.Errorf("error decoding Secret metadata: %v", err)
+			}
+			return toSecretList(list), nil
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			opts.Watch = true
+			w, err := restClient.Get().
+				SetHeader("Accept", watchContentType).
+				NamespaceIfScoped(namespace, namespace != "").
+				Resource("secrets").
+				VersionedParams(&opts, metav1.ParameterCodec).
+				Watch()
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, func(in watch.Event) (watch.Event, bool) {
+				if m, ok := in.Object.(*metav1beta1.PartialObjectMetadata); ok {
+					in.Object = toSecret(m)
+				}
+				return in, true
+			}), nil
+		},
+	}, nil
+}
+
+// partialObjectMetadataList mirrors metav1beta1.PartialObjectMetadataList,
+// which lacks the ListMeta the informer needs in this version of apimachinery
+type partialObjectMetadataList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []metav1beta1.PartialObjectMetadata `json:"items"`
+}
+
+// toSecretList converts a list of Secret metadata to a SecretList
+func toSecretList(list *partialObjectMetadataList) *corev1.SecretList {
+	secrets := &corev1.SecretList{ListMeta: list.ListMeta}
+	for i := range list.Items {
+		secrets.Items = append(secrets.Items, *toSecret(&list.Items[i]))
+	}
+	return secrets
+}
+
+// toSecret converts the metadata of a Secret to a Secret without data
+func toSecret(m *metav1beta1.PartialObjectMetadata) *corev1.Secret {
+	return &corev1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: m.ObjectMeta,
+	}
+}
@@ -0,0 +1,122 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package metadata
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+var _ = Describe("Wave metadata Suite", func() {
+	Context("newListWatch", func() {
+		var server *httptest.Server
+		var accept string
+
+		BeforeEach(func() {
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				accept = r.Header.Get("Accept")
+				Expect(r.URL.Path).To(Equal("/api/v1/namespaces/default/secrets"))
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"kind":"PartialObjectMetadataList","apiVersion":"meta.k8s.io/v1beta1","metadata":{"resourceVersion":"10"},"items":[{"metadata":{"namespace":"default","name":"example","resourceVersion":"9"}}]}`))
+			}))
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("lists the metadata of Secrets as Secrets without data", func() {
+			lw, err := newListWatch(&rest.Config{Host: server.URL}, "default")
+			Expect(err).NotTo(HaveOccurred())
+
+			obj, err := lw.List(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(accept).To(Equal(listContentType))
+
+			list := obj.(*corev1.SecretList)
+			Expect(list.ResourceVersion).To(Equal("10"))
+			Expect(list.Items).To(HaveLen(1))
+			Expect(list.Items[0].Name).To(Equal("example"))
+			Expect(list.Items[0].ResourceVersion).To(Equal("9"))
+			Expect(list.Items[0].Data).To(BeNil())
+		})
+	})
+
+	Context("secretCache", func() {
+		var c *secretCache
+
+		BeforeEach(func() {
+			informer := toolscache.NewSharedIndexInformer(&toolscache.ListWatch{}, &corev1.Secret{}, 0, toolscache.Indexers{
+				toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+			})
+			for _, s := range []*corev1.Secret{
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", Labels: map[string]string{"app": "example"}}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"}},
+				{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "example"}},
+			} {
+				Expect(informer.GetIndexer().Add(s)).To(Succeed())
+			}
+			c = &secretCache{secrets: informers{"": informer}}
+		})
+
+		It("gets Secrets from the metadata informer", func() {
+			s := &corev1.Secret{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, s)).To(Succeed())
+			Expect(s.Labels).To(HaveKeyWithValue("app", "example"))
+
+			err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "missing"}, s)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("lists Secrets by namespace and labels", func() {
+			list := &corev1.SecretList{}
+			Expect(c.List(context.TODO(), list, client.InNamespace("default"))).To(Succeed())
+			Expect(list.Items).To(HaveLen(2))
+
+			Expect(c.List(context.TODO(), list, client.MatchingLabels{"app": "example"})).To(Succeed())
+			Expect(list.Items).To(HaveLen(1))
+			Expect(list.Items[0].Namespace).To(Equal("default"))
+		})
+
+		It("returns the metadata informer for Secrets", func() {
+			informer, err := c.GetInformer(&corev1.Secret{})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(informer).To(Equal(c.secrets))
+		})
+
+		It("only serves Secrets from the namespaces it watches", func() {
+			c.secrets = informers{"other": c.secrets[""]}
+			list := &corev1.SecretList{}
+			Expect(c.List(context.TODO(), list, client.InNamespace("default"))).To(Succeed())
+			Expect(list.Items).To(BeEmpty())
+
+			err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, &corev1.Secret{})
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+	})
+})
@@ -0,0 +1,149 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	"github.com/ghodss/yaml"
+)
+
+// Config describes the notification providers Wave should construct and
+// which namespaces' Events are routed to each of them
+type Config struct {
+	Providers []ProviderConfig `json:"providers"`
+	Routes    []RouteConfig    `json:"routes"`
+}
+
+// ProviderConfig configures a single instance of a provider type.
+// Option values may reference environment variables as $VAR or ${VAR} so
+// that credentials need not be stored in the configuration file.
+type ProviderConfig struct {
+	Name    string            `json:"name"`
+	Type    string            `json:"type"`
+	Options map[string]string `json:"options"`
+}
+
+// RouteConfig sends the Events from workloads in matching namespaces to the
+// named providers.
+// Namespaces may contain shell glob patterns. A route with no namespaces
+// matches every namespace.
+type RouteConfig struct {
+	Namespaces []string `json:"namespaces"`
+	Providers  []string `json:"providers"`
+}
+
+// LoadConfig reads a notification Config from a YAML or JSON file
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error reading notification config: %v", err)
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("error parsing notification config: %v", err)
+	}
+	return config, nil
+}
+
+// route is a RouteConfig with its providers resolved
+type route struct {
+	namespaces []string
+	notifiers  []string
+}
+
+// Router is a Notifier that sends each Event to the providers whose routes
+// match the Event's namespace
+type Router struct {
+	notifiers map[string]Notifier
+	routes    []route
+}
+
+// NewRouter constructs the providers described by the Config and returns a
+// Router that sends Events to them
+func NewRouter(config *Config) (*Router, error) {
+	r := &Router{notifiers: make(map[string]Notifier)}
+	for _, provider := range config.Providers {
+		if provider.Name == "" {
+			return nil, fmt.Errorf("provider of type %q has no name", provider.Type)
+		}
+		if _, exists := r.notifiers[provider.Name]; exists {
+			return nil, fmt.Errorf("provider %q is defined more than once", provider.Name)
+		}
+		options := make(map[string]string)
+		for key, value := range provider.Options {
+			options[key] = os.ExpandEnv(value)
+		}
+		n, err := newProvider(provider.Type, options)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring provider %q: %v", provider.Name, err)
+		}
+		r.notifiers[provider.Name] = n
+	}
+
+	for _, rc := range config.Routes {
+		for _, name := range rc.Providers {
+			if _, ok := r.notifiers[name]; !ok {
+				return nil, fmt.Errorf("route references unknown provider %q", name)
+			}
+		}
+		for _, pattern := range rc.Namespaces {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return nil, fmt.Errorf("invalid namespace pattern %q: %v", pattern, err)
+			}
+		}
+		r.routes = append(r.routes, route{namespaces: rc.Namespaces, notifiers: rc.Providers})
+	}
+	return r, nil
+}
+
+// Notify sends the Event to every provider with a route matching the Event's
+// namespace. Each provider receives the Event at most once.
+func (r *Router) Notify(ctx context.Context, event Event) error {
+	var selected Multi
+	seen := make(map[string]struct{})
+	for _, rt := range r.routes {
+		if !rt.matches(event.Namespace) {
+			continue
+		}
+		for _, name := range rt.notifiers {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			selected = append(selected, r.notifiers[name])
+		}
+	}
+	return selected.Notify(ctx, event)
+}
+
+// matches returns true if the route applies to the namespace
+func (rt route) matches(namespace string) bool {
+	if len(rt.namespaces) == 0 {
+		return true
+	}
+	for _, pattern := range rt.namespaces {
+		if ok, _ := path.Match(pattern, namespace); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+// recorder is a Notifier that records the Events it receives
+type recorder struct {
+	options map[string]string
+	events  []Event
+}
+
+func (r *recorder) Notify(ctx context.Context, event Event) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+var _ = Describe("Wave notification config Suite", func() {
+	var recorders map[string]*recorder
+
+	BeforeEach(func() {
+		recorders = make(map[string]*recorder)
+		Register("test-recorder", func(options map[string]string) (Notifier, error) {
+			r := &recorder{options: options}
+			recorders[options["id"]] = r
+			return r, nil
+		})
+	})
+
+	Context("LoadConfig", func() {
+		It("parses providers and routes from YAML", func() {
+			dir, err := ioutil.TempDir("", "wave-notify")
+			Expect(err).NotTo(HaveOccurred())
+			defer os.RemoveAll(dir)
+
+			filename := filepath.Join(dir, "config.yaml")
+			Expect(ioutil.WriteFile(filename, []byte(`
+providers:
+- name: slack-prod
+  type: slack
+  options:
+    url: https://hooks.slack.com/services/example
+routes:
+- namespaces: ["prod-*"]
+  providers: ["slack-prod"]
+`), 0600)).To(Succeed())
+
+			config, err := LoadConfig(filename)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.Providers).To(Equal([]ProviderConfig{
+				{Name: "slack-prod", Type: "slack", Options: map[string]string{"url": "https://hooks.slack.com/services/example"}},
+			}))
+			Expect(config.Routes).To(Equal([]RouteConfig{
+				{Namespaces: []string{"prod-*"}, Providers: []string{"slack-prod"}},
+			}))
+		})
+
+		It("returns an error when the file does not exist", func() {
+			_, err := LoadConfig("/does/not/exist")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("NewRouter", func() {
+		It("returns an error for unknown provider types", func() {
+			_, err := NewRouter(&Config{Providers: []ProviderConfig{{Name: "a", Type: "carrier-pigeon"}}})
+			Expect(err).To(MatchError(ContainSubstring("unknown provider type")))
+		})
+
+		It("returns an error for routes to unknown providers", func() {
+			_, err := NewRouter(&Config{Routes: []RouteConfig{{Providers: []string{"missing"}}}})
+			Expect(err).To(MatchError(ContainSubstring("unknown provider")))
+		})
+
+		It("returns an error for duplicate provider names", func() {
+			_, err := NewRouter(&Config{Providers: []ProviderConfig{
+				{Name: "a", Type: "test-recorder"},
+				{Name: "a", Type: "test-recorder"},
+			}})
+			Expect(err).To(MatchError(ContainSubstring("more than once")))
+		})
+
+		It("expands environment variables in options", func() {
+			os.Setenv("WAVE_NOTIFY_TEST", "expanded")
+			defer os.Unsetenv("WAVE_NOTIFY_TEST")
+			_, err := NewRouter(&Config{Providers: []ProviderConfig{
+				{Name: "a", Type: "test-recorder", Options: map[string]string{"id": "a", "secret": "${WAVE_NOTIFY_TEST}"}},
+			}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorders["a"].options["secret"]).To(Equal("expanded"))
+		})
+	})
+
+	Context("Router", func() {
+		var router *Router
+
+		BeforeEach(func() {
+			var err error
+			router, err = NewRouter(&Config{
+				Providers: []ProviderConfig{
+					{Name: "prod", Type: "test-recorder", Options: map[string]string{"id": "prod"}},
+					{Name: "all", Type: "test-recorder", Options: map[string]string{"id": "all"}},
+				},
+				Routes: []RouteConfig{
+					{Namespaces: []string{"prod-*", "payments"}, Providers: []string{"prod", "all"}},
+					{Providers: []string{"all"}},
+				},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("sends events to providers with a matching namespace pattern", func() {
+			Expect(router.Notify(context.TODO(), Event{Namespace: "prod-eu"})).To(Succeed())
+			Expect(recorders["prod"].events).To(HaveLen(1))
+		})
+
+		It("does not send events to providers with no matching route", func() {
+			Expect(router.Notify(context.TODO(), Event{Namespace: "dev"})).To(Succeed())
+			Expect(recorders["prod"].events).To(BeEmpty())
+			Expect(recorders["all"].events).To(HaveLen(1))
+		})
+
+		It("sends each event to a provider at most once", func() {
+			Expect(router.Notify(context.TODO(), Event{Namespace: "payments"})).To(Succeed())
+			Expect(recorders["all"].events).To(HaveLen(1))
+		})
+	})
+
+	Context("Multi", func() {
+		It("returns the errors of failing notifiers after notifying all of them", func() {
+			r := &recorder{}
+			failing := notifierFunc(func(ctx context.Context, event Event) error {
+				return errors.New("failed")
+			})
+			err := Multi{failing, r}.Notify(context.TODO(), Event{})
+			Expect(err).To(MatchError(ContainSubstring("failed")))
+			Expect(r.events).To(HaveLen(1))
+		})
+	})
+})
+
+// notifierFunc adapts a function to the Notifier interface
+type notifierFunc func(ctx context.Context, event Event) error
+
+func (f notifierFunc) Notify(ctx context.Context, event Event) error {
+	return f(ctx, event)
+}
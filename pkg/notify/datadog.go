@@ -0,0 +1,150 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDatadogSite is the Datadog site events are sent to when no other
+	// site is configured
+	DefaultDatadogSite = "datadoghq.com"
+
+	datadogAPIKeyHeader = "DD-API-KEY"
+)
+
+// Datadog posts Events to the Datadog Events API
+type Datadog struct {
+	apiKey   string
+	endpoint string
+	tags     []string
+	client   *http.Client
+}
+
+// datadogEvent is the body of a request to the Datadog Events API
+type datadogEvent struct {
+	Title          string   `json:"title"`
+	Text           string   `json:"text"`
+	Tags           []string `json:"tags"`
+	AlertType      string   `json:"alert_type"`
+	AggregationKey string   `json:"aggregation_key"`
+	SourceTypeName string   `json:"source_type_name"`
+	DateHappened   int64    `json:"date_happened"`
+}
+
+// NewDatadog constructs a Datadog notifier that sends events to the given
+// Datadog site, adding tags to every event it sends
+func NewDatadog(apiKey, site string, tags []string) *Datadog {
+	if site == "" {
+		site = DefaultDatadogSite
+	}
+	return &Datadog{
+		apiKey:   apiKey,
+		endpoint: fmt.Sprintf("https://api.%s/api/v1/events", site),
+		tags:     tags,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// newDatadogFromOptions constructs a Datadog notifier from provider options
+func newDatadogFromOptions(options map[string]string) (Notifier, error) {
+	apiKey, err := requireOption(options, "apiKey")
+	if err != nil {
+		return nil, err
+	}
+	var tags []string
+	if options["tags"] != "" {
+		tags = strings.Split(options["tags"], ",")
+	}
+	return NewDatadog(apiKey, options["site"], tags), nil
+}
+
+// Notify sends the Event to Datadog
+func (d *Datadog) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(d.buildEvent(event))
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+
+	headers := map[string]string{datadogAPIKeyHeader: d.apiKey}
+	if err := postJSON(ctx, d.client, d.endpoint, body, headers); err != nil {
+		return fmt.Errorf("error sending event to Datadog: %v", err)
+	}
+	return nil
+}
+
+// buildEvent converts the Event into the format expected by Datadog
+func (d *Datadog) buildEvent(event Event) datadogEvent {
+	tags := append([]string{}, d.tags...)
+	tags = append(tags,
+		fmt.Sprintf("kube_namespace:%s", event.Namespace),
+		fmt.Sprintf("%s:%s", datadogKindTag(event.Kind), event.Name),
+		fmt.Sprintf("wave_event:%s", strings.ToLower(string(event.Type))),
+	)
+	for _, source := range event.Sources {
+		tags = append(tags, fmt.Sprintf("wave_source:%s", strings.ToLower(source)))
+	}
+
+	alertType := "info"
+	title := fmt.Sprintf("Wave triggered a rollout of %s %s/%s", event.Kind, event.Namespace, event.Name)
+	if event.Type == EventSkipped {
+		alertType = "warning"
+		title = fmt.Sprintf("Wave skipped a rollout of %s %s/%s", event.Kind, event.Namespace, event.Name)
+	}
+
+	text := fmt.Sprintf("Configuration hash: %s", event.Hash)
+	if len(event.Sources) > 0 {
+		text = fmt.Sprintf("%s\nChanged: %s", text, strings.Join(event.Sources, ", "))
+	}
+	if len(event.Keys) > 0 {
+		text = fmt.Sprintf("%s\nKeys: %s", text, DescribeKeys(event.Keys))
+	}
+	if event.Reason != "" {
+		text = fmt.Sprintf("%s\nReason: %s", text, event.Reason)
+	}
+
+	return datadogEvent{
+		Title:          title,
+		Text:           text,
+		Tags:           tags,
+		AlertType:      alertType,
+		AggregationKey: fmt.Sprintf("wave/%s/%s/%s", event.Kind, event.Namespace, event.Name),
+		SourceTypeName: "kubernetes",
+		DateHappened:   event.Time.Unix(),
+	}
+}
+
+// datadogKindTag returns the tag name the Datadog Kubernetes integration uses
+// for the given workload kind
+func datadogKindTag(kind string) string {
+	switch kind {
+	case "Deployment":
+		return "kube_deployment"
+	case "StatefulSet":
+		return "kube_stateful_set"
+	case "DaemonSet":
+		return "kube_daemon_set"
+	default:
+		return "kube_" + strings.ToLower(kind)
+	}
+}
@@ -0,0 +1,112 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wave Datadog Suite", func() {
+	var server *httptest.Server
+	var received chan *http.Request
+	var bodies chan datadogEvent
+	var status int
+	var d *Datadog
+
+	var event = Event{
+		Type:      EventTriggered,
+		Time:      time.Unix(1500000000, 0),
+		Namespace: "default",
+		Kind:      "Deployment",
+		Name:      "example",
+		Hash:      "abc123",
+		Sources:   []string{"ConfigMap/example1", "Secret/example2"},
+	}
+
+	BeforeEach(func() {
+		received = make(chan *http.Request, 1)
+		bodies = make(chan datadogEvent, 1)
+		status = http.StatusAccepted
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ddEvent := datadogEvent{}
+			Expect(json.NewDecoder(r.Body).Decode(&ddEvent)).To(Succeed())
+			received <- r
+			bodies <- ddEvent
+			w.WriteHeader(status)
+		}))
+
+		d = NewDatadog("api-key", "", []string{"env:test"})
+		d.endpoint = server.URL
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("defaults to the datadoghq.com site", func() {
+		Expect(NewDatadog("api-key", "", nil).endpoint).To(Equal("https://api.datadoghq.com/api/v1/events"))
+	})
+
+	It("uses the configured site", func() {
+		Expect(NewDatadog("api-key", "datadoghq.eu", nil).endpoint).To(Equal("https://api.datadoghq.eu/api/v1/events"))
+	})
+
+	It("sends the API key in the request headers", func() {
+		Expect(d.Notify(context.TODO(), event)).To(Succeed())
+		req := <-received
+		Expect(req.Method).To(Equal(http.MethodPost))
+		Expect(req.Header.Get(datadogAPIKeyHeader)).To(Equal("api-key"))
+	})
+
+	It("tags the event with the namespace, workload and triggering resources", func() {
+		Expect(d.Notify(context.TODO(), event)).To(Succeed())
+		ddEvent := <-bodies
+		Expect(ddEvent.Tags).To(ConsistOf(
+			"env:test",
+			"kube_namespace:default",
+			"kube_deployment:example",
+			"wave_event:triggered",
+			"wave_source:configmap/example1",
+			"wave_source:secret/example2",
+		))
+		Expect(ddEvent.AlertType).To(Equal("info"))
+		Expect(ddEvent.DateHappened).To(Equal(int64(1500000000)))
+	})
+
+	It("sends skipped events as warnings", func() {
+		skipped := event
+		skipped.Type = EventSkipped
+		skipped.Reason = "update failed"
+		Expect(d.Notify(context.TODO(), skipped)).To(Succeed())
+		ddEvent := <-bodies
+		Expect(ddEvent.AlertType).To(Equal("warning"))
+		Expect(ddEvent.Tags).To(ContainElement("wave_event:skipped"))
+		Expect(ddEvent.Text).To(ContainSubstring("update failed"))
+	})
+
+	It("returns an error when Datadog rejects the event", func() {
+		status = http.StatusForbidden
+		Expect(d.Notify(context.TODO(), event)).NotTo(Succeed())
+	})
+})
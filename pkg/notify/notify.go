@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package notify contains the notification providers Wave uses to report
+rollouts it triggers (or decides not to trigger) to external systems
+*/
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// EventType describes the decision Wave made about a rollout
+type EventType string
+
+const (
+	// EventTriggered is sent when Wave updates the configuration hash of a
+	// workload, causing a rollout
+	EventTriggered EventType = "Triggered"
+
+	// EventSkipped is sent when Wave calculated a new configuration hash for a
+	// workload but did not apply it
+	EventSkipped EventType = "Skipped"
+)
+
+// Event contains the details of a single rollout decision
+type Event struct {
+	Type      EventType `json:"type"`
+	Time      time.Time `json:"time"`
+	Namespace string    `json:"namespace"`
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Reason    string    `json:"reason,omitempty"`
+
+	// Sources contains the ConfigMaps and Secrets whose changes caused the
+	// decision, formatted as "<kind>/<name>"
+	Sources []string `json:"sources,omitempty"`
+
+	// Keys contains the keys of the ConfigMaps and Secrets that changed, if
+	// Wave recorded the keys the workload was last rolled out with
+	Keys []KeyChange `json:"keys,omitempty"`
+}
+
+// KeyChange describes a key of a ConfigMap or Secret that was added, removed
+// or modified. Values are never included.
+type KeyChange struct {
+	// Source is the ConfigMap or Secret, formatted as "<kind>/<name>"
+	Source string `json:"source"`
+	Key    string `json:"key"`
+	Change string `json:"change"`
+}
+
+// maxDescribedKeys is the number of keys named by DescribeKeys, keeping
+// messages within the limits of the systems they are sent to
+const maxDescribedKeys = 10
+
+// DescribeKeys returns a short description of the changed keys, naming at
+// most the first maxDescribedKeys, followed by the number of keys added,
+// removed and modified
+func DescribeKeys(keys []KeyChange) string {
+	counts := make(map[string]int)
+	names := []string{}
+	for i, key := range keys {
+		counts[key.Change]++
+		if i < maxDescribedKeys {
+			names = append(names, fmt.Sprintf("%s[%s] %s", key.Source, key.Key, key.Change))
+		}
+	}
+	if len(keys) > maxDescribedKeys {
+		names = append(names, fmt.Sprintf("and %d more", len(keys)-maxDescribedKeys))
+	}
+	return fmt.Sprintf("%s (%d added, %d removed, %d modified)", strings.Join(names, ", "), counts["added"], counts["removed"], counts["modified"])
+}
+
+// Notifier sends Events to an external system
+type Notifier interface {
+	Notify(ctx context.Context, event Event) error
+}
+
+// Multi is a Notifier that sends each Event to all of the Notifiers it
+// contains
+type Multi []Notifier
+
+// Notify sends the Event to every Notifier, returning the errors from any
+// that failed
+func (m Multi) Notify(ctx context.Context, event Event) error {
+	var errs []string
+	for _, n := range m {
+		if err := n.Notify(ctx, event); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("error(s) encountered sending notifications: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// summary returns a short, human readable description of the Event
+func summary(event Event) string {
+	verb := "triggered"
+	if event.Type == EventSkipped {
+		verb = "skipped"
+	}
+	text := fmt.Sprintf("Wave %s a rollout of %s %s/%s (hash %s)", verb, event.Kind, event.Namespace, event.Name, event.Hash)
+	if len(event.Sources) > 0 {
+		text = fmt.Sprintf("%s, changed: %s", text, strings.Join(event.Sources, ", "))
+	}
+	if len(event.Keys) > 0 {
+		text = fmt.Sprintf("%s, keys: %s", text, DescribeKeys(event.Keys))
+	}
+	if event.Reason != "" {
+		text = fmt.Sprintf("%s, reason: %s", text, event.Reason)
+	}
+	return text
+}
@@ -0,0 +1,167 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/sigv4"
+)
+
+var _ = Describe("Wave notification providers Suite", func() {
+	var server *httptest.Server
+	var requests chan *http.Request
+	var bodies chan []byte
+
+	var event = Event{
+		Type:      EventTriggered,
+		Time:      time.Unix(1500000000, 0).UTC(),
+		Namespace: "default",
+		Kind:      "Deployment",
+		Name:      "example",
+		Hash:      "abc123",
+		Sources:   []string{"ConfigMap/example1"},
+	}
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 1)
+		bodies = make(chan []byte, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+			requests <- r
+			bodies <- body
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("Webhook", func() {
+		It("posts the event as JSON", func() {
+			Expect(NewWebhook(server.URL, "Bearer token").Notify(context.TODO(), event)).To(Succeed())
+			req := <-requests
+			Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+
+			received := Event{}
+			Expect(json.Unmarshal(<-bodies, &received)).To(Succeed())
+			Expect(received).To(Equal(event))
+		})
+
+		It("requires a url option", func() {
+			_, err := newWebhookFromOptions(map[string]string{})
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("Slack", func() {
+		It("posts a summary of the event", func() {
+			Expect(NewSlack(server.URL, "#deploys").Notify(context.TODO(), event)).To(Succeed())
+			message := slackMessage{}
+			Expect(json.Unmarshal(<-bodies, &message)).To(Succeed())
+			Expect(message.Channel).To(Equal("#deploys"))
+			Expect(message.Text).To(ContainSubstring("Deployment default/example"))
+			Expect(message.Text).To(ContainSubstring("ConfigMap/example1"))
+		})
+	})
+
+	Context("DescribeKeys", func() {
+		It("names the changed keys and counts them", func() {
+			Expect(DescribeKeys([]KeyChange{
+				{Source: "ConfigMap/example1", Key: "log-level", Change: "modified"},
+				{Source: "Secret/example2", Key: "db-password", Change: "added"},
+			})).To(Equal("ConfigMap/example1[log-level] modified, Secret/example2[db-password] added (1 added, 0 removed, 1 modified)"))
+		})
+
+		It("limits the number of keys named", func() {
+			keys := []KeyChange{}
+			for i := 0; i < maxDescribedKeys+2; i++ {
+				keys = append(keys, KeyChange{Source: "ConfigMap/example1", Key: fmt.Sprintf("key%d", i), Change: "removed"})
+			}
+			description := DescribeKeys(keys)
+			Expect(description).NotTo(ContainSubstring("key11"))
+			Expect(description).To(ContainSubstring("and 2 more (0 added, 12 removed, 0 modified)"))
+		})
+	})
+
+	Context("JSONWriter", func() {
+		It("writes each event as a line of JSON", func() {
+			out := &bytes.Buffer{}
+			Expect(NewJSONWriter(out).Notify(context.TODO(), event)).To(Succeed())
+			Expect(out.String()).To(HaveSuffix("}\n"))
+
+			received := Event{}
+			Expect(json.Unmarshal(out.Bytes(), &received)).To(Succeed())
+			Expect(received).To(Equal(event))
+		})
+	})
+
+	Context("SNS", func() {
+		var sns *SNS
+
+		BeforeEach(func() {
+			sns = NewSNS("arn:aws:sns:eu-west-1:123456789012:wave", "eu-west-1", sigv4.Credentials{
+				AccessKeyID:     "AKIDEXAMPLE",
+				SecretAccessKey: "secret",
+			})
+			sns.endpoint = server.URL + "/"
+			sns.now = func() time.Time { return time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC) }
+		})
+
+		It("publishes the event to the topic", func() {
+			Expect(sns.Notify(context.TODO(), event)).To(Succeed())
+			values, err := url.ParseQuery(string(<-bodies))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(values.Get("Action")).To(Equal("Publish"))
+			Expect(values.Get("TopicArn")).To(Equal("arn:aws:sns:eu-west-1:123456789012:wave"))
+
+			received := Event{}
+			Expect(json.Unmarshal([]byte(values.Get("Message")), &received)).To(Succeed())
+			Expect(received).To(Equal(event))
+		})
+
+		It("signs the request", func() {
+			Expect(sns.Notify(context.TODO(), event)).To(Succeed())
+			req := <-requests
+			Expect(req.Header.Get("X-Amz-Date")).To(Equal("20190102T030405Z"))
+			Expect(req.Header.Get("Authorization")).To(HavePrefix(
+				"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20190102/eu-west-1/sns/aws4_request, SignedHeaders=content-type;host;x-amz-date, Signature=",
+			))
+		})
+
+		It("determines the region from the topic ARN", func() {
+			n, err := newSNSFromOptions(map[string]string{
+				"topicArn":        "arn:aws:sns:us-east-2:123456789012:wave",
+				"accessKeyId":     "AKIDEXAMPLE",
+				"secretAccessKey": "secret",
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(n.(*SNS).region).To(Equal("us-east-2"))
+		})
+	})
+})
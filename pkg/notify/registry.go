@@ -0,0 +1,83 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Factory constructs a Notifier from the options given in the notification
+// configuration
+type Factory func(options map[string]string) (Notifier, error)
+
+var (
+	factoriesMutex sync.RWMutex
+	factories      = make(map[string]Factory)
+)
+
+// Register makes a provider type available for use within the notification
+// configuration.
+// Organizations embedding Wave can call Register (typically from an init
+// function) to add their own notification backends.
+func Register(providerType string, factory Factory) {
+	factoriesMutex.Lock()
+	defer factoriesMutex.Unlock()
+	factories[providerType] = factory
+}
+
+// ProviderTypes returns the names of all registered provider types
+func ProviderTypes() []string {
+	factoriesMutex.RLock()
+	defer factoriesMutex.RUnlock()
+	types := []string{}
+	for providerType := range factories {
+		types = append(types, providerType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// newProvider constructs a Notifier using the Factory registered for the
+// provider type
+func newProvider(providerType string, options map[string]string) (Notifier, error) {
+	factoriesMutex.RLock()
+	factory, ok := factories[providerType]
+	factoriesMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown provider type %q, must be one of %v", providerType, ProviderTypes())
+	}
+	return factory(options)
+}
+
+// requireOption returns the value of a mandatory provider option
+func requireOption(options map[string]string, key string) (string, error) {
+	value := options[key]
+	if value == "" {
+		return "", fmt.Errorf("option %q must be set", key)
+	}
+	return value, nil
+}
+
+func init() {
+	Register("datadog", newDatadogFromOptions)
+	Register("slack", newSlackFromOptions)
+	Register("webhook", newWebhookFromOptions)
+	Register("sns", newSNSFromOptions)
+	Register("stdout-json", newStdoutJSONFromOptions)
+}
@@ -0,0 +1,69 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Slack posts a message for each Event to a Slack incoming webhook
+type Slack struct {
+	url     string
+	channel string
+	client  *http.Client
+}
+
+// slackMessage is the body of a request to a Slack incoming webhook
+type slackMessage struct {
+	Channel string `json:"channel,omitempty"`
+	Text    string `json:"text"`
+}
+
+// NewSlack constructs a Slack notifier. If channel is empty, messages are
+// sent to the webhook's default channel.
+func NewSlack(url, channel string) *Slack {
+	return &Slack{
+		url:     url,
+		channel: channel,
+		client:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// newSlackFromOptions constructs a Slack notifier from provider options
+func newSlackFromOptions(options map[string]string) (Notifier, error) {
+	url, err := requireOption(options, "url")
+	if err != nil {
+		return nil, err
+	}
+	return NewSlack(url, options["channel"]), nil
+}
+
+// Notify posts a message describing the Event to Slack
+func (s *Slack) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(slackMessage{Channel: s.channel, Text: summary(event)})
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	if err := postJSON(ctx, s.client, s.url, body, nil); err != nil {
+		return fmt.Errorf("error sending message to Slack: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/sigv4"
+)
+
+const (
+	snsService     = "sns"
+	snsAPIVersion  = "2010-03-31"
+	snsSubjectSize = 100
+)
+
+// SNS publishes each Event as JSON to an Amazon SNS topic
+type SNS struct {
+	topicARN    string
+	region      string
+	endpoint    string
+	credentials sigv4.Credentials
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewSNS constructs an SNS notifier publishing to the given topic
+func NewSNS(topicARN, region string, credentials sigv4.Credentials) *SNS {
+	return &SNS{
+		topicARN:    topicARN,
+		region:      region,
+		endpoint:    fmt.Sprintf("https://sns.%s.amazonaws.com/", region),
+		credentials: credentials,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		now:         time.Now,
+	}
+}
+
+// newSNSFromOptions constructs an SNS notifier from provider options.
+// Credentials default to the standard AWS environment variables.
+func newSNSFromOptions(options map[string]string) (Notifier, error) {
+	topicARN, err := requireOption(options, "topicArn")
+	if err != nil {
+		return nil, err
+	}
+	region := options["region"]
+	if region == "" {
+		// arn:aws:sns:<region>:<account>:<topic>
+		parts := strings.Split(topicARN, ":")
+		if len(parts) != 6 {
+			return nil, fmt.Errorf("unable to determine region from topic ARN %q", topicARN)
+		}
+		region = parts[3]
+	}
+	credentials := sigv4.Credentials{
+		AccessKeyID:     optionOrEnv(options, "accessKeyId", "AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: optionOrEnv(options, "secretAccessKey", "AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    optionOrEnv(options, "sessionToken", "AWS_SESSION_TOKEN"),
+	}
+	if !credentials.Valid() {
+		return nil, fmt.Errorf("AWS credentials must be set")
+	}
+	return NewSNS(topicARN, region, credentials), nil
+}
+
+// optionOrEnv returns the option if it is set, otherwise the value of the
+// environment variable
+func optionOrEnv(options map[string]string, key, env string) string {
+	if value := options[key]; value != "" {
+		return value
+	}
+	return os.Getenv(env)
+}
+
+// Notify publishes the Event to the SNS topic
+func (s *SNS) Notify(ctx context.Context, event Event) error {
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	subject := summary(event)
+	if len(subject) > snsSubjectSize {
+		subject = subject[:snsSubjectSize]
+	}
+	body := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {snsAPIVersion},
+		"TopicArn": {s.topicARN},
+		"Subject":  {subject},
+		"Message":  {string(message)},
+	}.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, s.endpoint, strings.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	sigv4.Sign(req, []byte(body), snsService, s.region, s.credentials, s.now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error publishing to SNS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from SNS: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// JSONWriter writes each Event as a single line of JSON, for collection by a
+// log shipper
+type JSONWriter struct {
+	mutex sync.Mutex
+	out   io.Writer
+}
+
+// NewJSONWriter constructs a JSONWriter that writes to out
+func NewJSONWriter(out io.Writer) *JSONWriter {
+	return &JSONWriter{out: out}
+}
+
+// newStdoutJSONFromOptions constructs a JSONWriter writing to stdout
+func newStdoutJSONFromOptions(options map[string]string) (Notifier, error) {
+	return NewJSONWriter(os.Stdout), nil
+}
+
+// Notify writes the Event to the output
+func (j *JSONWriter) Notify(ctx context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	_, err = fmt.Fprintf(j.out, "%s\n", line)
+	return err
+}
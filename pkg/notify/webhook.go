@@ -0,0 +1,89 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Webhook posts each Event as JSON to an HTTP endpoint
+type Webhook struct {
+	url           string
+	authorization string
+	client        *http.Client
+}
+
+// NewWebhook constructs a Webhook notifier. If authorization is not empty it
+// is sent as the Authorization header of each request.
+func NewWebhook(url, authorization string) *Webhook {
+	return &Webhook{
+		url:           url,
+		authorization: authorization,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// newWebhookFromOptions constructs a Webhook notifier from provider options
+func newWebhookFromOptions(options map[string]string) (Notifier, error) {
+	url, err := requireOption(options, "url")
+	if err != nil {
+		return nil, err
+	}
+	return NewWebhook(url, options["authorization"]), nil
+}
+
+// Notify posts the Event to the webhook
+func (w *Webhook) Notify(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	headers := map[string]string{}
+	if w.authorization != "" {
+		headers["Authorization"] = w.authorization
+	}
+	return postJSON(ctx, w.client, w.url, body, headers)
+}
+
+// postJSON sends a JSON body to the URL and checks for a successful response
+func postJSON(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error sending request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// Exec runs an executable for each version. The VersionRequest is written to
+// its standard input as JSON and it must print the version to its standard
+// output and exit zero.
+type Exec struct {
+	path string
+}
+
+// NewExec constructs an Exec Source running the executable at path
+func NewExec(path string) *Exec {
+	return &Exec{path: path}
+}
+
+// Version runs the executable, killing it if ctx is done first
+func (e *Exec) Version(ctx context.Context, req *VersionRequest) (string, error) {
+	input, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.path)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("error running %s: %v: %s", e.path, err, message)
+		}
+		return "", fmt.Errorf("error running %s: %v", e.path, err)
+	}
+	version := strings.TrimSpace(stdout.String())
+	if version == "" {
+		return "", fmt.Errorf("%s printed no version", e.path)
+	}
+	return version, nil
+}
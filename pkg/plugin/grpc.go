@@ -0,0 +1,68 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// GRPC calls the Source service of a plugin serving gRPC for each version
+type GRPC struct {
+	conn   *grpc.ClientConn
+	client SourceClient
+}
+
+// NewGRPC constructs a GRPC Source calling the plugin at address. The
+// connection is established in the background and re-established whenever
+// it breaks.
+func NewGRPC(address string) (*GRPC, error) {
+	opts := []grpc.DialOption{grpc.WithInsecure()}
+	if strings.HasPrefix(address, "unix://") {
+		opts = append(opts, grpc.WithDialer(func(path string, timeout time.Duration) (net.Conn, error) {
+			return net.DialTimeout("unix", path, timeout)
+		}))
+		address = strings.TrimPrefix(address, "unix://")
+	}
+	conn, err := grpc.Dial(address, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("error dialing %s: %v", address, err)
+	}
+	return &GRPC{conn: conn, client: NewSourceClient(conn)}, nil
+}
+
+// Version calls the plugin's Version method
+func (g *GRPC) Version(ctx context.Context, req *VersionRequest) (string, error) {
+	resp, err := g.client.Version(ctx, req)
+	if err != nil {
+		return "", fmt.Errorf("error calling plugin: %v", err)
+	}
+	if resp.GetVersion() == "" {
+		return "", fmt.Errorf("plugin returned no version")
+	}
+	return resp.GetVersion(), nil
+}
+
+// Close closes the connection to the plugin
+func (g *GRPC) Close() error {
+	return g.conn.Close()
+}
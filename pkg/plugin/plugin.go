@@ -0,0 +1,57 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package plugin contains the trigger sources Wave consults, in addition to
+ConfigMaps and Secrets, for the versions of external configuration mixed into
+the configuration hash of workloads
+*/
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// Source computes the version of an external source of configuration. Any
+// change to the version triggers a rollout of the workloads referencing it.
+type Source interface {
+	Version(ctx context.Context, req *VersionRequest) (string, error)
+}
+
+// Parse parses a plugin given as NAME=exec:PATH, running the executable at
+// PATH for each version, or NAME=grpc:ADDRESS, calling the Source service
+// served at ADDRESS. Addresses of Unix sockets start with unix://.
+func Parse(spec string) (string, Source, error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return "", nil, fmt.Errorf("invalid plugin %q: must be NAME=exec:PATH or NAME=grpc:ADDRESS", spec)
+	}
+	name, target := parts[0], parts[1]
+	switch {
+	case strings.HasPrefix(target, "exec:") && len(target) > len("exec:"):
+		return name, NewExec(strings.TrimPrefix(target, "exec:")), nil
+	case strings.HasPrefix(target, "grpc:") && len(target) > len("grpc:"):
+		source, err := NewGRPC(strings.TrimPrefix(target, "grpc:"))
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid plugin %q: %v", name, err)
+		}
+		return name, source, nil
+	default:
+		return "", nil, fmt.Errorf("invalid plugin %q: must be NAME=exec:PATH or NAME=grpc:ADDRESS", spec)
+	}
+}
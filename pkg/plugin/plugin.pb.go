@@ -0,0 +1,237 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: plugin.proto
+
+package plugin
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// VersionRequest identifies the workload and the source it references
+type VersionRequest struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind      string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name      string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	// argument is the value given to the plugin by the workload, e.g. the name
+	// of a feature flag
+	Argument             string   `protobuf:"bytes,4,opt,name=argument,proto3" json:"argument,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VersionRequest) Reset()         { *m = VersionRequest{} }
+func (m *VersionRequest) String() string { return proto.CompactTextString(m) }
+func (*VersionRequest) ProtoMessage()    {}
+func (*VersionRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a625af4bc1cc87, []int{0}
+}
+
+func (m *VersionRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VersionRequest.Unmarshal(m, b)
+}
+func (m *VersionRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VersionRequest.Marshal(b, m, deterministic)
+}
+func (m *VersionRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VersionRequest.Merge(m, src)
+}
+func (m *VersionRequest) XXX_Size() int {
+	return xxx_messageInfo_VersionRequest.Size(m)
+}
+func (m *VersionRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_VersionRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VersionRequest proto.InternalMessageInfo
+
+func (m *VersionRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *VersionRequest) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *VersionRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *VersionRequest) GetArgument() string {
+	if m != nil {
+		return m.Argument
+	}
+	return ""
+}
+
+// VersionResponse contains the version of the source. Any change to it
+// triggers a rollout of the workload.
+type VersionResponse struct {
+	Version              string   `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *VersionResponse) Reset()         { *m = VersionResponse{} }
+func (m *VersionResponse) String() string { return proto.CompactTextString(m) }
+func (*VersionResponse) ProtoMessage()    {}
+func (*VersionResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_22a625af4bc1cc87, []int{1}
+}
+
+func (m *VersionResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_VersionResponse.Unmarshal(m, b)
+}
+func (m *VersionResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_VersionResponse.Marshal(b, m, deterministic)
+}
+func (m *VersionResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_VersionResponse.Merge(m, src)
+}
+func (m *VersionResponse) XXX_Size() int {
+	return xxx_messageInfo_VersionResponse.Size(m)
+}
+func (m *VersionResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_VersionResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_VersionResponse proto.InternalMessageInfo
+
+func (m *VersionResponse) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*VersionRequest)(nil), "wave.plugin.v1.VersionRequest")
+	proto.RegisterType((*VersionResponse)(nil), "wave.plugin.v1.VersionResponse")
+}
+
+func init() { proto.RegisterFile("plugin.proto", fileDescriptor_22a625af4bc1cc87) }
+
+var fileDescriptor_22a625af4bc1cc87 = []byte{
+	// 192 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xe2, 0xe2, 0x29, 0xc8, 0x29, 0x4d,
+	0xcf, 0xcc, 0xd3, 0x2b, 0x28, 0xca, 0x2f, 0xc9, 0x17, 0xe2, 0x2b, 0x4f, 0x2c, 0x4b, 0xd5, 0x83,
+	0x0a, 0x95, 0x19, 0x2a, 0x15, 0x71, 0xf1, 0x85, 0xa5, 0x16, 0x15, 0x67, 0xe6, 0xe7, 0x05, 0xa5,
+	0x16, 0x96, 0xa6, 0x16, 0x97, 0x08, 0xc9, 0x70, 0x71, 0xe6, 0x25, 0xe6, 0xa6, 0x16, 0x17, 0x24,
+	0x26, 0xa7, 0x4a, 0x30, 0x2a, 0x30, 0x6a, 0x70, 0x06, 0x21, 0x04, 0x84, 0x84, 0xb8, 0x58, 0xb2,
+	0x33, 0xf3, 0x52, 0x24, 0x98, 0xc0, 0x12, 0x60, 0x36, 0x48, 0x0c, 0xa4, 0x40, 0x82, 0x19, 0x22,
+	0x06, 0x62, 0x0b, 0x49, 0x71, 0x71, 0x24, 0x16, 0xa5, 0x97, 0xe6, 0xa6, 0xe6, 0x95, 0x48, 0xb0,
+	0x80, 0xc5, 0xe1, 0x7c, 0x25, 0x6d, 0x2e, 0x7e, 0xb8, 0x9d, 0xc5, 0x05, 0xf9, 0x79, 0xc5, 0xa9,
+	0x42, 0x12, 0x5c, 0xec, 0x65, 0x10, 0x21, 0xa8, 0x95, 0x30, 0xae, 0x51, 0x08, 0x17, 0x5b, 0x70,
+	0x7e, 0x69, 0x51, 0x72, 0xaa, 0x90, 0x17, 0x17, 0x3b, 0x54, 0x9b, 0x90, 0x9c, 0x1e, 0xaa, 0x37,
+	0xf4, 0x50, 0xfd, 0x20, 0x25, 0x8f, 0x53, 0x1e, 0x62, 0x9f, 0x13, 0x47, 0x14, 0x1b, 0x44, 0x32,
+	0x89, 0x0d, 0x1c, 0x2e, 0xc6, 0x80, 0x01, 0x00, 0x8c, 0x38, 0x86, 0xcd, 0x27, 0x01, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// SourceClient is the client API for Source service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type SourceClient interface {
+	// Version returns the current version of the source for a workload
+	Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error)
+}
+
+type sourceClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewSourceClient(cc *grpc.ClientConn) SourceClient {
+	return &sourceClient{cc}
+}
+
+func (c *sourceClient) Version(ctx context.Context, in *VersionRequest, opts ...grpc.CallOption) (*VersionResponse, error) {
+	out := new(VersionResponse)
+	err := c.cc.Invoke(ctx, "/wave.plugin.v1.Source/Version", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SourceServer is the server API for Source service.
+type SourceServer interface {
+	// Version returns the current version of the source for a workload
+	Version(context.Context, *VersionRequest) (*VersionResponse, error)
+}
+
+// UnimplementedSourceServer can be embedded to have forward compatible implementations.
+type UnimplementedSourceServer struct {
+}
+
+func (*UnimplementedSourceServer) Version(ctx context.Context, req *VersionRequest) (*VersionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Version not implemented")
+}
+
+func RegisterSourceServer(s *grpc.Server, srv SourceServer) {
+	s.RegisterService(&_Source_serviceDesc, srv)
+}
+
+func _Source_Version_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VersionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SourceServer).Version(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.plugin.v1.Source/Version",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SourceServer).Version(ctx, req.(*VersionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Source_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "wave.plugin.v1.Source",
+	HandlerType: (*SourceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Version",
+			Handler:    _Source_Version_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "plugin.proto",
+}
@@ -0,0 +1,125 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plugin
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"google.golang.org/grpc"
+)
+
+// versionServer returns the argument of each request as its version
+type versionServer struct{}
+
+func (versionServer) Version(ctx context.Context, req *VersionRequest) (*VersionResponse, error) {
+	return &VersionResponse{Version: req.GetArgument()}, nil
+}
+
+var _ = Describe("Wave plugin Suite", func() {
+	var dir string
+	var req = &VersionRequest{Namespace: "default", Kind: "Deployment", Name: "example", Argument: "checkout"}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "wave-plugin")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	script := func(body string) string {
+		path := filepath.Join(dir, "plugin")
+		Expect(ioutil.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755)).To(Succeed())
+		return path
+	}
+
+	Context("Exec", func() {
+		It("passes the request as JSON and returns the printed version", func() {
+			path := script(`cat > "$(dirname "$0")/input"; echo " v42 "`)
+			version, err := NewExec(path).Version(context.TODO(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("v42"))
+
+			input, err := ioutil.ReadFile(filepath.Join(dir, "input"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(input).To(MatchJSON(`{"namespace":"default","kind":"Deployment","name":"example","argument":"checkout"}`))
+		})
+
+		It("returns the standard error of failed executables", func() {
+			path := script(`echo "flag service unavailable" >&2; exit 1`)
+			_, err := NewExec(path).Version(context.TODO(), req)
+			Expect(err).To(MatchError(ContainSubstring("flag service unavailable")))
+		})
+
+		It("requires a version", func() {
+			path := script(`exit 0`)
+			_, err := NewExec(path).Version(context.TODO(), req)
+			Expect(err).To(MatchError(ContainSubstring("printed no version")))
+		})
+
+		It("kills executables when the context is done", func() {
+			path := script(`exec sleep 10`)
+			ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+			defer cancel()
+			_, err := NewExec(path).Version(ctx, req)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("GRPC", func() {
+		It("calls the Source service", func() {
+			lis, err := net.Listen("unix", filepath.Join(dir, "plugin.sock"))
+			Expect(err).NotTo(HaveOccurred())
+			server := grpc.NewServer()
+			RegisterSourceServer(server, versionServer{})
+			go server.Serve(lis)
+			defer server.Stop()
+
+			_, source, err := Parse("flags=grpc:unix://" + filepath.Join(dir, "plugin.sock"))
+			Expect(err).NotTo(HaveOccurred())
+			defer source.(*GRPC).Close()
+			version, err := source.Version(context.TODO(), req)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(version).To(Equal("checkout"))
+		})
+	})
+
+	Context("Parse", func() {
+		It("parses exec plugins", func() {
+			name, source, err := Parse("flags=exec:/usr/local/bin/flags")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(name).To(Equal("flags"))
+			Expect(source).To(Equal(NewExec("/usr/local/bin/flags")))
+		})
+
+		It("rejects invalid plugins", func() {
+			for _, spec := range []string{"flags", "=exec:/bin/flags", "flags=exec:", "flags=http://flags"} {
+				_, _, err := Parse(spec)
+				Expect(err).To(MatchError(ContainSubstring("must be NAME=exec:PATH or NAME=grpc:ADDRESS")), spec)
+			}
+		})
+	})
+})
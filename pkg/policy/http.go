@@ -0,0 +1,47 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// HTTP evaluates rollouts by posting the Input as JSON to an endpoint which
+// responds with a Verdict
+type HTTP struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTP constructs an HTTP Evaluator calling the given URL
+func NewHTTP(url string, timeout time.Duration) *HTTP {
+	return &HTTP{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+// Evaluate posts the Input to the endpoint and returns its Verdict
+func (h *HTTP) Evaluate(ctx context.Context, input Input) (Verdict, error) {
+	verdict := Verdict{}
+	if err := postJSON(ctx, h.client, h.url, input, &verdict); err != nil {
+		return Verdict{}, err
+	}
+	if err := verdict.validate(); err != nil {
+		return Verdict{}, err
+	}
+	return verdict, nil
+}
@@ -0,0 +1,78 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// OPA evaluates rollouts by querying a Rego policy loaded into an Open Policy
+// Agent server through its Data API.
+// The policy document at the configured path must evaluate to an object
+// with the same fields as a Verdict, for example:
+//
+//	package wave
+//
+//	default rollout = {"decision": "allow"}
+//
+//	rollout = {"decision": "defer", "reason": "change freeze", "retryAfterSeconds": 3600} {
+//	  input.workload.namespace == "production"
+//	  data.freeze.active
+//	}
+type OPA struct {
+	url    string
+	client *http.Client
+}
+
+// opaRequest is the body of a request to the OPA Data API
+type opaRequest struct {
+	Input Input `json:"input"`
+}
+
+// opaResponse is the body of a response from the OPA Data API.
+// Result is nil when the queried document is undefined.
+type opaResponse struct {
+	Result *Verdict `json:"result"`
+}
+
+// NewOPA constructs an OPA Evaluator querying the document at path (for
+// example "wave/rollout") on the OPA server at address
+func NewOPA(address, path string, timeout time.Duration) *OPA {
+	return &OPA{
+		url:    fmt.Sprintf("%s/v1/data/%s", strings.TrimSuffix(address, "/"), strings.Trim(path, "/")),
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+// Evaluate queries the policy with the Input and returns its Verdict
+func (o *OPA) Evaluate(ctx context.Context, input Input) (Verdict, error) {
+	resp := opaResponse{}
+	if err := postJSON(ctx, o.client, o.url, opaRequest{Input: input}, &resp); err != nil {
+		return Verdict{}, err
+	}
+	if resp.Result == nil {
+		return Verdict{}, fmt.Errorf("policy document is undefined")
+	}
+	if err := resp.Result.validate(); err != nil {
+		return Verdict{}, err
+	}
+	return *resp.Result, nil
+}
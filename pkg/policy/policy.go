@@ -0,0 +1,131 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package policy contains the external decision hooks Wave consults before
+applying a configuration hash change to a workload
+*/
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Decision is the verdict returned by a policy
+type Decision string
+
+const (
+	// Allow permits the rollout to proceed
+	Allow Decision = "allow"
+
+	// Deny prevents the rollout. It will be evaluated again the next time the
+	// workload's configuration changes.
+	Deny Decision = "deny"
+
+	// Defer postpones the rollout, which will be evaluated again after the
+	// verdict's RetryAfter has elapsed
+	Defer Decision = "defer"
+)
+
+// Workload identifies the workload whose rollout is being evaluated
+type Workload struct {
+	Namespace   string            `json:"namespace"`
+	Kind        string            `json:"kind"`
+	Name        string            `json:"name"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// Change describes a change to a single ConfigMap or Secret
+type Change struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	// Type is one of "added", "removed" or "modified"
+	Type string `json:"type"`
+}
+
+// Input is the information passed to the policy for each decision
+type Input struct {
+	Workload    Workload `json:"workload"`
+	CurrentHash string   `json:"currentHash"`
+	NewHash     string   `json:"newHash"`
+	Changes     []Change `json:"changes"`
+	Summary     string   `json:"summary"`
+}
+
+// Verdict is the result of evaluating a policy
+type Verdict struct {
+	Decision Decision `json:"decision"`
+	Reason   string   `json:"reason,omitempty"`
+
+	// RetryAfterSeconds is how long to wait before re-evaluating a deferred
+	// rollout. If unset, the Evaluator's default is used.
+	RetryAfterSeconds int `json:"retryAfterSeconds,omitempty"`
+}
+
+// RetryAfter returns the time to wait before re-evaluating the rollout
+func (v Verdict) RetryAfter() time.Duration {
+	return time.Duration(v.RetryAfterSeconds) * time.Second
+}
+
+// Evaluator decides whether a rollout may proceed
+type Evaluator interface {
+	Evaluate(ctx context.Context, input Input) (Verdict, error)
+}
+
+// validate checks that the verdict contains a known decision
+func (v Verdict) validate() error {
+	switch v.Decision {
+	case Allow, Deny, Defer:
+		return nil
+	default:
+		return fmt.Errorf("unknown decision %q", v.Decision)
+	}
+}
+
+// postJSON sends the body to the URL as JSON and decodes the JSON response
+// into out
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("unable to marshal JSON: %v", err)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("error building request: %v", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling policy endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected response from policy endpoint: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("unable to decode policy response: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wave policy Suite", func() {
+	var server *httptest.Server
+	var paths chan string
+	var bodies chan []byte
+	var response string
+
+	var input = Input{
+		Workload:    Workload{Namespace: "default", Kind: "Deployment", Name: "example"},
+		CurrentHash: "old",
+		NewHash:     "new",
+		Changes:     []Change{{Kind: "ConfigMap", Name: "example1", Type: "modified"}},
+		Summary:     "0 added, 0 removed, 1 modified",
+	}
+
+	BeforeEach(func() {
+		paths = make(chan string, 1)
+		bodies = make(chan []byte, 1)
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body := json.RawMessage{}
+			Expect(json.NewDecoder(r.Body).Decode(&body)).To(Succeed())
+			paths <- r.URL.Path
+			bodies <- body
+			w.Write([]byte(response))
+		}))
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("HTTP", func() {
+		It("posts the input and returns the verdict", func() {
+			response = `{"decision": "defer", "reason": "change freeze", "retryAfterSeconds": 60}`
+			verdict, err := NewHTTP(server.URL, time.Second).Evaluate(context.TODO(), input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verdict.Decision).To(Equal(Defer))
+			Expect(verdict.Reason).To(Equal("change freeze"))
+			Expect(verdict.RetryAfter()).To(Equal(time.Minute))
+
+			received := Input{}
+			Expect(json.Unmarshal(<-bodies, &received)).To(Succeed())
+			Expect(received).To(Equal(input))
+		})
+
+		It("returns an error for unknown decisions", func() {
+			response = `{"decision": "maybe"}`
+			_, err := NewHTTP(server.URL, time.Second).Evaluate(context.TODO(), input)
+			Expect(err).To(MatchError(ContainSubstring("unknown decision")))
+		})
+	})
+
+	Context("OPA", func() {
+		It("queries the policy document with the input", func() {
+			response = `{"result": {"decision": "deny", "reason": "not approved"}}`
+			verdict, err := NewOPA(server.URL+"/", "/wave/rollout", time.Second).Evaluate(context.TODO(), input)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(verdict.Decision).To(Equal(Deny))
+			Expect(<-paths).To(Equal("/v1/data/wave/rollout"))
+
+			received := struct {
+				Input Input `json:"input"`
+			}{}
+			Expect(json.Unmarshal(<-bodies, &received)).To(Succeed())
+			Expect(received.Input).To(Equal(input))
+		})
+
+		It("returns an error when the policy document is undefined", func() {
+			response = `{}`
+			_, err := NewOPA(server.URL, "wave/rollout", time.Second).Evaluate(context.TODO(), input)
+			Expect(err).To(MatchError(ContainSubstring("undefined")))
+		})
+	})
+})
@@ -0,0 +1,218 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// Label is the key of the label on a Namespace holding the priority of the
+// workloads in it, as an integer. Workloads in Namespaces with higher
+// priorities are reconciled first. The default priority is 0.
+const Label = "wave.pusher.com/priority"
+
+// pollInterval is how often the Queue checks whether the workqueue of the
+// controller has room for more requests
+const pollInterval = 50 * time.Millisecond
+
+// Queue holds the reconcile requests enqueued by event handlers and feeds
+// them to the workqueue of a controller, highest priority first, while the
+// workqueue holds fewer than depth requests. Requests of the same priority
+// are fed in the order they were enqueued.
+type Queue struct {
+	client client.Reader
+	depth  int
+
+	mutex   sync.Mutex
+	queue   workqueue.Interface
+	pending map[reconcile.Request]entry
+	seq     uint64
+	wake    chan struct{}
+}
+
+// entry is a request held by the Queue
+type entry struct {
+	priority int
+	seq      uint64
+}
+
+// NewQueue constructs a Queue reading Namespaces from the client, which
+// keeps at most depth requests in the controller's workqueue
+func NewQueue(c client.Reader, depth int) *Queue {
+	if depth < 1 {
+		depth = 1
+	}
+	return &Queue{
+		client:  c,
+		depth:   depth,
+		pending: make(map[reconcile.Request]entry),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Handler wraps the EventHandler so that the requests it enqueues are held
+// by the Queue. Every EventHandler of a controller must share the Queue.
+func (q *Queue) Handler(h handler.EventHandler) handler.EventHandler {
+	return &prioritized{EventHandler: h, queue: q}
+}
+
+// prioritized passes the requests enqueued by an EventHandler to its Queue
+type prioritized struct {
+	handler.EventHandler
+	queue *Queue
+}
+
+// Create implements handler.EventHandler
+func (p *prioritized) Create(evt event.CreateEvent, wq workqueue.RateLimitingInterface) {
+	p.EventHandler.Create(evt, p.queue.collector(wq))
+}
+
+// Update implements handler.EventHandler
+func (p *prioritized) Update(evt event.UpdateEvent, wq workqueue.RateLimitingInterface) {
+	p.EventHandler.Update(evt, p.queue.collector(wq))
+}
+
+// Delete implements handler.EventHandler
+func (p *prioritized) Delete(evt event.DeleteEvent, wq workqueue.RateLimitingInterface) {
+	p.EventHandler.Delete(evt, p.queue.collector(wq))
+}
+
+// Generic implements handler.EventHandler
+func (p *prioritized) Generic(evt event.GenericEvent, wq workqueue.RateLimitingInterface) {
+	p.EventHandler.Generic(evt, p.queue.collector(wq))
+}
+
+// collector intercepts the requests added to the controller's workqueue
+type collector struct {
+	workqueue.RateLimitingInterface
+	queue *Queue
+}
+
+// Add implements workqueue.Interface
+func (c *collector) Add(item interface{}) {
+	request, ok := item.(reconcile.Request)
+	if !ok {
+		c.RateLimitingInterface.Add(item)
+		return
+	}
+	c.queue.push(request)
+}
+
+// collector binds the Queue to the controller's workqueue, starting to feed
+// it, and returns a workqueue collecting the requests added to it
+func (q *Queue) collector(wq workqueue.RateLimitingInterface) workqueue.RateLimitingInterface {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.queue == nil {
+		q.queue = wq
+		go q.run(wq)
+	}
+	return &collector{RateLimitingInterface: wq, queue: q}
+}
+
+// push holds the request until the workqueue has room for it
+func (q *Queue) push(request reconcile.Request) {
+	priority := q.priorityOf(request.Namespace)
+
+	q.mutex.Lock()
+	if _, ok := q.pending[request]; !ok {
+		q.seq++
+		q.pending[request] = entry{priority: priority, seq: q.seq}
+	}
+	q.mutex.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// pop removes and returns the pending request with the highest priority
+func (q *Queue) pop() (reconcile.Request, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	var next reconcile.Request
+	var best *entry
+	for request, e := range q.pending {
+		e := e
+		if best == nil || e.priority > best.priority || e.priority == best.priority && e.seq < best.seq {
+			next, best = request, &e
+		}
+	}
+	if best == nil {
+		return reconcile.Request{}, false
+	}
+	delete(q.pending, next)
+	return next, true
+}
+
+// len returns the number of requests held by the Queue
+func (q *Queue) len() int {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	return len(q.pending)
+}
+
+// run feeds the workqueue until it shuts down
+func (q *Queue) run(wq workqueue.Interface) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for !wq.ShuttingDown() {
+		q.feed(wq)
+		select {
+		case <-q.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// feed moves pending requests to the workqueue while it has room
+func (q *Queue) feed(wq workqueue.Interface) {
+	for wq.Len() < q.depth {
+		request, ok := q.pop()
+		if !ok {
+			return
+		}
+		wq.Add(request)
+	}
+}
+
+// priorityOf returns the priority of the Namespace, or 0 if it cannot be
+// read or its label is not an integer
+// +kubebuilder:rbac:groups=,resources=namespaces,verbs=get;list;watch
+func (q *Queue) priorityOf(namespace string) int {
+	ns := &corev1.Namespace{}
+	if err := q.client.Get(context.TODO(), types.NamespacedName{Name: namespace}, ns); err != nil {
+		return 0
+	}
+	priority, err := strconv.Atoi(ns.GetLabels()[Label])
+	if err != nil {
+		return 0
+	}
+	return priority
+}
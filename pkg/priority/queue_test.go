@@ -0,0 +1,101 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package priority
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+var _ = Describe("Queue", func() {
+	var q *Queue
+	var wq workqueue.RateLimitingInterface
+	var h handler.EventHandler
+
+	namespace := func(name, priority string) *corev1.Namespace {
+		ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+		if priority != "" {
+			ns.SetLabels(map[string]string{Label: priority})
+		}
+		return ns
+	}
+
+	create := func(namespace, name string) {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+		h.Create(event.CreateEvent{Meta: d, Object: d}, wq)
+	}
+
+	request := func(namespace, name string) reconcile.Request {
+		return reconcile.Request{NamespacedName: types.NamespacedName{Namespace: namespace, Name: name}}
+	}
+
+	BeforeEach(func() {
+		c := fake.NewFakeClient(namespace("production", "100"), namespace("batch", "-10"), namespace("dev", "invalid"))
+		q = NewQueue(c, 1)
+		wq = workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter())
+		h = q.Handler(&handler.EnqueueRequestForObject{})
+	})
+
+	AfterEach(func() {
+		wq.ShutDown()
+	})
+
+	// next waits for the next request fed to the workqueue
+	next := func() reconcile.Request {
+		item, shutdown := wq.Get()
+		Expect(shutdown).To(BeFalse())
+		wq.Done(item)
+		return item.(reconcile.Request)
+	}
+
+	It("feeds requests by the priority of their Namespace", func() {
+		create("dev", "a")
+		Eventually(wq.Len).Should(Equal(1))
+
+		// The workqueue is full, so these are held and sorted
+		create("batch", "b")
+		create("dev", "c")
+		create("production", "d")
+		create("missing", "e")
+		create("production", "f")
+		Expect(q.len()).To(Equal(5))
+
+		Expect(next()).To(Equal(request("dev", "a")))
+		Expect(next()).To(Equal(request("production", "d")))
+		Expect(next()).To(Equal(request("production", "f")))
+		Expect(next()).To(Equal(request("dev", "c")))
+		Expect(next()).To(Equal(request("missing", "e")))
+		Expect(next()).To(Equal(request("batch", "b")))
+	})
+
+	It("holds each request once", func() {
+		create("dev", "a")
+		Eventually(wq.Len).Should(Equal(1))
+		create("production", "b")
+		create("production", "b")
+		Expect(q.len()).To(Equal(1))
+	})
+})
@@ -0,0 +1,301 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package rpc contains Wave's gRPC integration API, a typed alternative to the
+admin API and to reading Wave's annotations, through which internal platforms
+query the workloads Wave manages and pause, resume and trigger their rollouts
+*/
+package rpc
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/trigger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// controlMethods are the methods which change workloads, which only the
+// control token may call
+var controlMethods = map[string]bool{
+	"/wave.v1.Wave/Pause":   true,
+	"/wave.v1.Wave/Resume":  true,
+	"/wave.v1.Wave/Trigger": true,
+}
+
+// Options configures the Server
+type Options struct {
+	// Address is the address to listen on
+	Address string
+
+	// ControlToken is the bearer token allowing every method
+	ControlToken string
+
+	// ReadToken is an optional bearer token only allowing queries
+	ReadToken string
+
+	// CertFile and KeyFile are the TLS certificate and key to serve with.
+	// Without them, the server accepts plaintext connections.
+	CertFile string
+	KeyFile  string
+}
+
+// Server serves the Wave gRPC service
+type Server struct {
+	handler *core.Handler
+	trigger *trigger.Server
+	options Options
+}
+
+// NewServer constructs a Server which acts through the Handler
+func NewServer(h *core.Handler, o Options) *Server {
+	return &Server{
+		handler: h,
+		trigger: trigger.NewServer(h, "", ""),
+		options: o,
+	}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	srv, err := s.GRPCServer()
+	if err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", s.options.Address)
+	if err != nil {
+		return fmt.Errorf("error listening for gRPC requests: %v", err)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving gRPC requests: %v", err)
+	case <-stop:
+		srv.GracefulStop()
+		return nil
+	}
+}
+
+// GRPCServer returns a grpc.Server serving the Wave service with
+// authentication and, if configured, TLS
+func (s *Server) GRPCServer() (*grpc.Server, error) {
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(s.authorize)}
+	if s.options.CertFile != "" || s.options.KeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(s.options.CertFile, s.options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading TLS certificate: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+	srv := grpc.NewServer(opts...)
+	RegisterWaveServer(srv, s)
+	return srv, nil
+}
+
+// authorize rejects calls without a token allowing the method
+func (s *Server) authorize(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var presented string
+	if values := md.Get("authorization"); len(values) > 0 {
+		presented = values[0]
+	}
+	switch {
+	case tokenMatches(presented, s.options.ControlToken):
+	case tokenMatches(presented, s.options.ReadToken) && !controlMethods[info.FullMethod]:
+	case tokenMatches(presented, s.options.ReadToken):
+		return nil, status.Errorf(codes.PermissionDenied, "the read token may not call %s", info.FullMethod)
+	default:
+		return nil, status.Error(codes.Unauthenticated, "a valid bearer token is required")
+	}
+
+	resp, err := handler(ctx, req)
+	log := logf.Log.WithName("rpc")
+	log.V(1).Info("Handled gRPC request", "method", info.FullMethod, "error", err)
+	return resp, err
+}
+
+// tokenMatches checks the presented authorization in constant time
+func tokenMatches(presented, token string) bool {
+	return token != "" && subtle.ConstantTimeCompare([]byte(presented), []byte("Bearer "+token)) == 1
+}
+
+// ListWorkloads returns the workloads Wave manages
+func (s *Server) ListWorkloads(ctx context.Context, req *ListWorkloadsRequest) (*ListWorkloadsResponse, error) {
+	objs, err := s.handler.ListWorkloads(ctx, req.Namespace)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &ListWorkloadsResponse{Workloads: []*Workload{}}
+	for _, obj := range objs {
+		if !core.Managed(obj) {
+			continue
+		}
+		workload, err := s.describe(ctx, obj)
+		if err != nil {
+			return nil, toStatus(err)
+		}
+		if req.PendingOnly && !workload.Pending {
+			continue
+		}
+		resp.Workloads = append(resp.Workloads, workload)
+	}
+	sort.Slice(resp.Workloads, func(i, j int) bool {
+		a, b := resp.Workloads[i], resp.Workloads[j]
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return resp, nil
+}
+
+// GetWorkload returns a single workload Wave manages
+func (s *Server) GetWorkload(ctx context.Context, req *WorkloadReference) (*Workload, error) {
+	if req.Namespace == "" || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "namespace and name are required")
+	}
+	var obj core.Object
+	switch req.Kind {
+	case "Deployment":
+		obj = &appsv1.Deployment{}
+	case "StatefulSet":
+		obj = &appsv1.StatefulSet{}
+	case "DaemonSet":
+		obj = &appsv1.DaemonSet{}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unsupported kind %q", req.Kind)
+	}
+	if err := s.handler.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, obj); err != nil {
+		return nil, toStatus(err)
+	}
+	if !core.Managed(obj) {
+		return nil, status.Errorf(codes.NotFound, "%s %s/%s is not managed by Wave", req.Kind, req.Namespace, req.Name)
+	}
+	workload, err := s.describe(ctx, obj)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return workload, nil
+}
+
+// Pause pauses rollouts of the targeted workloads
+func (s *Server) Pause(ctx context.Context, req *Target) (*TargetResponse, error) {
+	return s.administer(ctx, req, s.handler.Pause)
+}
+
+// Resume resumes rollouts of the targeted workloads
+func (s *Server) Resume(ctx context.Context, req *Target) (*TargetResponse, error) {
+	return s.administer(ctx, req, s.handler.Resume)
+}
+
+// Trigger restarts the referenced workload, or the workloads using the
+// referenced ConfigMap or Secret
+func (s *Server) Trigger(ctx context.Context, req *WorkloadReference) (*TargetResponse, error) {
+	if req.Namespace == "" || req.Kind == "" || req.Name == "" {
+		return nil, status.Error(codes.InvalidArgument, "namespace, kind and name are required")
+	}
+	triggered, err := s.trigger.Trigger(ctx, trigger.Request{Namespace: req.Namespace, Kind: req.Kind, Name: req.Name})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &TargetResponse{Workloads: triggered}, nil
+}
+
+// administer applies an admin action of the Handler to the target
+func (s *Server) administer(ctx context.Context, req *Target, fn func(context.Context, core.AdminTarget) ([]string, error)) (*TargetResponse, error) {
+	if (req.Kind == "") != (req.Name == "") {
+		return nil, status.Error(codes.InvalidArgument, "kind and name must be given together")
+	}
+	workloads, err := fn(ctx, core.AdminTarget{Namespace: req.Namespace, Kind: req.Kind, Name: req.Name})
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &TargetResponse{Workloads: workloads}, nil
+}
+
+// describe returns the Workload describing the object
+func (s *Server) describe(ctx context.Context, obj core.Object) (*Workload, error) {
+	policy, _ := core.TriggerPolicyOf(obj)
+	diff, err := s.handler.DiffConfig(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	references, err := core.References(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]string)
+	for _, source := range diff.Sources {
+		changes[source.Source] = source.Change
+	}
+	workload := &Workload{
+		Namespace:     obj.GetNamespace(),
+		Kind:          core.WorkloadKind(obj),
+		Name:          obj.GetName(),
+		Uid:           string(obj.GetUID()),
+		TriggerPolicy: string(policy),
+		Paused:        diff.Paused,
+		AppliedHash:   diff.AppliedHash,
+		CurrentHash:   diff.CurrentHash,
+		Pending:       diff.Pending(),
+	}
+	for _, ref := range references {
+		workload.Sources = append(workload.Sources, &Source{
+			Kind:     ref.Kind,
+			Name:     ref.Name,
+			Keys:     ref.Keys,
+			Required: ref.Required,
+			Change:   changes[ref.Kind+"/"+ref.Name],
+		})
+	}
+	return workload, nil
+}
+
+// toStatus converts an error to a gRPC status error
+func toStatus(err error) error {
+	switch {
+	case errors.IsNotFound(err):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.IsBadRequest(err):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.IsForbidden(err):
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
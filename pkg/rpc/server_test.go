@@ -0,0 +1,160 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package rpc
+
+import (
+	"context"
+	"net"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave RPC Suite", func() {
+	var c client.Client
+	var srv *grpc.Server
+	var conn *grpc.ClientConn
+	var wave WaveClient
+
+	withToken := func(token string) context.Context {
+		return metadata.AppendToOutgoingContext(context.TODO(), "authorization", "Bearer "+token)
+	}
+
+	codeOf := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	BeforeEach(func() {
+		d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        "example",
+			UID:         types.UID("example"),
+			Annotations: map[string]string{core.RequiredAnnotation: "manual"},
+		}}
+		d.Spec.Template.Spec.Containers = []corev1.Container{{
+			Name: "example",
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "example"}},
+			}},
+		}}
+		c = fake.NewFakeClient(
+			d,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unmanaged"}},
+			&corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example"},
+				Data:       map[string]string{"key": "value"},
+			},
+		)
+
+		var err error
+		h := core.NewHandler(c, record.NewFakeRecorder(100))
+		srv, err = NewServer(h, Options{ControlToken: "control", ReadToken: "read"}).GRPCServer()
+		Expect(err).NotTo(HaveOccurred())
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+		go srv.Serve(listener)
+
+		conn, err = grpc.Dial(listener.Addr().String(), grpc.WithInsecure())
+		Expect(err).NotTo(HaveOccurred())
+		wave = NewWaveClient(conn)
+	})
+
+	AfterEach(func() {
+		conn.Close()
+		srv.Stop()
+	})
+
+	It("lists the workloads Wave manages and their sources", func() {
+		resp, err := wave.ListWorkloads(withToken("read"), &ListWorkloadsRequest{Namespace: "default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Workloads).To(HaveLen(1))
+
+		workload := resp.Workloads[0]
+		Expect(workload.Kind).To(Equal("Deployment"))
+		Expect(workload.Name).To(Equal("example"))
+		Expect(workload.TriggerPolicy).To(Equal("manual"))
+		Expect(workload.AppliedHash).To(BeEmpty())
+		Expect(workload.CurrentHash).NotTo(BeEmpty())
+		Expect(workload.Pending).To(BeTrue())
+		Expect(workload.Sources).To(HaveLen(1))
+		Expect(workload.Sources[0].Kind).To(Equal("ConfigMap"))
+		Expect(workload.Sources[0].Name).To(Equal("example"))
+	})
+
+	It("only lists pending workloads when asked", func() {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "example"}, d)).To(Succeed())
+		_, err := core.SetConfig(context.TODO(), c, d)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(c.Update(context.TODO(), d)).To(Succeed())
+
+		resp, err := wave.ListWorkloads(withToken("read"), &ListWorkloadsRequest{PendingOnly: true})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Workloads).To(BeEmpty())
+	})
+
+	It("gets a single workload", func() {
+		workload, err := wave.GetWorkload(withToken("read"), &WorkloadReference{Namespace: "default", Kind: "Deployment", Name: "example"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workload.Uid).To(Equal("example"))
+
+		_, err = wave.GetWorkload(withToken("read"), &WorkloadReference{Namespace: "default", Kind: "Deployment", Name: "unmanaged"})
+		Expect(codeOf(err)).To(Equal(codes.NotFound))
+		_, err = wave.GetWorkload(withToken("read"), &WorkloadReference{Namespace: "default", Kind: "Pod", Name: "example"})
+		Expect(codeOf(err)).To(Equal(codes.InvalidArgument))
+	})
+
+	It("pauses, resumes and triggers workloads", func() {
+		resp, err := wave.Pause(withToken("control"), &Target{Namespace: "default"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Workloads).To(Equal([]string{"Deployment/example"}))
+		workload, err := wave.GetWorkload(withToken("control"), &WorkloadReference{Namespace: "default", Kind: "Deployment", Name: "example"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(workload.Paused).To(BeTrue())
+
+		_, err = wave.Resume(withToken("control"), &Target{Namespace: "default", Kind: "Deployment", Name: "example"})
+		Expect(err).NotTo(HaveOccurred())
+
+		resp, err = wave.Trigger(withToken("control"), &WorkloadReference{Namespace: "default", Kind: "ConfigMap", Name: "example"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Workloads).To(BeEmpty())
+		resp, err = wave.Trigger(withToken("control"), &WorkloadReference{Namespace: "default", Kind: "Deployment", Name: "example"})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.Workloads).To(Equal([]string{"Deployment/example"}))
+	})
+
+	It("authenticates and authorizes calls", func() {
+		_, err := wave.ListWorkloads(context.TODO(), &ListWorkloadsRequest{})
+		Expect(codeOf(err)).To(Equal(codes.Unauthenticated))
+		_, err = wave.ListWorkloads(withToken("wrong"), &ListWorkloadsRequest{})
+		Expect(codeOf(err)).To(Equal(codes.Unauthenticated))
+		_, err = wave.Pause(withToken("read"), &Target{Namespace: "default"})
+		Expect(codeOf(err)).To(Equal(codes.PermissionDenied))
+	})
+})
@@ -0,0 +1,748 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: wave.proto
+
+package rpc
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+// ListWorkloadsRequest selects the workloads to list
+type ListWorkloadsRequest struct {
+	// Namespace to list workloads in, or every namespace if empty
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// PendingOnly only lists workloads whose configuration changed since it
+	// was last rolled out
+	PendingOnly          bool     `protobuf:"varint,2,opt,name=pending_only,json=pendingOnly,proto3" json:"pending_only,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *ListWorkloadsRequest) Reset()         { *m = ListWorkloadsRequest{} }
+func (m *ListWorkloadsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListWorkloadsRequest) ProtoMessage()    {}
+func (*ListWorkloadsRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{0}
+}
+
+func (m *ListWorkloadsRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListWorkloadsRequest.Unmarshal(m, b)
+}
+func (m *ListWorkloadsRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListWorkloadsRequest.Marshal(b, m, deterministic)
+}
+func (m *ListWorkloadsRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListWorkloadsRequest.Merge(m, src)
+}
+func (m *ListWorkloadsRequest) XXX_Size() int {
+	return xxx_messageInfo_ListWorkloadsRequest.Size(m)
+}
+func (m *ListWorkloadsRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListWorkloadsRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListWorkloadsRequest proto.InternalMessageInfo
+
+func (m *ListWorkloadsRequest) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *ListWorkloadsRequest) GetPendingOnly() bool {
+	if m != nil {
+		return m.PendingOnly
+	}
+	return false
+}
+
+// ListWorkloadsResponse lists workloads sorted by namespace, kind and name
+type ListWorkloadsResponse struct {
+	Workloads            []*Workload `protobuf:"bytes,1,rep,name=workloads,proto3" json:"workloads,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}    `json:"-"`
+	XXX_unrecognized     []byte      `json:"-"`
+	XXX_sizecache        int32       `json:"-"`
+}
+
+func (m *ListWorkloadsResponse) Reset()         { *m = ListWorkloadsResponse{} }
+func (m *ListWorkloadsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListWorkloadsResponse) ProtoMessage()    {}
+func (*ListWorkloadsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{1}
+}
+
+func (m *ListWorkloadsResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_ListWorkloadsResponse.Unmarshal(m, b)
+}
+func (m *ListWorkloadsResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_ListWorkloadsResponse.Marshal(b, m, deterministic)
+}
+func (m *ListWorkloadsResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_ListWorkloadsResponse.Merge(m, src)
+}
+func (m *ListWorkloadsResponse) XXX_Size() int {
+	return xxx_messageInfo_ListWorkloadsResponse.Size(m)
+}
+func (m *ListWorkloadsResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_ListWorkloadsResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_ListWorkloadsResponse proto.InternalMessageInfo
+
+func (m *ListWorkloadsResponse) GetWorkloads() []*Workload {
+	if m != nil {
+		return m.Workloads
+	}
+	return nil
+}
+
+// WorkloadReference names a Deployment, StatefulSet, DaemonSet, ConfigMap or
+// Secret
+type WorkloadReference struct {
+	Namespace            string   `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind                 string   `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *WorkloadReference) Reset()         { *m = WorkloadReference{} }
+func (m *WorkloadReference) String() string { return proto.CompactTextString(m) }
+func (*WorkloadReference) ProtoMessage()    {}
+func (*WorkloadReference) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{2}
+}
+
+func (m *WorkloadReference) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_WorkloadReference.Unmarshal(m, b)
+}
+func (m *WorkloadReference) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_WorkloadReference.Marshal(b, m, deterministic)
+}
+func (m *WorkloadReference) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_WorkloadReference.Merge(m, src)
+}
+func (m *WorkloadReference) XXX_Size() int {
+	return xxx_messageInfo_WorkloadReference.Size(m)
+}
+func (m *WorkloadReference) XXX_DiscardUnknown() {
+	xxx_messageInfo_WorkloadReference.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_WorkloadReference proto.InternalMessageInfo
+
+func (m *WorkloadReference) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *WorkloadReference) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *WorkloadReference) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// Target selects the workload of the kind and name in the namespace, or
+// every workload Wave manages in the namespace if they are empty
+type Target struct {
+	Namespace            string   `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind                 string   `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name                 string   `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Target) Reset()         { *m = Target{} }
+func (m *Target) String() string { return proto.CompactTextString(m) }
+func (*Target) ProtoMessage()    {}
+func (*Target) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{3}
+}
+
+func (m *Target) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Target.Unmarshal(m, b)
+}
+func (m *Target) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Target.Marshal(b, m, deterministic)
+}
+func (m *Target) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Target.Merge(m, src)
+}
+func (m *Target) XXX_Size() int {
+	return xxx_messageInfo_Target.Size(m)
+}
+func (m *Target) XXX_DiscardUnknown() {
+	xxx_messageInfo_Target.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Target proto.InternalMessageInfo
+
+func (m *Target) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Target) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Target) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+// TargetResponse lists the workloads an action changed, as kind/name
+type TargetResponse struct {
+	Workloads            []string `protobuf:"bytes,1,rep,name=workloads,proto3" json:"workloads,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TargetResponse) Reset()         { *m = TargetResponse{} }
+func (m *TargetResponse) String() string { return proto.CompactTextString(m) }
+func (*TargetResponse) ProtoMessage()    {}
+func (*TargetResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{4}
+}
+
+func (m *TargetResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TargetResponse.Unmarshal(m, b)
+}
+func (m *TargetResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TargetResponse.Marshal(b, m, deterministic)
+}
+func (m *TargetResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TargetResponse.Merge(m, src)
+}
+func (m *TargetResponse) XXX_Size() int {
+	return xxx_messageInfo_TargetResponse.Size(m)
+}
+func (m *TargetResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_TargetResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TargetResponse proto.InternalMessageInfo
+
+func (m *TargetResponse) GetWorkloads() []string {
+	if m != nil {
+		return m.Workloads
+	}
+	return nil
+}
+
+// Workload is a Deployment, StatefulSet or DaemonSet Wave manages
+type Workload struct {
+	Namespace string `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	Kind      string `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name      string `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Uid       string `protobuf:"bytes,4,opt,name=uid,proto3" json:"uid,omitempty"`
+	// TriggerPolicy is the value of the wave.pusher.com/update-on-config-change
+	// annotation
+	TriggerPolicy string `protobuf:"bytes,5,opt,name=trigger_policy,json=triggerPolicy,proto3" json:"trigger_policy,omitempty"`
+	Paused        bool   `protobuf:"varint,6,opt,name=paused,proto3" json:"paused,omitempty"`
+	// AppliedHash is the configuration hash the workload runs
+	AppliedHash string `protobuf:"bytes,7,opt,name=applied_hash,json=appliedHash,proto3" json:"applied_hash,omitempty"`
+	// CurrentHash is the configuration hash Wave calculates now
+	CurrentHash string `protobuf:"bytes,8,opt,name=current_hash,json=currentHash,proto3" json:"current_hash,omitempty"`
+	// Pending is true if Wave will restart the workload once it applies the
+	// current hash
+	Pending bool `protobuf:"varint,9,opt,name=pending,proto3" json:"pending,omitempty"`
+	// Sources are the ConfigMaps and Secrets the workload references
+	Sources              []*Source `protobuf:"bytes,10,rep,name=sources,proto3" json:"sources,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *Workload) Reset()         { *m = Workload{} }
+func (m *Workload) String() string { return proto.CompactTextString(m) }
+func (*Workload) ProtoMessage()    {}
+func (*Workload) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{5}
+}
+
+func (m *Workload) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Workload.Unmarshal(m, b)
+}
+func (m *Workload) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Workload.Marshal(b, m, deterministic)
+}
+func (m *Workload) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Workload.Merge(m, src)
+}
+func (m *Workload) XXX_Size() int {
+	return xxx_messageInfo_Workload.Size(m)
+}
+func (m *Workload) XXX_DiscardUnknown() {
+	xxx_messageInfo_Workload.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Workload proto.InternalMessageInfo
+
+func (m *Workload) GetNamespace() string {
+	if m != nil {
+		return m.Namespace
+	}
+	return ""
+}
+
+func (m *Workload) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Workload) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Workload) GetUid() string {
+	if m != nil {
+		return m.Uid
+	}
+	return ""
+}
+
+func (m *Workload) GetTriggerPolicy() string {
+	if m != nil {
+		return m.TriggerPolicy
+	}
+	return ""
+}
+
+func (m *Workload) GetPaused() bool {
+	if m != nil {
+		return m.Paused
+	}
+	return false
+}
+
+func (m *Workload) GetAppliedHash() string {
+	if m != nil {
+		return m.AppliedHash
+	}
+	return ""
+}
+
+func (m *Workload) GetCurrentHash() string {
+	if m != nil {
+		return m.CurrentHash
+	}
+	return ""
+}
+
+func (m *Workload) GetPending() bool {
+	if m != nil {
+		return m.Pending
+	}
+	return false
+}
+
+func (m *Workload) GetSources() []*Source {
+	if m != nil {
+		return m.Sources
+	}
+	return nil
+}
+
+// Source is a ConfigMap or Secret referenced by a workload
+type Source struct {
+	Kind string `protobuf:"bytes,1,opt,name=kind,proto3" json:"kind,omitempty"`
+	Name string `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	// Keys lists the keys referenced individually, or is empty when the whole
+	// object is used
+	Keys     []string `protobuf:"bytes,3,rep,name=keys,proto3" json:"keys,omitempty"`
+	Required bool     `protobuf:"varint,4,opt,name=required,proto3" json:"required,omitempty"`
+	// Change is how the source changed since the applied hash was calculated:
+	// "added", "removed", "modified" or empty
+	Change               string   `protobuf:"bytes,5,opt,name=change,proto3" json:"change,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Source) Reset()         { *m = Source{} }
+func (m *Source) String() string { return proto.CompactTextString(m) }
+func (*Source) ProtoMessage()    {}
+func (*Source) Descriptor() ([]byte, []int) {
+	return fileDescriptor_b1cd93d812a03e36, []int{6}
+}
+
+func (m *Source) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Source.Unmarshal(m, b)
+}
+func (m *Source) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Source.Marshal(b, m, deterministic)
+}
+func (m *Source) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Source.Merge(m, src)
+}
+func (m *Source) XXX_Size() int {
+	return xxx_messageInfo_Source.Size(m)
+}
+func (m *Source) XXX_DiscardUnknown() {
+	xxx_messageInfo_Source.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Source proto.InternalMessageInfo
+
+func (m *Source) GetKind() string {
+	if m != nil {
+		return m.Kind
+	}
+	return ""
+}
+
+func (m *Source) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *Source) GetKeys() []string {
+	if m != nil {
+		return m.Keys
+	}
+	return nil
+}
+
+func (m *Source) GetRequired() bool {
+	if m != nil {
+		return m.Required
+	}
+	return false
+}
+
+func (m *Source) GetChange() string {
+	if m != nil {
+		return m.Change
+	}
+	return ""
+}
+
+func init() {
+	proto.RegisterType((*ListWorkloadsRequest)(nil), "wave.v1.ListWorkloadsRequest")
+	proto.RegisterType((*ListWorkloadsResponse)(nil), "wave.v1.ListWorkloadsResponse")
+	proto.RegisterType((*WorkloadReference)(nil), "wave.v1.WorkloadReference")
+	proto.RegisterType((*Target)(nil), "wave.v1.Target")
+	proto.RegisterType((*TargetResponse)(nil), "wave.v1.TargetResponse")
+	proto.RegisterType((*Workload)(nil), "wave.v1.Workload")
+	proto.RegisterType((*Source)(nil), "wave.v1.Source")
+}
+
+func init() { proto.RegisterFile("wave.proto", fileDescriptor_b1cd93d812a03e36) }
+
+var fileDescriptor_b1cd93d812a03e36 = []byte{
+	// 483 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xac, 0x94, 0x5d, 0x6f, 0xd3, 0x3e,
+	0x14, 0xc6, 0x95, 0xa6, 0xcd, 0xcb, 0xe9, 0x7f, 0xfb, 0x33, 0x8b, 0x17, 0x2b, 0x1a, 0xa8, 0x44,
+	0x42, 0x2a, 0x37, 0x45, 0x2b, 0xb7, 0x88, 0x0b, 0x6e, 0xd8, 0x05, 0x1a, 0x53, 0x98, 0x54, 0xc1,
+	0x4d, 0x65, 0x92, 0x43, 0x1a, 0x2d, 0x73, 0x3c, 0x3b, 0xe9, 0xe8, 0x77, 0xe2, 0x1b, 0xf0, 0xe5,
+	0x90, 0x1d, 0x27, 0x1d, 0xdd, 0x06, 0x42, 0xda, 0xdd, 0xf1, 0xcf, 0x8f, 0x9d, 0xe3, 0xc7, 0x8f,
+	0x03, 0x70, 0xc5, 0xd6, 0x38, 0x13, 0xb2, 0xaa, 0x2b, 0xe2, 0x9b, 0x7a, 0x7d, 0x14, 0x2f, 0xe0,
+	0xe1, 0x87, 0x42, 0xd5, 0x8b, 0x4a, 0x9e, 0x97, 0x15, 0xcb, 0x54, 0x82, 0x97, 0x0d, 0xaa, 0x9a,
+	0x1c, 0x42, 0xc8, 0xd9, 0x05, 0x2a, 0xc1, 0x52, 0xa4, 0xce, 0xc4, 0x99, 0x86, 0xc9, 0x16, 0x90,
+	0xe7, 0xf0, 0x9f, 0x40, 0x9e, 0x15, 0x3c, 0x5f, 0x56, 0xbc, 0xdc, 0xd0, 0xc1, 0xc4, 0x99, 0x06,
+	0xc9, 0xd8, 0xb2, 0x8f, 0xbc, 0xdc, 0xc4, 0xc7, 0xf0, 0x68, 0x67, 0x63, 0x25, 0x2a, 0xae, 0x90,
+	0xbc, 0x82, 0xf0, 0xaa, 0x83, 0xd4, 0x99, 0xb8, 0xd3, 0xf1, 0xfc, 0x60, 0x66, 0xdb, 0x99, 0x75,
+	0xf2, 0x64, 0xab, 0x89, 0x3f, 0xc3, 0x41, 0x8f, 0xf1, 0x1b, 0x4a, 0xe4, 0x29, 0xfe, 0xa5, 0x3f,
+	0x02, 0xc3, 0xf3, 0x82, 0x67, 0xa6, 0xaf, 0x30, 0x31, 0xb5, 0x66, 0x5a, 0x40, 0xdd, 0x96, 0xe9,
+	0x3a, 0x3e, 0x01, 0xef, 0x8c, 0xc9, 0x1c, 0xeb, 0x7b, 0xda, 0x6f, 0x06, 0xfb, 0xed, 0x7e, 0xfd,
+	0x69, 0x0f, 0x77, 0x4f, 0x1b, 0x5e, 0x3f, 0xda, 0x8f, 0x01, 0x04, 0xdd, 0xd9, 0xee, 0xa7, 0x05,
+	0xf2, 0x00, 0xdc, 0xa6, 0xc8, 0xe8, 0xd0, 0x20, 0x5d, 0x92, 0x17, 0xb0, 0x5f, 0xcb, 0x22, 0xcf,
+	0x51, 0x2e, 0x45, 0x55, 0x16, 0xe9, 0x86, 0x8e, 0xcc, 0xe4, 0x9e, 0xa5, 0xa7, 0x06, 0x92, 0xc7,
+	0xe0, 0x09, 0xd6, 0x28, 0xcc, 0xa8, 0x67, 0x6e, 0xd3, 0x8e, 0xf4, 0x5d, 0x33, 0x21, 0xca, 0x02,
+	0xb3, 0xe5, 0x8a, 0xa9, 0x15, 0xf5, 0xcd, 0xe2, 0xb1, 0x65, 0xc7, 0x4c, 0xad, 0xb4, 0x24, 0x6d,
+	0xa4, 0x44, 0x5e, 0xb7, 0x92, 0xa0, 0x95, 0x58, 0x66, 0x24, 0x14, 0x7c, 0x9b, 0x0e, 0x1a, 0x9a,
+	0xed, 0xbb, 0x21, 0x79, 0x09, 0xbe, 0xaa, 0x1a, 0x99, 0xa2, 0xa2, 0x60, 0xd2, 0xf0, 0x7f, 0x9f,
+	0x86, 0x4f, 0x86, 0x27, 0xdd, 0x7c, 0xfc, 0x1d, 0xbc, 0x16, 0xf5, 0x6e, 0x38, 0xb7, 0xb8, 0x31,
+	0xb8, 0xe6, 0x86, 0xd6, 0xe1, 0x46, 0x51, 0xd7, 0x38, 0x6f, 0x6a, 0x12, 0x41, 0x20, 0xf1, 0xb2,
+	0x29, 0x24, 0xb6, 0x36, 0x05, 0x49, 0x3f, 0xd6, 0x26, 0xa4, 0x2b, 0xc6, 0x73, 0xb4, 0x1e, 0xd9,
+	0xd1, 0xfc, 0xe7, 0x00, 0x86, 0x0b, 0xb6, 0x46, 0x72, 0x02, 0x7b, 0xbf, 0xc5, 0x9a, 0x3c, 0xed,
+	0xbb, 0xbd, 0xed, 0x1d, 0x45, 0xcf, 0xee, 0x9a, 0xb6, 0xf9, 0x78, 0x03, 0xe3, 0xf7, 0xd8, 0x73,
+	0x12, 0xdd, 0x7c, 0x09, 0x5d, 0xe4, 0xa3, 0x9b, 0xaf, 0x84, 0x1c, 0xc1, 0xe8, 0x54, 0xdf, 0x12,
+	0xd9, 0x7a, 0xd6, 0xe6, 0x2f, 0x7a, 0xb2, 0x03, 0xfa, 0x0f, 0xce, 0xc1, 0x4b, 0x50, 0x35, 0x17,
+	0xff, 0xb2, 0xe6, 0x2d, 0xf8, 0x67, 0x6d, 0x56, 0xfe, 0xd8, 0xe0, 0x5d, 0xeb, 0xdf, 0x8d, 0xbe,
+	0xb8, 0x52, 0xa4, 0x5f, 0x3d, 0xf3, 0xef, 0x79, 0xfd, 0x6b, 0x00, 0x79, 0x55, 0xd6, 0x2a, 0x89,
+	0x04, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// WaveClient is the client API for Wave service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type WaveClient interface {
+	// ListWorkloads returns the workloads Wave manages
+	ListWorkloads(ctx context.Context, in *ListWorkloadsRequest, opts ...grpc.CallOption) (*ListWorkloadsResponse, error)
+	// GetWorkload returns a single workload Wave manages
+	GetWorkload(ctx context.Context, in *WorkloadReference, opts ...grpc.CallOption) (*Workload, error)
+	// Pause pauses rollouts of the targeted workloads
+	Pause(ctx context.Context, in *Target, opts ...grpc.CallOption) (*TargetResponse, error)
+	// Resume resumes rollouts of the targeted workloads
+	Resume(ctx context.Context, in *Target, opts ...grpc.CallOption) (*TargetResponse, error)
+	// Trigger restarts the referenced workload or, for a ConfigMap or Secret,
+	// every workload Wave manages that uses it
+	Trigger(ctx context.Context, in *WorkloadReference, opts ...grpc.CallOption) (*TargetResponse, error)
+}
+
+type waveClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewWaveClient(cc *grpc.ClientConn) WaveClient {
+	return &waveClient{cc}
+}
+
+func (c *waveClient) ListWorkloads(ctx context.Context, in *ListWorkloadsRequest, opts ...grpc.CallOption) (*ListWorkloadsResponse, error) {
+	out := new(ListWorkloadsResponse)
+	err := c.cc.Invoke(ctx, "/wave.v1.Wave/ListWorkloads", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *waveClient) GetWorkload(ctx context.Context, in *WorkloadReference, opts ...grpc.CallOption) (*Workload, error) {
+	out := new(Workload)
+	err := c.cc.Invoke(ctx, "/wave.v1.Wave/GetWorkload", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *waveClient) Pause(ctx context.Context, in *Target, opts ...grpc.CallOption) (*TargetResponse, error) {
+	out := new(TargetResponse)
+	err := c.cc.Invoke(ctx, "/wave.v1.Wave/Pause", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *waveClient) Resume(ctx context.Context, in *Target, opts ...grpc.CallOption) (*TargetResponse, error) {
+	out := new(TargetResponse)
+	err := c.cc.Invoke(ctx, "/wave.v1.Wave/Resume", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *waveClient) Trigger(ctx context.Context, in *WorkloadReference, opts ...grpc.CallOption) (*TargetResponse, error) {
+	out := new(TargetResponse)
+	err := c.cc.Invoke(ctx, "/wave.v1.Wave/Trigger", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// WaveServer is the server API for Wave service.
+type WaveServer interface {
+	// ListWorkloads returns the workloads Wave manages
+	ListWorkloads(context.Context, *ListWorkloadsRequest) (*ListWorkloadsResponse, error)
+	// GetWorkload returns a single workload Wave manages
+	GetWorkload(context.Context, *WorkloadReference) (*Workload, error)
+	// Pause pauses rollouts of the targeted workloads
+	Pause(context.Context, *Target) (*TargetResponse, error)
+	// Resume resumes rollouts of the targeted workloads
+	Resume(context.Context, *Target) (*TargetResponse, error)
+	// Trigger restarts the referenced workload or, for a ConfigMap or Secret,
+	// every workload Wave manages that uses it
+	Trigger(context.Context, *WorkloadReference) (*TargetResponse, error)
+}
+
+// UnimplementedWaveServer can be embedded to have forward compatible implementations.
+type UnimplementedWaveServer struct {
+}
+
+func (*UnimplementedWaveServer) ListWorkloads(ctx context.Context, req *ListWorkloadsRequest) (*ListWorkloadsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListWorkloads not implemented")
+}
+func (*UnimplementedWaveServer) GetWorkload(ctx context.Context, req *WorkloadReference) (*Workload, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetWorkload not implemented")
+}
+func (*UnimplementedWaveServer) Pause(ctx context.Context, req *Target) (*TargetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Pause not implemented")
+}
+func (*UnimplementedWaveServer) Resume(ctx context.Context, req *Target) (*TargetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Resume not implemented")
+}
+func (*UnimplementedWaveServer) Trigger(ctx context.Context, req *WorkloadReference) (*TargetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Trigger not implemented")
+}
+
+func RegisterWaveServer(s *grpc.Server, srv WaveServer) {
+	s.RegisterService(&_Wave_serviceDesc, srv)
+}
+
+func _Wave_ListWorkloads_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListWorkloadsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WaveServer).ListWorkloads(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.v1.Wave/ListWorkloads",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WaveServer).ListWorkloads(ctx, req.(*ListWorkloadsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wave_GetWorkload_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkloadReference)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WaveServer).GetWorkload(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.v1.Wave/GetWorkload",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WaveServer).GetWorkload(ctx, req.(*WorkloadReference))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wave_Pause_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Target)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WaveServer).Pause(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.v1.Wave/Pause",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WaveServer).Pause(ctx, req.(*Target))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wave_Resume_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Target)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WaveServer).Resume(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.v1.Wave/Resume",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WaveServer).Resume(ctx, req.(*Target))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Wave_Trigger_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(WorkloadReference)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(WaveServer).Trigger(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/wave.v1.Wave/Trigger",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(WaveServer).Trigger(ctx, req.(*WorkloadReference))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Wave_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "wave.v1.Wave",
+	HandlerType: (*WaveServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListWorkloads",
+			Handler:    _Wave_ListWorkloads_Handler,
+		},
+		{
+			MethodName: "GetWorkload",
+			Handler:    _Wave_GetWorkload_Handler,
+		},
+		{
+			MethodName: "Pause",
+			Handler:    _Wave_Pause_Handler,
+		},
+		{
+			MethodName: "Resume",
+			Handler:    _Wave_Resume_Handler,
+		},
+		{
+			MethodName: "Trigger",
+			Handler:    _Wave_Trigger_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "wave.proto",
+}
@@ -0,0 +1,403 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// workloads are empty objects of each kind of workload the cache scopes
+var workloads = map[schema.GroupVersionKind]runtime.Object{
+	appsv1.SchemeGroupVersion.WithKind("Deployment"):  &appsv1.Deployment{},
+	appsv1.SchemeGroupVersion.WithKind("StatefulSet"): &appsv1.StatefulSet{},
+	appsv1.SchemeGroupVersion.WithKind("DaemonSet"):   &appsv1.DaemonSet{},
+}
+
+// CacheBuilder returns a NewCacheFunc which wraps the Cache built by
+// newCache, or the default Cache if it is nil, so that it only stores the
+// Deployments, StatefulSets and DaemonSets which are in Wave's scope, see
+// core.InScope. The workloads are watched in the namespaces, or in the
+// namespace of the cache options if none are given.
+//
+// Workloads are still listed and watched from the API server, but the others
+// are dropped before they are stored, which on large clusters saves most of
+// the memory of the cache. The informers only see a workload once it comes
+// into scope, and see it deleted once it leaves the scope.
+func CacheBuilder(newCache cache.NewCacheFunc, namespaces []string) cache.NewCacheFunc {
+	if newCache == nil {
+		newCache = cache.New
+	}
+	return func(config *rest.Config, opts cache.Options) (cache.Cache, error) {
+		c, err := newCache(config, opts)
+		if err != nil {
+			return nil, err
+		}
+		clientset, err := kubernetes.NewForConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("error creating workload client: %v", err)
+		}
+		if len(namespaces) == 0 {
+			namespaces = []string{opts.Namespace}
+		}
+		scheme := opts.Scheme
+		if scheme == nil {
+			scheme = runtime.NewScheme()
+			if err := appsv1.AddToScheme(scheme); err != nil {
+				return nil, err
+			}
+		}
+		resync := 10 * time.Hour
+		if opts.Resync != nil {
+			resync = *opts.Resync
+		}
+		return newScopedCache(c, scheme, resync, namespaces, func(gvk schema.GroupVersionKind, namespace string) *toolscache.ListWatch {
+			return newListWatch(clientset, gvk.Kind, namespace)
+		}), nil
+	}
+}
+
+// newScopedCache wraps the Cache with scoped informers of each kind of
+// workload in each namespace, listing and watching through newListWatch
+func newScopedCache(c cache.Cache, scheme *runtime.Scheme, resync time.Duration, namespaces []string, newListWatch func(schema.GroupVersionKind, string) *toolscache.ListWatch) *scopedCache {
+	scoped := &scopedCache{Cache: c, scheme: scheme, workloads: make(map[schema.GroupVersionKind]informers)}
+	for gvk, obj := range workloads {
+		scoped.workloads[gvk] = make(informers)
+		for _, namespace := range namespaces {
+			scoped.workloads[gvk][namespace] = toolscache.NewSharedIndexInformer(inScope(newListWatch(gvk, namespace)), obj, resync, toolscache.Indexers{
+				toolscache.NamespaceIndex: toolscache.MetaNamespaceIndexFunc,
+			})
+		}
+	}
+	return scoped
+}
+
+// scopedCache serves workloads from scoped informers and everything else
+// from the wrapped Cache
+type scopedCache struct {
+	cache.Cache
+	scheme    *runtime.Scheme
+	workloads map[schema.GroupVersionKind]informers
+}
+
+// informers holds the scoped informers of a kind of workload, keyed on the
+// namespace they watch, or the empty string for all namespaces. It fans
+// event handlers out to every informer.
+type informers map[string]toolscache.SharedIndexInformer
+
+// forNamespace returns the informer watching the namespace, if any
+func (i informers) forNamespace(namespace string) (toolscache.SharedIndexInformer, bool) {
+	if informer, ok := i[""]; ok {
+		return informer, true
+	}
+	informer, ok := i[namespace]
+	return informer, ok
+}
+
+// AddEventHandler implements cache.Informer
+func (i informers) AddEventHandler(handler toolscache.ResourceEventHandler) {
+	for _, informer := range i {
+		informer.AddEventHandler(handler)
+	}
+}
+
+// AddEventHandlerWithResyncPeriod implements cache.Informer
+func (i informers) AddEventHandlerWithResyncPeriod(handler toolscache.ResourceEventHandler, resyncPeriod time.Duration) {
+	for _, informer := range i {
+		informer.AddEventHandlerWithResyncPeriod(handler, resyncPeriod)
+	}
+}
+
+// AddIndexers implements cache.Informer
+func (i informers) AddIndexers(indexers toolscache.Indexers) error {
+	for _, informer := range i {
+		if err := informer.AddIndexers(indexers); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// HasSynced implements cache.Informer
+func (i informers) HasSynced() bool {
+	for _, informer := range i {
+		if !informer.HasSynced() {
+			return false
+		}
+	}
+	return true
+}
+
+// informersFor returns the scoped informers of the object, if it is a
+// workload, or of the items of the list, if it is a list of workloads
+func (c *scopedCache) informersFor(obj runtime.Object) (informers, schema.GroupVersionKind, bool) {
+	gvk, err := apiutil.GVKForObject(obj, c.scheme)
+	if err != nil {
+		return nil, gvk, false
+	}
+	gvk.Kind = strings.TrimSuffix(gvk.Kind, "List")
+	i, ok := c.workloads[gvk]
+	return i, gvk, ok
+}
+
+// Get implements client.Reader
+func (c *scopedCache) Get(ctx context.Context, key client.ObjectKey, obj runtime.Object) error {
+	i, gvk, ok := c.informersFor(obj)
+	if !ok {
+		return c.Cache.Get(ctx, key, obj)
+	}
+	notFound := errors.NewNotFound(schema.GroupResource{Group: gvk.Group, Resource: strings.ToLower(gvk.Kind) + "s"}, key.Name)
+	informer, ok := i.forNamespace(key.Namespace)
+	if !ok {
+		return notFound
+	}
+	item, exists, err := informer.GetIndexer().GetByKey(key.String())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return notFound
+	}
+	reflect.ValueOf(obj).Elem().Set(reflect.ValueOf(item.(runtime.Object).DeepCopyObject()).Elem())
+	return nil
+}
+
+// List implements client.Reader
+func (c *scopedCache) List(ctx context.Context, list runtime.Object, opts ...client.ListOption) error {
+	i, _, ok := c.informersFor(list)
+	if !ok || !meta.IsListType(list) {
+		return c.Cache.List(ctx, list, opts...)
+	}
+	listOpts := client.ListOptions{}
+	listOpts.ApplyOptions(opts)
+
+	var items []interface{}
+	if listOpts.Namespace != "" {
+		if informer, ok := i.forNamespace(listOpts.Namespace); ok {
+			var err error
+			items, err = informer.GetIndexer().ByIndex(toolscache.NamespaceIndex, listOpts.Namespace)
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		for _, informer := range i {
+			items = append(items, informer.GetIndexer().List()...)
+		}
+	}
+
+	objs := []runtime.Object{}
+	for _, item := range items {
+		obj := item.(runtime.Object)
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			return err
+		}
+		if listOpts.LabelSelector != nil && !listOpts.LabelSelector.Matches(labels.Set(accessor.GetLabels())) {
+			continue
+		}
+		objs = append(objs, obj.DeepCopyObject())
+	}
+	return meta.SetList(list, objs)
+}
+
+// GetInformer implements cache.Informers
+func (c *scopedCache) GetInformer(obj runtime.Object) (cache.Informer, error) {
+	if i, _, ok := c.informersFor(obj); ok {
+		return i, nil
+	}
+	return c.Cache.GetInformer(obj)
+}
+
+// GetInformerForKind implements cache.Informers
+func (c *scopedCache) GetInformerForKind(gvk schema.GroupVersionKind) (cache.Informer, error) {
+	if i, ok := c.workloads[gvk]; ok {
+		return i, nil
+	}
+	return c.Cache.GetInformerForKind(gvk)
+}
+
+// Start implements cache.Informers
+func (c *scopedCache) Start(stop <-chan struct{}) error {
+	for _, i := range c.workloads {
+		for _, informer := range i {
+			go informer.Run(stop)
+		}
+	}
+	return c.Cache.Start(stop)
+}
+
+// WaitForCacheSync implements cache.Informers
+func (c *scopedCache) WaitForCacheSync(stop <-chan struct{}) bool {
+	for _, i := range c.workloads {
+		if !toolscache.WaitForCacheSync(stop, i.HasSynced) {
+			return false
+		}
+	}
+	return c.Cache.WaitForCacheSync(stop)
+}
+
+// IndexField implements client.FieldIndexer
+func (c *scopedCache) IndexField(obj runtime.Object, field string, extractValue client.IndexerFunc) error {
+	if _, _, ok := c.informersFor(obj); ok {
+		return fmt.Errorf("field indexes are not supported for scoped workloads")
+	}
+	return c.Cache.IndexField(obj, field, extractValue)
+}
+
+// newListWatch creates a ListWatch which lists and watches the workloads of
+// the kind in the given namespace, or all namespaces if it is empty
+func newListWatch(clientset kubernetes.Interface, kind, namespace string) *toolscache.ListWatch {
+	apps := clientset.AppsV1()
+	switch kind {
+	case "Deployment":
+		return &toolscache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return apps.Deployments(namespace).List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return apps.Deployments(namespace).Watch(opts)
+			},
+		}
+	case "StatefulSet":
+		return &toolscache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				return apps.StatefulSets(namespace).List(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				return apps.StatefulSets(namespace).Watch(opts)
+			},
+		}
+	}
+	return &toolscache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return apps.DaemonSets(namespace).List(opts)
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			return apps.DaemonSets(namespace).Watch(opts)
+		},
+	}
+}
+
+// inScope wraps the ListWatch so that it drops the workloads which are not
+// in scope. A workload leaving the scope is turned into a deletion.
+func inScope(lw *toolscache.ListWatch) *toolscache.ListWatch {
+	f := &scopeFilter{keys: make(map[string]struct{})}
+	return &toolscache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			list, err := lw.List(opts)
+			if err != nil {
+				return nil, err
+			}
+			if err := f.list(list); err != nil {
+				return nil, err
+			}
+			return list, nil
+		},
+		WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+			w, err := lw.Watch(opts)
+			if err != nil {
+				return nil, err
+			}
+			return watch.Filter(w, f.event), nil
+		},
+	}
+}
+
+// scopeFilter tracks the keys of the workloads in scope that were passed on
+// to the informer, so that it knows which workloads leave the scope
+type scopeFilter struct {
+	mutex sync.Mutex
+	keys  map[string]struct{}
+}
+
+// list removes the workloads which are not in scope from the list, and
+// resets the known keys to those of the workloads left
+func (f *scopeFilter) list(list runtime.Object) error {
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return err
+	}
+	keys := make(map[string]struct{})
+	var kept []runtime.Object
+	for _, item := range items {
+		accessor, err := meta.Accessor(item)
+		if err != nil {
+			return err
+		}
+		if core.InScope(accessor) {
+			keys[keyOf(accessor)] = struct{}{}
+			kept = append(kept, item)
+		}
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.keys = keys
+	return meta.SetList(list, kept)
+}
+
+// event passes on the events of workloads in scope, and the deletion of
+// workloads which were
+func (f *scopeFilter) event(in watch.Event) (watch.Event, bool) {
+	accessor, err := meta.Accessor(in.Object)
+	if err != nil || (in.Type != watch.Added && in.Type != watch.Modified && in.Type != watch.Deleted) {
+		return in, true
+	}
+	key := keyOf(accessor)
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	_, known := f.keys[key]
+	switch {
+	case in.Type != watch.Deleted && core.InScope(accessor):
+		f.keys[key] = struct{}{}
+		return in, true
+	case known:
+		delete(f.keys, key)
+		in.Type = watch.Deleted
+		return in, true
+	}
+	return in, false
+}
+
+// keyOf returns the key of the object in the informer's store
+func keyOf(obj metav1.Object) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package scope
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes/scheme"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// workload returns a Deployment in the default namespace, in scope if
+// tracked is true
+func workload(name string, tracked bool) *appsv1.Deployment {
+	d := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, ResourceVersion: "1"}}
+	if tracked {
+		d.SetAnnotations(map[string]string{core.RequiredAnnotation: "true"})
+	}
+	return d
+}
+
+var _ = Describe("Wave scope Suite", func() {
+	Context("inScope", func() {
+		var watcher *watch.FakeWatcher
+		var lw *toolscache.ListWatch
+
+		BeforeEach(func() {
+			watcher = watch.NewFake()
+			lw = inScope(&toolscache.ListWatch{
+				ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+					return &appsv1.DeploymentList{Items: []appsv1.Deployment{*workload("tracked", true), *workload("untracked", false)}}, nil
+				},
+				WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+					return watcher, nil
+				},
+			})
+		})
+
+		// next sends the event and returns the event passed on, if any
+		next := func(w watch.Interface, eventType watch.EventType, obj runtime.Object) *watch.Event {
+			go watcher.Action(eventType, obj)
+			select {
+			case event := <-w.ResultChan():
+				return &event
+			case <-time.After(100 * time.Millisecond):
+				return nil
+			}
+		}
+
+		It("only lists workloads in scope", func() {
+			list, err := lw.List(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			items := list.(*appsv1.DeploymentList).Items
+			Expect(items).To(HaveLen(1))
+			Expect(items[0].GetName()).To(Equal("tracked"))
+		})
+
+		It("keeps workloads with Wave's finalizer or made by Wave in scope", func() {
+			finalized := workload("finalized", false)
+			finalized.SetFinalizers([]string{core.FinalizerString})
+			Expect(core.InScope(finalized)).To(BeTrue())
+
+			clone := workload("clone", false)
+			clone.SetLabels(map[string]string{core.BlueGreenOfLabel: "example"})
+			Expect(core.InScope(clone)).To(BeTrue())
+
+			disabled := workload("disabled", false)
+			disabled.SetAnnotations(map[string]string{core.RequiredAnnotation: "false"})
+			Expect(core.InScope(disabled)).To(BeTrue())
+
+			Expect(core.InScope(workload("untracked", false))).To(BeFalse())
+		})
+
+		It("drops the events of workloads out of scope", func() {
+			_, err := lw.List(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			w, err := lw.Watch(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(next(w, watch.Added, workload("other", false))).To(BeNil())
+			Expect(next(w, watch.Modified, workload("untracked", false))).To(BeNil())
+			Expect(next(w, watch.Deleted, workload("untracked", false))).To(BeNil())
+
+			event := next(w, watch.Modified, workload("tracked", true))
+			Expect(event).NotTo(BeNil())
+			Expect(event.Type).To(Equal(watch.Modified))
+		})
+
+		It("turns workloads leaving the scope into deletions", func() {
+			_, err := lw.List(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+			w, err := lw.Watch(metav1.ListOptions{})
+			Expect(err).NotTo(HaveOccurred())
+
+			event := next(w, watch.Modified, workload("tracked", false))
+			Expect(event).NotTo(BeNil())
+			Expect(event.Type).To(Equal(watch.Deleted))
+			Expect(next(w, watch.Deleted, workload("tracked", false))).To(BeNil())
+
+			event = next(w, watch.Modified, workload("untracked", true))
+			Expect(event).NotTo(BeNil())
+			Expect(event.Type).To(Equal(watch.Modified))
+		})
+	})
+
+	Context("scopedCache", func() {
+		var c *scopedCache
+		var stop chan struct{}
+
+		BeforeEach(func() {
+			stop = make(chan struct{})
+			c = newScopedCache(nil, scheme.Scheme, time.Hour, []string{""}, func(gvk schema.GroupVersionKind, namespace string) *toolscache.ListWatch {
+				return &toolscache.ListWatch{
+					ListFunc: func(metav1.ListOptions) (runtime.Object, error) {
+						if gvk.Kind != "Deployment" {
+							return &appsv1.StatefulSetList{}, nil
+						}
+						return &appsv1.DeploymentList{Items: []appsv1.Deployment{*workload("tracked", true), *workload("untracked", false)}}, nil
+					},
+					WatchFunc: func(metav1.ListOptions) (watch.Interface, error) {
+						return watch.NewFake(), nil
+					},
+				}
+			})
+			for _, i := range c.workloads {
+				for _, informer := range i {
+					go informer.Run(stop)
+				}
+			}
+			Eventually(c.workloads[appsv1.SchemeGroupVersion.WithKind("Deployment")].HasSynced).Should(BeTrue())
+		})
+
+		AfterEach(func() {
+			close(stop)
+		})
+
+		It("gets workloads in scope", func() {
+			d := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "tracked"}, d)).To(Succeed())
+			Expect(d.GetAnnotations()).To(HaveKey(core.RequiredAnnotation))
+
+			err := c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: "untracked"}, d)
+			Expect(errors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("lists workloads in scope", func() {
+			list := &appsv1.DeploymentList{}
+			Expect(c.List(context.TODO(), list, client.InNamespace("default"))).To(Succeed())
+			Expect(list.Items).To(HaveLen(1))
+			Expect(list.Items[0].GetName()).To(Equal("tracked"))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package sigv4 signs requests to AWS APIs using AWS Signature Version 4,
+allowing Wave to publish to AWS services without depending on the AWS SDK
+*/
+package sigv4
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	algorithm  = "AWS4-HMAC-SHA256"
+	dateFormat = "20060102"
+	timeFormat = "20060102T150405Z"
+
+	// ContentSHA256Header is the header carrying the hash of the request body,
+	// which S3 requires on every request
+	ContentSHA256Header = "X-Amz-Content-Sha256"
+)
+
+// Credentials are used to sign requests
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// CredentialsFromEnv reads Credentials from the standard AWS environment
+// variables
+func CredentialsFromEnv() Credentials {
+	return Credentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+}
+
+// Valid returns true if the Credentials contain an access key
+func (c Credentials) Valid() bool {
+	return c.AccessKeyID != "" && c.SecretAccessKey != ""
+}
+
+// Sign adds the headers required to authenticate the request to the given
+// AWS service and region. body must be the exact payload of the request.
+func Sign(req *http.Request, body []byte, service, region string, credentials Credentials, now time.Time) {
+	now = now.UTC()
+	date := now.Format(dateFormat)
+	payloadHash := HexSHA256(body)
+	req.Header.Set("X-Amz-Date", now.Format(timeFormat))
+	if service == "s3" {
+		req.Header.Set(ContentSHA256Header, payloadHash)
+	}
+	if credentials.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", credentials.SessionToken)
+	}
+
+	// Build the canonical headers, which must include the host
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := []string{}
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", name, headers[name])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, region, service, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		algorithm,
+		now.Format(timeFormat),
+		scope,
+		HexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+credentials.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := fmt.Sprintf("%x", hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, credentials.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// HexSHA256 returns the hex encoded SHA256 hash of the data
+func HexSHA256(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of the data using the key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
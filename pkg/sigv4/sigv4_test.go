@@ -0,0 +1,64 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package sigv4
+
+import (
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Wave SigV4 Suite", func() {
+	var credentials = Credentials{
+		AccessKeyID:     "AKIDEXAMPLE",
+		SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	}
+	var now = time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+
+	It("matches the signature from the AWS documentation example", func() {
+		req, err := http.NewRequest(http.MethodGet, "https://iam.amazonaws.com/?Action=ListUsers&Version=2010-05-08", nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+
+		Sign(req, nil, "iam", "us-east-1", credentials, now)
+		Expect(req.Header.Get("Authorization")).To(Equal(
+			"AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/iam/aws4_request, " +
+				"SignedHeaders=content-type;host;x-amz-date, " +
+				"Signature=5d672d79c15b13162d9279b0855cfba6789a8edb4c82c400e06b5924a6f2b5d7",
+		))
+	})
+
+	It("adds the payload hash header for S3", func() {
+		req, err := http.NewRequest(http.MethodPut, "https://bucket.s3.amazonaws.com/key", nil)
+		Expect(err).NotTo(HaveOccurred())
+		Sign(req, []byte("body"), "s3", "us-east-1", credentials, now)
+		Expect(req.Header.Get(ContentSHA256Header)).To(Equal(HexSHA256([]byte("body"))))
+		Expect(req.Header.Get("Authorization")).To(ContainSubstring("x-amz-content-sha256"))
+	})
+
+	It("includes the session token when present", func() {
+		req, err := http.NewRequest(http.MethodPost, "https://sns.eu-west-1.amazonaws.com/", nil)
+		Expect(err).NotTo(HaveOccurred())
+		withToken := credentials
+		withToken.SessionToken = "token"
+		Sign(req, nil, "sns", "eu-west-1", withToken, now)
+		Expect(req.Header.Get("X-Amz-Security-Token")).To(Equal("token"))
+		Expect(req.Header.Get("Authorization")).To(ContainSubstring("x-amz-security-token"))
+	})
+})
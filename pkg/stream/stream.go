@@ -0,0 +1,226 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package stream contains an HTTP server streaming Wave's rollout decisions as
+Server-Sent Events, so that deployment dashboards and chat bots can follow
+them as they are made instead of polling the Events API
+*/
+package stream
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/audit"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+const (
+	// Path is the path the server streams decisions on
+	Path = "/decisions"
+
+	// heartbeatInterval is how often an idle stream is sent a comment,
+	// keeping the connection open through proxies
+	heartbeatInterval = 15 * time.Second
+
+	// bufferSize is how many decisions may wait to be sent to a subscriber
+	// before it is disconnected for falling behind
+	bufferSize = 100
+)
+
+// Filter selects the decisions a subscriber receives. Empty fields match
+// every decision.
+type Filter struct {
+	Namespace string
+	Kind      string
+	Name      string
+	Decision  audit.Decision
+}
+
+// matches returns true if the filter selects the record
+func (f Filter) matches(record audit.Record) bool {
+	return (f.Namespace == "" || f.Namespace == record.Workload.Namespace) &&
+		(f.Kind == "" || f.Kind == record.Workload.Kind) &&
+		(f.Name == "" || f.Name == record.Workload.Name) &&
+		(f.Decision == "" || f.Decision == record.Decision)
+}
+
+// Broadcaster fans the decisions it receives out to every subscriber.
+// It implements audit.Sink so that the Handler can send it each decision.
+type Broadcaster struct {
+	mutex       sync.Mutex
+	subscribers map[chan audit.Record]Filter
+}
+
+// NewBroadcaster constructs a Broadcaster without subscribers
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subscribers: make(map[chan audit.Record]Filter)}
+}
+
+// Write sends the record to every subscriber whose filter matches it without
+// blocking. Subscribers which fell behind are disconnected so that they can
+// reconnect rather than silently miss decisions.
+func (b *Broadcaster) Write(ctx context.Context, record audit.Record) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for records, filter := range b.subscribers {
+		if !filter.matches(record) {
+			continue
+		}
+		select {
+		case records <- record:
+		default:
+			delete(b.subscribers, records)
+			close(records)
+		}
+	}
+	return nil
+}
+
+// subscribe returns a channel receiving the decisions the filter selects,
+// which is closed if the subscriber falls behind
+func (b *Broadcaster) subscribe(filter Filter) chan audit.Record {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	records := make(chan audit.Record, bufferSize)
+	b.subscribers[records] = filter
+	return records
+}
+
+// unsubscribe stops sending decisions to the channel
+func (b *Broadcaster) unsubscribe(records chan audit.Record) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.subscribers[records]; ok {
+		delete(b.subscribers, records)
+		close(records)
+	}
+}
+
+// Server streams the decisions of a Broadcaster over HTTP
+type Server struct {
+	broadcaster *Broadcaster
+	address     string
+	token       string
+	heartbeat   time.Duration
+
+	// done is closed when the server stops, ending every stream
+	done <-chan struct{}
+}
+
+// NewServer constructs a Server listening on address. Requests must present
+// token as a bearer token.
+func NewServer(b *Broadcaster, address, token string) *Server {
+	return &Server{
+		broadcaster: b,
+		address:     address,
+		token:       token,
+		heartbeat:   heartbeatInterval,
+	}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	s.done = stop
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	srv := &http.Server{Addr: s.address, Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving decision streams: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// ServeHTTP streams each decision selected by the namespace, kind, name and
+// decision query parameters as a Server-Sent Event whose data is the JSON
+// audit.Record, until the client disconnects
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	query := r.URL.Query()
+	records := s.broadcaster.subscribe(Filter{
+		Namespace: query.Get("namespace"),
+		Kind:      query.Get("kind"),
+		Name:      query.Get("name"),
+		Decision:  audit.Decision(query.Get("decision")),
+	})
+	defer s.broadcaster.unsubscribe(records)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(s.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case record, ok := <-records:
+			if !ok {
+				log := logf.Log.WithName("stream")
+				log.V(0).Info("Disconnecting subscriber which fell behind", "remote", r.RemoteAddr)
+				return
+			}
+			data, err := json.Marshal(record)
+			if err != nil {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", record.Decision, data)
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// authorized checks the request's bearer token in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + s.token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package stream
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/audit"
+)
+
+var _ = Describe("Wave stream Suite", func() {
+	var b *Broadcaster
+	var s *Server
+	var server *httptest.Server
+
+	newRecord := func(name string, decision audit.Decision) audit.Record {
+		return audit.Record{
+			Workload: audit.Workload{Namespace: "default", Kind: "Deployment", Name: name},
+			Decision: decision,
+			NewHash:  "1234",
+		}
+	}
+
+	// subscribe opens a stream with the query and returns its lines
+	subscribe := func(query string) (*bufio.Reader, func()) {
+		req, err := http.NewRequest(http.MethodGet, server.URL+Path+query, nil)
+		Expect(err).NotTo(HaveOccurred())
+		req.Header.Set("Authorization", "Bearer secret-token")
+		resp, err := http.DefaultClient.Do(req)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		Expect(resp.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		return bufio.NewReader(resp.Body), func() { resp.Body.Close() }
+	}
+
+	// readEvent returns the name and data of the next event of the stream
+	readEvent := func(r *bufio.Reader) (string, string) {
+		var event, data string
+		for {
+			line, err := r.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case line == "" && data != "":
+				return event, data
+			case strings.HasPrefix(line, "event: "):
+				event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				data = strings.TrimPrefix(line, "data: ")
+			}
+		}
+	}
+
+	// waitForSubscribers waits until the Broadcaster has n subscribers
+	waitForSubscribers := func(n int) {
+		Eventually(func() int {
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			return len(b.subscribers)
+		}).Should(Equal(n))
+	}
+
+	BeforeEach(func() {
+		b = NewBroadcaster()
+		s = NewServer(b, ":0", "secret-token")
+		server = httptest.NewServer(s)
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	It("streams decisions as they are made", func() {
+		r, stop := subscribe("")
+		defer stop()
+		waitForSubscribers(1)
+
+		Expect(b.Write(context.TODO(), newRecord("example", audit.Triggered))).To(Succeed())
+		event, data := readEvent(r)
+		Expect(event).To(Equal("triggered"))
+		record := audit.Record{}
+		Expect(json.Unmarshal([]byte(data), &record)).To(Succeed())
+		Expect(record.Workload.Name).To(Equal("example"))
+		Expect(record.NewHash).To(Equal("1234"))
+	})
+
+	It("only streams the decisions selected by the query", func() {
+		r, stop := subscribe("?name=example&decision=deferred")
+		defer stop()
+		waitForSubscribers(1)
+
+		Expect(b.Write(context.TODO(), newRecord("other", audit.Deferred))).To(Succeed())
+		Expect(b.Write(context.TODO(), newRecord("example", audit.Triggered))).To(Succeed())
+		Expect(b.Write(context.TODO(), newRecord("example", audit.Deferred))).To(Succeed())
+		event, data := readEvent(r)
+		Expect(event).To(Equal("deferred"))
+		Expect(data).To(ContainSubstring(`"name":"example"`))
+	})
+
+	It("sends heartbeats to idle streams", func() {
+		s.heartbeat = 10 * time.Millisecond
+		r, stop := subscribe("")
+		defer stop()
+		line, err := r.ReadString('\n')
+		Expect(err).NotTo(HaveOccurred())
+		Expect(line).To(Equal(": heartbeat\n"))
+	})
+
+	It("unsubscribes disconnected clients", func() {
+		_, stop := subscribe("")
+		waitForSubscribers(1)
+		stop()
+		// The server notices the disconnect once it writes to the stream
+		Eventually(func() int {
+			Expect(b.Write(context.TODO(), newRecord("example", audit.Triggered))).To(Succeed())
+			b.mutex.Lock()
+			defer b.mutex.Unlock()
+			return len(b.subscribers)
+		}).Should(BeZero())
+	})
+
+	It("disconnects subscribers which fall behind", func() {
+		records := b.subscribe(Filter{})
+		for i := 0; i <= bufferSize; i++ {
+			Expect(b.Write(context.TODO(), newRecord("example", audit.Triggered))).To(Succeed())
+		}
+		Expect(records).To(HaveLen(bufferSize))
+		for range records {
+		}
+		Expect(b.subscribers).To(BeEmpty())
+	})
+
+	It("rejects requests without the token", func() {
+		resp, err := http.Get(server.URL + Path)
+		Expect(err).NotTo(HaveOccurred())
+		resp.Body.Close()
+		Expect(resp.StatusCode).To(Equal(http.StatusUnauthorized))
+	})
+})
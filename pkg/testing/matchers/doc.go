@@ -0,0 +1,27 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package matchers contains Gomega matchers for Kubernetes objects backed by the
+controller-runtime client. Wave's own envtest suites use them, and operators
+embedding Wave's core handler can use them in theirs.
+
+	m := matchers.Matcher{Client: c}
+	m.Eventually(deployment, timeout).Should(
+		matchers.WithPodTemplateAnnotations(HaveKey(core.ConfigHashAnnotation)),
+	)
+*/
+package matchers
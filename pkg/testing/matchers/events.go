@@ -0,0 +1,102 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"sort"
+	"time"
+
+	"github.com/onsi/gomega"
+	gtypes "github.com/onsi/gomega/types"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// WithEvents returns the Events recorded for the object, oldest first.
+// Events are matched to the object by UID, or by kind and name when the
+// object has no UID.
+//
+//	m.Eventually(deployment, timeout).Should(
+//		m.WithEvents(ContainElement(matchers.HaveReason("ConfigChanged"))),
+//	)
+func (m *Matcher) WithEvents(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []corev1.Event {
+		events, err := m.eventsFor(obj)
+		if err != nil {
+			panic(err)
+		}
+		return events
+	}, matcher)
+}
+
+// eventsFor lists the Events in the object's namespace and keeps those
+// involving it. Events are filtered here rather than with a field selector
+// so that the fake client is supported.
+func (m *Matcher) eventsFor(obj Object) ([]corev1.Event, error) {
+	list := &corev1.EventList{}
+	if err := m.Client.List(m.pollContext(), list, client.InNamespace(obj.GetNamespace())); err != nil {
+		return nil, err
+	}
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	events := []corev1.Event{}
+	for _, event := range list.Items {
+		involved := event.InvolvedObject
+		switch {
+		case obj.GetUID() != "":
+			if involved.UID != obj.GetUID() {
+				continue
+			}
+		case involved.Name != obj.GetName() || (kind != "" && involved.Kind != kind):
+			continue
+		}
+		events = append(events, event)
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return lastSeen(events[i]).Before(lastSeen(events[j]))
+	})
+	return events, nil
+}
+
+// lastSeen returns when the Event last occurred
+func lastSeen(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+	return event.CreationTimestamp.Time
+}
+
+// HaveReason matches an Event with the reason
+func HaveReason(reason string) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(event corev1.Event) string {
+		return event.Reason
+	}, gomega.Equal(reason))
+}
+
+// HaveEventType matches an Event of the type, either corev1.EventTypeNormal
+// or corev1.EventTypeWarning
+func HaveEventType(eventType string) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(event corev1.Event) string {
+		return event.Type
+	}, gomega.Equal(eventType))
+}
+
+// HaveEventMessage matches an Event whose message matches the matcher
+func HaveEventMessage(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(event corev1.Event) string {
+		return event.Message
+	}, matcher)
+}
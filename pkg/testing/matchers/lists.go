@@ -0,0 +1,128 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega"
+	"github.com/onsi/gomega/format"
+	gtypes "github.com/onsi/gomega/types"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// HaveOwnerReferenceTo succeeds if the object has an OwnerReference to the
+// owner, identified by its UID
+func HaveOwnerReferenceTo(owner Object) gtypes.GomegaMatcher {
+	return WithOwnerReferences(gomega.ContainElement(gomega.WithTransform(func(ref metav1.OwnerReference) types.UID {
+		return ref.UID
+	}, gomega.Equal(owner.GetUID()))))
+}
+
+// HaveFinalizer succeeds if the object has the named Finalizer
+func HaveFinalizer(name string) gtypes.GomegaMatcher {
+	return WithFinalizers(gomega.ContainElement(name))
+}
+
+// EachItem succeeds if every item of the list matches the matcher, including
+// when the list is empty
+func EachItem(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return &eachItemMatcher{matcher: matcher}
+}
+
+// EachHaveOwnerReferenceTo succeeds if the list isn't empty and each of its
+// items has an OwnerReference to the owner
+//
+//	m.Eventually(&corev1.ConfigMapList{}, timeout, client.InNamespace(ns)).Should(
+//		matchers.EachHaveOwnerReferenceTo(deployment),
+//	)
+func EachHaveOwnerReferenceTo(owner Object) gtypes.GomegaMatcher {
+	return gomega.And(WithItems(gomega.Not(gomega.BeEmpty())), EachItem(HaveOwnerReferenceTo(owner)))
+}
+
+// NoneHaveOwnerReferenceTo succeeds if none of the items of the list has an
+// OwnerReference to the owner
+func NoneHaveOwnerReferenceTo(owner Object) gtypes.GomegaMatcher {
+	return EachItem(gomega.Not(HaveOwnerReferenceTo(owner)))
+}
+
+// EachHaveFinalizer succeeds if the list isn't empty and each of its items
+// has the named Finalizer
+func EachHaveFinalizer(name string) gtypes.GomegaMatcher {
+	return gomega.And(WithItems(gomega.Not(gomega.BeEmpty())), EachItem(HaveFinalizer(name)))
+}
+
+// NoneHaveFinalizer succeeds if none of the items of the list has the named
+// Finalizer
+func NoneHaveFinalizer(name string) gtypes.GomegaMatcher {
+	return EachItem(gomega.Not(HaveFinalizer(name)))
+}
+
+// eachItemMatcher matches lists whose every item matches its matcher. It
+// remembers the first item which didn't, to explain the failure.
+type eachItemMatcher struct {
+	matcher gtypes.GomegaMatcher
+
+	failedItem    runtime.Object
+	failedMessage string
+}
+
+func (m *eachItemMatcher) Match(actual interface{}) (bool, error) {
+	list, ok := actual.(runtime.Object)
+	if !ok || !meta.IsListType(list) {
+		return false, fmt.Errorf("EachItem expects a list, got\n%s", format.Object(actual, 1))
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range items {
+		matched, err := m.matcher.Match(item)
+		if err != nil {
+			return false, err
+		}
+		if !matched {
+			m.failedItem = item
+			m.failedMessage = m.matcher.FailureMessage(item)
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (m *eachItemMatcher) FailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected every item of the list to match, but %s didn't:\n%s", itemName(m.failedItem), m.failedMessage)
+}
+
+func (m *eachItemMatcher) NegatedFailureMessage(actual interface{}) string {
+	return fmt.Sprintf("Expected some item of the list not to match, but every item matched:\n%s", format.Object(m.matcher, 1))
+}
+
+// itemName returns the namespaced name of an item of a list
+func itemName(item runtime.Object) string {
+	accessor, err := meta.Accessor(item)
+	if err != nil {
+		return format.Object(item, 1)
+	}
+	if accessor.GetNamespace() == "" {
+		return accessor.GetName()
+	}
+	return accessor.GetNamespace() + "/" + accessor.GetName()
+}
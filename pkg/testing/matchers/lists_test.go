@@ -0,0 +1,80 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave list matchers Suite", func() {
+	var m *Matcher
+	owner := &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "owner", UID: types.UID("owner-uid")}}
+	ref := metav1.OwnerReference{APIVersion: "apps/v1", Kind: "Deployment", Name: "owner", UID: owner.UID}
+
+	configMap := func(namespace, name string, refs []metav1.OwnerReference, finalizers ...string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+			Namespace:       namespace,
+			Name:            name,
+			OwnerReferences: refs,
+			Finalizers:      finalizers,
+		}}
+	}
+
+	BeforeEach(func() {
+		m = &Matcher{Client: fake.NewFakeClient(
+			configMap("default", "first", []metav1.OwnerReference{ref}, "example.com/finalizer"),
+			configMap("default", "second", []metav1.OwnerReference{ref}, "example.com/finalizer"),
+			configMap("other", "orphan", nil),
+		)}
+	})
+
+	It("matches lists whose every item is owned", func() {
+		m.Eventually(&corev1.ConfigMapList{}, client.InNamespace("default")).Should(EachHaveOwnerReferenceTo(owner))
+		m.Eventually(&corev1.ConfigMapList{}, client.InNamespace("other")).Should(NoneHaveOwnerReferenceTo(owner))
+		Expect(&corev1.ConfigMapList{}).To(NoneHaveOwnerReferenceTo(owner))
+		Expect(&corev1.ConfigMapList{}).NotTo(EachHaveOwnerReferenceTo(owner))
+	})
+
+	It("explains which item didn't match", func() {
+		list := &corev1.ConfigMapList{}
+		Expect(m.Client.List(context.TODO(), list)).To(Succeed())
+		matcher := EachHaveOwnerReferenceTo(owner)
+		Expect(matcher.Match(list)).To(BeFalse())
+		Expect(matcher.FailureMessage(list)).To(ContainSubstring("other/orphan didn't"))
+		Expect(list).NotTo(NoneHaveOwnerReferenceTo(owner))
+	})
+
+	It("matches lists by the Finalizers of their items", func() {
+		m.Eventually(&corev1.ConfigMapList{}, client.InNamespace("default")).Should(EachHaveFinalizer("example.com/finalizer"))
+		m.Eventually(&corev1.ConfigMapList{}, client.InNamespace("other")).Should(NoneHaveFinalizer("example.com/finalizer"))
+		m.Eventually(&corev1.ConfigMapList{}).ShouldNot(NoneHaveFinalizer("example.com/finalizer"))
+	})
+
+	It("rejects objects which aren't lists", func() {
+		_, err := EachItem(HaveFinalizer("example.com/finalizer")).Match(owner)
+		Expect(err).To(HaveOccurred())
+	})
+})
@@ -0,0 +1,449 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/onsi/gomega"
+	gtypes "github.com/onsi/gomega/types"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+)
+
+// Matcher has Gomega Matchers that use the controller-runtime client
+type Matcher struct {
+	Client client.Client
+
+	// Scheme is used to construct new objects of the same type as those
+	// passed to the Matcher. If nil, the client-go scheme is used.
+	Scheme *runtime.Scheme
+
+	ctx context.Context
+}
+
+// WithContext returns a copy of the Matcher that sends every request with
+// the given context. Once the context is done, polling stops and the
+// assertion fails with the context's error.
+func (m *Matcher) WithContext(ctx context.Context) *Matcher {
+	c := *m
+	c.ctx = ctx
+	return &c
+}
+
+// context returns the context requests are sent with
+func (m *Matcher) context() context.Context {
+	if m.ctx == nil {
+		return context.TODO()
+	}
+	return m.ctx
+}
+
+// pollContext returns the context for a request made while polling. It
+// panics if the context is done so that the assertion stops immediately.
+func (m *Matcher) pollContext() context.Context {
+	ctx := m.context()
+	if err := ctx.Err(); err != nil {
+		panic(err)
+	}
+	return ctx
+}
+
+// Object is the combination of two interfaces as a helper for passing
+// Kubernetes objects between methods
+type Object interface {
+	runtime.Object
+	metav1.Object
+}
+
+// UpdateFunc modifies the object fetched from the API server before sending
+// the update
+type UpdateFunc func(Object) Object
+
+// Create creates the object on the API server
+func (m *Matcher) Create(obj Object, extras ...interface{}) gomega.GomegaAssertion {
+	err := m.Client.Create(m.context(), obj)
+	return gomega.Expect(err, extras)
+}
+
+// Delete deletes the object from the API server
+func (m *Matcher) Delete(obj Object, extras ...interface{}) gomega.GomegaAssertion {
+	err := m.Client.Delete(m.context(), obj)
+	return gomega.Expect(err, extras)
+}
+
+// Update udpates the object on the API server by fetching the object
+// and applying a mutating UpdateFunc before sending the update
+func (m *Matcher) Update(obj Object, fn UpdateFunc, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	key := types.NamespacedName{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+	update := func() error {
+		err := m.Client.Get(m.pollContext(), key, obj)
+		if err != nil {
+			return err
+		}
+		return m.Client.Update(m.pollContext(), fn(obj))
+	}
+	return gomega.Eventually(update, intervals...)
+}
+
+// UpdateStatus updates the object's status on the API server by fetching the
+// object and applying a mutating UpdateFunc before sending the update
+func (m *Matcher) UpdateStatus(obj Object, fn UpdateFunc, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	key := types.NamespacedName{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+	update := func() error {
+		err := m.Client.Get(m.pollContext(), key, obj)
+		if err != nil {
+			return err
+		}
+		return m.Client.Status().Update(m.pollContext(), fn(obj))
+	}
+	return gomega.Eventually(update, intervals...)
+}
+
+// Patch sends the patch for the object to the API server until it succeeds.
+// The object is updated with the response, so patches built with
+// client.MergeFrom should be created from a copy of the object before it
+// was modified.
+func (m *Matcher) Patch(obj Object, patch client.Patch, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	send := func() error {
+		return m.Client.Patch(m.pollContext(), obj, patch)
+	}
+	return gomega.Eventually(send, intervals...)
+}
+
+// Apply sends the object to the API server as a server-side apply patch
+// owned by the given field manager until it succeeds. Further
+// client.PatchOptions, such as client.ForceOwnership, may be passed alongside
+// the intervals. The API server must have server-side apply enabled.
+func (m *Matcher) Apply(obj Object, fieldManager string, args ...interface{}) gomega.GomegaAsyncAssertion {
+	opts := []client.PatchOption{client.FieldOwner(fieldManager)}
+	var intervals []interface{}
+	for _, arg := range args {
+		if opt, ok := arg.(client.PatchOption); ok {
+			opts = append(opts, opt)
+			continue
+		}
+		intervals = append(intervals, arg)
+	}
+
+	// Apply patches must state the object's apiVersion and kind
+	if obj.GetObjectKind().GroupVersionKind().Empty() {
+		gvk, err := apiutil.GVKForObject(obj, m.scheme())
+		if err != nil {
+			panic(err)
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+
+	send := func() error {
+		return m.Client.Patch(m.pollContext(), obj, client.Apply, opts...)
+	}
+	return gomega.Eventually(send, intervals...)
+}
+
+// Get gets the object from the API server
+func (m *Matcher) Get(obj Object, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	key := types.NamespacedName{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+	get := func() error {
+		return m.Client.Get(m.pollContext(), key, obj)
+	}
+	return gomega.Eventually(get, intervals...)
+}
+
+// Consistently continually gets the object from the API for comparison
+func (m *Matcher) Consistently(obj Object, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	return m.consistentlyObject(obj, intervals...)
+}
+
+// consistentlyObject gets an individual object from the API server
+func (m *Matcher) consistentlyObject(obj Object, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	key := types.NamespacedName{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+	get := func() Object {
+		err := m.Client.Get(m.pollContext(), key, obj)
+		if err != nil {
+			panic(err)
+		}
+		return obj
+	}
+	return gomega.Consistently(get, intervals...)
+}
+
+// Eventually continually gets the object from the API for comparison.
+// Lists may be scoped by passing client.ListOptions, such as
+// client.InNamespace, alongside the intervals.
+func (m *Matcher) Eventually(obj runtime.Object, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	// If the object is a list, return a list
+	if meta.IsListType(obj) {
+		opts, intervals := splitListOptions(intervals)
+		return m.eventuallyList(obj, opts, intervals...)
+	}
+	if o, ok := obj.(Object); ok {
+		return m.eventuallyObject(o, intervals...)
+	}
+	//Should not get here
+	panic("Unknown object.")
+}
+
+// eventuallyObject gets an individual object from the API server
+func (m *Matcher) eventuallyObject(obj Object, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+
+	key := types.NamespacedName{
+		Name:      obj.GetName(),
+		Namespace: obj.GetNamespace(),
+	}
+
+	get := func() Object {
+		u, ok := m.newObject(obj).(Object)
+		if !ok {
+			panic("Unknown Object type.")
+		}
+
+		err := m.Client.Get(m.pollContext(), key, u)
+		if err != nil {
+			panic(err)
+		}
+
+		return u
+	}
+	return gomega.Eventually(get, intervals...)
+}
+
+// eventuallyList gets a list type  from the API server
+func (m *Matcher) eventuallyList(obj runtime.Object, opts []client.ListOption, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	list := func() runtime.Object {
+		u := m.newObject(obj)
+		err := m.Client.List(m.pollContext(), u, opts...)
+		if err != nil {
+			panic(err)
+		}
+		return u
+	}
+	return gomega.Eventually(list, intervals...)
+}
+
+// splitListOptions separates the client.ListOptions from the intervals
+func splitListOptions(args []interface{}) ([]client.ListOption, []interface{}) {
+	var opts []client.ListOption
+	var intervals []interface{}
+	for _, arg := range args {
+		if opt, ok := arg.(client.ListOption); ok {
+			opts = append(opts, opt)
+			continue
+		}
+		intervals = append(intervals, arg)
+	}
+	return opts, intervals
+}
+
+// scheme returns the Matcher's Scheme, defaulting to the client-go scheme
+func (m *Matcher) scheme() *runtime.Scheme {
+	if m.Scheme == nil {
+		return scheme.Scheme
+	}
+	return m.Scheme
+}
+
+// newObject constructs an empty object of the same type as obj using the
+// Matcher's Scheme. Unstructured objects keep the GroupVersionKind of obj.
+func (m *Matcher) newObject(obj runtime.Object) runtime.Object {
+	switch u := obj.(type) {
+	case *unstructured.Unstructured:
+		n := &unstructured.Unstructured{}
+		n.SetGroupVersionKind(u.GroupVersionKind())
+		return n
+	case *unstructured.UnstructuredList:
+		n := &unstructured.UnstructuredList{}
+		n.SetGroupVersionKind(u.GroupVersionKind())
+		return n
+	}
+
+	s := m.scheme()
+	gvk, err := apiutil.GVKForObject(obj, s)
+	if err != nil {
+		panic(err)
+	}
+	u, err := s.New(gvk)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+// WithAnnotations returns the object's Annotations
+func WithAnnotations(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) map[string]string {
+		return obj.GetAnnotations()
+	}, matcher)
+}
+
+// WithFinalizers returns the object's Finalizers
+func WithFinalizers(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []string {
+		return obj.GetFinalizers()
+	}, matcher)
+}
+
+// WithItems returns the lists Finalizers
+func WithItems(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj runtime.Object) []runtime.Object {
+		items, err := meta.ExtractList(obj)
+		if err != nil {
+			panic(err)
+		}
+		return items
+	}, matcher)
+}
+
+// WithOwnerReferences returns the object's OwnerReferences
+func WithOwnerReferences(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []metav1.OwnerReference {
+		return obj.GetOwnerReferences()
+	}, matcher)
+}
+
+// WithLabels returns the object's Labels
+func WithLabels(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) map[string]string {
+		return obj.GetLabels()
+	}, matcher)
+}
+
+// WithPodTemplateAnnotations returns the PodTemplate's annotations
+func WithPodTemplateAnnotations(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) map[string]string {
+		return podTemplateOf(obj).GetAnnotations()
+	}, matcher)
+}
+
+// WithPodTemplateLabels returns the PodTemplate's labels
+func WithPodTemplateLabels(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) map[string]string {
+		return podTemplateOf(obj).GetLabels()
+	}, matcher)
+}
+
+// WithContainers returns the PodTemplate's containers
+func WithContainers(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []corev1.Container {
+		return podTemplateOf(obj).Spec.Containers
+	}, matcher)
+}
+
+// WithContainerEnv returns the environment of the named container in the
+// PodTemplate
+func WithContainerEnv(container string, matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []corev1.EnvVar {
+		for _, c := range podTemplateOf(obj).Spec.Containers {
+			if c.Name == container {
+				return c.Env
+			}
+		}
+		panic(fmt.Sprintf("Unknown container %q.", container))
+	}, matcher)
+}
+
+// WithVolumes returns the PodTemplate's volumes
+func WithVolumes(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) []corev1.Volume {
+		return podTemplateOf(obj).Spec.Volumes
+	}, matcher)
+}
+
+// WithReplicas returns the number of replicas of a Deployment or StatefulSet,
+// defaulting to 1 as the API server does
+func WithReplicas(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) int32 {
+		var replicas *int32
+		switch o := obj.(type) {
+		case *appsv1.Deployment:
+			replicas = o.Spec.Replicas
+		case *appsv1.StatefulSet:
+			replicas = o.Spec.Replicas
+		case *unstructured.Unstructured:
+			value, found, err := unstructured.NestedInt64(o.Object, "spec", "replicas")
+			if err != nil {
+				panic(err)
+			}
+			if found {
+				r := int32(value)
+				replicas = &r
+			}
+		default:
+			panic("Unknown replicated type.")
+		}
+		if replicas == nil {
+			return 1
+		}
+		return *replicas
+	}, matcher)
+}
+
+// podTemplateOf returns the PodTemplate of a Deployment, StatefulSet,
+// DaemonSet or unstructured object with a spec.template field
+func podTemplateOf(obj Object) *corev1.PodTemplateSpec {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return &o.Spec.Template
+	case *appsv1.StatefulSet:
+		return &o.Spec.Template
+	case *appsv1.DaemonSet:
+		return &o.Spec.Template
+	case *unstructured.Unstructured:
+		fields, found, err := unstructured.NestedMap(o.Object, "spec", "template")
+		if err != nil {
+			panic(err)
+		}
+		template := &corev1.PodTemplateSpec{}
+		if found {
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(fields, template); err != nil {
+				panic(err)
+			}
+		}
+		return template
+	default:
+		panic("Unknown pod template type.")
+	}
+}
+
+// WithDeletionTimestamp returns the objects Deletion Timestamp
+func WithDeletionTimestamp(matcher gtypes.GomegaMatcher) gtypes.GomegaMatcher {
+	return gomega.WithTransform(func(obj Object) *metav1.Time {
+		return obj.GetDeletionTimestamp()
+	}, matcher)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"fmt"
+
+	"github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// revisionAnnotation is set by the Deployment controller on Deployments
+	// and their ReplicaSets
+	revisionAnnotation = "deployment.kubernetes.io/revision"
+
+	// podTemplateHashLabel identifies the ReplicaSet a Pod belongs to
+	podTemplateHashLabel = appsv1.DefaultDeploymentUniqueLabelKey
+
+	// controllerRevisionHashLabel identifies the ControllerRevision of the
+	// Pods of StatefulSets and DaemonSets
+	controllerRevisionHashLabel = appsv1.ControllerRevisionHashLabelKey
+)
+
+// Revision returns the revision of the current PodTemplate of a Deployment,
+// StatefulSet or DaemonSet, as found in the labels of its Pods. It is empty
+// until the workload's controller has created the revision. Pass it to
+// EventuallyRestarted before changing the workload's configuration.
+func (m *Matcher) Revision(obj Object) (string, error) {
+	return m.revision(obj)
+}
+
+// EventuallyRestarted waits until the workload has rolled from the previous
+// revision: its controller has created a new revision and every one of its
+// Pods is of that revision and Ready. It requires controllers creating Pods,
+// so it cannot be used with envtest alone.
+//
+//	previous, err := m.Revision(deployment)
+//	Expect(err).NotTo(HaveOccurred())
+//	m.Update(configMap, modify, timeout).Should(Succeed())
+//	m.EventuallyRestarted(deployment, previous, timeout).Should(Succeed())
+func (m *Matcher) EventuallyRestarted(obj Object, previous string, intervals ...interface{}) gomega.GomegaAsyncAssertion {
+	restarted := func() error {
+		current, ok := m.newObject(obj).(Object)
+		if !ok {
+			panic("Unknown Object type.")
+		}
+		key := client.ObjectKey{Namespace: obj.GetNamespace(), Name: obj.GetName()}
+		if err := m.Client.Get(m.pollContext(), key, current); err != nil {
+			return err
+		}
+		revision, err := m.revision(current)
+		if err != nil {
+			return err
+		}
+		if revision == "" || revision == previous {
+			return fmt.Errorf("%s has not created a new revision since %q", key, previous)
+		}
+		return m.rolledOut(current, revision)
+	}
+	return gomega.Eventually(restarted, intervals...)
+}
+
+// revision returns the current revision of the workload
+func (m *Matcher) revision(obj Object) (string, error) {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		// The ReplicaSet of the current template has the Deployment's revision
+		want := o.GetAnnotations()[revisionAnnotation]
+		if want == "" {
+			return "", nil
+		}
+		replicaSets := &appsv1.ReplicaSetList{}
+		if err := m.listOwned(o, replicaSets, o.Spec.Selector); err != nil {
+			return "", err
+		}
+		for _, rs := range replicaSets.Items {
+			if isOwnedBy(&rs, o) && rs.GetAnnotations()[revisionAnnotation] == want {
+				return rs.GetLabels()[podTemplateHashLabel], nil
+			}
+		}
+		return "", nil
+	case *appsv1.StatefulSet:
+		// StatefulSet Pods are labelled with the name of their revision
+		return o.Status.UpdateRevision, nil
+	case *appsv1.DaemonSet:
+		// DaemonSet Pods are labelled with the hash of their revision, which
+		// the newest ControllerRevision carries as a label
+		revisions := &appsv1.ControllerRevisionList{}
+		if err := m.listOwned(o, revisions, o.Spec.Selector); err != nil {
+			return "", err
+		}
+		var newest *appsv1.ControllerRevision
+		for i := range revisions.Items {
+			cr := &revisions.Items[i]
+			if isOwnedBy(cr, o) && (newest == nil || cr.Revision > newest.Revision) {
+				newest = cr
+			}
+		}
+		if newest == nil {
+			return "", nil
+		}
+		return newest.GetLabels()[controllerRevisionHashLabel], nil
+	default:
+		return "", fmt.Errorf("unsupported workload type %T", obj)
+	}
+}
+
+// rolledOut returns an error unless every Pod of the workload is of the
+// revision and Ready, and there are as many as the workload wants
+func (m *Matcher) rolledOut(obj Object, revision string) error {
+	label := controllerRevisionHashLabel
+	var selector *metav1.LabelSelector
+	var want int32
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		label = podTemplateHashLabel
+		selector = o.Spec.Selector
+		want = replicasOf(o.Spec.Replicas)
+	case *appsv1.StatefulSet:
+		selector = o.Spec.Selector
+		want = replicasOf(o.Spec.Replicas)
+	case *appsv1.DaemonSet:
+		selector = o.Spec.Selector
+		want = o.Status.DesiredNumberScheduled
+	}
+
+	pods := &corev1.PodList{}
+	if err := m.listOwned(obj, pods, selector); err != nil {
+		return err
+	}
+	var ready int32
+	for _, pod := range pods.Items {
+		if pod.GetLabels()[label] != revision {
+			return fmt.Errorf("pod %s/%s is still of revision %q", pod.GetNamespace(), pod.GetName(), pod.GetLabels()[label])
+		}
+		if pod.GetDeletionTimestamp() == nil && isPodReady(&pod) {
+			ready++
+		}
+	}
+	if ready < want {
+		return fmt.Errorf("%d of %d pods of revision %q are ready", ready, want, revision)
+	}
+	return nil
+}
+
+// listOwned lists the objects matching the workload's selector in its
+// namespace
+func (m *Matcher) listOwned(obj Object, list runtime.Object, selector *metav1.LabelSelector) error {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return err
+	}
+	return m.Client.List(m.pollContext(), list, client.InNamespace(obj.GetNamespace()), client.MatchingLabelsSelector{Selector: s})
+}
+
+// isOwnedBy returns whether the owner is the controller of the object
+func isOwnedBy(obj metav1.Object, owner metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	return ref != nil && ref.UID == owner.GetUID()
+}
+
+// isPodReady returns whether the Pod's Ready condition is true
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// replicasOf defaults the number of replicas to 1 as the API server does
+func replicasOf(replicas *int32) int32 {
+	if replicas == nil {
+		return 1
+	}
+	return *replicas
+}
@@ -0,0 +1,195 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package matchers
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave restarted matchers Suite", func() {
+	labels := map[string]string{"app": "example"}
+	selector := &metav1.LabelSelector{MatchLabels: labels}
+	replicas := int32(2)
+	controller := true
+
+	// owned returns metadata of an object controlled by the owner
+	owned := func(owner Object, kind, name string, extra map[string]string) metav1.ObjectMeta {
+		objLabels := map[string]string{}
+		for k, v := range labels {
+			objLabels[k] = v
+		}
+		for k, v := range extra {
+			objLabels[k] = v
+		}
+		return metav1.ObjectMeta{
+			Namespace:       "default",
+			Name:            name,
+			Labels:          objLabels,
+			OwnerReferences: []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: kind, Name: owner.GetName(), UID: owner.GetUID(), Controller: &controller}},
+		}
+	}
+
+	// pod returns a Ready Pod of the owner labelled with the revision
+	pod := func(owner Object, kind, name, label, revision string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: owned(owner, kind, name, map[string]string{label: revision}),
+			Status:     corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}},
+		}
+	}
+
+	// restarted returns whether the workload rolled from the previous
+	// revision
+	restarted := func(obj Object, previous string, objs ...runtime.Object) bool {
+		m := &Matcher{Client: fake.NewFakeClient(append(objs, obj)...)}
+		failures := InterceptGomegaFailures(func() {
+			m.EventuallyRestarted(obj, previous, 100*time.Millisecond, 10*time.Millisecond).Should(Succeed())
+		})
+		return len(failures) == 0
+	}
+
+	Context("with a Deployment", func() {
+		var d *appsv1.Deployment
+		var replicaSets []runtime.Object
+
+		BeforeEach(func() {
+			d = &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: types.UID("deployment-uid"), Annotations: map[string]string{revisionAnnotation: "2"}},
+				Spec:       appsv1.DeploymentSpec{Replicas: &replicas, Selector: selector},
+			}
+			old := &appsv1.ReplicaSet{ObjectMeta: owned(d, "Deployment", "example-old", map[string]string{podTemplateHashLabel: "old"})}
+			old.SetAnnotations(map[string]string{revisionAnnotation: "1"})
+			current := &appsv1.ReplicaSet{ObjectMeta: owned(d, "Deployment", "example-new", map[string]string{podTemplateHashLabel: "new"})}
+			current.SetAnnotations(map[string]string{revisionAnnotation: "2"})
+			replicaSets = []runtime.Object{old, current}
+		})
+
+		It("returns the pod-template-hash of the current ReplicaSet", func() {
+			m := &Matcher{Client: fake.NewFakeClient(append(replicaSets, d)...)}
+			Expect(m.Revision(d)).To(Equal("new"))
+		})
+
+		It("matches once every Pod is of the new revision", func() {
+			Expect(restarted(d, "old", append(replicaSets,
+				pod(d, "ReplicaSet", "example-1", podTemplateHashLabel, "new"),
+				pod(d, "ReplicaSet", "example-2", podTemplateHashLabel, "new"),
+			)...)).To(BeTrue())
+		})
+
+		It("doesn't match while Pods of the previous revision remain", func() {
+			Expect(restarted(d, "old", append(replicaSets,
+				pod(d, "ReplicaSet", "example-1", podTemplateHashLabel, "new"),
+				pod(d, "ReplicaSet", "example-2", podTemplateHashLabel, "old"),
+			)...)).To(BeFalse())
+		})
+
+		It("doesn't match without a new revision", func() {
+			Expect(restarted(d, "new", append(replicaSets,
+				pod(d, "ReplicaSet", "example-1", podTemplateHashLabel, "new"),
+				pod(d, "ReplicaSet", "example-2", podTemplateHashLabel, "new"),
+			)...)).To(BeFalse())
+		})
+	})
+
+	Context("with a StatefulSet", func() {
+		var ss *appsv1.StatefulSet
+
+		BeforeEach(func() {
+			ss = &appsv1.StatefulSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: types.UID("statefulset-uid")},
+				Spec:       appsv1.StatefulSetSpec{Replicas: &replicas, Selector: selector},
+				Status:     appsv1.StatefulSetStatus{CurrentRevision: "example-old", UpdateRevision: "example-new"},
+			}
+		})
+
+		It("matches once every Pod is of the new revision", func() {
+			Expect(restarted(ss, "example-old",
+				pod(ss, "StatefulSet", "example-0", controllerRevisionHashLabel, "example-new"),
+				pod(ss, "StatefulSet", "example-1", controllerRevisionHashLabel, "example-new"),
+			)).To(BeTrue())
+		})
+
+		It("doesn't match while Pods of the previous revision remain", func() {
+			Expect(restarted(ss, "example-old",
+				pod(ss, "StatefulSet", "example-0", controllerRevisionHashLabel, "example-old"),
+				pod(ss, "StatefulSet", "example-1", controllerRevisionHashLabel, "example-new"),
+			)).To(BeFalse())
+		})
+
+		It("doesn't match while Pods are missing or not Ready", func() {
+			unready := pod(ss, "StatefulSet", "example-1", controllerRevisionHashLabel, "example-new")
+			unready.Status.Conditions[0].Status = corev1.ConditionFalse
+			Expect(restarted(ss, "example-old",
+				pod(ss, "StatefulSet", "example-0", controllerRevisionHashLabel, "example-new"),
+				unready,
+			)).To(BeFalse())
+			Expect(restarted(ss, "example-old",
+				pod(ss, "StatefulSet", "example-0", controllerRevisionHashLabel, "example-new"),
+			)).To(BeFalse())
+		})
+	})
+
+	Context("with a DaemonSet", func() {
+		var ds *appsv1.DaemonSet
+		var revisions []runtime.Object
+
+		BeforeEach(func() {
+			ds = &appsv1.DaemonSet{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "example", UID: types.UID("daemonset-uid")},
+				Spec:       appsv1.DaemonSetSpec{Selector: selector},
+				Status:     appsv1.DaemonSetStatus{DesiredNumberScheduled: 2},
+			}
+			revisions = []runtime.Object{
+				&appsv1.ControllerRevision{ObjectMeta: owned(ds, "DaemonSet", "example-old", map[string]string{controllerRevisionHashLabel: "old"}), Revision: 1},
+				&appsv1.ControllerRevision{ObjectMeta: owned(ds, "DaemonSet", "example-new", map[string]string{controllerRevisionHashLabel: "new"}), Revision: 2},
+			}
+		})
+
+		It("returns the hash of the newest ControllerRevision", func() {
+			m := &Matcher{Client: fake.NewFakeClient(append(revisions, ds)...)}
+			Expect(m.Revision(ds)).To(Equal("new"))
+		})
+
+		It("matches once every Pod is of the new revision", func() {
+			Expect(restarted(ds, "old", append(revisions,
+				pod(ds, "DaemonSet", "example-a", controllerRevisionHashLabel, "new"),
+				pod(ds, "DaemonSet", "example-b", controllerRevisionHashLabel, "new"),
+			)...)).To(BeTrue())
+		})
+
+		It("doesn't match while Pods of the previous revision remain", func() {
+			Expect(restarted(ds, "old", append(revisions,
+				pod(ds, "DaemonSet", "example-a", controllerRevisionHashLabel, "new"),
+				pod(ds, "DaemonSet", "example-b", controllerRevisionHashLabel, "old"),
+			)...)).To(BeFalse())
+		})
+	})
+
+	It("returns an error for unsupported objects", func() {
+		m := &Matcher{Client: fake.NewFakeClient()}
+		_, err := m.Revision(&corev1.ConfigMap{})
+		Expect(err).To(HaveOccurred())
+	})
+})
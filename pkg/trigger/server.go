@@ -0,0 +1,229 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package trigger contains an HTTP server that lets external systems, such as
+secret rotation hooks or CI pipelines, restart the workloads managed by Wave
+*/
+package trigger
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// Path is the path the server receives trigger requests on
+const Path = "/trigger"
+
+// Request names an object that has changed.
+// For a ConfigMap or Secret, every workload Wave manages that uses the object
+// is restarted. For a Deployment, StatefulSet or DaemonSet, that workload is
+// restarted directly, which is useful when its configuration lives outside of
+// Kubernetes.
+type Request struct {
+	Namespace string `json:"namespace"`
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+}
+
+// Response lists the workloads that were restarted
+type Response struct {
+	Triggered []string `json:"triggered"`
+}
+
+// Server receives trigger requests over HTTP
+type Server struct {
+	client  client.Client
+	address string
+	token   string
+	now     func() time.Time
+}
+
+// NewServer constructs a Server listening on address. Requests must present
+// token as a bearer token.
+func NewServer(c client.Client, address, token string) *Server {
+	return &Server{
+		client:  c,
+		address: address,
+		token:   token,
+		now:     time.Now,
+	}
+}
+
+// Start runs the server until the stop channel is closed.
+// It implements the controller-runtime manager.Runnable interface.
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	srv := &http.Server{Addr: s.address, Handler: mux}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return fmt.Errorf("error serving trigger requests: %v", err)
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return srv.Shutdown(ctx)
+	}
+}
+
+// ServeHTTP handles a single trigger request
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	req := Request{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %v", err), http.StatusBadRequest)
+		return
+	}
+	if req.Namespace == "" || req.Kind == "" || req.Name == "" {
+		http.Error(w, "namespace, kind and name are required", http.StatusBadRequest)
+		return
+	}
+
+	triggered, err := s.Trigger(r.Context(), req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.IsNotFound(err):
+			status = http.StatusNotFound
+		case errors.IsBadRequest(err):
+			status = http.StatusBadRequest
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	log := logf.Log.WithName("wave")
+	log.V(0).Info("Received trigger request", "namespace", req.Namespace, "kind", req.Kind, "name", req.Name, "triggered", triggered)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(Response{Triggered: triggered})
+}
+
+// authorized checks the request's bearer token in constant time
+func (s *Server) authorized(r *http.Request) bool {
+	expected := []byte("Bearer " + s.token)
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) == 1
+}
+
+// Trigger restarts the workloads affected by the request and returns their
+// names
+func (s *Server) Trigger(ctx context.Context, req Request) ([]string, error) {
+	var owners []workloadRef
+	switch req.Kind {
+	case "ConfigMap", "Secret":
+		var obj core.Object = &corev1.ConfigMap{}
+		if req.Kind == "Secret" {
+			obj = &corev1.Secret{}
+		}
+		err := s.client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, obj)
+		if err != nil {
+			return nil, err
+		}
+		// Wave adds an OwnerReference to each ConfigMap and Secret pointing
+		// at every workload it manages that uses it
+		for _, ref := range obj.GetOwnerReferences() {
+			if ref.APIVersion == appsv1.SchemeGroupVersion.String() && newWorkload(ref.Kind) != nil {
+				owners = append(owners, workloadRef{kind: ref.Kind, name: ref.Name})
+			}
+		}
+	default:
+		if newWorkload(req.Kind) == nil {
+			return nil, errors.NewBadRequest(fmt.Sprintf("unsupported kind %q", req.Kind))
+		}
+		owners = append(owners, workloadRef{kind: req.Kind, name: req.Name})
+	}
+
+	triggered := []string{}
+	value := s.now().UTC().Format(time.RFC3339Nano)
+	for _, owner := range owners {
+		ok, err := s.triggerWorkload(ctx, req.Namespace, owner, value)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			triggered = append(triggered, fmt.Sprintf("%s/%s", owner.kind, owner.name))
+		}
+	}
+	return triggered, nil
+}
+
+// workloadRef identifies a workload within a namespace
+type workloadRef struct {
+	kind string
+	name string
+}
+
+// triggerWorkload sets the TriggerAnnotation on the workload so that Wave
+// restarts it. Workloads that Wave does not manage are left untouched.
+func (s *Server) triggerWorkload(ctx context.Context, namespace string, ref workloadRef, value string) (bool, error) {
+	obj := newWorkload(ref.kind)
+	err := s.client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.name}, obj)
+	if err != nil {
+		return false, err
+	}
+
+	if !core.Managed(obj) {
+		return false, nil
+	}
+	annotations := obj.GetAnnotations()
+	annotations[core.TriggerAnnotation] = value
+	obj.SetAnnotations(annotations)
+	if err := s.client.Update(ctx, obj); err != nil {
+		return false, fmt.Errorf("error updating %s %s/%s: %v", ref.kind, namespace, ref.name, err)
+	}
+	return true, nil
+}
+
+// newWorkload returns an empty object of the given workload kind, or nil if
+// the kind is not a workload Wave manages
+func newWorkload(kind string) core.Object {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+var _ = Describe("Wave trigger Suite", func() {
+	var c client.Client
+	var s *Server
+	var now = time.Date(2019, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	deployment := func(name string, managed bool) *appsv1.Deployment {
+		d := &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name, Annotations: map[string]string{}},
+		}
+		if managed {
+			d.Annotations[core.RequiredAnnotation] = "true"
+		}
+		return d
+	}
+
+	post := func(token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, Path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		return rec
+	}
+
+	triggerOf := func(name string) string {
+		d := &appsv1.Deployment{}
+		Expect(c.Get(context.TODO(), types.NamespacedName{Namespace: "default", Name: name}, d)).To(Succeed())
+		return d.Annotations[core.TriggerAnnotation]
+	}
+
+	BeforeEach(func() {
+		secret := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Namespace: "default",
+				Name:      "credentials",
+				OwnerReferences: []metav1.OwnerReference{
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "managed"},
+					{APIVersion: "apps/v1", Kind: "Deployment", Name: "unmanaged"},
+				},
+			},
+		}
+		c = fake.NewFakeClient(secret, deployment("managed", true), deployment("unmanaged", false))
+		s = NewServer(c, ":0", "secret-token")
+		s.now = func() time.Time { return now }
+	})
+
+	It("rejects requests without the token", func() {
+		rec := post("wrong", `{"namespace":"default","kind":"Deployment","name":"managed"}`)
+		Expect(rec.Code).To(Equal(http.StatusUnauthorized))
+		Expect(triggerOf("managed")).To(BeEmpty())
+	})
+
+	It("restarts the managed consumers of a Secret", func() {
+		rec := post("secret-token", `{"namespace":"default","kind":"Secret","name":"credentials"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+
+		response := Response{}
+		Expect(json.Unmarshal(rec.Body.Bytes(), &response)).To(Succeed())
+		Expect(response.Triggered).To(Equal([]string{"Deployment/managed"}))
+		Expect(triggerOf("managed")).To(Equal("2019-01-02T03:04:05Z"))
+		Expect(triggerOf("unmanaged")).To(BeEmpty())
+	})
+
+	It("restarts a workload directly", func() {
+		rec := post("secret-token", `{"namespace":"default","kind":"Deployment","name":"managed"}`)
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(triggerOf("managed")).To(Equal("2019-01-02T03:04:05Z"))
+	})
+
+	It("returns not found for unknown objects", func() {
+		rec := post("secret-token", `{"namespace":"default","kind":"ConfigMap","name":"missing"}`)
+		Expect(rec.Code).To(Equal(http.StatusNotFound))
+	})
+
+	It("rejects unsupported kinds", func() {
+		rec := post("secret-token", `{"namespace":"default","kind":"Pod","name":"example"}`)
+		Expect(rec.Code).To(Equal(http.StatusBadRequest))
+	})
+})
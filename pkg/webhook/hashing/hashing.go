@@ -0,0 +1,134 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hashing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	toolscache "k8s.io/client-go/tools/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path is the path the configuration hash webhook is served on
+const Path = "/mutate-config-hash"
+
+// AddToManager registers the configuration hash webhook with the Manager's
+// webhook server. The webhook shares the cache the controllers watch
+// ConfigMaps and Secrets through, and the index fed by the informers of
+// their workloads, so that it hashes the same data they do without reading
+// from the API server. The Handler is configured with the controllers'
+// options.
+func AddToManager(mgr manager.Manager, index *core.DependencyIndex, opts ...core.Option) error {
+	synced := []toolscache.InformerSynced{index.HasSynced}
+	for _, obj := range []runtime.Object{&corev1.ConfigMap{}, &corev1.Secret{}} {
+		informer, err := mgr.GetCache().GetInformer(obj)
+		if err != nil {
+			return fmt.Errorf("error getting informer for %T: %v", obj, err)
+		}
+		synced = append(synced, informer.HasSynced)
+	}
+	c := &client.DelegatingClient{Reader: mgr.GetCache(), Writer: mgr.GetClient(), StatusClient: mgr.GetClient()}
+	h := core.NewHandler(c, mgr.GetEventRecorderFor("wave"), opts...)
+	mgr.GetWebhookServer().Register(Path, &admission.Webhook{Handler: webhook.Instrument("config-hash", NewHasher(h, synced...))})
+	return nil
+}
+
+// NewHasher returns an admission Handler which places the configuration
+// hash on Deployments, StatefulSets and DaemonSets as they are created, see
+// core.Handler.Admit. Until every one of synced returns true the cache may
+// miss objects, or reading from it may block, so workloads are left to the
+// controller.
+func NewHasher(h *core.Handler, synced ...toolscache.InformerSynced) admission.Handler {
+	return &hasher{handler: h, synced: synced}
+}
+
+type hasher struct {
+	handler *core.Handler
+	synced  []toolscache.InformerSynced
+}
+
+// Handle implements admission.Handler
+func (m *hasher) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Create || req.Kind.Group != appsv1.GroupName {
+		return admission.Allowed("")
+	}
+	obj := newWorkload(req.Kind.Kind)
+	if obj == nil {
+		return admission.Allowed("")
+	}
+	if !m.hasSynced() {
+		return admission.Allowed("the cache has not synced yet, leaving the configuration hash to the controller")
+	}
+
+	if err := json.Unmarshal(req.Object.Raw, obj); err != nil {
+		return admission.Errored(http.StatusBadRequest, fmt.Errorf("error decoding %s: %v", req.Kind.Kind, err))
+	}
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(req.Namespace)
+	}
+	admitted, err := m.handler.Admit(ctx, obj)
+	if err != nil {
+		log := logf.Log.WithName("webhook")
+		log.Error(err, "Unable to calculate configuration hash", "kind", req.Kind.Kind, "namespace", req.Namespace, "name", obj.GetName())
+		return admission.Allowed(fmt.Sprintf("leaving the configuration hash to the controller: %v", err))
+	}
+	if !admitted {
+		return admission.Allowed("")
+	}
+
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, fmt.Errorf("error encoding %s: %v", req.Kind.Kind, err))
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, raw)
+}
+
+// hasSynced returns true once the cache and the index have synced
+func (m *hasher) hasSynced() bool {
+	for _, synced := range m.synced {
+		if !synced() {
+			return false
+		}
+	}
+	return true
+}
+
+// newWorkload returns an empty workload of the kind, or nil if Wave doesn't
+// manage the kind
+func newWorkload(kind string) core.Object {
+	switch kind {
+	case "Deployment":
+		return &appsv1.Deployment{}
+	case "StatefulSet":
+		return &appsv1.StatefulSet{}
+	case "DaemonSet":
+		return &appsv1.DaemonSet{}
+	}
+	return nil
+}
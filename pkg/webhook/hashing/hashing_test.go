@@ -0,0 +1,153 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hashing
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/test/utils"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Wave configuration hash webhook Suite", func() {
+	var deployment *appsv1.Deployment
+	var c client.Client
+	var synced bool
+
+	BeforeEach(func() {
+		deployment = utils.ExampleDeployment.DeepCopy()
+		deployment.SetAnnotations(map[string]string{core.RequiredAnnotation: "true"})
+		c = fake.NewFakeClient(utils.ExampleConfigMap1.DeepCopy(), utils.ExampleConfigMap2.DeepCopy(), utils.ExampleConfigMap3.DeepCopy(),
+			utils.ExampleSecret1.DeepCopy(), utils.ExampleSecret2.DeepCopy(), utils.ExampleSecret3.DeepCopy())
+		synced = true
+	})
+
+	// createRequest returns a request to create the object
+	createRequest := func(kind string, obj runtime.Object) admission.Request {
+		raw, err := json.Marshal(obj)
+		Expect(err).NotTo(HaveOccurred())
+		return admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Group: "apps", Version: "v1", Kind: kind},
+			Operation: admissionv1beta1.Create,
+			Namespace: deployment.GetNamespace(),
+			Name:      deployment.GetName(),
+			Object:    runtime.RawExtension{Raw: raw},
+		}}
+	}
+
+	handle := func(req admission.Request, opts ...core.Option) admission.Response {
+		h := core.NewHandler(c, record.NewFakeRecorder(10), opts...)
+		return NewHasher(h, func() bool { return synced }).Handle(context.TODO(), req)
+	}
+
+	// patched applies the patches of the response to the object of the
+	// request
+	patched := func(req admission.Request, resp admission.Response) *appsv1.Deployment {
+		raw, err := json.Marshal(resp.Patches)
+		Expect(err).NotTo(HaveOccurred())
+		patch, err := jsonpatch.DecodePatch(raw)
+		Expect(err).NotTo(HaveOccurred())
+		result, err := patch.Apply(req.Object.Raw)
+		Expect(err).NotTo(HaveOccurred())
+		d := &appsv1.Deployment{}
+		Expect(json.Unmarshal(result, d)).To(Succeed())
+		return d
+	}
+
+	It("places the hash the controller would on created workloads", func() {
+		expected, err := core.CalculateConfigHash(context.TODO(), c, deployment.DeepCopy())
+		Expect(err).NotTo(HaveOccurred())
+
+		req := createRequest("Deployment", deployment)
+		resp := handle(req)
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).NotTo(BeEmpty())
+		Expect(patched(req, resp).Spec.Template.Annotations).To(HaveKeyWithValue(core.ConfigHashAnnotation, expected))
+	})
+
+	It("leaves workloads to the controller until the cache has synced", func() {
+		synced = false
+		resp := handle(createRequest("Deployment", deployment))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("leaves untracked workloads as they are", func() {
+		deployment.SetAnnotations(nil)
+		resp := handle(createRequest("Deployment", deployment))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("leaves workloads whose first rollout is held back to the controller", func() {
+		deployment.SetAnnotations(map[string]string{core.RequiredAnnotation: string(core.ManualPolicy)})
+		resp := handle(createRequest("Deployment", deployment))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("leaves workloads to the controller when their sources are missing", func() {
+		c = fake.NewFakeClient()
+		resp := handle(createRequest("Deployment", deployment))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("leaves workloads to the controller while their Secrets await data", func() {
+		secret := utils.ExampleSecret1.DeepCopy()
+		secret.SetAnnotations(map[string]string{core.AwaitDataAnnotation: "true"})
+		secret.SetCreationTimestamp(metav1.Now())
+		secret.Data = nil
+		c = fake.NewFakeClient(utils.ExampleConfigMap1.DeepCopy(), utils.ExampleConfigMap2.DeepCopy(), utils.ExampleConfigMap3.DeepCopy(),
+			secret, utils.ExampleSecret2.DeepCopy(), utils.ExampleSecret3.DeepCopy())
+
+		resp := handle(createRequest("Deployment", deployment), core.WithAwaitSecretData(time.Hour))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+
+		// Without the option, the empty Secret is hashed right away
+		resp = handle(createRequest("Deployment", deployment))
+		Expect(resp.Patches).NotTo(BeEmpty())
+	})
+
+	It("leaves the kinds the Handler doesn't manage as they are", func() {
+		resp := handle(createRequest("Deployment", deployment), core.WithWorkloadKinds("StatefulSet"))
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+
+	It("allows other operations", func() {
+		req := createRequest("Deployment", deployment)
+		req.Operation = admissionv1beta1.Update
+		resp := handle(req)
+		Expect(resp.Allowed).To(BeTrue())
+		Expect(resp.Patches).To(BeEmpty())
+	})
+})
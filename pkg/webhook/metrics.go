@@ -0,0 +1,116 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// DefaultLatencySLO is how long an admission call may take by default
+// before it counts against the latency SLO
+const DefaultLatencySLO = 100 * time.Millisecond
+
+var (
+	// requestDuration is the latency of the admission calls of each webhook
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wave_webhook_request_duration_seconds",
+		Help:    "Latency of the admission calls Wave's webhooks served, by webhook and result",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"webhook", "result"})
+
+	// sloViolations counts the admission calls slower than the latency SLO
+	sloViolations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wave_webhook_slo_violations_total",
+		Help: "Number of admission calls which took longer than the latency SLO, by webhook",
+	}, []string{"webhook"})
+
+	// sloTarget exports the latency SLO, so that alerts don't repeat it
+	sloTarget = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "wave_webhook_latency_slo_seconds",
+		Help: "Latency an admission call may take before it counts against the SLO",
+	})
+)
+
+func init() {
+	metrics.Registry.MustRegister(requestDuration, sloViolations, sloTarget)
+	sloTarget.Set(DefaultLatencySLO.Seconds())
+}
+
+var (
+	sloMutex sync.RWMutex
+	slo      = DefaultLatencySLO
+)
+
+// SetLatencySLO sets how long the admission calls of instrumented webhooks
+// may take before they count against the latency SLO
+func SetLatencySLO(latency time.Duration) {
+	sloMutex.Lock()
+	defer sloMutex.Unlock()
+	slo = latency
+	sloTarget.Set(latency.Seconds())
+}
+
+// latencySLO returns the latency SLO of admission calls
+func latencySLO() time.Duration {
+	sloMutex.RLock()
+	defer sloMutex.RUnlock()
+	return slo
+}
+
+// Instrument measures the latency of every admission call of the webhook
+// with the given name, counting those slower than the latency SLO
+func Instrument(name string, h admission.Handler) admission.Handler {
+	return &instrumented{name: name, handler: h}
+}
+
+type instrumented struct {
+	name    string
+	handler admission.Handler
+}
+
+// Handle implements admission.Handler
+func (i *instrumented) Handle(ctx context.Context, req admission.Request) admission.Response {
+	start := time.Now()
+	resp := i.handler.Handle(ctx, req)
+	latency := time.Since(start)
+	requestDuration.WithLabelValues(i.name, resultOf(resp)).Observe(latency.Seconds())
+	if latency > latencySLO() {
+		sloViolations.WithLabelValues(i.name).Inc()
+	}
+	return resp
+}
+
+// resultOf classifies the response of an admission call as allowed,
+// patched, denied or errored
+func resultOf(resp admission.Response) string {
+	switch {
+	case resp.Allowed && len(resp.Patches) > 0:
+		return "patched"
+	case resp.Allowed:
+		return "allowed"
+	case resp.Result != nil && resp.Result.Code >= http.StatusInternalServerError:
+		return "errored"
+	}
+	return "denied"
+}
@@ -0,0 +1,76 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	dto "github.com/prometheus/client_model/go"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Wave webhook metrics Suite", func() {
+	// violations returns the number of admission calls of the webhook which
+	// took longer than the SLO
+	violations := func(name string) float64 {
+		metric := &dto.Metric{}
+		Expect(sloViolations.WithLabelValues(name).Write(metric)).To(Succeed())
+		return metric.GetCounter().GetValue()
+	}
+
+	// calls returns the number of admission calls of the webhook with the
+	// result
+	calls := func(name, result string) uint64 {
+		metric := &dto.Metric{}
+		observer := requestDuration.WithLabelValues(name, result).(interface{ Write(*dto.Metric) error })
+		Expect(observer.Write(metric)).To(Succeed())
+		return metric.GetHistogram().GetSampleCount()
+	}
+
+	respond := func(resp admission.Response, delay time.Duration) admission.Handler {
+		return admission.HandlerFunc(func(context.Context, admission.Request) admission.Response {
+			time.Sleep(delay)
+			return resp
+		})
+	}
+
+	AfterEach(func() {
+		SetLatencySLO(DefaultLatencySLO)
+	})
+
+	It("counts admission calls slower than the SLO", func() {
+		SetLatencySLO(10 * time.Millisecond)
+		Instrument("slow", respond(admission.Allowed(""), 20*time.Millisecond)).Handle(context.TODO(), admission.Request{})
+		Instrument("fast", respond(admission.Allowed(""), 0)).Handle(context.TODO(), admission.Request{})
+		Expect(violations("slow")).To(Equal(1.0))
+		Expect(violations("fast")).To(BeZero())
+	})
+
+	It("measures admission calls by result", func() {
+		Instrument("results", respond(admission.Allowed(""), 0)).Handle(context.TODO(), admission.Request{})
+		Instrument("results", respond(admission.Denied("no"), 0)).Handle(context.TODO(), admission.Request{})
+		Instrument("results", respond(admission.Errored(http.StatusInternalServerError, errors.New("failed")), 0)).Handle(context.TODO(), admission.Request{})
+		Expect(calls("results", "allowed")).To(Equal(uint64(1)))
+		Expect(calls("results", "denied")).To(Equal(uint64(1)))
+		Expect(calls("results", "errored")).To(Equal(uint64(1)))
+	})
+})
@@ -0,0 +1,142 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protection
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/pkg/webhook"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1beta1 "k8s.io/apimachinery/pkg/apis/meta/v1beta1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// Path is the path the deletion protection webhook is served on
+const Path = "/validate-config-deletion"
+
+// AddToManager registers the deletion protection webhook with the Manager's
+// webhook server. Workloads are listed with the options.
+func AddToManager(mgr manager.Manager, opts ...client.ListOption) error {
+	mgr.GetWebhookServer().Register(Path, &admission.Webhook{Handler: webhook.Instrument("protect-referenced-config", NewProtector(mgr.GetClient(), opts...))})
+	return nil
+}
+
+// NewProtector returns an admission Handler which denies deleting a ConfigMap
+// or Secret while running workloads tracked by Wave still reference it,
+// unless it has the core.AllowDeletionAnnotation. Workloads are listed with
+// the options.
+func NewProtector(c client.Client, opts ...client.ListOption) admission.Handler {
+	return &protector{client: c, opts: opts}
+}
+
+type protector struct {
+	client client.Client
+	opts   []client.ListOption
+}
+
+// Handle implements admission.Handler
+func (p *protector) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation != admissionv1beta1.Delete {
+		return admission.Allowed("")
+	}
+	kind := req.Kind.Kind
+	if req.Kind.Group != "" || (kind != "ConfigMap" && kind != "Secret") {
+		return admission.Allowed("")
+	}
+
+	meta, err := p.getMetadata(ctx, req)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if meta == nil {
+		return admission.Allowed("")
+	}
+	if meta.GetAnnotations()[core.AllowDeletionAnnotation] == "true" {
+		return admission.Allowed(fmt.Sprintf("%s has the %s annotation", kind, core.AllowDeletionAnnotation))
+	}
+
+	consumers, err := core.Consumers(ctx, p.client, req.Namespace, kind, req.Name, p.opts...)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	var running []string
+	for _, obj := range consumers {
+		if isRunning(obj) {
+			running = append(running, fmt.Sprintf("%s/%s", core.WorkloadKind(obj), obj.GetName()))
+		}
+	}
+	if len(running) == 0 {
+		return admission.Allowed("")
+	}
+	sort.Strings(running)
+	message := fmt.Sprintf("%s %s is still referenced by %s; set the %s annotation to \"true\" to delete it",
+		kind, req.Name, strings.Join(running, ", "), core.AllowDeletionAnnotation)
+	resp := admission.Denied(message)
+	// The API server shows the message rather than the reason to the user
+	resp.Result.Message = message
+	return resp
+}
+
+// getMetadata returns the metadata of the object being deleted, or nil if it
+// no longer exists. API servers before 1.15 don't send the old object with
+// DELETE requests, so it is read from the cluster instead.
+func (p *protector) getMetadata(ctx context.Context, req admission.Request) (metav1.Object, error) {
+	if len(req.OldObject.Raw) > 0 {
+		obj := &metav1beta1.PartialObjectMetadata{}
+		if err := json.Unmarshal(req.OldObject.Raw, obj); err != nil {
+			return nil, fmt.Errorf("error decoding %s: %v", req.Kind.Kind, err)
+		}
+		return obj, nil
+	}
+
+	var obj core.Object = &corev1.ConfigMap{}
+	if req.Kind.Kind == "Secret" {
+		obj = &corev1.Secret{}
+	}
+	err := p.client.Get(ctx, types.NamespacedName{Namespace: req.Namespace, Name: req.Name}, obj)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting %s: %v", req.Kind.Kind, err)
+	}
+	return obj, nil
+}
+
+// isRunning returns true unless the workload is scaled to zero
+func isRunning(obj core.Object) bool {
+	switch o := obj.(type) {
+	case *appsv1.Deployment:
+		return o.Spec.Replicas == nil || *o.Spec.Replicas > 0
+	case *appsv1.StatefulSet:
+		return o.Spec.Replicas == nil || *o.Spec.Replicas > 0
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package protection
+
+import (
+	"context"
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/test/utils"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+var _ = Describe("Wave deletion protection Suite", func() {
+	var deployment *appsv1.Deployment
+	var configMap *corev1.ConfigMap
+
+	BeforeEach(func() {
+		deployment = utils.ExampleDeployment.DeepCopy()
+		deployment.SetAnnotations(map[string]string{core.RequiredAnnotation: "true"})
+		configMap = utils.ExampleConfigMap1.DeepCopy()
+	})
+
+	// deleteRequest returns a request to delete the object, optionally
+	// including the object as API servers from 1.15 do
+	deleteRequest := func(kind string, obj metav1.Object, withOldObject bool) admission.Request {
+		req := admission.Request{AdmissionRequest: admissionv1beta1.AdmissionRequest{
+			Kind:      metav1.GroupVersionKind{Version: "v1", Kind: kind},
+			Operation: admissionv1beta1.Delete,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+		}}
+		if withOldObject {
+			raw, err := json.Marshal(obj)
+			Expect(err).NotTo(HaveOccurred())
+			req.OldObject = runtime.RawExtension{Raw: raw}
+		}
+		return req
+	}
+
+	handle := func(req admission.Request, objs ...runtime.Object) admission.Response {
+		return NewProtector(fake.NewFakeClient(objs...)).Handle(context.TODO(), req)
+	}
+
+	It("denies deleting a ConfigMap referenced by a running workload", func() {
+		resp := handle(deleteRequest("ConfigMap", configMap, true), deployment)
+		Expect(resp.Allowed).To(BeFalse())
+		Expect(resp.Result.Message).To(ContainSubstring("Deployment/example"))
+		Expect(resp.Result.Message).To(ContainSubstring(core.AllowDeletionAnnotation))
+	})
+
+	It("reads the object from the cluster when the request doesn't include it", func() {
+		resp := handle(deleteRequest("ConfigMap", configMap, false), deployment, configMap)
+		Expect(resp.Allowed).To(BeFalse())
+	})
+
+	It("denies deleting a referenced Secret", func() {
+		secret := utils.ExampleSecret1.DeepCopy()
+		resp := handle(deleteRequest("Secret", secret, true), deployment)
+		Expect(resp.Allowed).To(BeFalse())
+	})
+
+	It("allows deleting objects with the bypass annotation", func() {
+		configMap.SetAnnotations(map[string]string{core.AllowDeletionAnnotation: "true"})
+		resp := handle(deleteRequest("ConfigMap", configMap, true), deployment)
+		Expect(resp.Allowed).To(BeTrue())
+	})
+
+	It("allows deleting objects only referenced by untracked workloads", func() {
+		deployment.SetAnnotations(nil)
+		resp := handle(deleteRequest("ConfigMap", configMap, true), deployment)
+		Expect(resp.Allowed).To(BeTrue())
+	})
+
+	It("allows deleting objects only referenced by workloads scaled to zero", func() {
+		replicas := int32(0)
+		deployment.Spec.Replicas = &replicas
+		resp := handle(deleteRequest("ConfigMap", configMap, true), deployment)
+		Expect(resp.Allowed).To(BeTrue())
+	})
+
+	It("allows deleting unreferenced objects", func() {
+		configMap.SetName("unreferenced")
+		resp := handle(deleteRequest("ConfigMap", configMap, true), deployment)
+		Expect(resp.Allowed).To(BeTrue())
+	})
+
+	It("allows other operations", func() {
+		req := deleteRequest("ConfigMap", configMap, true)
+		req.Operation = admissionv1beta1.Update
+		Expect(handle(req, deployment).Allowed).To(BeTrue())
+	})
+})
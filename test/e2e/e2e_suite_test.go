@@ -0,0 +1,59 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/test/reporters"
+	"github.com/wave-k8s/wave/test/utils"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+)
+
+var cfg *rest.Config
+
+func TestE2E(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecsWithDefaultAndCustomReporters(t, "Wave E2E Suite", reporters.Reporters())
+}
+
+var t *envtest.Environment
+
+// apiServerFlags returns envtest's default API server flags with mutating
+// admission webhooks enabled in place of the AlwaysAdmit admission controller
+func apiServerFlags() []string {
+	flags := []string{"--enable-admission-plugins=MutatingAdmissionWebhook"}
+	for _, flag := range envtest.DefaultKubeAPIServerFlags {
+		if !strings.HasPrefix(flag, "--admission-control=") {
+			flags = append(flags, flag)
+		}
+	}
+	return flags
+}
+
+var _ = BeforeSuite(func() {
+	t, cfg = utils.StartEnvironment(filepath.Join("..", ".."), apiServerFlags()...)
+})
+
+var _ = AfterSuite(func() {
+	utils.StopEnvironment(t)
+})
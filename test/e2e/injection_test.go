@@ -0,0 +1,269 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/wave-k8s/wave/pkg/controller"
+	"github.com/wave-k8s/wave/pkg/core"
+	"github.com/wave-k8s/wave/test/utils"
+	admissionv1beta1 "k8s.io/api/admission/v1beta1"
+	admissionregistrationv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const (
+	// injectLabel selects the namespaces the fake injector mutates
+	injectLabel = "wave-e2e/inject"
+
+	// injectedAtAnnotation is stamped on the pod template by the fake
+	// injector on every write
+	injectedAtAnnotation = "sidecar.example.com/injected-at"
+)
+
+// injector is a fake sidecar injecting webhook. Like some real injectors, it
+// adds a sidecar container to the pod template of Deployments and stamps the
+// template on every write.
+type injector struct {
+	admissions int32
+}
+
+// jsonPatchOperation is an operation of a JSON patch
+type jsonPatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+func (i *injector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+	review := &admissionv1beta1.AdmissionReview{}
+	Expect(json.NewDecoder(r.Body).Decode(review)).To(Succeed())
+	deployment := &appsv1.Deployment{}
+	Expect(json.Unmarshal(review.Request.Object.Raw, deployment)).To(Succeed())
+
+	n := atomic.AddInt32(&i.admissions, 1)
+	var patch []jsonPatchOperation
+	if deployment.Spec.Template.GetAnnotations() == nil {
+		patch = append(patch, jsonPatchOperation{Op: "add", Path: "/spec/template/metadata/annotations", Value: map[string]string{}})
+	}
+	patch = append(patch, jsonPatchOperation{Op: "add", Path: "/spec/template/metadata/annotations/sidecar.example.com~1injected-at", Value: strconv.Itoa(int(n))})
+	if !hasSidecar(deployment) {
+		patch = append(patch, jsonPatchOperation{Op: "add", Path: "/spec/template/spec/containers/-", Value: corev1.Container{Name: "sidecar", Image: "sidecar"}})
+	}
+	data, err := json.Marshal(patch)
+	Expect(err).NotTo(HaveOccurred())
+
+	patchType := admissionv1beta1.PatchTypeJSONPatch
+	review.Response = &admissionv1beta1.AdmissionResponse{
+		UID:       review.Request.UID,
+		Allowed:   true,
+		Patch:     data,
+		PatchType: &patchType,
+	}
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	Expect(json.NewEncoder(w).Encode(review)).To(Succeed())
+}
+
+// hasSidecar returns true if the fake injector added its sidecar
+func hasSidecar(deployment *appsv1.Deployment) bool {
+	for _, container := range deployment.Spec.Template.Spec.Containers {
+		if container.Name == "sidecar" {
+			return true
+		}
+	}
+	return false
+}
+
+var _ = Describe("Wave sidecar injection Suite", func() {
+	var c client.Client
+	var m utils.Matcher
+	var mgrStopped *sync.WaitGroup
+	var stopMgr chan struct{}
+	var server *httptest.Server
+	var webhook *admissionregistrationv1beta1.MutatingWebhookConfiguration
+	var namespace string
+	var deployment *appsv1.Deployment
+	var cm1 *corev1.ConfigMap
+
+	const timeout = time.Second * 5
+	const consistentlyTimeout = time.Second * 3
+
+	BeforeEach(func() {
+		// Reset the Prometheus Registry before each test to avoid errors
+		metrics.Registry = prometheus.NewRegistry()
+
+		var err error
+		c, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
+		Expect(err).NotTo(HaveOccurred())
+		m = utils.Matcher{Client: c}
+
+		// Serve the fake injector and register it with the API server
+		server = httptest.NewTLSServer(&injector{})
+		url := server.URL + "/inject"
+		failurePolicy := admissionregistrationv1beta1.Fail
+		webhook = &admissionregistrationv1beta1.MutatingWebhookConfiguration{
+			ObjectMeta: metav1.ObjectMeta{GenerateName: "wave-e2e-injector-"},
+			Webhooks: []admissionregistrationv1beta1.Webhook{{
+				Name: "injector.wave-e2e.example.com",
+				ClientConfig: admissionregistrationv1beta1.WebhookClientConfig{
+					URL:      &url,
+					CABundle: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}),
+				},
+				Rules: []admissionregistrationv1beta1.RuleWithOperations{{
+					Operations: []admissionregistrationv1beta1.OperationType{admissionregistrationv1beta1.Create, admissionregistrationv1beta1.Update},
+					Rule: admissionregistrationv1beta1.Rule{
+						APIGroups:   []string{"apps"},
+						APIVersions: []string{"v1"},
+						Resources:   []string{"deployments"},
+					},
+				}},
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{injectLabel: "enabled"}},
+				FailurePolicy:     &failurePolicy,
+			}},
+		}
+		m.Create(webhook).Should(Succeed())
+
+		ns := &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{
+				GenerateName: "wave-e2e-",
+				Labels:       map[string]string{injectLabel: "enabled"},
+			},
+		}
+		m.Create(ns).Should(Succeed())
+		namespace = ns.GetName()
+
+		// The API server picks up new webhooks asynchronously, so wait until
+		// Deployments are injected
+		Eventually(func() bool {
+			probe := &appsv1.Deployment{}
+			utils.ExampleDeployment.DeepCopyInto(probe)
+			probe.ObjectMeta = metav1.ObjectMeta{Namespace: namespace, GenerateName: "probe-"}
+			if err := c.Create(context.TODO(), probe); err != nil {
+				return false
+			}
+			Expect(c.Delete(context.TODO(), probe)).To(Succeed())
+			return hasSidecar(probe)
+		}, timeout).Should(BeTrue())
+
+		mgr, err := manager.New(cfg, manager.Options{
+			MetricsBindAddress: "0",
+		})
+		Expect(err).NotTo(HaveOccurred())
+		Expect(controller.AddToManager(mgr)).To(Succeed())
+		stopMgr, mgrStopped = utils.StartTestManager(mgr)
+
+		for _, obj := range []core.Object{
+			utils.ExampleConfigMap1.DeepCopy(),
+			utils.ExampleConfigMap2.DeepCopy(),
+			utils.ExampleConfigMap3.DeepCopy(),
+			utils.ExampleSecret1.DeepCopy(),
+			utils.ExampleSecret2.DeepCopy(),
+			utils.ExampleSecret3.DeepCopy(),
+		} {
+			obj.SetNamespace(namespace)
+			m.Create(obj).Should(Succeed())
+			if cm, ok := obj.(*corev1.ConfigMap); ok && cm.GetName() == utils.ExampleConfigMap1.GetName() {
+				cm1 = cm
+			}
+		}
+
+		deployment = utils.ExampleDeployment.DeepCopy()
+		deployment.SetNamespace(namespace)
+		deployment.SetAnnotations(map[string]string{core.RequiredAnnotation: "true"})
+		m.Create(deployment).Should(Succeed())
+		m.Eventually(deployment, timeout).Should(utils.WithPodTemplateAnnotations(HaveKey(core.ConfigHashAnnotation)))
+	})
+
+	AfterEach(func() {
+		// Let Wave remove its finalizer before stopping it
+		m.Delete(deployment).Should(Succeed())
+		Eventually(func() error {
+			return c.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: deployment.GetName()}, &appsv1.Deployment{})
+		}, timeout).ShouldNot(Succeed())
+
+		utils.StopTestManager(stopMgr, mgrStopped)
+		m.Delete(webhook).Should(Succeed())
+		server.Close()
+
+		utils.DeleteAll(cfg, timeout,
+			&appsv1.DeploymentList{},
+			&corev1.ConfigMapList{},
+			&corev1.SecretList{},
+			&corev1.EventList{},
+		)
+	})
+
+	It("keeps the injected sidecar", func() {
+		m.Get(deployment, timeout).Should(Succeed())
+		Expect(hasSidecar(deployment)).To(BeTrue())
+		Expect(deployment.Spec.Template.GetAnnotations()).To(HaveKey(core.ConfigHashAnnotation))
+	})
+
+	It("doesn't update the Deployment again after the injector mutates it", func() {
+		m.Get(deployment, timeout).Should(Succeed())
+		resourceVersion := deployment.GetResourceVersion()
+		Expect(deployment.Spec.Template.GetAnnotations()).To(HaveKey(injectedAtAnnotation))
+
+		Consistently(func() string {
+			d := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: deployment.GetName()}, d)).To(Succeed())
+			return d.GetResourceVersion()
+		}, consistentlyTimeout).Should(Equal(resourceVersion))
+	})
+
+	It("updates the hash exactly once when the config changes", func() {
+		m.Get(deployment, timeout).Should(Succeed())
+		oldHash := deployment.Spec.Template.GetAnnotations()[core.ConfigHashAnnotation]
+
+		m.Update(cm1, func(obj utils.Object) utils.Object {
+			cm := obj.(*corev1.ConfigMap)
+			cm.Data["key1"] = "modified"
+			return cm
+		}, timeout).Should(Succeed())
+		m.Eventually(deployment, timeout).ShouldNot(utils.WithPodTemplateAnnotations(HaveKeyWithValue(core.ConfigHashAnnotation, oldHash)))
+
+		// The injector stamps Wave's write, which Wave must not undo
+		m.Get(deployment, timeout).Should(Succeed())
+		resourceVersion := deployment.GetResourceVersion()
+		Consistently(func() string {
+			d := &appsv1.Deployment{}
+			Expect(c.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: deployment.GetName()}, d)).To(Succeed())
+			return d.GetResourceVersion()
+		}, consistentlyTimeout).Should(Equal(resourceVersion))
+		Expect(hasSidecar(deployment)).To(BeTrue())
+	})
+})
@@ -0,0 +1,226 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// scaleLabel marks every object the tool generates
+const scaleLabel = "wave.pusher.com/scale-test"
+
+// spec describes the objects to generate
+type spec struct {
+	prefix     string
+	namespaces int
+	workloads  int
+	configMaps int
+	secrets    int
+	references int
+	skew       float64
+	seed       int64
+}
+
+// source identifies a ConfigMap or Secret
+type source struct {
+	kind string
+	types.NamespacedName
+}
+
+// fixtures holds the generated objects and which workloads reference each
+// ConfigMap and Secret
+type fixtures struct {
+	namespaces []*corev1.Namespace
+	configMaps []*corev1.ConfigMap
+	secrets    []*corev1.Secret
+	workloads  []*appsv1.Deployment
+	consumers  map[source][]types.NamespacedName
+}
+
+// references returns the number of references from workloads to sources
+func (f *fixtures) references() int {
+	count := 0
+	for _, consumers := range f.consumers {
+		count += len(consumers)
+	}
+	return count
+}
+
+// sources returns every source referenced by at least one workload
+func (f *fixtures) sources() []source {
+	var sources []source
+	for _, cm := range f.configMaps {
+		s := source{kind: "ConfigMap", NamespacedName: types.NamespacedName{Namespace: cm.GetNamespace(), Name: cm.GetName()}}
+		if len(f.consumers[s]) > 0 {
+			sources = append(sources, s)
+		}
+	}
+	for _, secret := range f.secrets {
+		s := source{kind: "Secret", NamespacedName: types.NamespacedName{Namespace: secret.GetNamespace(), Name: secret.GetName()}}
+		if len(f.consumers[s]) > 0 {
+			sources = append(sources, s)
+		}
+	}
+	return sources
+}
+
+// generate builds the fixtures described by the spec. Workloads pick the
+// sources they reference from a Zipf distribution, so that a few sources are
+// shared by many workloads and most by only a few, as in real clusters.
+func generate(s spec) *fixtures {
+	r := rand.New(rand.NewSource(s.seed))
+	f := &fixtures{consumers: make(map[source][]types.NamespacedName)}
+	labels := map[string]string{scaleLabel: "true"}
+
+	for n := 0; n < s.namespaces; n++ {
+		namespace := fmt.Sprintf("%s-%d", s.prefix, n)
+		f.namespaces = append(f.namespaces, &corev1.Namespace{
+			ObjectMeta: metav1.ObjectMeta{Name: namespace, Labels: labels},
+		})
+
+		var sources []source
+		for i := 0; i < s.configMaps; i++ {
+			cm := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: fmt.Sprintf("config-%d", i), Labels: labels},
+				Data:       map[string]string{"key": fmt.Sprintf("value-%d", r.Int63())},
+			}
+			f.configMaps = append(f.configMaps, cm)
+			sources = append(sources, source{kind: "ConfigMap", NamespacedName: types.NamespacedName{Namespace: namespace, Name: cm.GetName()}})
+		}
+		for i := 0; i < s.secrets; i++ {
+			secret := &corev1.Secret{
+				ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: fmt.Sprintf("secret-%d", i), Labels: labels},
+				Data:       map[string][]byte{"key": []byte(fmt.Sprintf("value-%d", r.Int63()))},
+			}
+			f.secrets = append(f.secrets, secret)
+			sources = append(sources, source{kind: "Secret", NamespacedName: types.NamespacedName{Namespace: namespace, Name: secret.GetName()}})
+		}
+		// Shuffle the sources so that both kinds are popular
+		r.Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+
+		for i := 0; i < s.workloads; i++ {
+			workload := newWorkload(namespace, fmt.Sprintf("workload-%d", i), labels)
+			key := types.NamespacedName{Namespace: namespace, Name: workload.GetName()}
+			for _, src := range pickSources(r, sources, s.references, s.skew) {
+				addReference(workload, src, len(f.consumers[src]))
+				f.consumers[src] = append(f.consumers[src], key)
+			}
+			f.workloads = append(f.workloads, workload)
+		}
+	}
+	return f
+}
+
+// pickSources picks up to n distinct sources, skewed towards the first ones
+func pickSources(r *rand.Rand, sources []source, n int, skew float64) []source {
+	if n > len(sources) {
+		n = len(sources)
+	}
+	if n == 0 {
+		return nil
+	}
+	zipf := rand.NewZipf(r, skew, 1, uint64(len(sources)-1))
+	picked := make(map[int]struct{})
+	var result []source
+	for len(result) < n {
+		i := int(zipf.Uint64())
+		if _, ok := picked[i]; ok {
+			// Fall back to a uniform pick to avoid spinning on popular sources
+			i = r.Intn(len(sources))
+			if _, ok := picked[i]; ok {
+				continue
+			}
+		}
+		picked[i] = struct{}{}
+		result = append(result, sources[i])
+	}
+	return result
+}
+
+// newWorkload returns a Deployment with the required annotation and no
+// references yet
+func newWorkload(namespace, name string, labels map[string]string) *appsv1.Deployment {
+	selector := map[string]string{"app": name}
+	replicas := int32(1)
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   namespace,
+			Name:        name,
+			Labels:      labels,
+			Annotations: map[string]string{core.RequiredAnnotation: "true"},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{MatchLabels: selector},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: selector},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "app", Image: "example.com/app:latest"}},
+				},
+			},
+		},
+	}
+}
+
+// addReference makes the workload reference the source, varying the way it
+// does so between volumes, envFrom and single keys
+func addReference(workload *appsv1.Deployment, src source, i int) {
+	podSpec := &workload.Spec.Template.Spec
+	container := &podSpec.Containers[0]
+	local := corev1.LocalObjectReference{Name: src.Name}
+	switch i % 3 {
+	case 0:
+		volume := corev1.Volume{Name: fmt.Sprintf("%s-%s", kindPrefix(src.kind), src.Name)}
+		if src.kind == "ConfigMap" {
+			volume.ConfigMap = &corev1.ConfigMapVolumeSource{LocalObjectReference: local}
+		} else {
+			volume.Secret = &corev1.SecretVolumeSource{SecretName: src.Name}
+		}
+		podSpec.Volumes = append(podSpec.Volumes, volume)
+	case 1:
+		envFrom := corev1.EnvFromSource{}
+		if src.kind == "ConfigMap" {
+			envFrom.ConfigMapRef = &corev1.ConfigMapEnvSource{LocalObjectReference: local}
+		} else {
+			envFrom.SecretRef = &corev1.SecretEnvSource{LocalObjectReference: local}
+		}
+		container.EnvFrom = append(container.EnvFrom, envFrom)
+	default:
+		env := corev1.EnvVar{Name: fmt.Sprintf("%s_%d", kindPrefix(src.kind), len(container.Env))}
+		if src.kind == "ConfigMap" {
+			env.ValueFrom = &corev1.EnvVarSource{ConfigMapKeyRef: &corev1.ConfigMapKeySelector{LocalObjectReference: local, Key: "key"}}
+		} else {
+			env.ValueFrom = &corev1.EnvVarSource{SecretKeyRef: &corev1.SecretKeySelector{LocalObjectReference: local, Key: "key"}}
+		}
+		container.Env = append(container.Env, env)
+	}
+}
+
+// kindPrefix returns a short prefix for names derived from the kind
+func kindPrefix(kind string) string {
+	if kind == "ConfigMap" {
+		return "cm"
+	}
+	return "secret"
+}
@@ -0,0 +1,84 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var _ = Describe("Wave scale Suite", func() {
+	s := spec{prefix: "scale", namespaces: 2, workloads: 20, configMaps: 10, secrets: 5, references: 4, skew: 1.1, seed: 1}
+
+	It("generates the objects of the spec", func() {
+		f := generate(s)
+		Expect(f.namespaces).To(HaveLen(2))
+		Expect(f.configMaps).To(HaveLen(20))
+		Expect(f.secrets).To(HaveLen(10))
+		Expect(f.workloads).To(HaveLen(40))
+		Expect(f.references()).To(Equal(160))
+		for _, w := range f.workloads {
+			Expect(w.GetAnnotations()).To(HaveKeyWithValue(core.RequiredAnnotation, "true"))
+		}
+	})
+
+	It("is deterministic", func() {
+		Expect(generate(s)).To(Equal(generate(s)))
+	})
+
+	It("records the consumers of each source", func() {
+		f := generate(s)
+		workloads := make(map[types.NamespacedName]*appsv1.Deployment)
+		for _, w := range f.workloads {
+			workloads[types.NamespacedName{Namespace: w.GetNamespace(), Name: w.GetName()}] = w
+		}
+		for _, src := range f.sources() {
+			for _, key := range f.consumers[src] {
+				Expect(key.Namespace).To(Equal(src.Namespace))
+				Expect(workloads).To(HaveKey(key))
+			}
+		}
+	})
+
+	It("shares popular sources between many workloads", func() {
+		f := generate(spec{prefix: "scale", namespaces: 1, workloads: 200, configMaps: 50, secrets: 50, references: 2, skew: 1.5, seed: 1})
+		most := 0
+		for _, consumers := range f.consumers {
+			if len(consumers) > most {
+				most = len(consumers)
+			}
+		}
+		// Uniform picks would reference each source 4 times on average
+		Expect(most).To(BeNumerically(">", 40))
+	})
+
+	It("computes percentiles", func() {
+		var latencies []time.Duration
+		for i := 100; i > 0; i-- {
+			latencies = append(latencies, time.Duration(i)*time.Millisecond)
+		}
+		Expect(percentile(latencies, 50)).To(Equal(50 * time.Millisecond))
+		Expect(percentile(latencies, 99)).To(Equal(99 * time.Millisecond))
+		Expect(percentile(latencies, 100)).To(Equal(100 * time.Millisecond))
+		Expect(percentile(nil, 50)).To(BeZero())
+	})
+})
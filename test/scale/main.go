@@ -0,0 +1,304 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Command scale measures the throughput, latency and memory use of Wave
+// against generated workloads, ConfigMaps and Secrets. It is meant to run
+// against a disposable cluster, such as one created by kwokctl, see
+// `make scale`.
+package main
+
+import (
+	"context"
+	goflag "flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/wave-k8s/wave/pkg/apis"
+	"github.com/wave-k8s/wave/pkg/controller"
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	_ "k8s.io/client-go/plugin/pkg/client/auth"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+)
+
+var (
+	prefix     = flag.String("prefix", "wave-scale", "Prefix of the names of the generated namespaces")
+	namespaces = flag.Int("namespaces", 10, "Number of namespaces to generate")
+	workloads  = flag.Int("workloads", 100, "Number of Deployments to generate in each namespace")
+	configMaps = flag.Int("configmaps", 50, "Number of ConfigMaps to generate in each namespace")
+	secrets    = flag.Int("secrets", 20, "Number of Secrets to generate in each namespace")
+	references = flag.Int("references", 4, "Number of ConfigMaps and Secrets each Deployment references")
+	skew       = flag.Float64("skew", 1.1, "Skew of the Zipf distribution of references, higher values share fewer sources between more workloads (must be greater than 1)")
+	updates    = flag.Int("updates", 50, "Number of ConfigMaps and Secrets to update, one at a time, to measure rollout latency")
+	seed       = flag.Int64("seed", 1, "Seed of the generated objects and updates")
+	workers    = flag.Int("workers", 20, "Number of objects to create concurrently")
+	timeout    = flag.Duration("timeout", 10*time.Minute, "Maximum time to wait for the initial sync, and for each update to roll out")
+	keep       = flag.Bool("keep", false, "Keep the generated namespaces after the run")
+)
+
+func main() {
+	flag.CommandLine.AddGoFlagSet(goflag.CommandLine)
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// run generates the fixtures, runs Wave against them and prints the report
+func run() error {
+	if *skew <= 1 {
+		return fmt.Errorf("--skew must be greater than 1")
+	}
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return fmt.Errorf("error loading kubeconfig: %v", err)
+	}
+	c, err := client.New(cfg, client.Options{})
+	if err != nil {
+		return fmt.Errorf("error creating client: %v", err)
+	}
+	ctx := context.Background()
+
+	f := generate(spec{
+		prefix:     *prefix,
+		namespaces: *namespaces,
+		workloads:  *workloads,
+		configMaps: *configMaps,
+		secrets:    *secrets,
+		references: *references,
+		skew:       *skew,
+		seed:       *seed,
+	})
+	r := &report{
+		workloads:  len(f.workloads),
+		namespaces: len(f.namespaces),
+		configMaps: len(f.configMaps),
+		secrets:    len(f.secrets),
+		references: f.references(),
+	}
+
+	fmt.Printf("Creating %d namespaces, %d ConfigMaps, %d Secrets and %d Deployments\n", r.namespaces, r.configMaps, r.secrets, r.workloads)
+	if !*keep {
+		defer cleanup(ctx, c, f)
+	}
+	if err := createAll(ctx, c, f); err != nil {
+		return err
+	}
+
+	// Run Wave in this process so that its memory can be measured
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	if err != nil {
+		return fmt.Errorf("error creating manager: %v", err)
+	}
+	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+		return fmt.Errorf("error adding APIs to scheme: %v", err)
+	}
+	if err := controller.AddToManager(mgr, core.WithReconcileTimeout(time.Minute)); err != nil {
+		return fmt.Errorf("error adding controllers: %v", err)
+	}
+	informer, err := mgr.GetCache().GetInformer(&appsv1.Deployment{})
+	if err != nil {
+		return fmt.Errorf("error getting Deployment informer: %v", err)
+	}
+	obs := newObserver(time.Now)
+	informer.AddEventHandler(obs)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	heap := &heapSampler{}
+	go heap.run(100*time.Millisecond, stop)
+
+	fmt.Println("Starting Wave")
+	start := time.Now()
+	errs := make(chan error, 1)
+	go func() {
+		errs <- mgr.Start(stop)
+	}()
+
+	keys := make([]types.NamespacedName, 0, len(f.workloads))
+	for _, w := range f.workloads {
+		keys = append(keys, types.NamespacedName{Namespace: w.GetNamespace(), Name: w.GetName()})
+	}
+	err = wait.PollImmediate(100*time.Millisecond, *timeout, func() (bool, error) {
+		select {
+		case err := <-errs:
+			return false, fmt.Errorf("error running manager: %v", err)
+		default:
+		}
+		return obs.hashed(keys) == len(keys), nil
+	})
+	if err != nil {
+		return fmt.Errorf("error waiting for the initial sync (%d of %d workloads hashed): %v", obs.hashed(keys), len(keys), err)
+	}
+	r.syncDuration = time.Since(start)
+	r.heapAfterSync = heap.sample()
+	fmt.Printf("Initial sync completed in %s\n", r.syncDuration.Round(time.Millisecond))
+
+	// Update sources one at a time and time the rollouts they trigger
+	rnd := rand.New(rand.NewSource(*seed))
+	sources := f.sources()
+	for i := 0; i < *updates && len(sources) > 0; i++ {
+		src := sources[rnd.Intn(len(sources))]
+		latencies, timeouts, err := measureUpdate(ctx, c, obs, src, f.consumers[src], i)
+		if err != nil {
+			return err
+		}
+		r.updates++
+		r.latencies = append(r.latencies, latencies...)
+		r.timeouts += timeouts
+	}
+	r.heapPeak = heap.max()
+
+	fmt.Println()
+	r.write(os.Stdout)
+	return nil
+}
+
+// createAll creates the fixtures, with up to --workers creates in flight
+func createAll(ctx context.Context, c client.Client, f *fixtures) error {
+	var objs []runtime.Object
+	for _, ns := range f.namespaces {
+		objs = append(objs, ns)
+	}
+	if err := createConcurrently(ctx, c, objs); err != nil {
+		return err
+	}
+
+	objs = nil
+	for _, cm := range f.configMaps {
+		objs = append(objs, cm)
+	}
+	for _, secret := range f.secrets {
+		objs = append(objs, secret)
+	}
+	for _, w := range f.workloads {
+		objs = append(objs, w)
+	}
+	return createConcurrently(ctx, c, objs)
+}
+
+// createConcurrently creates the objects, returning the first error
+func createConcurrently(ctx context.Context, c client.Client, objs []runtime.Object) error {
+	queue := make(chan runtime.Object)
+	errs := make(chan error, len(objs))
+	wg := sync.WaitGroup{}
+	for i := 0; i < *workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for obj := range queue {
+				if err := c.Create(ctx, obj); err != nil && !errors.IsAlreadyExists(err) {
+					errs <- fmt.Errorf("error creating object: %v", err)
+				}
+			}
+		}()
+	}
+	for _, obj := range objs {
+		queue <- obj
+	}
+	close(queue)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return err
+	default:
+		return nil
+	}
+}
+
+// measureUpdate changes the data of the source and waits for the hash of
+// each of its consumers to change, returning how long each took to change
+// and how many never did within --timeout
+func measureUpdate(ctx context.Context, c client.Client, obs *observer, src source, consumers []types.NamespacedName, i int) ([]time.Duration, int, error) {
+	before := make(map[types.NamespacedName]string)
+	for _, key := range consumers {
+		before[key], _ = obs.hash(key)
+	}
+
+	start := time.Now()
+	if err := updateSource(ctx, c, src, i); err != nil {
+		return nil, 0, err
+	}
+
+	var latencies []time.Duration
+	pending := consumers
+	deadline := start.Add(*timeout)
+	for len(pending) > 0 && time.Now().Before(deadline) {
+		var still []types.NamespacedName
+		for _, key := range pending {
+			hash, changed := obs.hash(key)
+			if hash == before[key] {
+				still = append(still, key)
+				continue
+			}
+			latencies = append(latencies, changed.Sub(start))
+		}
+		pending = still
+		if len(pending) > 0 {
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+	return latencies, len(pending), nil
+}
+
+// updateSource changes the data of the ConfigMap or Secret
+func updateSource(ctx context.Context, c client.Client, src source, i int) error {
+	value := fmt.Sprintf("update-%d-%d", i, time.Now().UnixNano())
+	if src.kind == "ConfigMap" {
+		cm := &corev1.ConfigMap{}
+		if err := c.Get(ctx, src.NamespacedName, cm); err != nil {
+			return fmt.Errorf("error getting ConfigMap %s: %v", src.NamespacedName, err)
+		}
+		cm.Data = map[string]string{"key": value}
+		if err := c.Update(ctx, cm); err != nil {
+			return fmt.Errorf("error updating ConfigMap %s: %v", src.NamespacedName, err)
+		}
+		return nil
+	}
+	secret := &corev1.Secret{}
+	if err := c.Get(ctx, src.NamespacedName, secret); err != nil {
+		return fmt.Errorf("error getting Secret %s: %v", src.NamespacedName, err)
+	}
+	secret.Data = map[string][]byte{"key": []byte(value)}
+	if err := c.Update(ctx, secret); err != nil {
+		return fmt.Errorf("error updating Secret %s: %v", src.NamespacedName, err)
+	}
+	return nil
+}
+
+// cleanup deletes the generated namespaces and everything in them
+func cleanup(ctx context.Context, c client.Client, f *fixtures) {
+	fmt.Printf("Deleting %d namespaces\n", len(f.namespaces))
+	for _, ns := range f.namespaces {
+		if err := c.Delete(ctx, ns); err != nil && !errors.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "Error deleting namespace %s: %v\n", ns.GetName(), err)
+		}
+	}
+}
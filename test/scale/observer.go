@@ -0,0 +1,94 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"sync"
+	"time"
+
+	"github.com/wave-k8s/wave/pkg/core"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// observer records when the configuration hash of each workload changes, as
+// seen by an informer
+type observer struct {
+	mutex   sync.Mutex
+	hashes  map[types.NamespacedName]string
+	changed map[types.NamespacedName]time.Time
+	now     func() time.Time
+}
+
+// newObserver constructs an observer which reads the time from now
+func newObserver(now func() time.Time) *observer {
+	return &observer{
+		hashes:  make(map[types.NamespacedName]string),
+		changed: make(map[types.NamespacedName]time.Time),
+		now:     now,
+	}
+}
+
+// OnAdd implements cache.ResourceEventHandler
+func (o *observer) OnAdd(obj interface{}) {
+	o.observe(obj)
+}
+
+// OnUpdate implements cache.ResourceEventHandler
+func (o *observer) OnUpdate(oldObj, newObj interface{}) {
+	o.observe(newObj)
+}
+
+// OnDelete implements cache.ResourceEventHandler
+func (o *observer) OnDelete(obj interface{}) {}
+
+// observe records the hash of the Deployment, if it changed
+func (o *observer) observe(obj interface{}) {
+	d, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return
+	}
+	key := types.NamespacedName{Namespace: d.GetNamespace(), Name: d.GetName()}
+	hash := d.Spec.Template.GetAnnotations()[core.ConfigHashAnnotation]
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.hashes[key] != hash {
+		o.hashes[key] = hash
+		o.changed[key] = o.now()
+	}
+}
+
+// hash returns the last hash seen for the workload and when it changed
+func (o *observer) hash(key types.NamespacedName) (string, time.Time) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.hashes[key], o.changed[key]
+}
+
+// hashed returns the number of the workloads which have a hash
+func (o *observer) hashed(keys []types.NamespacedName) int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	count := 0
+	for _, key := range keys {
+		if o.hashes[key] != "" {
+			count++
+		}
+	}
+	return count
+}
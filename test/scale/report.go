@@ -0,0 +1,126 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+)
+
+// report holds the measurements of a run
+type report struct {
+	workloads  int
+	namespaces int
+	configMaps int
+	secrets    int
+	references int
+
+	syncDuration time.Duration
+	updates      int
+	latencies    []time.Duration
+	timeouts     int
+
+	heapAfterSync uint64
+	heapPeak      uint64
+}
+
+// percentile returns the latency below which the given percentage of the
+// latencies fall
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration{}, latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	i := int(float64(len(sorted))*p/100+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// write prints the report in a human readable form
+func (r *report) write(w io.Writer) {
+	fmt.Fprintf(w, "Workloads:       %d in %d namespaces\n", r.workloads, r.namespaces)
+	fmt.Fprintf(w, "Sources:         %d ConfigMaps, %d Secrets, %d references\n", r.configMaps, r.secrets, r.references)
+	throughput := 0.0
+	if r.syncDuration > 0 {
+		throughput = float64(r.workloads) / r.syncDuration.Seconds()
+	}
+	fmt.Fprintf(w, "Initial sync:    %s (%.1f workloads/s)\n", r.syncDuration.Round(time.Millisecond), throughput)
+	fmt.Fprintf(w, "Update latency:  p50 %s, p90 %s, p99 %s, max %s over %d rollouts of %d updates\n",
+		percentile(r.latencies, 50).Round(time.Millisecond),
+		percentile(r.latencies, 90).Round(time.Millisecond),
+		percentile(r.latencies, 99).Round(time.Millisecond),
+		percentile(r.latencies, 100).Round(time.Millisecond),
+		len(r.latencies), r.updates)
+	if r.timeouts > 0 {
+		fmt.Fprintf(w, "Timed out:       %d rollouts\n", r.timeouts)
+	}
+	fmt.Fprintf(w, "Heap in use:     %s after sync, %s peak\n", mebibytes(r.heapAfterSync), mebibytes(r.heapPeak))
+}
+
+// mebibytes formats a number of bytes in MiB
+func mebibytes(bytes uint64) string {
+	return fmt.Sprintf("%.1fMiB", float64(bytes)/(1<<20))
+}
+
+// heapSampler records the peak heap in use of the process, which runs Wave
+type heapSampler struct {
+	mutex sync.Mutex
+	peak  uint64
+}
+
+// sample records the current heap in use and returns it
+func (s *heapSampler) sample() uint64 {
+	stats := runtime.MemStats{}
+	runtime.ReadMemStats(&stats)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if stats.HeapInuse > s.peak {
+		s.peak = stats.HeapInuse
+	}
+	return stats.HeapInuse
+}
+
+// run samples the heap every interval until stop is closed
+func (s *heapSampler) run(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// max returns the peak heap in use sampled
+func (s *heapSampler) max() uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.peak
+}
@@ -0,0 +1,113 @@
+/*
+Copyright 2018 Pusher Ltd. and Wave Contributors
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package utils
+
+import (
+	"context"
+	"log"
+	"path/filepath"
+	"sync"
+
+	"github.com/go-logr/glogr"
+	"github.com/onsi/ginkgo"
+	g "github.com/onsi/gomega"
+	"github.com/wave-k8s/wave/pkg/apis"
+	"github.com/wave-k8s/wave/pkg/webhook"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+)
+
+// StartEnvironment starts an envtest control plane with Wave's CRDs
+// installed and returns it with its config. root is the path from the
+// calling test suite to the root of the repository. apiServerFlags, if
+// given, replace envtest's default API server flags.
+func StartEnvironment(root string, apiServerFlags ...string) (*envtest.Environment, *rest.Config) {
+	t := &envtest.Environment{
+		CRDDirectoryPaths:  []string{filepath.Join(root, "config", "crds")},
+		KubeAPIServerFlags: apiServerFlags,
+	}
+	apis.AddToScheme(scheme.Scheme)
+
+	logf.SetLogger(glogr.New())
+
+	cfg, err := t.Start()
+	if err != nil {
+		log.Fatal(err)
+	}
+	return t, cfg
+}
+
+// StopEnvironment stops the envtest control plane
+func StopEnvironment(t *envtest.Environment) {
+	if err := t.Stop(); err != nil {
+		log.Printf("error stopping test environment: %v", err)
+	}
+}
+
+// SetupTestReconcile returns a reconcile.Reconcile implementation that delegates to inner and
+// writes the request to requests after Reconcile is finished.
+func SetupTestReconcile(inner reconcile.Reconciler) (reconcile.Reconciler, chan reconcile.Request) {
+	requests := make(chan reconcile.Request)
+	fn := reconcile.Func(func(req reconcile.Request) (reconcile.Result, error) {
+		result, err := inner.Reconcile(req)
+		requests <- req
+		return result, err
+	})
+	return fn, requests
+}
+
+// StartTestManager registers Wave's webhooks with the manager and starts it.
+// Close the returned channel and wait on the WaitGroup to stop it.
+func StartTestManager(mgr manager.Manager) (chan struct{}, *sync.WaitGroup) {
+	g.Expect(webhook.AddToManager(mgr)).To(g.Succeed())
+
+	stop := make(chan struct{})
+	wg := &sync.WaitGroup{}
+	wg.Add(1)
+	go func() {
+		defer ginkgo.GinkgoRecover()
+		g.Expect(mgr.Start(stop)).NotTo(g.HaveOccurred())
+		wg.Done()
+	}()
+	return stop, wg
+}
+
+// StopTestManager stops a manager started by StartTestManager and waits for
+// it to exit
+func StopTestManager(stop chan struct{}, wg *sync.WaitGroup) {
+	close(stop)
+	wg.Wait()
+}
+
+// NewNamespace creates a Namespace with a unique name so that a spec's
+// objects are isolated from those of other specs. The test control plane
+// does not run the namespace controller, so objects are not removed when the
+// Namespace is deleted: use DeleteAll to remove them.
+func NewNamespace(c client.Client) string {
+	ns := &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{GenerateName: "wave-test-"},
+	}
+	g.Expect(c.Create(context.TODO(), ns)).To(g.Succeed())
+	return ns.GetName()
+}